@@ -25,6 +25,7 @@ type SetConnectionRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	MachineName   string                 `protobuf:"bytes,1,opt,name=machineName,proto3" json:"machineName,omitempty"`
 	Addr          string                 `protobuf:"bytes,2,opt,name=addr,proto3" json:"addr,omitempty"`
+	Hardware      *NodeHardware          `protobuf:"bytes,3,opt,name=hardware,proto3" json:"hardware,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -73,6 +74,106 @@ func (x *SetConnectionRequest) GetAddr() string {
 	return ""
 }
 
+func (x *SetConnectionRequest) GetHardware() *NodeHardware {
+	if x != nil {
+		return x.Hardware
+	}
+	return nil
+}
+
+// shown to the admin while the slave waits for approval
+type NodeHardware struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hostname      string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Os            string                 `protobuf:"bytes,2,opt,name=os,proto3" json:"os,omitempty"`
+	Kernel        string                 `protobuf:"bytes,3,opt,name=kernel,proto3" json:"kernel,omitempty"`
+	CpuModel      string                 `protobuf:"bytes,4,opt,name=cpuModel,proto3" json:"cpuModel,omitempty"`
+	CpuCores      int32                  `protobuf:"varint,5,opt,name=cpuCores,proto3" json:"cpuCores,omitempty"`
+	CpuThreads    int32                  `protobuf:"varint,6,opt,name=cpuThreads,proto3" json:"cpuThreads,omitempty"`
+	MemoryMB      int64                  `protobuf:"varint,7,opt,name=memoryMB,proto3" json:"memoryMB,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NodeHardware) Reset() {
+	*x = NodeHardware{}
+	mi := &file_protocol_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeHardware) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeHardware) ProtoMessage() {}
+
+func (x *NodeHardware) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeHardware.ProtoReflect.Descriptor instead.
+func (*NodeHardware) Descriptor() ([]byte, []int) {
+	return file_protocol_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *NodeHardware) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *NodeHardware) GetOs() string {
+	if x != nil {
+		return x.Os
+	}
+	return ""
+}
+
+func (x *NodeHardware) GetKernel() string {
+	if x != nil {
+		return x.Kernel
+	}
+	return ""
+}
+
+func (x *NodeHardware) GetCpuModel() string {
+	if x != nil {
+		return x.CpuModel
+	}
+	return ""
+}
+
+func (x *NodeHardware) GetCpuCores() int32 {
+	if x != nil {
+		return x.CpuCores
+	}
+	return 0
+}
+
+func (x *NodeHardware) GetCpuThreads() int32 {
+	if x != nil {
+		return x.CpuThreads
+	}
+	return 0
+}
+
+func (x *NodeHardware) GetMemoryMB() int64 {
+	if x != nil {
+		return x.MemoryMB
+	}
+	return 0
+}
+
 type SetConnectionResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Ok            string                 `protobuf:"bytes,1,opt,name=ok,proto3" json:"ok,omitempty"`
@@ -82,7 +183,7 @@ type SetConnectionResponse struct {
 
 func (x *SetConnectionResponse) Reset() {
 	*x = SetConnectionResponse{}
-	mi := &file_protocol_proto_msgTypes[1]
+	mi := &file_protocol_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -94,7 +195,7 @@ func (x *SetConnectionResponse) String() string {
 func (*SetConnectionResponse) ProtoMessage() {}
 
 func (x *SetConnectionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_protocol_proto_msgTypes[1]
+	mi := &file_protocol_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -107,7 +208,7 @@ func (x *SetConnectionResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetConnectionResponse.ProtoReflect.Descriptor instead.
 func (*SetConnectionResponse) Descriptor() ([]byte, []int) {
-	return file_protocol_proto_rawDescGZIP(), []int{1}
+	return file_protocol_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *SetConnectionResponse) GetOk() string {
@@ -126,7 +227,7 @@ type NotifyRequest struct {
 
 func (x *NotifyRequest) Reset() {
 	*x = NotifyRequest{}
-	mi := &file_protocol_proto_msgTypes[2]
+	mi := &file_protocol_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -138,7 +239,7 @@ func (x *NotifyRequest) String() string {
 func (*NotifyRequest) ProtoMessage() {}
 
 func (x *NotifyRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_protocol_proto_msgTypes[2]
+	mi := &file_protocol_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -151,7 +252,7 @@ func (x *NotifyRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NotifyRequest.ProtoReflect.Descriptor instead.
 func (*NotifyRequest) Descriptor() ([]byte, []int) {
-	return file_protocol_proto_rawDescGZIP(), []int{2}
+	return file_protocol_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *NotifyRequest) GetText() string {
@@ -170,7 +271,7 @@ type NotifyResponse struct {
 
 func (x *NotifyResponse) Reset() {
 	*x = NotifyResponse{}
-	mi := &file_protocol_proto_msgTypes[3]
+	mi := &file_protocol_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -182,7 +283,7 @@ func (x *NotifyResponse) String() string {
 func (*NotifyResponse) ProtoMessage() {}
 
 func (x *NotifyResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_protocol_proto_msgTypes[3]
+	mi := &file_protocol_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -195,7 +296,7 @@ func (x *NotifyResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NotifyResponse.ProtoReflect.Descriptor instead.
 func (*NotifyResponse) Descriptor() ([]byte, []int) {
-	return file_protocol_proto_rawDescGZIP(), []int{3}
+	return file_protocol_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *NotifyResponse) GetOk() string {
@@ -205,25 +306,307 @@ func (x *NotifyResponse) GetOk() string {
 	return ""
 }
 
+// csr and certificates are PEM encoded
+type EnrollRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	MachineName   string                 `protobuf:"bytes,2,opt,name=machineName,proto3" json:"machineName,omitempty"`
+	Addr          string                 `protobuf:"bytes,3,opt,name=addr,proto3" json:"addr,omitempty"`
+	Csr           []byte                 `protobuf:"bytes,4,opt,name=csr,proto3" json:"csr,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EnrollRequest) Reset() {
+	*x = EnrollRequest{}
+	mi := &file_protocol_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnrollRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnrollRequest) ProtoMessage() {}
+
+func (x *EnrollRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnrollRequest.ProtoReflect.Descriptor instead.
+func (*EnrollRequest) Descriptor() ([]byte, []int) {
+	return file_protocol_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *EnrollRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *EnrollRequest) GetMachineName() string {
+	if x != nil {
+		return x.MachineName
+	}
+	return ""
+}
+
+func (x *EnrollRequest) GetAddr() string {
+	if x != nil {
+		return x.Addr
+	}
+	return ""
+}
+
+func (x *EnrollRequest) GetCsr() []byte {
+	if x != nil {
+		return x.Csr
+	}
+	return nil
+}
+
+type RenewCertificateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Csr           []byte                 `protobuf:"bytes,1,opt,name=csr,proto3" json:"csr,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenewCertificateRequest) Reset() {
+	*x = RenewCertificateRequest{}
+	mi := &file_protocol_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenewCertificateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenewCertificateRequest) ProtoMessage() {}
+
+func (x *RenewCertificateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenewCertificateRequest.ProtoReflect.Descriptor instead.
+func (*RenewCertificateRequest) Descriptor() ([]byte, []int) {
+	return file_protocol_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RenewCertificateRequest) GetCsr() []byte {
+	if x != nil {
+		return x.Csr
+	}
+	return nil
+}
+
+type CertificateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Certificate   []byte                 `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	CaCertificate []byte                 `protobuf:"bytes,2,opt,name=caCertificate,proto3" json:"caCertificate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CertificateResponse) Reset() {
+	*x = CertificateResponse{}
+	mi := &file_protocol_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CertificateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CertificateResponse) ProtoMessage() {}
+
+func (x *CertificateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CertificateResponse.ProtoReflect.Descriptor instead.
+func (*CertificateResponse) Descriptor() ([]byte, []int) {
+	return file_protocol_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CertificateResponse) GetCertificate() []byte {
+	if x != nil {
+		return x.Certificate
+	}
+	return nil
+}
+
+func (x *CertificateResponse) GetCaCertificate() []byte {
+	if x != nil {
+		return x.CaCertificate
+	}
+	return nil
+}
+
+type RotateCertificateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RotateCertificateRequest) Reset() {
+	*x = RotateCertificateRequest{}
+	mi := &file_protocol_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RotateCertificateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RotateCertificateRequest) ProtoMessage() {}
+
+func (x *RotateCertificateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RotateCertificateRequest.ProtoReflect.Descriptor instead.
+func (*RotateCertificateRequest) Descriptor() ([]byte, []int) {
+	return file_protocol_proto_rawDescGZIP(), []int{8}
+}
+
+type RotateCertificateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Serial        string                 `protobuf:"bytes,1,opt,name=serial,proto3" json:"serial,omitempty"`
+	NotAfter      string                 `protobuf:"bytes,2,opt,name=notAfter,proto3" json:"notAfter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RotateCertificateResponse) Reset() {
+	*x = RotateCertificateResponse{}
+	mi := &file_protocol_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RotateCertificateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RotateCertificateResponse) ProtoMessage() {}
+
+func (x *RotateCertificateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RotateCertificateResponse.ProtoReflect.Descriptor instead.
+func (*RotateCertificateResponse) Descriptor() ([]byte, []int) {
+	return file_protocol_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *RotateCertificateResponse) GetSerial() string {
+	if x != nil {
+		return x.Serial
+	}
+	return ""
+}
+
+func (x *RotateCertificateResponse) GetNotAfter() string {
+	if x != nil {
+		return x.NotAfter
+	}
+	return ""
+}
+
 var File_protocol_proto protoreflect.FileDescriptor
 
 const file_protocol_proto_rawDesc = "" +
 	"\n" +
-	"\x0eprotocol.proto\x12\bprotocol\"L\n" +
+	"\x0eprotocol.proto\x12\bprotocol\"\x80\x01\n" +
 	"\x14SetConnectionRequest\x12 \n" +
 	"\vmachineName\x18\x01 \x01(\tR\vmachineName\x12\x12\n" +
-	"\x04addr\x18\x02 \x01(\tR\x04addr\"'\n" +
+	"\x04addr\x18\x02 \x01(\tR\x04addr\x122\n" +
+	"\bhardware\x18\x03 \x01(\v2\x16.protocol.NodeHardwareR\bhardware\"\xc6\x01\n" +
+	"\fNodeHardware\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12\x0e\n" +
+	"\x02os\x18\x02 \x01(\tR\x02os\x12\x16\n" +
+	"\x06kernel\x18\x03 \x01(\tR\x06kernel\x12\x1a\n" +
+	"\bcpuModel\x18\x04 \x01(\tR\bcpuModel\x12\x1a\n" +
+	"\bcpuCores\x18\x05 \x01(\x05R\bcpuCores\x12\x1e\n" +
+	"\n" +
+	"cpuThreads\x18\x06 \x01(\x05R\n" +
+	"cpuThreads\x12\x1a\n" +
+	"\bmemoryMB\x18\a \x01(\x03R\bmemoryMB\"'\n" +
 	"\x15SetConnectionResponse\x12\x0e\n" +
 	"\x02ok\x18\x01 \x01(\tR\x02ok\"#\n" +
 	"\rNotifyRequest\x12\x12\n" +
 	"\x04text\x18\x01 \x01(\tR\x04text\" \n" +
 	"\x0eNotifyResponse\x12\x0e\n" +
-	"\x02ok\x18\x01 \x01(\tR\x02ok2\xa0\x01\n" +
+	"\x02ok\x18\x01 \x01(\tR\x02ok\"m\n" +
+	"\rEnrollRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12 \n" +
+	"\vmachineName\x18\x02 \x01(\tR\vmachineName\x12\x12\n" +
+	"\x04addr\x18\x03 \x01(\tR\x04addr\x12\x10\n" +
+	"\x03csr\x18\x04 \x01(\fR\x03csr\"+\n" +
+	"\x17RenewCertificateRequest\x12\x10\n" +
+	"\x03csr\x18\x01 \x01(\fR\x03csr\"]\n" +
+	"\x13CertificateResponse\x12 \n" +
+	"\vcertificate\x18\x01 \x01(\fR\vcertificate\x12$\n" +
+	"\rcaCertificate\x18\x02 \x01(\fR\rcaCertificate\"\x1a\n" +
+	"\x18RotateCertificateRequest\"O\n" +
+	"\x19RotateCertificateResponse\x12\x16\n" +
+	"\x06serial\x18\x01 \x01(\tR\x06serial\x12\x1a\n" +
+	"\bnotAfter\x18\x02 \x01(\tR\bnotAfter2\xf6\x01\n" +
 	"\x0fProtocolService\x12P\n" +
 	"\rSetConnection\x12\x1e.protocol.SetConnectionRequest\x1a\x1f.protocol.SetConnectionResponse\x12;\n" +
-	"\x06Notify\x12\x17.protocol.NotifyRequest\x1a\x18.protocol.NotifyResponse2L\n" +
+	"\x06Notify\x12\x17.protocol.NotifyRequest\x1a\x18.protocol.NotifyResponse\x12T\n" +
+	"\x10RenewCertificate\x12!.protocol.RenewCertificateRequest\x1a\x1d.protocol.CertificateResponse2Q\n" +
+	"\rEnrollService\x12@\n" +
+	"\x06Enroll\x12\x17.protocol.EnrollRequest\x1a\x1d.protocol.CertificateResponse2\xaa\x01\n" +
 	"\rClientService\x12;\n" +
-	"\x06Notify\x12\x17.protocol.NotifyRequest\x1a\x18.protocol.NotifyResponseB6Z4github.com/Maruqes/512SvMan/api/proto/protocol;protob\x06proto3"
+	"\x06Notify\x12\x17.protocol.NotifyRequest\x1a\x18.protocol.NotifyResponse\x12\\\n" +
+	"\x11RotateCertificate\x12\".protocol.RotateCertificateRequest\x1a#.protocol.RotateCertificateResponseB6Z4github.com/Maruqes/512SvMan/api/proto/protocol;protob\x06proto3"
 
 var (
 	file_protocol_proto_rawDescOnce sync.Once
@@ -237,25 +620,38 @@ func file_protocol_proto_rawDescGZIP() []byte {
 	return file_protocol_proto_rawDescData
 }
 
-var file_protocol_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_protocol_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
 var file_protocol_proto_goTypes = []any{
-	(*SetConnectionRequest)(nil),  // 0: protocol.SetConnectionRequest
-	(*SetConnectionResponse)(nil), // 1: protocol.SetConnectionResponse
-	(*NotifyRequest)(nil),         // 2: protocol.NotifyRequest
-	(*NotifyResponse)(nil),        // 3: protocol.NotifyResponse
+	(*SetConnectionRequest)(nil),      // 0: protocol.SetConnectionRequest
+	(*NodeHardware)(nil),              // 1: protocol.NodeHardware
+	(*SetConnectionResponse)(nil),     // 2: protocol.SetConnectionResponse
+	(*NotifyRequest)(nil),             // 3: protocol.NotifyRequest
+	(*NotifyResponse)(nil),            // 4: protocol.NotifyResponse
+	(*EnrollRequest)(nil),             // 5: protocol.EnrollRequest
+	(*RenewCertificateRequest)(nil),   // 6: protocol.RenewCertificateRequest
+	(*CertificateResponse)(nil),       // 7: protocol.CertificateResponse
+	(*RotateCertificateRequest)(nil),  // 8: protocol.RotateCertificateRequest
+	(*RotateCertificateResponse)(nil), // 9: protocol.RotateCertificateResponse
 }
 var file_protocol_proto_depIdxs = []int32{
-	0, // 0: protocol.ProtocolService.SetConnection:input_type -> protocol.SetConnectionRequest
-	2, // 1: protocol.ProtocolService.Notify:input_type -> protocol.NotifyRequest
-	2, // 2: protocol.ClientService.Notify:input_type -> protocol.NotifyRequest
-	1, // 3: protocol.ProtocolService.SetConnection:output_type -> protocol.SetConnectionResponse
-	3, // 4: protocol.ProtocolService.Notify:output_type -> protocol.NotifyResponse
-	3, // 5: protocol.ClientService.Notify:output_type -> protocol.NotifyResponse
-	3, // [3:6] is the sub-list for method output_type
-	0, // [0:3] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	1, // 0: protocol.SetConnectionRequest.hardware:type_name -> protocol.NodeHardware
+	0, // 1: protocol.ProtocolService.SetConnection:input_type -> protocol.SetConnectionRequest
+	3, // 2: protocol.ProtocolService.Notify:input_type -> protocol.NotifyRequest
+	6, // 3: protocol.ProtocolService.RenewCertificate:input_type -> protocol.RenewCertificateRequest
+	5, // 4: protocol.EnrollService.Enroll:input_type -> protocol.EnrollRequest
+	3, // 5: protocol.ClientService.Notify:input_type -> protocol.NotifyRequest
+	8, // 6: protocol.ClientService.RotateCertificate:input_type -> protocol.RotateCertificateRequest
+	2, // 7: protocol.ProtocolService.SetConnection:output_type -> protocol.SetConnectionResponse
+	4, // 8: protocol.ProtocolService.Notify:output_type -> protocol.NotifyResponse
+	7, // 9: protocol.ProtocolService.RenewCertificate:output_type -> protocol.CertificateResponse
+	7, // 10: protocol.EnrollService.Enroll:output_type -> protocol.CertificateResponse
+	4, // 11: protocol.ClientService.Notify:output_type -> protocol.NotifyResponse
+	9, // 12: protocol.ClientService.RotateCertificate:output_type -> protocol.RotateCertificateResponse
+	7, // [7:13] is the sub-list for method output_type
+	1, // [1:7] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
 }
 
 func init() { file_protocol_proto_init() }
@@ -269,9 +665,9 @@ func file_protocol_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_protocol_proto_rawDesc), len(file_protocol_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   10,
 			NumExtensions: 0,
-			NumServices:   2,
+			NumServices:   3,
 		},
 		GoTypes:           file_protocol_proto_goTypes,
 		DependencyIndexes: file_protocol_proto_depIdxs,
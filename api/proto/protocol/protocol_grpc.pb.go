@@ -19,8 +19,9 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ProtocolService_SetConnection_FullMethodName = "/protocol.ProtocolService/SetConnection"
-	ProtocolService_Notify_FullMethodName        = "/protocol.ProtocolService/Notify"
+	ProtocolService_SetConnection_FullMethodName    = "/protocol.ProtocolService/SetConnection"
+	ProtocolService_Notify_FullMethodName           = "/protocol.ProtocolService/Notify"
+	ProtocolService_RenewCertificate_FullMethodName = "/protocol.ProtocolService/RenewCertificate"
 )
 
 // ProtocolServiceClient is the client API for ProtocolService service.
@@ -31,6 +32,8 @@ const (
 type ProtocolServiceClient interface {
 	SetConnection(ctx context.Context, in *SetConnectionRequest, opts ...grpc.CallOption) (*SetConnectionResponse, error)
 	Notify(ctx context.Context, in *NotifyRequest, opts ...grpc.CallOption) (*NotifyResponse, error)
+	// re-signs the calling slave, the machine name comes from its current certificate
+	RenewCertificate(ctx context.Context, in *RenewCertificateRequest, opts ...grpc.CallOption) (*CertificateResponse, error)
 }
 
 type protocolServiceClient struct {
@@ -61,6 +64,16 @@ func (c *protocolServiceClient) Notify(ctx context.Context, in *NotifyRequest, o
 	return out, nil
 }
 
+func (c *protocolServiceClient) RenewCertificate(ctx context.Context, in *RenewCertificateRequest, opts ...grpc.CallOption) (*CertificateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CertificateResponse)
+	err := c.cc.Invoke(ctx, ProtocolService_RenewCertificate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ProtocolServiceServer is the server API for ProtocolService service.
 // All implementations must embed UnimplementedProtocolServiceServer
 // for forward compatibility.
@@ -69,6 +82,8 @@ func (c *protocolServiceClient) Notify(ctx context.Context, in *NotifyRequest, o
 type ProtocolServiceServer interface {
 	SetConnection(context.Context, *SetConnectionRequest) (*SetConnectionResponse, error)
 	Notify(context.Context, *NotifyRequest) (*NotifyResponse, error)
+	// re-signs the calling slave, the machine name comes from its current certificate
+	RenewCertificate(context.Context, *RenewCertificateRequest) (*CertificateResponse, error)
 	mustEmbedUnimplementedProtocolServiceServer()
 }
 
@@ -85,6 +100,9 @@ func (UnimplementedProtocolServiceServer) SetConnection(context.Context, *SetCon
 func (UnimplementedProtocolServiceServer) Notify(context.Context, *NotifyRequest) (*NotifyResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Notify not implemented")
 }
+func (UnimplementedProtocolServiceServer) RenewCertificate(context.Context, *RenewCertificateRequest) (*CertificateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenewCertificate not implemented")
+}
 func (UnimplementedProtocolServiceServer) mustEmbedUnimplementedProtocolServiceServer() {}
 func (UnimplementedProtocolServiceServer) testEmbeddedByValue()                         {}
 
@@ -142,6 +160,24 @@ func _ProtocolService_Notify_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ProtocolService_RenewCertificate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenewCertificateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProtocolServiceServer).RenewCertificate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProtocolService_RenewCertificate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProtocolServiceServer).RenewCertificate(ctx, req.(*RenewCertificateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ProtocolService_ServiceDesc is the grpc.ServiceDesc for ProtocolService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -157,13 +193,124 @@ var ProtocolService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Notify",
 			Handler:    _ProtocolService_Notify_Handler,
 		},
+		{
+			MethodName: "RenewCertificate",
+			Handler:    _ProtocolService_RenewCertificate_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "protocol.proto",
 }
 
 const (
-	ClientService_Notify_FullMethodName = "/protocol.ClientService/Notify"
+	EnrollService_Enroll_FullMethodName = "/protocol.EnrollService/Enroll"
+)
+
+// EnrollServiceClient is the client API for EnrollService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Servidor de enrollment do MASTER, TLS sem certificado de cliente
+type EnrollServiceClient interface {
+	Enroll(ctx context.Context, in *EnrollRequest, opts ...grpc.CallOption) (*CertificateResponse, error)
+}
+
+type enrollServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEnrollServiceClient(cc grpc.ClientConnInterface) EnrollServiceClient {
+	return &enrollServiceClient{cc}
+}
+
+func (c *enrollServiceClient) Enroll(ctx context.Context, in *EnrollRequest, opts ...grpc.CallOption) (*CertificateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CertificateResponse)
+	err := c.cc.Invoke(ctx, EnrollService_Enroll_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EnrollServiceServer is the server API for EnrollService service.
+// All implementations must embed UnimplementedEnrollServiceServer
+// for forward compatibility.
+//
+// Servidor de enrollment do MASTER, TLS sem certificado de cliente
+type EnrollServiceServer interface {
+	Enroll(context.Context, *EnrollRequest) (*CertificateResponse, error)
+	mustEmbedUnimplementedEnrollServiceServer()
+}
+
+// UnimplementedEnrollServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEnrollServiceServer struct{}
+
+func (UnimplementedEnrollServiceServer) Enroll(context.Context, *EnrollRequest) (*CertificateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Enroll not implemented")
+}
+func (UnimplementedEnrollServiceServer) mustEmbedUnimplementedEnrollServiceServer() {}
+func (UnimplementedEnrollServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeEnrollServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EnrollServiceServer will
+// result in compilation errors.
+type UnsafeEnrollServiceServer interface {
+	mustEmbedUnimplementedEnrollServiceServer()
+}
+
+func RegisterEnrollServiceServer(s grpc.ServiceRegistrar, srv EnrollServiceServer) {
+	// If the following call pancis, it indicates UnimplementedEnrollServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&EnrollService_ServiceDesc, srv)
+}
+
+func _EnrollService_Enroll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnrollRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnrollServiceServer).Enroll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EnrollService_Enroll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnrollServiceServer).Enroll(ctx, req.(*EnrollRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// EnrollService_ServiceDesc is the grpc.ServiceDesc for EnrollService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EnrollService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "protocol.EnrollService",
+	HandlerType: (*EnrollServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Enroll",
+			Handler:    _EnrollService_Enroll_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "protocol.proto",
+}
+
+const (
+	ClientService_Notify_FullMethodName            = "/protocol.ClientService/Notify"
+	ClientService_RotateCertificate_FullMethodName = "/protocol.ClientService/RotateCertificate"
 )
 
 // ClientServiceClient is the client API for ClientService service.
@@ -173,6 +320,7 @@ const (
 // Servidor do CLIENTE
 type ClientServiceClient interface {
 	Notify(ctx context.Context, in *NotifyRequest, opts ...grpc.CallOption) (*NotifyResponse, error)
+	RotateCertificate(ctx context.Context, in *RotateCertificateRequest, opts ...grpc.CallOption) (*RotateCertificateResponse, error)
 }
 
 type clientServiceClient struct {
@@ -193,6 +341,16 @@ func (c *clientServiceClient) Notify(ctx context.Context, in *NotifyRequest, opt
 	return out, nil
 }
 
+func (c *clientServiceClient) RotateCertificate(ctx context.Context, in *RotateCertificateRequest, opts ...grpc.CallOption) (*RotateCertificateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RotateCertificateResponse)
+	err := c.cc.Invoke(ctx, ClientService_RotateCertificate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ClientServiceServer is the server API for ClientService service.
 // All implementations must embed UnimplementedClientServiceServer
 // for forward compatibility.
@@ -200,6 +358,7 @@ func (c *clientServiceClient) Notify(ctx context.Context, in *NotifyRequest, opt
 // Servidor do CLIENTE
 type ClientServiceServer interface {
 	Notify(context.Context, *NotifyRequest) (*NotifyResponse, error)
+	RotateCertificate(context.Context, *RotateCertificateRequest) (*RotateCertificateResponse, error)
 	mustEmbedUnimplementedClientServiceServer()
 }
 
@@ -213,6 +372,9 @@ type UnimplementedClientServiceServer struct{}
 func (UnimplementedClientServiceServer) Notify(context.Context, *NotifyRequest) (*NotifyResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Notify not implemented")
 }
+func (UnimplementedClientServiceServer) RotateCertificate(context.Context, *RotateCertificateRequest) (*RotateCertificateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateCertificate not implemented")
+}
 func (UnimplementedClientServiceServer) mustEmbedUnimplementedClientServiceServer() {}
 func (UnimplementedClientServiceServer) testEmbeddedByValue()                       {}
 
@@ -252,6 +414,24 @@ func _ClientService_Notify_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ClientService_RotateCertificate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateCertificateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServiceServer).RotateCertificate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClientService_RotateCertificate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServiceServer).RotateCertificate(ctx, req.(*RotateCertificateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ClientService_ServiceDesc is the grpc.ServiceDesc for ClientService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -263,6 +443,10 @@ var ClientService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Notify",
 			Handler:    _ClientService_Notify_Handler,
 		},
+		{
+			MethodName: "RotateCertificate",
+			Handler:    _ClientService_RotateCertificate_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "protocol.proto",
@@ -182,6 +182,7 @@ type CreateVmRequest struct {
 	VncPassword   string                 `protobuf:"bytes,9,opt,name=vnc_password,json=vncPassword,proto3" json:"vnc_password,omitempty"`
 	CpuXml        string                 `protobuf:"bytes,10,opt,name=cpuXml,proto3" json:"cpuXml,omitempty"`
 	IsWindows     bool                   `protobuf:"varint,11,opt,name=is_windows,json=isWindows,proto3" json:"is_windows,omitempty"`
+	CloudInit     *CloudInitConfig       `protobuf:"bytes,12,opt,name=cloud_init,json=cloudInit,proto3" json:"cloud_init,omitempty"` // optional NoCloud seed attached on first boot
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -293,6 +294,173 @@ func (x *CreateVmRequest) GetIsWindows() bool {
 	return false
 }
 
+func (x *CreateVmRequest) GetCloudInit() *CloudInitConfig {
+	if x != nil {
+		return x.CloudInit
+	}
+	return nil
+}
+
+type CloudInitUser struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Name              string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Password          string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"` // plain text, hashed by cloud-init
+	SshAuthorizedKeys []string               `protobuf:"bytes,3,rep,name=ssh_authorized_keys,json=sshAuthorizedKeys,proto3" json:"ssh_authorized_keys,omitempty"`
+	Sudo              bool                   `protobuf:"varint,4,opt,name=sudo,proto3" json:"sudo,omitempty"` // passwordless sudo
+	Shell             string                 `protobuf:"bytes,5,opt,name=shell,proto3" json:"shell,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *CloudInitUser) Reset() {
+	*x = CloudInitUser{}
+	mi := &file_virsh_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CloudInitUser) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CloudInitUser) ProtoMessage() {}
+
+func (x *CloudInitUser) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CloudInitUser.ProtoReflect.Descriptor instead.
+func (*CloudInitUser) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CloudInitUser) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CloudInitUser) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *CloudInitUser) GetSshAuthorizedKeys() []string {
+	if x != nil {
+		return x.SshAuthorizedKeys
+	}
+	return nil
+}
+
+func (x *CloudInitUser) GetSudo() bool {
+	if x != nil {
+		return x.Sudo
+	}
+	return false
+}
+
+func (x *CloudInitUser) GetShell() string {
+	if x != nil {
+		return x.Shell
+	}
+	return ""
+}
+
+type CloudInitConfig struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Hostname          string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Users             []*CloudInitUser       `protobuf:"bytes,2,rep,name=users,proto3" json:"users,omitempty"`
+	SshAuthorizedKeys []string               `protobuf:"bytes,3,rep,name=ssh_authorized_keys,json=sshAuthorizedKeys,proto3" json:"ssh_authorized_keys,omitempty"` // added to the image default user
+	UserData          string                 `protobuf:"bytes,4,opt,name=user_data,json=userData,proto3" json:"user_data,omitempty"`                              // raw user-data, replaces the generated one
+	NetworkConfig     string                 `protobuf:"bytes,5,opt,name=network_config,json=networkConfig,proto3" json:"network_config,omitempty"`
+	VendorData        string                 `protobuf:"bytes,6,opt,name=vendor_data,json=vendorData,proto3" json:"vendor_data,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *CloudInitConfig) Reset() {
+	*x = CloudInitConfig{}
+	mi := &file_virsh_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CloudInitConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CloudInitConfig) ProtoMessage() {}
+
+func (x *CloudInitConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CloudInitConfig.ProtoReflect.Descriptor instead.
+func (*CloudInitConfig) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CloudInitConfig) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *CloudInitConfig) GetUsers() []*CloudInitUser {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+func (x *CloudInitConfig) GetSshAuthorizedKeys() []string {
+	if x != nil {
+		return x.SshAuthorizedKeys
+	}
+	return nil
+}
+
+func (x *CloudInitConfig) GetUserData() string {
+	if x != nil {
+		return x.UserData
+	}
+	return ""
+}
+
+func (x *CloudInitConfig) GetNetworkConfig() string {
+	if x != nil {
+		return x.NetworkConfig
+	}
+	return ""
+}
+
+func (x *CloudInitConfig) GetVendorData() string {
+	if x != nil {
+		return x.VendorData
+	}
+	return ""
+}
+
 type OkResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
@@ -303,7 +471,7 @@ type OkResponse struct {
 
 func (x *OkResponse) Reset() {
 	*x = OkResponse{}
-	mi := &file_virsh_proto_msgTypes[3]
+	mi := &file_virsh_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -315,7 +483,7 @@ func (x *OkResponse) String() string {
 func (*OkResponse) ProtoMessage() {}
 
 func (x *OkResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[3]
+	mi := &file_virsh_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -328,7 +496,7 @@ func (x *OkResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use OkResponse.ProtoReflect.Descriptor instead.
 func (*OkResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{3}
+	return file_virsh_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *OkResponse) GetOk() bool {
@@ -376,7 +544,7 @@ type Vm struct {
 
 func (x *Vm) Reset() {
 	*x = Vm{}
-	mi := &file_virsh_proto_msgTypes[4]
+	mi := &file_virsh_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -388,7 +556,7 @@ func (x *Vm) String() string {
 func (*Vm) ProtoMessage() {}
 
 func (x *Vm) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[4]
+	mi := &file_virsh_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -401,7 +569,7 @@ func (x *Vm) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Vm.ProtoReflect.Descriptor instead.
 func (*Vm) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{4}
+	return file_virsh_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *Vm) GetMachineName() string {
@@ -574,7 +742,7 @@ type GetVmByNameRequest struct {
 
 func (x *GetVmByNameRequest) Reset() {
 	*x = GetVmByNameRequest{}
-	mi := &file_virsh_proto_msgTypes[5]
+	mi := &file_virsh_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -586,7 +754,7 @@ func (x *GetVmByNameRequest) String() string {
 func (*GetVmByNameRequest) ProtoMessage() {}
 
 func (x *GetVmByNameRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[5]
+	mi := &file_virsh_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -599,7 +767,7 @@ func (x *GetVmByNameRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetVmByNameRequest.ProtoReflect.Descriptor instead.
 func (*GetVmByNameRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{5}
+	return file_virsh_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *GetVmByNameRequest) GetName() string {
@@ -619,7 +787,7 @@ type GetAllVmsResponse struct {
 
 func (x *GetAllVmsResponse) Reset() {
 	*x = GetAllVmsResponse{}
-	mi := &file_virsh_proto_msgTypes[6]
+	mi := &file_virsh_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -631,7 +799,7 @@ func (x *GetAllVmsResponse) String() string {
 func (*GetAllVmsResponse) ProtoMessage() {}
 
 func (x *GetAllVmsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[6]
+	mi := &file_virsh_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -644,7 +812,7 @@ func (x *GetAllVmsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetAllVmsResponse.ProtoReflect.Descriptor instead.
 func (*GetAllVmsResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{6}
+	return file_virsh_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *GetAllVmsResponse) GetVms() []*Vm {
@@ -662,18 +830,21 @@ func (x *GetAllVmsResponse) GetWarnings() []string {
 }
 
 type MigrateVmRequest struct {
-	state          protoimpl.MessageState `protogen:"open.v1"`
-	Name           string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	SlaveIp        string                 `protobuf:"bytes,3,opt,name=slaveIp,proto3" json:"slaveIp,omitempty"`
-	Live           bool                   `protobuf:"varint,4,opt,name=live,proto3" json:"live,omitempty"`
-	TimeoutSeconds int32                  `protobuf:"varint,5,opt,name=timeoutSeconds,proto3" json:"timeoutSeconds,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Name            string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	SlaveIp         string                 `protobuf:"bytes,3,opt,name=slaveIp,proto3" json:"slaveIp,omitempty"`
+	Live            bool                   `protobuf:"varint,4,opt,name=live,proto3" json:"live,omitempty"`
+	TimeoutSeconds  int32                  `protobuf:"varint,5,opt,name=timeoutSeconds,proto3" json:"timeoutSeconds,omitempty"`
+	MaxBandwidthMib uint64                 `protobuf:"varint,6,opt,name=max_bandwidth_mib,json=maxBandwidthMib,proto3" json:"max_bandwidth_mib,omitempty"` // MiB/s, 0 leaves it unlimited
+	AutoConverge    bool                   `protobuf:"varint,7,opt,name=auto_converge,json=autoConverge,proto3" json:"auto_converge,omitempty"`            // throttle the guest cpu when it dirties memory faster than it is copied
+	PostCopy        bool                   `protobuf:"varint,8,opt,name=post_copy,json=postCopy,proto3" json:"post_copy,omitempty"`                        // switch to post-copy when pre-copy does not converge
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *MigrateVmRequest) Reset() {
 	*x = MigrateVmRequest{}
-	mi := &file_virsh_proto_msgTypes[7]
+	mi := &file_virsh_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -685,7 +856,7 @@ func (x *MigrateVmRequest) String() string {
 func (*MigrateVmRequest) ProtoMessage() {}
 
 func (x *MigrateVmRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[7]
+	mi := &file_virsh_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -698,7 +869,7 @@ func (x *MigrateVmRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MigrateVmRequest.ProtoReflect.Descriptor instead.
 func (*MigrateVmRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{7}
+	return file_virsh_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *MigrateVmRequest) GetName() string {
@@ -729,28 +900,62 @@ func (x *MigrateVmRequest) GetTimeoutSeconds() int32 {
 	return 0
 }
 
-type CPUXMLResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	CpuXML        string                 `protobuf:"bytes,1,opt,name=cpuXML,proto3" json:"cpuXML,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *MigrateVmRequest) GetMaxBandwidthMib() uint64 {
+	if x != nil {
+		return x.MaxBandwidthMib
+	}
+	return 0
 }
 
-func (x *CPUXMLResponse) Reset() {
-	*x = CPUXMLResponse{}
-	mi := &file_virsh_proto_msgTypes[8]
+func (x *MigrateVmRequest) GetAutoConverge() bool {
+	if x != nil {
+		return x.AutoConverge
+	}
+	return false
+}
+
+func (x *MigrateVmRequest) GetPostCopy() bool {
+	if x != nil {
+		return x.PostCopy
+	}
+	return false
+}
+
+// progress of a migration, streamed every second from the origin slave.
+// phase is one of setup, precopy, postcopy, completed, failed, cancelled
+type MigrationProgress struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	Phase                string                 `protobuf:"bytes,1,opt,name=phase,proto3" json:"phase,omitempty"`
+	Percent              int32                  `protobuf:"varint,2,opt,name=percent,proto3" json:"percent,omitempty"`
+	DataTotal            uint64                 `protobuf:"varint,3,opt,name=data_total,json=dataTotal,proto3" json:"data_total,omitempty"`             // bytes
+	DataProcessed        uint64                 `protobuf:"varint,4,opt,name=data_processed,json=dataProcessed,proto3" json:"data_processed,omitempty"` // bytes
+	DataRemaining        uint64                 `protobuf:"varint,5,opt,name=data_remaining,json=dataRemaining,proto3" json:"data_remaining,omitempty"` // bytes
+	MemDirtyRate         uint64                 `protobuf:"varint,6,opt,name=mem_dirty_rate,json=memDirtyRate,proto3" json:"mem_dirty_rate,omitempty"`  // pages per second
+	MemBps               uint64                 `protobuf:"varint,7,opt,name=mem_bps,json=memBps,proto3" json:"mem_bps,omitempty"`                      // bytes per second
+	MemIteration         uint64                 `protobuf:"varint,8,opt,name=mem_iteration,json=memIteration,proto3" json:"mem_iteration,omitempty"`    // passes over guest memory
+	DowntimeMs           uint64                 `protobuf:"varint,9,opt,name=downtime_ms,json=downtimeMs,proto3" json:"downtime_ms,omitempty"`          // expected downtime, the real one once completed
+	TimeElapsedMs        uint64                 `protobuf:"varint,10,opt,name=time_elapsed_ms,json=timeElapsedMs,proto3" json:"time_elapsed_ms,omitempty"`
+	AutoConvergeThrottle int32                  `protobuf:"varint,11,opt,name=auto_converge_throttle,json=autoConvergeThrottle,proto3" json:"auto_converge_throttle,omitempty"` // percent of guest cpu throttled
+	Message              string                 `protobuf:"bytes,12,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *MigrationProgress) Reset() {
+	*x = MigrationProgress{}
+	mi := &file_virsh_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CPUXMLResponse) String() string {
+func (x *MigrationProgress) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CPUXMLResponse) ProtoMessage() {}
+func (*MigrationProgress) ProtoMessage() {}
 
-func (x *CPUXMLResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[8]
+func (x *MigrationProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -761,85 +966,250 @@ func (x *CPUXMLResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CPUXMLResponse.ProtoReflect.Descriptor instead.
-func (*CPUXMLResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{8}
+// Deprecated: Use MigrationProgress.ProtoReflect.Descriptor instead.
+func (*MigrationProgress) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *CPUXMLResponse) GetCpuXML() string {
+func (x *MigrationProgress) GetPhase() string {
 	if x != nil {
-		return x.CpuXML
+		return x.Phase
 	}
 	return ""
 }
 
-type VMXMLResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	VmXML         string                 `protobuf:"bytes,1,opt,name=vmXML,proto3" json:"vmXML,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *MigrationProgress) GetPercent() int32 {
+	if x != nil {
+		return x.Percent
+	}
+	return 0
 }
 
-func (x *VMXMLResponse) Reset() {
-	*x = VMXMLResponse{}
-	mi := &file_virsh_proto_msgTypes[9]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *MigrationProgress) GetDataTotal() uint64 {
+	if x != nil {
+		return x.DataTotal
+	}
+	return 0
 }
 
-func (x *VMXMLResponse) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *MigrationProgress) GetDataProcessed() uint64 {
+	if x != nil {
+		return x.DataProcessed
+	}
+	return 0
 }
 
-func (*VMXMLResponse) ProtoMessage() {}
+func (x *MigrationProgress) GetDataRemaining() uint64 {
+	if x != nil {
+		return x.DataRemaining
+	}
+	return 0
+}
 
-func (x *VMXMLResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[9]
+func (x *MigrationProgress) GetMemDirtyRate() uint64 {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.MemDirtyRate
 	}
-	return mi.MessageOf(x)
+	return 0
 }
 
-// Deprecated: Use VMXMLResponse.ProtoReflect.Descriptor instead.
-func (*VMXMLResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{9}
+func (x *MigrationProgress) GetMemBps() uint64 {
+	if x != nil {
+		return x.MemBps
+	}
+	return 0
 }
 
-func (x *VMXMLResponse) GetVmXML() string {
+func (x *MigrationProgress) GetMemIteration() uint64 {
 	if x != nil {
-		return x.VmXML
+		return x.MemIteration
+	}
+	return 0
+}
+
+func (x *MigrationProgress) GetDowntimeMs() uint64 {
+	if x != nil {
+		return x.DowntimeMs
+	}
+	return 0
+}
+
+func (x *MigrationProgress) GetTimeElapsedMs() uint64 {
+	if x != nil {
+		return x.TimeElapsedMs
+	}
+	return 0
+}
+
+func (x *MigrationProgress) GetAutoConvergeThrottle() int32 {
+	if x != nil {
+		return x.AutoConvergeThrottle
+	}
+	return 0
+}
+
+func (x *MigrationProgress) GetMessage() string {
+	if x != nil {
+		return x.Message
 	}
 	return ""
 }
 
-type UpdateVMCPUXmlRequest struct {
+type CancelMigrationRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	CpuXML        string                 `protobuf:"bytes,2,opt,name=cpuXML,proto3" json:"cpuXML,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UpdateVMCPUXmlRequest) Reset() {
-	*x = UpdateVMCPUXmlRequest{}
-	mi := &file_virsh_proto_msgTypes[10]
+func (x *CancelMigrationRequest) Reset() {
+	*x = CancelMigrationRequest{}
+	mi := &file_virsh_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UpdateVMCPUXmlRequest) String() string {
+func (x *CancelMigrationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelMigrationRequest) ProtoMessage() {}
+
+func (x *CancelMigrationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelMigrationRequest.ProtoReflect.Descriptor instead.
+func (*CancelMigrationRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *CancelMigrationRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type CPUXMLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CpuXML        string                 `protobuf:"bytes,1,opt,name=cpuXML,proto3" json:"cpuXML,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CPUXMLResponse) Reset() {
+	*x = CPUXMLResponse{}
+	mi := &file_virsh_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CPUXMLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CPUXMLResponse) ProtoMessage() {}
+
+func (x *CPUXMLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CPUXMLResponse.ProtoReflect.Descriptor instead.
+func (*CPUXMLResponse) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *CPUXMLResponse) GetCpuXML() string {
+	if x != nil {
+		return x.CpuXML
+	}
+	return ""
+}
+
+type VMXMLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	VmXML         string                 `protobuf:"bytes,1,opt,name=vmXML,proto3" json:"vmXML,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VMXMLResponse) Reset() {
+	*x = VMXMLResponse{}
+	mi := &file_virsh_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VMXMLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VMXMLResponse) ProtoMessage() {}
+
+func (x *VMXMLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VMXMLResponse.ProtoReflect.Descriptor instead.
+func (*VMXMLResponse) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *VMXMLResponse) GetVmXML() string {
+	if x != nil {
+		return x.VmXML
+	}
+	return ""
+}
+
+type UpdateVMCPUXmlRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	CpuXML        string                 `protobuf:"bytes,2,opt,name=cpuXML,proto3" json:"cpuXML,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateVMCPUXmlRequest) Reset() {
+	*x = UpdateVMCPUXmlRequest{}
+	mi := &file_virsh_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateVMCPUXmlRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
 func (*UpdateVMCPUXmlRequest) ProtoMessage() {}
 
 func (x *UpdateVMCPUXmlRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[10]
+	mi := &file_virsh_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -852,7 +1222,7 @@ func (x *UpdateVMCPUXmlRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateVMCPUXmlRequest.ProtoReflect.Descriptor instead.
 func (*UpdateVMCPUXmlRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{10}
+	return file_virsh_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *UpdateVMCPUXmlRequest) GetName() string {
@@ -879,7 +1249,7 @@ type UpdateVMXmlRequest struct {
 
 func (x *UpdateVMXmlRequest) Reset() {
 	*x = UpdateVMXmlRequest{}
-	mi := &file_virsh_proto_msgTypes[11]
+	mi := &file_virsh_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -891,7 +1261,7 @@ func (x *UpdateVMXmlRequest) String() string {
 func (*UpdateVMXmlRequest) ProtoMessage() {}
 
 func (x *UpdateVMXmlRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[11]
+	mi := &file_virsh_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -904,7 +1274,7 @@ func (x *UpdateVMXmlRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateVMXmlRequest.ProtoReflect.Descriptor instead.
 func (*UpdateVMXmlRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{11}
+	return file_virsh_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *UpdateVMXmlRequest) GetName() string {
@@ -921,6 +1291,67 @@ func (x *UpdateVMXmlRequest) GetVmXML() string {
 	return ""
 }
 
+// defines a vm that is not on this slave from a stored domain xml, used by HA
+type DefineVMFromXMLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	VmXML         string                 `protobuf:"bytes,2,opt,name=vmXML,proto3" json:"vmXML,omitempty"`
+	Start         bool                   `protobuf:"varint,3,opt,name=start,proto3" json:"start,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DefineVMFromXMLRequest) Reset() {
+	*x = DefineVMFromXMLRequest{}
+	mi := &file_virsh_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DefineVMFromXMLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DefineVMFromXMLRequest) ProtoMessage() {}
+
+func (x *DefineVMFromXMLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DefineVMFromXMLRequest.ProtoReflect.Descriptor instead.
+func (*DefineVMFromXMLRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *DefineVMFromXMLRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DefineVMFromXMLRequest) GetVmXML() string {
+	if x != nil {
+		return x.VmXML
+	}
+	return ""
+}
+
+func (x *DefineVMFromXMLRequest) GetStart() bool {
+	if x != nil {
+		return x.Start
+	}
+	return false
+}
+
 type ColdMigrationRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	VmName        string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
@@ -937,7 +1368,7 @@ type ColdMigrationRequest struct {
 
 func (x *ColdMigrationRequest) Reset() {
 	*x = ColdMigrationRequest{}
-	mi := &file_virsh_proto_msgTypes[12]
+	mi := &file_virsh_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -949,7 +1380,7 @@ func (x *ColdMigrationRequest) String() string {
 func (*ColdMigrationRequest) ProtoMessage() {}
 
 func (x *ColdMigrationRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[12]
+	mi := &file_virsh_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -962,7 +1393,7 @@ func (x *ColdMigrationRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ColdMigrationRequest.ProtoReflect.Descriptor instead.
 func (*ColdMigrationRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{12}
+	return file_virsh_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *ColdMigrationRequest) GetVmName() string {
@@ -1031,7 +1462,7 @@ type ChangeNetworkReq struct {
 
 func (x *ChangeNetworkReq) Reset() {
 	*x = ChangeNetworkReq{}
-	mi := &file_virsh_proto_msgTypes[13]
+	mi := &file_virsh_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1043,7 +1474,7 @@ func (x *ChangeNetworkReq) String() string {
 func (*ChangeNetworkReq) ProtoMessage() {}
 
 func (x *ChangeNetworkReq) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[13]
+	mi := &file_virsh_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1056,7 +1487,7 @@ func (x *ChangeNetworkReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChangeNetworkReq.ProtoReflect.Descriptor instead.
 func (*ChangeNetworkReq) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{13}
+	return file_virsh_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *ChangeNetworkReq) GetVmName() string {
@@ -1083,7 +1514,7 @@ type ChangeVncPassword struct {
 
 func (x *ChangeVncPassword) Reset() {
 	*x = ChangeVncPassword{}
-	mi := &file_virsh_proto_msgTypes[14]
+	mi := &file_virsh_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1095,7 +1526,7 @@ func (x *ChangeVncPassword) String() string {
 func (*ChangeVncPassword) ProtoMessage() {}
 
 func (x *ChangeVncPassword) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[14]
+	mi := &file_virsh_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1108,7 +1539,7 @@ func (x *ChangeVncPassword) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChangeVncPassword.ProtoReflect.Descriptor instead.
 func (*ChangeVncPassword) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{14}
+	return file_virsh_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *ChangeVncPassword) GetVmName() string {
@@ -1135,7 +1566,7 @@ type AddSSHKeyRequest struct {
 
 func (x *AddSSHKeyRequest) Reset() {
 	*x = AddSSHKeyRequest{}
-	mi := &file_virsh_proto_msgTypes[15]
+	mi := &file_virsh_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1147,7 +1578,7 @@ func (x *AddSSHKeyRequest) String() string {
 func (*AddSSHKeyRequest) ProtoMessage() {}
 
 func (x *AddSSHKeyRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[15]
+	mi := &file_virsh_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1160,7 +1591,7 @@ func (x *AddSSHKeyRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AddSSHKeyRequest.ProtoReflect.Descriptor instead.
 func (*AddSSHKeyRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{15}
+	return file_virsh_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *AddSSHKeyRequest) GetVmName() string {
@@ -1189,7 +1620,7 @@ type GetNoVNCVideoResponse struct {
 
 func (x *GetNoVNCVideoResponse) Reset() {
 	*x = GetNoVNCVideoResponse{}
-	mi := &file_virsh_proto_msgTypes[16]
+	mi := &file_virsh_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1201,7 +1632,7 @@ func (x *GetNoVNCVideoResponse) String() string {
 func (*GetNoVNCVideoResponse) ProtoMessage() {}
 
 func (x *GetNoVNCVideoResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[16]
+	mi := &file_virsh_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1214,7 +1645,7 @@ func (x *GetNoVNCVideoResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetNoVNCVideoResponse.ProtoReflect.Descriptor instead.
 func (*GetNoVNCVideoResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{16}
+	return file_virsh_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *GetNoVNCVideoResponse) GetEnabled() bool {
@@ -1255,7 +1686,7 @@ type SetMemoryBallooningRequest struct {
 
 func (x *SetMemoryBallooningRequest) Reset() {
 	*x = SetMemoryBallooningRequest{}
-	mi := &file_virsh_proto_msgTypes[17]
+	mi := &file_virsh_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1267,7 +1698,7 @@ func (x *SetMemoryBallooningRequest) String() string {
 func (*SetMemoryBallooningRequest) ProtoMessage() {}
 
 func (x *SetMemoryBallooningRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[17]
+	mi := &file_virsh_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1280,7 +1711,7 @@ func (x *SetMemoryBallooningRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetMemoryBallooningRequest.ProtoReflect.Descriptor instead.
 func (*SetMemoryBallooningRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{17}
+	return file_virsh_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *SetMemoryBallooningRequest) GetVmName() string {
@@ -1309,7 +1740,7 @@ type GetMemoryBallooningResponse struct {
 
 func (x *GetMemoryBallooningResponse) Reset() {
 	*x = GetMemoryBallooningResponse{}
-	mi := &file_virsh_proto_msgTypes[18]
+	mi := &file_virsh_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1321,7 +1752,7 @@ func (x *GetMemoryBallooningResponse) String() string {
 func (*GetMemoryBallooningResponse) ProtoMessage() {}
 
 func (x *GetMemoryBallooningResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[18]
+	mi := &file_virsh_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1334,7 +1765,7 @@ func (x *GetMemoryBallooningResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetMemoryBallooningResponse.ProtoReflect.Descriptor instead.
 func (*GetMemoryBallooningResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{18}
+	return file_virsh_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *GetMemoryBallooningResponse) GetEnabled() bool {
@@ -1375,7 +1806,7 @@ type SetHugePagesRequest struct {
 
 func (x *SetHugePagesRequest) Reset() {
 	*x = SetHugePagesRequest{}
-	mi := &file_virsh_proto_msgTypes[19]
+	mi := &file_virsh_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1387,7 +1818,7 @@ func (x *SetHugePagesRequest) String() string {
 func (*SetHugePagesRequest) ProtoMessage() {}
 
 func (x *SetHugePagesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[19]
+	mi := &file_virsh_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1400,7 +1831,7 @@ func (x *SetHugePagesRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetHugePagesRequest.ProtoReflect.Descriptor instead.
 func (*SetHugePagesRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{19}
+	return file_virsh_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *SetHugePagesRequest) GetVmName() string {
@@ -1428,7 +1859,7 @@ type GetHugePagesResponse struct {
 
 func (x *GetHugePagesResponse) Reset() {
 	*x = GetHugePagesResponse{}
-	mi := &file_virsh_proto_msgTypes[20]
+	mi := &file_virsh_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1440,7 +1871,7 @@ func (x *GetHugePagesResponse) String() string {
 func (*GetHugePagesResponse) ProtoMessage() {}
 
 func (x *GetHugePagesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[20]
+	mi := &file_virsh_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1453,7 +1884,7 @@ func (x *GetHugePagesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetHugePagesResponse.ProtoReflect.Descriptor instead.
 func (*GetHugePagesResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{20}
+	return file_virsh_proto_rawDescGZIP(), []int{25}
 }
 
 func (x *GetHugePagesResponse) GetEnabled() bool {
@@ -1486,7 +1917,7 @@ type MachineTypesResponse struct {
 
 func (x *MachineTypesResponse) Reset() {
 	*x = MachineTypesResponse{}
-	mi := &file_virsh_proto_msgTypes[21]
+	mi := &file_virsh_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1498,7 +1929,7 @@ func (x *MachineTypesResponse) String() string {
 func (*MachineTypesResponse) ProtoMessage() {}
 
 func (x *MachineTypesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[21]
+	mi := &file_virsh_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1511,7 +1942,7 @@ func (x *MachineTypesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MachineTypesResponse.ProtoReflect.Descriptor instead.
 func (*MachineTypesResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{21}
+	return file_virsh_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *MachineTypesResponse) GetMachineTypes() []string {
@@ -1531,7 +1962,7 @@ type SetMachineTypeRequest struct {
 
 func (x *SetMachineTypeRequest) Reset() {
 	*x = SetMachineTypeRequest{}
-	mi := &file_virsh_proto_msgTypes[22]
+	mi := &file_virsh_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1543,7 +1974,7 @@ func (x *SetMachineTypeRequest) String() string {
 func (*SetMachineTypeRequest) ProtoMessage() {}
 
 func (x *SetMachineTypeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[22]
+	mi := &file_virsh_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1556,7 +1987,7 @@ func (x *SetMachineTypeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetMachineTypeRequest.ProtoReflect.Descriptor instead.
 func (*SetMachineTypeRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{22}
+	return file_virsh_proto_rawDescGZIP(), []int{27}
 }
 
 func (x *SetMachineTypeRequest) GetVmName() string {
@@ -1583,7 +2014,7 @@ type MachineTypeResponse struct {
 
 func (x *MachineTypeResponse) Reset() {
 	*x = MachineTypeResponse{}
-	mi := &file_virsh_proto_msgTypes[23]
+	mi := &file_virsh_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1595,7 +2026,7 @@ func (x *MachineTypeResponse) String() string {
 func (*MachineTypeResponse) ProtoMessage() {}
 
 func (x *MachineTypeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[23]
+	mi := &file_virsh_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1608,7 +2039,7 @@ func (x *MachineTypeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MachineTypeResponse.ProtoReflect.Descriptor instead.
 func (*MachineTypeResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{23}
+	return file_virsh_proto_rawDescGZIP(), []int{28}
 }
 
 func (x *MachineTypeResponse) GetVmName() string {
@@ -1635,7 +2066,7 @@ type SetKVMHiddenRequest struct {
 
 func (x *SetKVMHiddenRequest) Reset() {
 	*x = SetKVMHiddenRequest{}
-	mi := &file_virsh_proto_msgTypes[24]
+	mi := &file_virsh_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1647,7 +2078,7 @@ func (x *SetKVMHiddenRequest) String() string {
 func (*SetKVMHiddenRequest) ProtoMessage() {}
 
 func (x *SetKVMHiddenRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[24]
+	mi := &file_virsh_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1660,46 +2091,1980 @@ func (x *SetKVMHiddenRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetKVMHiddenRequest.ProtoReflect.Descriptor instead.
 func (*SetKVMHiddenRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{24}
+	return file_virsh_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *SetKVMHiddenRequest) GetVmName() string {
+	if x != nil {
+		return x.VmName
+	}
+	return ""
+}
+
+func (x *SetKVMHiddenRequest) GetHidden() bool {
+	if x != nil {
+		return x.Hidden
+	}
+	return false
+}
+
+type KVMHiddenResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	VmName        string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
+	Hidden        bool                   `protobuf:"varint,2,opt,name=hidden,proto3" json:"hidden,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KVMHiddenResponse) Reset() {
+	*x = KVMHiddenResponse{}
+	mi := &file_virsh_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KVMHiddenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KVMHiddenResponse) ProtoMessage() {}
+
+func (x *KVMHiddenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KVMHiddenResponse.ProtoReflect.Descriptor instead.
+func (*KVMHiddenResponse) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *KVMHiddenResponse) GetVmName() string {
+	if x != nil {
+		return x.VmName
+	}
+	return ""
+}
+
+func (x *KVMHiddenResponse) GetHidden() bool {
+	if x != nil {
+		return x.Hidden
+	}
+	return false
+}
+
+type SetHyperVRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	VmName        string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
+	Hyperv        bool                   `protobuf:"varint,2,opt,name=hyperv,proto3" json:"hyperv,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetHyperVRequest) Reset() {
+	*x = SetHyperVRequest{}
+	mi := &file_virsh_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetHyperVRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetHyperVRequest) ProtoMessage() {}
+
+func (x *SetHyperVRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetHyperVRequest.ProtoReflect.Descriptor instead.
+func (*SetHyperVRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *SetHyperVRequest) GetVmName() string {
+	if x != nil {
+		return x.VmName
+	}
+	return ""
+}
+
+func (x *SetHyperVRequest) GetHyperv() bool {
+	if x != nil {
+		return x.Hyperv
+	}
+	return false
+}
+
+type HyperVResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	VmName        string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
+	Hyperv        bool                   `protobuf:"varint,2,opt,name=hyperv,proto3" json:"hyperv,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HyperVResponse) Reset() {
+	*x = HyperVResponse{}
+	mi := &file_virsh_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HyperVResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HyperVResponse) ProtoMessage() {}
+
+func (x *HyperVResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HyperVResponse.ProtoReflect.Descriptor instead.
+func (*HyperVResponse) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *HyperVResponse) GetVmName() string {
+	if x != nil {
+		return x.VmName
+	}
+	return ""
+}
+
+func (x *HyperVResponse) GetHyperv() bool {
+	if x != nil {
+		return x.Hyperv
+	}
+	return false
+}
+
+type ExternalDiskRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	VmName        string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
+	DiskPath      string                 `protobuf:"bytes,2,opt,name=disk_path,json=diskPath,proto3" json:"disk_path,omitempty"`
+	Format        string                 `protobuf:"bytes,3,opt,name=format,proto3" json:"format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExternalDiskRequest) Reset() {
+	*x = ExternalDiskRequest{}
+	mi := &file_virsh_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExternalDiskRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExternalDiskRequest) ProtoMessage() {}
+
+func (x *ExternalDiskRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExternalDiskRequest.ProtoReflect.Descriptor instead.
+func (*ExternalDiskRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *ExternalDiskRequest) GetVmName() string {
+	if x != nil {
+		return x.VmName
+	}
+	return ""
+}
+
+func (x *ExternalDiskRequest) GetDiskPath() string {
+	if x != nil {
+		return x.DiskPath
+	}
+	return ""
+}
+
+func (x *ExternalDiskRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+type ExternalDiskResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	TargetDev     string                 `protobuf:"bytes,2,opt,name=target_dev,json=targetDev,proto3" json:"target_dev,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExternalDiskResponse) Reset() {
+	*x = ExternalDiskResponse{}
+	mi := &file_virsh_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExternalDiskResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExternalDiskResponse) ProtoMessage() {}
+
+func (x *ExternalDiskResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExternalDiskResponse.ProtoReflect.Descriptor instead.
+func (*ExternalDiskResponse) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *ExternalDiskResponse) GetOk() bool {
+	if x != nil {
+		return x.Ok
+	}
+	return false
+}
+
+func (x *ExternalDiskResponse) GetTargetDev() string {
+	if x != nil {
+		return x.TargetDev
+	}
+	return ""
+}
+
+type CreateSnapshotRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	VmName        string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	DiskOnly      bool                   `protobuf:"varint,4,opt,name=disk_only,json=diskOnly,proto3" json:"disk_only,omitempty"`                // external disk-only snapshot instead of internal qcow2
+	IncludeMemory bool                   `protobuf:"varint,5,opt,name=include_memory,json=includeMemory,proto3" json:"include_memory,omitempty"` // save guest memory (running VMs only)
+	Quiesce       bool                   `protobuf:"varint,6,opt,name=quiesce,proto3" json:"quiesce,omitempty"`                                  // freeze guest filesystems through the guest agent
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSnapshotRequest) Reset() {
+	*x = CreateSnapshotRequest{}
+	mi := &file_virsh_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSnapshotRequest) ProtoMessage() {}
+
+func (x *CreateSnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSnapshotRequest.ProtoReflect.Descriptor instead.
+func (*CreateSnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *CreateSnapshotRequest) GetVmName() string {
+	if x != nil {
+		return x.VmName
+	}
+	return ""
+}
+
+func (x *CreateSnapshotRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateSnapshotRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *CreateSnapshotRequest) GetDiskOnly() bool {
+	if x != nil {
+		return x.DiskOnly
+	}
+	return false
+}
+
+func (x *CreateSnapshotRequest) GetIncludeMemory() bool {
+	if x != nil {
+		return x.IncludeMemory
+	}
+	return false
+}
+
+func (x *CreateSnapshotRequest) GetQuiesce() bool {
+	if x != nil {
+		return x.Quiesce
+	}
+	return false
+}
+
+type SnapshotRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	VmName        string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotRequest) Reset() {
+	*x = SnapshotRequest{}
+	mi := &file_virsh_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotRequest) ProtoMessage() {}
+
+func (x *SnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotRequest.ProtoReflect.Descriptor instead.
+func (*SnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *SnapshotRequest) GetVmName() string {
+	if x != nil {
+		return x.VmName
+	}
+	return ""
+}
+
+func (x *SnapshotRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type SnapshotInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	State         string                 `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`                                    // domain state captured by the snapshot
+	CreationTime  int64                  `protobuf:"varint,4,opt,name=creation_time,json=creationTime,proto3" json:"creation_time,omitempty"` // unix seconds
+	Current       bool                   `protobuf:"varint,5,opt,name=current,proto3" json:"current,omitempty"`
+	Parent        string                 `protobuf:"bytes,6,opt,name=parent,proto3" json:"parent,omitempty"`
+	External      bool                   `protobuf:"varint,7,opt,name=external,proto3" json:"external,omitempty"`
+	HasMemory     bool                   `protobuf:"varint,8,opt,name=has_memory,json=hasMemory,proto3" json:"has_memory,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotInfo) Reset() {
+	*x = SnapshotInfo{}
+	mi := &file_virsh_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotInfo) ProtoMessage() {}
+
+func (x *SnapshotInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotInfo.ProtoReflect.Descriptor instead.
+func (*SnapshotInfo) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *SnapshotInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SnapshotInfo) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *SnapshotInfo) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *SnapshotInfo) GetCreationTime() int64 {
+	if x != nil {
+		return x.CreationTime
+	}
+	return 0
+}
+
+func (x *SnapshotInfo) GetCurrent() bool {
+	if x != nil {
+		return x.Current
+	}
+	return false
+}
+
+func (x *SnapshotInfo) GetParent() string {
+	if x != nil {
+		return x.Parent
+	}
+	return ""
+}
+
+func (x *SnapshotInfo) GetExternal() bool {
+	if x != nil {
+		return x.External
+	}
+	return false
+}
+
+func (x *SnapshotInfo) GetHasMemory() bool {
+	if x != nil {
+		return x.HasMemory
+	}
+	return false
+}
+
+type ListSnapshotsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Snapshots     []*SnapshotInfo        `protobuf:"bytes,1,rep,name=snapshots,proto3" json:"snapshots,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSnapshotsResponse) Reset() {
+	*x = ListSnapshotsResponse{}
+	mi := &file_virsh_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSnapshotsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSnapshotsResponse) ProtoMessage() {}
+
+func (x *ListSnapshotsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSnapshotsResponse.ProtoReflect.Descriptor instead.
+func (*ListSnapshotsResponse) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *ListSnapshotsResponse) GetSnapshots() []*SnapshotInfo {
+	if x != nil {
+		return x.Snapshots
+	}
+	return nil
+}
+
+type BackupDiskRequest struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	VmName           string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
+	TargetPath       string                 `protobuf:"bytes,2,opt,name=target_path,json=targetPath,proto3" json:"target_path,omitempty"`                   // qcow2 written by the backup job
+	Checkpoint       string                 `protobuf:"bytes,3,opt,name=checkpoint,proto3" json:"checkpoint,omitempty"`                                     // checkpoint (dirty bitmap) started by this backup
+	ParentCheckpoint string                 `protobuf:"bytes,4,opt,name=parent_checkpoint,json=parentCheckpoint,proto3" json:"parent_checkpoint,omitempty"` // empty for a full backup
+	ParentPath       string                 `protobuf:"bytes,5,opt,name=parent_path,json=parentPath,proto3" json:"parent_path,omitempty"`                   // backing file of an incremental target
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *BackupDiskRequest) Reset() {
+	*x = BackupDiskRequest{}
+	mi := &file_virsh_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BackupDiskRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BackupDiskRequest) ProtoMessage() {}
+
+func (x *BackupDiskRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BackupDiskRequest.ProtoReflect.Descriptor instead.
+func (*BackupDiskRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *BackupDiskRequest) GetVmName() string {
+	if x != nil {
+		return x.VmName
+	}
+	return ""
+}
+
+func (x *BackupDiskRequest) GetTargetPath() string {
+	if x != nil {
+		return x.TargetPath
+	}
+	return ""
+}
+
+func (x *BackupDiskRequest) GetCheckpoint() string {
+	if x != nil {
+		return x.Checkpoint
+	}
+	return ""
+}
+
+func (x *BackupDiskRequest) GetParentCheckpoint() string {
+	if x != nil {
+		return x.ParentCheckpoint
+	}
+	return ""
+}
+
+func (x *BackupDiskRequest) GetParentPath() string {
+	if x != nil {
+		return x.ParentPath
+	}
+	return ""
+}
+
+type FlattenBackupRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SourcePath    string                 `protobuf:"bytes,1,opt,name=source_path,json=sourcePath,proto3" json:"source_path,omitempty"` // newest file of the chain
+	DestPath      string                 `protobuf:"bytes,2,opt,name=dest_path,json=destPath,proto3" json:"dest_path,omitempty"`
+	Compress      bool                   `protobuf:"varint,3,opt,name=compress,proto3" json:"compress,omitempty"` // write qcow2 compressed clusters
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FlattenBackupRequest) Reset() {
+	*x = FlattenBackupRequest{}
+	mi := &file_virsh_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FlattenBackupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FlattenBackupRequest) ProtoMessage() {}
+
+func (x *FlattenBackupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FlattenBackupRequest.ProtoReflect.Descriptor instead.
+func (*FlattenBackupRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *FlattenBackupRequest) GetSourcePath() string {
+	if x != nil {
+		return x.SourcePath
+	}
+	return ""
+}
+
+func (x *FlattenBackupRequest) GetDestPath() string {
+	if x != nil {
+		return x.DestPath
+	}
+	return ""
+}
+
+func (x *FlattenBackupRequest) GetCompress() bool {
+	if x != nil {
+		return x.Compress
+	}
+	return false
+}
+
+type CheckDiskImageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckDiskImageRequest) Reset() {
+	*x = CheckDiskImageRequest{}
+	mi := &file_virsh_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckDiskImageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckDiskImageRequest) ProtoMessage() {}
+
+func (x *CheckDiskImageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckDiskImageRequest.ProtoReflect.Descriptor instead.
+func (*CheckDiskImageRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *CheckDiskImageRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+// CheckDiskImageResponse is the result of qemu-img check, ok is false when
+// the image has corruptions or could not be checked.
+type CheckDiskImageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	Corruptions   int64                  `protobuf:"varint,2,opt,name=corruptions,proto3" json:"corruptions,omitempty"`
+	Leaks         int64                  `protobuf:"varint,3,opt,name=leaks,proto3" json:"leaks,omitempty"`
+	Output        string                 `protobuf:"bytes,4,opt,name=output,proto3" json:"output,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckDiskImageResponse) Reset() {
+	*x = CheckDiskImageResponse{}
+	mi := &file_virsh_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckDiskImageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckDiskImageResponse) ProtoMessage() {}
+
+func (x *CheckDiskImageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckDiskImageResponse.ProtoReflect.Descriptor instead.
+func (*CheckDiskImageResponse) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *CheckDiskImageResponse) GetOk() bool {
+	if x != nil {
+		return x.Ok
+	}
+	return false
+}
+
+func (x *CheckDiskImageResponse) GetCorruptions() int64 {
+	if x != nil {
+		return x.Corruptions
+	}
+	return 0
+}
+
+func (x *CheckDiskImageResponse) GetLeaks() int64 {
+	if x != nil {
+		return x.Leaks
+	}
+	return 0
+}
+
+func (x *CheckDiskImageResponse) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+type TestBootRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Name           string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`                                  // name of the throwaway domain
+	DiskPath       string                 `protobuf:"bytes,2,opt,name=disk_path,json=diskPath,proto3" json:"disk_path,omitempty"`          // newest file of the backup chain, never written to
+	OverlayPath    string                 `protobuf:"bytes,3,opt,name=overlay_path,json=overlayPath,proto3" json:"overlay_path,omitempty"` // qcow2 the guest writes to, empty puts it in the slave scratch folder
+	MemoryMb       int32                  `protobuf:"varint,4,opt,name=memory_mb,json=memoryMb,proto3" json:"memory_mb,omitempty"`
+	Vcpus          int32                  `protobuf:"varint,5,opt,name=vcpus,proto3" json:"vcpus,omitempty"`
+	TimeoutSeconds int32                  `protobuf:"varint,6,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *TestBootRequest) Reset() {
+	*x = TestBootRequest{}
+	mi := &file_virsh_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TestBootRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TestBootRequest) ProtoMessage() {}
+
+func (x *TestBootRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TestBootRequest.ProtoReflect.Descriptor instead.
+func (*TestBootRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *TestBootRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TestBootRequest) GetDiskPath() string {
+	if x != nil {
+		return x.DiskPath
+	}
+	return ""
+}
+
+func (x *TestBootRequest) GetOverlayPath() string {
+	if x != nil {
+		return x.OverlayPath
+	}
+	return ""
+}
+
+func (x *TestBootRequest) GetMemoryMb() int32 {
+	if x != nil {
+		return x.MemoryMb
+	}
+	return 0
+}
+
+func (x *TestBootRequest) GetVcpus() int32 {
+	if x != nil {
+		return x.Vcpus
+	}
+	return 0
+}
+
+func (x *TestBootRequest) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+type TestBootResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AgentOk       bool                   `protobuf:"varint,1,opt,name=agent_ok,json=agentOk,proto3" json:"agent_ok,omitempty"`
+	Seconds       int32                  `protobuf:"varint,2,opt,name=seconds,proto3" json:"seconds,omitempty"` // until the guest agent answered
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TestBootResponse) Reset() {
+	*x = TestBootResponse{}
+	mi := &file_virsh_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TestBootResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TestBootResponse) ProtoMessage() {}
+
+func (x *TestBootResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TestBootResponse.ProtoReflect.Descriptor instead.
+func (*TestBootResponse) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *TestBootResponse) GetAgentOk() bool {
+	if x != nil {
+		return x.AgentOk
+	}
+	return false
+}
+
+func (x *TestBootResponse) GetSeconds() int32 {
+	if x != nil {
+		return x.Seconds
+	}
+	return 0
+}
+
+func (x *TestBootResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type TCPPortRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Host          string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	Port          int32                  `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+	TimeoutMs     int32                  `protobuf:"varint,3,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TCPPortRequest) Reset() {
+	*x = TCPPortRequest{}
+	mi := &file_virsh_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TCPPortRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TCPPortRequest) ProtoMessage() {}
+
+func (x *TCPPortRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TCPPortRequest.ProtoReflect.Descriptor instead.
+func (*TCPPortRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *TCPPortRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *TCPPortRequest) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *TCPPortRequest) GetTimeoutMs() int32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+type BlockCopyRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	VmName          string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
+	SourcePath      string                 `protobuf:"bytes,2,opt,name=source_path,json=sourcePath,proto3" json:"source_path,omitempty"`                   // disk of the running domain to move
+	DestPath        string                 `protobuf:"bytes,3,opt,name=dest_path,json=destPath,proto3" json:"dest_path,omitempty"`                         // created by the copy job, must not exist
+	Format          string                 `protobuf:"bytes,4,opt,name=format,proto3" json:"format,omitempty"`                                             // qcow2 when empty
+	MaxBandwidthMib uint64                 `protobuf:"varint,5,opt,name=max_bandwidth_mib,json=maxBandwidthMib,proto3" json:"max_bandwidth_mib,omitempty"` // 0 = unlimited
+	RemoveSource    bool                   `protobuf:"varint,6,opt,name=remove_source,json=removeSource,proto3" json:"remove_source,omitempty"`            // delete the old file after the pivot
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *BlockCopyRequest) Reset() {
+	*x = BlockCopyRequest{}
+	mi := &file_virsh_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockCopyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockCopyRequest) ProtoMessage() {}
+
+func (x *BlockCopyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockCopyRequest.ProtoReflect.Descriptor instead.
+func (*BlockCopyRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *BlockCopyRequest) GetVmName() string {
+	if x != nil {
+		return x.VmName
+	}
+	return ""
+}
+
+func (x *BlockCopyRequest) GetSourcePath() string {
+	if x != nil {
+		return x.SourcePath
+	}
+	return ""
+}
+
+func (x *BlockCopyRequest) GetDestPath() string {
+	if x != nil {
+		return x.DestPath
+	}
+	return ""
+}
+
+func (x *BlockCopyRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *BlockCopyRequest) GetMaxBandwidthMib() uint64 {
+	if x != nil {
+		return x.MaxBandwidthMib
+	}
+	return 0
+}
+
+func (x *BlockCopyRequest) GetRemoveSource() bool {
+	if x != nil {
+		return x.RemoveSource
+	}
+	return false
+}
+
+type BlockCopyProgress struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Phase         string                 `protobuf:"bytes,1,opt,name=phase,proto3" json:"phase,omitempty"` // copying, ready, pivoted, failed, cancelled
+	Percent       float64                `protobuf:"fixed64,2,opt,name=percent,proto3" json:"percent,omitempty"`
+	Cur           uint64                 `protobuf:"varint,3,opt,name=cur,proto3" json:"cur,omitempty"`
+	End           uint64                 `protobuf:"varint,4,opt,name=end,proto3" json:"end,omitempty"`
+	Message       string                 `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockCopyProgress) Reset() {
+	*x = BlockCopyProgress{}
+	mi := &file_virsh_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockCopyProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockCopyProgress) ProtoMessage() {}
+
+func (x *BlockCopyProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockCopyProgress.ProtoReflect.Descriptor instead.
+func (*BlockCopyProgress) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *BlockCopyProgress) GetPhase() string {
+	if x != nil {
+		return x.Phase
+	}
+	return ""
+}
+
+func (x *BlockCopyProgress) GetPercent() float64 {
+	if x != nil {
+		return x.Percent
+	}
+	return 0
+}
+
+func (x *BlockCopyProgress) GetCur() uint64 {
+	if x != nil {
+		return x.Cur
+	}
+	return 0
+}
+
+func (x *BlockCopyProgress) GetEnd() uint64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *BlockCopyProgress) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// File-level restore: a backup image attached read-only with qemu-nbd, its
+// filesystems mounted read-only on demand under the session directory.
+type FileRestoreMountRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	SessionId      string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	ImagePath      string                 `protobuf:"bytes,2,opt,name=image_path,json=imagePath,proto3" json:"image_path,omitempty"` // plain qcow2, backing files are followed
+	TimeoutSeconds int32                  `protobuf:"varint,3,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *FileRestoreMountRequest) Reset() {
+	*x = FileRestoreMountRequest{}
+	mi := &file_virsh_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileRestoreMountRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileRestoreMountRequest) ProtoMessage() {}
+
+func (x *FileRestoreMountRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[48]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileRestoreMountRequest.ProtoReflect.Descriptor instead.
+func (*FileRestoreMountRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *FileRestoreMountRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *FileRestoreMountRequest) GetImagePath() string {
+	if x != nil {
+		return x.ImagePath
+	}
+	return ""
+}
+
+func (x *FileRestoreMountRequest) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+type FileRestorePartition struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // p1, p2... or disk for a filesystem without partition table
+	Device        string                 `protobuf:"bytes,2,opt,name=device,proto3" json:"device,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	Fstype        string                 `protobuf:"bytes,4,opt,name=fstype,proto3" json:"fstype,omitempty"`
+	Label         string                 `protobuf:"bytes,5,opt,name=label,proto3" json:"label,omitempty"`
+	Uuid          string                 `protobuf:"bytes,6,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Mountable     bool                   `protobuf:"varint,7,opt,name=mountable,proto3" json:"mountable,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileRestorePartition) Reset() {
+	*x = FileRestorePartition{}
+	mi := &file_virsh_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileRestorePartition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileRestorePartition) ProtoMessage() {}
+
+func (x *FileRestorePartition) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[49]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileRestorePartition.ProtoReflect.Descriptor instead.
+func (*FileRestorePartition) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *FileRestorePartition) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *FileRestorePartition) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *FileRestorePartition) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileRestorePartition) GetFstype() string {
+	if x != nil {
+		return x.Fstype
+	}
+	return ""
+}
+
+func (x *FileRestorePartition) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *FileRestorePartition) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *FileRestorePartition) GetMountable() bool {
+	if x != nil {
+		return x.Mountable
+	}
+	return false
+}
+
+type FileRestoreMountResponse struct {
+	state         protoimpl.MessageState  `protogen:"open.v1"`
+	Partitions    []*FileRestorePartition `protobuf:"bytes,1,rep,name=partitions,proto3" json:"partitions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileRestoreMountResponse) Reset() {
+	*x = FileRestoreMountResponse{}
+	mi := &file_virsh_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileRestoreMountResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileRestoreMountResponse) ProtoMessage() {}
+
+func (x *FileRestoreMountResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileRestoreMountResponse.ProtoReflect.Descriptor instead.
+func (*FileRestoreMountResponse) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *FileRestoreMountResponse) GetPartitions() []*FileRestorePartition {
+	if x != nil {
+		return x.Partitions
+	}
+	return nil
+}
+
+type FileRestoreBrowseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Partition     string                 `protobuf:"bytes,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	Path          string                 `protobuf:"bytes,3,opt,name=path,proto3" json:"path,omitempty"` // inside the filesystem, / when empty
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileRestoreBrowseRequest) Reset() {
+	*x = FileRestoreBrowseRequest{}
+	mi := &file_virsh_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileRestoreBrowseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileRestoreBrowseRequest) ProtoMessage() {}
+
+func (x *FileRestoreBrowseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileRestoreBrowseRequest.ProtoReflect.Descriptor instead.
+func (*FileRestoreBrowseRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *FileRestoreBrowseRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *FileRestoreBrowseRequest) GetPartition() string {
+	if x != nil {
+		return x.Partition
+	}
+	return ""
+}
+
+func (x *FileRestoreBrowseRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type FileRestoreEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Dir           bool                   `protobuf:"varint,2,opt,name=dir,proto3" json:"dir,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	ModTime       int64                  `protobuf:"varint,4,opt,name=mod_time,json=modTime,proto3" json:"mod_time,omitempty"` // unix seconds
+	Mode          string                 `protobuf:"bytes,5,opt,name=mode,proto3" json:"mode,omitempty"`
+	LinkTarget    string                 `protobuf:"bytes,6,opt,name=link_target,json=linkTarget,proto3" json:"link_target,omitempty"` // symlinks are listed, never followed
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileRestoreEntry) Reset() {
+	*x = FileRestoreEntry{}
+	mi := &file_virsh_proto_msgTypes[52]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileRestoreEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileRestoreEntry) ProtoMessage() {}
+
+func (x *FileRestoreEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[52]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileRestoreEntry.ProtoReflect.Descriptor instead.
+func (*FileRestoreEntry) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *FileRestoreEntry) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *FileRestoreEntry) GetDir() bool {
+	if x != nil {
+		return x.Dir
+	}
+	return false
+}
+
+func (x *FileRestoreEntry) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileRestoreEntry) GetModTime() int64 {
+	if x != nil {
+		return x.ModTime
+	}
+	return 0
+}
+
+func (x *FileRestoreEntry) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *FileRestoreEntry) GetLinkTarget() string {
+	if x != nil {
+		return x.LinkTarget
+	}
+	return ""
+}
+
+type FileRestoreBrowseResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Entries       []*FileRestoreEntry    `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileRestoreBrowseResponse) Reset() {
+	*x = FileRestoreBrowseResponse{}
+	mi := &file_virsh_proto_msgTypes[53]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileRestoreBrowseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileRestoreBrowseResponse) ProtoMessage() {}
+
+func (x *FileRestoreBrowseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[53]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileRestoreBrowseResponse.ProtoReflect.Descriptor instead.
+func (*FileRestoreBrowseResponse) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *FileRestoreBrowseResponse) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FileRestoreBrowseResponse) GetEntries() []*FileRestoreEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type FileRestoreReadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Partition     string                 `protobuf:"bytes,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	Paths         []string               `protobuf:"bytes,3,rep,name=paths,proto3" json:"paths,omitempty"`
+	Tar           bool                   `protobuf:"varint,4,opt,name=tar,proto3" json:"tar,omitempty"` // a tar of paths, directories recursively, instead of one file
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileRestoreReadRequest) Reset() {
+	*x = FileRestoreReadRequest{}
+	mi := &file_virsh_proto_msgTypes[54]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileRestoreReadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileRestoreReadRequest) ProtoMessage() {}
+
+func (x *FileRestoreReadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[54]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileRestoreReadRequest.ProtoReflect.Descriptor instead.
+func (*FileRestoreReadRequest) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *FileRestoreReadRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *FileRestoreReadRequest) GetPartition() string {
+	if x != nil {
+		return x.Partition
+	}
+	return ""
+}
+
+func (x *FileRestoreReadRequest) GetPaths() []string {
+	if x != nil {
+		return x.Paths
+	}
+	return nil
+}
+
+func (x *FileRestoreReadRequest) GetTar() bool {
+	if x != nil {
+		return x.Tar
+	}
+	return false
+}
+
+type FileChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileChunk) Reset() {
+	*x = FileChunk{}
+	mi := &file_virsh_proto_msgTypes[55]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileChunk) ProtoMessage() {}
+
+func (x *FileChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[55]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileChunk.ProtoReflect.Descriptor instead.
+func (*FileChunk) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{55}
+}
+
+func (x *FileChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// ScratchChunk streams an image into the scratch folder of a slave, a local
+// folder no share and no other slave sees. name is read from the first chunk.
+type ScratchChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScratchChunk) Reset() {
+	*x = ScratchChunk{}
+	mi := &file_virsh_proto_msgTypes[56]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScratchChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScratchChunk) ProtoMessage() {}
+
+func (x *ScratchChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[56]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScratchChunk.ProtoReflect.Descriptor instead.
+func (*ScratchChunk) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{56}
+}
+
+func (x *ScratchChunk) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ScratchChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ScratchImage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"` // on the slave
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScratchImage) Reset() {
+	*x = ScratchImage{}
+	mi := &file_virsh_proto_msgTypes[57]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScratchImage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScratchImage) ProtoMessage() {}
+
+func (x *ScratchImage) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[57]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScratchImage.ProtoReflect.Descriptor instead.
+func (*ScratchImage) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{57}
+}
+
+func (x *ScratchImage) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ScratchImage) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type FileRestoreSession struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	SessionId      string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	TimeoutSeconds int32                  `protobuf:"varint,2,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"` // for the unmount and the nbd disconnect
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *FileRestoreSession) Reset() {
+	*x = FileRestoreSession{}
+	mi := &file_virsh_proto_msgTypes[58]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileRestoreSession) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileRestoreSession) ProtoMessage() {}
+
+func (x *FileRestoreSession) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[58]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileRestoreSession.ProtoReflect.Descriptor instead.
+func (*FileRestoreSession) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{58}
+}
+
+func (x *FileRestoreSession) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *FileRestoreSession) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+// VmDiskStats are the block counters of one disk, rates are per second over
+// the sampling interval of the slave.
+type VmDiskStats struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Device           string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"` // target dev, vda
+	Path             string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	ReadIops         float64                `protobuf:"fixed64,3,opt,name=read_iops,json=readIops,proto3" json:"read_iops,omitempty"`
+	WriteIops        float64                `protobuf:"fixed64,4,opt,name=write_iops,json=writeIops,proto3" json:"write_iops,omitempty"`
+	ReadBytesPerSec  float64                `protobuf:"fixed64,5,opt,name=read_bytes_per_sec,json=readBytesPerSec,proto3" json:"read_bytes_per_sec,omitempty"`
+	WriteBytesPerSec float64                `protobuf:"fixed64,6,opt,name=write_bytes_per_sec,json=writeBytesPerSec,proto3" json:"write_bytes_per_sec,omitempty"`
+	ReadBytes        uint64                 `protobuf:"varint,7,opt,name=read_bytes,json=readBytes,proto3" json:"read_bytes,omitempty"` // totals since the domain started
+	WriteBytes       uint64                 `protobuf:"varint,8,opt,name=write_bytes,json=writeBytes,proto3" json:"write_bytes,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *VmDiskStats) Reset() {
+	*x = VmDiskStats{}
+	mi := &file_virsh_proto_msgTypes[59]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VmDiskStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VmDiskStats) ProtoMessage() {}
+
+func (x *VmDiskStats) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[59]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VmDiskStats.ProtoReflect.Descriptor instead.
+func (*VmDiskStats) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{59}
+}
+
+func (x *VmDiskStats) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *VmDiskStats) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *VmDiskStats) GetReadIops() float64 {
+	if x != nil {
+		return x.ReadIops
+	}
+	return 0
+}
+
+func (x *VmDiskStats) GetWriteIops() float64 {
+	if x != nil {
+		return x.WriteIops
+	}
+	return 0
+}
+
+func (x *VmDiskStats) GetReadBytesPerSec() float64 {
+	if x != nil {
+		return x.ReadBytesPerSec
+	}
+	return 0
+}
+
+func (x *VmDiskStats) GetWriteBytesPerSec() float64 {
+	if x != nil {
+		return x.WriteBytesPerSec
+	}
+	return 0
 }
 
-func (x *SetKVMHiddenRequest) GetVmName() string {
+func (x *VmDiskStats) GetReadBytes() uint64 {
 	if x != nil {
-		return x.VmName
+		return x.ReadBytes
 	}
-	return ""
+	return 0
 }
 
-func (x *SetKVMHiddenRequest) GetHidden() bool {
+func (x *VmDiskStats) GetWriteBytes() uint64 {
 	if x != nil {
-		return x.Hidden
+		return x.WriteBytes
 	}
-	return false
+	return 0
 }
 
-type KVMHiddenResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	VmName        string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
-	Hidden        bool                   `protobuf:"varint,2,opt,name=hidden,proto3" json:"hidden,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+type VmInterfaceStats struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Device          string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"` // host side tap, vnet0
+	Mac             string                 `protobuf:"bytes,2,opt,name=mac,proto3" json:"mac,omitempty"`
+	RxBytesPerSec   float64                `protobuf:"fixed64,3,opt,name=rx_bytes_per_sec,json=rxBytesPerSec,proto3" json:"rx_bytes_per_sec,omitempty"`
+	TxBytesPerSec   float64                `protobuf:"fixed64,4,opt,name=tx_bytes_per_sec,json=txBytesPerSec,proto3" json:"tx_bytes_per_sec,omitempty"`
+	RxPacketsPerSec float64                `protobuf:"fixed64,5,opt,name=rx_packets_per_sec,json=rxPacketsPerSec,proto3" json:"rx_packets_per_sec,omitempty"`
+	TxPacketsPerSec float64                `protobuf:"fixed64,6,opt,name=tx_packets_per_sec,json=txPacketsPerSec,proto3" json:"tx_packets_per_sec,omitempty"`
+	RxBytes         uint64                 `protobuf:"varint,7,opt,name=rx_bytes,json=rxBytes,proto3" json:"rx_bytes,omitempty"`
+	TxBytes         uint64                 `protobuf:"varint,8,opt,name=tx_bytes,json=txBytes,proto3" json:"tx_bytes,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
-func (x *KVMHiddenResponse) Reset() {
-	*x = KVMHiddenResponse{}
-	mi := &file_virsh_proto_msgTypes[25]
+func (x *VmInterfaceStats) Reset() {
+	*x = VmInterfaceStats{}
+	mi := &file_virsh_proto_msgTypes[60]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *KVMHiddenResponse) String() string {
+func (x *VmInterfaceStats) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*KVMHiddenResponse) ProtoMessage() {}
+func (*VmInterfaceStats) ProtoMessage() {}
 
-func (x *KVMHiddenResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[25]
+func (x *VmInterfaceStats) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[60]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1710,100 +4075,96 @@ func (x *KVMHiddenResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use KVMHiddenResponse.ProtoReflect.Descriptor instead.
-func (*KVMHiddenResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{25}
+// Deprecated: Use VmInterfaceStats.ProtoReflect.Descriptor instead.
+func (*VmInterfaceStats) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{60}
 }
 
-func (x *KVMHiddenResponse) GetVmName() string {
+func (x *VmInterfaceStats) GetDevice() string {
 	if x != nil {
-		return x.VmName
+		return x.Device
 	}
 	return ""
 }
 
-func (x *KVMHiddenResponse) GetHidden() bool {
+func (x *VmInterfaceStats) GetMac() string {
 	if x != nil {
-		return x.Hidden
+		return x.Mac
 	}
-	return false
-}
-
-type SetHyperVRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	VmName        string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
-	Hyperv        bool                   `protobuf:"varint,2,opt,name=hyperv,proto3" json:"hyperv,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	return ""
 }
 
-func (x *SetHyperVRequest) Reset() {
-	*x = SetHyperVRequest{}
-	mi := &file_virsh_proto_msgTypes[26]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *VmInterfaceStats) GetRxBytesPerSec() float64 {
+	if x != nil {
+		return x.RxBytesPerSec
+	}
+	return 0
 }
 
-func (x *SetHyperVRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *VmInterfaceStats) GetTxBytesPerSec() float64 {
+	if x != nil {
+		return x.TxBytesPerSec
+	}
+	return 0
 }
 
-func (*SetHyperVRequest) ProtoMessage() {}
-
-func (x *SetHyperVRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[26]
+func (x *VmInterfaceStats) GetRxPacketsPerSec() float64 {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.RxPacketsPerSec
 	}
-	return mi.MessageOf(x)
+	return 0
 }
 
-// Deprecated: Use SetHyperVRequest.ProtoReflect.Descriptor instead.
-func (*SetHyperVRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{26}
+func (x *VmInterfaceStats) GetTxPacketsPerSec() float64 {
+	if x != nil {
+		return x.TxPacketsPerSec
+	}
+	return 0
 }
 
-func (x *SetHyperVRequest) GetVmName() string {
+func (x *VmInterfaceStats) GetRxBytes() uint64 {
 	if x != nil {
-		return x.VmName
+		return x.RxBytes
 	}
-	return ""
+	return 0
 }
 
-func (x *SetHyperVRequest) GetHyperv() bool {
+func (x *VmInterfaceStats) GetTxBytes() uint64 {
 	if x != nil {
-		return x.Hyperv
+		return x.TxBytes
 	}
-	return false
+	return 0
 }
 
-type HyperVResponse struct {
+type VmStats struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	VmName        string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
-	Hyperv        bool                   `protobuf:"varint,2,opt,name=hyperv,proto3" json:"hyperv,omitempty"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	CpuPercent    float64                `protobuf:"fixed64,2,opt,name=cpu_percent,json=cpuPercent,proto3" json:"cpu_percent,omitempty"`
+	Vcpus         int32                  `protobuf:"varint,3,opt,name=vcpus,proto3" json:"vcpus,omitempty"`
+	MemTotalKib   uint64                 `protobuf:"varint,4,opt,name=mem_total_kib,json=memTotalKib,proto3" json:"mem_total_kib,omitempty"` // balloon size
+	MemUsedKib    uint64                 `protobuf:"varint,5,opt,name=mem_used_kib,json=memUsedKib,proto3" json:"mem_used_kib,omitempty"`
+	MemRssKib     uint64                 `protobuf:"varint,6,opt,name=mem_rss_kib,json=memRssKib,proto3" json:"mem_rss_kib,omitempty"`
+	Disks         []*VmDiskStats         `protobuf:"bytes,7,rep,name=disks,proto3" json:"disks,omitempty"`
+	Interfaces    []*VmInterfaceStats    `protobuf:"bytes,8,rep,name=interfaces,proto3" json:"interfaces,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *HyperVResponse) Reset() {
-	*x = HyperVResponse{}
-	mi := &file_virsh_proto_msgTypes[27]
+func (x *VmStats) Reset() {
+	*x = VmStats{}
+	mi := &file_virsh_proto_msgTypes[61]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *HyperVResponse) String() string {
+func (x *VmStats) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*HyperVResponse) ProtoMessage() {}
+func (*VmStats) ProtoMessage() {}
 
-func (x *HyperVResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[27]
+func (x *VmStats) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[61]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1814,108 +4175,89 @@ func (x *HyperVResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use HyperVResponse.ProtoReflect.Descriptor instead.
-func (*HyperVResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{27}
+// Deprecated: Use VmStats.ProtoReflect.Descriptor instead.
+func (*VmStats) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{61}
 }
 
-func (x *HyperVResponse) GetVmName() string {
+func (x *VmStats) GetName() string {
 	if x != nil {
-		return x.VmName
+		return x.Name
 	}
 	return ""
 }
 
-func (x *HyperVResponse) GetHyperv() bool {
+func (x *VmStats) GetCpuPercent() float64 {
 	if x != nil {
-		return x.Hyperv
+		return x.CpuPercent
 	}
-	return false
-}
-
-type ExternalDiskRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	VmName        string                 `protobuf:"bytes,1,opt,name=vm_name,json=vmName,proto3" json:"vm_name,omitempty"`
-	DiskPath      string                 `protobuf:"bytes,2,opt,name=disk_path,json=diskPath,proto3" json:"disk_path,omitempty"`
-	Format        string                 `protobuf:"bytes,3,opt,name=format,proto3" json:"format,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *ExternalDiskRequest) Reset() {
-	*x = ExternalDiskRequest{}
-	mi := &file_virsh_proto_msgTypes[28]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+	return 0
 }
 
-func (x *ExternalDiskRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *VmStats) GetVcpus() int32 {
+	if x != nil {
+		return x.Vcpus
+	}
+	return 0
 }
 
-func (*ExternalDiskRequest) ProtoMessage() {}
-
-func (x *ExternalDiskRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[28]
+func (x *VmStats) GetMemTotalKib() uint64 {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.MemTotalKib
 	}
-	return mi.MessageOf(x)
+	return 0
 }
 
-// Deprecated: Use ExternalDiskRequest.ProtoReflect.Descriptor instead.
-func (*ExternalDiskRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{28}
+func (x *VmStats) GetMemUsedKib() uint64 {
+	if x != nil {
+		return x.MemUsedKib
+	}
+	return 0
 }
 
-func (x *ExternalDiskRequest) GetVmName() string {
+func (x *VmStats) GetMemRssKib() uint64 {
 	if x != nil {
-		return x.VmName
+		return x.MemRssKib
 	}
-	return ""
+	return 0
 }
 
-func (x *ExternalDiskRequest) GetDiskPath() string {
+func (x *VmStats) GetDisks() []*VmDiskStats {
 	if x != nil {
-		return x.DiskPath
+		return x.Disks
 	}
-	return ""
+	return nil
 }
 
-func (x *ExternalDiskRequest) GetFormat() string {
+func (x *VmStats) GetInterfaces() []*VmInterfaceStats {
 	if x != nil {
-		return x.Format
+		return x.Interfaces
 	}
-	return ""
+	return nil
 }
 
-type ExternalDiskResponse struct {
+type VmStatsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
-	TargetDev     string                 `protobuf:"bytes,2,opt,name=target_dev,json=targetDev,proto3" json:"target_dev,omitempty"`
+	Vms           []*VmStats             `protobuf:"bytes,1,rep,name=vms,proto3" json:"vms,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ExternalDiskResponse) Reset() {
-	*x = ExternalDiskResponse{}
-	mi := &file_virsh_proto_msgTypes[29]
+func (x *VmStatsResponse) Reset() {
+	*x = VmStatsResponse{}
+	mi := &file_virsh_proto_msgTypes[62]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ExternalDiskResponse) String() string {
+func (x *VmStatsResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ExternalDiskResponse) ProtoMessage() {}
+func (*VmStatsResponse) ProtoMessage() {}
 
-func (x *ExternalDiskResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[29]
+func (x *VmStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_virsh_proto_msgTypes[62]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1926,23 +4268,16 @@ func (x *ExternalDiskResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ExternalDiskResponse.ProtoReflect.Descriptor instead.
-func (*ExternalDiskResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{29}
-}
-
-func (x *ExternalDiskResponse) GetOk() bool {
-	if x != nil {
-		return x.Ok
-	}
-	return false
+// Deprecated: Use VmStatsResponse.ProtoReflect.Descriptor instead.
+func (*VmStatsResponse) Descriptor() ([]byte, []int) {
+	return file_virsh_proto_rawDescGZIP(), []int{62}
 }
 
-func (x *ExternalDiskResponse) GetTargetDev() string {
+func (x *VmStatsResponse) GetVms() []*VmStats {
 	if x != nil {
-		return x.TargetDev
+		return x.Vms
 	}
-	return ""
+	return nil
 }
 
 // CPU Pinning messages
@@ -1959,7 +4294,7 @@ type CPUPinningRequest struct {
 
 func (x *CPUPinningRequest) Reset() {
 	*x = CPUPinningRequest{}
-	mi := &file_virsh_proto_msgTypes[30]
+	mi := &file_virsh_proto_msgTypes[63]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1971,7 +4306,7 @@ func (x *CPUPinningRequest) String() string {
 func (*CPUPinningRequest) ProtoMessage() {}
 
 func (x *CPUPinningRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[30]
+	mi := &file_virsh_proto_msgTypes[63]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1984,7 +4319,7 @@ func (x *CPUPinningRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CPUPinningRequest.ProtoReflect.Descriptor instead.
 func (*CPUPinningRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{30}
+	return file_virsh_proto_rawDescGZIP(), []int{63}
 }
 
 func (x *CPUPinningRequest) GetVmName() string {
@@ -2033,7 +4368,7 @@ type CPUPinningInfo struct {
 
 func (x *CPUPinningInfo) Reset() {
 	*x = CPUPinningInfo{}
-	mi := &file_virsh_proto_msgTypes[31]
+	mi := &file_virsh_proto_msgTypes[64]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2045,7 +4380,7 @@ func (x *CPUPinningInfo) String() string {
 func (*CPUPinningInfo) ProtoMessage() {}
 
 func (x *CPUPinningInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[31]
+	mi := &file_virsh_proto_msgTypes[64]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2058,7 +4393,7 @@ func (x *CPUPinningInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CPUPinningInfo.ProtoReflect.Descriptor instead.
 func (*CPUPinningInfo) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{31}
+	return file_virsh_proto_rawDescGZIP(), []int{64}
 }
 
 func (x *CPUPinningInfo) GetVcpu() int32 {
@@ -2097,7 +4432,7 @@ type CPUPinningResponse struct {
 
 func (x *CPUPinningResponse) Reset() {
 	*x = CPUPinningResponse{}
-	mi := &file_virsh_proto_msgTypes[32]
+	mi := &file_virsh_proto_msgTypes[65]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2109,7 +4444,7 @@ func (x *CPUPinningResponse) String() string {
 func (*CPUPinningResponse) ProtoMessage() {}
 
 func (x *CPUPinningResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[32]
+	mi := &file_virsh_proto_msgTypes[65]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2122,7 +4457,7 @@ func (x *CPUPinningResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CPUPinningResponse.ProtoReflect.Descriptor instead.
 func (*CPUPinningResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{32}
+	return file_virsh_proto_rawDescGZIP(), []int{65}
 }
 
 func (x *CPUPinningResponse) GetHasPinning() bool {
@@ -2185,7 +4520,7 @@ type CPUCoreInfo struct {
 
 func (x *CPUCoreInfo) Reset() {
 	*x = CPUCoreInfo{}
-	mi := &file_virsh_proto_msgTypes[33]
+	mi := &file_virsh_proto_msgTypes[66]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2197,7 +4532,7 @@ func (x *CPUCoreInfo) String() string {
 func (*CPUCoreInfo) ProtoMessage() {}
 
 func (x *CPUCoreInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[33]
+	mi := &file_virsh_proto_msgTypes[66]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2210,7 +4545,7 @@ func (x *CPUCoreInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CPUCoreInfo.ProtoReflect.Descriptor instead.
 func (*CPUCoreInfo) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{33}
+	return file_virsh_proto_rawDescGZIP(), []int{66}
 }
 
 func (x *CPUCoreInfo) GetCoreIndex() int32 {
@@ -2244,7 +4579,7 @@ type CPUSocketInfo struct {
 
 func (x *CPUSocketInfo) Reset() {
 	*x = CPUSocketInfo{}
-	mi := &file_virsh_proto_msgTypes[34]
+	mi := &file_virsh_proto_msgTypes[67]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2256,7 +4591,7 @@ func (x *CPUSocketInfo) String() string {
 func (*CPUSocketInfo) ProtoMessage() {}
 
 func (x *CPUSocketInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[34]
+	mi := &file_virsh_proto_msgTypes[67]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2269,7 +4604,7 @@ func (x *CPUSocketInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CPUSocketInfo.ProtoReflect.Descriptor instead.
 func (*CPUSocketInfo) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{34}
+	return file_virsh_proto_rawDescGZIP(), []int{67}
 }
 
 func (x *CPUSocketInfo) GetSocketId() int32 {
@@ -2295,7 +4630,7 @@ type CPUTopologyResponse struct {
 
 func (x *CPUTopologyResponse) Reset() {
 	*x = CPUTopologyResponse{}
-	mi := &file_virsh_proto_msgTypes[35]
+	mi := &file_virsh_proto_msgTypes[68]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2307,7 +4642,7 @@ func (x *CPUTopologyResponse) String() string {
 func (*CPUTopologyResponse) ProtoMessage() {}
 
 func (x *CPUTopologyResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[35]
+	mi := &file_virsh_proto_msgTypes[68]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2320,7 +4655,7 @@ func (x *CPUTopologyResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CPUTopologyResponse.ProtoReflect.Descriptor instead.
 func (*CPUTopologyResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{35}
+	return file_virsh_proto_rawDescGZIP(), []int{68}
 }
 
 func (x *CPUTopologyResponse) GetSockets() []*CPUSocketInfo {
@@ -2341,7 +4676,7 @@ type TunedAdmProfileInfo struct {
 
 func (x *TunedAdmProfileInfo) Reset() {
 	*x = TunedAdmProfileInfo{}
-	mi := &file_virsh_proto_msgTypes[36]
+	mi := &file_virsh_proto_msgTypes[69]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2353,7 +4688,7 @@ func (x *TunedAdmProfileInfo) String() string {
 func (*TunedAdmProfileInfo) ProtoMessage() {}
 
 func (x *TunedAdmProfileInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[36]
+	mi := &file_virsh_proto_msgTypes[69]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2366,7 +4701,7 @@ func (x *TunedAdmProfileInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TunedAdmProfileInfo.ProtoReflect.Descriptor instead.
 func (*TunedAdmProfileInfo) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{36}
+	return file_virsh_proto_rawDescGZIP(), []int{69}
 }
 
 func (x *TunedAdmProfileInfo) GetName() string {
@@ -2400,7 +4735,7 @@ type TunedAdmProfilesResponse struct {
 
 func (x *TunedAdmProfilesResponse) Reset() {
 	*x = TunedAdmProfilesResponse{}
-	mi := &file_virsh_proto_msgTypes[37]
+	mi := &file_virsh_proto_msgTypes[70]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2412,7 +4747,7 @@ func (x *TunedAdmProfilesResponse) String() string {
 func (*TunedAdmProfilesResponse) ProtoMessage() {}
 
 func (x *TunedAdmProfilesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[37]
+	mi := &file_virsh_proto_msgTypes[70]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2425,7 +4760,7 @@ func (x *TunedAdmProfilesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TunedAdmProfilesResponse.ProtoReflect.Descriptor instead.
 func (*TunedAdmProfilesResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{37}
+	return file_virsh_proto_rawDescGZIP(), []int{70}
 }
 
 func (x *TunedAdmProfilesResponse) GetProfiles() []*TunedAdmProfileInfo {
@@ -2451,7 +4786,7 @@ type SetTunedAdmProfileRequest struct {
 
 func (x *SetTunedAdmProfileRequest) Reset() {
 	*x = SetTunedAdmProfileRequest{}
-	mi := &file_virsh_proto_msgTypes[38]
+	mi := &file_virsh_proto_msgTypes[71]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2463,7 +4798,7 @@ func (x *SetTunedAdmProfileRequest) String() string {
 func (*SetTunedAdmProfileRequest) ProtoMessage() {}
 
 func (x *SetTunedAdmProfileRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[38]
+	mi := &file_virsh_proto_msgTypes[71]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2476,7 +4811,7 @@ func (x *SetTunedAdmProfileRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetTunedAdmProfileRequest.ProtoReflect.Descriptor instead.
 func (*SetTunedAdmProfileRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{38}
+	return file_virsh_proto_rawDescGZIP(), []int{71}
 }
 
 func (x *SetTunedAdmProfileRequest) GetProfile() string {
@@ -2497,7 +4832,7 @@ type SetTunedAdmProfileResponse struct {
 
 func (x *SetTunedAdmProfileResponse) Reset() {
 	*x = SetTunedAdmProfileResponse{}
-	mi := &file_virsh_proto_msgTypes[39]
+	mi := &file_virsh_proto_msgTypes[72]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2509,7 +4844,7 @@ func (x *SetTunedAdmProfileResponse) String() string {
 func (*SetTunedAdmProfileResponse) ProtoMessage() {}
 
 func (x *SetTunedAdmProfileResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[39]
+	mi := &file_virsh_proto_msgTypes[72]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2522,7 +4857,7 @@ func (x *SetTunedAdmProfileResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetTunedAdmProfileResponse.ProtoReflect.Descriptor instead.
 func (*SetTunedAdmProfileResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{39}
+	return file_virsh_proto_rawDescGZIP(), []int{72}
 }
 
 func (x *SetTunedAdmProfileResponse) GetOk() bool {
@@ -2557,7 +4892,7 @@ type IrqBalanceStateResponse struct {
 
 func (x *IrqBalanceStateResponse) Reset() {
 	*x = IrqBalanceStateResponse{}
-	mi := &file_virsh_proto_msgTypes[40]
+	mi := &file_virsh_proto_msgTypes[73]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2569,7 +4904,7 @@ func (x *IrqBalanceStateResponse) String() string {
 func (*IrqBalanceStateResponse) ProtoMessage() {}
 
 func (x *IrqBalanceStateResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[40]
+	mi := &file_virsh_proto_msgTypes[73]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2582,7 +4917,7 @@ func (x *IrqBalanceStateResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use IrqBalanceStateResponse.ProtoReflect.Descriptor instead.
 func (*IrqBalanceStateResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{40}
+	return file_virsh_proto_rawDescGZIP(), []int{73}
 }
 
 func (x *IrqBalanceStateResponse) GetEnabled() bool {
@@ -2615,7 +4950,7 @@ type SetIrqBalanceStateRequest struct {
 
 func (x *SetIrqBalanceStateRequest) Reset() {
 	*x = SetIrqBalanceStateRequest{}
-	mi := &file_virsh_proto_msgTypes[41]
+	mi := &file_virsh_proto_msgTypes[74]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2627,7 +4962,7 @@ func (x *SetIrqBalanceStateRequest) String() string {
 func (*SetIrqBalanceStateRequest) ProtoMessage() {}
 
 func (x *SetIrqBalanceStateRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[41]
+	mi := &file_virsh_proto_msgTypes[74]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2640,7 +4975,7 @@ func (x *SetIrqBalanceStateRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetIrqBalanceStateRequest.ProtoReflect.Descriptor instead.
 func (*SetIrqBalanceStateRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{41}
+	return file_virsh_proto_rawDescGZIP(), []int{74}
 }
 
 func (x *SetIrqBalanceStateRequest) GetEnabled() bool {
@@ -2663,7 +4998,7 @@ type SetIrqBalanceStateResponse struct {
 
 func (x *SetIrqBalanceStateResponse) Reset() {
 	*x = SetIrqBalanceStateResponse{}
-	mi := &file_virsh_proto_msgTypes[42]
+	mi := &file_virsh_proto_msgTypes[75]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2675,7 +5010,7 @@ func (x *SetIrqBalanceStateResponse) String() string {
 func (*SetIrqBalanceStateResponse) ProtoMessage() {}
 
 func (x *SetIrqBalanceStateResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[42]
+	mi := &file_virsh_proto_msgTypes[75]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2688,7 +5023,7 @@ func (x *SetIrqBalanceStateResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetIrqBalanceStateResponse.ProtoReflect.Descriptor instead.
 func (*SetIrqBalanceStateResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{42}
+	return file_virsh_proto_rawDescGZIP(), []int{75}
 }
 
 func (x *SetIrqBalanceStateResponse) GetOk() bool {
@@ -2736,7 +5071,7 @@ type HostCoreIsolationSocketSelection struct {
 
 func (x *HostCoreIsolationSocketSelection) Reset() {
 	*x = HostCoreIsolationSocketSelection{}
-	mi := &file_virsh_proto_msgTypes[43]
+	mi := &file_virsh_proto_msgTypes[76]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2748,7 +5083,7 @@ func (x *HostCoreIsolationSocketSelection) String() string {
 func (*HostCoreIsolationSocketSelection) ProtoMessage() {}
 
 func (x *HostCoreIsolationSocketSelection) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[43]
+	mi := &file_virsh_proto_msgTypes[76]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2761,7 +5096,7 @@ func (x *HostCoreIsolationSocketSelection) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HostCoreIsolationSocketSelection.ProtoReflect.Descriptor instead.
 func (*HostCoreIsolationSocketSelection) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{43}
+	return file_virsh_proto_rawDescGZIP(), []int{76}
 }
 
 func (x *HostCoreIsolationSocketSelection) GetSocketId() int32 {
@@ -2787,7 +5122,7 @@ type SetHostCoreIsolationRequest struct {
 
 func (x *SetHostCoreIsolationRequest) Reset() {
 	*x = SetHostCoreIsolationRequest{}
-	mi := &file_virsh_proto_msgTypes[44]
+	mi := &file_virsh_proto_msgTypes[77]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2799,7 +5134,7 @@ func (x *SetHostCoreIsolationRequest) String() string {
 func (*SetHostCoreIsolationRequest) ProtoMessage() {}
 
 func (x *SetHostCoreIsolationRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[44]
+	mi := &file_virsh_proto_msgTypes[77]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2812,7 +5147,7 @@ func (x *SetHostCoreIsolationRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetHostCoreIsolationRequest.ProtoReflect.Descriptor instead.
 func (*SetHostCoreIsolationRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{44}
+	return file_virsh_proto_rawDescGZIP(), []int{77}
 }
 
 func (x *SetHostCoreIsolationRequest) GetSockets() []*HostCoreIsolationSocketSelection {
@@ -2834,7 +5169,7 @@ type HostCoreIsolationSocketState struct {
 
 func (x *HostCoreIsolationSocketState) Reset() {
 	*x = HostCoreIsolationSocketState{}
-	mi := &file_virsh_proto_msgTypes[45]
+	mi := &file_virsh_proto_msgTypes[78]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2846,7 +5181,7 @@ func (x *HostCoreIsolationSocketState) String() string {
 func (*HostCoreIsolationSocketState) ProtoMessage() {}
 
 func (x *HostCoreIsolationSocketState) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[45]
+	mi := &file_virsh_proto_msgTypes[78]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2859,7 +5194,7 @@ func (x *HostCoreIsolationSocketState) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HostCoreIsolationSocketState.ProtoReflect.Descriptor instead.
 func (*HostCoreIsolationSocketState) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{45}
+	return file_virsh_proto_rawDescGZIP(), []int{78}
 }
 
 func (x *HostCoreIsolationSocketState) GetSocketId() int32 {
@@ -2908,7 +5243,7 @@ type HostCoreIsolationStateResponse struct {
 
 func (x *HostCoreIsolationStateResponse) Reset() {
 	*x = HostCoreIsolationStateResponse{}
-	mi := &file_virsh_proto_msgTypes[46]
+	mi := &file_virsh_proto_msgTypes[79]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2920,7 +5255,7 @@ func (x *HostCoreIsolationStateResponse) String() string {
 func (*HostCoreIsolationStateResponse) ProtoMessage() {}
 
 func (x *HostCoreIsolationStateResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[46]
+	mi := &file_virsh_proto_msgTypes[79]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2933,7 +5268,7 @@ func (x *HostCoreIsolationStateResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HostCoreIsolationStateResponse.ProtoReflect.Descriptor instead.
 func (*HostCoreIsolationStateResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{46}
+	return file_virsh_proto_rawDescGZIP(), []int{79}
 }
 
 func (x *HostCoreIsolationStateResponse) GetEnabled() bool {
@@ -3016,7 +5351,7 @@ type SetHostHugePagesRequest struct {
 
 func (x *SetHostHugePagesRequest) Reset() {
 	*x = SetHostHugePagesRequest{}
-	mi := &file_virsh_proto_msgTypes[47]
+	mi := &file_virsh_proto_msgTypes[80]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3028,7 +5363,7 @@ func (x *SetHostHugePagesRequest) String() string {
 func (*SetHostHugePagesRequest) ProtoMessage() {}
 
 func (x *SetHostHugePagesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[47]
+	mi := &file_virsh_proto_msgTypes[80]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3041,7 +5376,7 @@ func (x *SetHostHugePagesRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetHostHugePagesRequest.ProtoReflect.Descriptor instead.
 func (*SetHostHugePagesRequest) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{47}
+	return file_virsh_proto_rawDescGZIP(), []int{80}
 }
 
 func (x *SetHostHugePagesRequest) GetPageSize() string {
@@ -3079,7 +5414,7 @@ type HostHugePagesStateResponse struct {
 
 func (x *HostHugePagesStateResponse) Reset() {
 	*x = HostHugePagesStateResponse{}
-	mi := &file_virsh_proto_msgTypes[48]
+	mi := &file_virsh_proto_msgTypes[81]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3091,7 +5426,7 @@ func (x *HostHugePagesStateResponse) String() string {
 func (*HostHugePagesStateResponse) ProtoMessage() {}
 
 func (x *HostHugePagesStateResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_virsh_proto_msgTypes[48]
+	mi := &file_virsh_proto_msgTypes[81]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3104,7 +5439,7 @@ func (x *HostHugePagesStateResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HostHugePagesStateResponse.ProtoReflect.Descriptor instead.
 func (*HostHugePagesStateResponse) Descriptor() ([]byte, []int) {
-	return file_virsh_proto_rawDescGZIP(), []int{48}
+	return file_virsh_proto_rawDescGZIP(), []int{81}
 }
 
 func (x *HostHugePagesStateResponse) GetEnabled() bool {
@@ -3205,7 +5540,7 @@ const file_virsh_proto_rawDesc = "" +
 	"\vvirsh.proto\x12\x05virsh\"\a\n" +
 	"\x05Empty\"4\n" +
 	"\x16GetCpuFeaturesResponse\x12\x1a\n" +
-	"\bfeatures\x18\x01 \x03(\tR\bfeatures\"\xbf\x02\n" +
+	"\bfeatures\x18\x01 \x03(\tR\bfeatures\"\xf6\x02\n" +
 	"\x0fCreateVmRequest\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
 	"\x06memory\x18\x02 \x01(\x05R\x06memory\x12\x12\n" +
@@ -3221,7 +5556,23 @@ const file_virsh_proto_rawDesc = "" +
 	"\x06cpuXml\x18\n" +
 	" \x01(\tR\x06cpuXml\x12\x1d\n" +
 	"\n" +
-	"is_windows\x18\v \x01(\bR\tisWindows\"6\n" +
+	"is_windows\x18\v \x01(\bR\tisWindows\x125\n" +
+	"\n" +
+	"cloud_init\x18\f \x01(\v2\x16.virsh.CloudInitConfigR\tcloudInit\"\x99\x01\n" +
+	"\rCloudInitUser\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\x12.\n" +
+	"\x13ssh_authorized_keys\x18\x03 \x03(\tR\x11sshAuthorizedKeys\x12\x12\n" +
+	"\x04sudo\x18\x04 \x01(\bR\x04sudo\x12\x14\n" +
+	"\x05shell\x18\x05 \x01(\tR\x05shell\"\xee\x01\n" +
+	"\x0fCloudInitConfig\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12*\n" +
+	"\x05users\x18\x02 \x03(\v2\x14.virsh.CloudInitUserR\x05users\x12.\n" +
+	"\x13ssh_authorized_keys\x18\x03 \x03(\tR\x11sshAuthorizedKeys\x12\x1b\n" +
+	"\tuser_data\x18\x04 \x01(\tR\buserData\x12%\n" +
+	"\x0enetwork_config\x18\x05 \x01(\tR\rnetworkConfig\x12\x1f\n" +
+	"\vvendor_data\x18\x06 \x01(\tR\n" +
+	"vendorData\"6\n" +
 	"\n" +
 	"OkResponse\x12\x0e\n" +
 	"\x02ok\x18\x01 \x01(\bR\x02ok\x12\x18\n" +
@@ -3261,12 +5612,33 @@ const file_virsh_proto_rawDesc = "" +
 	"\x04name\x18\x01 \x01(\tR\x04name\"L\n" +
 	"\x11GetAllVmsResponse\x12\x1b\n" +
 	"\x03vms\x18\x01 \x03(\v2\t.virsh.VmR\x03vms\x12\x1a\n" +
-	"\bwarnings\x18\x02 \x03(\tR\bwarnings\"|\n" +
+	"\bwarnings\x18\x02 \x03(\tR\bwarnings\"\xea\x01\n" +
 	"\x10MigrateVmRequest\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12\x18\n" +
 	"\aslaveIp\x18\x03 \x01(\tR\aslaveIp\x12\x12\n" +
 	"\x04live\x18\x04 \x01(\bR\x04live\x12&\n" +
-	"\x0etimeoutSeconds\x18\x05 \x01(\x05R\x0etimeoutSeconds\"(\n" +
+	"\x0etimeoutSeconds\x18\x05 \x01(\x05R\x0etimeoutSeconds\x12*\n" +
+	"\x11max_bandwidth_mib\x18\x06 \x01(\x04R\x0fmaxBandwidthMib\x12#\n" +
+	"\rauto_converge\x18\a \x01(\bR\fautoConverge\x12\x1b\n" +
+	"\tpost_copy\x18\b \x01(\bR\bpostCopy\"\xad\x03\n" +
+	"\x11MigrationProgress\x12\x14\n" +
+	"\x05phase\x18\x01 \x01(\tR\x05phase\x12\x18\n" +
+	"\apercent\x18\x02 \x01(\x05R\apercent\x12\x1d\n" +
+	"\n" +
+	"data_total\x18\x03 \x01(\x04R\tdataTotal\x12%\n" +
+	"\x0edata_processed\x18\x04 \x01(\x04R\rdataProcessed\x12%\n" +
+	"\x0edata_remaining\x18\x05 \x01(\x04R\rdataRemaining\x12$\n" +
+	"\x0emem_dirty_rate\x18\x06 \x01(\x04R\fmemDirtyRate\x12\x17\n" +
+	"\amem_bps\x18\a \x01(\x04R\x06memBps\x12#\n" +
+	"\rmem_iteration\x18\b \x01(\x04R\fmemIteration\x12\x1f\n" +
+	"\vdowntime_ms\x18\t \x01(\x04R\n" +
+	"downtimeMs\x12&\n" +
+	"\x0ftime_elapsed_ms\x18\n" +
+	" \x01(\x04R\rtimeElapsedMs\x124\n" +
+	"\x16auto_converge_throttle\x18\v \x01(\x05R\x14autoConvergeThrottle\x12\x18\n" +
+	"\amessage\x18\f \x01(\tR\amessage\",\n" +
+	"\x16CancelMigrationRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"(\n" +
 	"\x0eCPUXMLResponse\x12\x16\n" +
 	"\x06cpuXML\x18\x01 \x01(\tR\x06cpuXML\"%\n" +
 	"\rVMXMLResponse\x12\x14\n" +
@@ -3276,7 +5648,11 @@ const file_virsh_proto_rawDesc = "" +
 	"\x06cpuXML\x18\x02 \x01(\tR\x06cpuXML\">\n" +
 	"\x12UpdateVMXmlRequest\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
-	"\x05vmXML\x18\x02 \x01(\tR\x05vmXML\"\xe4\x01\n" +
+	"\x05vmXML\x18\x02 \x01(\tR\x05vmXML\"X\n" +
+	"\x16DefineVMFromXMLRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05vmXML\x18\x02 \x01(\tR\x05vmXML\x12\x14\n" +
+	"\x05start\x18\x03 \x01(\bR\x05start\"\xe4\x01\n" +
 	"\x14ColdMigrationRequest\x12\x17\n" +
 	"\avm_name\x18\x01 \x01(\tR\x06vmName\x12\x16\n" +
 	"\x06memory\x18\x02 \x01(\x05R\x06memory\x12\x15\n" +
@@ -3345,7 +5721,169 @@ const file_virsh_proto_rawDesc = "" +
 	"\x14ExternalDiskResponse\x12\x0e\n" +
 	"\x02ok\x18\x01 \x01(\bR\x02ok\x12\x1d\n" +
 	"\n" +
-	"target_dev\x18\x02 \x01(\tR\ttargetDev\"\xb0\x01\n" +
+	"target_dev\x18\x02 \x01(\tR\ttargetDev\"\xc4\x01\n" +
+	"\x15CreateSnapshotRequest\x12\x17\n" +
+	"\avm_name\x18\x01 \x01(\tR\x06vmName\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x1b\n" +
+	"\tdisk_only\x18\x04 \x01(\bR\bdiskOnly\x12%\n" +
+	"\x0einclude_memory\x18\x05 \x01(\bR\rincludeMemory\x12\x18\n" +
+	"\aquiesce\x18\x06 \x01(\bR\aquiesce\">\n" +
+	"\x0fSnapshotRequest\x12\x17\n" +
+	"\avm_name\x18\x01 \x01(\tR\x06vmName\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"\xec\x01\n" +
+	"\fSnapshotInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x02 \x01(\tR\vdescription\x12\x14\n" +
+	"\x05state\x18\x03 \x01(\tR\x05state\x12#\n" +
+	"\rcreation_time\x18\x04 \x01(\x03R\fcreationTime\x12\x18\n" +
+	"\acurrent\x18\x05 \x01(\bR\acurrent\x12\x16\n" +
+	"\x06parent\x18\x06 \x01(\tR\x06parent\x12\x1a\n" +
+	"\bexternal\x18\a \x01(\bR\bexternal\x12\x1d\n" +
+	"\n" +
+	"has_memory\x18\b \x01(\bR\thasMemory\"J\n" +
+	"\x15ListSnapshotsResponse\x121\n" +
+	"\tsnapshots\x18\x01 \x03(\v2\x13.virsh.SnapshotInfoR\tsnapshots\"\xbb\x01\n" +
+	"\x11BackupDiskRequest\x12\x17\n" +
+	"\avm_name\x18\x01 \x01(\tR\x06vmName\x12\x1f\n" +
+	"\vtarget_path\x18\x02 \x01(\tR\n" +
+	"targetPath\x12\x1e\n" +
+	"\n" +
+	"checkpoint\x18\x03 \x01(\tR\n" +
+	"checkpoint\x12+\n" +
+	"\x11parent_checkpoint\x18\x04 \x01(\tR\x10parentCheckpoint\x12\x1f\n" +
+	"\vparent_path\x18\x05 \x01(\tR\n" +
+	"parentPath\"p\n" +
+	"\x14FlattenBackupRequest\x12\x1f\n" +
+	"\vsource_path\x18\x01 \x01(\tR\n" +
+	"sourcePath\x12\x1b\n" +
+	"\tdest_path\x18\x02 \x01(\tR\bdestPath\x12\x1a\n" +
+	"\bcompress\x18\x03 \x01(\bR\bcompress\"+\n" +
+	"\x15CheckDiskImageRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"x\n" +
+	"\x16CheckDiskImageResponse\x12\x0e\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok\x12 \n" +
+	"\vcorruptions\x18\x02 \x01(\x03R\vcorruptions\x12\x14\n" +
+	"\x05leaks\x18\x03 \x01(\x03R\x05leaks\x12\x16\n" +
+	"\x06output\x18\x04 \x01(\tR\x06output\"\xc1\x01\n" +
+	"\x0fTestBootRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1b\n" +
+	"\tdisk_path\x18\x02 \x01(\tR\bdiskPath\x12!\n" +
+	"\foverlay_path\x18\x03 \x01(\tR\voverlayPath\x12\x1b\n" +
+	"\tmemory_mb\x18\x04 \x01(\x05R\bmemoryMb\x12\x14\n" +
+	"\x05vcpus\x18\x05 \x01(\x05R\x05vcpus\x12'\n" +
+	"\x0ftimeout_seconds\x18\x06 \x01(\x05R\x0etimeoutSeconds\"a\n" +
+	"\x10TestBootResponse\x12\x19\n" +
+	"\bagent_ok\x18\x01 \x01(\bR\aagentOk\x12\x18\n" +
+	"\aseconds\x18\x02 \x01(\x05R\aseconds\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"W\n" +
+	"\x0eTCPPortRequest\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12\x12\n" +
+	"\x04port\x18\x02 \x01(\x05R\x04port\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x03 \x01(\x05R\ttimeoutMs\"\xd2\x01\n" +
+	"\x10BlockCopyRequest\x12\x17\n" +
+	"\avm_name\x18\x01 \x01(\tR\x06vmName\x12\x1f\n" +
+	"\vsource_path\x18\x02 \x01(\tR\n" +
+	"sourcePath\x12\x1b\n" +
+	"\tdest_path\x18\x03 \x01(\tR\bdestPath\x12\x16\n" +
+	"\x06format\x18\x04 \x01(\tR\x06format\x12*\n" +
+	"\x11max_bandwidth_mib\x18\x05 \x01(\x04R\x0fmaxBandwidthMib\x12#\n" +
+	"\rremove_source\x18\x06 \x01(\bR\fremoveSource\"\x81\x01\n" +
+	"\x11BlockCopyProgress\x12\x14\n" +
+	"\x05phase\x18\x01 \x01(\tR\x05phase\x12\x18\n" +
+	"\apercent\x18\x02 \x01(\x01R\apercent\x12\x10\n" +
+	"\x03cur\x18\x03 \x01(\x04R\x03cur\x12\x10\n" +
+	"\x03end\x18\x04 \x01(\x04R\x03end\x12\x18\n" +
+	"\amessage\x18\x05 \x01(\tR\amessage\"\x80\x01\n" +
+	"\x17FileRestoreMountRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x1d\n" +
+	"\n" +
+	"image_path\x18\x02 \x01(\tR\timagePath\x12'\n" +
+	"\x0ftimeout_seconds\x18\x03 \x01(\x05R\x0etimeoutSeconds\"\xb6\x01\n" +
+	"\x14FileRestorePartition\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06device\x18\x02 \x01(\tR\x06device\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06fstype\x18\x04 \x01(\tR\x06fstype\x12\x14\n" +
+	"\x05label\x18\x05 \x01(\tR\x05label\x12\x12\n" +
+	"\x04uuid\x18\x06 \x01(\tR\x04uuid\x12\x1c\n" +
+	"\tmountable\x18\a \x01(\bR\tmountable\"W\n" +
+	"\x18FileRestoreMountResponse\x12;\n" +
+	"\n" +
+	"partitions\x18\x01 \x03(\v2\x1b.virsh.FileRestorePartitionR\n" +
+	"partitions\"k\n" +
+	"\x18FileRestoreBrowseRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x1c\n" +
+	"\tpartition\x18\x02 \x01(\tR\tpartition\x12\x12\n" +
+	"\x04path\x18\x03 \x01(\tR\x04path\"\x9c\x01\n" +
+	"\x10FileRestoreEntry\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03dir\x18\x02 \x01(\bR\x03dir\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x19\n" +
+	"\bmod_time\x18\x04 \x01(\x03R\amodTime\x12\x12\n" +
+	"\x04mode\x18\x05 \x01(\tR\x04mode\x12\x1f\n" +
+	"\vlink_target\x18\x06 \x01(\tR\n" +
+	"linkTarget\"b\n" +
+	"\x19FileRestoreBrowseResponse\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x121\n" +
+	"\aentries\x18\x02 \x03(\v2\x17.virsh.FileRestoreEntryR\aentries\"}\n" +
+	"\x16FileRestoreReadRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x1c\n" +
+	"\tpartition\x18\x02 \x01(\tR\tpartition\x12\x14\n" +
+	"\x05paths\x18\x03 \x03(\tR\x05paths\x12\x10\n" +
+	"\x03tar\x18\x04 \x01(\bR\x03tar\"\x1f\n" +
+	"\tFileChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"6\n" +
+	"\fScratchChunk\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\"6\n" +
+	"\fScratchImage\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\"\\\n" +
+	"\x12FileRestoreSession\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12'\n" +
+	"\x0ftimeout_seconds\x18\x02 \x01(\x05R\x0etimeoutSeconds\"\x91\x02\n" +
+	"\vVmDiskStats\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x1b\n" +
+	"\tread_iops\x18\x03 \x01(\x01R\breadIops\x12\x1d\n" +
+	"\n" +
+	"write_iops\x18\x04 \x01(\x01R\twriteIops\x12+\n" +
+	"\x12read_bytes_per_sec\x18\x05 \x01(\x01R\x0freadBytesPerSec\x12-\n" +
+	"\x13write_bytes_per_sec\x18\x06 \x01(\x01R\x10writeBytesPerSec\x12\x1d\n" +
+	"\n" +
+	"read_bytes\x18\a \x01(\x04R\treadBytes\x12\x1f\n" +
+	"\vwrite_bytes\x18\b \x01(\x04R\n" +
+	"writeBytes\"\x9e\x02\n" +
+	"\x10VmInterfaceStats\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12\x10\n" +
+	"\x03mac\x18\x02 \x01(\tR\x03mac\x12'\n" +
+	"\x10rx_bytes_per_sec\x18\x03 \x01(\x01R\rrxBytesPerSec\x12'\n" +
+	"\x10tx_bytes_per_sec\x18\x04 \x01(\x01R\rtxBytesPerSec\x12+\n" +
+	"\x12rx_packets_per_sec\x18\x05 \x01(\x01R\x0frxPacketsPerSec\x12+\n" +
+	"\x12tx_packets_per_sec\x18\x06 \x01(\x01R\x0ftxPacketsPerSec\x12\x19\n" +
+	"\brx_bytes\x18\a \x01(\x04R\arxBytes\x12\x19\n" +
+	"\btx_bytes\x18\b \x01(\x04R\atxBytes\"\x9d\x02\n" +
+	"\aVmStats\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1f\n" +
+	"\vcpu_percent\x18\x02 \x01(\x01R\n" +
+	"cpuPercent\x12\x14\n" +
+	"\x05vcpus\x18\x03 \x01(\x05R\x05vcpus\x12\"\n" +
+	"\rmem_total_kib\x18\x04 \x01(\x04R\vmemTotalKib\x12 \n" +
+	"\fmem_used_kib\x18\x05 \x01(\x04R\n" +
+	"memUsedKib\x12\x1e\n" +
+	"\vmem_rss_kib\x18\x06 \x01(\x04R\tmemRssKib\x12(\n" +
+	"\x05disks\x18\a \x03(\v2\x12.virsh.VmDiskStatsR\x05disks\x127\n" +
+	"\n" +
+	"interfaces\x18\b \x03(\v2\x17.virsh.VmInterfaceStatsR\n" +
+	"interfaces\"3\n" +
+	"\x0fVmStatsResponse\x12 \n" +
+	"\x03vms\x18\x01 \x03(\v2\x0e.virsh.VmStatsR\x03vms\"\xb0\x01\n" +
 	"\x11CPUPinningRequest\x12\x17\n" +
 	"\avm_name\x18\x01 \x01(\tR\x06vmName\x12\x1f\n" +
 	"\vrange_start\x18\x02 \x01(\x05R\n" +
@@ -3458,7 +5996,7 @@ const file_virsh_proto_rawDesc = "" +
 	"\aSHUTOFF\x10\x05\x12\v\n" +
 	"\aCRASHED\x10\x06\x12\x0f\n" +
 	"\vPMSUSPENDED\x10\a\x12\v\n" +
-	"\aNOSTATE\x10\b2\xae\x1b\n" +
+	"\aNOSTATE\x10\b2\xf5&\n" +
 	"\x11SlaveVirshService\x12=\n" +
 	"\x0eGetCpuFeatures\x12\f.virsh.Empty\x1a\x1d.virsh.GetCpuFeaturesResponse\x120\n" +
 	"\tGetCPUXML\x12\f.virsh.Empty\x1a\x15.virsh.CPUXMLResponse\x12?\n" +
@@ -3466,8 +6004,9 @@ const file_virsh_proto_rawDesc = "" +
 	"\x0eUpdateVMCPUXml\x12\x1c.virsh.UpdateVMCPUXmlRequest\x1a\x11.virsh.OkResponse\x12;\n" +
 	"\bGetVMXml\x12\x19.virsh.GetVmByNameRequest\x1a\x14.virsh.VMXMLResponse\x12;\n" +
 	"\vUpdateVMXml\x12\x19.virsh.UpdateVMXmlRequest\x1a\x11.virsh.OkResponse\x125\n" +
-	"\bCreateVm\x12\x16.virsh.CreateVmRequest\x1a\x11.virsh.OkResponse\x127\n" +
-	"\tMigrateVM\x12\x17.virsh.MigrateVmRequest\x1a\x11.virsh.OkResponse\x12*\n" +
+	"\bCreateVm\x12\x16.virsh.CreateVmRequest\x1a\x11.virsh.OkResponse\x12@\n" +
+	"\tMigrateVM\x12\x17.virsh.MigrateVmRequest\x1a\x18.virsh.MigrationProgress0\x01\x12C\n" +
+	"\x0fCancelMigration\x12\x1d.virsh.CancelMigrationRequest\x1a\x11.virsh.OkResponse\x12*\n" +
 	"\n" +
 	"ShutdownVM\x12\t.virsh.Vm\x1a\x11.virsh.OkResponse\x12/\n" +
 	"\x0fForceShutdownVM\x12\t.virsh.Vm\x1a\x11.virsh.OkResponse\x12'\n" +
@@ -3477,9 +6016,12 @@ const file_virsh_proto_rawDesc = "" +
 	"\aPauseVM\x12\t.virsh.Vm\x1a\x11.virsh.OkResponse\x12(\n" +
 	"\bResumeVM\x12\t.virsh.Vm\x1a\x11.virsh.OkResponse\x12*\n" +
 	"\n" +
-	"UndefineVM\x12\t.virsh.Vm\x1a\x11.virsh.OkResponse\x123\n" +
+	"UndefineVM\x12\t.virsh.Vm\x1a\x11.virsh.OkResponse\x12/\n" +
+	"\x0fUndefineStaleVM\x12\t.virsh.Vm\x1a\x11.virsh.OkResponse\x123\n" +
 	"\tGetAllVms\x12\f.virsh.Empty\x1a\x18.virsh.GetAllVmsResponse\x123\n" +
-	"\vGetVmByName\x12\x19.virsh.GetVmByNameRequest\x1a\t.virsh.Vm\x12/\n" +
+	"\vGetVmByName\x12\x19.virsh.GetVmByNameRequest\x1a\t.virsh.Vm\x122\n" +
+	"\n" +
+	"GetVmStats\x12\f.virsh.Empty\x1a\x16.virsh.VmStatsResponse\x12/\n" +
 	"\x0fRemoveIsoFromVm\x12\t.virsh.Vm\x1a\x11.virsh.OkResponse\x126\n" +
 	"\rChangeNetwork\x12\x17.virsh.ChangeNetworkReq\x1a\f.virsh.Empty\x12=\n" +
 	"\rAddNoVNCVideo\x12\x19.virsh.GetVmByNameRequest\x1a\x11.virsh.OkResponse\x12@\n" +
@@ -3498,10 +6040,30 @@ const file_virsh_proto_rawDesc = "" +
 	"\x12AttachExternalDisk\x12\x1a.virsh.ExternalDiskRequest\x1a\x1b.virsh.ExternalDiskResponse\x12M\n" +
 	"\x12DetachExternalDisk\x12\x1a.virsh.ExternalDiskRequest\x1a\x1b.virsh.ExternalDiskResponse\x12/\n" +
 	"\x0fEditVmResources\x12\t.virsh.Vm\x1a\x11.virsh.OkResponse\x12?\n" +
-	"\rColdMigrateVm\x12\x1b.virsh.ColdMigrationRequest\x1a\x11.virsh.OkResponse\x12*\n" +
+	"\rColdMigrateVm\x12\x1b.virsh.ColdMigrationRequest\x1a\x11.virsh.OkResponse\x12C\n" +
+	"\x0fDefineVMFromXML\x12\x1d.virsh.DefineVMFromXMLRequest\x1a\x11.virsh.OkResponse\x12*\n" +
 	"\n" +
 	"FreezeDisk\x12\t.virsh.Vm\x1a\x11.virsh.OkResponse\x12,\n" +
-	"\fUnFreezeDisk\x12\t.virsh.Vm\x1a\x11.virsh.OkResponse\x12:\n" +
+	"\fUnFreezeDisk\x12\t.virsh.Vm\x1a\x11.virsh.OkResponse\x12>\n" +
+	"\x0eGuestAgentPing\x12\x19.virsh.GetVmByNameRequest\x1a\x11.virsh.OkResponse\x128\n" +
+	"\fCheckTCPPort\x12\x15.virsh.TCPPortRequest\x1a\x11.virsh.OkResponse\x12C\n" +
+	"\x0eCreateSnapshot\x12\x1c.virsh.CreateSnapshotRequest\x1a\x13.virsh.SnapshotInfo\x12H\n" +
+	"\rListSnapshots\x12\x19.virsh.GetVmByNameRequest\x1a\x1c.virsh.ListSnapshotsResponse\x12;\n" +
+	"\x0eRevertSnapshot\x12\x16.virsh.SnapshotRequest\x1a\x11.virsh.OkResponse\x12;\n" +
+	"\x0eDeleteSnapshot\x12\x16.virsh.SnapshotRequest\x1a\x11.virsh.OkResponse\x129\n" +
+	"\n" +
+	"BackupDisk\x12\x18.virsh.BackupDiskRequest\x1a\x11.virsh.OkResponse\x12D\n" +
+	"\x12FlattenBackupChain\x12\x1b.virsh.FlattenBackupRequest\x1a\x11.virsh.OkResponse\x12M\n" +
+	"\x0eCheckDiskImage\x12\x1c.virsh.CheckDiskImageRequest\x1a\x1d.virsh.CheckDiskImageResponse\x12A\n" +
+	"\x0eTestBootBackup\x12\x16.virsh.TestBootRequest\x1a\x17.virsh.TestBootResponse\x12?\n" +
+	"\x11WriteScratchImage\x12\x13.virsh.ScratchChunk\x1a\x13.virsh.ScratchImage(\x01\x12<\n" +
+	"\x12RemoveScratchImage\x12\x13.virsh.ScratchImage\x1a\x11.virsh.OkResponse\x12S\n" +
+	"\x10MountBackupImage\x12\x1e.virsh.FileRestoreMountRequest\x1a\x1f.virsh.FileRestoreMountResponse\x12V\n" +
+	"\x11BrowseBackupImage\x12\x1f.virsh.FileRestoreBrowseRequest\x1a .virsh.FileRestoreBrowseResponse\x12I\n" +
+	"\x14ReadBackupImageFiles\x12\x1d.virsh.FileRestoreReadRequest\x1a\x10.virsh.FileChunk0\x01\x12B\n" +
+	"\x12UnmountBackupImage\x12\x19.virsh.FileRestoreSession\x1a\x11.virsh.OkResponse\x126\n" +
+	"\x13CleanupFileRestores\x12\f.virsh.Empty\x1a\x11.virsh.OkResponse\x12D\n" +
+	"\rBlockCopyDisk\x12\x17.virsh.BlockCopyRequest\x1a\x18.virsh.BlockCopyProgress0\x01\x12:\n" +
 	"\x10ChangeVmPassword\x12\x18.virsh.ChangeVncPassword\x1a\f.virsh.Empty\x127\n" +
 	"\tAddSSHKey\x12\x17.virsh.AddSSHKeyRequest\x1a\x11.virsh.OkResponse\x12>\n" +
 	"\x0fApplyCPUPinning\x12\x18.virsh.CPUPinningRequest\x1a\x11.virsh.OkResponse\x12@\n" +
@@ -3532,183 +6094,268 @@ func file_virsh_proto_rawDescGZIP() []byte {
 }
 
 var file_virsh_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_virsh_proto_msgTypes = make([]protoimpl.MessageInfo, 49)
+var file_virsh_proto_msgTypes = make([]protoimpl.MessageInfo, 82)
 var file_virsh_proto_goTypes = []any{
 	(VmState)(0),                             // 0: virsh.VmState
 	(*Empty)(nil),                            // 1: virsh.Empty
 	(*GetCpuFeaturesResponse)(nil),           // 2: virsh.GetCpuFeaturesResponse
 	(*CreateVmRequest)(nil),                  // 3: virsh.CreateVmRequest
-	(*OkResponse)(nil),                       // 4: virsh.OkResponse
-	(*Vm)(nil),                               // 5: virsh.Vm
-	(*GetVmByNameRequest)(nil),               // 6: virsh.GetVmByNameRequest
-	(*GetAllVmsResponse)(nil),                // 7: virsh.GetAllVmsResponse
-	(*MigrateVmRequest)(nil),                 // 8: virsh.MigrateVmRequest
-	(*CPUXMLResponse)(nil),                   // 9: virsh.CPUXMLResponse
-	(*VMXMLResponse)(nil),                    // 10: virsh.VMXMLResponse
-	(*UpdateVMCPUXmlRequest)(nil),            // 11: virsh.UpdateVMCPUXmlRequest
-	(*UpdateVMXmlRequest)(nil),               // 12: virsh.UpdateVMXmlRequest
-	(*ColdMigrationRequest)(nil),             // 13: virsh.ColdMigrationRequest
-	(*ChangeNetworkReq)(nil),                 // 14: virsh.ChangeNetworkReq
-	(*ChangeVncPassword)(nil),                // 15: virsh.ChangeVncPassword
-	(*AddSSHKeyRequest)(nil),                 // 16: virsh.AddSSHKeyRequest
-	(*GetNoVNCVideoResponse)(nil),            // 17: virsh.GetNoVNCVideoResponse
-	(*SetMemoryBallooningRequest)(nil),       // 18: virsh.SetMemoryBallooningRequest
-	(*GetMemoryBallooningResponse)(nil),      // 19: virsh.GetMemoryBallooningResponse
-	(*SetHugePagesRequest)(nil),              // 20: virsh.SetHugePagesRequest
-	(*GetHugePagesResponse)(nil),             // 21: virsh.GetHugePagesResponse
-	(*MachineTypesResponse)(nil),             // 22: virsh.MachineTypesResponse
-	(*SetMachineTypeRequest)(nil),            // 23: virsh.SetMachineTypeRequest
-	(*MachineTypeResponse)(nil),              // 24: virsh.MachineTypeResponse
-	(*SetKVMHiddenRequest)(nil),              // 25: virsh.SetKVMHiddenRequest
-	(*KVMHiddenResponse)(nil),                // 26: virsh.KVMHiddenResponse
-	(*SetHyperVRequest)(nil),                 // 27: virsh.SetHyperVRequest
-	(*HyperVResponse)(nil),                   // 28: virsh.HyperVResponse
-	(*ExternalDiskRequest)(nil),              // 29: virsh.ExternalDiskRequest
-	(*ExternalDiskResponse)(nil),             // 30: virsh.ExternalDiskResponse
-	(*CPUPinningRequest)(nil),                // 31: virsh.CPUPinningRequest
-	(*CPUPinningInfo)(nil),                   // 32: virsh.CPUPinningInfo
-	(*CPUPinningResponse)(nil),               // 33: virsh.CPUPinningResponse
-	(*CPUCoreInfo)(nil),                      // 34: virsh.CPUCoreInfo
-	(*CPUSocketInfo)(nil),                    // 35: virsh.CPUSocketInfo
-	(*CPUTopologyResponse)(nil),              // 36: virsh.CPUTopologyResponse
-	(*TunedAdmProfileInfo)(nil),              // 37: virsh.TunedAdmProfileInfo
-	(*TunedAdmProfilesResponse)(nil),         // 38: virsh.TunedAdmProfilesResponse
-	(*SetTunedAdmProfileRequest)(nil),        // 39: virsh.SetTunedAdmProfileRequest
-	(*SetTunedAdmProfileResponse)(nil),       // 40: virsh.SetTunedAdmProfileResponse
-	(*IrqBalanceStateResponse)(nil),          // 41: virsh.IrqBalanceStateResponse
-	(*SetIrqBalanceStateRequest)(nil),        // 42: virsh.SetIrqBalanceStateRequest
-	(*SetIrqBalanceStateResponse)(nil),       // 43: virsh.SetIrqBalanceStateResponse
-	(*HostCoreIsolationSocketSelection)(nil), // 44: virsh.HostCoreIsolationSocketSelection
-	(*SetHostCoreIsolationRequest)(nil),      // 45: virsh.SetHostCoreIsolationRequest
-	(*HostCoreIsolationSocketState)(nil),     // 46: virsh.HostCoreIsolationSocketState
-	(*HostCoreIsolationStateResponse)(nil),   // 47: virsh.HostCoreIsolationStateResponse
-	(*SetHostHugePagesRequest)(nil),          // 48: virsh.SetHostHugePagesRequest
-	(*HostHugePagesStateResponse)(nil),       // 49: virsh.HostHugePagesStateResponse
+	(*CloudInitUser)(nil),                    // 4: virsh.CloudInitUser
+	(*CloudInitConfig)(nil),                  // 5: virsh.CloudInitConfig
+	(*OkResponse)(nil),                       // 6: virsh.OkResponse
+	(*Vm)(nil),                               // 7: virsh.Vm
+	(*GetVmByNameRequest)(nil),               // 8: virsh.GetVmByNameRequest
+	(*GetAllVmsResponse)(nil),                // 9: virsh.GetAllVmsResponse
+	(*MigrateVmRequest)(nil),                 // 10: virsh.MigrateVmRequest
+	(*MigrationProgress)(nil),                // 11: virsh.MigrationProgress
+	(*CancelMigrationRequest)(nil),           // 12: virsh.CancelMigrationRequest
+	(*CPUXMLResponse)(nil),                   // 13: virsh.CPUXMLResponse
+	(*VMXMLResponse)(nil),                    // 14: virsh.VMXMLResponse
+	(*UpdateVMCPUXmlRequest)(nil),            // 15: virsh.UpdateVMCPUXmlRequest
+	(*UpdateVMXmlRequest)(nil),               // 16: virsh.UpdateVMXmlRequest
+	(*DefineVMFromXMLRequest)(nil),           // 17: virsh.DefineVMFromXMLRequest
+	(*ColdMigrationRequest)(nil),             // 18: virsh.ColdMigrationRequest
+	(*ChangeNetworkReq)(nil),                 // 19: virsh.ChangeNetworkReq
+	(*ChangeVncPassword)(nil),                // 20: virsh.ChangeVncPassword
+	(*AddSSHKeyRequest)(nil),                 // 21: virsh.AddSSHKeyRequest
+	(*GetNoVNCVideoResponse)(nil),            // 22: virsh.GetNoVNCVideoResponse
+	(*SetMemoryBallooningRequest)(nil),       // 23: virsh.SetMemoryBallooningRequest
+	(*GetMemoryBallooningResponse)(nil),      // 24: virsh.GetMemoryBallooningResponse
+	(*SetHugePagesRequest)(nil),              // 25: virsh.SetHugePagesRequest
+	(*GetHugePagesResponse)(nil),             // 26: virsh.GetHugePagesResponse
+	(*MachineTypesResponse)(nil),             // 27: virsh.MachineTypesResponse
+	(*SetMachineTypeRequest)(nil),            // 28: virsh.SetMachineTypeRequest
+	(*MachineTypeResponse)(nil),              // 29: virsh.MachineTypeResponse
+	(*SetKVMHiddenRequest)(nil),              // 30: virsh.SetKVMHiddenRequest
+	(*KVMHiddenResponse)(nil),                // 31: virsh.KVMHiddenResponse
+	(*SetHyperVRequest)(nil),                 // 32: virsh.SetHyperVRequest
+	(*HyperVResponse)(nil),                   // 33: virsh.HyperVResponse
+	(*ExternalDiskRequest)(nil),              // 34: virsh.ExternalDiskRequest
+	(*ExternalDiskResponse)(nil),             // 35: virsh.ExternalDiskResponse
+	(*CreateSnapshotRequest)(nil),            // 36: virsh.CreateSnapshotRequest
+	(*SnapshotRequest)(nil),                  // 37: virsh.SnapshotRequest
+	(*SnapshotInfo)(nil),                     // 38: virsh.SnapshotInfo
+	(*ListSnapshotsResponse)(nil),            // 39: virsh.ListSnapshotsResponse
+	(*BackupDiskRequest)(nil),                // 40: virsh.BackupDiskRequest
+	(*FlattenBackupRequest)(nil),             // 41: virsh.FlattenBackupRequest
+	(*CheckDiskImageRequest)(nil),            // 42: virsh.CheckDiskImageRequest
+	(*CheckDiskImageResponse)(nil),           // 43: virsh.CheckDiskImageResponse
+	(*TestBootRequest)(nil),                  // 44: virsh.TestBootRequest
+	(*TestBootResponse)(nil),                 // 45: virsh.TestBootResponse
+	(*TCPPortRequest)(nil),                   // 46: virsh.TCPPortRequest
+	(*BlockCopyRequest)(nil),                 // 47: virsh.BlockCopyRequest
+	(*BlockCopyProgress)(nil),                // 48: virsh.BlockCopyProgress
+	(*FileRestoreMountRequest)(nil),          // 49: virsh.FileRestoreMountRequest
+	(*FileRestorePartition)(nil),             // 50: virsh.FileRestorePartition
+	(*FileRestoreMountResponse)(nil),         // 51: virsh.FileRestoreMountResponse
+	(*FileRestoreBrowseRequest)(nil),         // 52: virsh.FileRestoreBrowseRequest
+	(*FileRestoreEntry)(nil),                 // 53: virsh.FileRestoreEntry
+	(*FileRestoreBrowseResponse)(nil),        // 54: virsh.FileRestoreBrowseResponse
+	(*FileRestoreReadRequest)(nil),           // 55: virsh.FileRestoreReadRequest
+	(*FileChunk)(nil),                        // 56: virsh.FileChunk
+	(*ScratchChunk)(nil),                     // 57: virsh.ScratchChunk
+	(*ScratchImage)(nil),                     // 58: virsh.ScratchImage
+	(*FileRestoreSession)(nil),               // 59: virsh.FileRestoreSession
+	(*VmDiskStats)(nil),                      // 60: virsh.VmDiskStats
+	(*VmInterfaceStats)(nil),                 // 61: virsh.VmInterfaceStats
+	(*VmStats)(nil),                          // 62: virsh.VmStats
+	(*VmStatsResponse)(nil),                  // 63: virsh.VmStatsResponse
+	(*CPUPinningRequest)(nil),                // 64: virsh.CPUPinningRequest
+	(*CPUPinningInfo)(nil),                   // 65: virsh.CPUPinningInfo
+	(*CPUPinningResponse)(nil),               // 66: virsh.CPUPinningResponse
+	(*CPUCoreInfo)(nil),                      // 67: virsh.CPUCoreInfo
+	(*CPUSocketInfo)(nil),                    // 68: virsh.CPUSocketInfo
+	(*CPUTopologyResponse)(nil),              // 69: virsh.CPUTopologyResponse
+	(*TunedAdmProfileInfo)(nil),              // 70: virsh.TunedAdmProfileInfo
+	(*TunedAdmProfilesResponse)(nil),         // 71: virsh.TunedAdmProfilesResponse
+	(*SetTunedAdmProfileRequest)(nil),        // 72: virsh.SetTunedAdmProfileRequest
+	(*SetTunedAdmProfileResponse)(nil),       // 73: virsh.SetTunedAdmProfileResponse
+	(*IrqBalanceStateResponse)(nil),          // 74: virsh.IrqBalanceStateResponse
+	(*SetIrqBalanceStateRequest)(nil),        // 75: virsh.SetIrqBalanceStateRequest
+	(*SetIrqBalanceStateResponse)(nil),       // 76: virsh.SetIrqBalanceStateResponse
+	(*HostCoreIsolationSocketSelection)(nil), // 77: virsh.HostCoreIsolationSocketSelection
+	(*SetHostCoreIsolationRequest)(nil),      // 78: virsh.SetHostCoreIsolationRequest
+	(*HostCoreIsolationSocketState)(nil),     // 79: virsh.HostCoreIsolationSocketState
+	(*HostCoreIsolationStateResponse)(nil),   // 80: virsh.HostCoreIsolationStateResponse
+	(*SetHostHugePagesRequest)(nil),          // 81: virsh.SetHostHugePagesRequest
+	(*HostHugePagesStateResponse)(nil),       // 82: virsh.HostHugePagesStateResponse
 }
 var file_virsh_proto_depIdxs = []int32{
-	0,  // 0: virsh.Vm.state:type_name -> virsh.VmState
-	5,  // 1: virsh.GetAllVmsResponse.vms:type_name -> virsh.Vm
-	32, // 2: virsh.CPUPinningResponse.pins:type_name -> virsh.CPUPinningInfo
-	34, // 3: virsh.CPUSocketInfo.cores:type_name -> virsh.CPUCoreInfo
-	35, // 4: virsh.CPUTopologyResponse.sockets:type_name -> virsh.CPUSocketInfo
-	37, // 5: virsh.TunedAdmProfilesResponse.profiles:type_name -> virsh.TunedAdmProfileInfo
-	44, // 6: virsh.SetHostCoreIsolationRequest.sockets:type_name -> virsh.HostCoreIsolationSocketSelection
-	46, // 7: virsh.HostCoreIsolationStateResponse.sockets:type_name -> virsh.HostCoreIsolationSocketState
-	1,  // 8: virsh.SlaveVirshService.GetCpuFeatures:input_type -> virsh.Empty
-	1,  // 9: virsh.SlaveVirshService.GetCPUXML:input_type -> virsh.Empty
-	6,  // 10: virsh.SlaveVirshService.GetVMCPUXml:input_type -> virsh.GetVmByNameRequest
-	11, // 11: virsh.SlaveVirshService.UpdateVMCPUXml:input_type -> virsh.UpdateVMCPUXmlRequest
-	6,  // 12: virsh.SlaveVirshService.GetVMXml:input_type -> virsh.GetVmByNameRequest
-	12, // 13: virsh.SlaveVirshService.UpdateVMXml:input_type -> virsh.UpdateVMXmlRequest
-	3,  // 14: virsh.SlaveVirshService.CreateVm:input_type -> virsh.CreateVmRequest
-	8,  // 15: virsh.SlaveVirshService.MigrateVM:input_type -> virsh.MigrateVmRequest
-	5,  // 16: virsh.SlaveVirshService.ShutdownVM:input_type -> virsh.Vm
-	5,  // 17: virsh.SlaveVirshService.ForceShutdownVM:input_type -> virsh.Vm
-	5,  // 18: virsh.SlaveVirshService.StartVM:input_type -> virsh.Vm
-	5,  // 19: virsh.SlaveVirshService.RemoveVM:input_type -> virsh.Vm
-	5,  // 20: virsh.SlaveVirshService.RestartVM:input_type -> virsh.Vm
-	5,  // 21: virsh.SlaveVirshService.PauseVM:input_type -> virsh.Vm
-	5,  // 22: virsh.SlaveVirshService.ResumeVM:input_type -> virsh.Vm
-	5,  // 23: virsh.SlaveVirshService.UndefineVM:input_type -> virsh.Vm
-	1,  // 24: virsh.SlaveVirshService.GetAllVms:input_type -> virsh.Empty
-	6,  // 25: virsh.SlaveVirshService.GetVmByName:input_type -> virsh.GetVmByNameRequest
-	5,  // 26: virsh.SlaveVirshService.RemoveIsoFromVm:input_type -> virsh.Vm
-	14, // 27: virsh.SlaveVirshService.ChangeNetwork:input_type -> virsh.ChangeNetworkReq
-	6,  // 28: virsh.SlaveVirshService.AddNoVNCVideo:input_type -> virsh.GetVmByNameRequest
-	6,  // 29: virsh.SlaveVirshService.RemoveNoVNCVideo:input_type -> virsh.GetVmByNameRequest
-	6,  // 30: virsh.SlaveVirshService.GetNoVNCVideo:input_type -> virsh.GetVmByNameRequest
-	6,  // 31: virsh.SlaveVirshService.GetMemoryBallooning:input_type -> virsh.GetVmByNameRequest
-	18, // 32: virsh.SlaveVirshService.SetMemoryBallooning:input_type -> virsh.SetMemoryBallooningRequest
-	6,  // 33: virsh.SlaveVirshService.GetHugePages:input_type -> virsh.GetVmByNameRequest
-	20, // 34: virsh.SlaveVirshService.SetHugePages:input_type -> virsh.SetHugePagesRequest
-	1,  // 35: virsh.SlaveVirshService.ListMachineTypes:input_type -> virsh.Empty
-	23, // 36: virsh.SlaveVirshService.SetMachineType:input_type -> virsh.SetMachineTypeRequest
-	6,  // 37: virsh.SlaveVirshService.GetKVMHidden:input_type -> virsh.GetVmByNameRequest
-	25, // 38: virsh.SlaveVirshService.SetKVMHidden:input_type -> virsh.SetKVMHiddenRequest
-	6,  // 39: virsh.SlaveVirshService.GetHyperV:input_type -> virsh.GetVmByNameRequest
-	27, // 40: virsh.SlaveVirshService.SetHyperV:input_type -> virsh.SetHyperVRequest
-	29, // 41: virsh.SlaveVirshService.AttachExternalDisk:input_type -> virsh.ExternalDiskRequest
-	29, // 42: virsh.SlaveVirshService.DetachExternalDisk:input_type -> virsh.ExternalDiskRequest
-	5,  // 43: virsh.SlaveVirshService.EditVmResources:input_type -> virsh.Vm
-	13, // 44: virsh.SlaveVirshService.ColdMigrateVm:input_type -> virsh.ColdMigrationRequest
-	5,  // 45: virsh.SlaveVirshService.FreezeDisk:input_type -> virsh.Vm
-	5,  // 46: virsh.SlaveVirshService.UnFreezeDisk:input_type -> virsh.Vm
-	15, // 47: virsh.SlaveVirshService.ChangeVmPassword:input_type -> virsh.ChangeVncPassword
-	16, // 48: virsh.SlaveVirshService.AddSSHKey:input_type -> virsh.AddSSHKeyRequest
-	31, // 49: virsh.SlaveVirshService.ApplyCPUPinning:input_type -> virsh.CPUPinningRequest
-	6,  // 50: virsh.SlaveVirshService.RemoveCPUPinning:input_type -> virsh.GetVmByNameRequest
-	6,  // 51: virsh.SlaveVirshService.GetCPUPinning:input_type -> virsh.GetVmByNameRequest
-	1,  // 52: virsh.SlaveVirshService.GetCPUTopology:input_type -> virsh.Empty
-	1,  // 53: virsh.SlaveVirshService.GetTunedAdmProfiles:input_type -> virsh.Empty
-	39, // 54: virsh.SlaveVirshService.SetTunedAdmProfile:input_type -> virsh.SetTunedAdmProfileRequest
-	1,  // 55: virsh.SlaveVirshService.GetIrqBalanceState:input_type -> virsh.Empty
-	42, // 56: virsh.SlaveVirshService.SetIrqBalanceState:input_type -> virsh.SetIrqBalanceStateRequest
-	1,  // 57: virsh.SlaveVirshService.GetHostCoreIsolation:input_type -> virsh.Empty
-	45, // 58: virsh.SlaveVirshService.SetHostCoreIsolation:input_type -> virsh.SetHostCoreIsolationRequest
-	1,  // 59: virsh.SlaveVirshService.RemoveHostCoreIsolation:input_type -> virsh.Empty
-	1,  // 60: virsh.SlaveVirshService.GetHostHugePages:input_type -> virsh.Empty
-	48, // 61: virsh.SlaveVirshService.SetHostHugePages:input_type -> virsh.SetHostHugePagesRequest
-	1,  // 62: virsh.SlaveVirshService.RemoveHostHugePages:input_type -> virsh.Empty
-	2,  // 63: virsh.SlaveVirshService.GetCpuFeatures:output_type -> virsh.GetCpuFeaturesResponse
-	9,  // 64: virsh.SlaveVirshService.GetCPUXML:output_type -> virsh.CPUXMLResponse
-	9,  // 65: virsh.SlaveVirshService.GetVMCPUXml:output_type -> virsh.CPUXMLResponse
-	4,  // 66: virsh.SlaveVirshService.UpdateVMCPUXml:output_type -> virsh.OkResponse
-	10, // 67: virsh.SlaveVirshService.GetVMXml:output_type -> virsh.VMXMLResponse
-	4,  // 68: virsh.SlaveVirshService.UpdateVMXml:output_type -> virsh.OkResponse
-	4,  // 69: virsh.SlaveVirshService.CreateVm:output_type -> virsh.OkResponse
-	4,  // 70: virsh.SlaveVirshService.MigrateVM:output_type -> virsh.OkResponse
-	4,  // 71: virsh.SlaveVirshService.ShutdownVM:output_type -> virsh.OkResponse
-	4,  // 72: virsh.SlaveVirshService.ForceShutdownVM:output_type -> virsh.OkResponse
-	4,  // 73: virsh.SlaveVirshService.StartVM:output_type -> virsh.OkResponse
-	4,  // 74: virsh.SlaveVirshService.RemoveVM:output_type -> virsh.OkResponse
-	4,  // 75: virsh.SlaveVirshService.RestartVM:output_type -> virsh.OkResponse
-	4,  // 76: virsh.SlaveVirshService.PauseVM:output_type -> virsh.OkResponse
-	4,  // 77: virsh.SlaveVirshService.ResumeVM:output_type -> virsh.OkResponse
-	4,  // 78: virsh.SlaveVirshService.UndefineVM:output_type -> virsh.OkResponse
-	7,  // 79: virsh.SlaveVirshService.GetAllVms:output_type -> virsh.GetAllVmsResponse
-	5,  // 80: virsh.SlaveVirshService.GetVmByName:output_type -> virsh.Vm
-	4,  // 81: virsh.SlaveVirshService.RemoveIsoFromVm:output_type -> virsh.OkResponse
-	1,  // 82: virsh.SlaveVirshService.ChangeNetwork:output_type -> virsh.Empty
-	4,  // 83: virsh.SlaveVirshService.AddNoVNCVideo:output_type -> virsh.OkResponse
-	4,  // 84: virsh.SlaveVirshService.RemoveNoVNCVideo:output_type -> virsh.OkResponse
-	17, // 85: virsh.SlaveVirshService.GetNoVNCVideo:output_type -> virsh.GetNoVNCVideoResponse
-	19, // 86: virsh.SlaveVirshService.GetMemoryBallooning:output_type -> virsh.GetMemoryBallooningResponse
-	4,  // 87: virsh.SlaveVirshService.SetMemoryBallooning:output_type -> virsh.OkResponse
-	21, // 88: virsh.SlaveVirshService.GetHugePages:output_type -> virsh.GetHugePagesResponse
-	4,  // 89: virsh.SlaveVirshService.SetHugePages:output_type -> virsh.OkResponse
-	22, // 90: virsh.SlaveVirshService.ListMachineTypes:output_type -> virsh.MachineTypesResponse
-	24, // 91: virsh.SlaveVirshService.SetMachineType:output_type -> virsh.MachineTypeResponse
-	26, // 92: virsh.SlaveVirshService.GetKVMHidden:output_type -> virsh.KVMHiddenResponse
-	26, // 93: virsh.SlaveVirshService.SetKVMHidden:output_type -> virsh.KVMHiddenResponse
-	28, // 94: virsh.SlaveVirshService.GetHyperV:output_type -> virsh.HyperVResponse
-	28, // 95: virsh.SlaveVirshService.SetHyperV:output_type -> virsh.HyperVResponse
-	30, // 96: virsh.SlaveVirshService.AttachExternalDisk:output_type -> virsh.ExternalDiskResponse
-	30, // 97: virsh.SlaveVirshService.DetachExternalDisk:output_type -> virsh.ExternalDiskResponse
-	4,  // 98: virsh.SlaveVirshService.EditVmResources:output_type -> virsh.OkResponse
-	4,  // 99: virsh.SlaveVirshService.ColdMigrateVm:output_type -> virsh.OkResponse
-	4,  // 100: virsh.SlaveVirshService.FreezeDisk:output_type -> virsh.OkResponse
-	4,  // 101: virsh.SlaveVirshService.UnFreezeDisk:output_type -> virsh.OkResponse
-	1,  // 102: virsh.SlaveVirshService.ChangeVmPassword:output_type -> virsh.Empty
-	4,  // 103: virsh.SlaveVirshService.AddSSHKey:output_type -> virsh.OkResponse
-	4,  // 104: virsh.SlaveVirshService.ApplyCPUPinning:output_type -> virsh.OkResponse
-	4,  // 105: virsh.SlaveVirshService.RemoveCPUPinning:output_type -> virsh.OkResponse
-	33, // 106: virsh.SlaveVirshService.GetCPUPinning:output_type -> virsh.CPUPinningResponse
-	36, // 107: virsh.SlaveVirshService.GetCPUTopology:output_type -> virsh.CPUTopologyResponse
-	38, // 108: virsh.SlaveVirshService.GetTunedAdmProfiles:output_type -> virsh.TunedAdmProfilesResponse
-	40, // 109: virsh.SlaveVirshService.SetTunedAdmProfile:output_type -> virsh.SetTunedAdmProfileResponse
-	41, // 110: virsh.SlaveVirshService.GetIrqBalanceState:output_type -> virsh.IrqBalanceStateResponse
-	43, // 111: virsh.SlaveVirshService.SetIrqBalanceState:output_type -> virsh.SetIrqBalanceStateResponse
-	47, // 112: virsh.SlaveVirshService.GetHostCoreIsolation:output_type -> virsh.HostCoreIsolationStateResponse
-	47, // 113: virsh.SlaveVirshService.SetHostCoreIsolation:output_type -> virsh.HostCoreIsolationStateResponse
-	47, // 114: virsh.SlaveVirshService.RemoveHostCoreIsolation:output_type -> virsh.HostCoreIsolationStateResponse
-	49, // 115: virsh.SlaveVirshService.GetHostHugePages:output_type -> virsh.HostHugePagesStateResponse
-	49, // 116: virsh.SlaveVirshService.SetHostHugePages:output_type -> virsh.HostHugePagesStateResponse
-	49, // 117: virsh.SlaveVirshService.RemoveHostHugePages:output_type -> virsh.HostHugePagesStateResponse
-	63, // [63:118] is the sub-list for method output_type
-	8,  // [8:63] is the sub-list for method input_type
-	8,  // [8:8] is the sub-list for extension type_name
-	8,  // [8:8] is the sub-list for extension extendee
-	0,  // [0:8] is the sub-list for field type_name
+	5,  // 0: virsh.CreateVmRequest.cloud_init:type_name -> virsh.CloudInitConfig
+	4,  // 1: virsh.CloudInitConfig.users:type_name -> virsh.CloudInitUser
+	0,  // 2: virsh.Vm.state:type_name -> virsh.VmState
+	7,  // 3: virsh.GetAllVmsResponse.vms:type_name -> virsh.Vm
+	38, // 4: virsh.ListSnapshotsResponse.snapshots:type_name -> virsh.SnapshotInfo
+	50, // 5: virsh.FileRestoreMountResponse.partitions:type_name -> virsh.FileRestorePartition
+	53, // 6: virsh.FileRestoreBrowseResponse.entries:type_name -> virsh.FileRestoreEntry
+	60, // 7: virsh.VmStats.disks:type_name -> virsh.VmDiskStats
+	61, // 8: virsh.VmStats.interfaces:type_name -> virsh.VmInterfaceStats
+	62, // 9: virsh.VmStatsResponse.vms:type_name -> virsh.VmStats
+	65, // 10: virsh.CPUPinningResponse.pins:type_name -> virsh.CPUPinningInfo
+	67, // 11: virsh.CPUSocketInfo.cores:type_name -> virsh.CPUCoreInfo
+	68, // 12: virsh.CPUTopologyResponse.sockets:type_name -> virsh.CPUSocketInfo
+	70, // 13: virsh.TunedAdmProfilesResponse.profiles:type_name -> virsh.TunedAdmProfileInfo
+	77, // 14: virsh.SetHostCoreIsolationRequest.sockets:type_name -> virsh.HostCoreIsolationSocketSelection
+	79, // 15: virsh.HostCoreIsolationStateResponse.sockets:type_name -> virsh.HostCoreIsolationSocketState
+	1,  // 16: virsh.SlaveVirshService.GetCpuFeatures:input_type -> virsh.Empty
+	1,  // 17: virsh.SlaveVirshService.GetCPUXML:input_type -> virsh.Empty
+	8,  // 18: virsh.SlaveVirshService.GetVMCPUXml:input_type -> virsh.GetVmByNameRequest
+	15, // 19: virsh.SlaveVirshService.UpdateVMCPUXml:input_type -> virsh.UpdateVMCPUXmlRequest
+	8,  // 20: virsh.SlaveVirshService.GetVMXml:input_type -> virsh.GetVmByNameRequest
+	16, // 21: virsh.SlaveVirshService.UpdateVMXml:input_type -> virsh.UpdateVMXmlRequest
+	3,  // 22: virsh.SlaveVirshService.CreateVm:input_type -> virsh.CreateVmRequest
+	10, // 23: virsh.SlaveVirshService.MigrateVM:input_type -> virsh.MigrateVmRequest
+	12, // 24: virsh.SlaveVirshService.CancelMigration:input_type -> virsh.CancelMigrationRequest
+	7,  // 25: virsh.SlaveVirshService.ShutdownVM:input_type -> virsh.Vm
+	7,  // 26: virsh.SlaveVirshService.ForceShutdownVM:input_type -> virsh.Vm
+	7,  // 27: virsh.SlaveVirshService.StartVM:input_type -> virsh.Vm
+	7,  // 28: virsh.SlaveVirshService.RemoveVM:input_type -> virsh.Vm
+	7,  // 29: virsh.SlaveVirshService.RestartVM:input_type -> virsh.Vm
+	7,  // 30: virsh.SlaveVirshService.PauseVM:input_type -> virsh.Vm
+	7,  // 31: virsh.SlaveVirshService.ResumeVM:input_type -> virsh.Vm
+	7,  // 32: virsh.SlaveVirshService.UndefineVM:input_type -> virsh.Vm
+	7,  // 33: virsh.SlaveVirshService.UndefineStaleVM:input_type -> virsh.Vm
+	1,  // 34: virsh.SlaveVirshService.GetAllVms:input_type -> virsh.Empty
+	8,  // 35: virsh.SlaveVirshService.GetVmByName:input_type -> virsh.GetVmByNameRequest
+	1,  // 36: virsh.SlaveVirshService.GetVmStats:input_type -> virsh.Empty
+	7,  // 37: virsh.SlaveVirshService.RemoveIsoFromVm:input_type -> virsh.Vm
+	19, // 38: virsh.SlaveVirshService.ChangeNetwork:input_type -> virsh.ChangeNetworkReq
+	8,  // 39: virsh.SlaveVirshService.AddNoVNCVideo:input_type -> virsh.GetVmByNameRequest
+	8,  // 40: virsh.SlaveVirshService.RemoveNoVNCVideo:input_type -> virsh.GetVmByNameRequest
+	8,  // 41: virsh.SlaveVirshService.GetNoVNCVideo:input_type -> virsh.GetVmByNameRequest
+	8,  // 42: virsh.SlaveVirshService.GetMemoryBallooning:input_type -> virsh.GetVmByNameRequest
+	23, // 43: virsh.SlaveVirshService.SetMemoryBallooning:input_type -> virsh.SetMemoryBallooningRequest
+	8,  // 44: virsh.SlaveVirshService.GetHugePages:input_type -> virsh.GetVmByNameRequest
+	25, // 45: virsh.SlaveVirshService.SetHugePages:input_type -> virsh.SetHugePagesRequest
+	1,  // 46: virsh.SlaveVirshService.ListMachineTypes:input_type -> virsh.Empty
+	28, // 47: virsh.SlaveVirshService.SetMachineType:input_type -> virsh.SetMachineTypeRequest
+	8,  // 48: virsh.SlaveVirshService.GetKVMHidden:input_type -> virsh.GetVmByNameRequest
+	30, // 49: virsh.SlaveVirshService.SetKVMHidden:input_type -> virsh.SetKVMHiddenRequest
+	8,  // 50: virsh.SlaveVirshService.GetHyperV:input_type -> virsh.GetVmByNameRequest
+	32, // 51: virsh.SlaveVirshService.SetHyperV:input_type -> virsh.SetHyperVRequest
+	34, // 52: virsh.SlaveVirshService.AttachExternalDisk:input_type -> virsh.ExternalDiskRequest
+	34, // 53: virsh.SlaveVirshService.DetachExternalDisk:input_type -> virsh.ExternalDiskRequest
+	7,  // 54: virsh.SlaveVirshService.EditVmResources:input_type -> virsh.Vm
+	18, // 55: virsh.SlaveVirshService.ColdMigrateVm:input_type -> virsh.ColdMigrationRequest
+	17, // 56: virsh.SlaveVirshService.DefineVMFromXML:input_type -> virsh.DefineVMFromXMLRequest
+	7,  // 57: virsh.SlaveVirshService.FreezeDisk:input_type -> virsh.Vm
+	7,  // 58: virsh.SlaveVirshService.UnFreezeDisk:input_type -> virsh.Vm
+	8,  // 59: virsh.SlaveVirshService.GuestAgentPing:input_type -> virsh.GetVmByNameRequest
+	46, // 60: virsh.SlaveVirshService.CheckTCPPort:input_type -> virsh.TCPPortRequest
+	36, // 61: virsh.SlaveVirshService.CreateSnapshot:input_type -> virsh.CreateSnapshotRequest
+	8,  // 62: virsh.SlaveVirshService.ListSnapshots:input_type -> virsh.GetVmByNameRequest
+	37, // 63: virsh.SlaveVirshService.RevertSnapshot:input_type -> virsh.SnapshotRequest
+	37, // 64: virsh.SlaveVirshService.DeleteSnapshot:input_type -> virsh.SnapshotRequest
+	40, // 65: virsh.SlaveVirshService.BackupDisk:input_type -> virsh.BackupDiskRequest
+	41, // 66: virsh.SlaveVirshService.FlattenBackupChain:input_type -> virsh.FlattenBackupRequest
+	42, // 67: virsh.SlaveVirshService.CheckDiskImage:input_type -> virsh.CheckDiskImageRequest
+	44, // 68: virsh.SlaveVirshService.TestBootBackup:input_type -> virsh.TestBootRequest
+	57, // 69: virsh.SlaveVirshService.WriteScratchImage:input_type -> virsh.ScratchChunk
+	58, // 70: virsh.SlaveVirshService.RemoveScratchImage:input_type -> virsh.ScratchImage
+	49, // 71: virsh.SlaveVirshService.MountBackupImage:input_type -> virsh.FileRestoreMountRequest
+	52, // 72: virsh.SlaveVirshService.BrowseBackupImage:input_type -> virsh.FileRestoreBrowseRequest
+	55, // 73: virsh.SlaveVirshService.ReadBackupImageFiles:input_type -> virsh.FileRestoreReadRequest
+	59, // 74: virsh.SlaveVirshService.UnmountBackupImage:input_type -> virsh.FileRestoreSession
+	1,  // 75: virsh.SlaveVirshService.CleanupFileRestores:input_type -> virsh.Empty
+	47, // 76: virsh.SlaveVirshService.BlockCopyDisk:input_type -> virsh.BlockCopyRequest
+	20, // 77: virsh.SlaveVirshService.ChangeVmPassword:input_type -> virsh.ChangeVncPassword
+	21, // 78: virsh.SlaveVirshService.AddSSHKey:input_type -> virsh.AddSSHKeyRequest
+	64, // 79: virsh.SlaveVirshService.ApplyCPUPinning:input_type -> virsh.CPUPinningRequest
+	8,  // 80: virsh.SlaveVirshService.RemoveCPUPinning:input_type -> virsh.GetVmByNameRequest
+	8,  // 81: virsh.SlaveVirshService.GetCPUPinning:input_type -> virsh.GetVmByNameRequest
+	1,  // 82: virsh.SlaveVirshService.GetCPUTopology:input_type -> virsh.Empty
+	1,  // 83: virsh.SlaveVirshService.GetTunedAdmProfiles:input_type -> virsh.Empty
+	72, // 84: virsh.SlaveVirshService.SetTunedAdmProfile:input_type -> virsh.SetTunedAdmProfileRequest
+	1,  // 85: virsh.SlaveVirshService.GetIrqBalanceState:input_type -> virsh.Empty
+	75, // 86: virsh.SlaveVirshService.SetIrqBalanceState:input_type -> virsh.SetIrqBalanceStateRequest
+	1,  // 87: virsh.SlaveVirshService.GetHostCoreIsolation:input_type -> virsh.Empty
+	78, // 88: virsh.SlaveVirshService.SetHostCoreIsolation:input_type -> virsh.SetHostCoreIsolationRequest
+	1,  // 89: virsh.SlaveVirshService.RemoveHostCoreIsolation:input_type -> virsh.Empty
+	1,  // 90: virsh.SlaveVirshService.GetHostHugePages:input_type -> virsh.Empty
+	81, // 91: virsh.SlaveVirshService.SetHostHugePages:input_type -> virsh.SetHostHugePagesRequest
+	1,  // 92: virsh.SlaveVirshService.RemoveHostHugePages:input_type -> virsh.Empty
+	2,  // 93: virsh.SlaveVirshService.GetCpuFeatures:output_type -> virsh.GetCpuFeaturesResponse
+	13, // 94: virsh.SlaveVirshService.GetCPUXML:output_type -> virsh.CPUXMLResponse
+	13, // 95: virsh.SlaveVirshService.GetVMCPUXml:output_type -> virsh.CPUXMLResponse
+	6,  // 96: virsh.SlaveVirshService.UpdateVMCPUXml:output_type -> virsh.OkResponse
+	14, // 97: virsh.SlaveVirshService.GetVMXml:output_type -> virsh.VMXMLResponse
+	6,  // 98: virsh.SlaveVirshService.UpdateVMXml:output_type -> virsh.OkResponse
+	6,  // 99: virsh.SlaveVirshService.CreateVm:output_type -> virsh.OkResponse
+	11, // 100: virsh.SlaveVirshService.MigrateVM:output_type -> virsh.MigrationProgress
+	6,  // 101: virsh.SlaveVirshService.CancelMigration:output_type -> virsh.OkResponse
+	6,  // 102: virsh.SlaveVirshService.ShutdownVM:output_type -> virsh.OkResponse
+	6,  // 103: virsh.SlaveVirshService.ForceShutdownVM:output_type -> virsh.OkResponse
+	6,  // 104: virsh.SlaveVirshService.StartVM:output_type -> virsh.OkResponse
+	6,  // 105: virsh.SlaveVirshService.RemoveVM:output_type -> virsh.OkResponse
+	6,  // 106: virsh.SlaveVirshService.RestartVM:output_type -> virsh.OkResponse
+	6,  // 107: virsh.SlaveVirshService.PauseVM:output_type -> virsh.OkResponse
+	6,  // 108: virsh.SlaveVirshService.ResumeVM:output_type -> virsh.OkResponse
+	6,  // 109: virsh.SlaveVirshService.UndefineVM:output_type -> virsh.OkResponse
+	6,  // 110: virsh.SlaveVirshService.UndefineStaleVM:output_type -> virsh.OkResponse
+	9,  // 111: virsh.SlaveVirshService.GetAllVms:output_type -> virsh.GetAllVmsResponse
+	7,  // 112: virsh.SlaveVirshService.GetVmByName:output_type -> virsh.Vm
+	63, // 113: virsh.SlaveVirshService.GetVmStats:output_type -> virsh.VmStatsResponse
+	6,  // 114: virsh.SlaveVirshService.RemoveIsoFromVm:output_type -> virsh.OkResponse
+	1,  // 115: virsh.SlaveVirshService.ChangeNetwork:output_type -> virsh.Empty
+	6,  // 116: virsh.SlaveVirshService.AddNoVNCVideo:output_type -> virsh.OkResponse
+	6,  // 117: virsh.SlaveVirshService.RemoveNoVNCVideo:output_type -> virsh.OkResponse
+	22, // 118: virsh.SlaveVirshService.GetNoVNCVideo:output_type -> virsh.GetNoVNCVideoResponse
+	24, // 119: virsh.SlaveVirshService.GetMemoryBallooning:output_type -> virsh.GetMemoryBallooningResponse
+	6,  // 120: virsh.SlaveVirshService.SetMemoryBallooning:output_type -> virsh.OkResponse
+	26, // 121: virsh.SlaveVirshService.GetHugePages:output_type -> virsh.GetHugePagesResponse
+	6,  // 122: virsh.SlaveVirshService.SetHugePages:output_type -> virsh.OkResponse
+	27, // 123: virsh.SlaveVirshService.ListMachineTypes:output_type -> virsh.MachineTypesResponse
+	29, // 124: virsh.SlaveVirshService.SetMachineType:output_type -> virsh.MachineTypeResponse
+	31, // 125: virsh.SlaveVirshService.GetKVMHidden:output_type -> virsh.KVMHiddenResponse
+	31, // 126: virsh.SlaveVirshService.SetKVMHidden:output_type -> virsh.KVMHiddenResponse
+	33, // 127: virsh.SlaveVirshService.GetHyperV:output_type -> virsh.HyperVResponse
+	33, // 128: virsh.SlaveVirshService.SetHyperV:output_type -> virsh.HyperVResponse
+	35, // 129: virsh.SlaveVirshService.AttachExternalDisk:output_type -> virsh.ExternalDiskResponse
+	35, // 130: virsh.SlaveVirshService.DetachExternalDisk:output_type -> virsh.ExternalDiskResponse
+	6,  // 131: virsh.SlaveVirshService.EditVmResources:output_type -> virsh.OkResponse
+	6,  // 132: virsh.SlaveVirshService.ColdMigrateVm:output_type -> virsh.OkResponse
+	6,  // 133: virsh.SlaveVirshService.DefineVMFromXML:output_type -> virsh.OkResponse
+	6,  // 134: virsh.SlaveVirshService.FreezeDisk:output_type -> virsh.OkResponse
+	6,  // 135: virsh.SlaveVirshService.UnFreezeDisk:output_type -> virsh.OkResponse
+	6,  // 136: virsh.SlaveVirshService.GuestAgentPing:output_type -> virsh.OkResponse
+	6,  // 137: virsh.SlaveVirshService.CheckTCPPort:output_type -> virsh.OkResponse
+	38, // 138: virsh.SlaveVirshService.CreateSnapshot:output_type -> virsh.SnapshotInfo
+	39, // 139: virsh.SlaveVirshService.ListSnapshots:output_type -> virsh.ListSnapshotsResponse
+	6,  // 140: virsh.SlaveVirshService.RevertSnapshot:output_type -> virsh.OkResponse
+	6,  // 141: virsh.SlaveVirshService.DeleteSnapshot:output_type -> virsh.OkResponse
+	6,  // 142: virsh.SlaveVirshService.BackupDisk:output_type -> virsh.OkResponse
+	6,  // 143: virsh.SlaveVirshService.FlattenBackupChain:output_type -> virsh.OkResponse
+	43, // 144: virsh.SlaveVirshService.CheckDiskImage:output_type -> virsh.CheckDiskImageResponse
+	45, // 145: virsh.SlaveVirshService.TestBootBackup:output_type -> virsh.TestBootResponse
+	58, // 146: virsh.SlaveVirshService.WriteScratchImage:output_type -> virsh.ScratchImage
+	6,  // 147: virsh.SlaveVirshService.RemoveScratchImage:output_type -> virsh.OkResponse
+	51, // 148: virsh.SlaveVirshService.MountBackupImage:output_type -> virsh.FileRestoreMountResponse
+	54, // 149: virsh.SlaveVirshService.BrowseBackupImage:output_type -> virsh.FileRestoreBrowseResponse
+	56, // 150: virsh.SlaveVirshService.ReadBackupImageFiles:output_type -> virsh.FileChunk
+	6,  // 151: virsh.SlaveVirshService.UnmountBackupImage:output_type -> virsh.OkResponse
+	6,  // 152: virsh.SlaveVirshService.CleanupFileRestores:output_type -> virsh.OkResponse
+	48, // 153: virsh.SlaveVirshService.BlockCopyDisk:output_type -> virsh.BlockCopyProgress
+	1,  // 154: virsh.SlaveVirshService.ChangeVmPassword:output_type -> virsh.Empty
+	6,  // 155: virsh.SlaveVirshService.AddSSHKey:output_type -> virsh.OkResponse
+	6,  // 156: virsh.SlaveVirshService.ApplyCPUPinning:output_type -> virsh.OkResponse
+	6,  // 157: virsh.SlaveVirshService.RemoveCPUPinning:output_type -> virsh.OkResponse
+	66, // 158: virsh.SlaveVirshService.GetCPUPinning:output_type -> virsh.CPUPinningResponse
+	69, // 159: virsh.SlaveVirshService.GetCPUTopology:output_type -> virsh.CPUTopologyResponse
+	71, // 160: virsh.SlaveVirshService.GetTunedAdmProfiles:output_type -> virsh.TunedAdmProfilesResponse
+	73, // 161: virsh.SlaveVirshService.SetTunedAdmProfile:output_type -> virsh.SetTunedAdmProfileResponse
+	74, // 162: virsh.SlaveVirshService.GetIrqBalanceState:output_type -> virsh.IrqBalanceStateResponse
+	76, // 163: virsh.SlaveVirshService.SetIrqBalanceState:output_type -> virsh.SetIrqBalanceStateResponse
+	80, // 164: virsh.SlaveVirshService.GetHostCoreIsolation:output_type -> virsh.HostCoreIsolationStateResponse
+	80, // 165: virsh.SlaveVirshService.SetHostCoreIsolation:output_type -> virsh.HostCoreIsolationStateResponse
+	80, // 166: virsh.SlaveVirshService.RemoveHostCoreIsolation:output_type -> virsh.HostCoreIsolationStateResponse
+	82, // 167: virsh.SlaveVirshService.GetHostHugePages:output_type -> virsh.HostHugePagesStateResponse
+	82, // 168: virsh.SlaveVirshService.SetHostHugePages:output_type -> virsh.HostHugePagesStateResponse
+	82, // 169: virsh.SlaveVirshService.RemoveHostHugePages:output_type -> virsh.HostHugePagesStateResponse
+	93, // [93:170] is the sub-list for method output_type
+	16, // [16:93] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
 }
 
 func init() { file_virsh_proto_init() }
@@ -3722,7 +6369,7 @@ func file_virsh_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_virsh_proto_rawDesc), len(file_virsh_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   49,
+			NumMessages:   82,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
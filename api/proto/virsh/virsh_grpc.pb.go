@@ -27,6 +27,7 @@ const (
 	SlaveVirshService_UpdateVMXml_FullMethodName             = "/virsh.SlaveVirshService/UpdateVMXml"
 	SlaveVirshService_CreateVm_FullMethodName                = "/virsh.SlaveVirshService/CreateVm"
 	SlaveVirshService_MigrateVM_FullMethodName               = "/virsh.SlaveVirshService/MigrateVM"
+	SlaveVirshService_CancelMigration_FullMethodName         = "/virsh.SlaveVirshService/CancelMigration"
 	SlaveVirshService_ShutdownVM_FullMethodName              = "/virsh.SlaveVirshService/ShutdownVM"
 	SlaveVirshService_ForceShutdownVM_FullMethodName         = "/virsh.SlaveVirshService/ForceShutdownVM"
 	SlaveVirshService_StartVM_FullMethodName                 = "/virsh.SlaveVirshService/StartVM"
@@ -35,8 +36,10 @@ const (
 	SlaveVirshService_PauseVM_FullMethodName                 = "/virsh.SlaveVirshService/PauseVM"
 	SlaveVirshService_ResumeVM_FullMethodName                = "/virsh.SlaveVirshService/ResumeVM"
 	SlaveVirshService_UndefineVM_FullMethodName              = "/virsh.SlaveVirshService/UndefineVM"
+	SlaveVirshService_UndefineStaleVM_FullMethodName         = "/virsh.SlaveVirshService/UndefineStaleVM"
 	SlaveVirshService_GetAllVms_FullMethodName               = "/virsh.SlaveVirshService/GetAllVms"
 	SlaveVirshService_GetVmByName_FullMethodName             = "/virsh.SlaveVirshService/GetVmByName"
+	SlaveVirshService_GetVmStats_FullMethodName              = "/virsh.SlaveVirshService/GetVmStats"
 	SlaveVirshService_RemoveIsoFromVm_FullMethodName         = "/virsh.SlaveVirshService/RemoveIsoFromVm"
 	SlaveVirshService_ChangeNetwork_FullMethodName           = "/virsh.SlaveVirshService/ChangeNetwork"
 	SlaveVirshService_AddNoVNCVideo_FullMethodName           = "/virsh.SlaveVirshService/AddNoVNCVideo"
@@ -56,8 +59,27 @@ const (
 	SlaveVirshService_DetachExternalDisk_FullMethodName      = "/virsh.SlaveVirshService/DetachExternalDisk"
 	SlaveVirshService_EditVmResources_FullMethodName         = "/virsh.SlaveVirshService/EditVmResources"
 	SlaveVirshService_ColdMigrateVm_FullMethodName           = "/virsh.SlaveVirshService/ColdMigrateVm"
+	SlaveVirshService_DefineVMFromXML_FullMethodName         = "/virsh.SlaveVirshService/DefineVMFromXML"
 	SlaveVirshService_FreezeDisk_FullMethodName              = "/virsh.SlaveVirshService/FreezeDisk"
 	SlaveVirshService_UnFreezeDisk_FullMethodName            = "/virsh.SlaveVirshService/UnFreezeDisk"
+	SlaveVirshService_GuestAgentPing_FullMethodName          = "/virsh.SlaveVirshService/GuestAgentPing"
+	SlaveVirshService_CheckTCPPort_FullMethodName            = "/virsh.SlaveVirshService/CheckTCPPort"
+	SlaveVirshService_CreateSnapshot_FullMethodName          = "/virsh.SlaveVirshService/CreateSnapshot"
+	SlaveVirshService_ListSnapshots_FullMethodName           = "/virsh.SlaveVirshService/ListSnapshots"
+	SlaveVirshService_RevertSnapshot_FullMethodName          = "/virsh.SlaveVirshService/RevertSnapshot"
+	SlaveVirshService_DeleteSnapshot_FullMethodName          = "/virsh.SlaveVirshService/DeleteSnapshot"
+	SlaveVirshService_BackupDisk_FullMethodName              = "/virsh.SlaveVirshService/BackupDisk"
+	SlaveVirshService_FlattenBackupChain_FullMethodName      = "/virsh.SlaveVirshService/FlattenBackupChain"
+	SlaveVirshService_CheckDiskImage_FullMethodName          = "/virsh.SlaveVirshService/CheckDiskImage"
+	SlaveVirshService_TestBootBackup_FullMethodName          = "/virsh.SlaveVirshService/TestBootBackup"
+	SlaveVirshService_WriteScratchImage_FullMethodName       = "/virsh.SlaveVirshService/WriteScratchImage"
+	SlaveVirshService_RemoveScratchImage_FullMethodName      = "/virsh.SlaveVirshService/RemoveScratchImage"
+	SlaveVirshService_MountBackupImage_FullMethodName        = "/virsh.SlaveVirshService/MountBackupImage"
+	SlaveVirshService_BrowseBackupImage_FullMethodName       = "/virsh.SlaveVirshService/BrowseBackupImage"
+	SlaveVirshService_ReadBackupImageFiles_FullMethodName    = "/virsh.SlaveVirshService/ReadBackupImageFiles"
+	SlaveVirshService_UnmountBackupImage_FullMethodName      = "/virsh.SlaveVirshService/UnmountBackupImage"
+	SlaveVirshService_CleanupFileRestores_FullMethodName     = "/virsh.SlaveVirshService/CleanupFileRestores"
+	SlaveVirshService_BlockCopyDisk_FullMethodName           = "/virsh.SlaveVirshService/BlockCopyDisk"
 	SlaveVirshService_ChangeVmPassword_FullMethodName        = "/virsh.SlaveVirshService/ChangeVmPassword"
 	SlaveVirshService_AddSSHKey_FullMethodName               = "/virsh.SlaveVirshService/AddSSHKey"
 	SlaveVirshService_ApplyCPUPinning_FullMethodName         = "/virsh.SlaveVirshService/ApplyCPUPinning"
@@ -89,7 +111,8 @@ type SlaveVirshServiceClient interface {
 	GetVMXml(ctx context.Context, in *GetVmByNameRequest, opts ...grpc.CallOption) (*VMXMLResponse, error)
 	UpdateVMXml(ctx context.Context, in *UpdateVMXmlRequest, opts ...grpc.CallOption) (*OkResponse, error)
 	CreateVm(ctx context.Context, in *CreateVmRequest, opts ...grpc.CallOption) (*OkResponse, error)
-	MigrateVM(ctx context.Context, in *MigrateVmRequest, opts ...grpc.CallOption) (*OkResponse, error)
+	MigrateVM(ctx context.Context, in *MigrateVmRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MigrationProgress], error)
+	CancelMigration(ctx context.Context, in *CancelMigrationRequest, opts ...grpc.CallOption) (*OkResponse, error)
 	ShutdownVM(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error)
 	ForceShutdownVM(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error)
 	StartVM(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error)
@@ -98,8 +121,10 @@ type SlaveVirshServiceClient interface {
 	PauseVM(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error)
 	ResumeVM(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error)
 	UndefineVM(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error)
+	UndefineStaleVM(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error)
 	GetAllVms(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetAllVmsResponse, error)
 	GetVmByName(ctx context.Context, in *GetVmByNameRequest, opts ...grpc.CallOption) (*Vm, error)
+	GetVmStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*VmStatsResponse, error)
 	RemoveIsoFromVm(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error)
 	ChangeNetwork(ctx context.Context, in *ChangeNetworkReq, opts ...grpc.CallOption) (*Empty, error)
 	AddNoVNCVideo(ctx context.Context, in *GetVmByNameRequest, opts ...grpc.CallOption) (*OkResponse, error)
@@ -121,8 +146,32 @@ type SlaveVirshServiceClient interface {
 	// cpuCount and memoryMB are the new values to set
 	EditVmResources(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error)
 	ColdMigrateVm(ctx context.Context, in *ColdMigrationRequest, opts ...grpc.CallOption) (*OkResponse, error)
+	DefineVMFromXML(ctx context.Context, in *DefineVMFromXMLRequest, opts ...grpc.CallOption) (*OkResponse, error)
 	FreezeDisk(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error)
 	UnFreezeDisk(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error)
+	// Autostart readiness checks
+	GuestAgentPing(ctx context.Context, in *GetVmByNameRequest, opts ...grpc.CallOption) (*OkResponse, error)
+	CheckTCPPort(ctx context.Context, in *TCPPortRequest, opts ...grpc.CallOption) (*OkResponse, error)
+	// Snapshots
+	CreateSnapshot(ctx context.Context, in *CreateSnapshotRequest, opts ...grpc.CallOption) (*SnapshotInfo, error)
+	ListSnapshots(ctx context.Context, in *GetVmByNameRequest, opts ...grpc.CallOption) (*ListSnapshotsResponse, error)
+	RevertSnapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*OkResponse, error)
+	DeleteSnapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*OkResponse, error)
+	// Incremental backups
+	BackupDisk(ctx context.Context, in *BackupDiskRequest, opts ...grpc.CallOption) (*OkResponse, error)
+	FlattenBackupChain(ctx context.Context, in *FlattenBackupRequest, opts ...grpc.CallOption) (*OkResponse, error)
+	CheckDiskImage(ctx context.Context, in *CheckDiskImageRequest, opts ...grpc.CallOption) (*CheckDiskImageResponse, error)
+	TestBootBackup(ctx context.Context, in *TestBootRequest, opts ...grpc.CallOption) (*TestBootResponse, error)
+	WriteScratchImage(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ScratchChunk, ScratchImage], error)
+	RemoveScratchImage(ctx context.Context, in *ScratchImage, opts ...grpc.CallOption) (*OkResponse, error)
+	// File-level restore
+	MountBackupImage(ctx context.Context, in *FileRestoreMountRequest, opts ...grpc.CallOption) (*FileRestoreMountResponse, error)
+	BrowseBackupImage(ctx context.Context, in *FileRestoreBrowseRequest, opts ...grpc.CallOption) (*FileRestoreBrowseResponse, error)
+	ReadBackupImageFiles(ctx context.Context, in *FileRestoreReadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FileChunk], error)
+	UnmountBackupImage(ctx context.Context, in *FileRestoreSession, opts ...grpc.CallOption) (*OkResponse, error)
+	CleanupFileRestores(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*OkResponse, error)
+	// Live storage migration
+	BlockCopyDisk(ctx context.Context, in *BlockCopyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BlockCopyProgress], error)
 	ChangeVmPassword(ctx context.Context, in *ChangeVncPassword, opts ...grpc.CallOption) (*Empty, error)
 	AddSSHKey(ctx context.Context, in *AddSSHKeyRequest, opts ...grpc.CallOption) (*OkResponse, error)
 	// CPU Pinning
@@ -223,10 +272,29 @@ func (c *slaveVirshServiceClient) CreateVm(ctx context.Context, in *CreateVmRequ
 	return out, nil
 }
 
-func (c *slaveVirshServiceClient) MigrateVM(ctx context.Context, in *MigrateVmRequest, opts ...grpc.CallOption) (*OkResponse, error) {
+func (c *slaveVirshServiceClient) MigrateVM(ctx context.Context, in *MigrateVmRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MigrationProgress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SlaveVirshService_ServiceDesc.Streams[0], SlaveVirshService_MigrateVM_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[MigrateVmRequest, MigrationProgress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SlaveVirshService_MigrateVMClient = grpc.ServerStreamingClient[MigrationProgress]
+
+func (c *slaveVirshServiceClient) CancelMigration(ctx context.Context, in *CancelMigrationRequest, opts ...grpc.CallOption) (*OkResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(OkResponse)
-	err := c.cc.Invoke(ctx, SlaveVirshService_MigrateVM_FullMethodName, in, out, cOpts...)
+	err := c.cc.Invoke(ctx, SlaveVirshService_CancelMigration_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -313,6 +381,16 @@ func (c *slaveVirshServiceClient) UndefineVM(ctx context.Context, in *Vm, opts .
 	return out, nil
 }
 
+func (c *slaveVirshServiceClient) UndefineStaleVM(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OkResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_UndefineStaleVM_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *slaveVirshServiceClient) GetAllVms(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetAllVmsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GetAllVmsResponse)
@@ -333,6 +411,16 @@ func (c *slaveVirshServiceClient) GetVmByName(ctx context.Context, in *GetVmByNa
 	return out, nil
 }
 
+func (c *slaveVirshServiceClient) GetVmStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*VmStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VmStatsResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_GetVmStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *slaveVirshServiceClient) RemoveIsoFromVm(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(OkResponse)
@@ -523,6 +611,16 @@ func (c *slaveVirshServiceClient) ColdMigrateVm(ctx context.Context, in *ColdMig
 	return out, nil
 }
 
+func (c *slaveVirshServiceClient) DefineVMFromXML(ctx context.Context, in *DefineVMFromXMLRequest, opts ...grpc.CallOption) (*OkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OkResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_DefineVMFromXML_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *slaveVirshServiceClient) FreezeDisk(ctx context.Context, in *Vm, opts ...grpc.CallOption) (*OkResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(OkResponse)
@@ -543,6 +641,207 @@ func (c *slaveVirshServiceClient) UnFreezeDisk(ctx context.Context, in *Vm, opts
 	return out, nil
 }
 
+func (c *slaveVirshServiceClient) GuestAgentPing(ctx context.Context, in *GetVmByNameRequest, opts ...grpc.CallOption) (*OkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OkResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_GuestAgentPing_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) CheckTCPPort(ctx context.Context, in *TCPPortRequest, opts ...grpc.CallOption) (*OkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OkResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_CheckTCPPort_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) CreateSnapshot(ctx context.Context, in *CreateSnapshotRequest, opts ...grpc.CallOption) (*SnapshotInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SnapshotInfo)
+	err := c.cc.Invoke(ctx, SlaveVirshService_CreateSnapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) ListSnapshots(ctx context.Context, in *GetVmByNameRequest, opts ...grpc.CallOption) (*ListSnapshotsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSnapshotsResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_ListSnapshots_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) RevertSnapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*OkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OkResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_RevertSnapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) DeleteSnapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*OkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OkResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_DeleteSnapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) BackupDisk(ctx context.Context, in *BackupDiskRequest, opts ...grpc.CallOption) (*OkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OkResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_BackupDisk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) FlattenBackupChain(ctx context.Context, in *FlattenBackupRequest, opts ...grpc.CallOption) (*OkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OkResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_FlattenBackupChain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) CheckDiskImage(ctx context.Context, in *CheckDiskImageRequest, opts ...grpc.CallOption) (*CheckDiskImageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckDiskImageResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_CheckDiskImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) TestBootBackup(ctx context.Context, in *TestBootRequest, opts ...grpc.CallOption) (*TestBootResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TestBootResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_TestBootBackup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) WriteScratchImage(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ScratchChunk, ScratchImage], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SlaveVirshService_ServiceDesc.Streams[1], SlaveVirshService_WriteScratchImage_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ScratchChunk, ScratchImage]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SlaveVirshService_WriteScratchImageClient = grpc.ClientStreamingClient[ScratchChunk, ScratchImage]
+
+func (c *slaveVirshServiceClient) RemoveScratchImage(ctx context.Context, in *ScratchImage, opts ...grpc.CallOption) (*OkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OkResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_RemoveScratchImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) MountBackupImage(ctx context.Context, in *FileRestoreMountRequest, opts ...grpc.CallOption) (*FileRestoreMountResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileRestoreMountResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_MountBackupImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) BrowseBackupImage(ctx context.Context, in *FileRestoreBrowseRequest, opts ...grpc.CallOption) (*FileRestoreBrowseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileRestoreBrowseResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_BrowseBackupImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) ReadBackupImageFiles(ctx context.Context, in *FileRestoreReadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FileChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SlaveVirshService_ServiceDesc.Streams[2], SlaveVirshService_ReadBackupImageFiles_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[FileRestoreReadRequest, FileChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SlaveVirshService_ReadBackupImageFilesClient = grpc.ServerStreamingClient[FileChunk]
+
+func (c *slaveVirshServiceClient) UnmountBackupImage(ctx context.Context, in *FileRestoreSession, opts ...grpc.CallOption) (*OkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OkResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_UnmountBackupImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) CleanupFileRestores(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*OkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OkResponse)
+	err := c.cc.Invoke(ctx, SlaveVirshService_CleanupFileRestores_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveVirshServiceClient) BlockCopyDisk(ctx context.Context, in *BlockCopyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BlockCopyProgress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SlaveVirshService_ServiceDesc.Streams[3], SlaveVirshService_BlockCopyDisk_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BlockCopyRequest, BlockCopyProgress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SlaveVirshService_BlockCopyDiskClient = grpc.ServerStreamingClient[BlockCopyProgress]
+
 func (c *slaveVirshServiceClient) ChangeVmPassword(ctx context.Context, in *ChangeVncPassword, opts ...grpc.CallOption) (*Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(Empty)
@@ -716,7 +1015,8 @@ type SlaveVirshServiceServer interface {
 	GetVMXml(context.Context, *GetVmByNameRequest) (*VMXMLResponse, error)
 	UpdateVMXml(context.Context, *UpdateVMXmlRequest) (*OkResponse, error)
 	CreateVm(context.Context, *CreateVmRequest) (*OkResponse, error)
-	MigrateVM(context.Context, *MigrateVmRequest) (*OkResponse, error)
+	MigrateVM(*MigrateVmRequest, grpc.ServerStreamingServer[MigrationProgress]) error
+	CancelMigration(context.Context, *CancelMigrationRequest) (*OkResponse, error)
 	ShutdownVM(context.Context, *Vm) (*OkResponse, error)
 	ForceShutdownVM(context.Context, *Vm) (*OkResponse, error)
 	StartVM(context.Context, *Vm) (*OkResponse, error)
@@ -725,8 +1025,10 @@ type SlaveVirshServiceServer interface {
 	PauseVM(context.Context, *Vm) (*OkResponse, error)
 	ResumeVM(context.Context, *Vm) (*OkResponse, error)
 	UndefineVM(context.Context, *Vm) (*OkResponse, error)
+	UndefineStaleVM(context.Context, *Vm) (*OkResponse, error)
 	GetAllVms(context.Context, *Empty) (*GetAllVmsResponse, error)
 	GetVmByName(context.Context, *GetVmByNameRequest) (*Vm, error)
+	GetVmStats(context.Context, *Empty) (*VmStatsResponse, error)
 	RemoveIsoFromVm(context.Context, *Vm) (*OkResponse, error)
 	ChangeNetwork(context.Context, *ChangeNetworkReq) (*Empty, error)
 	AddNoVNCVideo(context.Context, *GetVmByNameRequest) (*OkResponse, error)
@@ -748,8 +1050,32 @@ type SlaveVirshServiceServer interface {
 	// cpuCount and memoryMB are the new values to set
 	EditVmResources(context.Context, *Vm) (*OkResponse, error)
 	ColdMigrateVm(context.Context, *ColdMigrationRequest) (*OkResponse, error)
+	DefineVMFromXML(context.Context, *DefineVMFromXMLRequest) (*OkResponse, error)
 	FreezeDisk(context.Context, *Vm) (*OkResponse, error)
 	UnFreezeDisk(context.Context, *Vm) (*OkResponse, error)
+	// Autostart readiness checks
+	GuestAgentPing(context.Context, *GetVmByNameRequest) (*OkResponse, error)
+	CheckTCPPort(context.Context, *TCPPortRequest) (*OkResponse, error)
+	// Snapshots
+	CreateSnapshot(context.Context, *CreateSnapshotRequest) (*SnapshotInfo, error)
+	ListSnapshots(context.Context, *GetVmByNameRequest) (*ListSnapshotsResponse, error)
+	RevertSnapshot(context.Context, *SnapshotRequest) (*OkResponse, error)
+	DeleteSnapshot(context.Context, *SnapshotRequest) (*OkResponse, error)
+	// Incremental backups
+	BackupDisk(context.Context, *BackupDiskRequest) (*OkResponse, error)
+	FlattenBackupChain(context.Context, *FlattenBackupRequest) (*OkResponse, error)
+	CheckDiskImage(context.Context, *CheckDiskImageRequest) (*CheckDiskImageResponse, error)
+	TestBootBackup(context.Context, *TestBootRequest) (*TestBootResponse, error)
+	WriteScratchImage(grpc.ClientStreamingServer[ScratchChunk, ScratchImage]) error
+	RemoveScratchImage(context.Context, *ScratchImage) (*OkResponse, error)
+	// File-level restore
+	MountBackupImage(context.Context, *FileRestoreMountRequest) (*FileRestoreMountResponse, error)
+	BrowseBackupImage(context.Context, *FileRestoreBrowseRequest) (*FileRestoreBrowseResponse, error)
+	ReadBackupImageFiles(*FileRestoreReadRequest, grpc.ServerStreamingServer[FileChunk]) error
+	UnmountBackupImage(context.Context, *FileRestoreSession) (*OkResponse, error)
+	CleanupFileRestores(context.Context, *Empty) (*OkResponse, error)
+	// Live storage migration
+	BlockCopyDisk(*BlockCopyRequest, grpc.ServerStreamingServer[BlockCopyProgress]) error
 	ChangeVmPassword(context.Context, *ChangeVncPassword) (*Empty, error)
 	AddSSHKey(context.Context, *AddSSHKeyRequest) (*OkResponse, error)
 	// CPU Pinning
@@ -801,8 +1127,11 @@ func (UnimplementedSlaveVirshServiceServer) UpdateVMXml(context.Context, *Update
 func (UnimplementedSlaveVirshServiceServer) CreateVm(context.Context, *CreateVmRequest) (*OkResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateVm not implemented")
 }
-func (UnimplementedSlaveVirshServiceServer) MigrateVM(context.Context, *MigrateVmRequest) (*OkResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method MigrateVM not implemented")
+func (UnimplementedSlaveVirshServiceServer) MigrateVM(*MigrateVmRequest, grpc.ServerStreamingServer[MigrationProgress]) error {
+	return status.Errorf(codes.Unimplemented, "method MigrateVM not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) CancelMigration(context.Context, *CancelMigrationRequest) (*OkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelMigration not implemented")
 }
 func (UnimplementedSlaveVirshServiceServer) ShutdownVM(context.Context, *Vm) (*OkResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ShutdownVM not implemented")
@@ -828,12 +1157,18 @@ func (UnimplementedSlaveVirshServiceServer) ResumeVM(context.Context, *Vm) (*OkR
 func (UnimplementedSlaveVirshServiceServer) UndefineVM(context.Context, *Vm) (*OkResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UndefineVM not implemented")
 }
+func (UnimplementedSlaveVirshServiceServer) UndefineStaleVM(context.Context, *Vm) (*OkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UndefineStaleVM not implemented")
+}
 func (UnimplementedSlaveVirshServiceServer) GetAllVms(context.Context, *Empty) (*GetAllVmsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetAllVms not implemented")
 }
 func (UnimplementedSlaveVirshServiceServer) GetVmByName(context.Context, *GetVmByNameRequest) (*Vm, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetVmByName not implemented")
 }
+func (UnimplementedSlaveVirshServiceServer) GetVmStats(context.Context, *Empty) (*VmStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVmStats not implemented")
+}
 func (UnimplementedSlaveVirshServiceServer) RemoveIsoFromVm(context.Context, *Vm) (*OkResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RemoveIsoFromVm not implemented")
 }
@@ -891,12 +1226,69 @@ func (UnimplementedSlaveVirshServiceServer) EditVmResources(context.Context, *Vm
 func (UnimplementedSlaveVirshServiceServer) ColdMigrateVm(context.Context, *ColdMigrationRequest) (*OkResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ColdMigrateVm not implemented")
 }
+func (UnimplementedSlaveVirshServiceServer) DefineVMFromXML(context.Context, *DefineVMFromXMLRequest) (*OkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DefineVMFromXML not implemented")
+}
 func (UnimplementedSlaveVirshServiceServer) FreezeDisk(context.Context, *Vm) (*OkResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FreezeDisk not implemented")
 }
 func (UnimplementedSlaveVirshServiceServer) UnFreezeDisk(context.Context, *Vm) (*OkResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UnFreezeDisk not implemented")
 }
+func (UnimplementedSlaveVirshServiceServer) GuestAgentPing(context.Context, *GetVmByNameRequest) (*OkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GuestAgentPing not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) CheckTCPPort(context.Context, *TCPPortRequest) (*OkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckTCPPort not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) CreateSnapshot(context.Context, *CreateSnapshotRequest) (*SnapshotInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSnapshot not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) ListSnapshots(context.Context, *GetVmByNameRequest) (*ListSnapshotsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSnapshots not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) RevertSnapshot(context.Context, *SnapshotRequest) (*OkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevertSnapshot not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) DeleteSnapshot(context.Context, *SnapshotRequest) (*OkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSnapshot not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) BackupDisk(context.Context, *BackupDiskRequest) (*OkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BackupDisk not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) FlattenBackupChain(context.Context, *FlattenBackupRequest) (*OkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FlattenBackupChain not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) CheckDiskImage(context.Context, *CheckDiskImageRequest) (*CheckDiskImageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckDiskImage not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) TestBootBackup(context.Context, *TestBootRequest) (*TestBootResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TestBootBackup not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) WriteScratchImage(grpc.ClientStreamingServer[ScratchChunk, ScratchImage]) error {
+	return status.Errorf(codes.Unimplemented, "method WriteScratchImage not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) RemoveScratchImage(context.Context, *ScratchImage) (*OkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveScratchImage not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) MountBackupImage(context.Context, *FileRestoreMountRequest) (*FileRestoreMountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MountBackupImage not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) BrowseBackupImage(context.Context, *FileRestoreBrowseRequest) (*FileRestoreBrowseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BrowseBackupImage not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) ReadBackupImageFiles(*FileRestoreReadRequest, grpc.ServerStreamingServer[FileChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method ReadBackupImageFiles not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) UnmountBackupImage(context.Context, *FileRestoreSession) (*OkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnmountBackupImage not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) CleanupFileRestores(context.Context, *Empty) (*OkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CleanupFileRestores not implemented")
+}
+func (UnimplementedSlaveVirshServiceServer) BlockCopyDisk(*BlockCopyRequest, grpc.ServerStreamingServer[BlockCopyProgress]) error {
+	return status.Errorf(codes.Unimplemented, "method BlockCopyDisk not implemented")
+}
 func (UnimplementedSlaveVirshServiceServer) ChangeVmPassword(context.Context, *ChangeVncPassword) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ChangeVmPassword not implemented")
 }
@@ -1092,20 +1484,31 @@ func _SlaveVirshService_CreateVm_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveVirshService_MigrateVM_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(MigrateVmRequest)
+func _SlaveVirshService_MigrateVM_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(MigrateVmRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SlaveVirshServiceServer).MigrateVM(m, &grpc.GenericServerStream[MigrateVmRequest, MigrationProgress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SlaveVirshService_MigrateVMServer = grpc.ServerStreamingServer[MigrationProgress]
+
+func _SlaveVirshService_CancelMigration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelMigrationRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveVirshServiceServer).MigrateVM(ctx, in)
+		return srv.(SlaveVirshServiceServer).CancelMigration(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: SlaveVirshService_MigrateVM_FullMethodName,
+		FullMethod: SlaveVirshService_CancelMigration_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveVirshServiceServer).MigrateVM(ctx, req.(*MigrateVmRequest))
+		return srv.(SlaveVirshServiceServer).CancelMigration(ctx, req.(*CancelMigrationRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -1254,6 +1657,24 @@ func _SlaveVirshService_UndefineVM_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SlaveVirshService_UndefineStaleVM_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Vm)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).UndefineStaleVM(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_UndefineStaleVM_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).UndefineStaleVM(ctx, req.(*Vm))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SlaveVirshService_GetAllVms_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Empty)
 	if err := dec(in); err != nil {
@@ -1290,6 +1711,24 @@ func _SlaveVirshService_GetVmByName_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SlaveVirshService_GetVmStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).GetVmStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_GetVmStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).GetVmStats(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SlaveVirshService_RemoveIsoFromVm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Vm)
 	if err := dec(in); err != nil {
@@ -1632,6 +2071,24 @@ func _SlaveVirshService_ColdMigrateVm_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SlaveVirshService_DefineVMFromXML_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DefineVMFromXMLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).DefineVMFromXML(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_DefineVMFromXML_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).DefineVMFromXML(ctx, req.(*DefineVMFromXMLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SlaveVirshService_FreezeDisk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Vm)
 	if err := dec(in); err != nil {
@@ -1668,6 +2125,305 @@ func _SlaveVirshService_UnFreezeDisk_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SlaveVirshService_GuestAgentPing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVmByNameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).GuestAgentPing(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_GuestAgentPing_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).GuestAgentPing(ctx, req.(*GetVmByNameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_CheckTCPPort_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TCPPortRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).CheckTCPPort(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_CheckTCPPort_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).CheckTCPPort(ctx, req.(*TCPPortRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_CreateSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).CreateSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_CreateSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).CreateSnapshot(ctx, req.(*CreateSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_ListSnapshots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVmByNameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).ListSnapshots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_ListSnapshots_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).ListSnapshots(ctx, req.(*GetVmByNameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_RevertSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).RevertSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_RevertSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).RevertSnapshot(ctx, req.(*SnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_DeleteSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).DeleteSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_DeleteSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).DeleteSnapshot(ctx, req.(*SnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_BackupDisk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BackupDiskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).BackupDisk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_BackupDisk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).BackupDisk(ctx, req.(*BackupDiskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_FlattenBackupChain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FlattenBackupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).FlattenBackupChain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_FlattenBackupChain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).FlattenBackupChain(ctx, req.(*FlattenBackupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_CheckDiskImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckDiskImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).CheckDiskImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_CheckDiskImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).CheckDiskImage(ctx, req.(*CheckDiskImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_TestBootBackup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TestBootRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).TestBootBackup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_TestBootBackup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).TestBootBackup(ctx, req.(*TestBootRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_WriteScratchImage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SlaveVirshServiceServer).WriteScratchImage(&grpc.GenericServerStream[ScratchChunk, ScratchImage]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SlaveVirshService_WriteScratchImageServer = grpc.ClientStreamingServer[ScratchChunk, ScratchImage]
+
+func _SlaveVirshService_RemoveScratchImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScratchImage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).RemoveScratchImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_RemoveScratchImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).RemoveScratchImage(ctx, req.(*ScratchImage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_MountBackupImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FileRestoreMountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).MountBackupImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_MountBackupImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).MountBackupImage(ctx, req.(*FileRestoreMountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_BrowseBackupImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FileRestoreBrowseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).BrowseBackupImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_BrowseBackupImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).BrowseBackupImage(ctx, req.(*FileRestoreBrowseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_ReadBackupImageFiles_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FileRestoreReadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SlaveVirshServiceServer).ReadBackupImageFiles(m, &grpc.GenericServerStream[FileRestoreReadRequest, FileChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SlaveVirshService_ReadBackupImageFilesServer = grpc.ServerStreamingServer[FileChunk]
+
+func _SlaveVirshService_UnmountBackupImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FileRestoreSession)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).UnmountBackupImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_UnmountBackupImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).UnmountBackupImage(ctx, req.(*FileRestoreSession))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_CleanupFileRestores_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveVirshServiceServer).CleanupFileRestores(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlaveVirshService_CleanupFileRestores_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveVirshServiceServer).CleanupFileRestores(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveVirshService_BlockCopyDisk_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BlockCopyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SlaveVirshServiceServer).BlockCopyDisk(m, &grpc.GenericServerStream[BlockCopyRequest, BlockCopyProgress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SlaveVirshService_BlockCopyDiskServer = grpc.ServerStreamingServer[BlockCopyProgress]
+
 func _SlaveVirshService_ChangeVmPassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ChangeVncPassword)
 	if err := dec(in); err != nil {
@@ -1992,8 +2748,8 @@ var SlaveVirshService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _SlaveVirshService_CreateVm_Handler,
 		},
 		{
-			MethodName: "MigrateVM",
-			Handler:    _SlaveVirshService_MigrateVM_Handler,
+			MethodName: "CancelMigration",
+			Handler:    _SlaveVirshService_CancelMigration_Handler,
 		},
 		{
 			MethodName: "ShutdownVM",
@@ -2027,6 +2783,10 @@ var SlaveVirshService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UndefineVM",
 			Handler:    _SlaveVirshService_UndefineVM_Handler,
 		},
+		{
+			MethodName: "UndefineStaleVM",
+			Handler:    _SlaveVirshService_UndefineStaleVM_Handler,
+		},
 		{
 			MethodName: "GetAllVms",
 			Handler:    _SlaveVirshService_GetAllVms_Handler,
@@ -2035,6 +2795,10 @@ var SlaveVirshService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetVmByName",
 			Handler:    _SlaveVirshService_GetVmByName_Handler,
 		},
+		{
+			MethodName: "GetVmStats",
+			Handler:    _SlaveVirshService_GetVmStats_Handler,
+		},
 		{
 			MethodName: "RemoveIsoFromVm",
 			Handler:    _SlaveVirshService_RemoveIsoFromVm_Handler,
@@ -2111,6 +2875,10 @@ var SlaveVirshService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ColdMigrateVm",
 			Handler:    _SlaveVirshService_ColdMigrateVm_Handler,
 		},
+		{
+			MethodName: "DefineVMFromXML",
+			Handler:    _SlaveVirshService_DefineVMFromXML_Handler,
+		},
 		{
 			MethodName: "FreezeDisk",
 			Handler:    _SlaveVirshService_FreezeDisk_Handler,
@@ -2119,6 +2887,66 @@ var SlaveVirshService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UnFreezeDisk",
 			Handler:    _SlaveVirshService_UnFreezeDisk_Handler,
 		},
+		{
+			MethodName: "GuestAgentPing",
+			Handler:    _SlaveVirshService_GuestAgentPing_Handler,
+		},
+		{
+			MethodName: "CheckTCPPort",
+			Handler:    _SlaveVirshService_CheckTCPPort_Handler,
+		},
+		{
+			MethodName: "CreateSnapshot",
+			Handler:    _SlaveVirshService_CreateSnapshot_Handler,
+		},
+		{
+			MethodName: "ListSnapshots",
+			Handler:    _SlaveVirshService_ListSnapshots_Handler,
+		},
+		{
+			MethodName: "RevertSnapshot",
+			Handler:    _SlaveVirshService_RevertSnapshot_Handler,
+		},
+		{
+			MethodName: "DeleteSnapshot",
+			Handler:    _SlaveVirshService_DeleteSnapshot_Handler,
+		},
+		{
+			MethodName: "BackupDisk",
+			Handler:    _SlaveVirshService_BackupDisk_Handler,
+		},
+		{
+			MethodName: "FlattenBackupChain",
+			Handler:    _SlaveVirshService_FlattenBackupChain_Handler,
+		},
+		{
+			MethodName: "CheckDiskImage",
+			Handler:    _SlaveVirshService_CheckDiskImage_Handler,
+		},
+		{
+			MethodName: "TestBootBackup",
+			Handler:    _SlaveVirshService_TestBootBackup_Handler,
+		},
+		{
+			MethodName: "RemoveScratchImage",
+			Handler:    _SlaveVirshService_RemoveScratchImage_Handler,
+		},
+		{
+			MethodName: "MountBackupImage",
+			Handler:    _SlaveVirshService_MountBackupImage_Handler,
+		},
+		{
+			MethodName: "BrowseBackupImage",
+			Handler:    _SlaveVirshService_BrowseBackupImage_Handler,
+		},
+		{
+			MethodName: "UnmountBackupImage",
+			Handler:    _SlaveVirshService_UnmountBackupImage_Handler,
+		},
+		{
+			MethodName: "CleanupFileRestores",
+			Handler:    _SlaveVirshService_CleanupFileRestores_Handler,
+		},
 		{
 			MethodName: "ChangeVmPassword",
 			Handler:    _SlaveVirshService_ChangeVmPassword_Handler,
@@ -2184,6 +3012,27 @@ var SlaveVirshService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _SlaveVirshService_RemoveHostHugePages_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "MigrateVM",
+			Handler:       _SlaveVirshService_MigrateVM_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WriteScratchImage",
+			Handler:       _SlaveVirshService_WriteScratchImage_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ReadBackupImageFiles",
+			Handler:       _SlaveVirshService_ReadBackupImageFiles_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "BlockCopyDisk",
+			Handler:       _SlaveVirshService_BlockCopyDisk_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "virsh.proto",
 }
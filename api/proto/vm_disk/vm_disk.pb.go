@@ -201,6 +201,186 @@ func (x *GrowVMDiskRequest) GetSizeGB() int64 {
 	return 0
 }
 
+type ImportVMDiskRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BasePath      string                 `protobuf:"bytes,1,opt,name=basePath,proto3" json:"basePath,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Url           string                 `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
+	Format        string                 `protobuf:"bytes,4,opt,name=format,proto3" json:"format,omitempty"`             // target format, qcow2 or raw
+	SourceFormat  string                 `protobuf:"bytes,5,opt,name=sourceFormat,proto3" json:"sourceFormat,omitempty"` // optional, qcow2, raw, vmdk or vhdx (probed when empty)
+	Checksum      string                 `protobuf:"bytes,6,opt,name=checksum,proto3" json:"checksum,omitempty"`         // optional, sha256:<hex> or sha512:<hex>
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportVMDiskRequest) Reset() {
+	*x = ImportVMDiskRequest{}
+	mi := &file_vm_disk_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportVMDiskRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportVMDiskRequest) ProtoMessage() {}
+
+func (x *ImportVMDiskRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vm_disk_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportVMDiskRequest.ProtoReflect.Descriptor instead.
+func (*ImportVMDiskRequest) Descriptor() ([]byte, []int) {
+	return file_vm_disk_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ImportVMDiskRequest) GetBasePath() string {
+	if x != nil {
+		return x.BasePath
+	}
+	return ""
+}
+
+func (x *ImportVMDiskRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ImportVMDiskRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *ImportVMDiskRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *ImportVMDiskRequest) GetSourceFormat() string {
+	if x != nil {
+		return x.SourceFormat
+	}
+	return ""
+}
+
+func (x *ImportVMDiskRequest) GetChecksum() string {
+	if x != nil {
+		return x.Checksum
+	}
+	return ""
+}
+
+type CreateOverlayDiskRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BackingPath   string                 `protobuf:"bytes,1,opt,name=backingPath,proto3" json:"backingPath,omitempty"`
+	DiskPath      string                 `protobuf:"bytes,2,opt,name=diskPath,proto3" json:"diskPath,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateOverlayDiskRequest) Reset() {
+	*x = CreateOverlayDiskRequest{}
+	mi := &file_vm_disk_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateOverlayDiskRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateOverlayDiskRequest) ProtoMessage() {}
+
+func (x *CreateOverlayDiskRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vm_disk_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateOverlayDiskRequest.ProtoReflect.Descriptor instead.
+func (*CreateOverlayDiskRequest) Descriptor() ([]byte, []int) {
+	return file_vm_disk_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CreateOverlayDiskRequest) GetBackingPath() string {
+	if x != nil {
+		return x.BackingPath
+	}
+	return ""
+}
+
+func (x *CreateOverlayDiskRequest) GetDiskPath() string {
+	if x != nil {
+		return x.DiskPath
+	}
+	return ""
+}
+
+type VMDiskByPathRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DiskPath      string                 `protobuf:"bytes,1,opt,name=diskPath,proto3" json:"diskPath,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VMDiskByPathRequest) Reset() {
+	*x = VMDiskByPathRequest{}
+	mi := &file_vm_disk_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VMDiskByPathRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VMDiskByPathRequest) ProtoMessage() {}
+
+func (x *VMDiskByPathRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vm_disk_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VMDiskByPathRequest.ProtoReflect.Descriptor instead.
+func (*VMDiskByPathRequest) Descriptor() ([]byte, []int) {
+	return file_vm_disk_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *VMDiskByPathRequest) GetDiskPath() string {
+	if x != nil {
+		return x.DiskPath
+	}
+	return ""
+}
+
 type VMDiskResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
@@ -216,7 +396,7 @@ type VMDiskResponse struct {
 
 func (x *VMDiskResponse) Reset() {
 	*x = VMDiskResponse{}
-	mi := &file_vm_disk_proto_msgTypes[3]
+	mi := &file_vm_disk_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -228,7 +408,7 @@ func (x *VMDiskResponse) String() string {
 func (*VMDiskResponse) ProtoMessage() {}
 
 func (x *VMDiskResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_vm_disk_proto_msgTypes[3]
+	mi := &file_vm_disk_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -241,7 +421,7 @@ func (x *VMDiskResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use VMDiskResponse.ProtoReflect.Descriptor instead.
 func (*VMDiskResponse) Descriptor() ([]byte, []int) {
-	return file_vm_disk_proto_rawDescGZIP(), []int{3}
+	return file_vm_disk_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *VMDiskResponse) GetOk() bool {
@@ -309,7 +489,19 @@ const file_vm_disk_proto_rawDesc = "" +
 	"\x11GrowVMDiskRequest\x12\x1a\n" +
 	"\bbasePath\x18\x01 \x01(\tR\bbasePath\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
-	"\x06sizeGB\x18\x03 \x01(\x03R\x06sizeGB\"\xd2\x01\n" +
+	"\x06sizeGB\x18\x03 \x01(\x03R\x06sizeGB\"\xaf\x01\n" +
+	"\x13ImportVMDiskRequest\x12\x1a\n" +
+	"\bbasePath\x18\x01 \x01(\tR\bbasePath\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x10\n" +
+	"\x03url\x18\x03 \x01(\tR\x03url\x12\x16\n" +
+	"\x06format\x18\x04 \x01(\tR\x06format\x12\"\n" +
+	"\fsourceFormat\x18\x05 \x01(\tR\fsourceFormat\x12\x1a\n" +
+	"\bchecksum\x18\x06 \x01(\tR\bchecksum\"X\n" +
+	"\x18CreateOverlayDiskRequest\x12 \n" +
+	"\vbackingPath\x18\x01 \x01(\tR\vbackingPath\x12\x1a\n" +
+	"\bdiskPath\x18\x02 \x01(\tR\bdiskPath\"1\n" +
+	"\x13VMDiskByPathRequest\x12\x1a\n" +
+	"\bdiskPath\x18\x01 \x01(\tR\bdiskPath\"\xd2\x01\n" +
 	"\x0eVMDiskResponse\x12\x0e\n" +
 	"\x02ok\x18\x01 \x01(\bR\x02ok\x12\x1a\n" +
 	"\bdiskPath\x18\x02 \x01(\tR\bdiskPath\x12\x1e\n" +
@@ -321,13 +513,16 @@ const file_vm_disk_proto_rawDesc = "" +
 	"\n" +
 	"occupiedGB\x18\x06 \x01(\x01R\n" +
 	"occupiedGB\x12$\n" +
-	"\roccupiedBytes\x18\a \x01(\x03R\roccupiedBytes2\xa8\x02\n" +
+	"\roccupiedBytes\x18\a \x01(\x03R\roccupiedBytes2\x86\x04\n" +
 	"\rVMDiskService\x12E\n" +
 	"\fCreateVMDisk\x12\x1c.vm_disk.CreateVMDiskRequest\x1a\x17.vm_disk.VMDiskResponse\x12E\n" +
 	"\fDeleteVMDisk\x12\x1c.vm_disk.VMDiskByNameRequest\x1a\x17.vm_disk.VMDiskResponse\x12A\n" +
 	"\n" +
 	"GrowVMDisk\x12\x1a.vm_disk.GrowVMDiskRequest\x1a\x17.vm_disk.VMDiskResponse\x12F\n" +
-	"\rGetVMDiskInfo\x12\x1c.vm_disk.VMDiskByNameRequest\x1a\x17.vm_disk.VMDiskResponseB5Z3github.com/Maruqes/512SvMan/api/proto/vm_disk;protob\x06proto3"
+	"\rGetVMDiskInfo\x12\x1c.vm_disk.VMDiskByNameRequest\x1a\x17.vm_disk.VMDiskResponse\x12E\n" +
+	"\fImportVMDisk\x12\x1c.vm_disk.ImportVMDiskRequest\x1a\x17.vm_disk.VMDiskResponse\x12O\n" +
+	"\x11CreateOverlayDisk\x12!.vm_disk.CreateOverlayDiskRequest\x1a\x17.vm_disk.VMDiskResponse\x12D\n" +
+	"\vFlattenDisk\x12\x1c.vm_disk.VMDiskByPathRequest\x1a\x17.vm_disk.VMDiskResponseB5Z3github.com/Maruqes/512SvMan/api/proto/vm_disk;protob\x06proto3"
 
 var (
 	file_vm_disk_proto_rawDescOnce sync.Once
@@ -341,24 +536,33 @@ func file_vm_disk_proto_rawDescGZIP() []byte {
 	return file_vm_disk_proto_rawDescData
 }
 
-var file_vm_disk_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_vm_disk_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_vm_disk_proto_goTypes = []any{
-	(*CreateVMDiskRequest)(nil), // 0: vm_disk.CreateVMDiskRequest
-	(*VMDiskByNameRequest)(nil), // 1: vm_disk.VMDiskByNameRequest
-	(*GrowVMDiskRequest)(nil),   // 2: vm_disk.GrowVMDiskRequest
-	(*VMDiskResponse)(nil),      // 3: vm_disk.VMDiskResponse
+	(*CreateVMDiskRequest)(nil),      // 0: vm_disk.CreateVMDiskRequest
+	(*VMDiskByNameRequest)(nil),      // 1: vm_disk.VMDiskByNameRequest
+	(*GrowVMDiskRequest)(nil),        // 2: vm_disk.GrowVMDiskRequest
+	(*ImportVMDiskRequest)(nil),      // 3: vm_disk.ImportVMDiskRequest
+	(*CreateOverlayDiskRequest)(nil), // 4: vm_disk.CreateOverlayDiskRequest
+	(*VMDiskByPathRequest)(nil),      // 5: vm_disk.VMDiskByPathRequest
+	(*VMDiskResponse)(nil),           // 6: vm_disk.VMDiskResponse
 }
 var file_vm_disk_proto_depIdxs = []int32{
 	0, // 0: vm_disk.VMDiskService.CreateVMDisk:input_type -> vm_disk.CreateVMDiskRequest
 	1, // 1: vm_disk.VMDiskService.DeleteVMDisk:input_type -> vm_disk.VMDiskByNameRequest
 	2, // 2: vm_disk.VMDiskService.GrowVMDisk:input_type -> vm_disk.GrowVMDiskRequest
 	1, // 3: vm_disk.VMDiskService.GetVMDiskInfo:input_type -> vm_disk.VMDiskByNameRequest
-	3, // 4: vm_disk.VMDiskService.CreateVMDisk:output_type -> vm_disk.VMDiskResponse
-	3, // 5: vm_disk.VMDiskService.DeleteVMDisk:output_type -> vm_disk.VMDiskResponse
-	3, // 6: vm_disk.VMDiskService.GrowVMDisk:output_type -> vm_disk.VMDiskResponse
-	3, // 7: vm_disk.VMDiskService.GetVMDiskInfo:output_type -> vm_disk.VMDiskResponse
-	4, // [4:8] is the sub-list for method output_type
-	0, // [0:4] is the sub-list for method input_type
+	3, // 4: vm_disk.VMDiskService.ImportVMDisk:input_type -> vm_disk.ImportVMDiskRequest
+	4, // 5: vm_disk.VMDiskService.CreateOverlayDisk:input_type -> vm_disk.CreateOverlayDiskRequest
+	5, // 6: vm_disk.VMDiskService.FlattenDisk:input_type -> vm_disk.VMDiskByPathRequest
+	6, // 7: vm_disk.VMDiskService.CreateVMDisk:output_type -> vm_disk.VMDiskResponse
+	6, // 8: vm_disk.VMDiskService.DeleteVMDisk:output_type -> vm_disk.VMDiskResponse
+	6, // 9: vm_disk.VMDiskService.GrowVMDisk:output_type -> vm_disk.VMDiskResponse
+	6, // 10: vm_disk.VMDiskService.GetVMDiskInfo:output_type -> vm_disk.VMDiskResponse
+	6, // 11: vm_disk.VMDiskService.ImportVMDisk:output_type -> vm_disk.VMDiskResponse
+	6, // 12: vm_disk.VMDiskService.CreateOverlayDisk:output_type -> vm_disk.VMDiskResponse
+	6, // 13: vm_disk.VMDiskService.FlattenDisk:output_type -> vm_disk.VMDiskResponse
+	7, // [7:14] is the sub-list for method output_type
+	0, // [0:7] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -375,7 +579,7 @@ func file_vm_disk_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_vm_disk_proto_rawDesc), len(file_vm_disk_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
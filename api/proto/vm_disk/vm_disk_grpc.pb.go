@@ -19,10 +19,13 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	VMDiskService_CreateVMDisk_FullMethodName  = "/vm_disk.VMDiskService/CreateVMDisk"
-	VMDiskService_DeleteVMDisk_FullMethodName  = "/vm_disk.VMDiskService/DeleteVMDisk"
-	VMDiskService_GrowVMDisk_FullMethodName    = "/vm_disk.VMDiskService/GrowVMDisk"
-	VMDiskService_GetVMDiskInfo_FullMethodName = "/vm_disk.VMDiskService/GetVMDiskInfo"
+	VMDiskService_CreateVMDisk_FullMethodName      = "/vm_disk.VMDiskService/CreateVMDisk"
+	VMDiskService_DeleteVMDisk_FullMethodName      = "/vm_disk.VMDiskService/DeleteVMDisk"
+	VMDiskService_GrowVMDisk_FullMethodName        = "/vm_disk.VMDiskService/GrowVMDisk"
+	VMDiskService_GetVMDiskInfo_FullMethodName     = "/vm_disk.VMDiskService/GetVMDiskInfo"
+	VMDiskService_ImportVMDisk_FullMethodName      = "/vm_disk.VMDiskService/ImportVMDisk"
+	VMDiskService_CreateOverlayDisk_FullMethodName = "/vm_disk.VMDiskService/CreateOverlayDisk"
+	VMDiskService_FlattenDisk_FullMethodName       = "/vm_disk.VMDiskService/FlattenDisk"
 )
 
 // VMDiskServiceClient is the client API for VMDiskService service.
@@ -33,6 +36,9 @@ type VMDiskServiceClient interface {
 	DeleteVMDisk(ctx context.Context, in *VMDiskByNameRequest, opts ...grpc.CallOption) (*VMDiskResponse, error)
 	GrowVMDisk(ctx context.Context, in *GrowVMDiskRequest, opts ...grpc.CallOption) (*VMDiskResponse, error)
 	GetVMDiskInfo(ctx context.Context, in *VMDiskByNameRequest, opts ...grpc.CallOption) (*VMDiskResponse, error)
+	ImportVMDisk(ctx context.Context, in *ImportVMDiskRequest, opts ...grpc.CallOption) (*VMDiskResponse, error)
+	CreateOverlayDisk(ctx context.Context, in *CreateOverlayDiskRequest, opts ...grpc.CallOption) (*VMDiskResponse, error)
+	FlattenDisk(ctx context.Context, in *VMDiskByPathRequest, opts ...grpc.CallOption) (*VMDiskResponse, error)
 }
 
 type vMDiskServiceClient struct {
@@ -83,6 +89,36 @@ func (c *vMDiskServiceClient) GetVMDiskInfo(ctx context.Context, in *VMDiskByNam
 	return out, nil
 }
 
+func (c *vMDiskServiceClient) ImportVMDisk(ctx context.Context, in *ImportVMDiskRequest, opts ...grpc.CallOption) (*VMDiskResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VMDiskResponse)
+	err := c.cc.Invoke(ctx, VMDiskService_ImportVMDisk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMDiskServiceClient) CreateOverlayDisk(ctx context.Context, in *CreateOverlayDiskRequest, opts ...grpc.CallOption) (*VMDiskResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VMDiskResponse)
+	err := c.cc.Invoke(ctx, VMDiskService_CreateOverlayDisk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMDiskServiceClient) FlattenDisk(ctx context.Context, in *VMDiskByPathRequest, opts ...grpc.CallOption) (*VMDiskResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VMDiskResponse)
+	err := c.cc.Invoke(ctx, VMDiskService_FlattenDisk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // VMDiskServiceServer is the server API for VMDiskService service.
 // All implementations must embed UnimplementedVMDiskServiceServer
 // for forward compatibility.
@@ -91,6 +127,9 @@ type VMDiskServiceServer interface {
 	DeleteVMDisk(context.Context, *VMDiskByNameRequest) (*VMDiskResponse, error)
 	GrowVMDisk(context.Context, *GrowVMDiskRequest) (*VMDiskResponse, error)
 	GetVMDiskInfo(context.Context, *VMDiskByNameRequest) (*VMDiskResponse, error)
+	ImportVMDisk(context.Context, *ImportVMDiskRequest) (*VMDiskResponse, error)
+	CreateOverlayDisk(context.Context, *CreateOverlayDiskRequest) (*VMDiskResponse, error)
+	FlattenDisk(context.Context, *VMDiskByPathRequest) (*VMDiskResponse, error)
 	mustEmbedUnimplementedVMDiskServiceServer()
 }
 
@@ -113,6 +152,15 @@ func (UnimplementedVMDiskServiceServer) GrowVMDisk(context.Context, *GrowVMDiskR
 func (UnimplementedVMDiskServiceServer) GetVMDiskInfo(context.Context, *VMDiskByNameRequest) (*VMDiskResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetVMDiskInfo not implemented")
 }
+func (UnimplementedVMDiskServiceServer) ImportVMDisk(context.Context, *ImportVMDiskRequest) (*VMDiskResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportVMDisk not implemented")
+}
+func (UnimplementedVMDiskServiceServer) CreateOverlayDisk(context.Context, *CreateOverlayDiskRequest) (*VMDiskResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateOverlayDisk not implemented")
+}
+func (UnimplementedVMDiskServiceServer) FlattenDisk(context.Context, *VMDiskByPathRequest) (*VMDiskResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FlattenDisk not implemented")
+}
 func (UnimplementedVMDiskServiceServer) mustEmbedUnimplementedVMDiskServiceServer() {}
 func (UnimplementedVMDiskServiceServer) testEmbeddedByValue()                       {}
 
@@ -206,6 +254,60 @@ func _VMDiskService_GetVMDiskInfo_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VMDiskService_ImportVMDisk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportVMDiskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMDiskServiceServer).ImportVMDisk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VMDiskService_ImportVMDisk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMDiskServiceServer).ImportVMDisk(ctx, req.(*ImportVMDiskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMDiskService_CreateOverlayDisk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateOverlayDiskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMDiskServiceServer).CreateOverlayDisk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VMDiskService_CreateOverlayDisk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMDiskServiceServer).CreateOverlayDisk(ctx, req.(*CreateOverlayDiskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMDiskService_FlattenDisk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VMDiskByPathRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMDiskServiceServer).FlattenDisk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VMDiskService_FlattenDisk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMDiskServiceServer).FlattenDisk(ctx, req.(*VMDiskByPathRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // VMDiskService_ServiceDesc is the grpc.ServiceDesc for VMDiskService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -229,6 +331,18 @@ var VMDiskService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetVMDiskInfo",
 			Handler:    _VMDiskService_GetVMDiskInfo_Handler,
 		},
+		{
+			MethodName: "ImportVMDisk",
+			Handler:    _VMDiskService_ImportVMDisk_Handler,
+		},
+		{
+			MethodName: "CreateOverlayDisk",
+			Handler:    _VMDiskService_CreateOverlayDisk_Handler,
+		},
+		{
+			MethodName: "FlattenDisk",
+			Handler:    _VMDiskService_FlattenDisk_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "vm_disk.proto",
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"512SvMan/db"
+	"512SvMan/services"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type principalCtxKey struct{}
+
+func setPrincipalInContext(r *http.Request, principal *services.Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalCtxKey{}, principal))
+}
+
+// PrincipalFromContext returns the user of an authenticated request.
+func PrincipalFromContext(r *http.Request) *services.Principal {
+	principal, _ := r.Context().Value(principalCtxKey{}).(*services.Principal)
+	return principal
+}
+
+// routes any logged in user can use
+var commonRoutes = map[string]bool{
+	"new-slave-count": true,
+	"notification":    true,
+	"sw.js":           true,
+	"protected":       true,
+	"ws":              true,
+	"nots":            true,
+	"notes":           true,
+	"me":              true,
+	"logout":          true,
+	"tokens":          true,
+}
+
+// resourceByRoute maps the first path segment to the resource it manages,
+// unknown segments are treated as system routes.
+var resourceByRoute = map[string]string{
+	"virsh":        services.ResourceVMs,
+	"vm-disk":      services.ResourceVMs,
+	"isos":         services.ResourceVMs,
+	"nfs":          services.ResourceNFS,
+	"btrfs":        services.ResourceBTRFS,
+	"docker":       services.ResourceDocker,
+	"k8s":          services.ResourceDocker,
+	"proxy":        services.ResourceNPM,
+	"404":          services.ResourceNPM,
+	"stream":       services.ResourceNPM,
+	"redirection":  services.ResourceNPM,
+	"certs":        services.ResourceNPM,
+	"access-lists": services.ResourceNPM,
+	"streamInfo":   services.ResourceNPM,
+	"goaccess":     services.ResourceNPM,
+	"wireguard":    services.ResourceWireguard,
+	"dnsmasq":      services.ResourceWireguard,
+	"protocol":     services.ResourceCluster,
+	"maintenance":  services.ResourceCluster,
+	"balancer":     services.ResourceCluster,
+	"users":        services.ResourceUsers,
+	"info":         services.ResourceSystem,
+	"extra":        services.ResourceSystem,
+	"logs":         services.ResourceSystem,
+	"pci":          services.ResourceSystem,
+	"smartdisk":    services.ResourceSystem,
+	"jobs":         services.ResourceSystem,
+	"spa":          services.ResourceSystem,
+}
+
+// the url param naming a single resource of each type
+var resourceNameParam = map[string]string{
+	services.ResourceVMs:       "vm_name",
+	services.ResourceNFS:       "id",
+	services.ResourceBTRFS:     "machine_name",
+	services.ResourceDocker:    "machineName",
+	services.ResourceNPM:       "id",
+	services.ResourceWireguard: "id",
+}
+
+// requestResource finds which resource a request touches. The middleware runs
+// before the subrouters, so the route is matched here to read its params.
+func requestResource(r *http.Request) (resourceType, name string) {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if commonRoutes[segment] {
+		return "", ""
+	}
+	resourceType, ok := resourceByRoute[segment]
+	if !ok {
+		return services.ResourceSystem, ""
+	}
+
+	param := resourceNameParam[resourceType]
+	if param == "" {
+		return resourceType, ""
+	}
+	return resourceType, matchURLParam(r, param)
+}
+
+// matchURLParam reads a url param from middleware that runs before the
+// subrouters had a chance to set it.
+func matchURLParam(r *http.Request, param string) string {
+	if value := chi.URLParam(r, param); value != "" {
+		return value
+	}
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+	match := chi.NewRouteContext()
+	if !rctx.Routes.Match(match, r.Method, r.URL.Path) {
+		return ""
+	}
+	return match.URLParam(param)
+}
+
+func requestAccess(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return db.AccessRead
+	}
+	return db.AccessWrite
+}
+
+// canAccess is used by handlers that need to check a resource the route does
+// not name, like the items of a list.
+func canAccess(r *http.Request, resourceType, name, access string) bool {
+	return PrincipalFromContext(r).Can(resourceType, name, access)
+}
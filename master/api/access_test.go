@@ -0,0 +1,174 @@
+package api
+
+import (
+	"512SvMan/db"
+	"512SvMan/services"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRequestResourceReadsParamsBeforeSubrouters(t *testing.T) {
+	type seen struct{ resourceType, name string }
+	var got seen
+
+	r := chi.NewRouter()
+	r.Group(func(r chi.Router) {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				got.resourceType, got.name = requestResource(req)
+				next.ServeHTTP(w, req)
+			})
+		})
+		noop := func(http.ResponseWriter, *http.Request) {}
+		r.Route("/virsh", func(r chi.Router) {
+			r.Post("/startvm/{vm_name}", noop)
+			r.Get("/history/{vm_name}", noop)
+			r.Route("/snapshots/{vm_name}", func(r chi.Router) {
+				r.Get("/", noop)
+			})
+		})
+		r.Route("/docker", func(r chi.Router) {
+			r.Route("/containers", func(r chi.Router) {
+				r.Post("/stop/{machineName}", noop)
+			})
+		})
+		r.Get("/notes", noop)
+		r.Get("/mystery", noop)
+	})
+
+	cases := []struct {
+		method, path string
+		want         seen
+	}{
+		{http.MethodPost, "/virsh/startvm/web1", seen{services.ResourceVMs, "web1"}},
+		{http.MethodGet, "/virsh/snapshots/db1/", seen{services.ResourceVMs, "db1"}},
+		{http.MethodGet, "/virsh/history/web1", seen{services.ResourceVMs, "web1"}},
+		{http.MethodPost, "/docker/containers/stop/node2", seen{services.ResourceDocker, "node2"}},
+		{http.MethodGet, "/notes", seen{"", ""}},
+		{http.MethodGet, "/mystery", seen{services.ResourceSystem, ""}},
+	}
+	for _, tc := range cases {
+		got = seen{}
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+		if got != tc.want {
+			t.Fatalf("%s %s: got %+v, want %+v", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestPrincipalCan(t *testing.T) {
+	owner := &services.Principal{
+		User: db.User{Username: "bob", Role: db.RoleVMOwner},
+		Grants: []db.UserGrant{
+			{ResourceType: services.ResourceVMs, ResourceName: "web1", Access: db.AccessWrite},
+			{ResourceType: services.ResourceVMs, ResourceName: "db1", Access: db.AccessRead},
+			{ResourceType: services.ResourceNFS, ResourceName: "*", Access: db.AccessRead},
+		},
+	}
+	viewer := &services.Principal{User: db.User{Username: "eve", Role: db.RoleViewer}}
+	operator := &services.Principal{User: db.User{Username: "op", Role: db.RoleOperator}}
+	admin := &services.Principal{User: db.User{Username: "root", Role: db.RoleAdmin}}
+	disabled := &services.Principal{User: db.User{Username: "old", Role: db.RoleAdmin, Disabled: true}}
+
+	cases := []struct {
+		name      string
+		principal *services.Principal
+		resource  string
+		item      string
+		access    string
+		want      bool
+	}{
+		{"owner writes granted vm", owner, services.ResourceVMs, "web1", db.AccessWrite, true},
+		{"owner cannot write read grant", owner, services.ResourceVMs, "db1", db.AccessWrite, false},
+		{"owner cannot see other vm", owner, services.ResourceVMs, "other", db.AccessRead, false},
+		{"owner lists vms", owner, services.ResourceVMs, "", db.AccessRead, true},
+		{"owner cannot create vms", owner, services.ResourceVMs, "", db.AccessWrite, false},
+		{"owner reads every nfs", owner, services.ResourceNFS, "3", db.AccessRead, true},
+		{"owner has no docker", owner, services.ResourceDocker, "", db.AccessRead, false},
+		{"viewer reads", viewer, services.ResourceWireguard, "", db.AccessRead, true},
+		{"viewer cannot write", viewer, services.ResourceVMs, "web1", db.AccessWrite, false},
+		{"viewer cannot see users", viewer, services.ResourceUsers, "", db.AccessRead, false},
+		{"operator writes vms", operator, services.ResourceVMs, "web1", db.AccessWrite, true},
+		{"operator cannot approve nodes", operator, services.ResourceCluster, "", db.AccessWrite, false},
+		{"admin manages users", admin, services.ResourceUsers, "", db.AccessWrite, true},
+		{"disabled user", disabled, services.ResourceVMs, "", db.AccessRead, false},
+		{"no principal", nil, services.ResourceVMs, "", db.AccessRead, false},
+	}
+	for _, tc := range cases {
+		if got := tc.principal.Can(tc.resource, tc.item, tc.access); got != tc.want {
+			t.Fatalf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAPITokenNarrowsPrincipal(t *testing.T) {
+	ci := &services.Principal{
+		User: db.User{Username: "root", Role: db.RoleAdmin},
+		Token: &db.APIToken{
+			Scopes: []string{"vms:write", "docker:read"},
+			Routes: []string{"POST /virsh/startvm/*", "/docker/*"},
+		},
+	}
+
+	if !ci.Can(services.ResourceVMs, "web1", db.AccessWrite) {
+		t.Fatalf("expected token to write vms")
+	}
+	if ci.Can(services.ResourceDocker, "node1", db.AccessWrite) {
+		t.Fatalf("expected docker to be read only")
+	}
+	if ci.Can(services.ResourceUsers, "", db.AccessRead) || ci.Can(services.ResourceNFS, "", db.AccessRead) {
+		t.Fatalf("expected token to be limited to its scopes")
+	}
+
+	routes := []struct {
+		method, path string
+		want         bool
+	}{
+		{http.MethodPost, "/virsh/startvm/web1", true},
+		{http.MethodPost, "/virsh/deletevm/web1", false},
+		{http.MethodGet, "/virsh/startvm/web1", false},
+		{http.MethodGet, "/docker/containers/node1", true},
+	}
+	for _, tc := range routes {
+		if got := ci.AllowsRoute(tc.method, tc.path); got != tc.want {
+			t.Fatalf("%s %s: got %v, want %v", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestRequestIPOnlyTrustsConfiguredProxies(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/24")
+	_, proxies6, _ := net.ParseCIDR("fd00::/64")
+	trusted := []*net.IPNet{proxies, proxies6}
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"direct client spoofing the header", "203.0.113.7:5000", map[string]string{"X-Forwarded-For": "192.0.2.1"}, "203.0.113.7"},
+		{"direct client spoofing x-real-ip", "203.0.113.7:5000", map[string]string{"X-Real-IP": "192.0.2.1"}, "203.0.113.7"},
+		{"ipv6 client", "[2001:db8::5]:443", nil, "2001:db8::5"},
+		{"ipv6 link local with zone", "[fe80::1%eth0]:443", nil, "fe80::1"},
+		{"trusted proxy", "10.0.0.2:80", map[string]string{"X-Forwarded-For": "198.51.100.4"}, "198.51.100.4"},
+		{"client prepending a fake hop", "10.0.0.2:80", map[string]string{"X-Forwarded-For": "192.0.2.1, 198.51.100.4"}, "198.51.100.4"},
+		{"chain of trusted proxies", "10.0.0.2:80", map[string]string{"X-Forwarded-For": "2001:db8::9, 10.0.0.3"}, "2001:db8::9"},
+		{"trusted ipv6 proxy with x-real-ip", "[fd00::2]:80", map[string]string{"X-Real-IP": "2001:db8::10"}, "2001:db8::10"},
+		{"trusted proxy without headers", "10.0.0.2:80", nil, "10.0.0.2"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		if got := requestIP(req, trusted); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want, got)
+		}
+	}
+}
@@ -29,8 +29,13 @@ var GetNewSlaveCountValue func() int = func() int { return 0 }
 // Handler to serve newSlaveCount
 func getNewSlaveCount(w http.ResponseWriter, r *http.Request) {
 	count := GetNewSlaveCountValue()
+	pending, err := db.CountNodesByState(r.Context(), db.NodeStatePending)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	fmt.Fprintf(w, `{"newSlaveCount": %d}`, count)
+	fmt.Fprintf(w, `{"newSlaveCount": %d, "pendingSlaveCount": %d}`, count, pending)
 }
 
 var upgrader = websocket.Upgrader{
@@ -153,7 +158,7 @@ func register_nots(w http.ResponseWriter, r *http.Request) {
 		SetCookieInBrowser(w, token, 3600)
 	}
 	loginService := services.LoginService{}
-	if !loginService.IsLoginValid(baseURL, token) {
+	if principal, err := loginService.Authenticate(r.Context(), token); err != nil || principal == nil {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -303,6 +308,8 @@ func StartApi(exitAfterStart bool) {
 		})
 
 		r.Get("/protected", protectedRoutes)
+		r.Get("/me", meHandler)
+		r.Post("/logout", logoutHandler)
 
 		r.Get("/ws", wsHandler)
 
@@ -317,7 +324,10 @@ func StartApi(exitAfterStart bool) {
 		setupPCIAPI(r)
 		setupNFSAPI(r)
 		setupVMDiskAPI(r)
+		setupJobsAPI(r)
 		setupProtocolAPI(r)
+		setupMaintenanceAPI(r)
+		setupBalancerAPI(r)
 		setupLogsAPI(r)
 		setupISOAPI(r)
 		setupExtraAPI(r)
@@ -333,6 +343,8 @@ func StartApi(exitAfterStart bool) {
 		setupDockerAPI(r)
 		setupK8sAPI(r)
 		setupSPAAPI(r)
+		setupUsersAPI(r)
+		setupAPITokensAPI(r)
 	})
 
 	go func() {
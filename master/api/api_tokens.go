@@ -0,0 +1,81 @@
+package api
+
+import (
+	"512SvMan/services"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// tokens and backup keys are managed from a login session, an api token
+// cannot mint or revoke other tokens nor export the keys
+func sessionPrincipal(w http.ResponseWriter, r *http.Request) (*services.Principal, bool) {
+	principal := PrincipalFromContext(r)
+	if principal == nil || principal.Token != nil {
+		http.Error(w, "this needs a login session, api tokens can't use it", http.StatusForbidden)
+		return nil, false
+	}
+	return principal, true
+}
+
+func listAPITokens(w http.ResponseWriter, r *http.Request) {
+	principal, ok := sessionPrincipal(w, r)
+	if !ok {
+		return
+	}
+	service := services.APITokenService{}
+	tokens, err := service.List(r.Context(), principal, r.URL.Query().Get("all") == "true")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, tokens)
+}
+
+func createAPIToken(w http.ResponseWriter, r *http.Request) {
+	principal, ok := sessionPrincipal(w, r)
+	if !ok {
+		return
+	}
+	var req services.APITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	service := services.APITokenService{}
+	token, err := service.Create(r.Context(), principal, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(token)
+}
+
+func revokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	principal, ok := sessionPrincipal(w, r)
+	if !ok {
+		return
+	}
+	id, ok := userIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+	service := services.APITokenService{}
+	if err := service.Revoke(r.Context(), principal, id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func setupAPITokensAPI(r chi.Router) chi.Router {
+	return r.Route("/tokens", func(r chi.Router) {
+		r.Get("/", listAPITokens)
+		r.Post("/", createAPIToken)
+		r.Delete("/{id}", revokeAPIToken)
+	})
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"512SvMan/db"
+	"512SvMan/services"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func setupVirshAutoStartGroupsAPI(r chi.Router) {
+	r.Route("/autostart-groups", func(r chi.Router) {
+		r.Get("/", listAutoStartGroups)
+		r.Post("/", createAutoStartGroup)
+		r.Post("/shutdown", orderedShutdown)
+		r.Put("/vms/{vm_name}", setAutoStartBoot)
+		r.Get("/{name}", getAutoStartGroup)
+		r.Put("/{name}", updateAutoStartGroup)
+		r.Delete("/{name}", deleteAutoStartGroup)
+	})
+}
+
+type autoStartGroupRequest struct {
+	Name  string `json:"name"`
+	Order int    `json:"order"`
+}
+
+func listAutoStartGroups(w http.ResponseWriter, r *http.Request) {
+	service := services.AutoStartService{}
+	groups, err := service.ListGroups(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, groups)
+}
+
+func getAutoStartGroup(w http.ResponseWriter, r *http.Request) {
+	service := services.AutoStartService{}
+	group, err := service.GetGroup(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeProtocolJSON(w, group)
+}
+
+func createAutoStartGroup(w http.ResponseWriter, r *http.Request) {
+	var req autoStartGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	service := services.AutoStartService{}
+	group, err := service.CreateGroup(r.Context(), req.Name, req.Order)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(group)
+}
+
+func updateAutoStartGroup(w http.ResponseWriter, r *http.Request) {
+	var req autoStartGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	service := services.AutoStartService{}
+	group, err := service.UpdateGroup(r.Context(), chi.URLParam(r, "name"), req.Order)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, group)
+}
+
+func deleteAutoStartGroup(w http.ResponseWriter, r *http.Request) {
+	service := services.AutoStartService{}
+	if err := service.DeleteGroup(r.Context(), chi.URLParam(r, "name")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setAutoStartBoot puts the vm in a group and sets its delay and readiness
+// check, the vm is put on autostart when it is not yet.
+func setAutoStartBoot(w http.ResponseWriter, r *http.Request) {
+	var settings db.AutoStart
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	settings.VmName = chi.URLParam(r, "vm_name")
+
+	service := services.AutoStartService{}
+	updated, err := service.SetBoot(r.Context(), settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, updated)
+}
+
+// orderedShutdown shuts the autostart vms down last group first, only on
+// machine_name when it is given.
+func orderedShutdown(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MachineName string `json:"machine_name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	service := services.AutoStartService{}
+	jobID, err := service.ShutdownOrdered(r.Context(), req.MachineName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(jobStartedResponse{JobId: jobID})
+}
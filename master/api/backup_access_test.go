@@ -0,0 +1,111 @@
+package api
+
+import (
+	"512SvMan/db"
+	"512SvMan/services"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func useBackupAPITestDB(t *testing.T) {
+	t.Helper()
+	originalDB := db.DB
+	t.Cleanup(func() {
+		db.DB = originalDB
+	})
+
+	var err error
+	db.DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.DB.Close() })
+	db.DB.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	for _, create := range []func(context.Context) error{db.CreateTableBackups, db.CreateBackupVerificationTable, db.CreateTableAutomaticBackup} {
+		if err := create(ctx); err != nil {
+			t.Fatalf("create tables: %v", err)
+		}
+	}
+}
+
+func TestBackupRoutesOnlyShowReadableVMs(t *testing.T) {
+	ctx := context.Background()
+	useBackupAPITestDB(t)
+
+	dir := t.TempDir()
+	ids := map[string]int{}
+	for _, name := range []string{"web1", "db1"} {
+		path := filepath.Join(dir, name+".qcow2")
+		if err := os.WriteFile(path, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		bak := &db.VirshBackup{Name: name, Path: path}
+		if err := db.InsertVirshBackup(ctx, bak); err != nil {
+			t.Fatalf("insert backup: %v", err)
+		}
+		ids[name] = bak.Id
+		if err := db.AddAutomaticBackup(ctx, &db.AutomaticBackup{VmName: name, FrequencyDays: 1}); err != nil {
+			t.Fatalf("add automatic backup: %v", err)
+		}
+	}
+
+	owner := &services.Principal{
+		User:   db.User{Username: "bob", Role: db.RoleVMOwner},
+		Grants: []db.UserGrant{{ResourceType: services.ResourceVMs, ResourceName: "web1", Access: db.AccessRead}},
+	}
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, setPrincipalInContext(r, owner))
+		})
+	})
+	router.Get("/virsh/backups", getAllBackups)
+	router.Get("/virsh/downloadbackup/{backup_id}", downloadBackup)
+	router.Get("/virsh/autobak", getAutoBak)
+	router.Get("/virsh/backups/{backup_id}/verifications", getBackupVerifications)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/virsh/downloadbackup/" + strconv.Itoa(ids["db1"])); rec.Code != http.StatusNotFound {
+		t.Fatalf("download of another vm's backup: got %d, want 404", rec.Code)
+	}
+	if rec := get("/virsh/backups/" + strconv.Itoa(ids["db1"]) + "/verifications"); rec.Code != http.StatusNotFound {
+		t.Fatalf("verifications of another vm's backup: got %d, want 404", rec.Code)
+	}
+	if rec := get("/virsh/downloadbackup/" + strconv.Itoa(ids["web1"])); rec.Code != http.StatusOK || rec.Body.String() != "web1" {
+		t.Fatalf("download of own backup: got %d %q", rec.Code, rec.Body.String())
+	}
+
+	var backups []struct {
+		DbRes db.VirshBackup `json:"db_res"`
+	}
+	if err := json.Unmarshal(get("/virsh/backups").Body.Bytes(), &backups); err != nil {
+		t.Fatalf("decode backups: %v", err)
+	}
+	if len(backups) != 1 || backups[0].DbRes.Name != "web1" {
+		t.Fatalf("backups = %+v, want only web1", backups)
+	}
+
+	var autoBaks []db.AutomaticBackup
+	if err := json.Unmarshal(get("/virsh/autobak").Body.Bytes(), &autoBaks); err != nil {
+		t.Fatalf("decode automatic backups: %v", err)
+	}
+	if len(autoBaks) != 1 || autoBaks[0].VmName != "web1" {
+		t.Fatalf("automatic backups = %+v, want only web1", autoBaks)
+	}
+}
@@ -0,0 +1,335 @@
+package api
+
+import (
+	"512SvMan/db"
+	"512SvMan/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func setupVirshBackupReposAPI(r chi.Router) {
+	r.Route("/backup-repositories", func(r chi.Router) {
+		r.Get("/", listBackupRepositories)
+		r.Post("/", createBackupRepository)
+		r.Get("/{id}", getBackupRepository)
+		r.Put("/{id}", updateBackupRepository)
+		r.Delete("/{id}", deleteBackupRepository)
+		r.Post("/{id}/test", testBackupRepository)
+	})
+
+	r.Route("/backup-copy-policies", func(r chi.Router) {
+		r.Get("/", listBackupCopyPolicies)
+		r.Post("/", createBackupCopyPolicy)
+		r.Put("/{id}", updateBackupCopyPolicy)
+		r.Delete("/{id}", deleteBackupCopyPolicy)
+	})
+
+	r.Route("/backup-replicas", func(r chi.Router) {
+		r.Get("/", listBackupReplicas)
+		r.Post("/{id}/retry", retryBackupReplica)
+		r.Post("/{id}/fetch", fetchBackupReplica)
+		r.Delete("/{id}", deleteBackupReplica)
+	})
+
+	r.Post("/backups/{backup_id}/copy", copyBackup)
+}
+
+func backupRepoID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// backupRepositoryRequest carries the secrets db.BackupRepository never
+// encodes, on update empty secrets keep the stored ones.
+type backupRepositoryRequest struct {
+	db.BackupRepository
+	SecretKey  string `json:"secret_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+func (req backupRepositoryRequest) repository() db.BackupRepository {
+	repo := req.BackupRepository
+	repo.SecretKey = req.SecretKey
+	repo.PrivateKey = req.PrivateKey
+	return repo
+}
+
+func listBackupRepositories(w http.ResponseWriter, r *http.Request) {
+	virshService := services.VirshService{}
+	repos, err := virshService.BackupRepositories(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, repos)
+}
+
+func getBackupRepository(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	virshService := services.VirshService{}
+	repo, err := virshService.GetBackupRepository(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeProtocolJSON(w, repo)
+}
+
+func createBackupRepository(w http.ResponseWriter, r *http.Request) {
+	var req backupRepositoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	repo := req.repository()
+
+	virshService := services.VirshService{}
+	if err := virshService.AddBackupRepository(r.Context(), &repo); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(repo)
+}
+
+func updateBackupRepository(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupRepoID(w, r)
+	if !ok {
+		return
+	}
+	var req backupRepositoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	repo := req.repository()
+	repo.Id = id
+
+	virshService := services.VirshService{}
+	if err := virshService.UpdateBackupRepository(r.Context(), repo); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	updated, err := virshService.GetBackupRepository(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, updated)
+}
+
+// deleteBackupRepository forgets the repository, its policies and the records
+// of its copies, the copied files stay in the repository.
+func deleteBackupRepository(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	virshService := services.VirshService{}
+	if err := virshService.DeleteBackupRepository(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// testBackupRepository writes, reads back and deletes a small object, sftp
+// repositories pin the host key of the server on the first test.
+func testBackupRepository(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	virshService := services.VirshService{}
+	if err := virshService.TestBackupRepository(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func listBackupCopyPolicies(w http.ResponseWriter, r *http.Request) {
+	virshService := services.VirshService{}
+	policies, err := virshService.BackupCopyPolicies(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, policies)
+}
+
+func createBackupCopyPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy db.BackupCopyPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	virshService := services.VirshService{}
+	if err := virshService.AddBackupCopyPolicy(r.Context(), &policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(policy)
+}
+
+func updateBackupCopyPolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupRepoID(w, r)
+	if !ok {
+		return
+	}
+	var policy db.BackupCopyPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	policy.Id = id
+
+	virshService := services.VirshService{}
+	if err := virshService.UpdateBackupCopyPolicy(r.Context(), policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, policy)
+}
+
+func deleteBackupCopyPolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	virshService := services.VirshService{}
+	if err := virshService.DeleteBackupCopyPolicy(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listBackupReplicas takes the optional backup_id, repository_id and status
+// filters.
+func listBackupReplicas(w http.ResponseWriter, r *http.Request) {
+	var filter db.BackupReplicaFilter
+	query := r.URL.Query()
+	for name, dst := range map[string]*int{"backup_id": &filter.BackupId, "repository_id": &filter.RepositoryId} {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dst = n
+		}
+	}
+	filter.Status = query.Get("status")
+
+	virshService := services.VirshService{}
+	replicas, err := virshService.BackupReplicas(r.Context(), filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, replicas)
+}
+
+func retryBackupReplica(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	virshService := services.VirshService{}
+	jobID, err := virshService.RetryBackupReplica(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(jobStartedResponse{JobId: jobID})
+}
+
+// fetchBackupReplica downloads a copy to the NFS share in the body and
+// registers it as a backup that can be restored.
+func fetchBackupReplica(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupRepoID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		NfsId int `json:"nfs_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	virshService := services.VirshService{}
+	jobID, err := virshService.FetchBackupReplica(r.Context(), id, req.NfsId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(jobStartedResponse{JobId: jobID})
+}
+
+// deleteBackupReplica removes the copy from its repository.
+func deleteBackupReplica(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	virshService := services.VirshService{}
+	if err := virshService.DeleteBackupReplica(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// copyBackup copies a full backup to a repository outside of the policies,
+// mode is copy or sync like a policy one.
+func copyBackup(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupIDParam(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		RepositoryId int    `json:"repository_id"`
+		Mode         string `json:"mode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	virshService := services.VirshService{}
+	jobID, err := virshService.CopyBackup(r.Context(), id, req.RepositoryId, req.Mode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(jobStartedResponse{JobId: jobID})
+}
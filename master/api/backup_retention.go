@@ -0,0 +1,78 @@
+package api
+
+import (
+	"512SvMan/db"
+	"512SvMan/services"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func setupVirshBackupRetentionAPI(r chi.Router) {
+	r.Get("/autobak/{id}/prune-plan", getAutoBakPrunePlan)
+	r.Post("/autobak/{id}/prune", pruneAutoBak)
+	r.Put("/backups/{backup_id}/pin", pinBackup)
+	r.Delete("/backups/{backup_id}/pin", unpinBackup)
+}
+
+// getAutoBakPrunePlan is a dry-run of the retention, it lists every automatic
+// backup of the vm with what the next prune would do to it and why.
+func getAutoBakPrunePlan(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	autoBak, err := db.GetAutomaticBackupById(r.Context(), id)
+	if err != nil || autoBak == nil || !canAccess(r, services.ResourceVMs, autoBak.VmName, db.AccessRead) {
+		http.Error(w, "automatic backup not found", http.StatusNotFound)
+		return
+	}
+
+	virshService := services.VirshService{}
+	plan, err := virshService.PlanAutoBakPrune(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeProtocolJSON(w, plan)
+}
+
+func pruneAutoBak(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	virshService := services.VirshService{}
+	plan, err := virshService.PruneAutoBak(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, plan)
+}
+
+func setBackupPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	id, ok := backupIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	virshService := services.VirshService{}
+	if err := virshService.PinBackup(r.Context(), id, pinned); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func pinBackup(w http.ResponseWriter, r *http.Request) {
+	setBackupPinned(w, r, true)
+}
+
+func unpinBackup(w http.ResponseWriter, r *http.Request) {
+	setBackupPinned(w, r, false)
+}
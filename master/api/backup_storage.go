@@ -0,0 +1,99 @@
+package api
+
+import (
+	"512SvMan/services"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func setupVirshBackupStorageAPI(r chi.Router) {
+	r.Get("/backups/keys", getBackupKeys)
+	r.Post("/backups/keys/rotate", rotateBackupKey)
+	r.Post("/backups/keys/export", exportBackupKeys)
+	r.Post("/backups/keys/import", importBackupKeys)
+}
+
+// getBackupKeys lists the backup encryption keys, never their material.
+func getBackupKeys(w http.ResponseWriter, r *http.Request) {
+	virshService := services.VirshService{}
+	keys, err := virshService.BackupKeys(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, keys)
+}
+
+// rotateBackupKey makes new encrypted backups use a fresh key.
+func rotateBackupKey(w http.ResponseWriter, r *http.Request) {
+	virshService := services.VirshService{}
+	key, err := virshService.RotateBackupKey(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(key)
+}
+
+type backupKeyExportRequest struct {
+	Passphrase string `json:"passphrase"`
+}
+
+type backupKeyImportRequest struct {
+	Passphrase string          `json:"passphrase"`
+	KeyFile    json.RawMessage `json:"key_file"`
+}
+
+type backupKeyImportResponse struct {
+	Imported int `json:"imported"`
+}
+
+// exportBackupKeys downloads every backup key sealed with the passphrase in the
+// body. Only an admin logged in with a session can do it, not an api token.
+func exportBackupKeys(w http.ResponseWriter, r *http.Request) {
+	principal, ok := sessionPrincipal(w, r)
+	if !ok {
+		return
+	}
+	var req backupKeyExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	virshService := services.VirshService{}
+	keyFile, err := virshService.ExportBackupKeys(r.Context(), principal, req.Passphrase)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filename := "backup-keys-" + time.Now().UTC().Format("20060102-150405") + ".json"
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	_, _ = w.Write(keyFile)
+}
+
+// importBackupKeys restores the keys of an export, on a new master or after the
+// database was lost.
+func importBackupKeys(w http.ResponseWriter, r *http.Request) {
+	principal, ok := sessionPrincipal(w, r)
+	if !ok {
+		return
+	}
+	var req backupKeyImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	virshService := services.VirshService{}
+	imported, err := virshService.ImportBackupKeys(r.Context(), principal, req.KeyFile, req.Passphrase)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, backupKeyImportResponse{Imported: imported})
+}
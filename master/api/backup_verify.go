@@ -0,0 +1,91 @@
+package api
+
+import (
+	"512SvMan/db"
+	"512SvMan/services"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func setupVirshBackupVerifyAPI(r chi.Router) {
+	r.Post("/backups/{backup_id}/verify", verifyBackup)
+	r.Post("/backups/{backup_id}/test-restore", testRestoreBackup)
+	r.Get("/backups/{backup_id}/verifications", getBackupVerifications)
+}
+
+func backupIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "backup_id"))
+	if err != nil {
+		http.Error(w, "invalid backup_id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// verifyBackup queues a qemu-img check and checksum comparison of the backup
+// and of every file it depends on.
+func verifyBackup(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	virshService := services.VirshService{}
+	jobID, err := virshService.VerifyBackup(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(jobStartedResponse{JobId: jobID})
+}
+
+// testRestoreBackup boots the backup as a throwaway vm without network and
+// waits for its guest agent, the body is optional.
+func testRestoreBackup(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupIDParam(w, r)
+	if !ok {
+		return
+	}
+	var opts services.TestRestoreOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && err != io.EOF {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	virshService := services.VirshService{}
+	jobID, err := virshService.TestRestoreBackup(r.Context(), id, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(jobStartedResponse{JobId: jobID})
+}
+
+func getBackupVerifications(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupIDParam(w, r)
+	if !ok {
+		return
+	}
+	backup, err := db.GetVirshBackupById(r.Context(), id)
+	if err != nil || backup == nil || !canAccess(r, services.ResourceVMs, backup.Name, db.AccessRead) {
+		http.Error(w, "backup not found", http.StatusNotFound)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	virshService := services.VirshService{}
+	verifications, err := virshService.BackupVerifications(r.Context(), id, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeProtocolJSON(w, verifications)
+}
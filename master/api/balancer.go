@@ -0,0 +1,77 @@
+package api
+
+import (
+	"512SvMan/services"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func getBalancerPlan(w http.ResponseWriter, r *http.Request) {
+	service := services.BalancerService{}
+	plan, err := service.Plan(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, plan)
+}
+
+// applyBalancerPlan takes the plan returned by GET /plan, only its moves run.
+func applyBalancerPlan(w http.ResponseWriter, r *http.Request) {
+	var plan services.BalancePlan
+	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	service := services.BalancerService{}
+	jobID, err := service.Apply(r.Context(), &plan)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(jobStartedResponse{JobId: jobID})
+}
+
+func getBalancerConfig(w http.ResponseWriter, r *http.Request) {
+	service := services.BalancerService{}
+	cfg, err := service.Config(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, cfg)
+}
+
+func setBalancerConfig(w http.ResponseWriter, r *http.Request) {
+	service := services.BalancerService{}
+	cfg, err := service.Config(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// fields left out of the body keep their current value
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := service.SetConfig(r.Context(), cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, saved)
+}
+
+func setupBalancerAPI(r chi.Router) chi.Router {
+	return r.Route("/balancer", func(r chi.Router) {
+		r.Get("/plan", getBalancerPlan)
+		r.Post("/apply", applyBalancerPlan)
+		r.Get("/config", getBalancerConfig)
+		r.Put("/config", setBalancerConfig)
+	})
+}
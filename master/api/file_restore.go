@@ -0,0 +1,174 @@
+package api
+
+import (
+	"512SvMan/db"
+	"512SvMan/services"
+	"encoding/json"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/Maruqes/512SvMan/logger"
+	"github.com/go-chi/chi/v5"
+)
+
+func setupVirshFileRestoreAPI(r chi.Router) {
+	r.Post("/backups/{backup_id}/files/mount", mountBackupFiles)
+
+	r.Route("/file-restore", func(r chi.Router) {
+		r.Get("/", listFileRestoreSessions)
+		r.Get("/{session}/browse", browseBackupFiles)
+		r.Get("/{session}/download", downloadBackupFile)
+		r.Get("/{session}/tar", downloadBackupFilesTar)
+		r.Delete("/{session}", unmountBackupFiles)
+	})
+}
+
+// The routes don't name the vm, access is checked against the vm of the
+// backup or of the session. Sessions of other vms answer as not found.
+
+// fileRestoreSession returns the session of the request when the user can
+// access its vm.
+func fileRestoreSession(w http.ResponseWriter, r *http.Request, access string) (*services.FileRestoreSession, bool) {
+	virshService := services.VirshService{}
+	session, err := virshService.FileRestoreSession(chi.URLParam(r, "session"))
+	if err != nil || !canAccess(r, services.ResourceVMs, session.VmName, access) {
+		http.Error(w, "file restore session not found", http.StatusNotFound)
+		return nil, false
+	}
+	return session, true
+}
+
+// mountBackupFiles starts mounting the backup read-only on a slave and answers
+// with the session, its partitions are listed once the job is done. A backup
+// already mounted answers with its session right away.
+func mountBackupFiles(w http.ResponseWriter, r *http.Request) {
+	id, ok := backupIDParam(w, r)
+	if !ok {
+		return
+	}
+	backup, err := db.GetVirshBackupById(r.Context(), id)
+	if err != nil || backup == nil || !canAccess(r, services.ResourceVMs, backup.Name, db.AccessRead) {
+		http.Error(w, "backup not found", http.StatusNotFound)
+		return
+	}
+
+	virshService := services.VirshService{}
+	session, err := virshService.MountBackupFiles(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status := http.StatusOK
+	if session.Status == services.FileRestoreMounting {
+		status = http.StatusAccepted
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(session)
+}
+
+func listFileRestoreSessions(w http.ResponseWriter, r *http.Request) {
+	virshService := services.VirshService{}
+	sessions := slices.DeleteFunc(virshService.FileRestoreSessions(), func(s services.FileRestoreSession) bool {
+		return !canAccess(r, services.ResourceVMs, s.VmName, db.AccessRead)
+	})
+	writeProtocolJSON(w, sessions)
+}
+
+// browseBackupFiles takes the partition and path query parameters, path is
+// relative to the root of the partition.
+func browseBackupFiles(w http.ResponseWriter, r *http.Request) {
+	session, ok := fileRestoreSession(w, r, db.AccessRead)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+
+	virshService := services.VirshService{}
+	listing, err := virshService.BrowseBackupFiles(r.Context(), session.Id, query.Get("partition"), query.Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, listing)
+}
+
+// lazyDownload only sends the headers with the first chunk, an error before it
+// can still be answered with http.Error.
+type lazyDownload struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (d *lazyDownload) Write(p []byte) (int, error) {
+	d.started = true
+	return d.w.Write(p)
+}
+
+func streamBackupFiles(w http.ResponseWriter, r *http.Request, paths []string, tar bool, filename, contentType string) {
+	session, ok := fileRestoreSession(w, r, db.AccessRead)
+	if !ok {
+		return
+	}
+	filename = strings.ReplaceAll(filename, "\"", "")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	w.Header().Set("Content-Type", contentType)
+
+	sessionID := session.Id
+	out := &lazyDownload{w: w}
+	virshService := services.VirshService{}
+	err := virshService.ReadBackupFiles(r.Context(), sessionID, r.URL.Query().Get("partition"), paths, tar, out)
+	if err == nil {
+		return
+	}
+	if !out.started {
+		w.Header().Del("Content-Disposition")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logger.Errorf("file restore %s: download of %v: %v", sessionID, paths, err)
+}
+
+// downloadBackupFile streams a single regular file of the backup.
+func downloadBackupFile(w http.ResponseWriter, r *http.Request) {
+	p := r.URL.Query().Get("path")
+	name := path.Base(path.Clean("/" + p))
+	if p == "" || name == "/" {
+		http.Error(w, "path of a file is required", http.StatusBadRequest)
+		return
+	}
+	streamBackupFiles(w, r, []string{p}, false, name, "application/octet-stream")
+}
+
+// downloadBackupFilesTar streams a tar of every path query parameter,
+// directories are added recursively.
+func downloadBackupFilesTar(w http.ResponseWriter, r *http.Request) {
+	paths := r.URL.Query()["path"]
+	if len(paths) == 0 {
+		http.Error(w, "at least one path is required", http.StatusBadRequest)
+		return
+	}
+
+	name := "restore"
+	if len(paths) == 1 {
+		if base := path.Base(path.Clean("/" + paths[0])); base != "/" {
+			name = base
+		}
+	}
+	streamBackupFiles(w, r, paths, true, name+".tar", "application/x-tar")
+}
+
+func unmountBackupFiles(w http.ResponseWriter, r *http.Request) {
+	session, ok := fileRestoreSession(w, r, db.AccessRead)
+	if !ok {
+		return
+	}
+	virshService := services.VirshService{}
+	if err := virshService.UnmountBackupFiles(r.Context(), session.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"512SvMan/db"
+	"512SvMan/services"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func setupVirshHAAPI(r chi.Router) {
+	r.Route("/ha", func(r chi.Router) {
+		r.Get("/", listHAPolicies)
+		r.Get("/failovers", listHAFailovers)
+		r.Get("/{vm_name}", getHAPolicy)
+		r.Put("/{vm_name}", setHAPolicy)
+		r.Delete("/{vm_name}", deleteHAPolicy)
+		r.Get("/{vm_name}/failovers", listHAFailovers)
+	})
+}
+
+func listHAPolicies(w http.ResponseWriter, r *http.Request) {
+	service := services.HAService{}
+	policies, err := service.ListPolicies(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	policies = slices.DeleteFunc(policies, func(policy db.HAPolicy) bool {
+		return !canAccess(r, services.ResourceVMs, policy.VmName, db.AccessRead)
+	})
+	writeProtocolJSON(w, policies)
+}
+
+func getHAPolicy(w http.ResponseWriter, r *http.Request) {
+	service := services.HAService{}
+	policy, err := service.GetPolicy(r.Context(), chi.URLParam(r, "vm_name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeProtocolJSON(w, policy)
+}
+
+func setHAPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy db.HAPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	policy.VmName = chi.URLParam(r, "vm_name")
+
+	service := services.HAService{}
+	saved, err := service.SetPolicy(r.Context(), policy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, saved)
+}
+
+func deleteHAPolicy(w http.ResponseWriter, r *http.Request) {
+	service := services.HAService{}
+	if err := service.DeletePolicy(r.Context(), chi.URLParam(r, "vm_name")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func listHAFailovers(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	service := services.HAService{}
+	failovers, err := service.Failovers(r.Context(), chi.URLParam(r, "vm_name"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	failovers = slices.DeleteFunc(failovers, func(failover db.HAFailover) bool {
+		return !canAccess(r, services.ResourceVMs, failover.VmName, db.AccessRead)
+	})
+	writeProtocolJSON(w, failovers)
+}
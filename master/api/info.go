@@ -689,6 +689,54 @@ func getNetworkHistory(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, converted)
 }
 
+type vmSnapshotResponse struct {
+	ID          int             `json:"id"`
+	VmName      string          `json:"vm_name"`
+	MachineName string          `json:"machine_name"`
+	CapturedAt  time.Time       `json:"captured_at"`
+	Resolution  string          `json:"resolution"`
+	Info        json.RawMessage `json:"info"`
+}
+
+// getVMHistory returns the usage samples of a vm, the ones older than two days
+// are hourly averages (resolution "hour").
+func getVMHistory(w http.ResponseWriter, r *http.Request) {
+	vmName := chi.URLParam(r, "vm_name")
+	duration, numberOfRows, err := parseHistoryDuration(r)
+	if err != nil {
+		http.Error(w, "invalid duration: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if duration <= 0 {
+		http.Error(w, "duration must be provided either via query param or body (hours/days/weeks/months)", http.StatusBadRequest)
+		return
+	}
+
+	infoService := &services.InfoService{}
+	snaps, err := infoService.GetVMHistory(r.Context(), vmName, duration, numberOfRows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	converted := make([]vmSnapshotResponse, 0, len(snaps))
+	for _, snap := range snaps {
+		raw, err := protoToRaw(snap.Stats)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		converted = append(converted, vmSnapshotResponse{
+			ID:          snap.ID,
+			VmName:      snap.VmName,
+			MachineName: snap.MachineName,
+			CapturedAt:  snap.CapturedAt,
+			Resolution:  snap.Resolution,
+			Info:        raw,
+		})
+	}
+	writeJSON(w, converted)
+}
+
 func stressCPU(w http.ResponseWriter, r *http.Request) {
 	machineName := chi.URLParam(r, "machine_name")
 	if machineName == "" {
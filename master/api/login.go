@@ -1,17 +1,22 @@
 package api
 
 import (
+	"512SvMan/db"
+	"512SvMan/env512"
 	"512SvMan/services"
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
 )
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token string   `json:"token"`
+	User  *db.User `json:"user"`
 }
 
 func loginHandler(w http.ResponseWriter, r *http.Request) {
@@ -30,20 +35,61 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := loginService.Login(baseURL, req.Email, req.Password)
+	// email also accepts the username of a local user
+	token, user, err := loginService.Login(r.Context(), baseURL, req.Email, req.Password)
 	if err != nil {
+		if !errors.Is(err, services.ErrInvalidCredentials) {
+			log.Printf("login: %v", err)
+		}
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(LoginResponse{Token: token}); err != nil {
+	if err := json.NewEncoder(w).Encode(LoginResponse{Token: token, User: user}); err != nil {
 		log.Printf("login: encode response failed: %v", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 }
 
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	loginService := services.LoginService{}
+	if err := loginService.Logout(r.Context(), requestToken(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	SetCookieInBrowser(w, "", -1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// meHandler returns the logged in user and what it can reach, the UI uses it
+// to hide what the user cannot do.
+func meHandler(w http.ResponseWriter, r *http.Request) {
+	principal := PrincipalFromContext(r)
+	permissions := map[string]string{}
+	for _, resourceType := range append(services.GrantableResources, services.ResourceSystem, services.ResourceCluster, services.ResourceUsers) {
+		switch {
+		case principal.Can(resourceType, "*", db.AccessWrite):
+			permissions[resourceType] = db.AccessWrite
+		case principal.Can(resourceType, "*", db.AccessRead):
+			permissions[resourceType] = db.AccessRead
+		}
+	}
+	grants := principal.Grants
+	if grants == nil {
+		grants = []db.UserGrant{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"user":        principal.User,
+		"permissions": permissions,
+		"grants":      grants,
+		"api_token":   principal.Token,
+	})
+}
+
 func SetTokenInContext(r *http.Request, token string) *http.Request {
 	return r.WithContext(context.WithValue(r.Context(), "token", token))
 }
@@ -91,33 +137,132 @@ func tokenFromRequest(r *http.Request) string {
 	return normalizeTokenValue(cookieValue)
 }
 
-func isAuthorized(r *http.Request) (bool, string) {
+func requestToken(r *http.Request) string {
 	token := tokenFromRequest(r)
 
 	// Also check query parameters for token
 	if token == "" {
 		token = normalizeTokenValue(r.URL.Query().Get("token"))
 	}
+	return token
+}
 
+func isAuthorized(r *http.Request) (*services.Principal, bool) {
+	token := requestToken(r)
 	if token == "" {
-		return false, ""
+		return nil, false
 	}
 
-	loginService := services.LoginService{}
-	return loginService.IsLoginValid(baseURL, token), token
+	var principal *services.Principal
+	var err error
+	if strings.HasPrefix(token, services.APITokenPrefix) {
+		ip := requestIP(r, env512.TrustedProxies)
+		tokenService := services.APITokenService{}
+		principal, err = tokenService.Authenticate(r.Context(), token, ip)
+	} else {
+		loginService := services.LoginService{}
+		principal, err = loginService.Authenticate(r.Context(), token)
+	}
+	if err != nil {
+		log.Printf("auth: %v", err)
+		return nil, false
+	}
+	return principal, principal != nil
+}
+
+// requestIP is the address api token allow-lists are checked against. The
+// forwarded headers are only believed when the connection comes from one of
+// the trusted proxies, anybody else could put any address in them.
+func requestIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := parseRequestIP(host)
+	if ip == nil || !inNetworks(ip, trusted) {
+		if ip == nil {
+			return ""
+		}
+		return ip.String()
+	}
+
+	forwarded := strings.TrimSpace(r.Header.Get("X-Forwarded-For"))
+	if forwarded == "" {
+		if realIP := parseRequestIP(r.Header.Get("X-Real-IP")); realIP != nil {
+			return realIP.String()
+		}
+		return ip.String()
+	}
+	// every proxy appends the address it got the request from, walking back from
+	// the nearest one the first hop that isn't a trusted proxy is the client
+	hops := strings.Split(forwarded, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseRequestIP(hops[i])
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !inNetworks(hop, trusted) {
+			break
+		}
+	}
+	return ip.String()
+}
+
+func parseRequestIP(raw string) net.IP {
+	raw = strings.TrimSpace(raw)
+	// link local ipv6 addresses carry their zone, net.ParseIP doesn't take it
+	if i := strings.IndexByte(raw, '%'); i >= 0 {
+		raw = raw[:i]
+	}
+	return net.ParseIP(raw)
+}
+
+func inNetworks(ip net.IP, networks []*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// withPrincipal puts the principal in the request context together with the
+// nginx proxy manager token the npm handlers use. Local users get the token
+// of the service account, only on the routes that need it.
+func withPrincipal(r *http.Request, principal *services.Principal, resourceType string) *http.Request {
+	npmToken := principal.NPMToken
+	if npmToken == "" && resourceType == services.ResourceNPM {
+		loginService := services.LoginService{}
+		token, err := loginService.NPMServiceToken(baseURL)
+		if err != nil {
+			log.Printf("auth: %v", err)
+		}
+		npmToken = token
+	}
+	r = setPrincipalInContext(r, principal)
+	return SetTokenInContext(r, npmToken)
 }
 
 func authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authorized, token := isAuthorized(r)
+		principal, authorized := isAuthorized(r)
 		if !authorized {
 			applyCORSHeaders(w, r)
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Add token to request context
-		r = SetTokenInContext(r, token)
+		resourceType, name := requestResource(r)
+		if !principal.AllowsRoute(r.Method, r.URL.Path) ||
+			(resourceType != "" && !principal.Can(resourceType, name, requestAccess(r))) {
+			applyCORSHeaders(w, r)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		// Add user and npm token to request context
+		r = withPrincipal(r, principal, resourceType)
 
 		// continue to the next handler
 		next.ServeHTTP(w, r)
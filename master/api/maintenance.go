@@ -0,0 +1,85 @@
+package api
+
+import (
+	"512SvMan/services"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type jobStartedResponse struct {
+	JobId int `json:"job_id"`
+}
+
+func listMaintenance(w http.ResponseWriter, r *http.Request) {
+	service := services.MaintenanceService{}
+	hosts, err := service.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, hosts)
+}
+
+func getMaintenance(w http.ResponseWriter, r *http.Request) {
+	service := services.MaintenanceService{}
+	status, err := service.Status(r.Context(), chi.URLParam(r, "machine_name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, status)
+}
+
+func enterMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req services.MaintenanceRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	service := services.MaintenanceService{}
+	jobID, err := service.Enter(r.Context(), chi.URLParam(r, "machine_name"), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(jobStartedResponse{JobId: jobID})
+}
+
+func exitMaintenance(w http.ResponseWriter, r *http.Request) {
+	service := services.MaintenanceService{}
+	status, err := service.Exit(r.Context(), chi.URLParam(r, "machine_name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, status)
+}
+
+func returnMaintenanceVMs(w http.ResponseWriter, r *http.Request) {
+	service := services.MaintenanceService{}
+	jobID, err := service.Return(r.Context(), chi.URLParam(r, "machine_name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(jobStartedResponse{JobId: jobID})
+}
+
+func setupMaintenanceAPI(r chi.Router) chi.Router {
+	return r.Route("/maintenance", func(r chi.Router) {
+		r.Get("/", listMaintenance)
+		r.Get("/{machine_name}", getMaintenance)
+		r.Post("/{machine_name}", enterMaintenance)
+		r.Post("/{machine_name}/exit", exitMaintenance)
+		r.Post("/{machine_name}/return", returnMaintenanceVMs)
+	})
+}
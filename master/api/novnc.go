@@ -1,6 +1,7 @@
 package api
 
 import (
+	"512SvMan/db"
 	"512SvMan/env512"
 	"512SvMan/protocol"
 	"512SvMan/services"
@@ -105,7 +106,7 @@ func initNoVNC() {
 				return "", http.ErrNoLocation
 			}
 
-			if GetTokenFromContext(r) == "" && guestVMFromContext(r) == "" {
+			if PrincipalFromContext(r) == nil && guestVMFromContext(r) == "" {
 				// Should not happen because middleware guards the route
 				logger.Warn("novnc: websocket request without auth context blocked")
 				return "", http.ErrNoLocation
@@ -294,15 +295,21 @@ func checkMap(r *http.Request, requestedVM string) (bool, string) {
 // checks for normal auth and token for vm
 func authMiddlewareNOVNC(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authorized, token := isAuthorized(r)
+		requestedVM := extractVMFromRequest(r)
+
+		principal, authorized := isAuthorized(r)
 		if authorized {
-			r = SetTokenInContext(r, token)
+			// a console is full control of the vm
+			if requestedVM != "" && !principal.Can(services.ResourceVMs, requestedVM, db.AccessWrite) {
+				applyCORSHeaders(w, r)
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			r = withPrincipal(r, principal, services.ResourceVMs)
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		requestedVM := extractVMFromRequest(r)
-
 		authorized, vm := checkMap(r, requestedVM)
 		if authorized {
 			if vm != "" {
@@ -321,7 +328,7 @@ func extractVMFromRequest(r *http.Request) string {
 	if vm := r.URL.Query().Get("vm"); vm != "" {
 		return vm
 	}
-	if vm := chi.URLParam(r, "vm_name"); vm != "" {
+	if vm := matchURLParam(r, "vm_name"); vm != "" {
 		return vm
 	}
 
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"512SvMan/db"
+	"512SvMan/services"
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func setupVirshPlacementGroupsAPI(r chi.Router) {
+	r.Route("/placement-groups", func(r chi.Router) {
+		r.Get("/", listPlacementGroups)
+		r.Post("/", createPlacementGroup)
+		r.Get("/compliance", placementCompliance)
+		r.Get("/{name}", getPlacementGroup)
+		r.Put("/{name}", updatePlacementGroup)
+		r.Delete("/{name}", deletePlacementGroup)
+		r.Put("/{name}/vms/{vm_name}", addPlacementGroupVM)
+		r.Delete("/{name}/vms/{vm_name}", removePlacementGroupVM)
+	})
+}
+
+func listPlacementGroups(w http.ResponseWriter, r *http.Request) {
+	service := services.PlacementGroupService{}
+	groups, err := service.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, groups)
+}
+
+func getPlacementGroup(w http.ResponseWriter, r *http.Request) {
+	service := services.PlacementGroupService{}
+	group, err := service.Get(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeProtocolJSON(w, group)
+}
+
+func createPlacementGroup(w http.ResponseWriter, r *http.Request) {
+	var group db.PlacementGroup
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, vmName := range group.VMs {
+		if !canAccess(r, services.ResourceVMs, vmName, db.AccessWrite) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	service := services.PlacementGroupService{}
+	created, err := service.Create(r.Context(), group)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func updatePlacementGroup(w http.ResponseWriter, r *http.Request) {
+	var group db.PlacementGroup
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	group.Name = chi.URLParam(r, "name")
+
+	service := services.PlacementGroupService{}
+	updated, err := service.Update(r.Context(), group)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, updated)
+}
+
+func deletePlacementGroup(w http.ResponseWriter, r *http.Request) {
+	service := services.PlacementGroupService{}
+	if err := service.Delete(r.Context(), chi.URLParam(r, "name")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func addPlacementGroupVM(w http.ResponseWriter, r *http.Request) {
+	service := services.PlacementGroupService{}
+	group, err := service.AddVM(r.Context(), chi.URLParam(r, "name"), chi.URLParam(r, "vm_name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, group)
+}
+
+func removePlacementGroupVM(w http.ResponseWriter, r *http.Request) {
+	service := services.PlacementGroupService{}
+	group, err := service.RemoveVM(r.Context(), chi.URLParam(r, "name"), chi.URLParam(r, "vm_name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, group)
+}
+
+func placementCompliance(w http.ResponseWriter, r *http.Request) {
+	service := services.PlacementGroupService{}
+	violations, err := service.Compliance(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	violations = slices.DeleteFunc(violations, func(violation services.PlacementViolation) bool {
+		for _, vms := range violation.Hosts {
+			for _, vmName := range vms {
+				if canAccess(r, services.ResourceVMs, vmName, db.AccessRead) {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	writeProtocolJSON(w, violations)
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"512SvMan/db"
+	"512SvMan/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func setupVirshPowerSchedulesAPI(r chi.Router) {
+	r.Route("/power-schedules", func(r chi.Router) {
+		r.Get("/", listPowerSchedules)
+		r.Post("/", createPowerSchedule)
+		r.Get("/runs", listPowerScheduleRuns)
+		r.Get("/{id}", getPowerSchedule)
+		r.Put("/{id}", updatePowerSchedule)
+		r.Delete("/{id}", deletePowerSchedule)
+		r.Get("/{id}/runs", listPowerScheduleRuns)
+		r.Post("/{id}/run", runPowerSchedule)
+	})
+
+	r.Route("/tags", func(r chi.Router) {
+		r.Get("/", listVMTags)
+		r.Get("/{vm_name}", getVMTags)
+		r.Put("/{vm_name}/{tag}", addVMTag)
+		r.Delete("/{vm_name}/{tag}", removeVMTag)
+	})
+}
+
+func powerScheduleID(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	return id, err == nil
+}
+
+func listPowerSchedules(w http.ResponseWriter, r *http.Request) {
+	service := services.PowerScheduleService{}
+	schedules, err := service.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, schedules)
+}
+
+func getPowerSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := powerScheduleID(r)
+	if !ok {
+		http.Error(w, "invalid schedule id", http.StatusBadRequest)
+		return
+	}
+
+	service := services.PowerScheduleService{}
+	schedule, err := service.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeProtocolJSON(w, schedule)
+}
+
+func createPowerSchedule(w http.ResponseWriter, r *http.Request) {
+	var schedule db.PowerSchedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	service := services.PowerScheduleService{}
+	created, err := service.Create(r.Context(), schedule)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func updatePowerSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := powerScheduleID(r)
+	if !ok {
+		http.Error(w, "invalid schedule id", http.StatusBadRequest)
+		return
+	}
+	var schedule db.PowerSchedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	schedule.Id = id
+
+	service := services.PowerScheduleService{}
+	updated, err := service.Update(r.Context(), schedule)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, updated)
+}
+
+func deletePowerSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := powerScheduleID(r)
+	if !ok {
+		http.Error(w, "invalid schedule id", http.StatusBadRequest)
+		return
+	}
+
+	service := services.PowerScheduleService{}
+	if err := service.Delete(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listPowerScheduleRuns returns the run history of one schedule, or of all of
+// them on /runs, newest first. ?limit= caps it, 100 by default.
+func listPowerScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	id := 0
+	if chi.URLParam(r, "id") != "" {
+		var ok bool
+		if id, ok = powerScheduleID(r); !ok {
+			http.Error(w, "invalid schedule id", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	service := services.PowerScheduleService{}
+	runs, err := service.Runs(r.Context(), id, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, runs)
+}
+
+// runPowerSchedule fires the schedule now, its runs show up in the history.
+func runPowerSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := powerScheduleID(r)
+	if !ok {
+		http.Error(w, "invalid schedule id", http.StatusBadRequest)
+		return
+	}
+
+	service := services.PowerScheduleService{}
+	if err := service.RunNow(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func listVMTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := db.GetAllVMTags(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for vmName := range tags {
+		if !canAccess(r, services.ResourceVMs, vmName, db.AccessRead) {
+			delete(tags, vmName)
+		}
+	}
+	writeProtocolJSON(w, tags)
+}
+
+func getVMTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := db.GetVMTags(r.Context(), chi.URLParam(r, "vm_name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, tags)
+}
+
+func addVMTag(w http.ResponseWriter, r *http.Request) {
+	tag := strings.TrimSpace(chi.URLParam(r, "tag"))
+	if tag == "" {
+		http.Error(w, "tag is required", http.StatusBadRequest)
+		return
+	}
+	if err := db.AddVMTag(r.Context(), chi.URLParam(r, "vm_name"), tag); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func removeVMTag(w http.ResponseWriter, r *http.Request) {
+	if err := db.RemoveVMTag(r.Context(), chi.URLParam(r, "vm_name"), chi.URLParam(r, "tag")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
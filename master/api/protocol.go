@@ -4,6 +4,8 @@ import (
 	"512SvMan/services"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -17,8 +19,218 @@ func listConnections(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(connections)
 }
 
+func writeProtocolJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func getClusterCA(w http.ResponseWriter, r *http.Request) {
+	service := services.PKIService{}
+	writeProtocolJSON(w, service.CAInfo())
+}
+
+func rotateMasterCertificate(w http.ResponseWriter, r *http.Request) {
+	service := services.PKIService{}
+	info, err := service.RotateMasterCertificate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, info)
+}
+
+func listJoinTokens(w http.ResponseWriter, r *http.Request) {
+	service := services.PKIService{}
+	tokens, err := service.ListJoinTokens(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, tokens)
+}
+
+func createJoinToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MachineName     string `json:"machine_name"`
+		ReplaceExisting bool   `json:"replace_existing"`
+		TTLHours        int    `json:"ttl_hours"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.TTLHours < 0 {
+		http.Error(w, "ttl_hours must be positive", http.StatusBadRequest)
+		return
+	}
+
+	service := services.PKIService{}
+	token, err := service.CreateJoinToken(r.Context(), req.MachineName, req.ReplaceExisting, time.Duration(req.TTLHours)*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(token)
+}
+
+func deleteJoinToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid token id", http.StatusBadRequest)
+		return
+	}
+	service := services.PKIService{}
+	if err := service.DeleteJoinToken(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func listNodeCertificates(w http.ResponseWriter, r *http.Request) {
+	service := services.PKIService{}
+	certs, err := service.ListCertificates(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, certs)
+}
+
+func revokeNodeCertificate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	service := services.PKIService{}
+	if err := service.RevokeCertificate(r.Context(), chi.URLParam(r, "serial"), req.Reason); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func rotateNodeCertificate(w http.ResponseWriter, r *http.Request) {
+	service := services.PKIService{}
+	cert, err := service.RotateNodeCertificate(r.Context(), chi.URLParam(r, "machine_name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, cert)
+}
+
+func listNodes(w http.ResponseWriter, r *http.Request) {
+	service := services.NodeService{}
+	nodes, err := service.List(r.Context(), r.URL.Query().Get("state"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, nodes)
+}
+
+func approveNode(w http.ResponseWriter, r *http.Request) {
+	service := services.NodeService{}
+	node, err := service.Approve(r.Context(), chi.URLParam(r, "machine_name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, node)
+}
+
+func rejectNode(w http.ResponseWriter, r *http.Request) {
+	service := services.NodeService{}
+	node, err := service.Reject(r.Context(), chi.URLParam(r, "machine_name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, node)
+}
+
+func renameNode(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DisplayName string `json:"display_name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	service := services.NodeService{}
+	node, err := service.Rename(r.Context(), chi.URLParam(r, "machine_name"), req.DisplayName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, node)
+}
+
+func retireNode(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Force bool `json:"force"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	service := services.NodeService{}
+	node, err := service.Retire(r.Context(), chi.URLParam(r, "machine_name"), req.Force)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, node)
+}
+
+func deleteNode(w http.ResponseWriter, r *http.Request) {
+	service := services.NodeService{}
+	if err := service.Delete(r.Context(), chi.URLParam(r, "machine_name")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func setupProtocolAPI(r chi.Router) chi.Router {
 	return r.Route("/protocol", func(r chi.Router) {
 		r.Get("/list", listConnections)
+
+		r.Route("/nodes", func(r chi.Router) {
+			r.Get("/", listNodes)
+			r.Put("/{machine_name}", renameNode)
+			r.Delete("/{machine_name}", deleteNode)
+			r.Post("/{machine_name}/approve", approveNode)
+			r.Post("/{machine_name}/reject", rejectNode)
+			r.Post("/{machine_name}/retire", retireNode)
+		})
+
+		r.Route("/pki", func(r chi.Router) {
+			r.Get("/ca", getClusterCA)
+			r.Post("/master/rotate", rotateMasterCertificate)
+			r.Get("/tokens", listJoinTokens)
+			r.Post("/tokens", createJoinToken)
+			r.Delete("/tokens/{id}", deleteJoinToken)
+			r.Get("/certificates", listNodeCertificates)
+			r.Post("/certificates/{serial}/revoke", revokeNodeCertificate)
+			r.Post("/nodes/{machine_name}/rotate", rotateNodeCertificate)
+		})
 	})
 }
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"512SvMan/services"
+	"encoding/json"
+	"net/http"
+)
+
+// placeVM previews where the scheduler would put a VM, on failure every slave
+// is still returned with the reasons it was rejected.
+func placeVM(w http.ResponseWriter, r *http.Request) {
+	var req services.PlacementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	scheduler := services.SchedulerService{}
+	placement, err := scheduler.Place(r.Context(), req)
+	if err != nil && placement == nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		placement.Reason = err.Error()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(placement)
+		return
+	}
+	writeProtocolJSON(w, placement)
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"512SvMan/db"
+	"512SvMan/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func userIDParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, name))
+	if err != nil {
+		http.Error(w, "invalid "+name, http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func listUsers(w http.ResponseWriter, r *http.Request) {
+	service := services.UserService{}
+	users, err := service.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, users)
+}
+
+func getUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+	service := services.UserService{}
+	user, err := service.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeProtocolJSON(w, user)
+}
+
+func createUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	service := services.UserService{}
+	user, err := service.Create(r.Context(), req.Username, req.Email, req.Password, req.Role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(user)
+}
+
+func updateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+	var req services.UserUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	service := services.UserService{}
+	user, err := service.Update(r.Context(), id, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, user)
+}
+
+func deleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+	service := services.UserService{}
+	if err := service.Delete(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func setUserGrant(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+	var req db.UserGrant
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	service := services.UserService{}
+	grant, err := service.SetGrant(r.Context(), id, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeProtocolJSON(w, grant)
+}
+
+func deleteUserGrant(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+	grantID, ok := userIDParam(w, r, "grant_id")
+	if !ok {
+		return
+	}
+	service := services.UserService{}
+	if err := service.DeleteGrant(r.Context(), id, grantID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// only admins reach these, see resourceByRoute
+func setupUsersAPI(r chi.Router) chi.Router {
+	return r.Route("/users", func(r chi.Router) {
+		r.Get("/", listUsers)
+		r.Post("/", createUser)
+		r.Get("/{id}", getUser)
+		r.Put("/{id}", updateUser)
+		r.Delete("/{id}", deleteUser)
+		r.Post("/{id}/grants", setUserGrant)
+		r.Delete("/{id}/grants/{grant_id}", deleteUserGrant)
+	})
+}
@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -68,6 +69,8 @@ func createVM(w http.ResponseWriter, r *http.Request) {
 		Live        bool   `json:"live"`
 		AutoStart   bool   `json:"auto_start"`
 		IsWindows   bool   `json:"is_windows"`
+		// optional, fields follow virsh.proto CloudInitConfig
+		CloudInit *grpcVirsh.CloudInitConfig `json:"cloud_init"`
 	}
 
 	var vmReq VMRequest
@@ -77,16 +80,29 @@ func createVM(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	scheduler := services.SchedulerService{}
+	machineName, placement, err := scheduler.Resolve(r.Context(), vmReq.MachineName, services.PlacementRequest{
+		Name:       vmReq.Name,
+		MemoryMB:   int(vmReq.Memory),
+		VCPUs:      int(vmReq.Vcpu),
+		NfsShareId: vmReq.NfsShareId,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	vmReq.MachineName = machineName
+
 	virshServices := services.VirshService{}
 	if vmReq.Live {
-		err = virshServices.CreateLiveVM(r.Context(), vmReq.MachineName, vmReq.Name, vmReq.Memory, vmReq.Vcpu, vmReq.NfsShareId, vmReq.DiskSizeGB, vmReq.IsoID, vmReq.Network, vmReq.VNCPassword, vmReq.CpuXml, vmReq.AutoStart, vmReq.IsWindows, vmReq.TemplateID)
+		err = virshServices.CreateLiveVM(r.Context(), vmReq.MachineName, vmReq.Name, vmReq.Memory, vmReq.Vcpu, vmReq.NfsShareId, vmReq.DiskSizeGB, vmReq.IsoID, vmReq.Network, vmReq.VNCPassword, vmReq.CpuXml, vmReq.AutoStart, vmReq.IsWindows, vmReq.TemplateID, vmReq.CloudInit)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 	} else {
-		err = virshServices.CreateVM(r.Context(), vmReq.MachineName, vmReq.Name, vmReq.Memory, vmReq.Vcpu, vmReq.NfsShareId, vmReq.DiskSizeGB, vmReq.IsoID, vmReq.Network, vmReq.VNCPassword, vmReq.CpuXml, vmReq.AutoStart, vmReq.IsWindows, vmReq.TemplateID)
+		err = virshServices.CreateVM(r.Context(), vmReq.MachineName, vmReq.Name, vmReq.Memory, vmReq.Vcpu, vmReq.NfsShareId, vmReq.DiskSizeGB, vmReq.IsoID, vmReq.Network, vmReq.VNCPassword, vmReq.CpuXml, vmReq.AutoStart, vmReq.IsWindows, vmReq.TemplateID, vmReq.CloudInit)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -95,6 +111,10 @@ func createVM(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusCreated)
+	if placement != nil {
+		w.Write([]byte("VM created successfully on " + placement.Reason))
+		return
+	}
 	w.Write([]byte("VM created successfully"))
 }
 
@@ -114,6 +134,7 @@ func migrateLiveVM(w http.ResponseWriter, r *http.Request) {
 		DestinationMachine string `json:"destination_machine"`
 		Live               bool   `json:"live"`
 		TimeoutSeconds     int    `json:"timeout"`
+		services.MigrationOptions
 	}
 
 	var migReq MigrateRequest
@@ -125,7 +146,7 @@ func migrateLiveVM(w http.ResponseWriter, r *http.Request) {
 
 	virshServices := services.VirshService{}
 
-	err = virshServices.MigrateVm(r.Context(), migReq.OriginMachine, migReq.DestinationMachine, vmName, migReq.Live, migReq.TimeoutSeconds)
+	err = virshServices.MigrateVm(r.Context(), migReq.OriginMachine, migReq.DestinationMachine, vmName, migReq.Live, migReq.TimeoutSeconds, migReq.MigrationOptions)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -135,6 +156,33 @@ func migrateLiveVM(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("VM migrated successfully"))
 }
 
+func listMigrations(w http.ResponseWriter, r *http.Request) {
+	virshServices := services.VirshService{}
+	migrations := slices.DeleteFunc(virshServices.Migrations(), func(migration services.MigrationStatus) bool {
+		return !canAccess(r, services.ResourceVMs, migration.VmName, db.AccessRead)
+	})
+	writeProtocolJSON(w, migrations)
+}
+
+func getMigration(w http.ResponseWriter, r *http.Request) {
+	virshServices := services.VirshService{}
+	migration, err := virshServices.GetMigration(chi.URLParam(r, "vm_name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeProtocolJSON(w, migration)
+}
+
+func cancelMigration(w http.ResponseWriter, r *http.Request) {
+	virshServices := services.VirshService{}
+	if err := virshServices.CancelMigration(r.Context(), chi.URLParam(r, "vm_name")); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Write([]byte("migration cancelled"))
+}
+
 func getAllVms(w http.ResponseWriter, r *http.Request) {
 
 	virshServices := services.VirshService{}
@@ -147,6 +195,10 @@ func getAllVms(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// users with grants on some vms only see those
+	res = slices.DeleteFunc(res, func(vm services.VmType) bool {
+		return !canAccess(r, services.ResourceVMs, vm.Name, db.AccessRead)
+	})
 	w.Header().Set("Content-Type", "application/json")
 	logger.Infof("getAllVms: got %d VMs in %s", len(res), time.Since(start).Round(time.Millisecond))
 
@@ -760,7 +812,7 @@ func addSSHKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	caller := GetTokenFromContext(r)
+	caller := PrincipalFromContext(r).User.Username
 	svc := services.VirshService{}
 
 	err := svc.AddSSHKey(vmName, req.SshKey)
@@ -1509,7 +1561,13 @@ func backupVM(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = virshServices.BackupVM(r.Context(), vmName, nfsIdInt, false)
+	incremental := r.URL.Query().Get("incremental") == "true"
+	storage := services.BackupStorage{
+		Compression: r.URL.Query().Get("compression"),
+		Encrypt:     r.URL.Query().Get("encrypt") == "true",
+	}
+
+	err = virshServices.BackupVM(r.Context(), vmName, nfsIdInt, false, incremental, storage)
 	if err != nil {
 		http.Error(w, "was not possible to backup your vm err: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -1533,10 +1591,21 @@ func getAllBackups(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "error getting all backups "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// the route names no vm, only list the backups of vms the user can read
+	virshBackups = slices.DeleteFunc(virshBackups, func(bak db.VirshBackup) bool {
+		return !canAccess(r, services.ResourceVMs, bak.Name, db.AccessRead)
+	})
+	verifications, err := db.GetLatestBackupVerifications(r.Context())
+	if err != nil {
+		http.Error(w, "error getting backup verifications "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	type Res struct {
 		DbRes db.VirshBackup `json:"db_res"`
 		Live  bool           `json:"live"`
 		Size  float64        `json:"size"`
+		// newest check or test restore, nil when it was never verified
+		Verification *db.BackupVerification `json:"verification"`
 	}
 
 	var res []Res
@@ -1549,6 +1618,9 @@ func getAllBackups(w http.ResponseWriter, r *http.Request) {
 			Live:  exists,
 			Size:  sizeGB,
 		}
+		if v, ok := verifications[bak.Id]; ok {
+			nres.Verification = &v
+		}
 		res = append(res, nres)
 	}
 
@@ -1580,15 +1652,37 @@ func downloadBackup(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if bak == nil {
+	if bak == nil || !canAccess(r, services.ResourceVMs, bak.Name, db.AccessRead) {
 		http.Error(w, "bak not found", http.StatusNotFound)
 		return
 	}
+	if bak.Incremental {
+		http.Error(w, "incremental backups only hold changes since their parent, restore it with useBackup instead", http.StatusConflict)
+		return
+	}
+
+	if !bak.NeedsDecode() {
+		filename := filepath.Base(bak.Path)
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeFile(w, r, bak.Path)
+		return
+	}
 
-	filename := filepath.Base(bak.Path)
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	// packed backups are decoded on the fly, the size is only known at the end
+	virshServices := services.VirshService{}
+	_, stream, err := virshServices.OpenBackup(r.Context(), backupIdInt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer stream.Close()
+
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+bak.Name+".qcow2\"")
 	w.Header().Set("Content-Type", "application/octet-stream")
-	http.ServeFile(w, r, bak.Path)
+	if _, err := io.Copy(w, stream); err != nil {
+		logger.Errorf("download of backup %d: %v", bak.Id, err)
+	}
 }
 
 // ask for new vmname ans use import code
@@ -1614,6 +1708,19 @@ func useBackup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	scheduler := services.SchedulerService{}
+	slaveName, placement, err := scheduler.Resolve(r.Context(), vmReq.Slave_name, services.PlacementRequest{
+		Name:       vmReq.VmName,
+		MemoryMB:   int(vmReq.Memory),
+		VCPUs:      int(vmReq.Vcpu),
+		NfsShareId: vmReq.NfsShareId,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	vmReq.Slave_name = slaveName
+
 	virshServices := services.VirshService{}
 	err = virshServices.UseBackup(r.Context(), backupIdInt,
 		vmReq.Slave_name, vmReq.NfsShareId,
@@ -1633,6 +1740,10 @@ func useBackup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if placement != nil {
+		writeProtocolJSON(w, placement)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -1722,6 +1833,14 @@ func createAutoBak(w http.ResponseWriter, r *http.Request) {
 		MaxTime          db.Clock `json:"max_time"`
 		NfsMountId       int      `json:"nfs_mount_id"`
 		MaxBackupsRetain int      `json:"max_backups_retain"`
+		FullEvery        int      `json:"full_every"`
+		KeepDaily        int      `json:"keep_daily"`
+		KeepWeekly       int      `json:"keep_weekly"`
+		KeepMonthly      int      `json:"keep_monthly"`
+		KeepYearly       int      `json:"keep_yearly"`
+		MinAgeDays       int      `json:"min_age_days"`
+		Compression      string   `json:"compression"`
+		Encrypt          bool     `json:"encrypt"`
 	}
 
 	var req Req
@@ -1738,6 +1857,14 @@ func createAutoBak(w http.ResponseWriter, r *http.Request) {
 		MinTime:          req.MinTime,
 		NfsMountId:       req.NfsMountId,
 		MaxBackupsRetain: req.MaxBackupsRetain,
+		FullEvery:        req.FullEvery,
+		KeepDaily:        req.KeepDaily,
+		KeepWeekly:       req.KeepWeekly,
+		KeepMonthly:      req.KeepMonthly,
+		KeepYearly:       req.KeepYearly,
+		MinAgeDays:       req.MinAgeDays,
+		Compression:      req.Compression,
+		Encrypt:          req.Encrypt,
 		Enabled:          true,
 	})
 
@@ -1754,6 +1881,9 @@ func getAutoBak(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	baks = slices.DeleteFunc(baks, func(bak db.AutomaticBackup) bool {
+		return !canAccess(r, services.ResourceVMs, bak.VmName, db.AccessRead)
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	data, err := json.Marshal(baks)
@@ -1777,6 +1907,14 @@ func updateAutoBak(w http.ResponseWriter, r *http.Request) {
 		MaxTime          db.Clock `json:"max_time"`
 		NfsMountId       int      `json:"nfs_mount_id"`
 		MaxBackupsRetain int      `json:"max_backups_retain"`
+		FullEvery        int      `json:"full_every"`
+		KeepDaily        int      `json:"keep_daily"`
+		KeepWeekly       int      `json:"keep_weekly"`
+		KeepMonthly      int      `json:"keep_monthly"`
+		KeepYearly       int      `json:"keep_yearly"`
+		MinAgeDays       int      `json:"min_age_days"`
+		Compression      string   `json:"compression"`
+		Encrypt          bool     `json:"encrypt"`
 	}
 
 	var req Req
@@ -1799,6 +1937,14 @@ func updateAutoBak(w http.ResponseWriter, r *http.Request) {
 		MinTime:          req.MinTime,
 		NfsMountId:       req.NfsMountId,
 		MaxBackupsRetain: req.MaxBackupsRetain,
+		FullEvery:        req.FullEvery,
+		KeepDaily:        req.KeepDaily,
+		KeepWeekly:       req.KeepWeekly,
+		KeepMonthly:      req.KeepMonthly,
+		KeepYearly:       req.KeepYearly,
+		MinAgeDays:       req.MinAgeDays,
+		Compression:      req.Compression,
+		Encrypt:          req.Encrypt,
 		Enabled:          true,
 	})
 
@@ -1893,6 +2039,7 @@ func moveDisk(w http.ResponseWriter, r *http.Request) {
 	type MoveReq struct {
 		NewName    string `json:"new_name"`
 		TemplateID int    `json:"template_id"`
+		Linked     bool   `json:"linked"` // thin overlay on top of a golden image
 	}
 	var mr MoveReq
 	if err := json.NewDecoder(r.Body).Decode(&mr); err != nil && err != io.EOF {
@@ -1918,6 +2065,36 @@ func moveDisk(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func moveDiskLive(w http.ResponseWriter, r *http.Request) {
+	vmName := chi.URLParam(r, "vm_name")
+	destNfs, err := strconv.Atoi(chi.URLParam(r, "dest_nfs"))
+	if err != nil {
+		http.Error(w, "invalid dest_nfs: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// vm_disk_id picks an attached VM disk instead of the vm's own disk
+	type MoveLiveReq struct {
+		VMDiskID        int    `json:"vm_disk_id"`
+		MaxBandwidthMiB uint64 `json:"max_bandwidth_mib"`
+	}
+	var mr MoveLiveReq
+	if err := json.NewDecoder(r.Body).Decode(&mr); err != nil && err != io.EOF {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	virshService := services.VirshService{}
+	jobID, err := virshService.MoveDiskLive(r.Context(), vmName, destNfs, mr.VMDiskID, mr.MaxBandwidthMiB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(jobStartedResponse{JobId: jobID})
+}
+
 func coldMigrate(w http.ResponseWriter, r *http.Request) {
 	vm_name := chi.URLParam(r, "vm_name")
 	if vm_name == "" {
@@ -1973,6 +2150,7 @@ func cloneVM(w http.ResponseWriter, r *http.Request) {
 	type CloneRequest struct {
 		NewName    string `json:"new_name"`
 		TemplateID int    `json:"template_id"`
+		Linked     bool   `json:"linked"` // thin overlay on top of a golden image
 	}
 	var creq CloneRequest
 	if err := json.NewDecoder(r.Body).Decode(&creq); err != nil && err != io.EOF {
@@ -1988,13 +2166,28 @@ func cloneVM(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	scheduler := services.SchedulerService{}
+	dest_machine_name, placement, err := scheduler.Resolve(r.Context(), dest_machine_name, services.PlacementRequest{
+		Name:       newName,
+		VmName:     vm_name,
+		NfsShareId: destNfs,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
 	virshService := services.VirshService{}
-	err = virshService.CloneVM(r.Context(), vm_name, newName, dest_machine_name, destNfs, creq.TemplateID)
+	err = virshService.CloneVM(r.Context(), vm_name, newName, dest_machine_name, destNfs, creq.TemplateID, creq.Linked)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if placement != nil {
+		writeProtocolJSON(w, placement)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -2503,12 +2696,27 @@ func setupVirshAPI(r chi.Router) chi.Router {
 	extra.RegisterCallFunction(websocketusInfoVms)
 	return r.Route("/virsh", func(r chi.Router) {
 		setupVirshXMLTemplatesAPI(r)
+		setupVirshSnapshotsAPI(r)
+		setupVirshGoldenAPI(r)
+		setupVirshHAAPI(r)
+		setupVirshPlacementGroupsAPI(r)
+		setupVirshAutoStartGroupsAPI(r)
+		setupVirshPowerSchedulesAPI(r)
+		setupVirshBackupVerifyAPI(r)
+		setupVirshBackupRetentionAPI(r)
+		setupVirshBackupStorageAPI(r)
+		setupVirshBackupReposAPI(r)
+		setupVirshFileRestoreAPI(r)
 
 		r.Get("/getcpudisablefeatures", getCpuFeatures)
 		r.Get("/getallvms", getAllVms)
 		r.Post("/createvm", createVM)
+		r.Post("/placement", placeVM)
 
 		r.Post("/migratevm/{vm_name}", migrateLiveVM)
+		r.Get("/migrations", listMigrations)
+		r.Get("/migrations/{vm_name}", getMigration)
+		r.Post("/migrations/{vm_name}/cancel", cancelMigration)
 		r.Post("/updatecpuxml/{vm_name}", updateCpuXml)
 		r.Get("/cpuxml/{vm_name}", getCpuXML)
 		r.Post("/updatevmxml/{vm_name}", updateVmXML)
@@ -2524,6 +2732,8 @@ func setupVirshAPI(r chi.Router) chi.Router {
 		r.Post("/pausevm/{vm_name}", pauseVm)
 		r.Post("/resumevm/{vm_name}", resumeVm)
 		r.Get("/getvmbyname/{vm_name}", getVmByName)
+		// under /virsh so the VM permissions apply, not the system ones
+		r.Get("/history/{vm_name}", getVMHistory)
 		r.Post("/removeiso/{vm_name}", removeIso)
 		r.Post("/vm_disk/{vm_name}", addVmDiskToVM)
 		r.Get("/vm_disk/{vm_name}", listVmDisksForVM)
@@ -2566,6 +2776,7 @@ func setupVirshAPI(r chi.Router) chi.Router {
 
 		//move
 		r.Post("/moveDisk/{vm_name}/{dest_nfs}", moveDisk)
+		r.Post("/moveDiskLive/{vm_name}/{dest_nfs}", moveDiskLive)
 		r.Post("/coldMigrate/{vm_name}/{dest_machine_name}", coldMigrate)
 		r.Post("/cloneVM/{vm_name}/{dest_nfs}/{dest_machine_name}", cloneVM)
 
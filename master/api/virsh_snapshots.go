@@ -0,0 +1,102 @@
+package api
+
+import (
+	"512SvMan/services"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func setupVirshSnapshotsAPI(r chi.Router) {
+	r.Route("/snapshots/{vm_name}", func(r chi.Router) {
+		r.Get("/", listSnapshots)
+		r.Post("/", createSnapshot)
+		r.Post("/{snapshot_name}/revert", revertSnapshot)
+		r.Delete("/{snapshot_name}", deleteSnapshot)
+	})
+}
+
+func listSnapshots(w http.ResponseWriter, r *http.Request) {
+	vmName := chi.URLParam(r, "vm_name")
+	if vmName == "" {
+		http.Error(w, "vm_name is required", http.StatusBadRequest)
+		return
+	}
+
+	virshServices := services.VirshService{}
+	snaps, err := virshServices.ListSnapshots(vmName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	data, err := json.Marshal(snaps)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
+
+func createSnapshot(w http.ResponseWriter, r *http.Request) {
+	vmName := chi.URLParam(r, "vm_name")
+	if vmName == "" {
+		http.Error(w, "vm_name is required", http.StatusBadRequest)
+		return
+	}
+
+	var req services.CreateSnapshotParams
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	virshServices := services.VirshService{}
+	snap, err := virshServices.CreateSnapshot(vmName, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	data, err := json.Marshal(snap)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
+
+func revertSnapshot(w http.ResponseWriter, r *http.Request) {
+	vmName := chi.URLParam(r, "vm_name")
+	snapshotName := chi.URLParam(r, "snapshot_name")
+	if vmName == "" || snapshotName == "" {
+		http.Error(w, "vm_name and snapshot_name are required", http.StatusBadRequest)
+		return
+	}
+
+	virshServices := services.VirshService{}
+	if err := virshServices.RevertSnapshot(vmName, snapshotName); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func deleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	vmName := chi.URLParam(r, "vm_name")
+	snapshotName := chi.URLParam(r, "snapshot_name")
+	if vmName == "" || snapshotName == "" {
+		http.Error(w, "vm_name and snapshot_name are required", http.StatusBadRequest)
+		return
+	}
+
+	virshServices := services.VirshService{}
+	if err := virshServices.DeleteSnapshot(vmName, snapshotName); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
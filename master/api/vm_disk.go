@@ -49,6 +49,25 @@ func createVMDisk(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func importVMDisk(w http.ResponseWriter, r *http.Request) {
+	var reqBody services.ImportVMDiskParams
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	service := services.VMDiskService{}
+	if err := service.ImportAsync(r.Context(), reqBody); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "started"})
+}
+
 func deleteVMDisk(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
@@ -98,11 +117,95 @@ func growVMDisk(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func markVMDiskGolden(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid vm disk id", http.StatusBadRequest)
+		return
+	}
+
+	service := services.VMDiskService{}
+	if err := service.MarkGolden(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func unmarkVMDiskGolden(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid vm disk id", http.StatusBadRequest)
+		return
+	}
+
+	service := services.VMDiskService{}
+	if err := service.UnmarkGolden(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func linkedCloneVMDisk(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid vm disk id", http.StatusBadRequest)
+		return
+	}
+
+	var reqBody struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	service := services.VMDiskService{}
+	res, err := service.LinkedClone(r.Context(), id, reqBody.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func flattenVMDisk(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid vm disk id", http.StatusBadRequest)
+		return
+	}
+
+	service := services.VMDiskService{}
+	res, err := service.Flatten(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func setupVMDiskAPI(r chi.Router) chi.Router {
 	return r.Route("/vm-disk", func(r chi.Router) {
 		r.Get("/list", listVMDisk)
 		r.Post("/create", createVMDisk)
+		r.Post("/import", importVMDisk)
 		r.Delete("/{id}", deleteVMDisk)
 		r.Post("/{id}/grow", growVMDisk)
+		r.Post("/{id}/golden", markVMDiskGolden)
+		r.Delete("/{id}/golden", unmarkVMDiskGolden)
+		r.Post("/{id}/clone", linkedCloneVMDisk)
+		r.Post("/{id}/flatten", flattenVMDisk)
 	})
 }
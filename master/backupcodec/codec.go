@@ -0,0 +1,228 @@
+// Package backupcodec turns a qcow2 backup into the file stored on the share
+// and back: an optional zstd stream with optional encryption on top.
+//
+// qcow2 compressed clusters are written by qemu-img on a slave, the result is
+// still a plain qcow2 so nothing here has to undo it.
+package backupcodec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	CompressionNone  = ""
+	CompressionQcow2 = "qcow2"
+	CompressionZstd  = "zstd"
+)
+
+func ValidCompression(c string) bool {
+	return c == CompressionNone || c == CompressionQcow2 || c == CompressionZstd
+}
+
+// Extension is what Encode appends to the qcow2 file name.
+func Extension(compression string, encrypted bool) string {
+	ext := ""
+	if compression == CompressionZstd {
+		ext += ".zst"
+	}
+	if encrypted {
+		ext += ".enc"
+	}
+	return ext
+}
+
+// Encode writes src to dst compressed with zstd and/or encrypted with key, a
+// nil key leaves it unencrypted. dst must not exist and is removed on error.
+func Encode(ctx context.Context, src, dst, compression string, key []byte) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(dst)
+		}
+	}()
+
+	var w io.Writer = out
+	var enc io.WriteCloser
+	if key != nil {
+		if enc, err = NewEncryptWriter(out, key); err != nil {
+			return err
+		}
+		w = enc
+	}
+
+	if compression == CompressionZstd {
+		err = runZstd(ctx, in, w, "-q", "-c", "-T0")
+	} else {
+		_, err = io.Copy(w, contextReader{ctx, in})
+	}
+	if err != nil {
+		return err
+	}
+	if enc != nil {
+		if err = enc.Close(); err != nil {
+			return err
+		}
+	}
+	return out.Sync()
+}
+
+// Open returns the plain qcow2 stream of a file written by Encode.
+func Open(ctx context.Context, path, compression string, key []byte) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader = f
+	if key != nil {
+		if r, err = NewDecryptReader(f, key); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if compression != CompressionZstd {
+		return readCloser{r, f.Close}, nil
+	}
+
+	cmd := exec.CommandContext(ctx, "zstd", "-q", "-d", "-c")
+	cmd.Stdin = r
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("start zstd: %w", err)
+	}
+	return &zstdReader{stdout: stdout, cmd: cmd, stderr: stderr, file: f}, nil
+}
+
+// Decode writes the plain qcow2 of a file written by Encode to dst.
+func Decode(ctx context.Context, src, dst, compression string, key []byte) (err error) {
+	in, err := Open(ctx, src, compression, key)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(dst)
+		}
+	}()
+
+	if _, err = io.Copy(out, contextReader{ctx, in}); err != nil {
+		return err
+	}
+	if err = in.Close(); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+func runZstd(ctx context.Context, in io.Reader, out io.Writer, args ...string) error {
+	cmd := exec.CommandContext(ctx, "zstd", args...)
+	cmd.Stdin = in
+	cmd.Stdout = out
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("zstd: %s", msg)
+		}
+		return fmt.Errorf("zstd: %w", err)
+	}
+	return nil
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error {
+	return r.close()
+}
+
+// zstdReader reports a failed decompression at the end of the stream instead
+// of a silent short read.
+type zstdReader struct {
+	stdout io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+	file   *os.File
+	waited bool
+	err    error
+}
+
+func (z *zstdReader) wait() error {
+	if z.waited {
+		return z.err
+	}
+	z.waited = true
+	if err := z.cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(z.stderr.String()); msg != "" {
+			z.err = fmt.Errorf("zstd: %s", msg)
+		} else {
+			z.err = fmt.Errorf("zstd: %w", err)
+		}
+	}
+	return z.err
+}
+
+func (z *zstdReader) Read(p []byte) (int, error) {
+	n, err := z.stdout.Read(p)
+	if err == io.EOF {
+		if werr := z.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (z *zstdReader) Close() error {
+	if !z.waited {
+		_ = z.cmd.Process.Kill()
+		_ = z.wait()
+	}
+	return z.file.Close()
+}
@@ -0,0 +1,133 @@
+package backupcodec
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func encrypt(t *testing.T, plain, key []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := NewEncryptWriter(&out, key)
+	if err != nil {
+		t.Fatalf("NewEncryptWriter: %v", err)
+	}
+	// odd write sizes so chunks never line up with writes
+	for rest := plain; len(rest) > 0; {
+		n := 1000
+		if n > len(rest) {
+			n = len(rest)
+		}
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		rest = rest[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return out.Bytes()
+}
+
+func decrypt(sealed, key []byte) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(sealed), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, 2*chunkSize + 5} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		sealed := encrypt(t, plain, key)
+		got, err := decrypt(sealed, key)
+		if err != nil {
+			t.Fatalf("size %d: decrypt: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("size %d: round trip changed the data", size)
+		}
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	key, _ := NewKey()
+	plain := make([]byte, 3*chunkSize)
+	rand.Read(plain)
+	sealed := encrypt(t, plain, key)
+	header := len(magic) + prefixSize
+
+	flipped := append([]byte(nil), sealed...)
+	flipped[header+10] ^= 1
+	// a whole chunk dropped at a chunk boundary
+	truncated := sealed[:header+2*sealedSize]
+	otherKey, _ := NewKey()
+
+	for name, tc := range map[string]struct {
+		data []byte
+		key  []byte
+	}{
+		"flipped bit": {flipped, key},
+		"truncated":   {truncated, key},
+		"wrong key":   {sealed, otherKey},
+	} {
+		if _, err := decrypt(tc.data, tc.key); !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("%s: expected ErrCorrupt, got %v", name, err)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "web.qcow2")
+	plain := bytes.Repeat([]byte("qcow2 clusters "), 50000)
+	if err := os.WriteFile(src, plain, 0o644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	key, _ := NewKey()
+
+	type format struct {
+		compression string
+		key         []byte
+	}
+	cases := []format{{CompressionNone, key}}
+	if _, err := exec.LookPath("zstd"); err == nil {
+		cases = append(cases, format{CompressionZstd, nil}, format{CompressionZstd, key})
+	}
+
+	for _, tc := range cases {
+		stored := src + Extension(tc.compression, tc.key != nil)
+		if err := Encode(ctx, src, stored, tc.compression, tc.key); err != nil {
+			t.Fatalf("%q/%v: encode: %v", tc.compression, tc.key != nil, err)
+		}
+		restored := filepath.Join(dir, "restored.qcow2")
+		if err := Decode(ctx, stored, restored, tc.compression, tc.key); err != nil {
+			t.Fatalf("%q/%v: decode: %v", tc.compression, tc.key != nil, err)
+		}
+		got, err := os.ReadFile(restored)
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Fatalf("%q/%v: decoded file differs (%v)", tc.compression, tc.key != nil, err)
+		}
+		os.Remove(stored)
+		os.Remove(restored)
+	}
+
+	if err := Encode(ctx, src, src, CompressionNone, key); err == nil {
+		t.Fatalf("encode must refuse to overwrite an existing file")
+	}
+}
@@ -0,0 +1,200 @@
+package backupcodec
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// An encrypted file is the magic, a random nonce prefix and then the plain
+// text in chunkSize pieces each sealed with AES-256-GCM. The nonce of a chunk
+// is the prefix, the chunk counter and a flag set only on the last chunk, so
+// reordered, dropped or truncated chunks fail to open.
+const (
+	KeySize = 32
+
+	magic       = "512BAK\x00\x01"
+	prefixSize  = 7
+	chunkSize   = 64 * 1024
+	sealedSize  = chunkSize + 16
+	maxChunks   = 1<<32 - 1
+	lastChunk   = 1
+	middleChunk = 0
+)
+
+var ErrCorrupt = errors.New("encrypted backup is corrupt, truncated or the key is wrong")
+
+// NewKey returns a random key for NewEncryptWriter.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("backup key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32, flag byte) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[prefixSize:], counter)
+	nonce[11] = flag
+	return nonce
+}
+
+type encryptWriter struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+// NewEncryptWriter encrypts everything written to it into dst. Close must be
+// called to write the last chunk, it does not close dst.
+func NewEncryptWriter(dst io.Writer, key []byte) (io.WriteCloser, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(append([]byte(magic), prefix...)); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{dst: dst, aead: aead, prefix: prefix, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (w *encryptWriter) seal(flag byte) error {
+	if w.counter == maxChunks {
+		return fmt.Errorf("backup too large to encrypt")
+	}
+	sealed := w.aead.Seal(nil, chunkNonce(w.prefix, w.counter, flag), w.buf, nil)
+	w.counter++
+	w.buf = w.buf[:0]
+	_, err := w.dst.Write(sealed)
+	return err
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encrypt writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		// a full chunk is only sealed once more data shows it isn't the last
+		if len(w.buf) == chunkSize {
+			if err := w.seal(middleChunk); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *encryptWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(lastChunk)
+}
+
+type decryptReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	sealed  []byte
+	buf     []byte
+	plain   []byte
+	done    bool
+}
+
+// NewDecryptReader reads the plain text of a file written by NewEncryptWriter.
+// Every chunk is authenticated before it is returned, ErrCorrupt is returned
+// for tampered or truncated input.
+func NewDecryptReader(src io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(magic)+prefixSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, ErrCorrupt
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, fmt.Errorf("not an encrypted backup")
+	}
+	return &decryptReader{
+		src:    bufio.NewReaderSize(src, sealedSize),
+		aead:   aead,
+		prefix: header[len(magic):],
+		sealed: make([]byte, sealedSize),
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (r *decryptReader) next() error {
+	n, err := io.ReadFull(r.src, r.sealed)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, err := r.src.Peek(1); err == io.EOF {
+			last = true
+		}
+	}
+
+	flag := byte(middleChunk)
+	if last {
+		flag = lastChunk
+	}
+	plain, err := r.aead.Open(r.buf[:0], chunkNonce(r.prefix, r.counter, flag), r.sealed[:n], nil)
+	if err != nil {
+		return ErrCorrupt
+	}
+	r.counter++
+	r.plain = plain
+	r.done = last
+	return nil
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
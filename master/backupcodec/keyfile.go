@@ -0,0 +1,127 @@
+package backupcodec
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// A key file carries backup keys off the master, sealed with AES-256-GCM under
+// a key derived from an operator passphrase with argon2id. The parameters are
+// stored with it so a file keeps opening if the defaults change.
+const (
+	keyFileMagic         = "512KEYS\x00\x01"
+	keyFileVersion       = 1
+	keyFileKDF           = "argon2id"
+	MinKeyFilePassphrase = 12
+
+	argonTime    = 3
+	argonMemory  = 64 * 1024 // KiB
+	argonThreads = 4
+	// an imported file can't make the master burn more than this
+	maxArgonTime   = 10
+	maxArgonMemory = 1024 * 1024
+)
+
+var ErrKeyFilePassphrase = errors.New("wrong passphrase or the key file was modified")
+
+// KeyFileEntry is one backup key, Id must stay the same for the backups that
+// reference it.
+type KeyFileEntry struct {
+	Id        int    `json:"id"`
+	Key       []byte `json:"key"`
+	CreatedAt string `json:"created_at"`
+}
+
+type keyFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Sealed  []byte `json:"sealed"`
+}
+
+func keyFileKey(passphrase string, f *keyFile) ([]byte, error) {
+	if f.Version != keyFileVersion || f.KDF != keyFileKDF {
+		return nil, fmt.Errorf("unsupported key file version %d (%s)", f.Version, f.KDF)
+	}
+	if f.Time == 0 || f.Time > maxArgonTime || f.Memory == 0 || f.Memory > maxArgonMemory || f.Threads == 0 {
+		return nil, fmt.Errorf("key file has out of range argon2id parameters")
+	}
+	return argon2.IDKey([]byte(passphrase), f.Salt, f.Time, f.Memory, f.Threads, KeySize), nil
+}
+
+// SealKeyFile encrypts keys with passphrase into a JSON document.
+func SealKeyFile(keys []KeyFileEntry, passphrase string) ([]byte, error) {
+	if len(passphrase) < MinKeyFilePassphrase {
+		return nil, fmt.Errorf("passphrase must be at least %d characters", MinKeyFilePassphrase)
+	}
+	plain, err := json.Marshal(keys)
+	if err != nil {
+		return nil, err
+	}
+	f := &keyFile{
+		Version: keyFileVersion,
+		KDF:     keyFileKDF,
+		Time:    argonTime,
+		Memory:  argonMemory,
+		Threads: argonThreads,
+		Salt:    make([]byte, 16),
+	}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return nil, err
+	}
+	key, err := keyFileKey(passphrase, f)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	f.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return nil, err
+	}
+	f.Sealed = aead.Seal(nil, f.Nonce, plain, []byte(keyFileMagic))
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// OpenKeyFile decrypts a document written by SealKeyFile.
+func OpenKeyFile(data []byte, passphrase string) ([]KeyFileEntry, error) {
+	var f keyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("not a backup key file: %w", err)
+	}
+	key, err := keyFileKey(passphrase, &f)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != aead.NonceSize() {
+		return nil, ErrKeyFilePassphrase
+	}
+	plain, err := aead.Open(nil, f.Nonce, f.Sealed, []byte(keyFileMagic))
+	if err != nil {
+		return nil, ErrKeyFilePassphrase
+	}
+	var keys []KeyFileEntry
+	if err := json.Unmarshal(plain, &keys); err != nil {
+		return nil, fmt.Errorf("key file content: %w", err)
+	}
+	for _, k := range keys {
+		if len(k.Key) != KeySize || k.Id <= 0 {
+			return nil, fmt.Errorf("key file holds an invalid key %d", k.Id)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,56 @@
+package backupcodec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestKeyFileRoundTrip(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+	keys := []KeyFileEntry{{Id: 3, Key: key, CreatedAt: "2026-01-01T00:00:00Z"}}
+	passphrase := "correct horse battery"
+
+	if _, err := SealKeyFile(keys, "short"); err == nil {
+		t.Fatalf("expected a short passphrase to be refused")
+	}
+	sealed, err := SealKeyFile(keys, passphrase)
+	if err != nil {
+		t.Fatalf("SealKeyFile: %v", err)
+	}
+	if bytes.Contains(sealed, key) {
+		t.Fatalf("key file holds the key in the clear")
+	}
+
+	got, err := OpenKeyFile(sealed, passphrase)
+	if err != nil {
+		t.Fatalf("OpenKeyFile: %v", err)
+	}
+	if len(got) != 1 || got[0].Id != 3 || !bytes.Equal(got[0].Key, key) || got[0].CreatedAt != keys[0].CreatedAt {
+		t.Fatalf("round trip changed the keys: %+v", got)
+	}
+
+	if _, err := OpenKeyFile(sealed, passphrase+"!"); !errors.Is(err, ErrKeyFilePassphrase) {
+		t.Fatalf("expected the wrong passphrase to fail, got %v", err)
+	}
+
+	var f keyFile
+	if err := json.Unmarshal(sealed, &f); err != nil {
+		t.Fatalf("key file is not json: %v", err)
+	}
+	f.Sealed[0] ^= 1
+	tampered, _ := json.Marshal(f)
+	if _, err := OpenKeyFile(tampered, passphrase); !errors.Is(err, ErrKeyFilePassphrase) {
+		t.Fatalf("expected a modified key file to fail, got %v", err)
+	}
+	f.Sealed[0] ^= 1
+	f.Memory = maxArgonMemory + 1
+	expensive, _ := json.Marshal(f)
+	if _, err := OpenKeyFile(expensive, passphrase); err == nil {
+		t.Fatalf("expected out of range argon2id parameters to be refused")
+	}
+}
@@ -0,0 +1,179 @@
+package backuprepo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	localChunkSize = 8 * 1024 * 1024
+	partialSuffix  = ".partial"
+)
+
+// local is a directory on the master, usually a mount of a disk or NAS that
+// isn't the one the vms run from.
+type local struct {
+	root  string
+	limit int64
+}
+
+func newLocal(cfg Config) (*local, error) {
+	if !filepath.IsAbs(cfg.Path) {
+		return nil, fmt.Errorf("local repository path must be absolute, got %q", cfg.Path)
+	}
+	info, err := os.Stat(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", cfg.Path)
+	}
+	return &local{root: cfg.Path, limit: cfg.BandwidthLimit}, nil
+}
+
+func (l *local) path(key string) (string, error) {
+	clean, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(clean)), nil
+}
+
+// Upload appends to the .partial file a previous attempt left and renames it
+// once complete.
+func (l *local) Upload(ctx context.Context, key, localPath string, progress func(done, total int64)) error {
+	dest, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	total := info.Size()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	partial := dest + partialSuffix
+	out, err := os.OpenFile(partial, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	done, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if done > total {
+		// not a previous attempt of this file, start over
+		if err := out.Truncate(0); err != nil {
+			return err
+		}
+		done = 0
+	}
+	if _, err := src.Seek(done, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := out.Seek(done, io.SeekStart); err != nil {
+		return err
+	}
+
+	t := newThrottle(l.limit)
+	buf := make([]byte, localChunkSize)
+	for done < total {
+		if progress != nil {
+			progress(done, total)
+		}
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := out.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			done += int64(n)
+			if werr := t.wait(ctx, n); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if done != total {
+		return fmt.Errorf("%s changed size during the upload", localPath)
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if progress != nil {
+		progress(total, total)
+	}
+	return os.Rename(partial, dest)
+}
+
+func (l *local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return throttleReadCloser(ctx, f, l.limit), nil
+}
+
+func (l *local) Size(ctx context.Context, key string) (int64, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Delete removes the object and the directories it leaves empty.
+func (l *local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	_ = os.Remove(p + partialSuffix)
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for dir := filepath.Dir(p); dir != l.root && len(dir) > len(l.root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (l *local) Close() error {
+	return nil
+}
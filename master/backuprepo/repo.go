@@ -0,0 +1,145 @@
+// Package backuprepo stores backup files off the cluster: on a local path, on
+// S3-compatible object storage or on an SFTP server.
+//
+// Uploads are chunked and resumable, an upload interrupted by a crash or a
+// lost connection continues from what the repository already has.
+package backuprepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	KindLocal = "local"
+	KindS3    = "s3"
+	KindSFTP  = "sftp"
+)
+
+var ErrNotFound = errors.New("not found in the repository")
+
+// Repository is where replicated backups live, keys are slash separated
+// paths relative to the repository root.
+type Repository interface {
+	// Upload stores the file at localPath under key, progress gets the bytes
+	// stored so far including the ones a previous attempt already stored.
+	Upload(ctx context.Context, key, localPath string, progress func(done, total int64)) error
+	// Open streams the object under key.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Size is the size of the object under key, ErrNotFound when it is missing.
+	Size(ctx context.Context, key string) (int64, error)
+	Delete(ctx context.Context, key string) error
+	Close() error
+}
+
+// Config describes a repository, which fields apply depends on Kind.
+type Config struct {
+	Kind string
+	// local directory, sftp directory or s3 key prefix
+	Path string
+	// s3 url like http://minio:9000, sftp host:port
+	Endpoint string
+	Bucket   string
+	Region   string
+	// PathStyle puts the bucket in the path instead of the host name, MinIO
+	// needs it
+	PathStyle bool
+	// s3 access key or sftp user
+	AccessKey string
+	// s3 secret key or sftp password
+	SecretKey string
+	// sftp private key in PEM
+	PrivateKey string
+	// sftp host key in authorized_keys format, empty trusts the first key
+	// seen and reports it through OnHostKey
+	HostKey   string
+	OnHostKey func(key string)
+	// BandwidthLimit caps uploads and downloads in bytes per second, 0 is
+	// unlimited
+	BandwidthLimit int64
+}
+
+// New opens the repository described by cfg.
+func New(ctx context.Context, cfg Config) (Repository, error) {
+	switch cfg.Kind {
+	case KindLocal:
+		return newLocal(cfg)
+	case KindS3:
+		return newS3(cfg)
+	case KindSFTP:
+		return newSFTP(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unknown repository kind %q", cfg.Kind)
+	}
+}
+
+// cleanKey refuses keys that would escape the repository root.
+func cleanKey(key string) (string, error) {
+	clean := path.Clean("/" + key)[1:]
+	if clean == "" || clean != strings.TrimPrefix(key, "/") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return clean, nil
+}
+
+// throttle sleeps so that n bytes moved since start stay under limit bytes
+// per second.
+type throttle struct {
+	limit int64
+	start time.Time
+	moved int64
+}
+
+func newThrottle(limit int64) *throttle {
+	return &throttle{limit: limit, start: time.Now()}
+}
+
+func (t *throttle) wait(ctx context.Context, n int) error {
+	if t == nil || t.limit <= 0 {
+		return ctx.Err()
+	}
+	t.moved += int64(n)
+	due := t.start.Add(time.Duration(float64(t.moved) / float64(t.limit) * float64(time.Second)))
+	if d := time.Until(due); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
+
+type throttledReader struct {
+	ctx context.Context
+	r   io.Reader
+	t   *throttle
+}
+
+func (r throttledReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if werr := r.t.wait(r.ctx, n); werr != nil && err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// throttleReadCloser caps a download at limit bytes per second.
+func throttleReadCloser(ctx context.Context, rc io.ReadCloser, limit int64) io.ReadCloser {
+	if limit <= 0 {
+		return rc
+	}
+	return readCloser{throttledReader{ctx, rc, newThrottle(limit)}, rc}
+}
@@ -0,0 +1,474 @@
+package backuprepo
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3MinPartSize = 64 * 1024 * 1024
+	// S3 allows 10000 parts, the rest is headroom
+	s3MaxParts      = 9000
+	s3DefaultRegion = "us-east-1"
+	emptySHA256     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+type s3Repo struct {
+	endpoint  *url.URL
+	bucket    string
+	prefix    string
+	region    string
+	pathStyle bool
+	accessKey string
+	secretKey string
+	limit     int64
+	client    *http.Client
+	now       func() time.Time
+}
+
+func newS3(cfg Config) (*s3Repo, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("s3 endpoint must be an http(s) url, got %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("s3 access key and secret key are required")
+	}
+	region := cfg.Region
+	if region == "" {
+		region = s3DefaultRegion
+	}
+	return &s3Repo{
+		endpoint:  u,
+		bucket:    cfg.Bucket,
+		prefix:    strings.Trim(cfg.Path, "/"),
+		region:    region,
+		pathStyle: cfg.PathStyle,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		limit:     cfg.BandwidthLimit,
+		client:    &http.Client{},
+		now:       time.Now,
+	}, nil
+}
+
+// s3PartSize is a function of the size only, so a resumed upload cuts the
+// file exactly like the attempt it continues.
+func s3PartSize(size int64) int64 {
+	part := int64(s3MinPartSize)
+	for size/part >= s3MaxParts {
+		part *= 2
+	}
+	return part
+}
+
+func (s *s3Repo) objectKey(key string) (string, error) {
+	clean, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if s.prefix == "" {
+		return clean, nil
+	}
+	return s.prefix + "/" + clean, nil
+}
+
+func (s *s3Repo) url(objectKey string, query url.Values) *url.URL {
+	u := *s.endpoint
+	if s.pathStyle {
+		u.Path = "/" + s.bucket + "/" + objectKey
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = "/" + objectKey
+	}
+	// sent exactly as signed
+	u.RawPath = canonicalURI(u.Path)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// do signs and sends a request, any status outside 2xx is an error.
+func (s *s3Repo) do(ctx context.Context, method, objectKey string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	return s.send(ctx, method, objectKey, query, body, header, nil)
+}
+
+// send is do with the body streamed through t, so the limit holds while the
+// bytes are on the wire instead of between requests.
+func (s *s3Repo) send(ctx context.Context, method, objectKey string, query url.Values, body []byte, header http.Header, t *throttle) (*http.Response, error) {
+	var reader io.Reader
+	payloadHash := emptySHA256
+	if body != nil {
+		reader = bytes.NewReader(body)
+		if t != nil && t.limit > 0 {
+			reader = throttledReader{ctx, reader, t}
+		}
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.url(objectKey, query).String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	// the throttled reader hides the length from net/http
+	req.ContentLength = int64(len(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.sign(req, payloadHash)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		var e struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+			return nil, fmt.Errorf("s3 %s %s: %s: %s", method, objectKey, e.Code, e.Message)
+		}
+		return nil, fmt.Errorf("s3 %s %s: %s", method, objectKey, resp.Status)
+	}
+	return resp, nil
+}
+
+func (s *s3Repo) doXML(ctx context.Context, method, objectKey string, query url.Values, body []byte, out any) error {
+	resp, err := s.do(ctx, method, objectKey, query, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return xml.NewDecoder(resp.Body).Decode(out)
+}
+
+type s3Part struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+	Size       int64  `xml:"Size,omitempty"`
+}
+
+// pendingUpload finds the multipart upload a previous attempt left for
+// objectKey, the newest one when there are several.
+func (s *s3Repo) pendingUpload(ctx context.Context, objectKey string) (string, error) {
+	var res struct {
+		Uploads []struct {
+			Key       string `xml:"Key"`
+			UploadId  string `xml:"UploadId"`
+			Initiated string `xml:"Initiated"`
+		} `xml:"Upload"`
+	}
+	err := s.doXML(ctx, http.MethodGet, "", url.Values{"uploads": {""}, "prefix": {objectKey}}, nil, &res)
+	if err != nil {
+		return "", err
+	}
+	id, newest := "", ""
+	for _, u := range res.Uploads {
+		if u.Key == objectKey && u.Initiated >= newest {
+			id, newest = u.UploadId, u.Initiated
+		}
+	}
+	return id, nil
+}
+
+func (s *s3Repo) uploadedParts(ctx context.Context, objectKey, uploadID string) (map[int]s3Part, error) {
+	parts := map[int]s3Part{}
+	marker := ""
+	for {
+		query := url.Values{"uploadId": {uploadID}}
+		if marker != "" {
+			query.Set("part-number-marker", marker)
+		}
+		var res struct {
+			Parts                []s3Part `xml:"Part"`
+			IsTruncated          bool     `xml:"IsTruncated"`
+			NextPartNumberMarker string   `xml:"NextPartNumberMarker"`
+		}
+		if err := s.doXML(ctx, http.MethodGet, objectKey, query, nil, &res); err != nil {
+			return nil, err
+		}
+		for _, p := range res.Parts {
+			parts[p.PartNumber] = p
+		}
+		if !res.IsTruncated || res.NextPartNumberMarker == "" {
+			return parts, nil
+		}
+		marker = res.NextPartNumberMarker
+	}
+}
+
+// Upload sends the file as a multipart upload and reuses the parts of an
+// unfinished upload of the same key.
+func (s *s3Repo) Upload(ctx context.Context, key, localPath string, progress func(done, total int64)) error {
+	objectKey, err := s.objectKey(key)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	total := info.Size()
+	partSize := s3PartSize(total)
+	t := newThrottle(s.limit)
+
+	if total <= partSize {
+		body, err := io.ReadAll(src)
+		if err != nil {
+			return err
+		}
+		resp, err := s.send(ctx, http.MethodPut, objectKey, nil, body, http.Header{"Content-Md5": {contentMD5(body)}}, t)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if progress != nil {
+			progress(total, total)
+		}
+		return nil
+	}
+
+	uploadID, err := s.pendingUpload(ctx, objectKey)
+	if err != nil {
+		return err
+	}
+	done := map[int]s3Part{}
+	if uploadID != "" {
+		if done, err = s.uploadedParts(ctx, objectKey, uploadID); err != nil {
+			return err
+		}
+	} else {
+		var res struct {
+			UploadId string `xml:"UploadId"`
+		}
+		if err := s.doXML(ctx, http.MethodPost, objectKey, url.Values{"uploads": {""}}, nil, &res); err != nil {
+			return err
+		}
+		uploadID = res.UploadId
+	}
+
+	count := int((total + partSize - 1) / partSize)
+	parts := make([]s3Part, 0, count)
+	buf := make([]byte, partSize)
+	for n := 1; n <= count; n++ {
+		offset := int64(n-1) * partSize
+		size := partSize
+		if offset+size > total {
+			size = total - offset
+		}
+		if p, ok := done[n]; ok && p.Size == size {
+			parts = append(parts, s3Part{PartNumber: n, ETag: p.ETag})
+			continue
+		}
+		if progress != nil {
+			progress(offset, total)
+		}
+
+		body := buf[:size]
+		if _, err := src.ReadAt(body, offset); err != nil {
+			return err
+		}
+		query := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {uploadID}}
+		resp, err := s.send(ctx, http.MethodPut, objectKey, query, body, http.Header{"Content-Md5": {contentMD5(body)}}, t)
+		if err != nil {
+			return fmt.Errorf("part %d: %w", n, err)
+		}
+		resp.Body.Close()
+		parts = append(parts, s3Part{PartNumber: n, ETag: resp.Header.Get("ETag")})
+	}
+
+	complete := struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: parts}
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	if err := s.doXML(ctx, http.MethodPost, objectKey, url.Values{"uploadId": {uploadID}}, body, nil); err != nil {
+		return err
+	}
+	if progress != nil {
+		progress(total, total)
+	}
+	return nil
+}
+
+func (s *s3Repo) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	objectKey, err := s.objectKey(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, objectKey, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return throttleReadCloser(ctx, resp.Body, s.limit), nil
+}
+
+func (s *s3Repo) Size(ctx context.Context, key string) (int64, error) {
+	objectKey, err := s.objectKey(key)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.do(ctx, http.MethodHead, objectKey, nil, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+// Delete removes the object and aborts unfinished uploads of it.
+func (s *s3Repo) Delete(ctx context.Context, key string) error {
+	objectKey, err := s.objectKey(key)
+	if err != nil {
+		return err
+	}
+	if id, err := s.pendingUpload(ctx, objectKey); err == nil && id != "" {
+		_ = s.doXML(ctx, http.MethodDelete, objectKey, url.Values{"uploadId": {id}}, nil, nil)
+	}
+	err = s.doXML(ctx, http.MethodDelete, objectKey, nil, nil, nil)
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (s *s3Repo) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func contentMD5(body []byte) string {
+	sum := md5.Sum(body)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// sign adds an AWS signature version 4 to req, every header already set on
+// req is signed together with host.
+func (s *s3Repo) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode escapes everything but the unreserved characters of RFC 3986,
+// the way SigV4 expects.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func canonicalURI(p string) string {
+	if p == "" {
+		return "/"
+	}
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = uriEncode(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,101 @@
+//go:build minio
+
+package backuprepo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Runs against a real MinIO, for example:
+//
+//	docker run -d -p 9000:9000 -e MINIO_ROOT_USER=minio -e MINIO_ROOT_PASSWORD=minio123 minio/minio server /data
+//	mc mb local/backups
+//	MINIO_ENDPOINT=http://127.0.0.1:9000 MINIO_ACCESS_KEY=minio MINIO_SECRET_KEY=minio123 MINIO_BUCKET=backups \
+//		go test -tags minio -run MinIO ./backuprepo/
+func minioRepo(t *testing.T) Repository {
+	t.Helper()
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("MINIO_ENDPOINT is not set")
+	}
+	repo, err := New(context.Background(), Config{
+		Kind:      KindS3,
+		Endpoint:  endpoint,
+		Bucket:    os.Getenv("MINIO_BUCKET"),
+		Path:      "512svman-test-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+		PathStyle: true,
+		AccessKey: os.Getenv("MINIO_ACCESS_KEY"),
+		SecretKey: os.Getenv("MINIO_SECRET_KEY"),
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func TestMinIOUploadAndRead(t *testing.T) {
+	ctx := context.Background()
+	repo := minioRepo(t)
+
+	data := randomData(t, 1<<20)
+	key := "web/2024 01/web.qcow2"
+	if err := repo.Upload(ctx, key, writeTemp(t, data), nil); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if size, err := repo.Size(ctx, key); err != nil || size != int64(len(data)) {
+		t.Fatalf("size %d (%v)", size, err)
+	}
+	if !bytes.Equal(readAll(t, repo, key), data) {
+		t.Fatalf("downloaded object differs")
+	}
+	if err := repo.Delete(ctx, key); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repo.Size(ctx, key); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestMinIOMultipartUploadResumes(t *testing.T) {
+	repo := minioRepo(t)
+	data := randomData(t, s3MinPartSize+3<<20)
+	src := writeTemp(t, data)
+	key := "web/1/web.qcow2"
+	partSize := s3PartSize(int64(len(data)))
+
+	// stop the first attempt once the first part is stored
+	ctx, cancel := context.WithCancel(context.Background())
+	err := repo.Upload(ctx, key, src, func(done, total int64) {
+		if done >= partSize {
+			cancel()
+		}
+	})
+	cancel()
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the first attempt to be cancelled, got %v", err)
+	}
+	t.Cleanup(func() { repo.Delete(context.Background(), key) })
+
+	var first int64 = -1
+	err = repo.Upload(context.Background(), key, src, func(done, total int64) {
+		if first < 0 {
+			first = done
+		}
+	})
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if first != partSize {
+		t.Fatalf("expected the upload to continue at %d, started at %d", partSize, first)
+	}
+	if !bytes.Equal(readAll(t, repo, key), data) {
+		t.Fatalf("resumed upload differs from the source")
+	}
+}
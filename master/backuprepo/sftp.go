@@ -0,0 +1,605 @@
+package backuprepo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTP version 3 (draft-ietf-secsh-filexfer-02), the one every server speaks.
+const (
+	sshFxpInit     = 1
+	sshFxpVersion  = 2
+	sshFxpOpen     = 3
+	sshFxpClose    = 4
+	sshFxpRead     = 5
+	sshFxpWrite    = 6
+	sshFxpRemove   = 13
+	sshFxpMkdir    = 14
+	sshFxpStat     = 17
+	sshFxpRename   = 18
+	sshFxpStatus   = 101
+	sshFxpHandle   = 102
+	sshFxpData     = 103
+	sshFxpAttrs    = 105
+	sftpVersion    = 3
+	sshFxOk        = 0
+	sshFxEOF       = 1
+	sshFxNoSuch    = 2
+	sshFxfRead     = 0x01
+	sshFxfWrite    = 0x02
+	sshFxfCreat    = 0x08
+	sshFxfTrunc    = 0x10
+	sshAttrSize    = 0x01
+	sshAttrUIDGID  = 0x02
+	sshAttrPerms   = 0x04
+	sshAttrTimes   = 0x08
+	sshAttrExtend  = 0x80000000
+	sftpChunkSize  = 32 * 1024
+	sftpInFlight   = 32
+	sftpMaxPacket  = 256 * 1024
+	sftpDialTimout = 30 * time.Second
+)
+
+type sftpStatusError struct {
+	code uint32
+	msg  string
+}
+
+func (e *sftpStatusError) Error() string {
+	return fmt.Sprintf("sftp status %d: %s", e.code, e.msg)
+}
+
+// sftpConn is a minimal SFTP client, requests are serialized except for the
+// pipelined writes and reads of a transfer.
+type sftpConn struct {
+	mu     sync.Mutex
+	w      io.Writer
+	r      io.Reader
+	nextID uint32
+	closer func() error
+}
+
+func newSFTPConn(r io.Reader, w io.Writer, closer func() error) (*sftpConn, error) {
+	c := &sftpConn{r: r, w: w, closer: closer}
+	if err := c.send(sshFxpInit, func(b []byte) []byte { return appendU32(b, sftpVersion) }); err != nil {
+		return nil, err
+	}
+	typ, _, err := c.recv()
+	if err != nil {
+		return nil, err
+	}
+	if typ != sshFxpVersion {
+		return nil, fmt.Errorf("sftp: unexpected packet %d instead of version", typ)
+	}
+	return c, nil
+}
+
+func appendU32(b []byte, v uint32) []byte {
+	return binary.BigEndian.AppendUint32(b, v)
+}
+
+func appendU64(b []byte, v uint64) []byte {
+	return binary.BigEndian.AppendUint64(b, v)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = appendU32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func (c *sftpConn) send(typ byte, payload func([]byte) []byte) error {
+	b := payload([]byte{0, 0, 0, 0, typ})
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	_, err := c.w.Write(b)
+	return err
+}
+
+// recv reads one packet and returns its type and the payload after the type.
+func (c *sftpConn) recv() (byte, []byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n == 0 || n > sftpMaxPacket+1024 {
+		return 0, nil, fmt.Errorf("sftp: bad packet length %d", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		return 0, nil, err
+	}
+	return b[0], b[1:], nil
+}
+
+func (c *sftpConn) id() uint32 {
+	c.nextID++
+	return c.nextID
+}
+
+type sftpReader struct {
+	b   []byte
+	err error
+}
+
+func (r *sftpReader) u32() uint32 {
+	if len(r.b) < 4 {
+		r.err = errors.New("sftp: short packet")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *sftpReader) u64() uint64 {
+	if len(r.b) < 8 {
+		r.err = errors.New("sftp: short packet")
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v
+}
+
+func (r *sftpReader) str() string {
+	n := r.u32()
+	if r.err != nil || uint32(len(r.b)) < n {
+		r.err = errors.New("sftp: short packet")
+		return ""
+	}
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
+
+// reply reads the answer to request id, a status other than ok is an error.
+func (c *sftpConn) reply(id uint32) (byte, *sftpReader, error) {
+	typ, payload, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	r := &sftpReader{b: payload}
+	if got := r.u32(); got != id {
+		return 0, nil, fmt.Errorf("sftp: reply to %d while waiting for %d", got, id)
+	}
+	if typ == sshFxpStatus {
+		code := r.u32()
+		msg := r.str()
+		if code != sshFxOk {
+			return typ, r, &sftpStatusError{code: code, msg: msg}
+		}
+	}
+	return typ, r, r.err
+}
+
+func (c *sftpConn) request(typ byte, payload func([]byte) []byte) (byte, *sftpReader, error) {
+	id := c.id()
+	if err := c.send(typ, func(b []byte) []byte { return payload(appendU32(b, id)) }); err != nil {
+		return 0, nil, err
+	}
+	return c.reply(id)
+}
+
+func (c *sftpConn) open(p string, flags uint32) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	typ, r, err := c.request(sshFxpOpen, func(b []byte) []byte {
+		b = appendString(b, p)
+		b = appendU32(b, flags)
+		return appendU32(b, 0)
+	})
+	if err != nil {
+		return "", err
+	}
+	if typ != sshFxpHandle {
+		return "", fmt.Errorf("sftp: unexpected packet %d instead of handle", typ)
+	}
+	return r.str(), r.err
+}
+
+func (c *sftpConn) closeHandle(handle string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, _, err := c.request(sshFxpClose, func(b []byte) []byte { return appendString(b, handle) })
+	return err
+}
+
+func (c *sftpConn) pathOp(typ byte, p string, extra func([]byte) []byte) (byte, *sftpReader, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.request(typ, func(b []byte) []byte {
+		b = appendString(b, p)
+		if extra != nil {
+			b = extra(b)
+		}
+		return b
+	})
+}
+
+// stat returns the size of p.
+func (c *sftpConn) stat(p string) (int64, error) {
+	typ, r, err := c.pathOp(sshFxpStat, p, nil)
+	if err != nil {
+		return 0, err
+	}
+	if typ != sshFxpAttrs {
+		return 0, fmt.Errorf("sftp: unexpected packet %d instead of attrs", typ)
+	}
+	flags := r.u32()
+	if flags&sshAttrSize == 0 {
+		return 0, fmt.Errorf("sftp: server did not report the size of %s", p)
+	}
+	return int64(r.u64()), r.err
+}
+
+func (c *sftpConn) remove(p string) error {
+	_, _, err := c.pathOp(sshFxpRemove, p, nil)
+	return err
+}
+
+func (c *sftpConn) rename(from, to string) error {
+	_, _, err := c.pathOp(sshFxpRename, from, func(b []byte) []byte { return appendString(b, to) })
+	return err
+}
+
+// mkdirAll creates p and its parents, existing ones are fine.
+func (c *sftpConn) mkdirAll(p string) error {
+	if p == "/" || p == "." || p == "" {
+		return nil
+	}
+	if _, err := c.stat(p); err == nil {
+		return nil
+	}
+	if err := c.mkdirAll(path.Dir(p)); err != nil {
+		return err
+	}
+	_, _, err := c.pathOp(sshFxpMkdir, p, func(b []byte) []byte { return appendU32(b, 0) })
+	if err != nil {
+		if _, serr := c.stat(p); serr == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// writeFrom writes src from offset on with up to sftpInFlight writes
+// outstanding, the round trip of each write would cap the speed otherwise.
+func (c *sftpConn) writeFrom(ctx context.Context, handle string, src io.ReaderAt, offset, total int64, t *throttle, progress func(done, total int64)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buf := make([]byte, sftpChunkSize)
+	// sizes of the writes waiting for their status, in the order sent
+	var inFlight []int64
+	acked := offset
+	var firstErr error
+	for offset < total || len(inFlight) > 0 {
+		for firstErr == nil && offset < total && len(inFlight) < sftpInFlight {
+			n := int64(sftpChunkSize)
+			if offset+n > total {
+				n = total - offset
+			}
+			if _, err := src.ReadAt(buf[:n], offset); err != nil && err != io.EOF {
+				firstErr = err
+				break
+			}
+			if err := t.wait(ctx, int(n)); err != nil {
+				firstErr = err
+				break
+			}
+			id := c.id()
+			chunk, at := buf[:n], offset
+			err := c.send(sshFxpWrite, func(b []byte) []byte {
+				b = appendU32(b, id)
+				b = appendString(b, handle)
+				b = appendU64(b, uint64(at))
+				return appendString(b, string(chunk))
+			})
+			if err != nil {
+				return err
+			}
+			inFlight = append(inFlight, n)
+			offset += n
+		}
+		if len(inFlight) == 0 {
+			break
+		}
+		typ, payload, err := c.recv()
+		if err != nil {
+			return err
+		}
+		acked += inFlight[0]
+		inFlight = inFlight[1:]
+		r := &sftpReader{b: payload}
+		r.u32()
+		if typ != sshFxpStatus {
+			return fmt.Errorf("sftp: unexpected packet %d instead of write status", typ)
+		}
+		if code := r.u32(); code != sshFxOk && firstErr == nil {
+			firstErr = &sftpStatusError{code: code, msg: r.str()}
+		}
+		if progress != nil && firstErr == nil {
+			progress(acked, total)
+		}
+	}
+	return firstErr
+}
+
+// readAt reads up to len(p) bytes of handle at offset, io.EOF past the end.
+func (c *sftpConn) readAt(handle string, p []byte, offset int64) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	typ, r, err := c.request(sshFxpRead, func(b []byte) []byte {
+		b = appendString(b, handle)
+		b = appendU64(b, uint64(offset))
+		return appendU32(b, uint32(len(p)))
+	})
+	var status *sftpStatusError
+	if errors.As(err, &status) && status.code == sshFxEOF {
+		return 0, io.EOF
+	}
+	if err != nil {
+		return 0, err
+	}
+	if typ != sshFxpData {
+		return 0, fmt.Errorf("sftp: unexpected packet %d instead of data", typ)
+	}
+	data := r.str()
+	return copy(p, data), r.err
+}
+
+func (c *sftpConn) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer()
+}
+
+func isNoSuchFile(err error) bool {
+	var status *sftpStatusError
+	return errors.As(err, &status) && status.code == sshFxNoSuch
+}
+
+type sftpRepo struct {
+	conn  *sftpConn
+	root  string
+	limit int64
+}
+
+func newSFTP(ctx context.Context, cfg Config) (*sftpRepo, error) {
+	if cfg.Endpoint == "" || cfg.AccessKey == "" {
+		return nil, fmt.Errorf("sftp endpoint and user are required")
+	}
+	addr := cfg.Endpoint
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	var auth []ssh.AuthMethod
+	if cfg.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(cfg.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("sftp private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.SecretKey != "" {
+		auth = append(auth, ssh.Password(cfg.SecretKey))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("sftp needs a password or a private key")
+	}
+
+	hostKeyCallback, err := sftpHostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := net.Dialer{Timeout: sftpDialTimout}
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, &ssh.ClientConfig{
+		User:            cfg.AccessKey,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sftpDialTimout,
+	})
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+
+	session, err := client.NewSession()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	w, err := session.StdinPipe()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("sftp subsystem: %w", err)
+	}
+	conn, err := newSFTPConn(r, w, func() error {
+		session.Close()
+		return client.Close()
+	})
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	root := cfg.Path
+	if root == "" {
+		root = "."
+	}
+	return newSFTPRepo(conn, root, cfg.BandwidthLimit), nil
+}
+
+// sftpHostKeyCallback pins cfg.HostKey, without one the first key seen is
+// trusted and handed to OnHostKey to be pinned from then on.
+func sftpHostKeyCallback(cfg Config) (ssh.HostKeyCallback, error) {
+	if cfg.HostKey == "" {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if cfg.OnHostKey != nil {
+				cfg.OnHostKey(strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))))
+			}
+			return nil
+		}, nil
+	}
+	pinned, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("sftp host key: %w", err)
+	}
+	return ssh.FixedHostKey(pinned), nil
+}
+
+func newSFTPRepo(conn *sftpConn, root string, limit int64) *sftpRepo {
+	return &sftpRepo{conn: conn, root: root, limit: limit}
+}
+
+func (s *sftpRepo) path(key string) (string, error) {
+	clean, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(s.root, clean), nil
+}
+
+// Upload continues the .partial file a previous attempt left and renames it
+// once complete.
+func (s *sftpRepo) Upload(ctx context.Context, key, localPath string, progress func(done, total int64)) error {
+	dest, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	total := info.Size()
+
+	if err := s.conn.mkdirAll(path.Dir(dest)); err != nil {
+		return fmt.Errorf("create %s: %w", path.Dir(dest), err)
+	}
+	partial := dest + partialSuffix
+	flags := uint32(sshFxfWrite | sshFxfCreat)
+	done, err := s.conn.stat(partial)
+	if err != nil || done > total {
+		done = 0
+		flags |= sshFxfTrunc
+	}
+
+	handle, err := s.conn.open(partial, flags)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", partial, err)
+	}
+	err = s.conn.writeFrom(ctx, handle, src, done, total, newThrottle(s.limit), progress)
+	if cerr := s.conn.closeHandle(handle); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	// rename fails on most servers when the target exists
+	if err := s.conn.remove(dest); err != nil && !isNoSuchFile(err) {
+		return err
+	}
+	if err := s.conn.rename(partial, dest); err != nil {
+		return err
+	}
+	if progress != nil {
+		progress(total, total)
+	}
+	return nil
+}
+
+type sftpFile struct {
+	conn   *sftpConn
+	handle string
+	offset int64
+}
+
+func (f *sftpFile) Read(p []byte) (int, error) {
+	if len(p) > sftpChunkSize {
+		p = p[:sftpChunkSize]
+	}
+	n, err := f.conn.readAt(f.handle, p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *sftpFile) Close() error {
+	return f.conn.closeHandle(f.handle)
+}
+
+func (s *sftpRepo) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := s.conn.open(p, sshFxfRead)
+	if isNoSuchFile(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return throttleReadCloser(ctx, &sftpFile{conn: s.conn, handle: handle}, s.limit), nil
+}
+
+func (s *sftpRepo) Size(ctx context.Context, key string) (int64, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	size, err := s.conn.stat(p)
+	if isNoSuchFile(err) {
+		return 0, ErrNotFound
+	}
+	return size, err
+}
+
+func (s *sftpRepo) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	_ = s.conn.remove(p + partialSuffix)
+	if err := s.conn.remove(p); err != nil && !isNoSuchFile(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *sftpRepo) Close() error {
+	return s.conn.Close()
+}
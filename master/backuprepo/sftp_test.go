@@ -0,0 +1,183 @@
+package backuprepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSFTPServer answers the subset of SFTP v3 the client uses from a
+// directory on disk.
+//
+// Replies are queued like the window of an ssh channel would, the client
+// keeps sending writes before it reads their status.
+func fakeSFTPServer(root string, r io.Reader, w io.Writer) {
+	replies := make(chan []byte, 1024)
+	defer close(replies)
+	go func() {
+		for b := range replies {
+			if _, err := w.Write(b); err != nil {
+				return
+			}
+		}
+	}()
+	files := map[string]*os.File{}
+	status := func(id uint32, code uint32) []byte {
+		b := appendU32([]byte{sshFxpStatus}, id)
+		b = appendU32(b, code)
+		b = appendString(b, "")
+		return appendString(b, "")
+	}
+	codeOf := func(err error) uint32 {
+		switch {
+		case err == nil:
+			return sshFxOk
+		case os.IsNotExist(err):
+			return sshFxNoSuch
+		default:
+			return 4
+		}
+	}
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return
+		}
+		pkt := make([]byte, binary.BigEndian.Uint32(hdr[:]))
+		if _, err := io.ReadFull(r, pkt); err != nil {
+			return
+		}
+		typ, in := pkt[0], &sftpReader{b: pkt[1:]}
+		var out []byte
+		if typ == sshFxpInit {
+			out = appendU32([]byte{sshFxpVersion}, sftpVersion)
+		} else {
+			id := in.u32()
+			switch typ {
+			case sshFxpOpen:
+				p, flags := filepath.Join(root, in.str()), in.u32()
+				mode := os.O_RDONLY
+				if flags&sshFxfWrite != 0 {
+					mode = os.O_WRONLY
+				}
+				if flags&sshFxfCreat != 0 {
+					mode |= os.O_CREATE
+				}
+				if flags&sshFxfTrunc != 0 {
+					mode |= os.O_TRUNC
+				}
+				f, err := os.OpenFile(p, mode, 0o644)
+				if err != nil {
+					out = status(id, codeOf(err))
+					break
+				}
+				handle := string(rune('a' + len(files)))
+				files[handle] = f
+				out = appendString(appendU32([]byte{sshFxpHandle}, id), handle)
+			case sshFxpClose:
+				h := in.str()
+				out = status(id, codeOf(files[h].Close()))
+				delete(files, h)
+			case sshFxpWrite:
+				f, offset, data := files[in.str()], in.u64(), in.str()
+				_, err := f.WriteAt([]byte(data), int64(offset))
+				out = status(id, codeOf(err))
+			case sshFxpRead:
+				f, offset, n := files[in.str()], in.u64(), in.u32()
+				buf := make([]byte, n)
+				got, err := f.ReadAt(buf, int64(offset))
+				if got == 0 && err == io.EOF {
+					out = status(id, sshFxEOF)
+					break
+				}
+				out = appendString(appendU32([]byte{sshFxpData}, id), string(buf[:got]))
+			case sshFxpStat:
+				info, err := os.Stat(filepath.Join(root, in.str()))
+				if err != nil {
+					out = status(id, codeOf(err))
+					break
+				}
+				out = appendU64(appendU32(appendU32([]byte{sshFxpAttrs}, id), sshAttrSize), uint64(info.Size()))
+			case sshFxpMkdir:
+				out = status(id, codeOf(os.Mkdir(filepath.Join(root, in.str()), 0o755)))
+			case sshFxpRemove:
+				out = status(id, codeOf(os.Remove(filepath.Join(root, in.str()))))
+			case sshFxpRename:
+				from, to := in.str(), in.str()
+				if _, err := os.Stat(filepath.Join(root, to)); err == nil {
+					out = status(id, 4)
+					break
+				}
+				out = status(id, codeOf(os.Rename(filepath.Join(root, from), filepath.Join(root, to))))
+			default:
+				out = status(id, 8)
+			}
+		}
+		replies <- append(appendU32(nil, uint32(len(out))), out...)
+	}
+}
+
+func TestSFTPUploadResumesAndReads(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+	go fakeSFTPServer(root, toServer, fromServer)
+
+	conn, err := newSFTPConn(toClient, fromClient, func() error {
+		fromClient.Close()
+		return fromServer.Close()
+	})
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	repo := newSFTPRepo(conn, "backups", 0)
+	defer repo.Close()
+
+	data := randomData(t, 10*sftpChunkSize+123)
+	key := "web/1/web.qcow2"
+	dest := filepath.Join(root, "backups", "web", "1", "web.qcow2")
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dest+partialSuffix, data[:3*sftpChunkSize], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// a previous copy is replaced
+	if err := os.WriteFile(dest, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var first int64 = -1
+	err = repo.Upload(ctx, key, writeTemp(t, data), func(done, total int64) {
+		if first < 0 {
+			first = done
+		}
+	})
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if first < 3*sftpChunkSize {
+		t.Fatalf("upload did not continue the partial file, first progress %d", first)
+	}
+	if stored, _ := os.ReadFile(dest); !bytes.Equal(stored, data) {
+		t.Fatalf("stored file differs from the source")
+	}
+	if !bytes.Equal(readAll(t, repo, key), data) {
+		t.Fatalf("downloaded file differs from the source")
+	}
+	if size, err := repo.Size(ctx, key); err != nil || size != int64(len(data)) {
+		t.Fatalf("size %d (%v)", size, err)
+	}
+
+	if err := repo.Delete(ctx, key); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repo.Open(ctx, key); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
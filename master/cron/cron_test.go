@@ -0,0 +1,45 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRejectsInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("expected %q to be rejected", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2026-10-16 is a friday
+	from := time.Date(2026, 10, 16, 18, 30, 0, 0, time.UTC)
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 8 * * 1-5", time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)},
+		{"0 19 * * 1-5", time.Date(2026, 10, 16, 19, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 16, 18, 45, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 6 * * 7", time.Date(2026, 10, 18, 6, 30, 0, 0, time.UTC)},
+		{"0 12 1 * 6", time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}, // day of month or saturday
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, c := range cases {
+		sched, err := Parse(c.expr)
+		if err != nil {
+			t.Fatalf("parse %q: %v", c.expr, err)
+		}
+		if got := sched.Next(from); !got.Equal(c.want) {
+			t.Fatalf("%q: next = %s, want %s", c.expr, got, c.want)
+		}
+	}
+
+	sched, _ := Parse("0 8 * * 1-5")
+	if !sched.Matches(time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)) || sched.Matches(time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Matches disagrees with the schedule")
+	}
+}
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// APIToken is a personal access token, only the hash of the secret is kept.
+// Scopes are "<resource type>:<read|write>", Routes optionally narrow it down
+// to some paths and AllowedIPs to some client addresses or networks.
+type APIToken struct {
+	Id         int      `json:"id"`
+	UserId     int      `json:"user_id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	Routes     []string `json:"routes"`
+	AllowedIPs []string `json:"allowed_ips"`
+	CreatedAt  string   `json:"created_at"`
+	ExpiresAt  string   `json:"expires_at"`
+	LastUsedAt string   `json:"last_used_at"`
+	LastUsedIP string   `json:"last_used_ip"`
+	RevokedAt  string   `json:"revoked_at"`
+}
+
+const apiTokenColumns = `id, user_id, name, prefix, scopes, routes, allowed_ips, created_at, expires_at,
+	last_used_at, last_used_ip, revoked_at`
+
+func CreateAPITokensTable(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		prefix TEXT NOT NULL,
+		scopes TEXT NOT NULL DEFAULT '[]',
+		routes TEXT NOT NULL DEFAULT '[]',
+		allowed_ips TEXT NOT NULL DEFAULT '[]',
+		created_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL,
+		last_used_at TEXT NOT NULL DEFAULT '',
+		last_used_ip TEXT NOT NULL DEFAULT '',
+		revoked_at TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_api_tokens_user ON api_tokens(user_id);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+func marshalStrings(values []string) (string, error) {
+	if values == nil {
+		values = []string{}
+	}
+	bytes, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func unmarshalStrings(raw string) ([]string, error) {
+	values := []string{}
+	if raw == "" {
+		return values, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func AddAPIToken(ctx context.Context, token APIToken, tokenHash string) (*APIToken, error) {
+	scopes, err := marshalStrings(token.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := marshalStrings(token.Routes)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := marshalStrings(token.AllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := DB.ExecContext(ctx, `
+	INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, routes, allowed_ips, created_at, expires_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		token.UserId, token.Name, tokenHash, token.Prefix, scopes, routes, ips,
+		time.Now().UTC().Format(time.RFC3339), token.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetAPITokenByID(ctx, int(id))
+}
+
+// GetAPITokens lists the tokens of a user, every token when userID is 0.
+func GetAPITokens(ctx context.Context, userID int) ([]APIToken, error) {
+	query := `SELECT ` + apiTokenColumns + ` FROM api_tokens`
+	var args []any
+	if userID != 0 {
+		query += ` WHERE user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` ORDER BY id;`
+
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func getAPIToken(ctx context.Context, where string, args ...any) (*APIToken, error) {
+	row := DB.QueryRowContext(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE `+where+`;`, args...)
+	token, err := scanAPIToken(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func GetAPITokenByID(ctx context.Context, id int) (*APIToken, error) {
+	return getAPIToken(ctx, `id = ?`, id)
+}
+
+// GetActiveAPIToken returns the token with this hash if it is not revoked or
+// expired.
+func GetActiveAPIToken(ctx context.Context, tokenHash string) (*APIToken, error) {
+	return getAPIToken(ctx, `token_hash = ? AND revoked_at = '' AND expires_at > ?`,
+		tokenHash, time.Now().UTC().Format(time.RFC3339))
+}
+
+// TouchAPIToken records a use of the token, at most once a minute so busy
+// automation does not write on every request.
+func TouchAPIToken(ctx context.Context, id int, ip string) error {
+	now := time.Now().UTC()
+	_, err := DB.ExecContext(ctx, `
+	UPDATE api_tokens SET last_used_at = ?, last_used_ip = ?
+	WHERE id = ? AND (last_used_at < ? OR last_used_ip <> ?);`,
+		now.Format(time.RFC3339), ip, id, now.Add(-time.Minute).Format(time.RFC3339), ip)
+	return err
+}
+
+func RevokeAPIToken(ctx context.Context, id int) error {
+	_, err := DB.ExecContext(ctx, `UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at = '';`,
+		time.Now().UTC().Format(time.RFC3339), id)
+	return err
+}
+
+func RevokeUserAPITokens(ctx context.Context, userID int) error {
+	_, err := DB.ExecContext(ctx, `UPDATE api_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at = '';`,
+		time.Now().UTC().Format(time.RFC3339), userID)
+	return err
+}
+
+func scanAPIToken(scanner rowScanner) (APIToken, error) {
+	var token APIToken
+	var scopes, routes, ips string
+	err := scanner.Scan(&token.Id, &token.UserId, &token.Name, &token.Prefix, &scopes, &routes, &ips,
+		&token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt, &token.LastUsedIP, &token.RevokedAt)
+	if err != nil {
+		return token, err
+	}
+	if token.Scopes, err = unmarshalStrings(scopes); err != nil {
+		return token, err
+	}
+	if token.Routes, err = unmarshalStrings(routes); err != nil {
+		return token, err
+	}
+	token.AllowedIPs, err = unmarshalStrings(ips)
+	return token, err
+}
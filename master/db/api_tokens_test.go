@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestAPITokensLifecycle(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateAPITokensTable(ctx); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	token, err := AddAPIToken(ctx, APIToken{
+		UserId:     1,
+		Name:       "ci",
+		Prefix:     "svm_abcd",
+		Scopes:     []string{"vms:write", "docker:read"},
+		AllowedIPs: []string{"10.0.0.0/8"},
+		ExpiresAt:  expires,
+	}, "hash1")
+	if err != nil {
+		t.Fatalf("add token: %v", err)
+	}
+	if len(token.Scopes) != 2 || len(token.Routes) != 0 || token.AllowedIPs[0] != "10.0.0.0/8" {
+		t.Fatalf("unexpected token %+v", token)
+	}
+	if _, err := AddAPIToken(ctx, APIToken{UserId: 2, Name: "old", Prefix: "svm_ef", ExpiresAt: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)}, "hash2"); err != nil {
+		t.Fatalf("add expired token: %v", err)
+	}
+
+	active, err := GetActiveAPIToken(ctx, "hash1")
+	if err != nil || active == nil || active.Id != token.Id {
+		t.Fatalf("expected active token, got %+v, %v", active, err)
+	}
+	if expired, err := GetActiveAPIToken(ctx, "hash2"); err != nil || expired != nil {
+		t.Fatalf("expected expired token to be ignored, got %+v, %v", expired, err)
+	}
+
+	if err := TouchAPIToken(ctx, token.Id, "10.0.0.7"); err != nil {
+		t.Fatalf("touch token: %v", err)
+	}
+	touched, err := GetAPITokenByID(ctx, token.Id)
+	if err != nil || touched.LastUsedAt == "" || touched.LastUsedIP != "10.0.0.7" {
+		t.Fatalf("expected last use to be recorded, got %+v, %v", touched, err)
+	}
+
+	mine, err := GetAPITokens(ctx, 1)
+	if err != nil || len(mine) != 1 {
+		t.Fatalf("expected one token for user 1, got %+v, %v", mine, err)
+	}
+	all, err := GetAPITokens(ctx, 0)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("expected two tokens, got %+v, %v", all, err)
+	}
+
+	if err := RevokeAPIToken(ctx, token.Id); err != nil {
+		t.Fatalf("revoke token: %v", err)
+	}
+	if revoked, err := GetActiveAPIToken(ctx, "hash1"); err != nil || revoked != nil {
+		t.Fatalf("expected revoked token to be ignored, got %+v, %v", revoked, err)
+	}
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// AutoStartGroup is a boot stage, groups start from the lowest Order up and
+// shut down the other way around.
+type AutoStartGroup struct {
+	Name      string      `json:"name"`
+	Order     int         `json:"order"`
+	VMs       []AutoStart `json:"vms"`
+	CreatedAt string      `json:"created_at"`
+}
+
+func CreateAutoStartGroup(ctx context.Context, name string, order int) error {
+	_, err := DB.ExecContext(ctx, `INSERT INTO auto_start_groups (name, boot_order, created_at) VALUES (?, ?, ?);`,
+		name, order, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func UpdateAutoStartGroup(ctx context.Context, name string, order int) error {
+	_, err := DB.ExecContext(ctx, `UPDATE auto_start_groups SET boot_order = ? WHERE name = ?;`, order, name)
+	return err
+}
+
+// DeleteAutoStartGroup keeps the vms on autostart, they just lose their group.
+func DeleteAutoStartGroup(ctx context.Context, name string) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE auto_start SET group_name = '' WHERE group_name = ?;`, name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM auto_start_groups WHERE name = ?;`, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func GetAutoStartGroup(ctx context.Context, name string) (*AutoStartGroup, error) {
+	var group AutoStartGroup
+	err := DB.QueryRowContext(ctx, `SELECT name, boot_order, created_at FROM auto_start_groups WHERE name = ?;`, name).
+		Scan(&group.Name, &group.Order, &group.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := GetAllAutoStart(ctx)
+	if err != nil {
+		return nil, err
+	}
+	group.VMs = []AutoStart{}
+	for _, vm := range all {
+		if vm.Group == name {
+			group.VMs = append(group.VMs, vm)
+		}
+	}
+	return &group, nil
+}
+
+// GetAutoStartGroups returns the groups in boot order with their vms.
+func GetAutoStartGroups(ctx context.Context) ([]AutoStartGroup, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT name, boot_order, created_at FROM auto_start_groups ORDER BY boot_order, name;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []AutoStartGroup{}
+	index := map[string]int{}
+	for rows.Next() {
+		group := AutoStartGroup{VMs: []AutoStart{}}
+		if err := rows.Scan(&group.Name, &group.Order, &group.CreatedAt); err != nil {
+			return nil, err
+		}
+		index[group.Name] = len(groups)
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	all, err := GetAllAutoStart(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, vm := range all {
+		if i, ok := index[vm.Group]; ok {
+			groups[i].VMs = append(groups[i].VMs, vm)
+		}
+	}
+	return groups, nil
+}
+
+// SetAutoStartBoot stores the boot settings of a vm already on autostart.
+func SetAutoStartBoot(ctx context.Context, settings AutoStart) error {
+	res, err := DB.ExecContext(ctx, `
+	UPDATE auto_start SET group_name = ?, boot_order = ?, delay_seconds = ?, wait_for = ?, tcp_host = ?,
+		tcp_port = ?, ready_timeout = ?
+	WHERE vm_name = ?;`,
+		settings.Group, settings.Order, settings.DelaySeconds, settings.WaitFor, settings.TCPHost,
+		settings.TCPPort, settings.ReadyTimeout, settings.VmName)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestAutoStartBootOrder(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateTableAutoStart(ctx); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	// running it twice must not fail on the column migrations
+	if err := CreateTableAutoStart(ctx); err != nil {
+		t.Fatalf("create table again: %v", err)
+	}
+
+	for _, name := range []string{"app", "db", "dns", "misc"} {
+		if err := AddAutoStart(ctx, name); err != nil {
+			t.Fatalf("add autostart %s: %v", name, err)
+		}
+	}
+	if err := CreateAutoStartGroup(ctx, "infra", 10); err != nil {
+		t.Fatalf("create infra: %v", err)
+	}
+	if err := CreateAutoStartGroup(ctx, "apps", 20); err != nil {
+		t.Fatalf("create apps: %v", err)
+	}
+	settings := []AutoStart{
+		{VmName: "app", Group: "apps"},
+		{VmName: "db", Group: "infra", Order: 2, WaitFor: AutoStartWaitTCP, TCPPort: 5432},
+		{VmName: "dns", Group: "infra", Order: 1, WaitFor: AutoStartWaitAgent, DelaySeconds: 30},
+	}
+	for _, s := range settings {
+		if err := SetAutoStartBoot(ctx, s); err != nil {
+			t.Fatalf("set boot of %s: %v", s.VmName, err)
+		}
+	}
+	if err := SetAutoStartBoot(ctx, AutoStart{VmName: "missing"}); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows for a vm without autostart, got %v", err)
+	}
+
+	all, err := GetAllAutoStart(ctx)
+	if err != nil {
+		t.Fatalf("get all: %v", err)
+	}
+	var order []string
+	for _, vm := range all {
+		order = append(order, vm.VmName)
+	}
+	if want := []string{"dns", "db", "app", "misc"}; len(order) != len(want) || order[0] != want[0] ||
+		order[1] != want[1] || order[2] != want[2] || order[3] != want[3] {
+		t.Fatalf("boot order = %v, want %v", order, want)
+	}
+	if all[0].DelaySeconds != 30 || all[0].WaitFor != AutoStartWaitAgent || all[1].TCPPort != 5432 {
+		t.Fatalf("boot settings not stored: %+v", all[:2])
+	}
+
+	groups, err := GetAutoStartGroups(ctx)
+	if err != nil {
+		t.Fatalf("get groups: %v", err)
+	}
+	if len(groups) != 2 || groups[0].Name != "infra" || len(groups[0].VMs) != 2 || len(groups[1].VMs) != 1 {
+		t.Fatalf("unexpected groups %+v", groups)
+	}
+
+	if err := DeleteAutoStartGroup(ctx, "infra"); err != nil {
+		t.Fatalf("delete group: %v", err)
+	}
+	dns, err := GetAutoStartByName(ctx, "dns")
+	if err != nil || dns == nil || dns.Group != "" {
+		t.Fatalf("expected dns to stay on autostart without a group, got %+v (%v)", dns, err)
+	}
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// BackupKey encrypts backups at rest. New backups use the newest key, older
+// keys are kept for as long as a backup was written with them.
+type BackupKey struct {
+	Id        int    `json:"id"`
+	Key       []byte `json:"-"`
+	CreatedAt string `json:"created_at"`
+	Backups   int    `json:"backups"`
+}
+
+func CreateBackupKeysTable(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS backup_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		key BLOB NOT NULL,
+		created_at TEXT NOT NULL
+	);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+func AddBackupKey(ctx context.Context, k *BackupKey) error {
+	k.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	res, err := DB.ExecContext(ctx, `INSERT INTO backup_keys (key, created_at) VALUES (?, ?);`, k.Key, k.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	k.Id = int(id)
+	return nil
+}
+
+func GetBackupKey(ctx context.Context, id int) (*BackupKey, error) {
+	var k BackupKey
+	err := DB.QueryRowContext(ctx, `SELECT id, key, created_at FROM backup_keys WHERE id = ?;`, id).
+		Scan(&k.Id, &k.Key, &k.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+// GetNewestBackupKey returns the key new backups are encrypted with, nil when
+// none was created yet.
+func GetNewestBackupKey(ctx context.Context) (*BackupKey, error) {
+	var k BackupKey
+	err := DB.QueryRowContext(ctx, `SELECT id, key, created_at FROM backup_keys ORDER BY id DESC LIMIT 1;`).
+		Scan(&k.Id, &k.Key, &k.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+// GetBackupKeys lists the keys without their material, with how many backups
+// each one still decrypts.
+func GetBackupKeys(ctx context.Context) ([]BackupKey, error) {
+	rows, err := DB.QueryContext(ctx, `
+	SELECT k.id, k.created_at, (SELECT COUNT(*) FROM virsh_backups b WHERE b.encrypted = 1 AND b.key_id = k.id)
+	FROM backup_keys k ORDER BY k.id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []BackupKey{}
+	for rows.Next() {
+		var k BackupKey
+		if err := rows.Scan(&k.Id, &k.CreatedAt, &k.Backups); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// GetBackupKeyMaterial returns every key with its material, for an export.
+func GetBackupKeyMaterial(ctx context.Context) ([]BackupKey, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT id, key, created_at FROM backup_keys ORDER BY id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []BackupKey{}
+	for rows.Next() {
+		var k BackupKey
+		if err := rows.Scan(&k.Id, &k.Key, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// ImportBackupKey stores an exported key under its original id, backups
+// reference it by id. It reports false when the same key is already there and
+// fails when the id holds a different one.
+func ImportBackupKey(ctx context.Context, k BackupKey) (bool, error) {
+	existing, err := GetBackupKey(ctx, k.Id)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil {
+		if !bytes.Equal(existing.Key, k.Key) {
+			return false, fmt.Errorf("key %d already exists with different material", k.Id)
+		}
+		return false, nil
+	}
+	_, err = DB.ExecContext(ctx, `INSERT INTO backup_keys (id, key, created_at) VALUES (?, ?, ?);`, k.Id, k.Key, k.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestBackupKeysAndStorage(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateTableBackups(ctx); err != nil {
+		t.Fatalf("create backups table: %v", err)
+	}
+	if err := CreateBackupKeysTable(ctx); err != nil {
+		t.Fatalf("create keys table: %v", err)
+	}
+
+	if k, err := GetNewestBackupKey(ctx); err != nil || k != nil {
+		t.Fatalf("expected no key yet, got %+v (%v)", k, err)
+	}
+	first := &BackupKey{Key: bytes.Repeat([]byte{1}, 32)}
+	second := &BackupKey{Key: bytes.Repeat([]byte{2}, 32)}
+	for _, k := range []*BackupKey{first, second} {
+		if err := AddBackupKey(ctx, k); err != nil {
+			t.Fatalf("add key: %v", err)
+		}
+	}
+	newest, err := GetNewestBackupKey(ctx)
+	if err != nil || newest == nil || newest.Id != second.Id || !bytes.Equal(newest.Key, second.Key) {
+		t.Fatalf("expected the second key as newest, got %+v (%v)", newest, err)
+	}
+
+	packed := &VirshBackup{Name: "web", Path: "/bak/backup-1/web.qcow2.zst.enc", NfsId: 1,
+		Compression: "zstd", Encrypted: true, KeyId: first.Id, OriginalSize: 10 << 30, StoredSize: 3 << 30}
+	plain := &VirshBackup{Name: "web", Path: "/bak/backup-2/web.qcow2", NfsId: 1, Compression: "qcow2"}
+	for _, b := range []*VirshBackup{packed, plain} {
+		if err := InsertVirshBackup(ctx, b); err != nil {
+			t.Fatalf("insert backup: %v", err)
+		}
+	}
+	got, err := GetVirshBackupById(ctx, packed.Id)
+	if err != nil || got == nil {
+		t.Fatalf("get backup: %v", err)
+	}
+	if !got.NeedsDecode() || got.KeyId != first.Id || got.OriginalSize != 10<<30 || got.StoredSize != 3<<30 {
+		t.Fatalf("storage not recorded: %+v", got)
+	}
+	if got, _ := GetVirshBackupById(ctx, plain.Id); got.NeedsDecode() {
+		t.Fatalf("a qcow2 compressed backup is still a usable qcow2")
+	}
+
+	keys, err := GetBackupKeys(ctx)
+	if err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	if len(keys) != 2 || keys[0].Backups != 1 || keys[1].Backups != 0 || keys[0].Key != nil {
+		t.Fatalf("unexpected key list %+v", keys)
+	}
+
+	material, err := GetBackupKeyMaterial(ctx)
+	if err != nil || len(material) != 2 || !bytes.Equal(material[1].Key, second.Key) {
+		t.Fatalf("unexpected key material %+v (%v)", material, err)
+	}
+	if added, err := ImportBackupKey(ctx, *first); err != nil || added {
+		t.Fatalf("importing a key that is already there should be a no-op, got %v (%v)", added, err)
+	}
+	if _, err := ImportBackupKey(ctx, BackupKey{Id: first.Id, Key: bytes.Repeat([]byte{9}, 32)}); err == nil {
+		t.Fatalf("expected a different key under an existing id to be refused")
+	}
+	restored := BackupKey{Id: 7, Key: bytes.Repeat([]byte{7}, 32), CreatedAt: "2026-01-01T00:00:00Z"}
+	if added, err := ImportBackupKey(ctx, restored); err != nil || !added {
+		t.Fatalf("import key: %v (%v)", added, err)
+	}
+	if k, err := GetBackupKey(ctx, 7); err != nil || k == nil || !bytes.Equal(k.Key, restored.Key) || k.CreatedAt != restored.CreatedAt {
+		t.Fatalf("imported key not kept under its id: %+v (%v)", k, err)
+	}
+}
@@ -0,0 +1,436 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// BackupRepository is an off-cluster target backups are replicated to, the
+// kinds and fields are the ones of backuprepo.Config. The secrets never leave
+// the master.
+type BackupRepository struct {
+	Id        int    `json:"id"`
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Path      string `json:"path"`
+	Endpoint  string `json:"endpoint"`
+	Bucket    string `json:"bucket"`
+	Region    string `json:"region"`
+	PathStyle bool   `json:"path_style"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"-"`
+	// PrivateKey is the sftp key in PEM
+	PrivateKey string `json:"-"`
+	// HostKey pins the sftp server, filled on the first connection when empty
+	HostKey string `json:"host_key"`
+	// BandwidthLimit is in bytes per second, 0 is unlimited
+	BandwidthLimit int64  `json:"bandwidth_limit"`
+	CreatedAt      string `json:"created_at"`
+}
+
+// copy keeps the remote copy after the backup is pruned or deleted, sync
+// deletes it with the backup
+const (
+	BackupCopyModeCopy = "copy"
+	BackupCopyModeSync = "sync"
+)
+
+// BackupCopyPolicy replicates the backups it matches to RepositoryId once
+// they are created. An empty VmName or a zero NfsId match any.
+type BackupCopyPolicy struct {
+	Id            int    `json:"id"`
+	Name          string `json:"name"`
+	RepositoryId  int    `json:"repository_id"`
+	VmName        string `json:"vm_name"`
+	NfsId         int    `json:"nfs_id"`
+	AutomaticOnly bool   `json:"automatic_only"`
+	Mode          string `json:"mode"`
+	Enabled       bool   `json:"enabled"`
+	CreatedAt     string `json:"created_at"`
+}
+
+const (
+	BackupReplicaPending = "pending"
+	BackupReplicaRunning = "running"
+	BackupReplicaDone    = "done"
+	BackupReplicaFailed  = "failed"
+)
+
+// BackupReplica is the copy of one backup in one repository. It keeps what is
+// needed to bring the file back after the local backup is gone.
+type BackupReplica struct {
+	Id           int `json:"id"`
+	BackupId     int `json:"backup_id"`
+	RepositoryId int `json:"repository_id"`
+	// PolicyId is 0 for copies started by hand
+	PolicyId        int    `json:"policy_id"`
+	Mode            string `json:"mode"`
+	RemoteKey       string `json:"remote_key"`
+	Status          string `json:"status"`
+	Error           string `json:"error"`
+	Attempts        int    `json:"attempts"`
+	Size            int64  `json:"size"`
+	VmName          string `json:"vm_name"`
+	BackupCreatedAt string `json:"backup_created_at"`
+	Compression     string `json:"compression"`
+	Encrypted       bool   `json:"encrypted"`
+	KeyId           int    `json:"key_id"`
+	OriginalSize    int64  `json:"original_size"`
+	Checksum        string `json:"checksum"`
+	CreatedAt       string `json:"created_at"`
+	UpdatedAt       string `json:"updated_at"`
+}
+
+const backupRepositoryColumns = `id, name, kind, path, endpoint, bucket, region, path_style, access_key, secret_key,
+	private_key, host_key, bandwidth_limit, created_at`
+
+const backupCopyPolicyColumns = `id, name, repository_id, vm_name, nfs_id, automatic_only, mode, enabled, created_at`
+
+const backupReplicaColumns = `id, backup_id, repository_id, policy_id, mode, remote_key, status, error, attempts, size,
+	vm_name, backup_created_at, compression, encrypted, key_id, original_size, checksum, created_at, updated_at`
+
+func CreateBackupRepositoryTables(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS backup_repositories (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		kind TEXT NOT NULL,
+		path TEXT NOT NULL DEFAULT '',
+		endpoint TEXT NOT NULL DEFAULT '',
+		bucket TEXT NOT NULL DEFAULT '',
+		region TEXT NOT NULL DEFAULT '',
+		path_style INTEGER NOT NULL DEFAULT 0,
+		access_key TEXT NOT NULL DEFAULT '',
+		secret_key TEXT NOT NULL DEFAULT '',
+		private_key TEXT NOT NULL DEFAULT '',
+		host_key TEXT NOT NULL DEFAULT '',
+		bandwidth_limit INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS backup_copy_policies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		repository_id INTEGER NOT NULL,
+		vm_name TEXT NOT NULL DEFAULT '',
+		nfs_id INTEGER NOT NULL DEFAULT 0,
+		automatic_only INTEGER NOT NULL DEFAULT 0,
+		mode TEXT NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		created_at TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS backup_replicas (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		backup_id INTEGER NOT NULL,
+		repository_id INTEGER NOT NULL,
+		policy_id INTEGER NOT NULL DEFAULT 0,
+		mode TEXT NOT NULL,
+		remote_key TEXT NOT NULL,
+		status TEXT NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		size INTEGER NOT NULL DEFAULT 0,
+		vm_name TEXT NOT NULL,
+		backup_created_at TEXT NOT NULL DEFAULT '',
+		compression TEXT NOT NULL DEFAULT '',
+		encrypted INTEGER NOT NULL DEFAULT 0,
+		key_id INTEGER NOT NULL DEFAULT 0,
+		original_size INTEGER NOT NULL DEFAULT 0,
+		checksum TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		UNIQUE(backup_id, repository_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_backup_replicas_status ON backup_replicas(status);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+func scanBackupRepository(row rowScanner) (BackupRepository, error) {
+	var r BackupRepository
+	err := row.Scan(&r.Id, &r.Name, &r.Kind, &r.Path, &r.Endpoint, &r.Bucket, &r.Region, &r.PathStyle, &r.AccessKey,
+		&r.SecretKey, &r.PrivateKey, &r.HostKey, &r.BandwidthLimit, &r.CreatedAt)
+	return r, err
+}
+
+func AddBackupRepository(ctx context.Context, r *BackupRepository) error {
+	r.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	res, err := DB.ExecContext(ctx, `
+	INSERT INTO backup_repositories (name, kind, path, endpoint, bucket, region, path_style, access_key, secret_key,
+		private_key, host_key, bandwidth_limit, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		r.Name, r.Kind, r.Path, r.Endpoint, r.Bucket, r.Region, r.PathStyle, r.AccessKey, r.SecretKey,
+		r.PrivateKey, r.HostKey, r.BandwidthLimit, r.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	r.Id = int(id)
+	return nil
+}
+
+func UpdateBackupRepository(ctx context.Context, r BackupRepository) error {
+	_, err := DB.ExecContext(ctx, `
+	UPDATE backup_repositories SET name = ?, kind = ?, path = ?, endpoint = ?, bucket = ?, region = ?, path_style = ?,
+		access_key = ?, secret_key = ?, private_key = ?, host_key = ?, bandwidth_limit = ?
+	WHERE id = ?;`,
+		r.Name, r.Kind, r.Path, r.Endpoint, r.Bucket, r.Region, r.PathStyle,
+		r.AccessKey, r.SecretKey, r.PrivateKey, r.HostKey, r.BandwidthLimit, r.Id)
+	return err
+}
+
+// SetBackupRepositoryHostKey pins the sftp host key seen on the first
+// connection, a key already pinned is left alone.
+func SetBackupRepositoryHostKey(ctx context.Context, id int, hostKey string) error {
+	_, err := DB.ExecContext(ctx, `UPDATE backup_repositories SET host_key = ? WHERE id = ? AND host_key = '';`, hostKey, id)
+	return err
+}
+
+func GetBackupRepository(ctx context.Context, id int) (*BackupRepository, error) {
+	r, err := scanBackupRepository(DB.QueryRowContext(ctx, `SELECT `+backupRepositoryColumns+` FROM backup_repositories WHERE id = ?;`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &r, nil
+}
+
+func GetBackupRepositories(ctx context.Context) ([]BackupRepository, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+backupRepositoryColumns+` FROM backup_repositories ORDER BY name;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	repos := []BackupRepository{}
+	for rows.Next() {
+		r, err := scanBackupRepository(rows)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, r)
+	}
+	return repos, rows.Err()
+}
+
+// DeleteBackupRepository removes the repository with its policies and the
+// records of its replicas, the remote files are left where they are.
+func DeleteBackupRepository(ctx context.Context, id int) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, query := range []string{
+		`DELETE FROM backup_replicas WHERE repository_id = ?;`,
+		`DELETE FROM backup_copy_policies WHERE repository_id = ?;`,
+		`DELETE FROM backup_repositories WHERE id = ?;`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func scanBackupCopyPolicy(row rowScanner) (BackupCopyPolicy, error) {
+	var p BackupCopyPolicy
+	err := row.Scan(&p.Id, &p.Name, &p.RepositoryId, &p.VmName, &p.NfsId, &p.AutomaticOnly, &p.Mode, &p.Enabled, &p.CreatedAt)
+	return p, err
+}
+
+func AddBackupCopyPolicy(ctx context.Context, p *BackupCopyPolicy) error {
+	p.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	res, err := DB.ExecContext(ctx, `
+	INSERT INTO backup_copy_policies (name, repository_id, vm_name, nfs_id, automatic_only, mode, enabled, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+		p.Name, p.RepositoryId, p.VmName, p.NfsId, p.AutomaticOnly, p.Mode, p.Enabled, p.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	p.Id = int(id)
+	return nil
+}
+
+func UpdateBackupCopyPolicy(ctx context.Context, p BackupCopyPolicy) error {
+	_, err := DB.ExecContext(ctx, `
+	UPDATE backup_copy_policies SET name = ?, repository_id = ?, vm_name = ?, nfs_id = ?, automatic_only = ?, mode = ?,
+		enabled = ?
+	WHERE id = ?;`,
+		p.Name, p.RepositoryId, p.VmName, p.NfsId, p.AutomaticOnly, p.Mode, p.Enabled, p.Id)
+	return err
+}
+
+func GetBackupCopyPolicy(ctx context.Context, id int) (*BackupCopyPolicy, error) {
+	p, err := scanBackupCopyPolicy(DB.QueryRowContext(ctx, `SELECT `+backupCopyPolicyColumns+` FROM backup_copy_policies WHERE id = ?;`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func GetBackupCopyPolicies(ctx context.Context) ([]BackupCopyPolicy, error) {
+	return queryBackupCopyPolicies(ctx, `SELECT `+backupCopyPolicyColumns+` FROM backup_copy_policies ORDER BY id;`)
+}
+
+// GetBackupCopyPoliciesFor returns the enabled policies matching backup.
+func GetBackupCopyPoliciesFor(ctx context.Context, backup VirshBackup) ([]BackupCopyPolicy, error) {
+	return queryBackupCopyPolicies(ctx, `SELECT `+backupCopyPolicyColumns+` FROM backup_copy_policies
+	WHERE enabled = 1 AND (vm_name = '' OR vm_name = ?) AND (nfs_id = 0 OR nfs_id = ?) AND (automatic_only = 0 OR ?)
+	ORDER BY id;`, backup.Name, backup.NfsId, backup.Automatic)
+}
+
+func queryBackupCopyPolicies(ctx context.Context, query string, args ...any) ([]BackupCopyPolicy, error) {
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []BackupCopyPolicy{}
+	for rows.Next() {
+		p, err := scanBackupCopyPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+func DeleteBackupCopyPolicy(ctx context.Context, id int) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM backup_copy_policies WHERE id = ?;`, id)
+	return err
+}
+
+func scanBackupReplica(row rowScanner) (BackupReplica, error) {
+	var r BackupReplica
+	err := row.Scan(&r.Id, &r.BackupId, &r.RepositoryId, &r.PolicyId, &r.Mode, &r.RemoteKey, &r.Status, &r.Error,
+		&r.Attempts, &r.Size, &r.VmName, &r.BackupCreatedAt, &r.Compression, &r.Encrypted, &r.KeyId, &r.OriginalSize,
+		&r.Checksum, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// AddBackupReplica queues a copy, false when the backup already has one in
+// that repository.
+func AddBackupReplica(ctx context.Context, r *BackupReplica) (bool, error) {
+	r.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	r.UpdatedAt = r.CreatedAt
+	if r.Status == "" {
+		r.Status = BackupReplicaPending
+	}
+	res, err := DB.ExecContext(ctx, `
+	INSERT OR IGNORE INTO backup_replicas (backup_id, repository_id, policy_id, mode, remote_key, status, vm_name,
+		backup_created_at, compression, encrypted, key_id, original_size, checksum, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		r.BackupId, r.RepositoryId, r.PolicyId, r.Mode, r.RemoteKey, r.Status, r.VmName,
+		r.BackupCreatedAt, r.Compression, r.Encrypted, r.KeyId, r.OriginalSize, r.Checksum, r.CreatedAt, r.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	r.Id = int(id)
+	return true, nil
+}
+
+// SetBackupReplicaStatus records the state of a copy, moving to running
+// counts an attempt.
+func SetBackupReplicaStatus(ctx context.Context, id int, status, message string, size int64) error {
+	_, err := DB.ExecContext(ctx, `
+	UPDATE backup_replicas SET status = ?, error = ?, size = CASE WHEN ? > 0 THEN ? ELSE size END,
+		attempts = attempts + CASE WHEN ? = ? THEN 1 ELSE 0 END, updated_at = ?
+	WHERE id = ?;`,
+		status, message, size, size, status, BackupReplicaRunning, time.Now().UTC().Format(time.RFC3339), id)
+	return err
+}
+
+// ResetRunningBackupReplicas marks the copies a stopped master left running
+// as failed so they are retried.
+func ResetRunningBackupReplicas(ctx context.Context) error {
+	_, err := DB.ExecContext(ctx, `UPDATE backup_replicas SET status = ?, error = 'interrupted', updated_at = ? WHERE status = ?;`,
+		BackupReplicaFailed, time.Now().UTC().Format(time.RFC3339), BackupReplicaRunning)
+	return err
+}
+
+func GetBackupReplica(ctx context.Context, id int) (*BackupReplica, error) {
+	r, err := scanBackupReplica(DB.QueryRowContext(ctx, `SELECT `+backupReplicaColumns+` FROM backup_replicas WHERE id = ?;`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &r, nil
+}
+
+// BackupReplicaFilter narrows GetBackupReplicas, zero values match any.
+type BackupReplicaFilter struct {
+	BackupId     int
+	RepositoryId int
+	Status       string
+}
+
+// GetBackupReplicas lists replicas, newest first.
+func GetBackupReplicas(ctx context.Context, filter BackupReplicaFilter) ([]BackupReplica, error) {
+	return queryBackupReplicas(ctx, `SELECT `+backupReplicaColumns+` FROM backup_replicas
+	WHERE (? = 0 OR backup_id = ?) AND (? = 0 OR repository_id = ?) AND (? = '' OR status = ?)
+	ORDER BY id DESC;`,
+		filter.BackupId, filter.BackupId, filter.RepositoryId, filter.RepositoryId, filter.Status, filter.Status)
+}
+
+// GetRetryableBackupReplicas returns the failed copies of backups that still
+// exist with fewer than maxAttempts attempts, oldest first.
+func GetRetryableBackupReplicas(ctx context.Context, maxAttempts int) ([]BackupReplica, error) {
+	return queryBackupReplicas(ctx, `SELECT `+backupReplicaColumns+` FROM backup_replicas r
+	WHERE r.status = ? AND r.attempts < ? AND EXISTS (SELECT 1 FROM virsh_backups b WHERE b.id = r.backup_id)
+	ORDER BY r.id;`, BackupReplicaFailed, maxAttempts)
+}
+
+// GetOrphanedSyncReplicas returns the sync copies whose backup was deleted.
+func GetOrphanedSyncReplicas(ctx context.Context) ([]BackupReplica, error) {
+	return queryBackupReplicas(ctx, `SELECT `+backupReplicaColumns+` FROM backup_replicas r
+	WHERE r.mode = ? AND r.status != ? AND NOT EXISTS (SELECT 1 FROM virsh_backups b WHERE b.id = r.backup_id)
+	ORDER BY r.id;`, BackupCopyModeSync, BackupReplicaRunning)
+}
+
+func queryBackupReplicas(ctx context.Context, query string, args ...any) ([]BackupReplica, error) {
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replicas := []BackupReplica{}
+	for rows.Next() {
+		r, err := scanBackupReplica(rows)
+		if err != nil {
+			return nil, err
+		}
+		replicas = append(replicas, r)
+	}
+	return replicas, rows.Err()
+}
+
+func DeleteBackupReplica(ctx context.Context, id int) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM backup_replicas WHERE id = ?;`, id)
+	return err
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestBackupRepositoriesPoliciesAndReplicas(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateTableBackups(ctx); err != nil {
+		t.Fatalf("create backups table: %v", err)
+	}
+	if err := CreateBackupRepositoryTables(ctx); err != nil {
+		t.Fatalf("create repository tables: %v", err)
+	}
+
+	repo := &BackupRepository{Name: "offsite", Kind: "sftp", Endpoint: "nas:22", AccessKey: "backup", SecretKey: "pw"}
+	if err := AddBackupRepository(ctx, repo); err != nil {
+		t.Fatalf("add repository: %v", err)
+	}
+	if err := AddBackupRepository(ctx, &BackupRepository{Name: "offsite", Kind: "local"}); err == nil {
+		t.Fatalf("expected repository names to be unique")
+	}
+	if err := SetBackupRepositoryHostKey(ctx, repo.Id, "ssh-ed25519 AAAA1"); err != nil {
+		t.Fatalf("set host key: %v", err)
+	}
+	if err := SetBackupRepositoryHostKey(ctx, repo.Id, "ssh-ed25519 AAAA2"); err != nil {
+		t.Fatalf("set host key: %v", err)
+	}
+	got, err := GetBackupRepository(ctx, repo.Id)
+	if err != nil || got == nil || got.HostKey != "ssh-ed25519 AAAA1" || got.SecretKey != "pw" {
+		t.Fatalf("expected the first host key to stay pinned, got %+v (%v)", got, err)
+	}
+
+	policies := []*BackupCopyPolicy{
+		{Name: "all", RepositoryId: repo.Id, Mode: BackupCopyModeCopy, Enabled: true},
+		{Name: "web auto", RepositoryId: repo.Id, VmName: "web", AutomaticOnly: true, Mode: BackupCopyModeSync, Enabled: true},
+		{Name: "other share", RepositoryId: repo.Id, NfsId: 2, Mode: BackupCopyModeCopy, Enabled: true},
+		{Name: "off", RepositoryId: repo.Id, Mode: BackupCopyModeCopy},
+	}
+	for _, p := range policies {
+		if err := AddBackupCopyPolicy(ctx, p); err != nil {
+			t.Fatalf("add policy: %v", err)
+		}
+	}
+	manual := VirshBackup{Name: "web", NfsId: 1}
+	auto := VirshBackup{Name: "web", NfsId: 1, Automatic: true}
+	if matched, _ := GetBackupCopyPoliciesFor(ctx, manual); len(matched) != 1 || matched[0].Id != policies[0].Id {
+		t.Fatalf("manual backup should only match the catch-all policy, got %+v", matched)
+	}
+	if matched, _ := GetBackupCopyPoliciesFor(ctx, auto); len(matched) != 2 {
+		t.Fatalf("automatic backup should match two policies, got %+v", matched)
+	}
+
+	backup := &VirshBackup{Name: "web", Path: "/bak/backup-1/web.qcow2", NfsId: 1, Automatic: true}
+	if err := InsertVirshBackup(ctx, backup); err != nil {
+		t.Fatalf("insert backup: %v", err)
+	}
+	replica := &BackupReplica{BackupId: backup.Id, RepositoryId: repo.Id, PolicyId: policies[1].Id,
+		Mode: BackupCopyModeSync, RemoteKey: "web/1/web.qcow2", VmName: "web"}
+	if added, err := AddBackupReplica(ctx, replica); err != nil || !added {
+		t.Fatalf("add replica: %v", err)
+	}
+	if added, err := AddBackupReplica(ctx, &BackupReplica{BackupId: backup.Id, RepositoryId: repo.Id,
+		Mode: BackupCopyModeCopy, RemoteKey: "web/1/web.qcow2", VmName: "web"}); err != nil || added {
+		t.Fatalf("expected one replica per backup and repository (%v)", err)
+	}
+
+	if err := SetBackupReplicaStatus(ctx, replica.Id, BackupReplicaRunning, "", 0); err != nil {
+		t.Fatalf("set running: %v", err)
+	}
+	if err := ResetRunningBackupReplicas(ctx); err != nil {
+		t.Fatalf("reset running: %v", err)
+	}
+	retry, err := GetRetryableBackupReplicas(ctx, 3)
+	if err != nil || len(retry) != 1 || retry[0].Attempts != 1 || retry[0].Error != "interrupted" {
+		t.Fatalf("expected the interrupted copy to be retried, got %+v (%v)", retry, err)
+	}
+	if retry, _ := GetRetryableBackupReplicas(ctx, 1); len(retry) != 0 {
+		t.Fatalf("expected no retry past the attempt limit")
+	}
+	if err := SetBackupReplicaStatus(ctx, replica.Id, BackupReplicaDone, "", 1234); err != nil {
+		t.Fatalf("set done: %v", err)
+	}
+
+	if orphans, _ := GetOrphanedSyncReplicas(ctx); len(orphans) != 0 {
+		t.Fatalf("backup still exists, got orphans %+v", orphans)
+	}
+	if err := DeleteVirshBackupById(ctx, backup.Id); err != nil {
+		t.Fatalf("delete backup: %v", err)
+	}
+	orphans, err := GetOrphanedSyncReplicas(ctx)
+	if err != nil || len(orphans) != 1 || orphans[0].Size != 1234 || orphans[0].Attempts != 1 {
+		t.Fatalf("expected the sync replica to be orphaned, got %+v (%v)", orphans, err)
+	}
+
+	if err := DeleteBackupRepository(ctx, repo.Id); err != nil {
+		t.Fatalf("delete repository: %v", err)
+	}
+	if left, _ := GetBackupReplicas(ctx, BackupReplicaFilter{}); len(left) != 0 {
+		t.Fatalf("replicas of a deleted repository should be forgotten, got %+v", left)
+	}
+	if left, _ := GetBackupCopyPolicies(ctx); len(left) != 0 {
+		t.Fatalf("policies of a deleted repository should be removed, got %+v", left)
+	}
+}
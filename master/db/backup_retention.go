@@ -0,0 +1,171 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// reasons a backup survives a prune
+const (
+	RetainLast    = "last"
+	RetainDaily   = "daily"
+	RetainWeekly  = "weekly"
+	RetainMonthly = "monthly"
+	RetainYearly  = "yearly"
+	RetainPinned  = "pinned"
+	RetainMinAge  = "min_age"
+	RetainChain   = "chain"
+	// created_at could not be parsed, never delete what we can't date
+	RetainUnknownAge = "unknown_age"
+)
+
+// RetentionDecision is what the next prune does with one backup, Reasons is
+// empty for the ones it removes.
+type RetentionDecision struct {
+	BackupId  int      `json:"backup_id"`
+	Name      string   `json:"name"`
+	Path      string   `json:"path"`
+	CreatedAt string   `json:"created_at"`
+	Keep      bool     `json:"keep"`
+	Reasons   []string `json:"reasons"`
+}
+
+// ParseBackupTime reads virsh_backups.created_at, which sqlite hands back
+// either as RFC3339 or as "2006-01-02 15:04:05" in UTC.
+func ParseBackupTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if ts, err := time.Parse(time.RFC3339, value); err == nil {
+		return ts, true
+	}
+	if ts, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.UTC); err == nil {
+		return ts, true
+	}
+	return time.Time{}, false
+}
+
+// PlanBackupRetention applies the retention policy of ab to the automatic
+// backups of its vm and returns a decision per backup, newest first so the
+// removals can run in order with children going before their parents.
+//
+// The newest MaxBackupsRetain are kept, then the newest backup of each of the
+// last KeepDaily days, KeepWeekly ISO weeks, KeepMonthly months and KeepYearly
+// years that have a backup. Pinned backups, backups younger than MinAgeDays
+// and every parent an incremental kept backup needs are never removed.
+func PlanBackupRetention(backups []VirshBackup, ab AutomaticBackup, now time.Time) []RetentionDecision {
+	sorted := make([]VirshBackup, len(backups))
+	copy(sorted, backups)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ti, okI := ParseBackupTime(sorted[i].CreatedAt)
+		tj, okJ := ParseBackupTime(sorted[j].CreatedAt)
+		switch {
+		case okI && okJ && !ti.Equal(tj):
+			return ti.After(tj)
+		case okI != okJ:
+			return okI
+		default:
+			return sorted[i].Id > sorted[j].Id
+		}
+	})
+
+	reasons := make(map[int][]string, len(sorted))
+	keep := func(id int, reason string) {
+		reasons[id] = append(reasons[id], reason)
+	}
+
+	tiers := []struct {
+		reason string
+		count  int
+		period func(time.Time) string
+	}{
+		{RetainDaily, ab.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{RetainWeekly, ab.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{RetainMonthly, ab.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") }},
+		{RetainYearly, ab.KeepYearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+	seen := make([]map[string]bool, len(tiers))
+	for i := range seen {
+		seen[i] = map[string]bool{}
+	}
+
+	minAge := time.Duration(ab.MinAgeDays) * 24 * time.Hour
+	for i, b := range sorted {
+		if i < ab.MaxBackupsRetain {
+			keep(b.Id, RetainLast)
+		}
+		if b.Pinned {
+			keep(b.Id, RetainPinned)
+		}
+		created, ok := ParseBackupTime(b.CreatedAt)
+		if !ok {
+			keep(b.Id, RetainUnknownAge)
+			continue
+		}
+		if minAge > 0 && now.Sub(created) < minAge {
+			keep(b.Id, RetainMinAge)
+		}
+		created = created.UTC()
+		for t, tier := range tiers {
+			period := tier.period(created)
+			if seen[t][period] || len(seen[t]) >= tier.count {
+				continue
+			}
+			seen[t][period] = true
+			keep(b.Id, tier.reason)
+		}
+	}
+
+	// incrementals are useless without their parents
+	byID := make(map[int]VirshBackup, len(sorted))
+	for _, b := range sorted {
+		byID[b.Id] = b
+	}
+	for _, b := range sorted {
+		if len(reasons[b.Id]) == 0 || b.ParentId == nil {
+			continue
+		}
+		visited := map[int]bool{b.Id: true}
+		for parent := b.ParentId; parent != nil && !visited[*parent]; {
+			visited[*parent] = true
+			p, ok := byID[*parent]
+			if !ok {
+				break
+			}
+			if !containsReason(reasons[p.Id], RetainChain) {
+				keep(p.Id, RetainChain)
+			}
+			parent = p.ParentId
+		}
+	}
+
+	decisions := make([]RetentionDecision, 0, len(sorted))
+	for _, b := range sorted {
+		r := reasons[b.Id]
+		if r == nil {
+			r = []string{}
+		}
+		decisions = append(decisions, RetentionDecision{
+			BackupId:  b.Id,
+			Name:      b.Name,
+			Path:      b.Path,
+			CreatedAt: b.CreatedAt,
+			Keep:      len(r) > 0,
+			Reasons:   r,
+		})
+	}
+	return decisions
+}
+
+func containsReason(reasons []string, reason string) bool {
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
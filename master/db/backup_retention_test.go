@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestPlanBackupRetention(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	at := func(days int) string {
+		return now.Add(-time.Duration(days) * 24 * time.Hour).Format("2006-01-02 15:04:05")
+	}
+
+	// one backup a day for 100 days, id 1 is the newest
+	var backups []VirshBackup
+	for day := 0; day < 100; day++ {
+		backups = append(backups, VirshBackup{Id: day + 1, Name: "web", CreatedAt: at(day), Automatic: true})
+	}
+	// an incremental whose full backup would otherwise go
+	parent := 90
+	backups[1].ParentId = &parent
+	backups[1].Incremental = true
+	backups[70].Pinned = true
+
+	plan := PlanBackupRetention(backups, AutomaticBackup{
+		MaxBackupsRetain: 2,
+		KeepDaily:        3,
+		KeepWeekly:       2,
+		KeepMonthly:      3,
+		KeepYearly:       1,
+	}, now)
+	if len(plan) != len(backups) {
+		t.Fatalf("expected a decision per backup, got %d", len(plan))
+	}
+	if plan[0].BackupId != 1 || plan[len(plan)-1].BackupId != 100 {
+		t.Fatalf("plan is not newest first: %d .. %d", plan[0].BackupId, plan[len(plan)-1].BackupId)
+	}
+
+	kept := map[int][]string{}
+	for _, d := range plan {
+		if d.Keep != (len(d.Reasons) > 0) {
+			t.Fatalf("backup %d: keep %v with reasons %v", d.BackupId, d.Keep, d.Reasons)
+		}
+		if d.Keep {
+			kept[d.BackupId] = d.Reasons
+		}
+	}
+
+	// 2026-03-15 is a sunday: daily keeps 03-15..03-13, weekly the newest of
+	// this and last week (03-15, 03-08), monthly the newest of march, february
+	// and january (03-15, 02-28, 01-31), yearly 03-15
+	for id, reason := range map[int]string{
+		1: RetainLast, 2: RetainLast, 3: RetainDaily,
+		8:  RetainWeekly,
+		16: RetainMonthly, 44: RetainMonthly,
+		71: RetainPinned,
+		90: RetainChain,
+	} {
+		if !containsReason(kept[id], reason) {
+			t.Fatalf("backup %d should be kept as %s, got %v", id, reason, kept[id])
+		}
+	}
+	if len(kept) != 8 {
+		t.Fatalf("expected 8 backups kept, got %d: %v", len(kept), kept)
+	}
+
+	// nothing younger than the minimum age goes, whatever the tiers say
+	plan = PlanBackupRetention(backups, AutomaticBackup{MaxBackupsRetain: 1, MinAgeDays: 10}, now)
+	for _, d := range plan {
+		age := d.BackupId - 1
+		if age < 10 && !containsReason(d.Reasons, RetainMinAge) {
+			t.Fatalf("backup %d is %d days old and should be kept, got %v", d.BackupId, age, d.Reasons)
+		}
+		if age >= 10 && d.Keep && d.BackupId != 71 && d.BackupId != 90 {
+			t.Fatalf("backup %d should be pruned, got %v", d.BackupId, d.Reasons)
+		}
+	}
+
+	// backups without a readable date are never pruned
+	plan = PlanBackupRetention([]VirshBackup{{Id: 1, CreatedAt: "garbage"}, {Id: 2, CreatedAt: at(5)}}, AutomaticBackup{MaxBackupsRetain: 1}, now)
+	if plan[0].BackupId != 2 || !plan[1].Keep || plan[1].Reasons[0] != RetainUnknownAge {
+		t.Fatalf("unexpected plan for an undated backup: %+v", plan)
+	}
+}
+
+func TestBackupRetentionColumns(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	for i := 0; i < 2; i++ {
+		if err := CreateTableBackups(ctx); err != nil {
+			t.Fatalf("create backups table: %v", err)
+		}
+		if err := CreateTableAutomaticBackup(ctx); err != nil {
+			t.Fatalf("create automatic backup table: %v", err)
+		}
+	}
+
+	ab := &AutomaticBackup{VmName: "web", FrequencyDays: 1, NfsMountId: 1, MaxBackupsRetain: 3, Enabled: true, KeepDaily: 7, KeepWeekly: 4}
+	if err := AddAutomaticBackup(ctx, ab); err != nil {
+		t.Fatalf("add automatic backup: %v", err)
+	}
+	ab.KeepMonthly, ab.KeepYearly, ab.MinAgeDays = 12, 2, 3
+	if err := UpdateAutomaticBackup(ctx, ab); err != nil {
+		t.Fatalf("update automatic backup: %v", err)
+	}
+	got, err := GetAutomaticBackupById(ctx, ab.Id)
+	if err != nil || got == nil {
+		t.Fatalf("get automatic backup: %v", err)
+	}
+	if got.KeepDaily != 7 || got.KeepWeekly != 4 || got.KeepMonthly != 12 || got.KeepYearly != 2 || got.MinAgeDays != 3 {
+		t.Fatalf("retention not stored: %+v", got)
+	}
+
+	b := &VirshBackup{Name: "web", Path: "/bak/backup-1/web.qcow2", NfsId: 1, Automatic: true}
+	if err := InsertVirshBackup(ctx, b); err != nil {
+		t.Fatalf("insert backup: %v", err)
+	}
+	if err := SetVirshBackupPinned(ctx, b.Id, true); err != nil {
+		t.Fatalf("pin backup: %v", err)
+	}
+	baks, err := GetAutomaticBackups(ctx, "web")
+	if err != nil || len(baks) != 1 || !baks[0].Pinned {
+		t.Fatalf("expected one pinned backup, got %+v (%v)", baks, err)
+	}
+}
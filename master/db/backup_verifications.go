@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// a check runs qemu-img check on every file of the chain and compares the
+// checksums, a restore boots the backup as a throwaway vm
+const (
+	BackupVerifyCheck   = "check"
+	BackupVerifyRestore = "restore"
+
+	BackupVerifyOk     = "ok"
+	BackupVerifyFailed = "failed"
+)
+
+type BackupVerification struct {
+	Id         int    `json:"id"`
+	BackupId   int    `json:"backup_id"`
+	Kind       string `json:"kind"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+}
+
+const backupVerificationColumns = `id, backup_id, kind, status, message, started_at, finished_at`
+
+func CreateBackupVerificationTable(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS backup_verifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		backup_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		status TEXT NOT NULL,
+		message TEXT NOT NULL DEFAULT '',
+		started_at TEXT NOT NULL,
+		finished_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_backup_verifications_backup ON backup_verifications(backup_id, kind);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+func scanBackupVerification(row rowScanner) (BackupVerification, error) {
+	var v BackupVerification
+	err := row.Scan(&v.Id, &v.BackupId, &v.Kind, &v.Status, &v.Message, &v.StartedAt, &v.FinishedAt)
+	return v, err
+}
+
+// AddBackupVerification records a finished verification, FinishedAt is set to
+// now.
+func AddBackupVerification(ctx context.Context, v *BackupVerification) error {
+	v.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	res, err := DB.ExecContext(ctx, `
+	INSERT INTO backup_verifications (backup_id, kind, status, message, started_at, finished_at)
+	VALUES (?, ?, ?, ?, ?, ?);`,
+		v.BackupId, v.Kind, v.Status, v.Message, v.StartedAt, v.FinishedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	v.Id = int(id)
+	return nil
+}
+
+// GetBackupVerifications returns the verifications of a backup, newest first.
+func GetBackupVerifications(ctx context.Context, backupID int, limit int) ([]BackupVerification, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+backupVerificationColumns+` FROM backup_verifications
+	WHERE backup_id = ? ORDER BY id DESC LIMIT ?;`, backupID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	verifications := []BackupVerification{}
+	for rows.Next() {
+		v, err := scanBackupVerification(rows)
+		if err != nil {
+			return nil, err
+		}
+		verifications = append(verifications, v)
+	}
+	return verifications, rows.Err()
+}
+
+// GetLatestBackupVerifications returns the newest verification of every backup
+// that had one, by backup id.
+func GetLatestBackupVerifications(ctx context.Context) (map[int]BackupVerification, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+backupVerificationColumns+` FROM backup_verifications
+	WHERE id IN (SELECT MAX(id) FROM backup_verifications GROUP BY backup_id);`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	latest := map[int]BackupVerification{}
+	for rows.Next() {
+		v, err := scanBackupVerification(rows)
+		if err != nil {
+			return nil, err
+		}
+		latest[v.BackupId] = v
+	}
+	return latest, rows.Err()
+}
+
+// GetBackupsDueForCheck returns the backups without a check that finished
+// after since, oldest backup first.
+func GetBackupsDueForCheck(ctx context.Context, since time.Time) ([]VirshBackup, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+virshBackupColumns+` FROM virsh_backups b
+	WHERE NOT EXISTS (
+		SELECT 1 FROM backup_verifications v
+		WHERE v.backup_id = b.id AND v.kind = ? AND v.finished_at >= ?
+	)
+	ORDER BY b.id;`, BackupVerifyCheck, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var backups []VirshBackup
+	for rows.Next() {
+		b, err := scanVirshBackup(rows)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, b)
+	}
+	return backups, rows.Err()
+}
+
+func DeleteBackupVerifications(ctx context.Context, backupID int) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM backup_verifications WHERE backup_id = ?;`, backupID)
+	return err
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestBackupVerifications(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateTableBackups(ctx); err != nil {
+		t.Fatalf("create backups table: %v", err)
+	}
+	if err := CreateBackupVerificationTable(ctx); err != nil {
+		t.Fatalf("create verification table: %v", err)
+	}
+
+	web := &VirshBackup{Name: "web", Path: "/bak/backup-1/web.qcow2", NfsId: 1}
+	db1 := &VirshBackup{Name: "db", Path: "/bak/backup-2/db.qcow2", NfsId: 1}
+	for _, b := range []*VirshBackup{web, db1} {
+		if err := InsertVirshBackup(ctx, b); err != nil {
+			t.Fatalf("insert backup: %v", err)
+		}
+	}
+	if err := SetVirshBackupChecksum(ctx, web.Id, "abc123"); err != nil {
+		t.Fatalf("set checksum: %v", err)
+	}
+	got, err := GetVirshBackupById(ctx, web.Id)
+	if err != nil || got == nil || got.Checksum != "abc123" {
+		t.Fatalf("expected checksum abc123, got %+v (%v)", got, err)
+	}
+
+	since := time.Now().Add(-time.Hour)
+	due, err := GetBackupsDueForCheck(ctx, since)
+	if err != nil {
+		t.Fatalf("due for check: %v", err)
+	}
+	if len(due) != 2 {
+		t.Fatalf("expected both backups due, got %d", len(due))
+	}
+
+	started := time.Now().UTC().Format(time.RFC3339)
+	check := &BackupVerification{BackupId: web.Id, Kind: BackupVerifyCheck, Status: BackupVerifyOk, StartedAt: started}
+	if err := AddBackupVerification(ctx, check); err != nil {
+		t.Fatalf("add check: %v", err)
+	}
+	// a restore does not count as a check
+	restore := &BackupVerification{BackupId: db1.Id, Kind: BackupVerifyRestore, Status: BackupVerifyFailed, Message: "agent never answered", StartedAt: started}
+	if err := AddBackupVerification(ctx, restore); err != nil {
+		t.Fatalf("add restore: %v", err)
+	}
+
+	due, err = GetBackupsDueForCheck(ctx, since)
+	if err != nil {
+		t.Fatalf("due for check: %v", err)
+	}
+	if len(due) != 1 || due[0].Id != db1.Id {
+		t.Fatalf("expected only db due for a check, got %+v", due)
+	}
+
+	latest, err := GetLatestBackupVerifications(ctx)
+	if err != nil {
+		t.Fatalf("latest verifications: %v", err)
+	}
+	if latest[web.Id].Kind != BackupVerifyCheck || latest[db1.Id].Status != BackupVerifyFailed {
+		t.Fatalf("unexpected latest verifications: %+v", latest)
+	}
+
+	if err := DeleteBackupVerifications(ctx, web.Id); err != nil {
+		t.Fatalf("delete verifications: %v", err)
+	}
+	history, err := GetBackupVerifications(ctx, web.Id, 10)
+	if err != nil {
+		t.Fatalf("get verifications: %v", err)
+	}
+	if len(history) != 0 {
+		t.Fatalf("expected no verifications left, got %d", len(history))
+	}
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// BalancerConfig drives the cluster load balancer. The plan is always
+// available, AutoApply migrates VMs by itself every IntervalMinutes while the
+// local time is between WindowStart and WindowEnd (HH:MM, both empty means
+// any time).
+type BalancerConfig struct {
+	AutoApply       bool    `json:"auto_apply"`
+	WindowStart     string  `json:"window_start"`
+	WindowEnd       string  `json:"window_end"`
+	Threshold       float64 `json:"threshold"`
+	MaxMigrations   int     `json:"max_migrations"`
+	LookbackMinutes int     `json:"lookback_minutes"`
+	IntervalMinutes int     `json:"interval_minutes"`
+	UpdatedAt       string  `json:"updated_at"`
+}
+
+// DefaultBalancerConfig is used until the config is first saved.
+func DefaultBalancerConfig() BalancerConfig {
+	return BalancerConfig{
+		Threshold:       20,
+		MaxMigrations:   3,
+		LookbackMinutes: 30,
+		IntervalMinutes: 60,
+	}
+}
+
+func CreateBalancerTable(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS balancer_config (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		auto_apply INTEGER NOT NULL DEFAULT 0,
+		window_start TEXT NOT NULL DEFAULT '',
+		window_end TEXT NOT NULL DEFAULT '',
+		threshold REAL NOT NULL,
+		max_migrations INTEGER NOT NULL,
+		lookback_minutes INTEGER NOT NULL,
+		interval_minutes INTEGER NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+func GetBalancerConfig(ctx context.Context) (BalancerConfig, error) {
+	cfg := DefaultBalancerConfig()
+	row := DB.QueryRowContext(ctx, `SELECT auto_apply, window_start, window_end, threshold, max_migrations,
+	lookback_minutes, interval_minutes, updated_at FROM balancer_config WHERE id = 1;`)
+	err := row.Scan(&cfg.AutoApply, &cfg.WindowStart, &cfg.WindowEnd, &cfg.Threshold, &cfg.MaxMigrations,
+		&cfg.LookbackMinutes, &cfg.IntervalMinutes, &cfg.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return DefaultBalancerConfig(), nil
+	}
+	return cfg, err
+}
+
+func SetBalancerConfig(ctx context.Context, cfg BalancerConfig) error {
+	_, err := DB.ExecContext(ctx, `
+	INSERT INTO balancer_config (id, auto_apply, window_start, window_end, threshold, max_migrations,
+		lookback_minutes, interval_minutes, updated_at)
+	VALUES (1, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(id) DO UPDATE SET auto_apply = excluded.auto_apply, window_start = excluded.window_start,
+		window_end = excluded.window_end, threshold = excluded.threshold, max_migrations = excluded.max_migrations,
+		lookback_minutes = excluded.lookback_minutes, interval_minutes = excluded.interval_minutes,
+		updated_at = excluded.updated_at;`,
+		cfg.AutoApply, cfg.WindowStart, cfg.WindowEnd, cfg.Threshold, cfg.MaxMigrations,
+		cfg.LookbackMinutes, cfg.IntervalMinutes, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestBalancerConfig(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateBalancerTable(ctx); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	cfg, err := GetBalancerConfig(ctx)
+	if err != nil || cfg != DefaultBalancerConfig() {
+		t.Fatalf("expected the default config, got %+v (%v)", cfg, err)
+	}
+
+	cfg.AutoApply = true
+	cfg.WindowStart = "22:00"
+	cfg.WindowEnd = "06:00"
+	cfg.MaxMigrations = 5
+	if err := SetBalancerConfig(ctx, cfg); err != nil {
+		t.Fatalf("set config: %v", err)
+	}
+	cfg.Threshold = 10
+	if err := SetBalancerConfig(ctx, cfg); err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+
+	saved, err := GetBalancerConfig(ctx)
+	if err != nil {
+		t.Fatalf("get config: %v", err)
+	}
+	if !saved.AutoApply || saved.WindowStart != "22:00" || saved.WindowEnd != "06:00" ||
+		saved.MaxMigrations != 5 || saved.Threshold != 10 || saved.UpdatedAt == "" {
+		t.Fatalf("unexpected config %+v", saved)
+	}
+}
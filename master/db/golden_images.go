@@ -0,0 +1,198 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// a golden image is either a shut off vm or a vm disk whose qcow2 is used as the
+// read only backing file of linked clones
+const (
+	GoldenImageKindVM   = "vm"
+	GoldenImageKindDisk = "disk"
+)
+
+type GoldenImage struct {
+	Id        int    `json:"id"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	NFSID     int    `json:"nfs_id"`
+	DiskPath  string `json:"disk_path"`
+	CreatedAt string `json:"created_at"`
+}
+
+type LinkedClone struct {
+	Id            int    `json:"id"`
+	GoldenImageId int    `json:"golden_image_id"`
+	Kind          string `json:"kind"`
+	Name          string `json:"name"`
+	DiskPath      string `json:"disk_path"`
+	CreatedAt     string `json:"created_at"`
+}
+
+func CreateGoldenImageTables(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS golden_images (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		name TEXT NOT NULL,
+		nfs_id INTEGER NOT NULL,
+		disk_path TEXT NOT NULL UNIQUE,
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (kind, name)
+	);
+	CREATE TABLE IF NOT EXISTS linked_clones (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		golden_image_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		name TEXT NOT NULL,
+		disk_path TEXT NOT NULL UNIQUE,
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (kind, name),
+		FOREIGN KEY (golden_image_id) REFERENCES golden_images(id)
+	);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+func AddGoldenImage(ctx context.Context, kind, name string, nfsID int, diskPath string) (int, error) {
+	query := `
+	INSERT INTO golden_images (kind, name, nfs_id, disk_path, created_at)
+	VALUES (?, ?, ?, ?, ?);
+	`
+	res, err := DB.ExecContext(ctx, query, kind, name, nfsID, diskPath, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func GetAllGoldenImages(ctx context.Context) ([]GoldenImage, error) {
+	const query = `
+	SELECT id, kind, name, nfs_id, disk_path, created_at
+	FROM golden_images
+	ORDER BY id;
+	`
+	rows, err := DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []GoldenImage
+	for rows.Next() {
+		var img GoldenImage
+		if err := rows.Scan(&img.Id, &img.Kind, &img.Name, &img.NFSID, &img.DiskPath, &img.CreatedAt); err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, rows.Err()
+}
+
+func GetGoldenImageByID(ctx context.Context, id int) (*GoldenImage, error) {
+	const query = `
+	SELECT id, kind, name, nfs_id, disk_path, created_at
+	FROM golden_images
+	WHERE id = ?;
+	`
+	var img GoldenImage
+	err := DB.QueryRowContext(ctx, query, id).Scan(&img.Id, &img.Kind, &img.Name, &img.NFSID, &img.DiskPath, &img.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &img, nil
+}
+
+func GetGoldenImageByName(ctx context.Context, kind, name string) (*GoldenImage, error) {
+	const query = `
+	SELECT id, kind, name, nfs_id, disk_path, created_at
+	FROM golden_images
+	WHERE kind = ? AND name = ?;
+	`
+	var img GoldenImage
+	err := DB.QueryRowContext(ctx, query, kind, name).Scan(&img.Id, &img.Kind, &img.Name, &img.NFSID, &img.DiskPath, &img.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &img, nil
+}
+
+func RemoveGoldenImage(ctx context.Context, id int) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM golden_images WHERE id = ?;`, id)
+	return err
+}
+
+func AddLinkedClone(ctx context.Context, goldenImageID int, kind, name, diskPath string) (int, error) {
+	query := `
+	INSERT INTO linked_clones (golden_image_id, kind, name, disk_path, created_at)
+	VALUES (?, ?, ?, ?, ?);
+	`
+	res, err := DB.ExecContext(ctx, query, goldenImageID, kind, name, diskPath, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func GetLinkedClonesByGoldenImage(ctx context.Context, goldenImageID int) ([]LinkedClone, error) {
+	const query = `
+	SELECT id, golden_image_id, kind, name, disk_path, created_at
+	FROM linked_clones
+	WHERE golden_image_id = ?
+	ORDER BY id;
+	`
+	rows, err := DB.QueryContext(ctx, query, goldenImageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clones []LinkedClone
+	for rows.Next() {
+		var c LinkedClone
+		if err := rows.Scan(&c.Id, &c.GoldenImageId, &c.Kind, &c.Name, &c.DiskPath, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		clones = append(clones, c)
+	}
+	return clones, rows.Err()
+}
+
+func GetLinkedCloneByName(ctx context.Context, kind, name string) (*LinkedClone, error) {
+	const query = `
+	SELECT id, golden_image_id, kind, name, disk_path, created_at
+	FROM linked_clones
+	WHERE kind = ? AND name = ?;
+	`
+	var c LinkedClone
+	err := DB.QueryRowContext(ctx, query, kind, name).Scan(&c.Id, &c.GoldenImageId, &c.Kind, &c.Name, &c.DiskPath, &c.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func RemoveLinkedClone(ctx context.Context, kind, name string) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM linked_clones WHERE kind = ? AND name = ?;`, kind, name)
+	return err
+}
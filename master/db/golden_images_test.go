@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestGoldenImagesAndLinkedClones(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateGoldenImageTables(ctx); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+
+	id, err := AddGoldenImage(ctx, GoldenImageKindVM, "debian", 1, "/mnt/share/debian/debian.qcow2")
+	if err != nil {
+		t.Fatalf("add golden image: %v", err)
+	}
+	if _, err := AddGoldenImage(ctx, GoldenImageKindVM, "debian", 1, "/mnt/share/other.qcow2"); err == nil {
+		t.Fatalf("expected duplicate golden image to fail")
+	}
+
+	for _, name := range []string{"lab1", "lab2"} {
+		if _, err := AddLinkedClone(ctx, id, GoldenImageKindVM, name, "/mnt/share/"+name+"/"+name+".qcow2"); err != nil {
+			t.Fatalf("add linked clone %s: %v", name, err)
+		}
+	}
+
+	clones, err := GetLinkedClonesByGoldenImage(ctx, id)
+	if err != nil {
+		t.Fatalf("list clones: %v", err)
+	}
+	if len(clones) != 2 || clones[0].Name != "lab1" || clones[1].Name != "lab2" {
+		t.Fatalf("unexpected clones %+v", clones)
+	}
+
+	if err := RemoveLinkedClone(ctx, GoldenImageKindVM, "lab1"); err != nil {
+		t.Fatalf("remove clone: %v", err)
+	}
+	if c, err := GetLinkedCloneByName(ctx, GoldenImageKindVM, "lab1"); err != nil || c != nil {
+		t.Fatalf("expected lab1 to be gone, got %+v, %v", c, err)
+	}
+	if c, err := GetLinkedCloneByName(ctx, GoldenImageKindVM, "lab2"); err != nil || c == nil || c.GoldenImageId != id {
+		t.Fatalf("expected lab2 linked to %d, got %+v, %v", id, c, err)
+	}
+
+	// disk and vm golden images are looked up separately
+	if img, err := GetGoldenImageByName(ctx, GoldenImageKindDisk, "debian"); err != nil || img != nil {
+		t.Fatalf("expected no disk golden image, got %+v, %v", img, err)
+	}
+}
@@ -0,0 +1,256 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// HAPolicy says if a VM is restarted elsewhere when its host dies. Higher
+// priority VMs are restarted first, PreferredHosts are tried before the rest
+// of the cluster and MaxRestarts caps the failovers in a day, 0 means no cap.
+type HAPolicy struct {
+	VmName         string   `json:"vm_name"`
+	Enabled        bool     `json:"enabled"`
+	Priority       int      `json:"priority"`
+	PreferredHosts []string `json:"preferred_hosts"`
+	MaxRestarts    int      `json:"max_restarts"`
+	UpdatedAt      string   `json:"updated_at"`
+}
+
+// HADomain is the last known domain XML of an HA VM and the host it ran on,
+// it is what the VM is defined from when the host is gone.
+type HADomain struct {
+	VmName      string `json:"vm_name"`
+	MachineName string `json:"machine_name"`
+	XML         string `json:"xml"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// a failover is restarted or failed, a restarted one becomes cleaned once the
+// old host came back and its copy of the VM was removed
+const (
+	HAFailoverRestarted = "restarted"
+	HAFailoverFailed    = "failed"
+	HAFailoverCleaned   = "cleaned"
+)
+
+type HAFailover struct {
+	Id          int    `json:"id"`
+	VmName      string `json:"vm_name"`
+	FromMachine string `json:"from_machine"`
+	ToMachine   string `json:"to_machine"`
+	State       string `json:"state"`
+	Message     string `json:"message"`
+	CreatedAt   string `json:"created_at"`
+	CleanedAt   string `json:"cleaned_at"`
+}
+
+const haFailoverColumns = `id, vm_name, from_machine, to_machine, state, message, created_at, cleaned_at`
+
+func CreateHATables(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS ha_policies (
+		vm_name TEXT PRIMARY KEY,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		priority INTEGER NOT NULL DEFAULT 0,
+		preferred_hosts TEXT NOT NULL DEFAULT '[]',
+		max_restarts INTEGER NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS ha_domains (
+		vm_name TEXT PRIMARY KEY,
+		machine_name TEXT NOT NULL,
+		xml TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS ha_failovers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		vm_name TEXT NOT NULL,
+		from_machine TEXT NOT NULL,
+		to_machine TEXT NOT NULL DEFAULT '',
+		state TEXT NOT NULL,
+		message TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		cleaned_at TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_ha_failovers_vm ON ha_failovers(vm_name, created_at);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+func SetHAPolicy(ctx context.Context, policy HAPolicy) error {
+	hosts, err := marshalStrings(policy.PreferredHosts)
+	if err != nil {
+		return err
+	}
+	_, err = DB.ExecContext(ctx, `
+	INSERT INTO ha_policies (vm_name, enabled, priority, preferred_hosts, max_restarts, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT(vm_name) DO UPDATE SET enabled = excluded.enabled, priority = excluded.priority,
+		preferred_hosts = excluded.preferred_hosts, max_restarts = excluded.max_restarts,
+		updated_at = excluded.updated_at;`,
+		policy.VmName, policy.Enabled, policy.Priority, hosts, policy.MaxRestarts,
+		time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func scanHAPolicy(row rowScanner) (HAPolicy, error) {
+	var policy HAPolicy
+	var hosts string
+	if err := row.Scan(&policy.VmName, &policy.Enabled, &policy.Priority, &hosts, &policy.MaxRestarts, &policy.UpdatedAt); err != nil {
+		return policy, err
+	}
+	var err error
+	policy.PreferredHosts, err = unmarshalStrings(hosts)
+	return policy, err
+}
+
+func GetHAPolicy(ctx context.Context, vmName string) (*HAPolicy, error) {
+	row := DB.QueryRowContext(ctx, `
+	SELECT vm_name, enabled, priority, preferred_hosts, max_restarts, updated_at
+	FROM ha_policies WHERE vm_name = ?;`, vmName)
+	policy, err := scanHAPolicy(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// GetHAPolicies returns every policy, highest priority first.
+func GetHAPolicies(ctx context.Context) ([]HAPolicy, error) {
+	rows, err := DB.QueryContext(ctx, `
+	SELECT vm_name, enabled, priority, preferred_hosts, max_restarts, updated_at
+	FROM ha_policies ORDER BY priority DESC, vm_name;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []HAPolicy
+	for rows.Next() {
+		policy, err := scanHAPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// DeleteHAPolicy removes the policy and the stored domain of a VM.
+func DeleteHAPolicy(ctx context.Context, vmName string) error {
+	if _, err := DB.ExecContext(ctx, `DELETE FROM ha_policies WHERE vm_name = ?;`, vmName); err != nil {
+		return err
+	}
+	_, err := DB.ExecContext(ctx, `DELETE FROM ha_domains WHERE vm_name = ?;`, vmName)
+	return err
+}
+
+func SetHADomain(ctx context.Context, vmName, machineName, xml string) error {
+	_, err := DB.ExecContext(ctx, `
+	INSERT INTO ha_domains (vm_name, machine_name, xml, updated_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT(vm_name) DO UPDATE SET machine_name = excluded.machine_name, xml = excluded.xml,
+		updated_at = excluded.updated_at;`,
+		vmName, machineName, xml, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// GetHADomains returns the stored domains, all of them when machineName is
+// empty.
+func GetHADomains(ctx context.Context, machineName string) ([]HADomain, error) {
+	query := `SELECT vm_name, machine_name, xml, updated_at FROM ha_domains`
+	var args []any
+	if machineName != "" {
+		query += ` WHERE machine_name = ?`
+		args = append(args, machineName)
+	}
+	query += ` ORDER BY vm_name;`
+
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []HADomain
+	for rows.Next() {
+		var domain HADomain
+		if err := rows.Scan(&domain.VmName, &domain.MachineName, &domain.XML, &domain.UpdatedAt); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
+
+func AddHAFailover(ctx context.Context, failover HAFailover) error {
+	_, err := DB.ExecContext(ctx, `
+	INSERT INTO ha_failovers (vm_name, from_machine, to_machine, state, message, created_at)
+	VALUES (?, ?, ?, ?, ?, ?);`,
+		failover.VmName, failover.FromMachine, failover.ToMachine, failover.State, failover.Message,
+		time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func scanHAFailovers(rows *sql.Rows) ([]HAFailover, error) {
+	defer rows.Close()
+	var failovers []HAFailover
+	for rows.Next() {
+		var f HAFailover
+		if err := rows.Scan(&f.Id, &f.VmName, &f.FromMachine, &f.ToMachine, &f.State, &f.Message, &f.CreatedAt, &f.CleanedAt); err != nil {
+			return nil, err
+		}
+		failovers = append(failovers, f)
+	}
+	return failovers, rows.Err()
+}
+
+// GetHAFailovers returns the failover history, newest first. vmName filters
+// it down to one VM when set.
+func GetHAFailovers(ctx context.Context, vmName string, limit int) ([]HAFailover, error) {
+	query := `SELECT ` + haFailoverColumns + ` FROM ha_failovers`
+	var args []any
+	if vmName != "" {
+		query += ` WHERE vm_name = ?`
+		args = append(args, vmName)
+	}
+	query += ` ORDER BY id DESC LIMIT ?;`
+	args = append(args, limit)
+
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanHAFailovers(rows)
+}
+
+// GetUncleanedHAFailovers returns the VMs restarted away from a machine that
+// may still be defined there.
+func GetUncleanedHAFailovers(ctx context.Context, fromMachine string) ([]HAFailover, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+haFailoverColumns+` FROM ha_failovers
+	WHERE from_machine = ? AND state = ? ORDER BY id;`, fromMachine, HAFailoverRestarted)
+	if err != nil {
+		return nil, err
+	}
+	return scanHAFailovers(rows)
+}
+
+func MarkHAFailoverCleaned(ctx context.Context, id int) error {
+	_, err := DB.ExecContext(ctx, `UPDATE ha_failovers SET state = ?, cleaned_at = ? WHERE id = ?;`,
+		HAFailoverCleaned, time.Now().UTC().Format(time.RFC3339), id)
+	return err
+}
+
+// CountHARestarts counts the successful failovers of a VM since a time.
+func CountHARestarts(ctx context.Context, vmName string, since time.Time) (int, error) {
+	var count int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM ha_failovers
+	WHERE vm_name = ? AND state != ? AND created_at >= ?;`,
+		vmName, HAFailoverFailed, since.UTC().Format(time.RFC3339)).Scan(&count)
+	return count, err
+}
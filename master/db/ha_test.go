@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestHAPoliciesAndFailovers(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateHATables(ctx); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+
+	if err := SetHAPolicy(ctx, HAPolicy{VmName: "web1", Enabled: true, Priority: 1}); err != nil {
+		t.Fatalf("set policy: %v", err)
+	}
+	if err := SetHAPolicy(ctx, HAPolicy{VmName: "db1", Enabled: true, Priority: 10, PreferredHosts: []string{"node2"}, MaxRestarts: 2}); err != nil {
+		t.Fatalf("set policy: %v", err)
+	}
+	policies, err := GetHAPolicies(ctx)
+	if err != nil {
+		t.Fatalf("get policies: %v", err)
+	}
+	if len(policies) != 2 || policies[0].VmName != "db1" || policies[0].PreferredHosts[0] != "node2" {
+		t.Fatalf("expected db1 first with its preferred host, got %+v", policies)
+	}
+
+	if err := SetHADomain(ctx, "db1", "node1", "<domain/>"); err != nil {
+		t.Fatalf("set domain: %v", err)
+	}
+	if err := SetHADomain(ctx, "db1", "node3", "<domain><name>db1</name></domain>"); err != nil {
+		t.Fatalf("update domain: %v", err)
+	}
+	domains, err := GetHADomains(ctx, "node3")
+	if err != nil || len(domains) != 1 || domains[0].XML != "<domain><name>db1</name></domain>" {
+		t.Fatalf("expected the updated domain on node3, got %+v (%v)", domains, err)
+	}
+
+	if err := AddHAFailover(ctx, HAFailover{VmName: "db1", FromMachine: "node1", ToMachine: "node2", State: HAFailoverRestarted}); err != nil {
+		t.Fatalf("add failover: %v", err)
+	}
+	if err := AddHAFailover(ctx, HAFailover{VmName: "db1", FromMachine: "node1", State: HAFailoverFailed, Message: "no host"}); err != nil {
+		t.Fatalf("add failover: %v", err)
+	}
+	count, err := CountHARestarts(ctx, "db1", time.Now().Add(-time.Hour))
+	if err != nil || count != 1 {
+		t.Fatalf("expected one restart, got %d (%v)", count, err)
+	}
+
+	pending, err := GetUncleanedHAFailovers(ctx, "node1")
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("expected one failover to clean, got %+v (%v)", pending, err)
+	}
+	if err := MarkHAFailoverCleaned(ctx, pending[0].Id); err != nil {
+		t.Fatalf("mark cleaned: %v", err)
+	}
+	if pending, _ := GetUncleanedHAFailovers(ctx, "node1"); len(pending) != 0 {
+		t.Fatalf("expected nothing left to clean, got %+v", pending)
+	}
+	if count, _ := CountHARestarts(ctx, "db1", time.Now().Add(-time.Hour)); count != 1 {
+		t.Fatalf("cleaned failovers still count as restarts, got %d", count)
+	}
+
+	history, err := GetHAFailovers(ctx, "db1", 10)
+	if err != nil || len(history) != 2 || history[0].State != HAFailoverFailed {
+		t.Fatalf("expected newest failover first, got %+v (%v)", history, err)
+	}
+
+	if err := DeleteHAPolicy(ctx, "db1"); err != nil {
+		t.Fatalf("delete policy: %v", err)
+	}
+	if policy, err := GetHAPolicy(ctx, "db1"); err != nil || policy != nil {
+		t.Fatalf("expected policy to be gone, got %+v (%v)", policy, err)
+	}
+	if domains, _ := GetHADomains(ctx, ""); len(domains) != 0 {
+		t.Fatalf("expected stored domain to be gone, got %+v", domains)
+	}
+}
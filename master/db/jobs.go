@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+const (
+	JobStateQueued      = "queued"
+	JobStateRunning     = "running"
+	JobStateSucceeded   = "succeeded"
+	JobStateFailed      = "failed"
+	JobStateCanceled    = "canceled"
+	JobStateInterrupted = "interrupted" // master stopped while the job was queued or running
+)
+
+type Job struct {
+	Id         int     `json:"id"`
+	Type       string  `json:"type"`
+	Target     string  `json:"target"`
+	State      string  `json:"state"`
+	Progress   float64 `json:"progress"`
+	Error      string  `json:"error"`
+	CreatedAt  string  `json:"created_at"`
+	StartedAt  string  `json:"started_at"`
+	FinishedAt string  `json:"finished_at"`
+}
+
+type JobLog struct {
+	Time    string `json:"time"`
+	Message string `json:"message"`
+}
+
+type JobFilter struct {
+	Type   string
+	Target string
+	State  string
+	Limit  int
+}
+
+const jobColumns = `id, type, target, state, progress, error, created_at, started_at, finished_at`
+
+func CreateJobsTable(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL,
+		target TEXT NOT NULL DEFAULT '',
+		state TEXT NOT NULL,
+		progress REAL NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		started_at TEXT NOT NULL DEFAULT '',
+		finished_at TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs(state);
+	CREATE TABLE IF NOT EXISTS job_logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		job_id INTEGER NOT NULL,
+		time TEXT NOT NULL,
+		message TEXT NOT NULL,
+		FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_job_logs_job_id ON job_logs(job_id);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+func InsertJob(ctx context.Context, job *Job) error {
+	job.State = JobStateQueued
+	job.CreatedAt = time.Now().Format(time.RFC3339)
+	res, err := DB.ExecContext(ctx, `INSERT INTO jobs (type, target, state, created_at) VALUES (?, ?, ?, ?);`,
+		job.Type, job.Target, job.State, job.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	job.Id = int(id)
+	return nil
+}
+
+func MarkJobRunning(ctx context.Context, id int) error {
+	_, err := DB.ExecContext(ctx, `UPDATE jobs SET state = ?, started_at = ? WHERE id = ?;`,
+		JobStateRunning, time.Now().Format(time.RFC3339), id)
+	return err
+}
+
+func FinishJob(ctx context.Context, id int, state, errMsg string) error {
+	query := `UPDATE jobs SET state = ?, error = ?, finished_at = ? WHERE id = ?;`
+	if state == JobStateSucceeded {
+		query = `UPDATE jobs SET state = ?, error = ?, finished_at = ?, progress = 100 WHERE id = ?;`
+	}
+	_, err := DB.ExecContext(ctx, query, state, errMsg, time.Now().Format(time.RFC3339), id)
+	return err
+}
+
+func UpdateJobProgress(ctx context.Context, id int, progress float64) error {
+	_, err := DB.ExecContext(ctx, `UPDATE jobs SET progress = ? WHERE id = ?;`, progress, id)
+	return err
+}
+
+func AppendJobLog(ctx context.Context, id int, message string) error {
+	_, err := DB.ExecContext(ctx, `INSERT INTO job_logs (job_id, time, message) VALUES (?, ?, ?);`,
+		id, time.Now().Format(time.RFC3339), message)
+	return err
+}
+
+// MarkInterruptedJobs closes jobs left queued or running by a previous master
+// process, their goroutines died with it.
+func MarkInterruptedJobs(ctx context.Context) (int64, error) {
+	res, err := DB.ExecContext(ctx, `
+	UPDATE jobs SET state = ?, error = 'master restarted before the job finished', finished_at = ?
+	WHERE state IN (?, ?);`,
+		JobStateInterrupted, time.Now().Format(time.RFC3339), JobStateQueued, JobStateRunning)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func GetJobByID(ctx context.Context, id int) (*Job, error) {
+	row := DB.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?;`, id)
+	job, err := scanJob(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func GetJobs(ctx context.Context, filter JobFilter) ([]Job, error) {
+	var where []string
+	var args []any
+	if filter.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, filter.Type)
+	}
+	if filter.Target != "" {
+		where = append(where, "target = ?")
+		args = append(args, filter.Target)
+	}
+	if filter.State != "" {
+		where = append(where, "state = ?")
+		args = append(args, filter.State)
+	}
+
+	query := `SELECT ` + jobColumns + ` FROM jobs`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func GetJobLogs(ctx context.Context, id int) ([]JobLog, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT time, message FROM job_logs WHERE job_id = ? ORDER BY id;`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []JobLog
+	for rows.Next() {
+		var l JobLog
+		if err := rows.Scan(&l.Time, &l.Message); err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+// DeleteJobsFinishedBefore drops old finished jobs and their logs.
+func DeleteJobsFinishedBefore(ctx context.Context, before time.Time) error {
+	cutoff := before.Format(time.RFC3339)
+	if _, err := DB.ExecContext(ctx, `
+	DELETE FROM job_logs WHERE job_id IN (SELECT id FROM jobs WHERE finished_at != '' AND finished_at < ?);`, cutoff); err != nil {
+		return err
+	}
+	_, err := DB.ExecContext(ctx, `DELETE FROM jobs WHERE finished_at != '' AND finished_at < ?;`, cutoff)
+	return err
+}
+
+func scanJob(scanner rowScanner) (Job, error) {
+	var job Job
+	err := scanner.Scan(&job.Id, &job.Type, &job.Target, &job.State, &job.Progress, &job.Error,
+		&job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+	return job, err
+}
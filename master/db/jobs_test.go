@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestJobsLifecycle(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateJobsTable(ctx); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	done := &Job{Type: "backup", Target: "web"}
+	if err := InsertJob(ctx, done); err != nil {
+		t.Fatalf("insert job: %v", err)
+	}
+	if err := MarkJobRunning(ctx, done.Id); err != nil {
+		t.Fatalf("mark running: %v", err)
+	}
+	if err := UpdateJobProgress(ctx, done.Id, 42.5); err != nil {
+		t.Fatalf("update progress: %v", err)
+	}
+	if err := AppendJobLog(ctx, done.Id, "copying"); err != nil {
+		t.Fatalf("append log: %v", err)
+	}
+	if err := FinishJob(ctx, done.Id, JobStateSucceeded, ""); err != nil {
+		t.Fatalf("finish job: %v", err)
+	}
+
+	stuck := &Job{Type: "clone_vm", Target: "lab1"}
+	if err := InsertJob(ctx, stuck); err != nil {
+		t.Fatalf("insert job: %v", err)
+	}
+	if err := MarkJobRunning(ctx, stuck.Id); err != nil {
+		t.Fatalf("mark running: %v", err)
+	}
+
+	n, err := MarkInterruptedJobs(ctx)
+	if err != nil {
+		t.Fatalf("mark interrupted: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 interrupted job, got %d", n)
+	}
+
+	got, err := GetJobByID(ctx, done.Id)
+	if err != nil || got == nil {
+		t.Fatalf("get job: %+v, %v", got, err)
+	}
+	if got.State != JobStateSucceeded || got.Progress != 100 || got.StartedAt == "" || got.FinishedAt == "" {
+		t.Fatalf("unexpected finished job %+v", got)
+	}
+
+	got, err = GetJobByID(ctx, stuck.Id)
+	if err != nil || got == nil {
+		t.Fatalf("get job: %+v, %v", got, err)
+	}
+	if got.State != JobStateInterrupted || got.Error == "" {
+		t.Fatalf("unexpected interrupted job %+v", got)
+	}
+
+	logs, err := GetJobLogs(ctx, done.Id)
+	if err != nil {
+		t.Fatalf("get logs: %v", err)
+	}
+	if len(logs) != 1 || logs[0].Message != "copying" {
+		t.Fatalf("unexpected logs %+v", logs)
+	}
+
+	jobs, err := GetJobs(ctx, JobFilter{Type: "backup"})
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].Id != done.Id {
+		t.Fatalf("unexpected filtered jobs %+v", jobs)
+	}
+
+	if err := DeleteJobsFinishedBefore(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("delete old jobs: %v", err)
+	}
+	jobs, err = GetJobs(ctx, JobFilter{})
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(jobs) != 0 {
+		t.Fatalf("expected finished jobs to be pruned, got %+v", jobs)
+	}
+	if logs, _ := GetJobLogs(ctx, done.Id); len(logs) != 0 {
+		t.Fatalf("expected logs to be pruned, got %+v", logs)
+	}
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// a host is evacuating while its VMs are moved away and in maintenance once
+// that finished, no VM is placed on it in either state
+const (
+	MaintenanceEvacuating = "evacuating"
+	MaintenanceActive     = "active"
+)
+
+// HostMaintenance is a slave in maintenance mode, JobId is the evacuation job.
+type HostMaintenance struct {
+	MachineName string `json:"machine_name"`
+	State       string `json:"state"`
+	Reason      string `json:"reason"`
+	JobId       int    `json:"job_id"`
+	StartedAt   string `json:"started_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// states of a VM moved away by an evacuation, migrated ones can be returned
+// to their host once it left maintenance
+const (
+	MaintenanceVMPending   = "pending"
+	MaintenanceVMMigrating = "migrating"
+	MaintenanceVMMigrated  = "migrated"
+	MaintenanceVMFailed    = "failed"
+	MaintenanceVMReturning = "returning"
+	MaintenanceVMReturned  = "returned"
+)
+
+// MaintenanceVM is a VM evacuated from MachineName, Method is live or cold.
+type MaintenanceVM struct {
+	Id            int    `json:"id"`
+	MachineName   string `json:"machine_name"`
+	VmName        string `json:"vm_name"`
+	TargetMachine string `json:"target_machine"`
+	Method        string `json:"method"`
+	State         string `json:"state"`
+	Message       string `json:"message"`
+	UpdatedAt     string `json:"updated_at"`
+}
+
+func CreateMaintenanceTables(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS host_maintenance (
+		machine_name TEXT PRIMARY KEY,
+		state TEXT NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		job_id INTEGER NOT NULL DEFAULT 0,
+		started_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS maintenance_vms (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		machine_name TEXT NOT NULL,
+		vm_name TEXT NOT NULL,
+		target_machine TEXT NOT NULL DEFAULT '',
+		method TEXT NOT NULL DEFAULT '',
+		state TEXT NOT NULL,
+		message TEXT NOT NULL DEFAULT '',
+		updated_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_maintenance_vms_machine ON maintenance_vms(machine_name);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+// StartHostMaintenance puts a host in maintenance, it fails when the host
+// already is.
+func StartHostMaintenance(ctx context.Context, machineName, reason string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := DB.ExecContext(ctx, `
+	INSERT INTO host_maintenance (machine_name, state, reason, started_at, updated_at)
+	VALUES (?, ?, ?, ?, ?);`, machineName, MaintenanceEvacuating, reason, now, now)
+	return err
+}
+
+func SetHostMaintenanceState(ctx context.Context, machineName, state string, jobID int) error {
+	_, err := DB.ExecContext(ctx, `UPDATE host_maintenance SET state = ?, job_id = ?, updated_at = ?
+	WHERE machine_name = ?;`, state, jobID, time.Now().UTC().Format(time.RFC3339), machineName)
+	return err
+}
+
+func SetHostMaintenanceJob(ctx context.Context, machineName string, jobID int) error {
+	_, err := DB.ExecContext(ctx, `UPDATE host_maintenance SET job_id = ? WHERE machine_name = ?;`, jobID, machineName)
+	return err
+}
+
+func scanHostMaintenance(row rowScanner) (HostMaintenance, error) {
+	var m HostMaintenance
+	err := row.Scan(&m.MachineName, &m.State, &m.Reason, &m.JobId, &m.StartedAt, &m.UpdatedAt)
+	return m, err
+}
+
+func GetHostMaintenance(ctx context.Context, machineName string) (*HostMaintenance, error) {
+	row := DB.QueryRowContext(ctx, `SELECT machine_name, state, reason, job_id, started_at, updated_at
+	FROM host_maintenance WHERE machine_name = ?;`, machineName)
+	m, err := scanHostMaintenance(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func GetHostMaintenances(ctx context.Context) ([]HostMaintenance, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT machine_name, state, reason, job_id, started_at, updated_at
+	FROM host_maintenance ORDER BY machine_name;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hosts []HostMaintenance
+	for rows.Next() {
+		m, err := scanHostMaintenance(rows)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, m)
+	}
+	return hosts, rows.Err()
+}
+
+func DeleteHostMaintenance(ctx context.Context, machineName string) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM host_maintenance WHERE machine_name = ?;`, machineName)
+	return err
+}
+
+// ClearMaintenanceVMs forgets the VMs of a previous evacuation of a host.
+func ClearMaintenanceVMs(ctx context.Context, machineName string) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM maintenance_vms WHERE machine_name = ?;`, machineName)
+	return err
+}
+
+func AddMaintenanceVM(ctx context.Context, vm MaintenanceVM) (*MaintenanceVM, error) {
+	vm.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	res, err := DB.ExecContext(ctx, `
+	INSERT INTO maintenance_vms (machine_name, vm_name, target_machine, method, state, message, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?);`,
+		vm.MachineName, vm.VmName, vm.TargetMachine, vm.Method, vm.State, vm.Message, vm.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	vm.Id = int(id)
+	return &vm, nil
+}
+
+func UpdateMaintenanceVM(ctx context.Context, vm MaintenanceVM) error {
+	_, err := DB.ExecContext(ctx, `UPDATE maintenance_vms SET target_machine = ?, method = ?, state = ?,
+		message = ?, updated_at = ? WHERE id = ?;`,
+		vm.TargetMachine, vm.Method, vm.State, vm.Message, time.Now().UTC().Format(time.RFC3339), vm.Id)
+	return err
+}
+
+func GetMaintenanceVMs(ctx context.Context, machineName string) ([]MaintenanceVM, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT id, machine_name, vm_name, target_machine, method, state, message,
+	updated_at FROM maintenance_vms WHERE machine_name = ? ORDER BY id;`, machineName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vms []MaintenanceVM
+	for rows.Next() {
+		var vm MaintenanceVM
+		if err := rows.Scan(&vm.Id, &vm.MachineName, &vm.VmName, &vm.TargetMachine, &vm.Method, &vm.State,
+			&vm.Message, &vm.UpdatedAt); err != nil {
+			return nil, err
+		}
+		vms = append(vms, vm)
+	}
+	return vms, rows.Err()
+}
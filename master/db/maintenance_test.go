@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestHostMaintenanceLifecycle(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateMaintenanceTables(ctx); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+
+	if err := StartHostMaintenance(ctx, "node1", "kernel update"); err != nil {
+		t.Fatalf("start maintenance: %v", err)
+	}
+	if err := StartHostMaintenance(ctx, "node1", "again"); err == nil {
+		t.Fatalf("expected a host to only enter maintenance once")
+	}
+	if err := SetHostMaintenanceState(ctx, "node1", MaintenanceActive, 7); err != nil {
+		t.Fatalf("set state: %v", err)
+	}
+	m, err := GetHostMaintenance(ctx, "node1")
+	if err != nil || m == nil || m.State != MaintenanceActive || m.JobId != 7 || m.Reason != "kernel update" {
+		t.Fatalf("unexpected maintenance %+v (%v)", m, err)
+	}
+
+	vm, err := AddMaintenanceVM(ctx, MaintenanceVM{MachineName: "node1", VmName: "web1", State: MaintenanceVMPending})
+	if err != nil {
+		t.Fatalf("add vm: %v", err)
+	}
+	vm.State = MaintenanceVMMigrated
+	vm.TargetMachine = "node2"
+	vm.Method = "live"
+	if err := UpdateMaintenanceVM(ctx, *vm); err != nil {
+		t.Fatalf("update vm: %v", err)
+	}
+	vms, err := GetMaintenanceVMs(ctx, "node1")
+	if err != nil || len(vms) != 1 || vms[0].State != MaintenanceVMMigrated || vms[0].TargetMachine != "node2" {
+		t.Fatalf("unexpected vms %+v (%v)", vms, err)
+	}
+
+	if err := DeleteHostMaintenance(ctx, "node1"); err != nil {
+		t.Fatalf("delete maintenance: %v", err)
+	}
+	if m, _ := GetHostMaintenance(ctx, "node1"); m != nil {
+		t.Fatalf("expected host to leave maintenance, got %+v", m)
+	}
+	if vms, _ := GetMaintenanceVMs(ctx, "node1"); len(vms) != 1 {
+		t.Fatalf("evacuated vms are kept after exit so they can be returned, got %+v", vms)
+	}
+	if err := ClearMaintenanceVMs(ctx, "node1"); err != nil {
+		t.Fatalf("clear vms: %v", err)
+	}
+	if vms, _ := GetMaintenanceVMs(ctx, "node1"); len(vms) != 0 {
+		t.Fatalf("expected vms to be cleared, got %+v", vms)
+	}
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// a slave is pending until an admin approves it, only approved slaves get their
+// connection set up by the master
+const (
+	NodeStatePending  = "pending"
+	NodeStateApproved = "approved"
+	NodeStateRejected = "rejected"
+	NodeStateRetired  = "retired"
+)
+
+type Node struct {
+	Id          int    `json:"id"`
+	MachineName string `json:"machine_name"`
+	DisplayName string `json:"display_name"`
+	State       string `json:"state"`
+	Addr        string `json:"addr"`
+	Fingerprint string `json:"fingerprint"`
+	Hostname    string `json:"hostname"`
+	OS          string `json:"os"`
+	Kernel      string `json:"kernel"`
+	CPUModel    string `json:"cpu_model"`
+	CPUCores    int    `json:"cpu_cores"`
+	CPUThreads  int    `json:"cpu_threads"`
+	MemoryMB    int64  `json:"memory_mb"`
+	FirstSeen   string `json:"first_seen"`
+	LastSeen    string `json:"last_seen"`
+	DecidedAt   string `json:"decided_at"`
+}
+
+const nodeColumns = `id, machine_name, display_name, state, addr, fingerprint, hostname, os, kernel,
+	cpu_model, cpu_cores, cpu_threads, memory_mb, first_seen, last_seen, decided_at`
+
+func CreateNodesTable(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS nodes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		machine_name TEXT NOT NULL UNIQUE,
+		display_name TEXT NOT NULL DEFAULT '',
+		state TEXT NOT NULL,
+		addr TEXT NOT NULL DEFAULT '',
+		fingerprint TEXT NOT NULL DEFAULT '',
+		hostname TEXT NOT NULL DEFAULT '',
+		os TEXT NOT NULL DEFAULT '',
+		kernel TEXT NOT NULL DEFAULT '',
+		cpu_model TEXT NOT NULL DEFAULT '',
+		cpu_cores INTEGER NOT NULL DEFAULT 0,
+		cpu_threads INTEGER NOT NULL DEFAULT 0,
+		memory_mb INTEGER NOT NULL DEFAULT 0,
+		first_seen TEXT NOT NULL,
+		last_seen TEXT NOT NULL,
+		decided_at TEXT NOT NULL DEFAULT ''
+	);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+// RecordNodeSeen stores what a slave reported when it connected. Unknown
+// machines are added as pending, created tells the caller this happened.
+func RecordNodeSeen(ctx context.Context, seen Node) (node *Node, created bool, err error) {
+	now := time.Now().Format(time.RFC3339)
+	res, err := DB.ExecContext(ctx, `
+	UPDATE nodes SET addr = ?, fingerprint = ?, hostname = ?, os = ?, kernel = ?, cpu_model = ?,
+		cpu_cores = ?, cpu_threads = ?, memory_mb = ?, last_seen = ?
+	WHERE machine_name = ?;`,
+		seen.Addr, seen.Fingerprint, seen.Hostname, seen.OS, seen.Kernel, seen.CPUModel,
+		seen.CPUCores, seen.CPUThreads, seen.MemoryMB, now, seen.MachineName)
+	if err != nil {
+		return nil, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	if n == 0 {
+		_, err = DB.ExecContext(ctx, `
+		INSERT INTO nodes (machine_name, state, addr, fingerprint, hostname, os, kernel, cpu_model,
+			cpu_cores, cpu_threads, memory_mb, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+			seen.MachineName, NodeStatePending, seen.Addr, seen.Fingerprint, seen.Hostname, seen.OS, seen.Kernel,
+			seen.CPUModel, seen.CPUCores, seen.CPUThreads, seen.MemoryMB, now, now)
+		if err != nil {
+			return nil, false, err
+		}
+		created = true
+	}
+	node, err = GetNodeByName(ctx, seen.MachineName)
+	return node, created, err
+}
+
+func GetNodes(ctx context.Context, state string) ([]Node, error) {
+	query := `SELECT ` + nodeColumns + ` FROM nodes`
+	var args []any
+	if state != "" {
+		query += ` WHERE state = ?`
+		args = append(args, state)
+	}
+	query += ` ORDER BY machine_name;`
+
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []Node
+	for rows.Next() {
+		node, err := scanNode(rows)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, rows.Err()
+}
+
+func GetNodeByName(ctx context.Context, machineName string) (*Node, error) {
+	row := DB.QueryRowContext(ctx, `SELECT `+nodeColumns+` FROM nodes WHERE machine_name = ?;`, machineName)
+	node, err := scanNode(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &node, nil
+}
+
+func SetNodeState(ctx context.Context, machineName, state string) error {
+	_, err := DB.ExecContext(ctx, `UPDATE nodes SET state = ?, decided_at = ? WHERE machine_name = ?;`,
+		state, time.Now().Format(time.RFC3339), machineName)
+	return err
+}
+
+func SetNodeDisplayName(ctx context.Context, machineName, displayName string) error {
+	_, err := DB.ExecContext(ctx, `UPDATE nodes SET display_name = ? WHERE machine_name = ?;`, displayName, machineName)
+	return err
+}
+
+func DeleteNode(ctx context.Context, machineName string) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM nodes WHERE machine_name = ?;`, machineName)
+	return err
+}
+
+func CountNodesByState(ctx context.Context, state string) (int, error) {
+	var n int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM nodes WHERE state = ?;`, state).Scan(&n)
+	return n, err
+}
+
+func scanNode(scanner rowScanner) (Node, error) {
+	var node Node
+	err := scanner.Scan(&node.Id, &node.MachineName, &node.DisplayName, &node.State, &node.Addr, &node.Fingerprint,
+		&node.Hostname, &node.OS, &node.Kernel, &node.CPUModel, &node.CPUCores, &node.CPUThreads, &node.MemoryMB,
+		&node.FirstSeen, &node.LastSeen, &node.DecidedAt)
+	return node, err
+}
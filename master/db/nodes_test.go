@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestNodesLifecycle(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateNodesTable(ctx); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	node, created, err := RecordNodeSeen(ctx, Node{MachineName: "node1", Addr: "10.0.0.5", Fingerprint: "sha256:aa", CPUCores: 8})
+	if err != nil {
+		t.Fatalf("record node: %v", err)
+	}
+	if !created || node.State != NodeStatePending || node.CPUCores != 8 {
+		t.Fatalf("expected a new pending node, got created=%v %+v", created, node)
+	}
+
+	if err := SetNodeState(ctx, "node1", NodeStateApproved); err != nil {
+		t.Fatalf("approve node: %v", err)
+	}
+	if err := SetNodeDisplayName(ctx, "node1", "rack 1"); err != nil {
+		t.Fatalf("rename node: %v", err)
+	}
+
+	node, created, err = RecordNodeSeen(ctx, Node{MachineName: "node1", Addr: "10.0.0.6", Fingerprint: "sha256:bb"})
+	if err != nil {
+		t.Fatalf("record node again: %v", err)
+	}
+	if created || node.State != NodeStateApproved || node.Addr != "10.0.0.6" || node.DisplayName != "rack 1" || node.DecidedAt == "" {
+		t.Fatalf("expected the approved node to be updated, got created=%v %+v", created, node)
+	}
+
+	if _, _, err := RecordNodeSeen(ctx, Node{MachineName: "node2"}); err != nil {
+		t.Fatalf("record node2: %v", err)
+	}
+	pending, err := GetNodes(ctx, NodeStatePending)
+	if err != nil {
+		t.Fatalf("get pending nodes: %v", err)
+	}
+	if len(pending) != 1 || pending[0].MachineName != "node2" {
+		t.Fatalf("unexpected pending nodes: %+v", pending)
+	}
+	count, err := CountNodesByState(ctx, NodeStatePending)
+	if err != nil || count != 1 {
+		t.Fatalf("expected 1 pending node, got %d, %v", count, err)
+	}
+
+	if err := DeleteNode(ctx, "node2"); err != nil {
+		t.Fatalf("delete node: %v", err)
+	}
+	missing, err := GetNodeByName(ctx, "node2")
+	if err != nil || missing != nil {
+		t.Fatalf("expected node2 to be gone, got %+v, %v", missing, err)
+	}
+}
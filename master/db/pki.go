@@ -0,0 +1,260 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// join tokens are stored as a sha256 of the secret, the secret itself is only
+// shown once when the token is created
+type JoinToken struct {
+	Id          int    `json:"id"`
+	MachineName string `json:"machine_name"` // empty means any machine name
+	// lets an unbound token enroll a name that still has active certificates
+	ReplaceExisting bool   `json:"replace_existing"`
+	CreatedAt       string `json:"created_at"`
+	ExpiresAt       string `json:"expires_at"`
+	UsedAt          string `json:"used_at"`
+	UsedBy          string `json:"used_by"`
+}
+
+type NodeCertificate struct {
+	Serial       string `json:"serial"`
+	MachineName  string `json:"machine_name"`
+	Fingerprint  string `json:"fingerprint"`
+	NotBefore    string `json:"not_before"`
+	NotAfter     string `json:"not_after"`
+	IssuedAt     string `json:"issued_at"`
+	RevokedAt    string `json:"revoked_at"`
+	RevokeReason string `json:"revoke_reason"`
+}
+
+const nodeCertificateColumns = `serial, machine_name, fingerprint, not_before, not_after, issued_at, revoked_at, revoke_reason`
+
+func CreatePKITables(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS join_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		token_hash TEXT NOT NULL UNIQUE,
+		machine_name TEXT NOT NULL DEFAULT '',
+		replace_existing BOOLEAN NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL,
+		used_at TEXT NOT NULL DEFAULT '',
+		used_by TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE IF NOT EXISTS node_certificates (
+		serial TEXT PRIMARY KEY,
+		machine_name TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		not_before TEXT NOT NULL,
+		not_after TEXT NOT NULL,
+		issued_at TEXT NOT NULL,
+		revoked_at TEXT NOT NULL DEFAULT '',
+		revoke_reason TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_node_certificates_machine ON node_certificates(machine_name);
+	`
+	if _, err := DB.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	// Ensure replace_existing exists for older installations.
+	if _, err := DB.ExecContext(ctx, `ALTER TABLE join_tokens ADD COLUMN replace_existing BOOLEAN NOT NULL DEFAULT 0`); err != nil {
+		if !strings.Contains(strings.ToLower(err.Error()), "duplicate column name") {
+			return err
+		}
+	}
+	return nil
+}
+
+func AddJoinToken(ctx context.Context, tokenHash, machineName string, replaceExisting bool, expiresAt time.Time) (*JoinToken, error) {
+	token := &JoinToken{
+		MachineName:     machineName,
+		ReplaceExisting: replaceExisting,
+		CreatedAt:       time.Now().UTC().Format(time.RFC3339),
+		ExpiresAt:       expiresAt.UTC().Format(time.RFC3339),
+	}
+	res, err := DB.ExecContext(ctx, `
+	INSERT INTO join_tokens (token_hash, machine_name, replace_existing, created_at, expires_at)
+	VALUES (?, ?, ?, ?, ?);`, tokenHash, token.MachineName, token.ReplaceExisting, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	token.Id = int(id)
+	return token, nil
+}
+
+func GetJoinTokens(ctx context.Context) ([]JoinToken, error) {
+	rows, err := DB.QueryContext(ctx, `
+	SELECT id, machine_name, replace_existing, created_at, expires_at, used_at, used_by
+	FROM join_tokens
+	ORDER BY id DESC;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []JoinToken
+	for rows.Next() {
+		var t JoinToken
+		if err := rows.Scan(&t.Id, &t.MachineName, &t.ReplaceExisting, &t.CreatedAt, &t.ExpiresAt, &t.UsedAt, &t.UsedBy); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func DeleteJoinToken(ctx context.Context, id int) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM join_tokens WHERE id = ?;`, id)
+	return err
+}
+
+// ConsumeJoinToken marks an unused, unexpired token as used by machineName. The
+// update is the check so two slaves racing with the same token can't both win.
+// An unbound token can't take over a name that still has active certificates
+// unless it was created with replace_existing.
+func ConsumeJoinToken(ctx context.Context, tokenHash, machineName string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	res, err := DB.ExecContext(ctx, `
+	UPDATE join_tokens SET used_at = ?, used_by = ?
+	WHERE token_hash = ? AND used_at = '' AND expires_at > ? AND (machine_name = '' OR machine_name = ?)
+	AND (machine_name != '' OR replace_existing = 1 OR NOT EXISTS (
+		SELECT 1 FROM node_certificates WHERE machine_name = ? AND revoked_at = '' AND not_after > ?));`,
+		now, machineName, tokenHash, now, machineName, machineName, now)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	var unbound int
+	err = DB.QueryRowContext(ctx, `
+	SELECT 1 FROM join_tokens WHERE token_hash = ? AND used_at = '' AND expires_at > ? AND machine_name = '';`,
+		tokenHash, now).Scan(&unbound)
+	if err == nil {
+		return fmt.Errorf("machine %s is already enrolled, use a token bound to it or created with replace_existing", machineName)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	return fmt.Errorf("join token is invalid, expired, already used or bound to another machine")
+}
+
+func AddNodeCertificate(ctx context.Context, cert NodeCertificate) error {
+	if cert.IssuedAt == "" {
+		cert.IssuedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	_, err := DB.ExecContext(ctx, `
+	INSERT INTO node_certificates (serial, machine_name, fingerprint, not_before, not_after, issued_at)
+	VALUES (?, ?, ?, ?, ?, ?);`,
+		cert.Serial, cert.MachineName, cert.Fingerprint, cert.NotBefore, cert.NotAfter, cert.IssuedAt)
+	return err
+}
+
+func GetNodeCertificates(ctx context.Context) ([]NodeCertificate, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+nodeCertificateColumns+` FROM node_certificates ORDER BY machine_name, issued_at DESC;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var certs []NodeCertificate
+	for rows.Next() {
+		cert, err := scanNodeCertificate(rows)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, rows.Err()
+}
+
+func GetNodeCertificateBySerial(ctx context.Context, serial string) (*NodeCertificate, error) {
+	row := DB.QueryRowContext(ctx, `SELECT `+nodeCertificateColumns+` FROM node_certificates WHERE serial = ?;`, serial)
+	cert, err := scanNodeCertificate(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func RevokeNodeCertificate(ctx context.Context, serial, reason string) error {
+	_, err := DB.ExecContext(ctx, `
+	UPDATE node_certificates SET revoked_at = ?, revoke_reason = ?
+	WHERE serial = ? AND revoked_at = '';`,
+		time.Now().UTC().Format(time.RFC3339), reason, serial)
+	return err
+}
+
+// RevokeMachineCertificates revokes every certificate of machineName except
+// keepSerial and returns the serials it revoked.
+func RevokeMachineCertificates(ctx context.Context, machineName, keepSerial, reason string) ([]string, error) {
+	rows, err := DB.QueryContext(ctx, `
+	SELECT serial FROM node_certificates
+	WHERE machine_name = ? AND serial != ? AND revoked_at = '';`, machineName, keepSerial)
+	if err != nil {
+		return nil, err
+	}
+	var serials []string
+	for rows.Next() {
+		var serial string
+		if err := rows.Scan(&serial); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		serials = append(serials, serial)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, serial := range serials {
+		if err := RevokeNodeCertificate(ctx, serial, reason); err != nil {
+			return nil, err
+		}
+	}
+	return serials, nil
+}
+
+func GetRevokedCertificateSerials(ctx context.Context) ([]string, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT serial FROM node_certificates WHERE revoked_at != '';`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var serials []string
+	for rows.Next() {
+		var serial string
+		if err := rows.Scan(&serial); err != nil {
+			return nil, err
+		}
+		serials = append(serials, serial)
+	}
+	return serials, rows.Err()
+}
+
+func scanNodeCertificate(scanner rowScanner) (NodeCertificate, error) {
+	var cert NodeCertificate
+	err := scanner.Scan(&cert.Serial, &cert.MachineName, &cert.Fingerprint, &cert.NotBefore, &cert.NotAfter,
+		&cert.IssuedAt, &cert.RevokedAt, &cert.RevokeReason)
+	return cert, err
+}
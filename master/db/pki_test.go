@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJoinTokensAndNodeCertificates(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreatePKITables(ctx); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+
+	if _, err := AddJoinToken(ctx, "any", "", false, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("add token: %v", err)
+	}
+	if _, err := AddJoinToken(ctx, "bound", "node1", false, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("add bound token: %v", err)
+	}
+	if _, err := AddJoinToken(ctx, "old", "", false, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("add expired token: %v", err)
+	}
+
+	if err := ConsumeJoinToken(ctx, "any", "node2"); err != nil {
+		t.Fatalf("consume token: %v", err)
+	}
+	if err := ConsumeJoinToken(ctx, "any", "node3"); err == nil {
+		t.Fatalf("expected a used token to be rejected")
+	}
+	if err := ConsumeJoinToken(ctx, "bound", "node2"); err == nil {
+		t.Fatalf("expected a token bound to node1 to be rejected for node2")
+	}
+	if err := ConsumeJoinToken(ctx, "bound", "node1"); err != nil {
+		t.Fatalf("consume bound token: %v", err)
+	}
+	if err := ConsumeJoinToken(ctx, "old", "node4"); err == nil {
+		t.Fatalf("expected an expired token to be rejected")
+	}
+	if err := ConsumeJoinToken(ctx, "missing", "node4"); err == nil {
+		t.Fatalf("expected an unknown token to be rejected")
+	}
+
+	tokens, err := GetJoinTokens(ctx)
+	if err != nil {
+		t.Fatalf("get tokens: %v", err)
+	}
+	if len(tokens) != 3 || tokens[2].UsedBy != "node2" {
+		t.Fatalf("unexpected tokens: %+v", tokens)
+	}
+
+	for _, serial := range []string{"01", "02", "03"} {
+		err := AddNodeCertificate(ctx, NodeCertificate{Serial: serial, MachineName: "node1", Fingerprint: "fp" + serial,
+			NotBefore: "2026-01-01T00:00:00Z", NotAfter: "2099-01-01T00:00:00Z"})
+		if err != nil {
+			t.Fatalf("add certificate %s: %v", serial, err)
+		}
+	}
+
+	revoked, err := RevokeMachineCertificates(ctx, "node1", "03", "superseded")
+	if err != nil {
+		t.Fatalf("revoke machine certificates: %v", err)
+	}
+	if len(revoked) != 2 {
+		t.Fatalf("expected 2 revoked serials, got %v", revoked)
+	}
+
+	cert, err := GetNodeCertificateBySerial(ctx, "01")
+	if err != nil {
+		t.Fatalf("get certificate: %v", err)
+	}
+	if cert == nil || cert.RevokedAt == "" || cert.RevokeReason != "superseded" {
+		t.Fatalf("expected certificate 01 to be revoked, got %+v", cert)
+	}
+
+	serials, err := GetRevokedCertificateSerials(ctx)
+	if err != nil {
+		t.Fatalf("get revoked serials: %v", err)
+	}
+	if len(serials) != 2 {
+		t.Fatalf("expected 2 revoked serials, got %v", serials)
+	}
+
+	// node1 has an active certificate now, only a bound or replacing token may take its name
+	for _, hash := range []string{"free", "replace", "bound-again"} {
+		machine := ""
+		if hash == "bound-again" {
+			machine = "node1"
+		}
+		if _, err := AddJoinToken(ctx, hash, machine, hash == "replace", time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("add token %s: %v", hash, err)
+		}
+	}
+	if err := ConsumeJoinToken(ctx, "free", "node1"); err == nil || !strings.Contains(err.Error(), "already enrolled") {
+		t.Fatalf("expected an unbound token to be refused for an enrolled machine, got %v", err)
+	}
+	if err := ConsumeJoinToken(ctx, "free", "node5"); err != nil {
+		t.Fatalf("the refused token must stay usable for a new machine: %v", err)
+	}
+	if err := ConsumeJoinToken(ctx, "replace", "node1"); err != nil {
+		t.Fatalf("consume replacing token: %v", err)
+	}
+	if err := ConsumeJoinToken(ctx, "bound-again", "node1"); err != nil {
+		t.Fatalf("consume token bound to an enrolled machine: %v", err)
+	}
+
+	missing, err := GetNodeCertificateBySerial(ctx, "ff")
+	if err != nil || missing != nil {
+		t.Fatalf("expected nil for unknown serial, got %+v, %v", missing, err)
+	}
+}
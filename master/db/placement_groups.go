@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// affinity keeps the VMs of a group on one host, anti-affinity keeps every
+// VM of the group on a different host
+const (
+	PlacementAffinity     = "affinity"
+	PlacementAntiAffinity = "anti-affinity"
+)
+
+// hard rules refuse a placement that breaks them, soft ones only warn and
+// make the scheduler prefer other hosts
+const (
+	PlacementHard = "hard"
+	PlacementSoft = "soft"
+)
+
+// PlacementGroup is a named set of VMs sharing a placement rule.
+type PlacementGroup struct {
+	Name        string   `json:"name"`
+	Policy      string   `json:"policy"`
+	Enforcement string   `json:"enforcement"`
+	VMs         []string `json:"vms"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+func CreatePlacementGroupTables(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS placement_groups (
+		name TEXT PRIMARY KEY,
+		policy TEXT NOT NULL,
+		enforcement TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS placement_group_vms (
+		group_name TEXT NOT NULL,
+		vm_name TEXT NOT NULL,
+		PRIMARY KEY (group_name, vm_name),
+		FOREIGN KEY (group_name) REFERENCES placement_groups(name) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_placement_group_vms_vm ON placement_group_vms(vm_name);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+func CreatePlacementGroup(ctx context.Context, group PlacementGroup) error {
+	_, err := DB.ExecContext(ctx, `INSERT INTO placement_groups (name, policy, enforcement, created_at)
+	VALUES (?, ?, ?, ?);`, group.Name, group.Policy, group.Enforcement, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func UpdatePlacementGroup(ctx context.Context, group PlacementGroup) error {
+	_, err := DB.ExecContext(ctx, `UPDATE placement_groups SET policy = ?, enforcement = ? WHERE name = ?;`,
+		group.Policy, group.Enforcement, group.Name)
+	return err
+}
+
+func DeletePlacementGroup(ctx context.Context, name string) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM placement_group_vms WHERE group_name = ?;`, name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM placement_groups WHERE name = ?;`, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func AddPlacementGroupVM(ctx context.Context, groupName, vmName string) error {
+	_, err := DB.ExecContext(ctx, `INSERT OR IGNORE INTO placement_group_vms (group_name, vm_name) VALUES (?, ?);`,
+		groupName, vmName)
+	return err
+}
+
+func RemovePlacementGroupVM(ctx context.Context, groupName, vmName string) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM placement_group_vms WHERE group_name = ? AND vm_name = ?;`,
+		groupName, vmName)
+	return err
+}
+
+func GetPlacementGroup(ctx context.Context, name string) (*PlacementGroup, error) {
+	row := DB.QueryRowContext(ctx, `SELECT name, policy, enforcement, created_at FROM placement_groups
+	WHERE name = ?;`, name)
+	var group PlacementGroup
+	err := row.Scan(&group.Name, &group.Policy, &group.Enforcement, &group.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []PlacementGroup{group}
+	if err := loadPlacementGroupVMs(ctx, groups); err != nil {
+		return nil, err
+	}
+	return &groups[0], nil
+}
+
+// GetPlacementGroups returns every group with its VMs.
+func GetPlacementGroups(ctx context.Context) ([]PlacementGroup, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT name, policy, enforcement, created_at FROM placement_groups
+	ORDER BY name;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []PlacementGroup
+	for rows.Next() {
+		var group PlacementGroup
+		if err := rows.Scan(&group.Name, &group.Policy, &group.Enforcement, &group.CreatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := loadPlacementGroupVMs(ctx, groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func loadPlacementGroupVMs(ctx context.Context, groups []PlacementGroup) error {
+	rows, err := DB.QueryContext(ctx, `SELECT group_name, vm_name FROM placement_group_vms ORDER BY vm_name;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	index := map[string]int{}
+	for i := range groups {
+		index[groups[i].Name] = i
+		groups[i].VMs = []string{}
+	}
+	for rows.Next() {
+		var groupName, vmName string
+		if err := rows.Scan(&groupName, &vmName); err != nil {
+			return err
+		}
+		if i, ok := index[groupName]; ok {
+			groups[i].VMs = append(groups[i].VMs, vmName)
+		}
+	}
+	return rows.Err()
+}
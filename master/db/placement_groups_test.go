@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+)
+
+func TestPlacementGroups(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreatePlacementGroupTables(ctx); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+
+	if err := CreatePlacementGroup(ctx, PlacementGroup{Name: "dns", Policy: PlacementAntiAffinity, Enforcement: PlacementHard}); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	if err := CreatePlacementGroup(ctx, PlacementGroup{Name: "dns", Policy: PlacementAffinity, Enforcement: PlacementSoft}); err == nil {
+		t.Fatalf("expected group names to be unique")
+	}
+	if err := CreatePlacementGroup(ctx, PlacementGroup{Name: "app", Policy: PlacementAffinity, Enforcement: PlacementSoft}); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+
+	for _, vm := range []string{"dns1", "dns2", "dns2"} {
+		if err := AddPlacementGroupVM(ctx, "dns", vm); err != nil {
+			t.Fatalf("add vm: %v", err)
+		}
+	}
+	if err := AddPlacementGroupVM(ctx, "app", "web"); err != nil {
+		t.Fatalf("add vm: %v", err)
+	}
+
+	group, err := GetPlacementGroup(ctx, "dns")
+	if err != nil || group == nil || !slices.Equal(group.VMs, []string{"dns1", "dns2"}) {
+		t.Fatalf("unexpected group %+v (%v)", group, err)
+	}
+
+	group.Enforcement = PlacementSoft
+	if err := UpdatePlacementGroup(ctx, *group); err != nil {
+		t.Fatalf("update group: %v", err)
+	}
+	if err := RemovePlacementGroupVM(ctx, "dns", "dns1"); err != nil {
+		t.Fatalf("remove vm: %v", err)
+	}
+
+	groups, err := GetPlacementGroups(ctx)
+	if err != nil || len(groups) != 2 {
+		t.Fatalf("unexpected groups %+v (%v)", groups, err)
+	}
+	if groups[0].Name != "app" || !slices.Equal(groups[0].VMs, []string{"web"}) {
+		t.Fatalf("unexpected group %+v", groups[0])
+	}
+	if groups[1].Enforcement != PlacementSoft || !slices.Equal(groups[1].VMs, []string{"dns2"}) {
+		t.Fatalf("unexpected group %+v", groups[1])
+	}
+
+	if err := DeletePlacementGroup(ctx, "dns"); err != nil {
+		t.Fatalf("delete group: %v", err)
+	}
+	if group, _ := GetPlacementGroup(ctx, "dns"); group != nil {
+		t.Fatalf("expected group to be deleted, got %+v", group)
+	}
+	var members int
+	if err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM placement_group_vms WHERE group_name = 'dns';`).Scan(&members); err != nil || members != 0 {
+		t.Fatalf("expected members to be deleted with the group, got %d (%v)", members, err)
+	}
+}
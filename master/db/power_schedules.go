@@ -0,0 +1,230 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+const (
+	PowerActionStart    = "start"
+	PowerActionShutdown = "shutdown"
+	PowerActionRestart  = "restart"
+	PowerActionPause    = "pause"
+	PowerActionResume   = "resume"
+	PowerActionSnapshot = "snapshot"
+)
+
+// a schedule targets one vm by name or every vm carrying a tag
+const (
+	PowerTargetVM  = "vm"
+	PowerTargetTag = "tag"
+)
+
+// PowerSchedule runs Action on its targets every time Cron matches.
+// ForceAfterSeconds turns a shutdown that didn't finish in time into a forced
+// one, 0 never forces. KeepSnapshots is how many snapshots a snapshot
+// schedule keeps per vm, 0 keeps them all.
+type PowerSchedule struct {
+	Id                int    `json:"id"`
+	Name              string `json:"name"`
+	Cron              string `json:"cron"`
+	Action            string `json:"action"`
+	TargetType        string `json:"target_type"`
+	Target            string `json:"target"`
+	ForceAfterSeconds int    `json:"force_after_seconds"`
+	KeepSnapshots     int    `json:"keep_snapshots"`
+	Enabled           bool   `json:"enabled"`
+	LastRun           string `json:"last_run"`
+	CreatedAt         string `json:"created_at"`
+}
+
+const (
+	PowerRunOk      = "ok"
+	PowerRunFailed  = "failed"
+	PowerRunSkipped = "skipped"
+)
+
+// PowerScheduleRun is the outcome of a schedule on one vm.
+type PowerScheduleRun struct {
+	Id         int    `json:"id"`
+	ScheduleId int    `json:"schedule_id"`
+	VmName     string `json:"vm_name"`
+	Action     string `json:"action"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+}
+
+const powerScheduleColumns = `id, name, cron, action, target_type, target, force_after_seconds, keep_snapshots, enabled, last_run, created_at`
+
+func CreatePowerScheduleTables(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS power_schedules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		cron TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target_type TEXT NOT NULL,
+		target TEXT NOT NULL,
+		force_after_seconds INTEGER NOT NULL DEFAULT 0,
+		keep_snapshots INTEGER NOT NULL DEFAULT 0,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		last_run TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS power_schedule_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		schedule_id INTEGER NOT NULL,
+		vm_name TEXT NOT NULL,
+		action TEXT NOT NULL,
+		status TEXT NOT NULL,
+		message TEXT NOT NULL DEFAULT '',
+		started_at TEXT NOT NULL,
+		finished_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_power_schedule_runs_schedule ON power_schedule_runs(schedule_id);
+	`
+	if _, err := DB.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	// Ensure keep_snapshots exists for older installations.
+	if _, err := DB.ExecContext(ctx, `ALTER TABLE power_schedules ADD COLUMN keep_snapshots INTEGER NOT NULL DEFAULT 0`); err != nil {
+		if !strings.Contains(strings.ToLower(err.Error()), "duplicate column name") {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanPowerSchedule(row rowScanner) (PowerSchedule, error) {
+	var s PowerSchedule
+	err := row.Scan(&s.Id, &s.Name, &s.Cron, &s.Action, &s.TargetType, &s.Target, &s.ForceAfterSeconds,
+		&s.KeepSnapshots, &s.Enabled, &s.LastRun, &s.CreatedAt)
+	return s, err
+}
+
+func AddPowerSchedule(ctx context.Context, s *PowerSchedule) error {
+	s.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	res, err := DB.ExecContext(ctx, `
+	INSERT INTO power_schedules (name, cron, action, target_type, target, force_after_seconds, keep_snapshots, enabled, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		s.Name, s.Cron, s.Action, s.TargetType, s.Target, s.ForceAfterSeconds, s.KeepSnapshots, s.Enabled, s.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	s.Id = int(id)
+	return nil
+}
+
+func UpdatePowerSchedule(ctx context.Context, s PowerSchedule) error {
+	_, err := DB.ExecContext(ctx, `
+	UPDATE power_schedules SET name = ?, cron = ?, action = ?, target_type = ?, target = ?,
+		force_after_seconds = ?, keep_snapshots = ?, enabled = ?
+	WHERE id = ?;`,
+		s.Name, s.Cron, s.Action, s.TargetType, s.Target, s.ForceAfterSeconds, s.KeepSnapshots, s.Enabled, s.Id)
+	return err
+}
+
+// DeletePowerSchedule removes the schedule and its run history.
+func DeletePowerSchedule(ctx context.Context, id int) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM power_schedule_runs WHERE schedule_id = ?;`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM power_schedules WHERE id = ?;`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func GetPowerSchedule(ctx context.Context, id int) (*PowerSchedule, error) {
+	row := DB.QueryRowContext(ctx, `SELECT `+powerScheduleColumns+` FROM power_schedules WHERE id = ?;`, id)
+	s, err := scanPowerSchedule(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func GetPowerSchedules(ctx context.Context) ([]PowerSchedule, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+powerScheduleColumns+` FROM power_schedules ORDER BY id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []PowerSchedule{}
+	for rows.Next() {
+		s, err := scanPowerSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func SetPowerScheduleLastRun(ctx context.Context, id int, t time.Time) error {
+	_, err := DB.ExecContext(ctx, `UPDATE power_schedules SET last_run = ? WHERE id = ?;`, t.UTC().Format(time.RFC3339), id)
+	return err
+}
+
+func AddPowerScheduleRun(ctx context.Context, run PowerScheduleRun) error {
+	_, err := DB.ExecContext(ctx, `
+	INSERT INTO power_schedule_runs (schedule_id, vm_name, action, status, message, started_at, finished_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?);`,
+		run.ScheduleId, run.VmName, run.Action, run.Status, run.Message, run.StartedAt,
+		time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// GetPowerScheduleRuns returns the run history, newest first. scheduleID
+// filters it down to one schedule when it is not 0.
+func GetPowerScheduleRuns(ctx context.Context, scheduleID int, limit int) ([]PowerScheduleRun, error) {
+	query := `SELECT id, schedule_id, vm_name, action, status, message, started_at, finished_at FROM power_schedule_runs`
+	var args []any
+	if scheduleID != 0 {
+		query += ` WHERE schedule_id = ?`
+		args = append(args, scheduleID)
+	}
+	query += ` ORDER BY id DESC LIMIT ?;`
+	args = append(args, limit)
+
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []PowerScheduleRun{}
+	for rows.Next() {
+		var r PowerScheduleRun
+		if err := rows.Scan(&r.Id, &r.ScheduleId, &r.VmName, &r.Action, &r.Status, &r.Message, &r.StartedAt, &r.FinishedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// PrunePowerScheduleRuns drops the history older than before.
+func PrunePowerScheduleRuns(ctx context.Context, before time.Time) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM power_schedule_runs WHERE started_at < ?;`, before.UTC().Format(time.RFC3339))
+	return err
+}
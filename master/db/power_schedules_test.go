@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestPowerSchedules(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreatePowerScheduleTables(ctx); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+	if err := CreateVMTagsTable(ctx); err != nil {
+		t.Fatalf("create tags table: %v", err)
+	}
+
+	for _, vm := range []string{"lab-1", "lab-2"} {
+		if err := AddVMTag(ctx, vm, "lab"); err != nil {
+			t.Fatalf("tag %s: %v", vm, err)
+		}
+	}
+	// tagging twice is a no-op
+	if err := AddVMTag(ctx, "lab-1", "lab"); err != nil {
+		t.Fatalf("tag again: %v", err)
+	}
+	vms, err := GetVMsByTag(ctx, "lab")
+	if err != nil || len(vms) != 2 || vms[0] != "lab-1" || vms[1] != "lab-2" {
+		t.Fatalf("unexpected tagged vms %v (%v)", vms, err)
+	}
+
+	start := &PowerSchedule{Name: "lab on", Cron: "0 8 * * 1-5", Action: PowerActionStart, TargetType: PowerTargetTag, Target: "lab", Enabled: true}
+	if err := AddPowerSchedule(ctx, start); err != nil {
+		t.Fatalf("add schedule: %v", err)
+	}
+	stop := &PowerSchedule{Name: "lab off", Cron: "0 19 * * 1-5", Action: PowerActionShutdown, TargetType: PowerTargetTag, Target: "lab", ForceAfterSeconds: 300, KeepSnapshots: 3, Enabled: true}
+	if err := AddPowerSchedule(ctx, stop); err != nil {
+		t.Fatalf("add schedule: %v", err)
+	}
+
+	stop.Enabled = false
+	if err := UpdatePowerSchedule(ctx, *stop); err != nil {
+		t.Fatalf("update schedule: %v", err)
+	}
+	if err := SetPowerScheduleLastRun(ctx, start.Id, time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("set last run: %v", err)
+	}
+
+	schedules, err := GetPowerSchedules(ctx)
+	if err != nil || len(schedules) != 2 {
+		t.Fatalf("unexpected schedules %+v (%v)", schedules, err)
+	}
+	if schedules[0].LastRun != "2026-10-16T08:00:00Z" || schedules[1].Enabled || schedules[1].ForceAfterSeconds != 300 || schedules[1].KeepSnapshots != 3 {
+		t.Fatalf("schedule fields not stored: %+v", schedules)
+	}
+
+	for _, vm := range vms {
+		run := PowerScheduleRun{ScheduleId: start.Id, VmName: vm, Action: PowerActionStart, Status: PowerRunOk, StartedAt: time.Now().UTC().Format(time.RFC3339)}
+		if err := AddPowerScheduleRun(ctx, run); err != nil {
+			t.Fatalf("add run: %v", err)
+		}
+	}
+	runs, err := GetPowerScheduleRuns(ctx, start.Id, 10)
+	if err != nil || len(runs) != 2 || runs[0].VmName != "lab-2" {
+		t.Fatalf("unexpected runs %+v (%v)", runs, err)
+	}
+
+	if err := DeletePowerSchedule(ctx, start.Id); err != nil {
+		t.Fatalf("delete schedule: %v", err)
+	}
+	if s, err := GetPowerSchedule(ctx, start.Id); err != nil || s != nil {
+		t.Fatalf("expected schedule to be gone, got %+v (%v)", s, err)
+	}
+	if runs, err := GetPowerScheduleRuns(ctx, 0, 10); err != nil || len(runs) != 0 {
+		t.Fatalf("expected the history to go with the schedule, got %+v (%v)", runs, err)
+	}
+}
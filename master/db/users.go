@@ -0,0 +1,302 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+	RoleVMOwner  = "vm_owner"
+)
+
+// where the identity of a user comes from, npm users are created on their
+// first login and always authenticate against nginx proxy manager
+const (
+	UserSourceLocal = "local"
+	UserSourceNPM   = "npm"
+)
+
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+type User struct {
+	Id        int    `json:"id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	Source    string `json:"source"`
+	Disabled  bool   `json:"disabled"`
+	CreatedAt string `json:"created_at"`
+	LastLogin string `json:"last_login"`
+
+	PasswordHash string `json:"-"`
+}
+
+// UserGrant gives a user access to a single resource, ResourceName "*" covers
+// every resource of that type.
+type UserGrant struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id"`
+	ResourceType string `json:"resource_type"`
+	ResourceName string `json:"resource_name"`
+	Access       string `json:"access"`
+}
+
+type UserSession struct {
+	UserId    int
+	NPMToken  string
+	CreatedAt string
+	ExpiresAt string
+}
+
+const userColumns = `id, username, email, role, source, disabled, created_at, last_login, password_hash`
+
+func CreateUserTables(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL UNIQUE,
+		email TEXT NOT NULL DEFAULT '',
+		password_hash TEXT NOT NULL DEFAULT '',
+		role TEXT NOT NULL,
+		source TEXT NOT NULL,
+		disabled INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL,
+		last_login TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE IF NOT EXISTS user_grants (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		resource_type TEXT NOT NULL,
+		resource_name TEXT NOT NULL,
+		access TEXT NOT NULL,
+		UNIQUE (user_id, resource_type, resource_name)
+	);
+	CREATE TABLE IF NOT EXISTS user_sessions (
+		token_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		npm_token TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+func AddUser(ctx context.Context, user User) (*User, error) {
+	res, err := DB.ExecContext(ctx, `
+	INSERT INTO users (username, email, password_hash, role, source, disabled, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?);`,
+		user.Username, user.Email, user.PasswordHash, user.Role, user.Source, user.Disabled, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetUserByID(ctx, int(id))
+}
+
+func GetUsers(ctx context.Context) ([]User, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+userColumns+` FROM users ORDER BY username;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func getUser(ctx context.Context, where string, args ...any) (*User, error) {
+	row := DB.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE `+where+`;`, args...)
+	user, err := scanUser(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func GetUserByID(ctx context.Context, id int) (*User, error) {
+	return getUser(ctx, `id = ?`, id)
+}
+
+// GetUserByLogin finds a user by username or, case insensitive, by email.
+func GetUserByLogin(ctx context.Context, login string) (*User, error) {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		return nil, nil
+	}
+	user, err := getUser(ctx, `username = ?`, login)
+	if user != nil || err != nil {
+		return user, err
+	}
+	return getUser(ctx, `email <> '' AND lower(email) = lower(?) ORDER BY id LIMIT 1`, login)
+}
+
+func UpdateUser(ctx context.Context, user User) error {
+	_, err := DB.ExecContext(ctx, `UPDATE users SET email = ?, role = ?, disabled = ? WHERE id = ?;`,
+		user.Email, user.Role, user.Disabled, user.Id)
+	return err
+}
+
+func SetUserPassword(ctx context.Context, id int, passwordHash string) error {
+	_, err := DB.ExecContext(ctx, `UPDATE users SET password_hash = ? WHERE id = ?;`, passwordHash, id)
+	return err
+}
+
+func TouchUserLogin(ctx context.Context, id int) error {
+	_, err := DB.ExecContext(ctx, `UPDATE users SET last_login = ? WHERE id = ?;`, time.Now().Format(time.RFC3339), id)
+	return err
+}
+
+// DeleteUser removes the user together with its grants and sessions.
+func DeleteUser(ctx context.Context, id int) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM user_grants WHERE user_id = ?;`,
+		`DELETE FROM user_sessions WHERE user_id = ?;`,
+		`DELETE FROM users WHERE id = ?;`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func CountUsers(ctx context.Context) (int, error) {
+	var n int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM users;`).Scan(&n)
+	return n, err
+}
+
+// CountActiveAdmins is used to refuse changes that would lock everyone out.
+func CountActiveAdmins(ctx context.Context) (int, error) {
+	var n int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE role = ? AND disabled = 0;`, RoleAdmin).Scan(&n)
+	return n, err
+}
+
+func scanUser(scanner rowScanner) (User, error) {
+	var user User
+	err := scanner.Scan(&user.Id, &user.Username, &user.Email, &user.Role, &user.Source, &user.Disabled,
+		&user.CreatedAt, &user.LastLogin, &user.PasswordHash)
+	return user, err
+}
+
+// SetUserGrant adds a grant or changes the access of an existing one.
+func SetUserGrant(ctx context.Context, grant UserGrant) (*UserGrant, error) {
+	_, err := DB.ExecContext(ctx, `
+	INSERT INTO user_grants (user_id, resource_type, resource_name, access) VALUES (?, ?, ?, ?)
+	ON CONFLICT (user_id, resource_type, resource_name) DO UPDATE SET access = excluded.access;`,
+		grant.UserId, grant.ResourceType, grant.ResourceName, grant.Access)
+	if err != nil {
+		return nil, err
+	}
+	row := DB.QueryRowContext(ctx, `
+	SELECT id, user_id, resource_type, resource_name, access FROM user_grants
+	WHERE user_id = ? AND resource_type = ? AND resource_name = ?;`,
+		grant.UserId, grant.ResourceType, grant.ResourceName)
+	var saved UserGrant
+	if err := row.Scan(&saved.Id, &saved.UserId, &saved.ResourceType, &saved.ResourceName, &saved.Access); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func GetUserGrants(ctx context.Context, userID int) ([]UserGrant, error) {
+	rows, err := DB.QueryContext(ctx, `
+	SELECT id, user_id, resource_type, resource_name, access FROM user_grants
+	WHERE user_id = ? ORDER BY resource_type, resource_name;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []UserGrant
+	for rows.Next() {
+		var grant UserGrant
+		if err := rows.Scan(&grant.Id, &grant.UserId, &grant.ResourceType, &grant.ResourceName, &grant.Access); err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
+
+func DeleteUserGrant(ctx context.Context, userID, grantID int) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM user_grants WHERE id = ? AND user_id = ?;`, grantID, userID)
+	return err
+}
+
+// DeleteResourceGrants drops the grants on a resource that no longer exists,
+// a new resource with the same name must not inherit them.
+func DeleteResourceGrants(ctx context.Context, resourceType, resourceName string) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM user_grants WHERE resource_type = ? AND resource_name = ?;`,
+		resourceType, resourceName)
+	return err
+}
+
+func AddUserSession(ctx context.Context, tokenHash string, userID int, npmToken string, expiresAt time.Time) error {
+	_, err := DB.ExecContext(ctx, `
+	INSERT INTO user_sessions (token_hash, user_id, npm_token, created_at, expires_at) VALUES (?, ?, ?, ?, ?);`,
+		tokenHash, userID, npmToken, time.Now().UTC().Format(time.RFC3339), expiresAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+// GetUserSession returns nil when the session does not exist or has expired.
+func GetUserSession(ctx context.Context, tokenHash string) (*UserSession, error) {
+	var session UserSession
+	err := DB.QueryRowContext(ctx, `
+	SELECT user_id, npm_token, created_at, expires_at FROM user_sessions
+	WHERE token_hash = ? AND expires_at > ?;`, tokenHash, time.Now().UTC().Format(time.RFC3339)).
+		Scan(&session.UserId, &session.NPMToken, &session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+func DeleteUserSession(ctx context.Context, tokenHash string) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM user_sessions WHERE token_hash = ?;`, tokenHash)
+	return err
+}
+
+func DeleteUserSessions(ctx context.Context, userID int) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM user_sessions WHERE user_id = ?;`, userID)
+	return err
+}
+
+func DeleteExpiredUserSessions(ctx context.Context) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM user_sessions WHERE expires_at <= ?;`, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestUsersGrantsAndSessions(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateUserTables(ctx); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+
+	admin, err := AddUser(ctx, User{Username: "root", Email: "Root@Example.com", Role: RoleAdmin, Source: UserSourceLocal, PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("add admin: %v", err)
+	}
+	owner, err := AddUser(ctx, User{Username: "bob", Role: RoleVMOwner, Source: UserSourceLocal})
+	if err != nil {
+		t.Fatalf("add owner: %v", err)
+	}
+	if _, err := AddUser(ctx, User{Username: "bob", Role: RoleViewer, Source: UserSourceLocal}); err == nil {
+		t.Fatalf("expected duplicate username to fail")
+	}
+
+	found, err := GetUserByLogin(ctx, "root@example.com")
+	if err != nil || found == nil || found.Id != admin.Id || found.PasswordHash != "hash" {
+		t.Fatalf("expected to find admin by email, got %+v, %v", found, err)
+	}
+	if n, err := CountActiveAdmins(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 active admin, got %d, %v", n, err)
+	}
+
+	if _, err := SetUserGrant(ctx, UserGrant{UserId: owner.Id, ResourceType: "vms", ResourceName: "web1", Access: AccessRead}); err != nil {
+		t.Fatalf("add grant: %v", err)
+	}
+	grant, err := SetUserGrant(ctx, UserGrant{UserId: owner.Id, ResourceType: "vms", ResourceName: "web1", Access: AccessWrite})
+	if err != nil {
+		t.Fatalf("update grant: %v", err)
+	}
+	grants, err := GetUserGrants(ctx, owner.Id)
+	if err != nil || len(grants) != 1 || grants[0].Access != AccessWrite || grants[0].Id != grant.Id {
+		t.Fatalf("expected a single write grant, got %+v, %v", grants, err)
+	}
+
+	if err := AddUserSession(ctx, "live", owner.Id, "npm", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("add session: %v", err)
+	}
+	if err := AddUserSession(ctx, "old", owner.Id, "", time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("add expired session: %v", err)
+	}
+	session, err := GetUserSession(ctx, "live")
+	if err != nil || session == nil || session.UserId != owner.Id || session.NPMToken != "npm" {
+		t.Fatalf("expected live session, got %+v, %v", session, err)
+	}
+	if session, err := GetUserSession(ctx, "old"); err != nil || session != nil {
+		t.Fatalf("expected expired session to be ignored, got %+v, %v", session, err)
+	}
+
+	if err := DeleteUser(ctx, owner.Id); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if grants, err := GetUserGrants(ctx, owner.Id); err != nil || len(grants) != 0 {
+		t.Fatalf("expected grants to be removed, got %+v, %v", grants, err)
+	}
+	if session, err := GetUserSession(ctx, "live"); err != nil || session != nil {
+		t.Fatalf("expected sessions to be removed, got %+v, %v", session, err)
+	}
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"strings"
 )
 
 // just to save which vms can be migrated live
@@ -108,9 +109,33 @@ func GetAllVmLiveNames(ctx context.Context) (map[string]bool, error) {
 	return result, rows.Err()
 }
 
+// AutoStart readiness checks, what has to answer before the next vm starts.
+const (
+	AutoStartWaitNone  = ""
+	AutoStartWaitAgent = "agent"
+	AutoStartWaitTCP   = "tcp"
+)
+
+// AutoStart is a vm started when its host comes up. Vms boot group by group in
+// the group's order and by Order inside one, vms without a group go last.
 type AutoStart struct {
-	Id     int
-	VmName string
+	Id           int    `json:"id"`
+	VmName       string `json:"vm_name"`
+	Group        string `json:"group"`
+	Order        int    `json:"order"`
+	DelaySeconds int    `json:"delay_seconds"` // wait after this vm before the next one
+	WaitFor      string `json:"wait_for"`
+	TCPHost      string `json:"tcp_host"` // empty means the vm's first ip
+	TCPPort      int    `json:"tcp_port"`
+	ReadyTimeout int    `json:"ready_timeout"` // seconds
+}
+
+const autoStartColumns = "a.id, a.vm_name, a.group_name, a.boot_order, a.delay_seconds, a.wait_for, a.tcp_host, a.tcp_port, a.ready_timeout"
+
+func scanAutoStart(row rowScanner) (AutoStart, error) {
+	var a AutoStart
+	err := row.Scan(&a.Id, &a.VmName, &a.Group, &a.Order, &a.DelaySeconds, &a.WaitFor, &a.TCPHost, &a.TCPPort, &a.ReadyTimeout)
+	return a, err
 }
 
 func CreateTableAutoStart(ctx context.Context) error {
@@ -125,6 +150,30 @@ func CreateTableAutoStart(ctx context.Context) error {
 		return err
 	}
 
+	// boot ordering columns for older installations
+	for _, column := range []string{
+		`ALTER TABLE auto_start ADD COLUMN group_name TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE auto_start ADD COLUMN boot_order INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE auto_start ADD COLUMN delay_seconds INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE auto_start ADD COLUMN wait_for TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE auto_start ADD COLUMN tcp_host TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE auto_start ADD COLUMN tcp_port INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE auto_start ADD COLUMN ready_timeout INTEGER NOT NULL DEFAULT 0`,
+	} {
+		if _, err := DB.ExecContext(ctx, column); err != nil {
+			if !strings.Contains(strings.ToLower(err.Error()), "duplicate column name") {
+				return err
+			}
+		}
+	}
+
+	_, err = DB.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS auto_start_groups (
+		name TEXT PRIMARY KEY,
+		boot_order INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL
+	);
+	`)
 	return err
 }
 
@@ -155,10 +204,13 @@ func RemoveAutoStartById(ctx context.Context, id int) error {
 	return err
 }
 
+// GetAllAutoStart returns the vms in boot order.
 func GetAllAutoStart(ctx context.Context) ([]AutoStart, error) {
-	const query = `
-	SELECT id, vm_name
-	FROM auto_start;
+	query := `
+	SELECT ` + autoStartColumns + `
+	FROM auto_start a
+	LEFT JOIN auto_start_groups g ON g.name = a.group_name
+	ORDER BY g.name IS NULL, g.boot_order, g.name, a.boot_order, a.vm_name;
 	`
 	rows, err := DB.QueryContext(ctx, query)
 	if err != nil {
@@ -168,24 +220,19 @@ func GetAllAutoStart(ctx context.Context) ([]AutoStart, error) {
 
 	var vms []AutoStart
 	for rows.Next() {
-		var vm AutoStart
-		if err := rows.Scan(&vm.Id, &vm.VmName); err != nil {
+		vm, err := scanAutoStart(rows)
+		if err != nil {
 			return nil, err
 		}
 		vms = append(vms, vm)
 	}
-	return vms, nil
+	return vms, rows.Err()
 }
 
 func GetAutoStartByName(ctx context.Context, vmName string) (*AutoStart, error) {
-	const query = `
-	SELECT id, vm_name
-	FROM auto_start
-	WHERE vm_name = ?;
-	`
+	query := `SELECT ` + autoStartColumns + ` FROM auto_start a WHERE a.vm_name = ?;`
 	row := DB.QueryRowContext(ctx, query, vmName)
-	var vm AutoStart
-	err := row.Scan(&vm.Id, &vm.VmName)
+	vm, err := scanAutoStart(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -196,14 +243,9 @@ func GetAutoStartByName(ctx context.Context, vmName string) (*AutoStart, error)
 }
 
 func GetAutoStartById(ctx context.Context, id int) (*AutoStart, error) {
-	const query = `
-	SELECT id, vm_name
-	FROM auto_start
-	WHERE id = ?;
-	`
+	query := `SELECT ` + autoStartColumns + ` FROM auto_start a WHERE a.id = ?;`
 	row := DB.QueryRowContext(ctx, query, id)
-	var vm AutoStart
-	err := row.Scan(&vm.Id, &vm.VmName)
+	vm, err := scanAutoStart(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
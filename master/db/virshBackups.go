@@ -1,9 +1,11 @@
 package db
 
 import (
+	"512SvMan/backupcodec"
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -14,6 +16,50 @@ type VirshBackup struct {
 	NfsId     int
 	CreatedAt string
 	Automatic bool
+	// incremental backups only hold the blocks changed since ParentId and use
+	// the parent file as qcow2 backing file
+	ParentId    *int
+	Checkpoint  string
+	Incremental bool
+	// Checksum is the sha256 of the file taken right after the backup,
+	// verification compares against it
+	Checksum string
+	// Pinned backups are never pruned by retention nor deleted until unpinned
+	Pinned bool
+	// how the file at Path is stored, see backupcodec. Encrypted backups use
+	// the master key KeyId, the sizes are in bytes before and after
+	Compression  string
+	Encrypted    bool
+	KeyId        int
+	OriginalSize int64
+	StoredSize   int64
+}
+
+// NeedsDecode reports whether Path has to go through backupcodec before it
+// can be used as a qcow2 image.
+func (b VirshBackup) NeedsDecode() bool {
+	return b.Encrypted || b.Compression == backupcodec.CompressionZstd
+}
+
+const virshBackupColumns = "id, name, path, nfsmount_id, created_at, automatic, parent_id, checkpoint, incremental, checksum, pinned, " +
+	"compression, encrypted, key_id, original_size, stored_size"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanVirshBackup(row rowScanner) (VirshBackup, error) {
+	var b VirshBackup
+	var parentID sql.NullInt64
+	if err := row.Scan(&b.Id, &b.Name, &b.Path, &b.NfsId, &b.CreatedAt, &b.Automatic, &parentID, &b.Checkpoint, &b.Incremental, &b.Checksum, &b.Pinned,
+		&b.Compression, &b.Encrypted, &b.KeyId, &b.OriginalSize, &b.StoredSize); err != nil {
+		return b, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		b.ParentId = &id
+	}
+	return b, nil
 }
 
 func CreateTableBackups(ctx context.Context) error {
@@ -24,16 +70,50 @@ func CreateTableBackups(ctx context.Context) error {
 		path TEXT NOT NULL,
 		nfsmount_id INT NOT NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		automatic BOOLEAN DEFAULT 0
+		automatic BOOLEAN DEFAULT 0,
+		parent_id INTEGER,
+		checkpoint TEXT NOT NULL DEFAULT '',
+		incremental BOOLEAN NOT NULL DEFAULT 0,
+		checksum TEXT NOT NULL DEFAULT '',
+		pinned BOOLEAN NOT NULL DEFAULT 0,
+		compression TEXT NOT NULL DEFAULT '',
+		encrypted BOOLEAN NOT NULL DEFAULT 0,
+		key_id INTEGER NOT NULL DEFAULT 0,
+		original_size INTEGER NOT NULL DEFAULT 0,
+		stored_size INTEGER NOT NULL DEFAULT 0
 	);
 	`
-	_, err := DB.ExecContext(ctx, query)
-	return err
+	if _, err := DB.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	// Ensure backup chain, checksum, pinned and storage columns exist for older installations.
+	for _, column := range []string{
+		`ALTER TABLE virsh_backups ADD COLUMN parent_id INTEGER`,
+		`ALTER TABLE virsh_backups ADD COLUMN checkpoint TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE virsh_backups ADD COLUMN incremental BOOLEAN NOT NULL DEFAULT 0`,
+		`ALTER TABLE virsh_backups ADD COLUMN checksum TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE virsh_backups ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT 0`,
+		`ALTER TABLE virsh_backups ADD COLUMN compression TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE virsh_backups ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT 0`,
+		`ALTER TABLE virsh_backups ADD COLUMN key_id INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE virsh_backups ADD COLUMN original_size INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE virsh_backups ADD COLUMN stored_size INTEGER NOT NULL DEFAULT 0`,
+	} {
+		if _, err := DB.ExecContext(ctx, column); err != nil {
+			if !strings.Contains(strings.ToLower(err.Error()), "duplicate column name") {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func InsertVirshBackup(ctx context.Context, b *VirshBackup) error {
-	query := `INSERT INTO virsh_backups (name, path, nfsmount_id, automatic) VALUES (?, ?, ?, ?)`
-	result, err := DB.ExecContext(ctx, query, b.Name, b.Path, b.NfsId, b.Automatic)
+	query := `INSERT INTO virsh_backups (name, path, nfsmount_id, automatic, parent_id, checkpoint, incremental,
+	          compression, encrypted, key_id, original_size, stored_size) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := DB.ExecContext(ctx, query, b.Name, b.Path, b.NfsId, b.Automatic, b.ParentId, b.Checkpoint, b.Incremental,
+		b.Compression, b.Encrypted, b.KeyId, b.OriginalSize, b.StoredSize)
 	if err != nil {
 		return fmt.Errorf("failed to insert virsh backup: %v", err)
 	}
@@ -46,7 +126,7 @@ func InsertVirshBackup(ctx context.Context, b *VirshBackup) error {
 }
 
 func GetAllVirshBackups(ctx context.Context) ([]VirshBackup, error) {
-	rows, err := DB.QueryContext(ctx, "SELECT id, name, path, nfsmount_id, created_at, automatic FROM virsh_backups")
+	rows, err := DB.QueryContext(ctx, "SELECT "+virshBackupColumns+" FROM virsh_backups")
 	if err != nil {
 		return nil, fmt.Errorf("failed to query all backups: %v", err)
 	}
@@ -54,8 +134,8 @@ func GetAllVirshBackups(ctx context.Context) ([]VirshBackup, error) {
 
 	var backups []VirshBackup
 	for rows.Next() {
-		var b VirshBackup
-		if err := rows.Scan(&b.Id, &b.Name, &b.Path, &b.NfsId, &b.CreatedAt, &b.Automatic); err != nil {
+		b, err := scanVirshBackup(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %v", err)
 		}
 		backups = append(backups, b)
@@ -66,9 +146,10 @@ func GetAllVirshBackups(ctx context.Context) ([]VirshBackup, error) {
 
 func GetVirshBackupsByNfsMountID(ctx context.Context, nfsMountID int) ([]VirshBackup, error) {
 	const query = `
-	SELECT id, name, path, nfsmount_id, created_at, automatic
+	SELECT ` + virshBackupColumns + `
 	FROM virsh_backups
-	WHERE nfsmount_id = ?;
+	WHERE nfsmount_id = ?
+	ORDER BY id;
 	`
 
 	rows, err := DB.QueryContext(ctx, query, nfsMountID)
@@ -79,8 +160,8 @@ func GetVirshBackupsByNfsMountID(ctx context.Context, nfsMountID int) ([]VirshBa
 
 	var backups []VirshBackup
 	for rows.Next() {
-		var b VirshBackup
-		if err := rows.Scan(&b.Id, &b.Name, &b.Path, &b.NfsId, &b.CreatedAt, &b.Automatic); err != nil {
+		b, err := scanVirshBackup(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %v", err)
 		}
 		backups = append(backups, b)
@@ -90,11 +171,11 @@ func GetVirshBackupsByNfsMountID(ctx context.Context, nfsMountID int) ([]VirshBa
 }
 
 func GetVirshBackupById(ctx context.Context, id int) (*VirshBackup, error) {
-	query := `SELECT id, name, path, nfsmount_id, created_at, automatic FROM virsh_backups WHERE id = ?`
+	query := `SELECT ` + virshBackupColumns + ` FROM virsh_backups WHERE id = ?`
 	row := DB.QueryRowContext(ctx, query, id)
 
-	var b VirshBackup
-	if err := row.Scan(&b.Id, &b.Name, &b.Path, &b.NfsId, &b.CreatedAt, &b.Automatic); err != nil {
+	b, err := scanVirshBackup(row)
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -113,8 +194,105 @@ func DeleteVirshBackupById(ctx context.Context, id int) error {
 	return nil
 }
 
+func SetVirshBackupChecksum(ctx context.Context, id int, checksum string) error {
+	_, err := DB.ExecContext(ctx, `UPDATE virsh_backups SET checksum = ? WHERE id = ?`, checksum, id)
+	if err != nil {
+		return fmt.Errorf("failed to set backup checksum: %v", err)
+	}
+	return nil
+}
+
+func SetVirshBackupPinned(ctx context.Context, id int, pinned bool) error {
+	_, err := DB.ExecContext(ctx, `UPDATE virsh_backups SET pinned = ? WHERE id = ?`, pinned, id)
+	if err != nil {
+		return fmt.Errorf("failed to set backup pinned: %v", err)
+	}
+	return nil
+}
+
+// GetVirshBackupChildren returns the incremental backups built directly on top of id.
+func GetVirshBackupChildren(ctx context.Context, id int) ([]VirshBackup, error) {
+	query := `SELECT ` + virshBackupColumns + ` FROM virsh_backups WHERE parent_id = ?`
+	rows, err := DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query backup children: %v", err)
+	}
+	defer rows.Close()
+
+	var backups []VirshBackup
+	for rows.Next() {
+		b, err := scanVirshBackup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		backups = append(backups, b)
+	}
+
+	return backups, nil
+}
+
+// GetVirshBackupChain walks the parent links of id and returns the chain
+// ordered from the full backup to id itself.
+func GetVirshBackupChain(ctx context.Context, id int) ([]VirshBackup, error) {
+	var chain []VirshBackup
+	seen := make(map[int]bool)
+
+	next := &id
+	for next != nil {
+		if seen[*next] {
+			return nil, fmt.Errorf("backup chain of %d has a loop at %d", id, *next)
+		}
+		seen[*next] = true
+
+		b, err := GetVirshBackupById(ctx, *next)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			return nil, fmt.Errorf("backup %d of the chain of %d not found", *next, id)
+		}
+		chain = append([]VirshBackup{*b}, chain...)
+		next = b.ParentId
+	}
+
+	if len(chain) > 0 && chain[0].Incremental {
+		return nil, fmt.Errorf("backup chain of %d does not start with a full backup", id)
+	}
+	return chain, nil
+}
+
+// GetLatestCheckpointBackup returns the newest backup of vmName that recorded a
+// libvirt checkpoint, the only one an incremental backup can be based on.
+func GetLatestCheckpointBackup(ctx context.Context, vmName string) (*VirshBackup, error) {
+	query := `SELECT ` + virshBackupColumns + ` FROM virsh_backups
+			  WHERE name = ? AND checkpoint != ''
+			  ORDER BY created_at DESC, id DESC
+			  LIMIT 1`
+	row := DB.QueryRowContext(ctx, query, vmName)
+
+	b, err := scanVirshBackup(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query latest checkpoint backup: %v", err)
+	}
+
+	return &b, nil
+}
+
+// ClearVirshBackupCheckpoints forgets the checkpoints of vmName's backups once
+// the disk they tracked is gone, its next backup starts a new full chain.
+func ClearVirshBackupCheckpoints(ctx context.Context, vmName string) error {
+	_, err := DB.ExecContext(ctx, `UPDATE virsh_backups SET checkpoint = '' WHERE name = ?`, vmName)
+	if err != nil {
+		return fmt.Errorf("failed to clear backup checkpoints: %v", err)
+	}
+	return nil
+}
+
 func GetAutomaticBackups(ctx context.Context, vmName string) ([]*VirshBackup, error) {
-	query := `SELECT id, name, path, nfsmount_id, created_at, automatic 
+	query := `SELECT ` + virshBackupColumns + `
 			  FROM virsh_backups 
 			  WHERE name = ? AND automatic = 1 
 			  ORDER BY created_at DESC`
@@ -127,8 +305,8 @@ func GetAutomaticBackups(ctx context.Context, vmName string) ([]*VirshBackup, er
 
 	var backups []*VirshBackup
 	for rows.Next() {
-		var b VirshBackup
-		if err := rows.Scan(&b.Id, &b.Name, &b.Path, &b.NfsId, &b.CreatedAt, &b.Automatic); err != nil {
+		b, err := scanVirshBackup(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %v", err)
 		}
 		backups = append(backups, &b)
@@ -148,11 +326,39 @@ func CreateTableAutomaticBackup(ctx context.Context) error {
 		nfsmount_id INTEGER,
 		max_backups_retain INTEGER DEFAULT 5,
 		enabled BOOLEAN DEFAULT 1,
-		last_backup_time DATETIME
+		last_backup_time DATETIME,
+		full_every INTEGER NOT NULL DEFAULT 0,
+		keep_daily INTEGER NOT NULL DEFAULT 0,
+		keep_weekly INTEGER NOT NULL DEFAULT 0,
+		keep_monthly INTEGER NOT NULL DEFAULT 0,
+		keep_yearly INTEGER NOT NULL DEFAULT 0,
+		min_age_days INTEGER NOT NULL DEFAULT 0,
+		compression TEXT NOT NULL DEFAULT '',
+		encrypt BOOLEAN NOT NULL DEFAULT 0
 	);
 	`
-	_, err := DB.ExecContext(ctx, query)
-	return err
+	if _, err := DB.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	// Ensure full_every, retention and storage columns exist for older installations.
+	for _, column := range []string{
+		`ALTER TABLE automatic_backup ADD COLUMN full_every INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE automatic_backup ADD COLUMN keep_daily INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE automatic_backup ADD COLUMN keep_weekly INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE automatic_backup ADD COLUMN keep_monthly INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE automatic_backup ADD COLUMN keep_yearly INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE automatic_backup ADD COLUMN min_age_days INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE automatic_backup ADD COLUMN compression TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE automatic_backup ADD COLUMN encrypt BOOLEAN NOT NULL DEFAULT 0`,
+	} {
+		if _, err := DB.ExecContext(ctx, column); err != nil {
+			if !strings.Contains(strings.ToLower(err.Error()), "duplicate column name") {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 type Clock struct {
@@ -227,14 +433,48 @@ type AutomaticBackup struct {
 	MaxBackupsRetain int
 	Enabled          bool
 	LastBackupTime   *string
+	// FullEvery is how many incremental backups are taken between two full
+	// ones, 0 keeps every automatic backup full
+	FullEvery int
+	// grandfather-father-son retention on top of the MaxBackupsRetain newest:
+	// the newest backup of each of the last KeepDaily days, KeepWeekly weeks,
+	// KeepMonthly months and KeepYearly years is kept too
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+	KeepYearly  int
+	// MinAgeDays protects backups younger than this from pruning
+	MinAgeDays int
+	// Compression and Encrypt are how new backups are stored, see VirshBackup
+	Compression string
+	Encrypt     bool
+}
+
+const automaticBackupColumns = `id, vm_name, frequency_days, min_time, max_time, nfsmount_id,
+	       max_backups_retain, enabled, last_backup_time, full_every,
+	       keep_daily, keep_weekly, keep_monthly, keep_yearly, min_age_days, compression, encrypt`
+
+func scanAutomaticBackup(row rowScanner) (AutomaticBackup, error) {
+	var ab AutomaticBackup
+	var minTimeStr, maxTimeStr string
+	if err := row.Scan(&ab.Id, &ab.VmName, &ab.FrequencyDays, &minTimeStr, &maxTimeStr,
+		&ab.NfsMountId, &ab.MaxBackupsRetain, &ab.Enabled, &ab.LastBackupTime, &ab.FullEvery,
+		&ab.KeepDaily, &ab.KeepWeekly, &ab.KeepMonthly, &ab.KeepYearly, &ab.MinAgeDays, &ab.Compression, &ab.Encrypt); err != nil {
+		return ab, err
+	}
+	ab.MinTime, _ = ParseClock(minTimeStr)
+	ab.MaxTime, _ = ParseClock(maxTimeStr)
+	return ab, nil
 }
 
 func AddAutomaticBackup(ctx context.Context, ab *AutomaticBackup) error {
 	query := `
-	INSERT INTO automatic_backup (vm_name, frequency_days, min_time, max_time, nfsmount_id, max_backups_retain, enabled)
-	VALUES (?, ?, ?, ?, ?, ?, ?);
+	INSERT INTO automatic_backup (vm_name, frequency_days, min_time, max_time, nfsmount_id, max_backups_retain, enabled, full_every,
+	                              keep_daily, keep_weekly, keep_monthly, keep_yearly, min_age_days, compression, encrypt)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 	`
-	result, err := DB.ExecContext(ctx, query, ab.VmName, ab.FrequencyDays, ab.MinTime.String(), ab.MaxTime.String(), ab.NfsMountId, ab.MaxBackupsRetain, ab.Enabled)
+	result, err := DB.ExecContext(ctx, query, ab.VmName, ab.FrequencyDays, ab.MinTime.String(), ab.MaxTime.String(), ab.NfsMountId, ab.MaxBackupsRetain, ab.Enabled, ab.FullEvery,
+		ab.KeepDaily, ab.KeepWeekly, ab.KeepMonthly, ab.KeepYearly, ab.MinAgeDays, ab.Compression, ab.Encrypt)
 	if err != nil {
 		return err
 	}
@@ -250,10 +490,13 @@ func UpdateAutomaticBackup(ctx context.Context, ab *AutomaticBackup) error {
 	query := `
 	UPDATE automatic_backup
 	SET frequency_days = ?, min_time = ?, max_time = ?, nfsmount_id = ?, 
-	    max_backups_retain = ?, enabled = ?
+	    max_backups_retain = ?, enabled = ?, full_every = ?,
+	    keep_daily = ?, keep_weekly = ?, keep_monthly = ?, keep_yearly = ?, min_age_days = ?,
+	    compression = ?, encrypt = ?
 	WHERE id = ?;
 	`
-	_, err := DB.ExecContext(ctx, query, ab.FrequencyDays, ab.MinTime.String(), ab.MaxTime.String(), ab.NfsMountId, ab.MaxBackupsRetain, ab.Enabled, ab.Id)
+	_, err := DB.ExecContext(ctx, query, ab.FrequencyDays, ab.MinTime.String(), ab.MaxTime.String(), ab.NfsMountId, ab.MaxBackupsRetain, ab.Enabled, ab.FullEvery,
+		ab.KeepDaily, ab.KeepWeekly, ab.KeepMonthly, ab.KeepYearly, ab.MinAgeDays, ab.Compression, ab.Encrypt, ab.Id)
 	return err
 }
 
@@ -327,8 +570,7 @@ func DisableAutomaticBackupById(ctx context.Context, id int) error {
 
 func GetAllAutomaticBackups(ctx context.Context) ([]AutomaticBackup, error) {
 	const query = `
-	SELECT id, vm_name, frequency_days, min_time, max_time, nfsmount_id, 
-	       max_backups_retain, enabled, last_backup_time
+	SELECT ` + automaticBackupColumns + `
 	FROM automatic_backup;
 	`
 	rows, err := DB.QueryContext(ctx, query)
@@ -339,14 +581,10 @@ func GetAllAutomaticBackups(ctx context.Context) ([]AutomaticBackup, error) {
 
 	var backups []AutomaticBackup
 	for rows.Next() {
-		var ab AutomaticBackup
-		var minTimeStr, maxTimeStr string
-		if err := rows.Scan(&ab.Id, &ab.VmName, &ab.FrequencyDays, &minTimeStr, &maxTimeStr,
-			&ab.NfsMountId, &ab.MaxBackupsRetain, &ab.Enabled, &ab.LastBackupTime); err != nil {
+		ab, err := scanAutomaticBackup(rows)
+		if err != nil {
 			return nil, err
 		}
-		ab.MinTime, _ = ParseClock(minTimeStr)
-		ab.MaxTime, _ = ParseClock(maxTimeStr)
 		backups = append(backups, ab)
 	}
 	return backups, nil
@@ -354,8 +592,7 @@ func GetAllAutomaticBackups(ctx context.Context) ([]AutomaticBackup, error) {
 
 func GetAutomaticBackupsByNfsMountID(ctx context.Context, nfsMountID int) ([]AutomaticBackup, error) {
 	const query = `
-	SELECT id, vm_name, frequency_days, min_time, max_time, nfsmount_id, 
-	       max_backups_retain, enabled, last_backup_time
+	SELECT ` + automaticBackupColumns + `
 	FROM automatic_backup
 	WHERE nfsmount_id = ?;
 	`
@@ -368,14 +605,10 @@ func GetAutomaticBackupsByNfsMountID(ctx context.Context, nfsMountID int) ([]Aut
 
 	var backups []AutomaticBackup
 	for rows.Next() {
-		var ab AutomaticBackup
-		var minTimeStr, maxTimeStr string
-		if err := rows.Scan(&ab.Id, &ab.VmName, &ab.FrequencyDays, &minTimeStr, &maxTimeStr,
-			&ab.NfsMountId, &ab.MaxBackupsRetain, &ab.Enabled, &ab.LastBackupTime); err != nil {
+		ab, err := scanAutomaticBackup(rows)
+		if err != nil {
 			return nil, err
 		}
-		ab.MinTime, _ = ParseClock(minTimeStr)
-		ab.MaxTime, _ = ParseClock(maxTimeStr)
 		backups = append(backups, ab)
 	}
 	return backups, nil
@@ -383,54 +616,41 @@ func GetAutomaticBackupsByNfsMountID(ctx context.Context, nfsMountID int) ([]Aut
 
 func GetAutomaticBackupByName(ctx context.Context, vmName string) (*AutomaticBackup, error) {
 	const query = `
-	SELECT id, vm_name, frequency_days, min_time, max_time, nfsmount_id, 
-	       max_backups_retain, enabled, last_backup_time
+	SELECT ` + automaticBackupColumns + `
 	FROM automatic_backup
 	WHERE vm_name = ?;
 	`
 	row := DB.QueryRowContext(ctx, query, vmName)
-	var ab AutomaticBackup
-	var minTimeStr, maxTimeStr string
-	err := row.Scan(&ab.Id, &ab.VmName, &ab.FrequencyDays, &minTimeStr, &maxTimeStr,
-		&ab.NfsMountId, &ab.MaxBackupsRetain, &ab.Enabled, &ab.LastBackupTime)
+	ab, err := scanAutomaticBackup(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-	ab.MinTime, _ = ParseClock(minTimeStr)
-	ab.MaxTime, _ = ParseClock(maxTimeStr)
 	return &ab, nil
 }
 
 func GetAutomaticBackupById(ctx context.Context, id int) (*AutomaticBackup, error) {
 	const query = `
-	SELECT id, vm_name, frequency_days, min_time, max_time, nfsmount_id, 
-	       max_backups_retain, enabled, last_backup_time
+	SELECT ` + automaticBackupColumns + `
 	FROM automatic_backup
 	WHERE id = ?;
 	`
 	row := DB.QueryRowContext(ctx, query, id)
-	var ab AutomaticBackup
-	var minTimeStr, maxTimeStr string
-	err := row.Scan(&ab.Id, &ab.VmName, &ab.FrequencyDays, &minTimeStr, &maxTimeStr,
-		&ab.NfsMountId, &ab.MaxBackupsRetain, &ab.Enabled, &ab.LastBackupTime)
+	ab, err := scanAutomaticBackup(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-	ab.MinTime, _ = ParseClock(minTimeStr)
-	ab.MaxTime, _ = ParseClock(maxTimeStr)
 	return &ab, nil
 }
 
 func GetEnabledAutomaticBackups(ctx context.Context) ([]AutomaticBackup, error) {
 	const query = `
-	SELECT id, vm_name, frequency_days, min_time, max_time, nfsmount_id, 
-	       max_backups_retain, enabled, last_backup_time
+	SELECT ` + automaticBackupColumns + `
 	FROM automatic_backup
 	WHERE enabled = 1;
 	`
@@ -442,14 +662,10 @@ func GetEnabledAutomaticBackups(ctx context.Context) ([]AutomaticBackup, error)
 
 	var backups []AutomaticBackup
 	for rows.Next() {
-		var ab AutomaticBackup
-		var minTimeStr, maxTimeStr string
-		if err := rows.Scan(&ab.Id, &ab.VmName, &ab.FrequencyDays, &minTimeStr, &maxTimeStr,
-			&ab.NfsMountId, &ab.MaxBackupsRetain, &ab.Enabled, &ab.LastBackupTime); err != nil {
+		ab, err := scanAutomaticBackup(rows)
+		if err != nil {
 			return nil, err
 		}
-		ab.MinTime, _ = ParseClock(minTimeStr)
-		ab.MaxTime, _ = ParseClock(maxTimeStr)
 		backups = append(backups, ab)
 	}
 	return backups, nil
@@ -457,8 +673,7 @@ func GetEnabledAutomaticBackups(ctx context.Context) ([]AutomaticBackup, error)
 
 func GetEnabledAutomaticBackupsAt(ctx context.Context, clock Clock) ([]AutomaticBackup, error) {
 	const query = `
-	SELECT id, vm_name, frequency_days, min_time, max_time, nfsmount_id, 
-		   max_backups_retain, enabled, last_backup_time
+	SELECT ` + automaticBackupColumns + `
 	FROM automatic_backup
 	WHERE enabled = 1
 	  AND (
@@ -475,14 +690,10 @@ func GetEnabledAutomaticBackupsAt(ctx context.Context, clock Clock) ([]Automatic
 
 	var backups []AutomaticBackup
 	for rows.Next() {
-		var ab AutomaticBackup
-		var minTimeStr, maxTimeStr string
-		if err := rows.Scan(&ab.Id, &ab.VmName, &ab.FrequencyDays, &minTimeStr, &maxTimeStr,
-			&ab.NfsMountId, &ab.MaxBackupsRetain, &ab.Enabled, &ab.LastBackupTime); err != nil {
+		ab, err := scanAutomaticBackup(rows)
+		if err != nil {
 			return nil, err
 		}
-		ab.MinTime, _ = ParseClock(minTimeStr)
-		ab.MaxTime, _ = ParseClock(maxTimeStr)
 		backups = append(backups, ab)
 	}
 	return backups, nil
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestVirshBackupChain(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateTableBackups(ctx); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	// running it twice must not fail on the column migrations
+	if err := CreateTableBackups(ctx); err != nil {
+		t.Fatalf("create table again: %v", err)
+	}
+
+	full := &VirshBackup{Name: "web", Path: "/bak/backup-1/web.qcow2", NfsId: 1, Checkpoint: "bak-1"}
+	if err := InsertVirshBackup(ctx, full); err != nil {
+		t.Fatalf("insert full: %v", err)
+	}
+	inc1 := &VirshBackup{Name: "web", Path: "/bak/backup-2/web.qcow2", NfsId: 1, Checkpoint: "bak-2", ParentId: &full.Id, Incremental: true}
+	if err := InsertVirshBackup(ctx, inc1); err != nil {
+		t.Fatalf("insert inc1: %v", err)
+	}
+	inc2 := &VirshBackup{Name: "web", Path: "/bak/backup-3/web.qcow2", NfsId: 1, Checkpoint: "bak-3", ParentId: &inc1.Id, Incremental: true}
+	if err := InsertVirshBackup(ctx, inc2); err != nil {
+		t.Fatalf("insert inc2: %v", err)
+	}
+
+	chain, err := GetVirshBackupChain(ctx, inc2.Id)
+	if err != nil {
+		t.Fatalf("get chain: %v", err)
+	}
+	if len(chain) != 3 || chain[0].Id != full.Id || chain[1].Id != inc1.Id || chain[2].Id != inc2.Id {
+		t.Fatalf("unexpected chain %+v", chain)
+	}
+
+	children, err := GetVirshBackupChildren(ctx, full.Id)
+	if err != nil {
+		t.Fatalf("get children: %v", err)
+	}
+	if len(children) != 1 || children[0].Id != inc1.Id {
+		t.Fatalf("unexpected children %+v", children)
+	}
+
+	latest, err := GetLatestCheckpointBackup(ctx, "web")
+	if err != nil {
+		t.Fatalf("latest checkpoint backup: %v", err)
+	}
+	if latest == nil || latest.Id != inc2.Id {
+		t.Fatalf("latest = %+v, want id %d", latest, inc2.Id)
+	}
+
+	if err := ClearVirshBackupCheckpoints(ctx, "web"); err != nil {
+		t.Fatalf("clear checkpoints: %v", err)
+	}
+	latest, err = GetLatestCheckpointBackup(ctx, "web")
+	if err != nil || latest != nil {
+		t.Fatalf("expected no checkpoint backup after clearing, got %+v (%v)", latest, err)
+	}
+
+	if err := DeleteVirshBackupById(ctx, full.Id); err != nil {
+		t.Fatalf("delete full: %v", err)
+	}
+	if _, err := GetVirshBackupChain(ctx, inc2.Id); err == nil {
+		t.Fatalf("expected error for a chain with a missing full backup")
+	}
+}
@@ -132,6 +132,12 @@ func UpdateVMDiskSize(ctx context.Context, id int, sizeGB int64) error {
 	return err
 }
 
+// UpdateVMDiskLocation records where a disk lives after it moved to another share.
+func UpdateVMDiskLocation(ctx context.Context, id int, nfsID int, diskPath, folderPath string) error {
+	_, err := DB.ExecContext(ctx, `UPDATE vm_disks SET nfs_id = ?, disk_path = ?, folder_path = ? WHERE id = ?;`, nfsID, diskPath, folderPath, id)
+	return err
+}
+
 func ReserveVMDiskAttachment(ctx context.Context, id int, vmName, machineName string) (bool, error) {
 	query := `
 	UPDATE vm_disks
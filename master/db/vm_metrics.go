@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+)
+
+// vm metrics are kept per minute for vmMetricsRawRetention, then averaged into
+// one row per hour that lives as long as the host snapshots
+const (
+	VMMetricsMinute = "minute"
+	VMMetricsHour   = "hour"
+
+	vmMetricsRawRetention = 48 * time.Hour
+)
+
+type VMMetricsSnapshot struct {
+	ID          int
+	VmName      string
+	MachineName string
+	CapturedAt  time.Time
+	Resolution  string
+	Stats       *grpcVirsh.VmStats
+}
+
+func CreateVMMetricsTable(ctx context.Context) error {
+	const createStmt = `
+	CREATE TABLE IF NOT EXISTS vm_metrics (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		vm_name TEXT NOT NULL,
+		machine_name TEXT NOT NULL,
+		captured_at DATETIME NOT NULL,
+		resolution TEXT NOT NULL DEFAULT 'minute',
+		payload TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_vm_metrics_resolution_captured
+	ON vm_metrics(resolution, captured_at);
+	`
+	const indexStmt = `
+	CREATE INDEX IF NOT EXISTS idx_vm_metrics_vm_captured
+	ON vm_metrics(vm_name, captured_at);
+	`
+	return createSnapshotTable(ctx, createStmt, indexStmt)
+}
+
+// InsertVMMetrics stores one minute sample per vm of machineName.
+func InsertVMMetrics(ctx context.Context, machineName string, capturedAt time.Time, stats []*grpcVirsh.VmStats) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, s := range stats {
+		payload, err := marshalSnapshot(s)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO vm_metrics (vm_name, machine_name, captured_at, resolution, payload)
+		VALUES (?, ?, ?, ?, ?);`,
+			s.GetName(), machineName, formatSnapshotTime(capturedAt), VMMetricsMinute, payload); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func fetchVMMetrics(ctx context.Context, query string, args ...any) ([]VMMetricsSnapshot, error) {
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []VMMetricsSnapshot
+	for rows.Next() {
+		var (
+			s          VMMetricsSnapshot
+			capturedAt string
+			payload    string
+		)
+		if err := rows.Scan(&s.ID, &s.VmName, &s.MachineName, &capturedAt, &s.Resolution, &payload); err != nil {
+			return nil, err
+		}
+		if s.CapturedAt, err = parseSnapshotTime(capturedAt); err != nil {
+			return nil, err
+		}
+		stats := &grpcVirsh.VmStats{}
+		ok, err := assignSnapshot(payload, stats)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			s.Stats = stats
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// GetVMMetricsSince returns the samples of vmName since since, oldest first,
+// hourly averages where the minute samples were already folded.
+func GetVMMetricsSince(ctx context.Context, vmName string, since time.Time, numberOfRows int) ([]VMMetricsSnapshot, error) {
+	snapshots, err := fetchVMMetrics(ctx, `
+		SELECT id, vm_name, machine_name, captured_at, resolution, payload
+		FROM vm_metrics
+		WHERE vm_name = ? AND captured_at >= ?
+		ORDER BY captured_at ASC;`,
+		vmName, formatQueryTime(since))
+	if err != nil {
+		return nil, err
+	}
+	return sampleEvenly(snapshots, numberOfRows), nil
+}
+
+func DeleteVMMetrics(ctx context.Context, vmName string) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM vm_metrics WHERE vm_name = ?;`, vmName)
+	return err
+}
+
+// DownsampleVMMetrics folds the minute samples of every full hour older than
+// vmMetricsRawRetention into one averaged row and drops the hourly rows past
+// the snapshot retention.
+func DownsampleVMMetrics(ctx context.Context, now time.Time) error {
+	cutoff := now.Add(-vmMetricsRawRetention).Truncate(time.Hour)
+	raw, err := fetchVMMetrics(ctx, `
+		SELECT id, vm_name, machine_name, captured_at, resolution, payload
+		FROM vm_metrics
+		WHERE resolution = ? AND captured_at < ?
+		ORDER BY captured_at ASC;`,
+		VMMetricsMinute, formatQueryTime(cutoff))
+	if err != nil {
+		return err
+	}
+
+	type bucketKey struct {
+		vmName string
+		hour   time.Time
+	}
+	buckets := map[bucketKey][]VMMetricsSnapshot{}
+	var order []bucketKey
+	for _, s := range raw {
+		key := bucketKey{vmName: s.VmName, hour: s.CapturedAt.Truncate(time.Hour)}
+		if _, ok := buckets[key]; !ok {
+			order = append(order, key)
+		}
+		buckets[key] = append(buckets[key], s)
+	}
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, key := range order {
+		samples := buckets[key]
+		stats := make([]*grpcVirsh.VmStats, 0, len(samples))
+		for _, s := range samples {
+			if s.Stats != nil {
+				stats = append(stats, s.Stats)
+			}
+		}
+		payload, err := marshalSnapshot(averageVMStats(key.vmName, stats))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO vm_metrics (vm_name, machine_name, captured_at, resolution, payload)
+		VALUES (?, ?, ?, ?, ?);`,
+			key.vmName, samples[len(samples)-1].MachineName, formatSnapshotTime(key.hour), VMMetricsHour, payload); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM vm_metrics WHERE resolution = ? AND captured_at < ?;`,
+		VMMetricsMinute, formatQueryTime(cutoff)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM vm_metrics WHERE captured_at < ?;`,
+		formatQueryTime(now.AddDate(0, -snapshotRetentionMonths, 0))); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// averageVMStats averages the usage of the samples, disks and interfaces are
+// matched by device. Totals and the vcpu count are taken from the last sample.
+func averageVMStats(vmName string, samples []*grpcVirsh.VmStats) *grpcVirsh.VmStats {
+	avg := &grpcVirsh.VmStats{Name: vmName}
+	if len(samples) == 0 {
+		return avg
+	}
+	n := float64(len(samples))
+
+	disks := map[string]*grpcVirsh.VmDiskStats{}
+	ifaces := map[string]*grpcVirsh.VmInterfaceStats{}
+	var memTotal, memUsed, memRSS float64
+	for _, s := range samples {
+		avg.CpuPercent += s.CpuPercent / n
+		avg.Vcpus = s.Vcpus
+		memTotal += float64(s.MemTotalKib) / n
+		memUsed += float64(s.MemUsedKib) / n
+		memRSS += float64(s.MemRssKib) / n
+
+		for _, d := range s.Disks {
+			acc, ok := disks[d.Device]
+			if !ok {
+				acc = &grpcVirsh.VmDiskStats{Device: d.Device}
+				disks[d.Device] = acc
+				avg.Disks = append(avg.Disks, acc)
+			}
+			acc.Path = d.Path
+			acc.ReadIops += d.ReadIops / n
+			acc.WriteIops += d.WriteIops / n
+			acc.ReadBytesPerSec += d.ReadBytesPerSec / n
+			acc.WriteBytesPerSec += d.WriteBytesPerSec / n
+			acc.ReadBytes = d.ReadBytes
+			acc.WriteBytes = d.WriteBytes
+		}
+		for _, i := range s.Interfaces {
+			acc, ok := ifaces[i.Device]
+			if !ok {
+				acc = &grpcVirsh.VmInterfaceStats{Device: i.Device}
+				ifaces[i.Device] = acc
+				avg.Interfaces = append(avg.Interfaces, acc)
+			}
+			acc.Mac = i.Mac
+			acc.RxBytesPerSec += i.RxBytesPerSec / n
+			acc.TxBytesPerSec += i.TxBytesPerSec / n
+			acc.RxPacketsPerSec += i.RxPacketsPerSec / n
+			acc.TxPacketsPerSec += i.TxPacketsPerSec / n
+			acc.RxBytes = i.RxBytes
+			acc.TxBytes = i.TxBytes
+		}
+	}
+	avg.MemTotalKib = uint64(memTotal)
+	avg.MemUsedKib = uint64(memUsed)
+	avg.MemRssKib = uint64(memRSS)
+	return avg
+}
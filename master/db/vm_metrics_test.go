@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+)
+
+func TestVMMetricsDownsample(t *testing.T) {
+	ctx := context.Background()
+	originalDB := DB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	var err error
+	DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer DB.Close()
+	DB.SetMaxOpenConns(1)
+
+	if err := CreateVMMetricsTable(ctx); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	now := time.Date(2025, 3, 10, 12, 30, 0, 0, time.UTC)
+	old := now.Add(-72 * time.Hour).Truncate(time.Hour)
+	sample := func(cpu float64, readIops float64, rxBytes uint64) []*grpcVirsh.VmStats {
+		return []*grpcVirsh.VmStats{{
+			Name:       "web",
+			CpuPercent: cpu,
+			Vcpus:      2,
+			MemUsedKib: 1024,
+			Disks:      []*grpcVirsh.VmDiskStats{{Device: "vda", ReadIops: readIops}},
+			Interfaces: []*grpcVirsh.VmInterfaceStats{{Device: "vnet0", RxBytes: rxBytes}},
+		}}
+	}
+	if err := InsertVMMetrics(ctx, "node1", old.Add(time.Minute), sample(10, 100, 5)); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := InsertVMMetrics(ctx, "node1", old.Add(2*time.Minute), sample(30, 300, 9)); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := InsertVMMetrics(ctx, "node1", now.Add(-time.Minute), sample(50, 0, 20)); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if err := DownsampleVMMetrics(ctx, now); err != nil {
+		t.Fatalf("downsample: %v", err)
+	}
+	snaps, err := GetVMMetricsSince(ctx, "web", time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("get metrics: %v", err)
+	}
+	if len(snaps) != 2 {
+		t.Fatalf("expected the old samples folded into one hour row plus the recent one, got %d rows", len(snaps))
+	}
+	hour := snaps[0]
+	if hour.Resolution != VMMetricsHour || !hour.CapturedAt.Equal(old) {
+		t.Fatalf("unexpected hourly row: %+v", hour)
+	}
+	if hour.Stats.CpuPercent != 20 || hour.Stats.Disks[0].ReadIops != 200 || hour.Stats.Interfaces[0].RxBytes != 9 {
+		t.Fatalf("unexpected hourly averages: %+v", hour.Stats)
+	}
+	if snaps[1].Resolution != VMMetricsMinute || snaps[1].Stats.CpuPercent != 50 {
+		t.Fatalf("recent sample should be kept as is, got %+v", snaps[1])
+	}
+
+	// a second pass has nothing left to fold
+	if err := DownsampleVMMetrics(ctx, now); err != nil {
+		t.Fatalf("downsample again: %v", err)
+	}
+	if snaps, _ = GetVMMetricsSince(ctx, "web", time.Time{}, 0); len(snaps) != 2 {
+		t.Fatalf("expected 2 rows after the second pass, got %d", len(snaps))
+	}
+
+	if err := DeleteVMMetrics(ctx, "web"); err != nil {
+		t.Fatalf("delete metrics: %v", err)
+	}
+	if snaps, _ = GetVMMetricsSince(ctx, "web", time.Time{}, 0); len(snaps) != 0 {
+		t.Fatalf("expected no rows after delete, got %d", len(snaps))
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+)
+
+func CreateVMTagsTable(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS vm_tags (
+		vm_name TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (vm_name, tag)
+	);
+	CREATE INDEX IF NOT EXISTS idx_vm_tags_tag ON vm_tags(tag);
+	`
+	_, err := DB.ExecContext(ctx, query)
+	return err
+}
+
+func AddVMTag(ctx context.Context, vmName, tag string) error {
+	_, err := DB.ExecContext(ctx, `INSERT OR IGNORE INTO vm_tags (vm_name, tag) VALUES (?, ?);`, vmName, tag)
+	return err
+}
+
+func RemoveVMTag(ctx context.Context, vmName, tag string) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM vm_tags WHERE vm_name = ? AND tag = ?;`, vmName, tag)
+	return err
+}
+
+func RemoveVMTags(ctx context.Context, vmName string) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM vm_tags WHERE vm_name = ?;`, vmName)
+	return err
+}
+
+func GetVMTags(ctx context.Context, vmName string) ([]string, error) {
+	return queryStrings(ctx, `SELECT tag FROM vm_tags WHERE vm_name = ? ORDER BY tag;`, vmName)
+}
+
+func GetVMsByTag(ctx context.Context, tag string) ([]string, error) {
+	return queryStrings(ctx, `SELECT vm_name FROM vm_tags WHERE tag = ? ORDER BY vm_name;`, tag)
+}
+
+// GetAllVMTags maps every tag to its vms.
+func GetAllVMTags(ctx context.Context) (map[string][]string, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT tag, vm_name FROM vm_tags ORDER BY tag, vm_name;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := map[string][]string{}
+	for rows.Next() {
+		var tag, vmName string
+		if err := rows.Scan(&tag, &vmName); err != nil {
+			return nil, err
+		}
+		tags[tag] = append(tags[tag], vmName)
+	}
+	return tags, rows.Err()
+}
+
+func queryStrings(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []string{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -2,6 +2,7 @@ package env512
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -27,6 +28,15 @@ var (
 	GoAccessGeoIPEdition    string
 	VapidPublicKey          string
 	VapidPrivateKey         string
+	NPMLogin                bool
+	NPMDefaultRole          string
+	NPMServiceEmail         string
+	NPMServicePassword      string
+	HAGracePeriod           time.Duration
+	CPUOvercommit           float64
+	MemoryOvercommit        float64
+	// proxies whose X-Forwarded-For is believed, nobody else's is
+	TrustedProxies []*net.IPNet
 )
 
 func Setup() error {
@@ -69,6 +79,24 @@ func Setup() error {
 	}
 	StartupTimeOverLoad = parsedStartupTimeOverLoad
 
+	parsedHAGracePeriod, err := parseDurationEnv("HA_GRACE_PERIOD", time.Minute)
+	if err != nil {
+		return err
+	}
+	if parsedHAGracePeriod < 30*time.Second {
+		return fmt.Errorf("HA_GRACE_PERIOD must be at least 30s so slave heartbeats can be seen")
+	}
+	HAGracePeriod = parsedHAGracePeriod
+
+	CPUOvercommit, err = parseRatioEnv("CPU_OVERCOMMIT", 4)
+	if err != nil {
+		return err
+	}
+	MemoryOvercommit, err = parseRatioEnv("MEMORY_OVERCOMMIT", 1)
+	if err != nil {
+		return err
+	}
+
 	if MAIN_LINK == "" {
 		panic("needs MAIN_LINK")
 	}
@@ -114,9 +142,46 @@ func Setup() error {
 	VapidPublicKey = strings.TrimSpace(os.Getenv("VAPID_PUBLIC_KEY"))
 	VapidPrivateKey = strings.TrimSpace(os.Getenv("VAPID_PRIVATE_KEY"))
 
+	// nginx proxy manager accounts can still log in unless NPM_LOGIN=false,
+	// the service account gives local users a token for the npm routes
+	NPMLogin = !strings.EqualFold(strings.TrimSpace(os.Getenv("NPM_LOGIN")), "false")
+	NPMDefaultRole = strings.TrimSpace(os.Getenv("NPM_DEFAULT_ROLE"))
+	if NPMDefaultRole == "" {
+		NPMDefaultRole = "viewer"
+	}
+	NPMServiceEmail = strings.TrimSpace(os.Getenv("NPM_EMAIL"))
+	NPMServicePassword = os.Getenv("NPM_PASSWORD")
+
+	TrustedProxies, err = parseNetworksEnv("TRUSTED_PROXIES")
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// parseNetworksEnv reads a comma separated list of addresses and CIDRs, a
+// plain address is a single host network.
+func parseNetworksEnv(key string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range splitAndTrimCSV(os.Getenv(key)) {
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q, expected an address or a CIDR", key, entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
 func splitAndTrimCSV(val string) []string {
 	if val == "" {
 		return nil
@@ -132,6 +197,23 @@ func splitAndTrimCSV(val string) []string {
 	return out
 }
 
+// parseRatioEnv reads an overcommit ratio, 1 means no overcommit.
+func parseRatioEnv(key string, defaultValue float64) (float64, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return defaultValue, nil
+	}
+
+	ratio, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if ratio < 1 {
+		return 0, fmt.Errorf("%s must be at least 1", key)
+	}
+	return ratio, nil
+}
+
 func parseDurationEnv(key string, defaultValue time.Duration) (time.Duration, error) {
 	raw := strings.TrimSpace(os.Getenv(key))
 	if raw == "" {
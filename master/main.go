@@ -6,6 +6,7 @@ import (
 	"512SvMan/env512"
 	"512SvMan/info"
 	"512SvMan/logs512"
+	"512SvMan/pki"
 	"512SvMan/protocol"
 	"512SvMan/services"
 	"512SvMan/wireguard"
@@ -123,6 +124,13 @@ func newSlave(addr, machineName string, conn *grpc.ClientConn) error {
 		logger.Infof("newSlave finished for %s (%s). In-flight=%d", machineName, addr, atomic.LoadInt32(&newSlaveCount))
 	}()
 
+	// VMs that HA restarted elsewhere while this slave was gone must not come
+	// back here, this runs before anything on the slave is started
+	haService := services.HAService{}
+	if err := haService.ReclaimHost(context.Background(), machineName); err != nil {
+		logger.Errorf("HA reclaim failed for %s: %v", machineName, err)
+	}
+
 	btrfsService := services.BTRFSService{}
 	err := btrfsService.AutoMountRaid(machineName)
 	if err != nil {
@@ -388,6 +396,11 @@ func main() {
 		log.Fatalf("create vm_disks table: %v", err)
 	}
 
+	err = db.CreateGoldenImageTables(ctx)
+	if err != nil {
+		log.Fatalf("create golden image tables: %v", err)
+	}
+
 	err = db.CreateVMXMLTemplatesTable(ctx)
 	if err != nil {
 		log.Fatalf("create vm_xml_templates table: %v", err)
@@ -422,6 +435,10 @@ func main() {
 	if err != nil {
 		log.Fatalf("create network snapshots table: %v", err)
 	}
+	err = db.CreateVMMetricsTable(ctx)
+	if err != nil {
+		log.Fatalf("create vm metrics table: %v", err)
+	}
 
 	err = db.CreateStreamDailyMetricsTable(ctx)
 	if err != nil {
@@ -435,10 +452,30 @@ func main() {
 	infoCollector := &services.InfoService{}
 	go infoCollector.GetSlaveData(ctx)
 
+	err = db.CreateJobsTable(ctx)
+	if err != nil {
+		log.Fatalf("create jobs table: %v", err)
+	}
+	if err := services.RecoverJobs(ctx); err != nil {
+		log.Fatalf("recover jobs: %v", err)
+	}
+
 	err = db.CreateTableBackups(ctx)
 	if err != nil {
 		log.Fatalf("create backups table: %v", err)
 	}
+	err = db.CreateBackupVerificationTable(ctx)
+	if err != nil {
+		log.Fatalf("create backup verification table: %v", err)
+	}
+	err = db.CreateBackupKeysTable(ctx)
+	if err != nil {
+		log.Fatalf("create backup keys table: %v", err)
+	}
+	err = db.CreateBackupRepositoryTables(ctx)
+	if err != nil {
+		log.Fatalf("create backup repository tables: %v", err)
+	}
 
 	err = db.CreateTableAutoStart(ctx)
 	if err != nil {
@@ -475,6 +512,56 @@ func main() {
 		log.Fatalf("create notes table: %v", err)
 	}
 
+	err = db.CreatePKITables(ctx)
+	if err != nil {
+		log.Fatalf("create pki tables: %v", err)
+	}
+	err = db.CreateNodesTable(ctx)
+	if err != nil {
+		log.Fatalf("create nodes table: %v", err)
+	}
+	err = db.CreateUserTables(ctx)
+	if err != nil {
+		log.Fatalf("create user tables: %v", err)
+	}
+	err = db.CreateAPITokensTable(ctx)
+	if err != nil {
+		log.Fatalf("create api tokens table: %v", err)
+	}
+	err = db.CreateHATables(ctx)
+	if err != nil {
+		log.Fatalf("create ha tables: %v", err)
+	}
+	err = db.CreateMaintenanceTables(ctx)
+	if err != nil {
+		log.Fatalf("create maintenance tables: %v", err)
+	}
+	err = db.CreatePlacementGroupTables(ctx)
+	if err != nil {
+		log.Fatalf("create placement group tables: %v", err)
+	}
+	err = db.CreateBalancerTable(ctx)
+	if err != nil {
+		log.Fatalf("create balancer table: %v", err)
+	}
+	err = db.CreateVMTagsTable(ctx)
+	if err != nil {
+		log.Fatalf("create vm tags table: %v", err)
+	}
+	err = db.CreatePowerScheduleTables(ctx)
+	if err != nil {
+		log.Fatalf("create power schedule tables: %v", err)
+	}
+	loginService := services.LoginService{}
+	if err := loginService.EnsureAdmin(ctx); err != nil {
+		log.Fatalf("create first admin: %v", err)
+	}
+	go loginService.CleanupSessions(ctx)
+	if err := pki.Setup(ctx); err != nil {
+		log.Fatalf("setup cluster CA: %v", err)
+	}
+	go pki.MaintainMasterCertificate(ctx)
+
 	db.StartSQLiteBackupLoop(ctx)
 
 	if err := setupFrontendContainer(); err != nil {
@@ -492,8 +579,17 @@ func main() {
 	smartDiskService := services.SmartDiskService{}
 	nfsService := services.NFSService{}
 	go nfsService.MaintainNFS()
+	haService := services.HAService{}
+	go haService.Monitor(ctx)
+	balancerService := services.BalancerService{}
+	go balancerService.Maintain(ctx)
+	powerScheduleService := services.PowerScheduleService{}
+	go powerScheduleService.Run(ctx)
 
 	virshService.LoopAutomaticBaks(context.Background())
+	go virshService.LoopBackupVerification(ctx)
+	go virshService.LoopBackupReplication(ctx)
+	go virshService.LoopFileRestoreSessions(ctx)
 	smartDiskService.DoAutomaticTest()
 	info.LoopNots()
 	go SpaService.Maintain(ctx, 30*time.Second)
@@ -0,0 +1,417 @@
+package pki
+
+import (
+	"512SvMan/db"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+// MasterName is the name on the master certificate, slaves verify it instead of
+// an ip so the master can be reached on any address.
+const MasterName = "512svman-master"
+
+const (
+	pkiDir         = "pki"
+	caCertFile     = "ca.crt"
+	caKeyFile      = "ca.key"
+	masterCertFile = "master.crt"
+	masterKeyFile  = "master.key"
+
+	caValidity   = 10 * 365 * 24 * time.Hour
+	certValidity = 365 * 24 * time.Hour
+	// certificates are renewed once less than this is left
+	RenewBefore = 30 * 24 * time.Hour
+)
+
+var (
+	mu         sync.RWMutex
+	caCert     *x509.Certificate
+	caKey      crypto.Signer
+	caPEM      []byte
+	caPool     *x509.CertPool
+	masterCert *tls.Certificate
+	revoked    = map[string]struct{}{}
+)
+
+// Setup loads the cluster CA and the master certificate from ./pki, creating
+// them on the first start, and loads the revoked serials from the db.
+func Setup(ctx context.Context) error {
+	if err := os.MkdirAll(pkiDir, 0700); err != nil {
+		return fmt.Errorf("create pki directory: %w", err)
+	}
+	if err := loadOrCreateCA(); err != nil {
+		return err
+	}
+	if err := loadOrIssueMaster(); err != nil {
+		return err
+	}
+
+	serials, err := db.GetRevokedCertificateSerials(ctx)
+	if err != nil {
+		return fmt.Errorf("load revoked certificates: %w", err)
+	}
+	mu.Lock()
+	for _, serial := range serials {
+		revoked[serial] = struct{}{}
+	}
+	mu.Unlock()
+	return nil
+}
+
+func loadOrCreateCA() error {
+	certPath := filepath.Join(pkiDir, caCertFile)
+	keyPath := filepath.Join(pkiDir, caKeyFile)
+
+	cert, key, err := loadPair(certPath, keyPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("load cluster CA: %w", err)
+	}
+	if cert == nil {
+		newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return fmt.Errorf("generate CA key: %w", err)
+		}
+		key = newKey
+		serial, err := newSerial()
+		if err != nil {
+			return err
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:          serial,
+			Subject:               pkix.Name{CommonName: "512SvMan cluster CA"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(caValidity),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+			MaxPathLenZero:        true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, newKey.Public(), newKey)
+		if err != nil {
+			return fmt.Errorf("create CA certificate: %w", err)
+		}
+		if err := savePair(certPath, keyPath, der, newKey); err != nil {
+			return err
+		}
+		if cert, err = x509.ParseCertificate(der); err != nil {
+			return err
+		}
+		logger.Info("created cluster CA", "fingerprint", Fingerprint(cert))
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	mu.Lock()
+	caCert = cert
+	caKey = key
+	caPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	caPool = pool
+	mu.Unlock()
+	return nil
+}
+
+func loadOrIssueMaster() error {
+	cert, key, err := loadPair(filepath.Join(pkiDir, masterCertFile), filepath.Join(pkiDir, masterKeyFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("load master certificate: %w", err)
+	}
+	if cert == nil || time.Until(cert.NotAfter) < RenewBefore {
+		_, err := RotateMasterCertificate()
+		return err
+	}
+	setMasterCertificate(cert, key)
+	return nil
+}
+
+// RotateMasterCertificate issues a new master certificate. Open connections keep
+// the old one, new handshakes pick up the new one.
+func RotateMasterCertificate() (*x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate master key: %w", err)
+	}
+	der, err := sign(MasterName, key.Public())
+	if err != nil {
+		return nil, err
+	}
+	if err := savePair(filepath.Join(pkiDir, masterCertFile), filepath.Join(pkiDir, masterKeyFile), der, key); err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	setMasterCertificate(cert, key)
+	logger.Info("issued master certificate", "serial", Serial(cert), "notAfter", cert.NotAfter.Format(time.RFC3339))
+	return cert, nil
+}
+
+func setMasterCertificate(cert *x509.Certificate, key crypto.Signer) {
+	mu.Lock()
+	defer mu.Unlock()
+	masterCert = &tls.Certificate{
+		Certificate: [][]byte{cert.Raw, caCert.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}
+}
+
+// MaintainMasterCertificate renews the master certificate before it expires.
+func MaintainMasterCertificate(ctx context.Context) {
+	ticker := time.NewTicker(12 * time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if time.Until(MasterCertificate().NotAfter) >= RenewBefore {
+			continue
+		}
+		if _, err := RotateMasterCertificate(); err != nil {
+			logger.Errorf("renew master certificate: %v", err)
+		}
+	}
+}
+
+// CheckNodeCSR validates a slave CSR for machineName without signing it.
+func CheckNodeCSR(csrPEM []byte, machineName string) (*x509.CertificateRequest, error) {
+	machineName = strings.TrimSpace(machineName)
+	if machineName == "" {
+		return nil, fmt.Errorf("machine name is required")
+	}
+	if machineName == MasterName {
+		return nil, fmt.Errorf("machine name %s is reserved", MasterName)
+	}
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("csr is not a PEM certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse csr: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("csr signature: %w", err)
+	}
+	return csr, nil
+}
+
+// SignNodeCSR signs a slave CSR. The name on the certificate is always
+// machineName, whatever the CSR asked for.
+func SignNodeCSR(csrPEM []byte, machineName string) ([]byte, *x509.Certificate, error) {
+	csr, err := CheckNodeCSR(csrPEM, machineName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, err := sign(strings.TrimSpace(machineName), csr.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), cert, nil
+}
+
+// node and master certificates are used both to serve and to dial
+func sign(name string, pub crypto.PublicKey) ([]byte, error) {
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	if caCert == nil {
+		return nil, fmt.Errorf("cluster CA is not loaded")
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, pub, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("sign certificate for %s: %w", name, err)
+	}
+	return der, nil
+}
+
+func CAPEM() []byte {
+	mu.RLock()
+	defer mu.RUnlock()
+	return caPEM
+}
+
+// CAFingerprint is what slaves pin in CA_CERT_HASH before they trust the master.
+func CAFingerprint() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return Fingerprint(caCert)
+}
+
+func MasterCertificate() *x509.Certificate {
+	mu.RLock()
+	defer mu.RUnlock()
+	return masterCert.Leaf
+}
+
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func Serial(cert *x509.Certificate) string {
+	return hex.EncodeToString(cert.SerialNumber.Bytes())
+}
+
+func MarkRevoked(serial string) {
+	mu.Lock()
+	defer mu.Unlock()
+	revoked[serial] = struct{}{}
+}
+
+func IsRevoked(serial string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := revoked[serial]
+	return ok
+}
+
+func checkNotRevoked(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+		return fmt.Errorf("no verified certificate chain")
+	}
+	leaf := verifiedChains[0][0]
+	if IsRevoked(Serial(leaf)) {
+		return fmt.Errorf("certificate %s of %s is revoked", Serial(leaf), leaf.Subject.CommonName)
+	}
+	return nil
+}
+
+func getMasterCertificate() (*tls.Certificate, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if masterCert == nil {
+		return nil, fmt.Errorf("master certificate is not loaded")
+	}
+	return masterCert, nil
+}
+
+// ServerTLSConfig is used by the master gRPC server, every client must present
+// a non revoked certificate signed by the cluster CA.
+func ServerTLSConfig() *tls.Config {
+	mu.RLock()
+	pool := caPool
+	mu.RUnlock()
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return getMasterCertificate()
+		},
+		VerifyPeerCertificate: checkNotRevoked,
+	}
+}
+
+// EnrollTLSConfig is used by the enrollment server, slaves have no certificate
+// yet and check the master against the CA hash they were given.
+func EnrollTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return getMasterCertificate()
+		},
+	}
+}
+
+// ClientTLSConfig is used to dial a slave, its certificate must carry machineName.
+func ClientTLSConfig(machineName string) *tls.Config {
+	mu.RLock()
+	pool := caPool
+	mu.RUnlock()
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		RootCAs:    pool,
+		ServerName: machineName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return getMasterCertificate()
+		},
+		VerifyPeerCertificate: checkNotRevoked,
+	}
+}
+
+func newSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial: %w", err)
+	}
+	return serial, nil
+}
+
+func loadPair(certPath, keyPath string) (*x509.Certificate, crypto.Signer, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a signing key", keyPath)
+	}
+	return cert, key, nil
+}
+
+func savePair(certPath, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("marshal key: %w", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return fmt.Errorf("write %s: %w", keyPath, err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return fmt.Errorf("write %s: %w", certPath, err)
+	}
+	return nil
+}
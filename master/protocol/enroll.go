@@ -0,0 +1,171 @@
+package protocol
+
+import (
+	"512SvMan/db"
+	"512SvMan/pki"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	pb "github.com/Maruqes/512SvMan/api/proto/protocol"
+	"github.com/Maruqes/512SvMan/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// slaves without a certificate only reach this port, it serves nothing but Enroll
+const enrollPort = "50053"
+
+// HashJoinToken is how join tokens are stored and looked up.
+func HashJoinToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueNodeCertificate signs csr for machineName and records the certificate.
+func issueNodeCertificate(ctx context.Context, machineName string, csr []byte) (*pb.CertificateResponse, string, error) {
+	certPEM, cert, err := pki.SignNodeCSR(csr, machineName)
+	if err != nil {
+		return nil, "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	err = db.AddNodeCertificate(ctx, db.NodeCertificate{
+		Serial:      pki.Serial(cert),
+		MachineName: machineName,
+		Fingerprint: pki.Fingerprint(cert),
+		NotBefore:   cert.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:    cert.NotAfter.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, "", status.Errorf(codes.Internal, "record certificate: %v", err)
+	}
+	logger.Info("issued node certificate", "machine", machineName, "serial", pki.Serial(cert))
+	return &pb.CertificateResponse{Certificate: certPEM, CaCertificate: pki.CAPEM()}, pki.Serial(cert), nil
+}
+
+// peerCertificate returns the verified certificate the caller connected with.
+func peerCertificate(ctx context.Context) (*x509.Certificate, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "no peer information")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "no verified client certificate")
+	}
+	return tlsInfo.State.VerifiedChains[0][0], nil
+}
+
+// a certificate revoked after the handshake would keep its connection open,
+// so every call checks it again. Slaves not approved yet can only talk to
+// ProtocolService, which is where they wait for approval.
+func checkPeer(ctx context.Context, fullMethod string) error {
+	cert, err := peerCertificate(ctx)
+	if err != nil {
+		return err
+	}
+	if pki.IsRevoked(pki.Serial(cert)) {
+		return status.Errorf(codes.Unauthenticated, "certificate %s of %s is revoked", pki.Serial(cert), cert.Subject.CommonName)
+	}
+	if strings.HasPrefix(fullMethod, "/"+pb.ProtocolService_ServiceDesc.ServiceName+"/") {
+		return nil
+	}
+	node, err := db.GetNodeByName(ctx, cert.Subject.CommonName)
+	if err != nil {
+		return status.Errorf(codes.Internal, "get node: %v", err)
+	}
+	if node == nil || node.State != db.NodeStateApproved {
+		return status.Errorf(codes.PermissionDenied, "slave %s is not approved", cert.Subject.CommonName)
+	}
+	return nil
+}
+
+func peerUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := checkPeer(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func peerStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := checkPeer(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+type enrollServer struct {
+	pb.UnimplementedEnrollServiceServer
+}
+
+func (s *enrollServer) Enroll(ctx context.Context, req *pb.EnrollRequest) (*pb.CertificateResponse, error) {
+	machineName := strings.TrimSpace(req.GetMachineName())
+	if machineName == "" || req.GetToken() == "" || len(req.GetCsr()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "token, machineName and csr are required")
+	}
+	// a request that can't be signed must not burn the token
+	if _, err := pki.CheckNodeCSR(req.GetCsr(), machineName); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := db.ConsumeJoinToken(ctx, HashJoinToken(req.GetToken()), machineName); err != nil {
+		logger.Warnf("enrollment of %s (%s) refused: %v", machineName, req.GetAddr(), err)
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	resp, serial, err := issueNodeCertificate(ctx, machineName, req.GetCsr())
+	if err != nil {
+		return nil, err
+	}
+	// the token was bound to this machine or allowed to replace it, the old certificates go
+	serials, err := db.RevokeMachineCertificates(ctx, machineName, serial, "superseded by enrollment")
+	if err != nil {
+		logger.Errorf("revoke previous certificates of %s: %v", machineName, err)
+	}
+	for _, revokedSerial := range serials {
+		pki.MarkRevoked(revokedSerial)
+	}
+	logger.Info("slave enrolled", "machine", machineName, "addr", req.GetAddr())
+	return resp, nil
+}
+
+func listenEnroll() {
+	lis, err := net.Listen("tcp", ":"+enrollPort)
+	if err != nil {
+		log.Fatalf("listen enroll: %v", err)
+	}
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(pki.EnrollTLSConfig())))
+	pb.RegisterEnrollServiceServer(s, &enrollServer{})
+	logger.Info("Master enrollment a ouvir em :" + enrollPort)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			log.Fatalf("serve enroll: %v", err)
+		}
+	}()
+}
+
+// ReconnectSlave closes the connection to machineName and dials it again, a
+// slave whose certificate was revoked fails the handshake and stays out.
+func ReconnectSlave(machineName string) error {
+	existing := GetConnectionByMachineName(machineName)
+	if existing == nil {
+		return nil
+	}
+	removed := removeConnection(existing.Addr, existing.MachineName, existing.EntryTime)
+	if removed != nil && removed.Connection != nil {
+		if err := removed.Connection.Close(); err != nil {
+			log.Printf("error closing removed connection: %v", err)
+		}
+	}
+	if err := NewSlaveConnection(existing.Addr, existing.MachineName); err != nil {
+		return fmt.Errorf("reconnect %s: %w", machineName, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package protocol
+
+import (
+	"512SvMan/db"
+	"512SvMan/nots"
+	"512SvMan/pki"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log"
+
+	pb "github.com/Maruqes/512SvMan/api/proto/protocol"
+	"github.com/Maruqes/512SvMan/logger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// admitNode records the slave and only lets approved ones through, the others
+// keep retrying SetConnection until an admin decides.
+func admitNode(ctx context.Context, req *pb.SetConnectionRequest, cert *x509.Certificate) error {
+	hw := req.GetHardware()
+	node, created, err := db.RecordNodeSeen(ctx, db.Node{
+		MachineName: req.GetMachineName(),
+		Addr:        req.GetAddr(),
+		Fingerprint: pki.Fingerprint(cert),
+		Hostname:    hw.GetHostname(),
+		OS:          hw.GetOs(),
+		Kernel:      hw.GetKernel(),
+		CPUModel:    hw.GetCpuModel(),
+		CPUCores:    int(hw.GetCpuCores()),
+		CPUThreads:  int(hw.GetCpuThreads()),
+		MemoryMB:    hw.GetMemoryMB(),
+	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "record node: %v", err)
+	}
+	if created {
+		logger.Warnf("slave %s (%s) is waiting for approval", node.MachineName, node.Addr)
+		nots.SendGlobalNotification("New slave waiting for approval",
+			fmt.Sprintf("%s (%s) wants to join the cluster", node.MachineName, node.Addr), "/", true)
+	}
+
+	switch node.State {
+	case db.NodeStateApproved:
+		return nil
+	case db.NodeStatePending:
+		return status.Errorf(codes.FailedPrecondition, "slave %s is waiting for approval", node.MachineName)
+	default:
+		return status.Errorf(codes.PermissionDenied, "slave %s is %s", node.MachineName, node.State)
+	}
+}
+
+// DropSlave closes the connection to machineName without dialing it again.
+func DropSlave(machineName string) {
+	existing := GetConnectionByMachineName(machineName)
+	if existing == nil {
+		return
+	}
+	removed := removeConnection(existing.Addr, existing.MachineName, existing.EntryTime)
+	if removed != nil && removed.Connection != nil {
+		if err := removed.Connection.Close(); err != nil {
+			log.Printf("error closing removed connection: %v", err)
+		}
+	}
+}
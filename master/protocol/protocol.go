@@ -4,6 +4,7 @@ import (
 	"512SvMan/env512"
 	"512SvMan/extra"
 	"512SvMan/logs512"
+	"512SvMan/pki"
 	"context"
 	"fmt"
 	"log"
@@ -16,8 +17,10 @@ import (
 	pb "github.com/Maruqes/512SvMan/api/proto/protocol"
 	"github.com/Maruqes/512SvMan/logger"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 )
 
 type ConnectionsStruct struct {
@@ -137,7 +140,7 @@ func NewSlaveConnection(addr, machineName string) error {
 	defer cancel()
 
 	conn, err := grpc.DialContext(ctx, target,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(credentials.NewTLS(pki.ClientTLSConfig(machineName))),
 		grpc.WithBlock(),
 		grpc.WithKeepaliveParams(ka),
 		grpc.WithDefaultCallOptions(
@@ -195,7 +198,17 @@ type protocolServer struct {
 
 func (s *protocolServer) SetConnection(ctx context.Context, req *pb.SetConnectionRequest) (*pb.SetConnectionResponse, error) {
 	log.Printf("Master recebeu SetConnection: %s", req.GetAddr())
-	err := NewSlaveConnection(req.GetAddr(), req.GetMachineName())
+	cert, err := peerCertificate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Subject.CommonName != req.GetMachineName() {
+		return nil, status.Errorf(codes.PermissionDenied, "certificate of %s can't register as %s", cert.Subject.CommonName, req.GetMachineName())
+	}
+	if err := admitNode(ctx, req, cert); err != nil {
+		return nil, err
+	}
+	err = NewSlaveConnection(req.GetAddr(), req.GetMachineName())
 	if err != nil {
 		return &pb.SetConnectionResponse{Ok: "Erro ao conectar ao slave"}, err
 	}
@@ -207,6 +220,15 @@ func (s *protocolServer) Notify(ctx context.Context, req *pb.NotifyRequest) (*pb
 	return &pb.NotifyResponse{Ok: "OK do Master"}, nil
 }
 
+func (s *protocolServer) RenewCertificate(ctx context.Context, req *pb.RenewCertificateRequest) (*pb.CertificateResponse, error) {
+	cert, err := peerCertificate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, _, err := issueNodeCertificate(ctx, cert.Subject.CommonName, req.GetCsr())
+	return resp, err
+}
+
 func ListenGRPC(recievedNewConnectionFunction func(addr, machineName string, conn *grpc.ClientConn) error) {
 	recievedNewSlaveFunc = recievedNewConnectionFunction
 
@@ -229,8 +251,11 @@ func ListenGRPC(recievedNewConnectionFunction func(addr, machineName string, con
 	}
 
 	s := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(pki.ServerTLSConfig())),
 		grpc.KeepaliveEnforcementPolicy(enf),
 		grpc.KeepaliveParams(srvParams),
+		grpc.UnaryInterceptor(peerUnaryInterceptor),
+		grpc.StreamInterceptor(peerStreamInterceptor),
 	)
 	pb.RegisterProtocolServiceServer(s, &protocolServer{})
 	logsGrpc.RegisterLogsServeServer(s, &logs512.LogsServer{})
@@ -241,6 +266,7 @@ func ListenGRPC(recievedNewConnectionFunction func(addr, machineName string, con
 			log.Fatalf("serve: %v", err)
 		}
 	}()
+	listenEnroll()
 
 }
 
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"512SvMan/db"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+// APITokenPrefix marks personal access tokens, anything else sent as a bearer
+// token is a login session.
+const APITokenPrefix = "svm_"
+
+const (
+	defaultAPITokenDays = 90
+	maxAPITokenDays     = 3650
+)
+
+// resource types a token can be scoped to, users can never be managed with a
+// token
+var scopableResources = append(slices.Clone(GrantableResources), ResourceSystem, ResourceCluster)
+
+type APITokenService struct{}
+
+type APITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	Routes        []string `json:"routes"`
+	AllowedIPs    []string `json:"allowed_ips"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// CreatedAPIToken carries the secret, it is only shown once.
+type CreatedAPIToken struct {
+	db.APIToken
+	Token string `json:"token"`
+}
+
+func validateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required, like vms:write or docker:read")
+	}
+	out := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		resourceType, access, ok := strings.Cut(scope, ":")
+		if !ok || accessLevel(access) == 0 {
+			return nil, fmt.Errorf("invalid scope %q, expected <resource>:<read|write>", scope)
+		}
+		if resourceType != "*" && !slices.Contains(scopableResources, resourceType) {
+			return nil, fmt.Errorf("invalid scope %q, resource must be * or one of %s", scope, strings.Join(scopableResources, ", "))
+		}
+		out = append(out, scope)
+	}
+	return out, nil
+}
+
+func validateRoutes(routes []string) ([]string, error) {
+	out := make([]string, 0, len(routes))
+	for _, route := range routes {
+		route = strings.TrimSpace(route)
+		method, path, hasMethod := strings.Cut(route, " ")
+		if !hasMethod {
+			path = method
+		} else if method == "" || strings.ToUpper(method) != method {
+			return nil, fmt.Errorf("invalid route %q, expected [METHOD] /path or /prefix/*", route)
+		}
+		if !strings.HasPrefix(path, "/") || strings.Contains(strings.TrimSuffix(path, "*"), "*") {
+			return nil, fmt.Errorf("invalid route %q, expected [METHOD] /path or /prefix/*", route)
+		}
+		out = append(out, route)
+	}
+	return out, nil
+}
+
+func validateAllowedIPs(ips []string) ([]string, error) {
+	out := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		ip = strings.TrimSpace(ip)
+		if net.ParseIP(ip) == nil {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				return nil, fmt.Errorf("invalid allowed ip %q, expected an address or a CIDR", ip)
+			}
+		}
+		out = append(out, ip)
+	}
+	return out, nil
+}
+
+// Create makes a token for the principal. The token never gets more than its
+// user has, its scopes only narrow the user's permissions down.
+func (s *APITokenService) Create(ctx context.Context, principal *Principal, req APITokenRequest) (*CreatedAPIToken, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	scopes, err := validateScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := validateRoutes(req.Routes)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := validateAllowedIPs(req.AllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultAPITokenDays
+	}
+	if days < 0 || days > maxAPITokenDays {
+		return nil, fmt.Errorf("expires_in_days must be between 1 and %d", maxAPITokenDays)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+	secret := APITokenPrefix + hex.EncodeToString(raw)
+
+	token, err := db.AddAPIToken(ctx, db.APIToken{
+		UserId:     principal.User.Id,
+		Name:       name,
+		Prefix:     secret[:len(APITokenPrefix)+8],
+		Scopes:     scopes,
+		Routes:     routes,
+		AllowedIPs: ips,
+		ExpiresAt:  time.Now().Add(time.Duration(days) * 24 * time.Hour).UTC().Format(time.RFC3339),
+	}, hashSessionToken(secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save token: %w", err)
+	}
+	logger.Infof("api token %s created by %s", token.Name, principal.User.Username)
+	return &CreatedAPIToken{APIToken: *token, Token: secret}, nil
+}
+
+// List returns the principal's tokens, admins can ask for everyone's.
+func (s *APITokenService) List(ctx context.Context, principal *Principal, all bool) ([]db.APIToken, error) {
+	userID := principal.User.Id
+	if all {
+		if principal.User.Role != db.RoleAdmin {
+			return nil, fmt.Errorf("only admins can list every token")
+		}
+		userID = 0
+	}
+	tokens, err := db.GetAPITokens(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	if tokens == nil {
+		tokens = []db.APIToken{}
+	}
+	return tokens, nil
+}
+
+func (s *APITokenService) Revoke(ctx context.Context, principal *Principal, id int) error {
+	token, err := db.GetAPITokenByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+	if token == nil || (token.UserId != principal.User.Id && principal.User.Role != db.RoleAdmin) {
+		return fmt.Errorf("token %d not found", id)
+	}
+	if err := db.RevokeAPIToken(ctx, id); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	logger.Infof("api token %s revoked by %s", token.Name, principal.User.Username)
+	return nil
+}
+
+func ipAllowed(allowed []string, clientIP string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(entry); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate returns the principal of an api token, nil when the token is
+// unknown, expired, revoked, used from an address it does not allow or its
+// user is disabled.
+func (s *APITokenService) Authenticate(ctx context.Context, secret, clientIP string) (*Principal, error) {
+	token, err := db.GetActiveAPIToken(ctx, hashSessionToken(secret))
+	if err != nil || token == nil {
+		return nil, err
+	}
+	if !ipAllowed(token.AllowedIPs, clientIP) {
+		logger.Warn("api token used from a denied address", "token", token.Name, "ip", clientIP)
+		return nil, nil
+	}
+	user, err := db.GetUserByID(ctx, token.UserId)
+	if err != nil || user == nil || user.Disabled {
+		return nil, err
+	}
+	grants, err := db.GetUserGrants(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.TouchAPIToken(ctx, token.Id, clientIP); err != nil {
+		logger.Warn("failed to record api token use", "token", token.Name, "error", err)
+	}
+	return &Principal{User: *user, Grants: grants, Token: token}, nil
+}
+
+func (p *Principal) tokenAllows(resourceType, access string) bool {
+	need := accessLevel(access)
+	for _, scope := range p.Token.Scopes {
+		scopeType, scopeAccess, _ := strings.Cut(scope, ":")
+		if (scopeType == "*" && resourceType != ResourceUsers) || scopeType == resourceType {
+			if accessLevel(scopeAccess) >= need {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AllowsRoute checks the route restriction of an api token, sessions and
+// tokens without routes may use any route.
+func (p *Principal) AllowsRoute(method, path string) bool {
+	if p == nil || p.Token == nil || len(p.Token.Routes) == 0 {
+		return true
+	}
+	for _, route := range p.Token.Routes {
+		routeMethod, pattern, hasMethod := strings.Cut(route, " ")
+		if !hasMethod {
+			pattern = routeMethod
+		} else if routeMethod != method {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if strings.TrimSuffix(path, "/") == strings.TrimSuffix(pattern, "/") {
+			return true
+		}
+	}
+	return false
+}
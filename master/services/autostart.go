@@ -0,0 +1,211 @@
+package services
+
+import (
+	"512SvMan/db"
+	"512SvMan/protocol"
+	"512SvMan/virsh"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+const (
+	autoStartReadyTimeout = 5 * time.Minute
+	autoStartReadyPoll    = 5 * time.Second
+	autoStartTCPDial      = 3 * time.Second
+)
+
+type AutoStartService struct{}
+
+func (s *AutoStartService) ListGroups(ctx context.Context) ([]db.AutoStartGroup, error) {
+	return db.GetAutoStartGroups(ctx)
+}
+
+func (s *AutoStartService) GetGroup(ctx context.Context, name string) (*db.AutoStartGroup, error) {
+	group, err := db.GetAutoStartGroup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, fmt.Errorf("autostart group %s not found", name)
+	}
+	return group, nil
+}
+
+func (s *AutoStartService) CreateGroup(ctx context.Context, name string, order int) (*db.AutoStartGroup, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("group name is required")
+	}
+	existing, err := db.GetAutoStartGroup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("autostart group %s already exists", name)
+	}
+	if err := db.CreateAutoStartGroup(ctx, name, order); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(ctx, name)
+}
+
+func (s *AutoStartService) UpdateGroup(ctx context.Context, name string, order int) (*db.AutoStartGroup, error) {
+	if _, err := s.GetGroup(ctx, name); err != nil {
+		return nil, err
+	}
+	if err := db.UpdateAutoStartGroup(ctx, name, order); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(ctx, name)
+}
+
+func (s *AutoStartService) DeleteGroup(ctx context.Context, name string) error {
+	if _, err := s.GetGroup(ctx, name); err != nil {
+		return err
+	}
+	return db.DeleteAutoStartGroup(ctx, name)
+}
+
+// SetBoot changes how an autostart vm boots, it is put on autostart first
+// when it is not yet.
+func (s *AutoStartService) SetBoot(ctx context.Context, settings db.AutoStart) (*db.AutoStart, error) {
+	settings.Group = strings.TrimSpace(settings.Group)
+	settings.TCPHost = strings.TrimSpace(settings.TCPHost)
+	if settings.DelaySeconds < 0 || settings.ReadyTimeout < 0 {
+		return nil, fmt.Errorf("delay_seconds and ready_timeout can't be negative")
+	}
+	switch settings.WaitFor {
+	case db.AutoStartWaitNone, db.AutoStartWaitAgent:
+	case db.AutoStartWaitTCP:
+		if settings.TCPPort <= 0 || settings.TCPPort > 65535 {
+			return nil, fmt.Errorf("wait_for tcp needs a tcp_port between 1 and 65535")
+		}
+	default:
+		return nil, fmt.Errorf("wait_for must be empty, %q or %q", db.AutoStartWaitAgent, db.AutoStartWaitTCP)
+	}
+	if settings.Group != "" {
+		if _, err := s.GetGroup(ctx, settings.Group); err != nil {
+			return nil, err
+		}
+	}
+
+	virshService := VirshService{}
+	if err := virshService.AutoStart(ctx, settings.VmName, true); err != nil {
+		return nil, err
+	}
+	if err := db.SetAutoStartBoot(ctx, settings); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("vm %s is not on autostart", settings.VmName)
+		}
+		return nil, err
+	}
+	return db.GetAutoStartByName(ctx, settings.VmName)
+}
+
+// waitAutoStartReady blocks until the readiness check of auto passes, a vm that
+// never gets ready only delays the rest of the boot by its timeout.
+func waitAutoStartReady(ctx context.Context, conn *protocol.ConnectionsStruct, auto db.AutoStart) {
+	if auto.WaitFor == db.AutoStartWaitNone {
+		return
+	}
+	timeout := time.Duration(auto.ReadyTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = autoStartReadyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		err := autoStartReady(ctx, conn, auto)
+		if err == nil {
+			logger.Infof("autostart vm %s: ready (%s)", auto.VmName, auto.WaitFor)
+			return
+		}
+		if time.Now().After(deadline) {
+			logger.Warnf("autostart vm %s: not ready after %s, going on with the next vm: %v", auto.VmName, timeout, err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(autoStartReadyPoll):
+		}
+	}
+}
+
+func autoStartReady(ctx context.Context, conn *protocol.ConnectionsStruct, auto db.AutoStart) error {
+	if auto.WaitFor == db.AutoStartWaitAgent {
+		return virsh.GuestAgentPing(ctx, conn.Connection, auto.VmName)
+	}
+
+	host := auto.TCPHost
+	if host == "" {
+		vm, err := virsh.GetVmByName(conn.Connection, &grpcVirsh.GetVmByNameRequest{Name: auto.VmName})
+		if err != nil {
+			return err
+		}
+		if vm == nil || len(vm.Ip) == 0 {
+			return fmt.Errorf("vm has no ip yet")
+		}
+		host = vm.Ip[0]
+	}
+	return virsh.CheckTCPPort(ctx, conn.Connection, host, auto.TCPPort, autoStartTCPDial)
+}
+
+// ShutdownOrdered gracefully shuts the running autostart vms down in the
+// reverse of their boot order, on machineName only when it is set. Each vm
+// gets maintenanceShutdownWait before it is forced off.
+func (s *AutoStartService) ShutdownOrdered(ctx context.Context, machineName string) (int, error) {
+	autoStart, err := db.GetAllAutoStart(ctx)
+	if err != nil {
+		return 0, err
+	}
+	slices.Reverse(autoStart)
+
+	target := machineName
+	if target == "" {
+		target = "cluster"
+	}
+	return StartJob(JobTypeOrderedShutdown, target, longTaskTimeout, func(ctx context.Context, job *JobHandle) error {
+		virshService := VirshService{}
+		var failed []string
+		for i, auto := range autoStart {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			job.Progress(float64(i) / float64(len(autoStart)) * 100)
+
+			vm, err := virshService.GetVmByName(auto.VmName)
+			if err != nil || vm == nil {
+				continue
+			}
+			if machineName != "" && vm.MachineName != machineName {
+				continue
+			}
+			if vm.State == grpcVirsh.VmState_SHUTOFF {
+				continue
+			}
+			conn := protocol.GetConnectionByMachineName(vm.MachineName)
+			if conn == nil || conn.Connection == nil {
+				failed = append(failed, vm.Name)
+				job.Log("%s: machine %s not connected", vm.Name, vm.MachineName)
+				continue
+			}
+
+			job.Log("shutting down %s (group %q) on %s", vm.Name, auto.Group, vm.MachineName)
+			if err := stopVMAndWait(ctx, conn, vm); err != nil {
+				failed = append(failed, vm.Name)
+				job.Log("%s: %v", vm.Name, err)
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("could not shut down %s", strings.Join(failed, ", "))
+		}
+		return nil
+	})
+}
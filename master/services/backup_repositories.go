@@ -0,0 +1,657 @@
+package services
+
+import (
+	"512SvMan/backuprepo"
+	"512SvMan/db"
+	"512SvMan/nots"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Maruqes/512SvMan/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	backupReplicationTick = 10 * time.Minute
+	// a copy that failed this many times waits for a manual retry
+	replicaMaxAttempts = 5
+)
+
+var (
+	replicatingMu sync.Mutex
+	replicating   = map[int]bool{}
+)
+
+func claimReplica(id int) bool {
+	replicatingMu.Lock()
+	defer replicatingMu.Unlock()
+	if replicating[id] {
+		return false
+	}
+	replicating[id] = true
+	return true
+}
+
+func releaseReplica(id int) {
+	replicatingMu.Lock()
+	delete(replicating, id)
+	replicatingMu.Unlock()
+}
+
+func validateBackupRepository(r db.BackupRepository) error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.BandwidthLimit < 0 {
+		return fmt.Errorf("bandwidth limit can't be negative")
+	}
+	switch r.Kind {
+	case backuprepo.KindLocal:
+		if !filepath.IsAbs(r.Path) {
+			return fmt.Errorf("local repositories need an absolute path")
+		}
+	case backuprepo.KindS3:
+		if r.Endpoint == "" || r.Bucket == "" || r.AccessKey == "" || r.SecretKey == "" {
+			return fmt.Errorf("s3 repositories need an endpoint, a bucket, an access key and a secret key")
+		}
+	case backuprepo.KindSFTP:
+		if r.Endpoint == "" || r.AccessKey == "" || (r.SecretKey == "" && r.PrivateKey == "") {
+			return fmt.Errorf("sftp repositories need an endpoint, a user and a password or private key")
+		}
+	default:
+		return fmt.Errorf("kind must be %q, %q or %q", backuprepo.KindLocal, backuprepo.KindS3, backuprepo.KindSFTP)
+	}
+	return nil
+}
+
+// repositoryConfig pins the sftp host key seen on the first connection.
+func repositoryConfig(r db.BackupRepository) backuprepo.Config {
+	return backuprepo.Config{
+		Kind:           r.Kind,
+		Path:           r.Path,
+		Endpoint:       r.Endpoint,
+		Bucket:         r.Bucket,
+		Region:         r.Region,
+		PathStyle:      r.PathStyle,
+		AccessKey:      r.AccessKey,
+		SecretKey:      r.SecretKey,
+		PrivateKey:     r.PrivateKey,
+		HostKey:        r.HostKey,
+		BandwidthLimit: r.BandwidthLimit,
+		OnHostKey: func(key string) {
+			logger.Infof("backup repository %s: trusting host key %s", r.Name, key)
+			if err := db.SetBackupRepositoryHostKey(context.Background(), r.Id, key); err != nil {
+				logger.Warnf("backup repository %s: pin host key: %v", r.Name, err)
+			}
+		},
+	}
+}
+
+func getBackupRepository(ctx context.Context, id int) (*db.BackupRepository, error) {
+	r, err := db.GetBackupRepository(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, fmt.Errorf("backup repository %d not found", id)
+	}
+	return r, nil
+}
+
+func openBackupRepository(ctx context.Context, id int) (*db.BackupRepository, backuprepo.Repository, error) {
+	r, err := getBackupRepository(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	repo, err := backuprepo.New(ctx, repositoryConfig(*r))
+	if err != nil {
+		return nil, nil, fmt.Errorf("backup repository %s: %w", r.Name, err)
+	}
+	return r, repo, nil
+}
+
+func (v *VirshService) BackupRepositories(ctx context.Context) ([]db.BackupRepository, error) {
+	return db.GetBackupRepositories(ctx)
+}
+
+func (v *VirshService) GetBackupRepository(ctx context.Context, id int) (*db.BackupRepository, error) {
+	return getBackupRepository(ctx, id)
+}
+
+func (v *VirshService) AddBackupRepository(ctx context.Context, r *db.BackupRepository) error {
+	if err := validateBackupRepository(*r); err != nil {
+		return err
+	}
+	return db.AddBackupRepository(ctx, r)
+}
+
+// UpdateBackupRepository keeps the stored secrets when r leaves them empty,
+// a new sftp endpoint forgets the pinned host key unless r pins one.
+func (v *VirshService) UpdateBackupRepository(ctx context.Context, r db.BackupRepository) error {
+	current, err := getBackupRepository(ctx, r.Id)
+	if err != nil {
+		return err
+	}
+	if r.SecretKey == "" {
+		r.SecretKey = current.SecretKey
+	}
+	if r.PrivateKey == "" {
+		r.PrivateKey = current.PrivateKey
+	}
+	if r.HostKey == "" && r.Endpoint == current.Endpoint {
+		r.HostKey = current.HostKey
+	}
+	if err := validateBackupRepository(r); err != nil {
+		return err
+	}
+	return db.UpdateBackupRepository(ctx, r)
+}
+
+// DeleteBackupRepository forgets the repository, its policies and replicas,
+// the files already copied stay in the repository.
+func (v *VirshService) DeleteBackupRepository(ctx context.Context, id int) error {
+	if _, err := getBackupRepository(ctx, id); err != nil {
+		return err
+	}
+	running, err := db.GetBackupReplicas(ctx, db.BackupReplicaFilter{RepositoryId: id, Status: db.BackupReplicaRunning})
+	if err != nil {
+		return err
+	}
+	if len(running) > 0 {
+		return fmt.Errorf("%d copies to this repository are running", len(running))
+	}
+	return db.DeleteBackupRepository(ctx, id)
+}
+
+// TestBackupRepository writes, reads back and deletes a small object.
+func (v *VirshService) TestBackupRepository(ctx context.Context, id int) error {
+	r, repo, err := openBackupRepository(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	probe, err := os.CreateTemp("", "512svman-repo-test-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(probe.Name())
+	content := fmt.Sprintf("512SvMan repository test %s\n", time.Now().UTC().Format(time.RFC3339))
+	if _, err := probe.WriteString(content); err != nil {
+		probe.Close()
+		return err
+	}
+	if err := probe.Close(); err != nil {
+		return err
+	}
+
+	key := ".512svman-test/" + uuid.New().String()
+	if err := repo.Upload(ctx, key, probe.Name(), nil); err != nil {
+		return fmt.Errorf("backup repository %s: upload: %w", r.Name, err)
+	}
+	defer repo.Delete(ctx, key)
+	rc, err := repo.Open(ctx, key)
+	if err != nil {
+		return fmt.Errorf("backup repository %s: read back: %w", r.Name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return fmt.Errorf("backup repository %s: read back: %w", r.Name, err)
+	}
+	if string(data) != content {
+		return fmt.Errorf("backup repository %s: read back %d bytes that differ from the %d written", r.Name, len(data), len(content))
+	}
+	return nil
+}
+
+func validateCopyPolicy(ctx context.Context, p db.BackupCopyPolicy) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if p.Mode != db.BackupCopyModeCopy && p.Mode != db.BackupCopyModeSync {
+		return fmt.Errorf("mode must be %q or %q", db.BackupCopyModeCopy, db.BackupCopyModeSync)
+	}
+	if _, err := getBackupRepository(ctx, p.RepositoryId); err != nil {
+		return err
+	}
+	if p.NfsId != 0 {
+		share, err := db.GetNFSShareByID(ctx, p.NfsId)
+		if err != nil {
+			return err
+		}
+		if share == nil {
+			return fmt.Errorf("NFS share %d not found", p.NfsId)
+		}
+	}
+	return nil
+}
+
+func (v *VirshService) BackupCopyPolicies(ctx context.Context) ([]db.BackupCopyPolicy, error) {
+	return db.GetBackupCopyPolicies(ctx)
+}
+
+func (v *VirshService) AddBackupCopyPolicy(ctx context.Context, p *db.BackupCopyPolicy) error {
+	if err := validateCopyPolicy(ctx, *p); err != nil {
+		return err
+	}
+	return db.AddBackupCopyPolicy(ctx, p)
+}
+
+func (v *VirshService) UpdateBackupCopyPolicy(ctx context.Context, p db.BackupCopyPolicy) error {
+	current, err := db.GetBackupCopyPolicy(ctx, p.Id)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("copy policy %d not found", p.Id)
+	}
+	if err := validateCopyPolicy(ctx, p); err != nil {
+		return err
+	}
+	return db.UpdateBackupCopyPolicy(ctx, p)
+}
+
+func (v *VirshService) DeleteBackupCopyPolicy(ctx context.Context, id int) error {
+	return db.DeleteBackupCopyPolicy(ctx, id)
+}
+
+func (v *VirshService) BackupReplicas(ctx context.Context, filter db.BackupReplicaFilter) ([]db.BackupReplica, error) {
+	return db.GetBackupReplicas(ctx, filter)
+}
+
+// replicaKey is where backup goes in a repository,
+// <vm>/<created>-<id>/<file>, so listing the repository by hand makes sense.
+func replicaKey(backup *db.VirshBackup) string {
+	created := "unknown"
+	if t, ok := db.ParseBackupTime(backup.CreatedAt); ok {
+		created = t.UTC().Format("20060102T150405Z")
+	}
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(backup.Name)
+	return fmt.Sprintf("%s/%s-%d/%s", name, created, backup.Id, filepath.Base(backup.Path))
+}
+
+func newReplica(backup *db.VirshBackup, repositoryID, policyID int, mode string) *db.BackupReplica {
+	return &db.BackupReplica{
+		BackupId:        backup.Id,
+		RepositoryId:    repositoryID,
+		PolicyId:        policyID,
+		Mode:            mode,
+		RemoteKey:       replicaKey(backup),
+		VmName:          backup.Name,
+		BackupCreatedAt: backup.CreatedAt,
+		Compression:     backup.Compression,
+		Encrypted:       backup.Encrypted,
+		KeyId:           backup.KeyId,
+		OriginalSize:    backup.OriginalSize,
+		Checksum:        backup.Checksum,
+	}
+}
+
+// replicateNewBackup queues the copies the policies ask for once a backup is
+// created. Incrementals are skipped, alone they are useless off the cluster.
+func replicateNewBackup(ctx context.Context, backupID int) {
+	backup, err := db.GetVirshBackupById(ctx, backupID)
+	if err != nil || backup == nil {
+		logger.Warnf("replication of backup %d: %v", backupID, err)
+		return
+	}
+	policies, err := db.GetBackupCopyPoliciesFor(ctx, *backup)
+	if err != nil {
+		logger.Warnf("replication of backup %d: %v", backupID, err)
+		return
+	}
+	if len(policies) == 0 {
+		return
+	}
+	if backup.Incremental {
+		logger.Infof("backup %d of %s is incremental, not replicated", backup.Id, backup.Name)
+		return
+	}
+	for _, p := range policies {
+		replica := newReplica(backup, p.RepositoryId, p.Id, p.Mode)
+		added, err := db.AddBackupReplica(ctx, replica)
+		if err != nil {
+			logger.Warnf("replication of backup %d by policy %s: %v", backup.Id, p.Name, err)
+			continue
+		}
+		if !added {
+			continue
+		}
+		if _, err := startReplication(replica.Id); err != nil {
+			logger.Warnf("replication of backup %d by policy %s: %v", backup.Id, p.Name, err)
+		}
+	}
+}
+
+// CopyBackup copies a backup to a repository outside of any policy.
+func (v *VirshService) CopyBackup(ctx context.Context, backupID, repositoryID int, mode string) (int, error) {
+	if mode == "" {
+		mode = db.BackupCopyModeCopy
+	}
+	if mode != db.BackupCopyModeCopy && mode != db.BackupCopyModeSync {
+		return 0, fmt.Errorf("mode must be %q or %q", db.BackupCopyModeCopy, db.BackupCopyModeSync)
+	}
+	backup, err := getBackup(ctx, backupID)
+	if err != nil {
+		return 0, err
+	}
+	if backup.Incremental {
+		return 0, fmt.Errorf("backup %d is incremental, copy a full backup instead", backup.Id)
+	}
+	if _, err := getBackupRepository(ctx, repositoryID); err != nil {
+		return 0, err
+	}
+
+	replica := newReplica(backup, repositoryID, 0, mode)
+	added, err := db.AddBackupReplica(ctx, replica)
+	if err != nil {
+		return 0, err
+	}
+	if !added {
+		existing, err := db.GetBackupReplicas(ctx, db.BackupReplicaFilter{BackupId: backupID, RepositoryId: repositoryID})
+		if err != nil {
+			return 0, err
+		}
+		if len(existing) == 0 {
+			return 0, fmt.Errorf("backup %d has no replica in repository %d", backupID, repositoryID)
+		}
+		if existing[0].Status == db.BackupReplicaDone {
+			return 0, fmt.Errorf("backup %d is already in this repository", backupID)
+		}
+		replica = &existing[0]
+	}
+	return startReplication(replica.Id)
+}
+
+// RetryBackupReplica starts a copy again, the upload continues from what the
+// repository already has.
+func (v *VirshService) RetryBackupReplica(ctx context.Context, id int) (int, error) {
+	replica, err := getBackupReplica(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if replica.Status == db.BackupReplicaDone {
+		return 0, fmt.Errorf("replica %d is already complete", id)
+	}
+	return startReplication(replica.Id)
+}
+
+func getBackupReplica(ctx context.Context, id int) (*db.BackupReplica, error) {
+	replica, err := db.GetBackupReplica(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if replica == nil {
+		return nil, fmt.Errorf("replica %d not found", id)
+	}
+	return replica, nil
+}
+
+func startReplication(replicaID int) (int, error) {
+	if !claimReplica(replicaID) {
+		return 0, fmt.Errorf("replica %d is already being copied", replicaID)
+	}
+	replica, err := getBackupReplica(context.Background(), replicaID)
+	if err != nil {
+		releaseReplica(replicaID)
+		return 0, err
+	}
+
+	jobID, err := StartJob(JobTypeReplicateBackup, replica.VmName, longTaskTimeout, func(ctx context.Context, job *JobHandle) error {
+		defer releaseReplica(replicaID)
+		if err := db.SetBackupReplicaStatus(ctx, replicaID, db.BackupReplicaRunning, "", 0); err != nil {
+			return err
+		}
+		size, err := replicate(ctx, replica, job)
+		if err != nil {
+			// the job context may be the one that ended
+			_ = db.SetBackupReplicaStatus(context.Background(), replicaID, db.BackupReplicaFailed, err.Error(), 0)
+			if replica.Attempts+1 >= replicaMaxAttempts {
+				sendImportantNotification(fmt.Sprintf("Copy of backup %d failed %d times", replica.BackupId, replica.Attempts+1), err)
+			}
+			return err
+		}
+		return db.SetBackupReplicaStatus(ctx, replicaID, db.BackupReplicaDone, "", size)
+	})
+	if err != nil {
+		releaseReplica(replicaID)
+	}
+	return jobID, err
+}
+
+func replicate(ctx context.Context, replica *db.BackupReplica, job *JobHandle) (int64, error) {
+	backup, err := getBackup(ctx, replica.BackupId)
+	if err != nil {
+		return 0, err
+	}
+	r, repo, err := openBackupRepository(ctx, replica.RepositoryId)
+	if err != nil {
+		return 0, err
+	}
+	defer repo.Close()
+
+	info, err := os.Stat(backup.Path)
+	if err != nil {
+		return 0, err
+	}
+	job.Log("copying %s to %s as %s", backup.Path, r.Name, replica.RemoteKey)
+	err = repo.Upload(ctx, replica.RemoteKey, backup.Path, func(done, total int64) {
+		if total > 0 {
+			job.Progress(float64(done) / float64(total) * 100)
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("upload to %s: %w", r.Name, err)
+	}
+
+	size, err := repo.Size(ctx, replica.RemoteKey)
+	if err != nil {
+		return 0, fmt.Errorf("check copy in %s: %w", r.Name, err)
+	}
+	if size != info.Size() {
+		return 0, fmt.Errorf("%s holds %d bytes of the %d of %s", r.Name, size, info.Size(), backup.Path)
+	}
+	job.Log("copied %d bytes", size)
+	return size, nil
+}
+
+// DeleteBackupReplica removes the copy from the repository and forgets it.
+func (v *VirshService) DeleteBackupReplica(ctx context.Context, id int) error {
+	replica, err := getBackupReplica(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !claimReplica(id) {
+		return fmt.Errorf("replica %d is being copied", id)
+	}
+	defer releaseReplica(id)
+	return deleteReplica(ctx, replica)
+}
+
+func deleteReplica(ctx context.Context, replica *db.BackupReplica) error {
+	r, repo, err := openBackupRepository(ctx, replica.RepositoryId)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+	if err := repo.Delete(ctx, replica.RemoteKey); err != nil {
+		return fmt.Errorf("delete %s from %s: %w", replica.RemoteKey, r.Name, err)
+	}
+	return db.DeleteBackupReplica(ctx, replica.Id)
+}
+
+// FetchBackupReplica downloads a copy to an NFS share and registers it as a
+// backup again, keys of encrypted copies are still the master ones.
+func (v *VirshService) FetchBackupReplica(ctx context.Context, id, nfsID int) (int, error) {
+	replica, err := getBackupReplica(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if replica.Status != db.BackupReplicaDone {
+		return 0, fmt.Errorf("replica %d is %s, only complete copies can be fetched", id, replica.Status)
+	}
+	share, err := db.GetNFSShareByID(ctx, nfsID)
+	if err != nil {
+		return 0, err
+	}
+	if share == nil {
+		return 0, fmt.Errorf("NFS share %d not found", nfsID)
+	}
+	if share.Target == "" {
+		return 0, fmt.Errorf("NFS share %d has an empty target path", nfsID)
+	}
+	if replica.Encrypted {
+		key, err := db.GetBackupKey(ctx, replica.KeyId)
+		if err != nil {
+			return 0, err
+		}
+		if key == nil {
+			return 0, fmt.Errorf("key %d of replica %d is gone, the copy can't be decrypted", replica.KeyId, id)
+		}
+	}
+
+	return StartJob(JobTypeFetchReplica, replica.VmName, longTaskTimeout, func(ctx context.Context, job *JobHandle) error {
+		backup, err := fetchReplica(ctx, replica, share, job)
+		if err != nil {
+			sendImportantNotification("Fetching a backup copy failed", err)
+			return err
+		}
+		nots.SendGlobalNotification("Backup fetched", fmt.Sprintf("Copy of %s restored to %s", replica.VmName, backup.Path), "/", false)
+		return nil
+	})
+}
+
+func fetchReplica(ctx context.Context, replica *db.BackupReplica, share *db.NFSShare, job *JobHandle) (*db.VirshBackup, error) {
+	r, repo, err := openBackupRepository(ctx, replica.RepositoryId)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	folder := strings.TrimRight(share.Target, "/") + "/backup-" + uuid.New().String()
+	if err := os.MkdirAll(folder, 0o755); err != nil {
+		return nil, err
+	}
+	backup := &db.VirshBackup{
+		Name:         replica.VmName,
+		Path:         folder + "/" + path.Base(replica.RemoteKey),
+		NfsId:        share.Id,
+		Compression:  replica.Compression,
+		Encrypted:    replica.Encrypted,
+		KeyId:        replica.KeyId,
+		OriginalSize: replica.OriginalSize,
+	}
+
+	job.Log("fetching %s from %s to %s", replica.RemoteKey, r.Name, backup.Path)
+	if err := downloadReplica(ctx, repo, replica, backup.Path, job); err != nil {
+		_ = os.RemoveAll(folder)
+		return nil, err
+	}
+	sum, err := backupChecksum(ctx, backup.Path)
+	if err != nil {
+		_ = os.RemoveAll(folder)
+		return nil, err
+	}
+	if replica.Checksum != "" && sum != replica.Checksum {
+		_ = os.RemoveAll(folder)
+		return nil, fmt.Errorf("checksum of the copy %s doesn't match the backup %s", sum, replica.Checksum)
+	}
+	backup.StoredSize = replica.Size
+
+	if err := db.InsertVirshBackup(ctx, backup); err != nil {
+		_ = os.RemoveAll(folder)
+		return nil, err
+	}
+	if err := db.SetVirshBackupChecksum(ctx, backup.Id, sum); err != nil {
+		logger.Warnf("checksum of backup %d: %v", backup.Id, err)
+	}
+	return backup, nil
+}
+
+func downloadReplica(ctx context.Context, repo backuprepo.Repository, replica *db.BackupReplica, dest string, job *JobHandle) error {
+	rc, err := repo.Open(ctx, replica.RemoteKey)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	buf := make([]byte, 4*1024*1024)
+	var done int64
+	for {
+		n, err := rc.Read(buf)
+		if n > 0 {
+			if _, werr := out.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			done += int64(n)
+			if replica.Size > 0 {
+				job.Progress(float64(done) / float64(replica.Size) * 100)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if replica.Size > 0 && done != replica.Size {
+		return fmt.Errorf("fetched %d bytes of %d", done, replica.Size)
+	}
+	return out.Close()
+}
+
+// LoopBackupReplication retries failed copies and removes the sync copies of
+// backups that were deleted or pruned.
+func (v *VirshService) LoopBackupReplication(ctx context.Context) {
+	if err := db.ResetRunningBackupReplicas(ctx); err != nil {
+		logger.Errorf("backup replication: %v", err)
+	}
+	ticker := time.NewTicker(backupReplicationTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			retry, err := db.GetRetryableBackupReplicas(ctx, replicaMaxAttempts)
+			if err != nil {
+				logger.Errorf("backup replication: %v", err)
+			}
+			for _, replica := range retry {
+				if _, err := startReplication(replica.Id); err != nil {
+					logger.Debugf("copy of backup %d not queued: %v", replica.BackupId, err)
+				}
+			}
+
+			orphans, err := db.GetOrphanedSyncReplicas(ctx)
+			if err != nil {
+				logger.Errorf("backup replication: %v", err)
+			}
+			for _, replica := range orphans {
+				if !claimReplica(replica.Id) {
+					continue
+				}
+				err := deleteReplica(ctx, &replica)
+				releaseReplica(replica.Id)
+				if err != nil && !errors.Is(err, context.Canceled) {
+					logger.Warnf("removing the copy of deleted backup %d: %v", replica.BackupId, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"512SvMan/db"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+func validateRetention(bak db.AutomaticBackup) error {
+	if bak.MaxBackupsRetain < 1 {
+		return fmt.Errorf("max backups retain must be at least 1")
+	}
+	if bak.KeepDaily < 0 || bak.KeepWeekly < 0 || bak.KeepMonthly < 0 || bak.KeepYearly < 0 {
+		return fmt.Errorf("keep daily, weekly, monthly and yearly must be 0 or more")
+	}
+	if bak.MinAgeDays < 0 {
+		return fmt.Errorf("min age days must be 0 or more")
+	}
+	return nil
+}
+
+func getAutoBak(ctx context.Context, id int) (*db.AutomaticBackup, error) {
+	bak, err := db.GetAutomaticBackupById(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get automatic backup by ID: %v", err)
+	}
+	if bak == nil {
+		return nil, fmt.Errorf("automatic backup with ID %d not found", id)
+	}
+	return bak, nil
+}
+
+// pruneAutoBak applies the retention of bak to the automatic backups of its vm,
+// with dryRun it only returns what would be removed.
+func (v *VirshService) pruneAutoBak(ctx context.Context, bak db.AutomaticBackup, dryRun bool) ([]db.RetentionDecision, error) {
+	baks, err := db.GetAutomaticBackups(ctx, bak.VmName)
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]db.VirshBackup, 0, len(baks))
+	for _, b := range baks {
+		backups = append(backups, *b)
+	}
+
+	plan := db.PlanBackupRetention(backups, bak, time.Now())
+	if dryRun {
+		return plan, nil
+	}
+
+	// the plan is newest first so children go before parents
+	for _, d := range plan {
+		if d.Keep {
+			continue
+		}
+		if err := v.DeleteBackup(ctx, d.BackupId); err != nil {
+			logger.Errorf("failed to delete old backup %d: %v", d.BackupId, err)
+			sendImportantNotification(fmt.Sprintf("pruneAutoBak: failed to delete old backup %d", d.BackupId), err)
+		}
+	}
+	return plan, nil
+}
+
+// PlanAutoBakPrune is a dry-run of the next prune of an automatic backup, no
+// backup is touched.
+func (v *VirshService) PlanAutoBakPrune(ctx context.Context, id int) ([]db.RetentionDecision, error) {
+	bak, err := getAutoBak(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return v.pruneAutoBak(ctx, *bak, true)
+}
+
+// PruneAutoBak applies the retention now instead of after the next backup.
+func (v *VirshService) PruneAutoBak(ctx context.Context, id int) ([]db.RetentionDecision, error) {
+	bak, err := getAutoBak(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return v.pruneAutoBak(ctx, *bak, false)
+}
+
+// PinBackup protects a backup from pruning and deletion, or lifts that.
+func (v *VirshService) PinBackup(ctx context.Context, backupID int, pinned bool) error {
+	if _, err := getBackup(ctx, backupID); err != nil {
+		return err
+	}
+	return db.SetVirshBackupPinned(ctx, backupID, pinned)
+}
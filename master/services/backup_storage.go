@@ -0,0 +1,279 @@
+package services
+
+import (
+	"512SvMan/backupcodec"
+	"512SvMan/db"
+	"512SvMan/virsh"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Maruqes/512SvMan/logger"
+	"google.golang.org/grpc"
+)
+
+// BackupStorage is how a new backup is written to the share. qcow2
+// compression keeps a usable qcow2, zstd and encryption have to be decoded
+// before the backup can be used as a disk.
+type BackupStorage struct {
+	Compression string `json:"compression"`
+	Encrypt     bool   `json:"encrypt"`
+}
+
+func (s BackupStorage) packed() bool {
+	return s.Encrypt || s.Compression == backupcodec.CompressionZstd
+}
+
+func validateBackupStorage(s BackupStorage) error {
+	if !backupcodec.ValidCompression(s.Compression) {
+		return fmt.Errorf("compression must be empty, %q or %q", backupcodec.CompressionQcow2, backupcodec.CompressionZstd)
+	}
+	return nil
+}
+
+// autoBakStorage checks the storage options of a schedule, incrementals are
+// always plain qcow2 because the next one uses them as backing file.
+func autoBakStorage(bak db.AutomaticBackup) (BackupStorage, error) {
+	storage := BackupStorage{Compression: bak.Compression, Encrypt: bak.Encrypt}
+	if err := validateBackupStorage(storage); err != nil {
+		return storage, err
+	}
+	if storage.packed() && bak.FullEvery > 0 {
+		return storage, fmt.Errorf("zstd and encrypted backups are always full, set full every to 0")
+	}
+	return storage, nil
+}
+
+// backupEncryptionKey returns the key new backups are encrypted with and
+// creates the first one when needed.
+func backupEncryptionKey(ctx context.Context) (*db.BackupKey, error) {
+	key, err := db.GetNewestBackupKey(ctx)
+	if err != nil || key != nil {
+		return key, err
+	}
+	return newBackupKey(ctx)
+}
+
+func newBackupKey(ctx context.Context) (*db.BackupKey, error) {
+	material, err := backupcodec.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &db.BackupKey{Key: material}
+	if err := db.AddBackupKey(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to store backup key: %v", err)
+	}
+	logger.Warnf("backup key %d created, export the backup keys again and keep the file away from the master", key.Id)
+	return key, nil
+}
+
+// RotateBackupKey makes new backups use a fresh key, older backups keep
+// decrypting with the key they were written with.
+func (v *VirshService) RotateBackupKey(ctx context.Context) (*db.BackupKey, error) {
+	return newBackupKey(ctx)
+}
+
+func (v *VirshService) BackupKeys(ctx context.Context) ([]db.BackupKey, error) {
+	return db.GetBackupKeys(ctx)
+}
+
+// ExportBackupKeys seals every backup key with passphrase. The keys only live
+// in the master database, losing it without an export makes every encrypted
+// backup unreadable.
+func (v *VirshService) ExportBackupKeys(ctx context.Context, principal *Principal, passphrase string) ([]byte, error) {
+	if principal == nil || principal.User.Role != db.RoleAdmin {
+		return nil, fmt.Errorf("only admins can export backup keys")
+	}
+	keys, err := db.GetBackupKeyMaterial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]backupcodec.KeyFileEntry, 0, len(keys))
+	for _, k := range keys {
+		entries = append(entries, backupcodec.KeyFileEntry{Id: k.Id, Key: k.Key, CreatedAt: k.CreatedAt})
+	}
+	sealed, err := backupcodec.SealKeyFile(entries, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("%d backup key(s) exported by %s", len(entries), principal.User.Username)
+	return sealed, nil
+}
+
+// ImportBackupKeys restores the keys of an export under their original ids,
+// keys already present are skipped. It returns how many were added.
+func (v *VirshService) ImportBackupKeys(ctx context.Context, principal *Principal, keyFile []byte, passphrase string) (int, error) {
+	if principal == nil || principal.User.Role != db.RoleAdmin {
+		return 0, fmt.Errorf("only admins can import backup keys")
+	}
+	entries, err := backupcodec.OpenKeyFile(keyFile, passphrase)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, e := range entries {
+		ok, err := db.ImportBackupKey(ctx, db.BackupKey{Id: e.Id, Key: e.Key, CreatedAt: e.CreatedAt})
+		if err != nil {
+			return added, err
+		}
+		if ok {
+			added++
+		}
+	}
+	logger.Infof("%d backup key(s) imported by %s", added, principal.User.Username)
+	return added, nil
+}
+
+// backupKeyOf is the key to decrypt backup with, nil when it isn't encrypted.
+func backupKeyOf(ctx context.Context, backup *db.VirshBackup) ([]byte, error) {
+	if !backup.Encrypted {
+		return nil, nil
+	}
+	key, err := db.GetBackupKey(ctx, backup.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("key %d of backup %d is gone, the backup can't be decrypted", backup.KeyId, backup.Id)
+	}
+	return key.Key, nil
+}
+
+// storeBackup turns the qcow2 a backup job wrote at backup.Path into the
+// stored format and records the sizes, backup.Path is moved to the new file.
+func storeBackup(ctx context.Context, backup *db.VirshBackup, machineName string, storage BackupStorage) error {
+	job := jobFromContext(ctx)
+	info, err := os.Stat(backup.Path)
+	if err != nil {
+		return err
+	}
+	backup.OriginalSize = info.Size()
+	backup.StoredSize = info.Size()
+
+	if backup.Incremental {
+		if storage != (BackupStorage{}) {
+			job.Log("backup of %s is incremental, keeping it as plain qcow2", backup.Name)
+		}
+		return nil
+	}
+
+	if storage.Compression == backupcodec.CompressionQcow2 {
+		conn, err := backupVerifyConn(ctx, backup, machineName)
+		if err != nil {
+			return err
+		}
+		job.Log("compressing %s", backup.Path)
+		tmp := backup.Path + ".compressing"
+		if err := virsh.CompressDiskImage(ctx, conn, backup.Path, tmp); err != nil {
+			return fmt.Errorf("qcow2 compression: %w", err)
+		}
+		if err := os.Rename(tmp, backup.Path); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+		backup.Compression = backupcodec.CompressionQcow2
+	}
+
+	if storage.packed() {
+		var material []byte
+		if storage.Encrypt {
+			key, err := backupEncryptionKey(ctx)
+			if err != nil {
+				return err
+			}
+			material = key.Key
+			backup.KeyId = key.Id
+		}
+		compression := ""
+		if storage.Compression == backupcodec.CompressionZstd {
+			compression = backupcodec.CompressionZstd
+		}
+
+		dst := backup.Path + backupcodec.Extension(compression, storage.Encrypt)
+		job.Log("writing %s", dst)
+		if err := backupcodec.Encode(ctx, backup.Path, dst, compression, material); err != nil {
+			return fmt.Errorf("encode backup: %w", err)
+		}
+		if err := os.Remove(backup.Path); err != nil {
+			logger.Warnf("plain copy of backup %s left behind: %v", dst, err)
+		}
+		backup.Path = dst
+		if compression != "" {
+			backup.Compression = compression
+		}
+		backup.Encrypted = storage.Encrypt
+	}
+
+	if info, err = os.Stat(backup.Path); err != nil {
+		return err
+	}
+	backup.StoredSize = info.Size()
+	return nil
+}
+
+// openBackup streams the qcow2 image of a full backup whatever way it is
+// stored.
+func openBackup(ctx context.Context, backup *db.VirshBackup) (io.ReadCloser, error) {
+	if !backup.NeedsDecode() {
+		return os.Open(backup.Path)
+	}
+	key, err := backupKeyOf(ctx, backup)
+	if err != nil {
+		return nil, err
+	}
+	return backupcodec.Open(ctx, backup.Path, backup.Compression, key)
+}
+
+// decodeBackup writes the qcow2 image of a packed backup to dest, owned by
+// qemu like a copied one.
+func decodeBackup(ctx context.Context, backup *db.VirshBackup, dest string) error {
+	key, err := backupKeyOf(ctx, backup)
+	if err != nil {
+		return err
+	}
+	if err := backupcodec.Decode(ctx, backup.Path, dest, backup.Compression, key); err != nil {
+		return err
+	}
+	uid, gid, err := qemuOwnership()
+	if err != nil {
+		return err
+	}
+	if err := os.Chown(dest, uid, gid); err != nil {
+		return fmt.Errorf("failed to set qemu ownership: %v", err)
+	}
+	return os.Chmod(dest, 0o777)
+}
+
+// decodeBackupToSlave streams the qcow2 image of a packed backup into the
+// local scratch folder of the slave behind conn and returns its path there.
+// Remove it with virsh.RemoveScratchImage once done.
+func decodeBackupToSlave(ctx context.Context, conn *grpc.ClientConn, backup *db.VirshBackup, name string) (string, error) {
+	r, err := openBackup(ctx, backup)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	image, err := virsh.WriteScratchImage(ctx, conn, name, r)
+	if err != nil {
+		return "", fmt.Errorf("decode backup %d to the slave: %w", backup.Id, err)
+	}
+	return image.Path, nil
+}
+
+// OpenBackup is the export of a full backup, packed ones are decoded on the
+// fly.
+func (v *VirshService) OpenBackup(ctx context.Context, backupID int) (*db.VirshBackup, io.ReadCloser, error) {
+	backup, err := getBackup(ctx, backupID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if backup.Incremental {
+		return nil, nil, fmt.Errorf("incremental backups only hold changes since their parent, restore it with useBackup instead")
+	}
+	r, err := openBackup(ctx, backup)
+	if err != nil {
+		return nil, nil, err
+	}
+	return backup, r, nil
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"512SvMan/db"
+	"512SvMan/nots"
+	"512SvMan/protocol"
+	"512SvMan/virsh"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+	"google.golang.org/grpc"
+)
+
+const (
+	// every backup gets a qemu-img check at least this often
+	backupCheckInterval = 7 * 24 * time.Hour
+	backupVerifyTick    = time.Hour
+
+	testRestoreTimeout = 5 * time.Minute
+	testRestoreMemory  = 2048
+	testRestoreVCPUs   = 2
+)
+
+// backups with a verification queued or running, so the loop doesn't queue
+// them twice
+var (
+	verifyingMu      sync.Mutex
+	verifyingBackups = map[int]bool{}
+)
+
+func claimBackupVerification(id int) bool {
+	verifyingMu.Lock()
+	defer verifyingMu.Unlock()
+	if verifyingBackups[id] {
+		return false
+	}
+	verifyingBackups[id] = true
+	return true
+}
+
+func releaseBackupVerification(id int) {
+	verifyingMu.Lock()
+	delete(verifyingBackups, id)
+	verifyingMu.Unlock()
+}
+
+// TestRestoreOptions size the throwaway vm, zero values use the defaults.
+type TestRestoreOptions struct {
+	MachineName    string `json:"machine_name"`
+	MemoryMB       int32  `json:"memory_mb"`
+	VCPUs          int32  `json:"vcpus"`
+	TimeoutSeconds int32  `json:"timeout_seconds"`
+}
+
+// backupChecksum is the sha256 of the file at path.
+func backupChecksum(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	buf := make([]byte, 4*1024*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := f.Read(buf)
+		if n > 0 {
+			h.Write(buf[:n])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("read %s: %w", path, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// recordBackupChecksum stores the checksum a new backup is verified against,
+// a backup without one is still checked with qemu-img.
+func recordBackupChecksum(ctx context.Context, backup *db.VirshBackup) {
+	sum, err := backupChecksum(ctx, backup.Path)
+	if err != nil {
+		logger.Warnf("checksum of backup %d: %v", backup.Id, err)
+		return
+	}
+	if err := db.SetVirshBackupChecksum(ctx, backup.Id, sum); err != nil {
+		logger.Warnf("checksum of backup %d: %v", backup.Id, err)
+		return
+	}
+	backup.Checksum = sum
+}
+
+// backupVerifyConn is the slave verifications run on, the host of the share
+// when it is connected, any slave otherwise since they all mount every share.
+func backupVerifyConn(ctx context.Context, backup *db.VirshBackup, machineName string) (*grpc.ClientConn, error) {
+	_, conn, err := backupSlave(ctx, backup, machineName)
+	return conn, err
+}
+
+// backupSlave is backupVerifyConn with the name of the slave picked.
+func backupSlave(ctx context.Context, backup *db.VirshBackup, machineName string) (string, *grpc.ClientConn, error) {
+	if machineName == "" {
+		if share, err := db.GetNFSShareByID(ctx, backup.NfsId); err == nil && share != nil {
+			machineName = share.MachineName
+		}
+	}
+	if conn := protocol.GetConnectionByMachineName(machineName); conn != nil && conn.Connection != nil {
+		return machineName, conn.Connection, nil
+	}
+	for _, name := range protocol.GetAllMachineNames() {
+		if conn := protocol.GetConnectionByMachineName(name); conn != nil && conn.Connection != nil {
+			return name, conn.Connection, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no slave connected to open backup %d", backup.Id)
+}
+
+// backupFiles is every file a backup needs, down to its full backup.
+func backupFiles(ctx context.Context, backup *db.VirshBackup) ([]db.VirshBackup, error) {
+	if !backup.Incremental {
+		return []db.VirshBackup{*backup}, nil
+	}
+	return db.GetVirshBackupChain(ctx, backup.Id)
+}
+
+func getBackup(ctx context.Context, id int) (*db.VirshBackup, error) {
+	backup, err := db.GetVirshBackupById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if backup == nil {
+		return nil, fmt.Errorf("backup %d not found", id)
+	}
+	return backup, nil
+}
+
+func finishBackupVerification(backup *db.VirshBackup, kind string, started time.Time, notify bool, err error) {
+	v := &db.BackupVerification{
+		BackupId:  backup.Id,
+		Kind:      kind,
+		Status:    db.BackupVerifyOk,
+		StartedAt: started.UTC().Format(time.RFC3339),
+	}
+	if err != nil {
+		v.Status = db.BackupVerifyFailed
+		v.Message = err.Error()
+	}
+	if dbErr := db.AddBackupVerification(context.Background(), v); dbErr != nil {
+		logger.Errorf("record %s of backup %d: %v", kind, backup.Id, dbErr)
+	}
+
+	if err != nil {
+		nots.SendGlobalNotification("Backup "+kind+" failed",
+			fmt.Sprintf("backup %d of %s (%s): %v", backup.Id, backup.Name, backup.CreatedAt, err), "/", true)
+	} else if notify {
+		nots.SendGlobalNotification("Backup "+kind+" passed",
+			fmt.Sprintf("backup %d of %s (%s) is fine", backup.Id, backup.Name, backup.CreatedAt), "/", false)
+	}
+}
+
+// VerifyBackup runs qemu-img check on every file of the backup chain and
+// compares each file against the checksum taken at backup time.
+func (v *VirshService) VerifyBackup(ctx context.Context, backupID int) (int, error) {
+	return startBackupCheck(ctx, backupID, true)
+}
+
+func startBackupCheck(ctx context.Context, backupID int, notify bool) (int, error) {
+	backup, err := getBackup(ctx, backupID)
+	if err != nil {
+		return 0, err
+	}
+	if !claimBackupVerification(backup.Id) {
+		return 0, fmt.Errorf("backup %d is already being verified", backup.Id)
+	}
+
+	jobID, err := StartJob(JobTypeVerifyBackup, backup.Name, longTaskTimeout, func(ctx context.Context, job *JobHandle) error {
+		defer releaseBackupVerification(backup.Id)
+		started := time.Now()
+		err := checkBackup(ctx, backup, job)
+		finishBackupVerification(backup, db.BackupVerifyCheck, started, notify, err)
+		return err
+	})
+	if err != nil {
+		releaseBackupVerification(backup.Id)
+	}
+	return jobID, err
+}
+
+func checkBackup(ctx context.Context, backup *db.VirshBackup, job *JobHandle) error {
+	files, err := backupFiles(ctx, backup)
+	if err != nil {
+		return err
+	}
+	conn, err := backupVerifyConn(ctx, backup, "")
+	if err != nil {
+		return err
+	}
+
+	for i, file := range files {
+		job.Progress(float64(i) / float64(len(files)) * 100)
+		if _, err := os.Stat(file.Path); err != nil {
+			return fmt.Errorf("backup %d: %w", file.Id, err)
+		}
+
+		if file.NeedsDecode() {
+			// qemu-img can't read it, decoding authenticates every encrypted
+			// chunk and checks the zstd frame checksums instead
+			job.Log("decoding %s", file.Path)
+			if err := checkPackedBackup(ctx, &file); err != nil {
+				return fmt.Errorf("backup %d: %w", file.Id, err)
+			}
+		} else {
+			job.Log("qemu-img check %s", file.Path)
+			check, err := virsh.CheckDiskImage(ctx, conn, file.Path)
+			if err != nil {
+				return fmt.Errorf("backup %d: qemu-img check: %w", file.Id, err)
+			}
+			if !check.Ok {
+				return fmt.Errorf("backup %d: qemu-img check found %d corruption(s): %s", file.Id, check.Corruptions, check.Output)
+			}
+			if check.Leaks > 0 {
+				job.Log("%s has %d leaked cluster(s), harmless", file.Path, check.Leaks)
+			}
+		}
+
+		sum, err := backupChecksum(ctx, file.Path)
+		if err != nil {
+			return fmt.Errorf("backup %d: checksum: %w", file.Id, err)
+		}
+		switch file.Checksum {
+		case "":
+			// taken before checksums were recorded, this one is the baseline
+			job.Log("backup %d had no checksum, recording %s", file.Id, sum)
+			if err := db.SetVirshBackupChecksum(ctx, file.Id, sum); err != nil {
+				return err
+			}
+		case sum:
+		default:
+			return fmt.Errorf("backup %d: checksum mismatch, the file changed since it was written", file.Id)
+		}
+	}
+	job.Progress(100)
+	return nil
+}
+
+func checkPackedBackup(ctx context.Context, backup *db.VirshBackup) error {
+	r, err := openBackup(ctx, backup)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
+
+// TestRestoreBackup boots the backup as a transient vm without network on a
+// slave, waits for its guest agent and destroys it again. The backup files are
+// only read, the guest writes to an overlay in the local scratch folder of the
+// slave, where packed backups are decoded as well.
+func (v *VirshService) TestRestoreBackup(ctx context.Context, backupID int, opts TestRestoreOptions) (int, error) {
+	backup, err := getBackup(ctx, backupID)
+	if err != nil {
+		return 0, err
+	}
+	files, err := backupFiles(ctx, backup)
+	if err != nil {
+		return 0, err
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file.Path); err != nil {
+			return 0, fmt.Errorf("backup %d of the chain is not available: %v", file.Id, err)
+		}
+	}
+	conn, err := backupVerifyConn(ctx, backup, opts.MachineName)
+	if err != nil {
+		return 0, err
+	}
+	if opts.MemoryMB <= 0 {
+		opts.MemoryMB = testRestoreMemory
+	}
+	if opts.VCPUs <= 0 {
+		opts.VCPUs = testRestoreVCPUs
+	}
+	timeout := time.Duration(opts.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = testRestoreTimeout
+	}
+	if !claimBackupVerification(backup.Id) {
+		return 0, fmt.Errorf("backup %d is already being verified", backup.Id)
+	}
+
+	jobID, err := StartJob(JobTypeTestRestore, backup.Name, timeout+10*time.Minute, func(ctx context.Context, job *JobHandle) error {
+		defer releaseBackupVerification(backup.Id)
+		started := time.Now()
+		name := fmt.Sprintf("verify-%d-%d", backup.Id, started.Unix())
+		diskPath := backup.Path
+		if backup.NeedsDecode() {
+			// decoded on the slave's local disk, a share would expose the
+			// plaintext and keep it if the master died halfway
+			job.Log("decoding %s to the scratch folder of the slave", backup.Path)
+			decoded, err := decodeBackupToSlave(ctx, conn, backup, name+"-disk.qcow2")
+			if err != nil {
+				finishBackupVerification(backup, db.BackupVerifyRestore, started, true, err)
+				return err
+			}
+			diskPath = decoded
+			defer func() {
+				if err := virsh.RemoveScratchImage(context.Background(), conn, name+"-disk.qcow2"); err != nil {
+					logger.Warnf("test restore of backup %d: remove decoded image: %v", backup.Id, err)
+				}
+			}()
+		}
+		job.Log("booting backup %d as %s without network", backup.Id, name)
+
+		// no overlay path puts the overlay in the scratch folder too
+		resp, err := virsh.TestBootBackup(ctx, conn, &grpcVirsh.TestBootRequest{
+			Name:           name,
+			DiskPath:       diskPath,
+			MemoryMb:       opts.MemoryMB,
+			Vcpus:          opts.VCPUs,
+			TimeoutSeconds: int32(timeout.Seconds()),
+		})
+		if err == nil && !resp.AgentOk {
+			err = fmt.Errorf("%s", resp.Message)
+		}
+		if err == nil {
+			job.Log("guest agent answered after %ds", resp.Seconds)
+		}
+		finishBackupVerification(backup, db.BackupVerifyRestore, started, true, err)
+		return err
+	})
+	if err != nil {
+		releaseBackupVerification(backup.Id)
+	}
+	return jobID, err
+}
+
+func (v *VirshService) BackupVerifications(ctx context.Context, backupID int, limit int) ([]db.BackupVerification, error) {
+	if _, err := getBackup(ctx, backupID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	return db.GetBackupVerifications(ctx, backupID, limit)
+}
+
+// leftoverVerifyFile matches the decoded disks and overlays test restores
+// used to leave next to the backup when the master stopped mid job.
+var leftoverVerifyFile = regexp.MustCompile(`^verify-[0-9]+-[0-9]+(-disk)?\.qcow2$`)
+
+// sweepBackupLeftovers removes the files matching pattern from every backup
+// folder, run it when the master starts and nothing can be using them.
+func sweepBackupLeftovers(ctx context.Context, pattern *regexp.Regexp, what string) {
+	backups, err := db.GetAllVirshBackups(ctx)
+	if err != nil {
+		logger.Errorf("sweep %s leftovers: %v", what, err)
+		return
+	}
+	// a vm could be called like that, its backups are not leftovers
+	tracked := map[string]bool{}
+	for _, backup := range backups {
+		tracked[backup.Path] = true
+	}
+	seen := map[string]bool{}
+	for _, backup := range backups {
+		dir := filepath.Dir(backup.Path)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			if e.Type().IsRegular() && pattern.MatchString(e.Name()) && !tracked[path] {
+				if err := os.Remove(path); err != nil {
+					logger.Warnf("sweep %s leftovers: %v", what, err)
+				} else {
+					logger.Infof("removed %s leftover %s", what, path)
+				}
+			}
+		}
+	}
+}
+
+// LoopBackupVerification queues a check of every backup that had none in
+// backupCheckInterval, the job limit keeps them running one at a time.
+func (v *VirshService) LoopBackupVerification(ctx context.Context) {
+	sweepBackupLeftovers(ctx, leftoverVerifyFile, "test restore")
+	ticker := time.NewTicker(backupVerifyTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			due, err := db.GetBackupsDueForCheck(ctx, time.Now().Add(-backupCheckInterval))
+			if err != nil {
+				logger.Errorf("backup verification: %v", err)
+				continue
+			}
+			for _, backup := range due {
+				if _, err := startBackupCheck(ctx, backup.Id, false); err != nil {
+					logger.Debugf("backup verification of %d not queued: %v", backup.Id, err)
+				}
+			}
+		}
+	}
+}
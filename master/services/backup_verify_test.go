@@ -0,0 +1,83 @@
+package services
+
+import (
+	"512SvMan/db"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func useBackupTestDB(t *testing.T) {
+	t.Helper()
+	originalDB := db.DB
+	t.Cleanup(func() {
+		db.DB = originalDB
+	})
+
+	var err error
+	db.DB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.DB.Close() })
+	db.DB.SetMaxOpenConns(1)
+
+	if err := db.CreateTableBackups(context.Background()); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+}
+
+func TestSweepBackupLeftovers(t *testing.T) {
+	ctx := context.Background()
+	useBackupTestDB(t)
+
+	dir := t.TempDir()
+	files := []string{"web.qcow2", "verify-3-1700000000.qcow2", "verify-3-1700000000-disk.qcow2", "verify-7.qcow2", "verify-1-2.qcow2"}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a vm whose name looks like a leftover keeps its backup
+	for _, name := range []string{"web.qcow2", "verify-1-2.qcow2"} {
+		if err := db.InsertVirshBackup(ctx, &db.VirshBackup{Name: strings.TrimSuffix(name, ".qcow2"), Path: filepath.Join(dir, name)}); err != nil {
+			t.Fatalf("insert backup: %v", err)
+		}
+	}
+
+	sweepBackupLeftovers(ctx, leftoverVerifyFile, "test restore")
+
+	var left []string
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	if got, want := strings.Join(left, ","), "verify-1-2.qcow2,verify-7.qcow2,web.qcow2"; got != want {
+		t.Fatalf("left %s, want %s", got, want)
+	}
+}
+
+func TestDeleteBackupRefusedWhileVerifying(t *testing.T) {
+	ctx := context.Background()
+	useBackupTestDB(t)
+
+	backup := &db.VirshBackup{Name: "web", Path: filepath.Join(t.TempDir(), "web.qcow2")}
+	if err := db.InsertVirshBackup(ctx, backup); err != nil {
+		t.Fatalf("insert backup: %v", err)
+	}
+	if !claimBackupVerification(backup.Id) {
+		t.Fatalf("claim verification")
+	}
+	defer releaseBackupVerification(backup.Id)
+
+	err := (&VirshService{}).DeleteBackup(ctx, backup.Id)
+	if err == nil || !strings.Contains(err.Error(), "being verified") {
+		t.Fatalf("expected the delete to be refused, got %v", err)
+	}
+	if got, _ := db.GetVirshBackupById(ctx, backup.Id); got == nil {
+		t.Fatalf("backup removed while it was being verified")
+	}
+}
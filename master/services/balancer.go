@@ -0,0 +1,512 @@
+package services
+
+import (
+	"512SvMan/db"
+	"512SvMan/nots"
+	"512SvMan/protocol"
+	"512SvMan/virsh"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	infoGrpc "github.com/Maruqes/512SvMan/api/proto/info"
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+const (
+	balancerTick = 5 * time.Minute
+	// a move has to lower the busiest host by at least this many percent
+	balancerMinGain = 2.0
+	// a reviewed plan older than this is planned again
+	balancerPlanMaxAge = 15 * time.Minute
+)
+
+type BalancerService struct{}
+
+// BalanceHost is the load of a slave, Load is the higher of its cpu and
+// memory use in percent and ProjectedLoad the same after the plan.
+type BalanceHost struct {
+	MachineName   string  `json:"machine_name"`
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryPercent float64 `json:"memory_percent"`
+	Load          float64 `json:"load"`
+	ProjectedLoad float64 `json:"projected_load"`
+}
+
+// BalanceMove is a live migration the balancer proposes.
+type BalanceMove struct {
+	VmName string `json:"vm_name"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+// BalancePlan is the migrations that even out the load of the cluster,
+// Spread is the gap between the busiest and the idlest slave.
+type BalancePlan struct {
+	GeneratedAt     string        `json:"generated_at"`
+	Spread          float64       `json:"spread"`
+	ProjectedSpread float64       `json:"projected_spread"`
+	Hosts           []BalanceHost `json:"hosts"`
+	Moves           []BalanceMove `json:"moves"`
+	Skipped         []string      `json:"skipped"`
+}
+
+// balanceVM is a VM the balancer may move, cpu in host cores and memory in
+// MB it takes on its host.
+type balanceVM struct {
+	vm       *grpcVirsh.Vm
+	cores    float64
+	memoryMB float64
+}
+
+type balanceHost struct {
+	BalanceHost
+	cores    float64
+	memoryMB float64
+	vms      []balanceVM
+}
+
+func (h *balanceHost) load() float64 {
+	return math.Max(h.CPUPercent, h.MemoryPercent)
+}
+
+// shift returns the cpu and memory use of the host once vm is added to it
+// (sign 1) or taken off it (sign -1).
+func (h *balanceHost) shift(vm balanceVM, sign float64) (cpu, mem float64) {
+	return h.CPUPercent + sign*vm.cores*100/h.cores, h.MemoryPercent + sign*vm.memoryMB*100/h.memoryMB
+}
+
+func validateBalancerConfig(cfg db.BalancerConfig) error {
+	if cfg.Threshold <= 0 || cfg.Threshold > 100 {
+		return fmt.Errorf("threshold must be between 0 and 100")
+	}
+	if cfg.MaxMigrations <= 0 {
+		return fmt.Errorf("max_migrations must be at least 1")
+	}
+	if cfg.LookbackMinutes <= 0 {
+		return fmt.Errorf("lookback_minutes must be at least 1")
+	}
+	if cfg.IntervalMinutes < 5 {
+		return fmt.Errorf("interval_minutes must be at least 5")
+	}
+	if (cfg.WindowStart == "") != (cfg.WindowEnd == "") {
+		return fmt.Errorf("window_start and window_end must both be set or both be empty")
+	}
+	if _, err := inBalancerWindow(time.Now(), cfg.WindowStart, cfg.WindowEnd); err != nil {
+		return err
+	}
+	return nil
+}
+
+// inBalancerWindow tells if now falls in the HH:MM window, a window ending
+// before it starts runs past midnight.
+func inBalancerWindow(now time.Time, start, end string) (bool, error) {
+	if start == "" && end == "" {
+		return true, nil
+	}
+	startAt, err := time.Parse("15:04", start)
+	if err != nil {
+		return false, fmt.Errorf("invalid window_start %q, use HH:MM", start)
+	}
+	endAt, err := time.Parse("15:04", end)
+	if err != nil {
+		return false, fmt.Errorf("invalid window_end %q, use HH:MM", end)
+	}
+	minute := now.Hour()*60 + now.Minute()
+	from := startAt.Hour()*60 + startAt.Minute()
+	to := endAt.Hour()*60 + endAt.Minute()
+	if from <= to {
+		return minute >= from && minute < to, nil
+	}
+	return minute >= from || minute < to, nil
+}
+
+func (s *BalancerService) Config(ctx context.Context) (db.BalancerConfig, error) {
+	return db.GetBalancerConfig(ctx)
+}
+
+func (s *BalancerService) SetConfig(ctx context.Context, cfg db.BalancerConfig) (db.BalancerConfig, error) {
+	if err := validateBalancerConfig(cfg); err != nil {
+		return cfg, err
+	}
+	if err := db.SetBalancerConfig(ctx, cfg); err != nil {
+		return cfg, err
+	}
+	return db.GetBalancerConfig(ctx)
+}
+
+// Plan proposes live migrations from the busiest slaves to the idlest ones
+// until their load is within the threshold. Host load comes from the
+// snapshot history, VM load from what the VMs use now. VMs that cannot live
+// migrate, have pinned cpus or would break a placement rule stay put.
+func (s *BalancerService) Plan(ctx context.Context) (*BalancePlan, error) {
+	cfg, err := db.GetBalancerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := loadPlacementRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &BalancePlan{GeneratedAt: time.Now().UTC().Format(time.RFC3339), Moves: []BalanceMove{}, Skipped: []string{}}
+	var hosts []*balanceHost
+	for _, conn := range protocol.GetConnectionsSnapshot() {
+		if conn.Connection == nil {
+			continue
+		}
+		if err := ensurePlacementAllowed(ctx, conn.MachineName); err != nil {
+			continue
+		}
+		host, skipped, err := s.hostLoad(ctx, conn, time.Duration(cfg.LookbackMinutes)*time.Minute)
+		if err != nil {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s: %v", conn.MachineName, err))
+			continue
+		}
+		plan.Skipped = append(plan.Skipped, skipped...)
+		hosts = append(hosts, host)
+	}
+	for _, host := range hosts {
+		host.Load = host.load()
+	}
+	plan.Spread = balanceSpread(hosts)
+
+	for len(plan.Moves) < cfg.MaxMigrations {
+		move, ok := nextBalanceMove(hosts, rules, cfg.Threshold)
+		if !ok {
+			break
+		}
+		plan.Moves = append(plan.Moves, move)
+	}
+
+	for _, host := range hosts {
+		host.ProjectedLoad = host.load()
+		plan.Hosts = append(plan.Hosts, host.BalanceHost)
+	}
+	slices.SortFunc(plan.Hosts, func(a, b BalanceHost) int { return strings.Compare(a.MachineName, b.MachineName) })
+	plan.ProjectedSpread = balanceSpread(hosts)
+	return plan, nil
+}
+
+func (s *BalancerService) hostLoad(ctx context.Context, conn protocol.ConnectionsStruct, lookback time.Duration) (*balanceHost, []string, error) {
+	infoService := InfoService{}
+	host := &balanceHost{BalanceHost: BalanceHost{MachineName: conn.MachineName}}
+
+	cpu, err := infoService.GetCPUInfo(conn.MachineName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read cpu: %v", err)
+	}
+	mem, err := infoService.GetMemSummary(conn.MachineName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read memory: %v", err)
+	}
+	host.cores = float64(len(cpu.Cores))
+	host.memoryMB = float64(mem.TotalMb)
+	if host.cores == 0 || host.memoryMB == 0 {
+		return nil, nil, fmt.Errorf("reports no cpu or memory")
+	}
+
+	// the history smooths out spikes, a slave without one uses what it
+	// reports now
+	host.CPUPercent = averageCPUUsage(cpu)
+	host.MemoryPercent = mem.UsedPercent
+	since := time.Now().Add(-lookback)
+	if snapshots, err := db.GetCPUSnapshotsSince(ctx, conn.MachineName, since, 0); err == nil && len(snapshots) > 0 {
+		total := 0.0
+		for _, snapshot := range snapshots {
+			total += averageCPUUsage(snapshot.Info)
+		}
+		host.CPUPercent = total / float64(len(snapshots))
+	}
+	if snapshots, err := db.GetMemSnapshotsSince(ctx, conn.MachineName, since, 0); err == nil && len(snapshots) > 0 {
+		total := 0.0
+		for _, snapshot := range snapshots {
+			total += snapshot.Info.GetUsedPercent()
+		}
+		host.MemoryPercent = total / float64(len(snapshots))
+	}
+
+	resp, err := virsh.GetAllVms(conn.Connection, &grpcVirsh.Empty{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not list VMs: %v", err)
+	}
+	var skipped []string
+	for _, vm := range resp.Vms {
+		if vm.State != grpcVirsh.VmState_RUNNING {
+			continue
+		}
+		if migrationMethod(ctx, conn.MachineName, vm) != migrationLive {
+			continue
+		}
+		pinning, err := virsh.GetCPUPinningGRPC(conn.Connection, vm.Name)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: could not read cpu pinning: %v", vm.Name, err))
+			continue
+		}
+		if pinning.HasPinning {
+			continue
+		}
+		memoryMB := float64(vm.RealHostMemUsage)
+		if memoryMB <= 0 {
+			memoryMB = float64(vm.DefinedRam)
+		}
+		host.vms = append(host.vms, balanceVM{
+			vm:       vm,
+			cores:    float64(vm.CurrentCpuUsage) / 100 * float64(vm.CpuCount),
+			memoryMB: memoryMB,
+		})
+	}
+	return host, skipped, nil
+}
+
+func averageCPUUsage(info *infoGrpc.CPUCoreInfo) float64 {
+	cores := info.GetCores()
+	if len(cores) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, core := range cores {
+		total += core.GetUsage()
+	}
+	return total / float64(len(cores))
+}
+
+func balanceSpread(hosts []*balanceHost) float64 {
+	if len(hosts) < 2 {
+		return 0
+	}
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, host := range hosts {
+		low = math.Min(low, host.load())
+		high = math.Max(high, host.load())
+	}
+	return high - low
+}
+
+// nextBalanceMove takes the busiest slave and finds the VM whose move to a
+// less busy slave lowers the busier of the two the most, the move is applied
+// to the hosts so the next call plans on top of it.
+func nextBalanceMove(hosts []*balanceHost, rules *placementRules, threshold float64) (BalanceMove, bool) {
+	if len(hosts) < 2 {
+		return BalanceMove{}, false
+	}
+	sorted := slices.Clone(hosts)
+	slices.SortFunc(sorted, func(a, b *balanceHost) int {
+		if a.load() != b.load() {
+			if a.load() > b.load() {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.MachineName, b.MachineName)
+	})
+	from := sorted[0]
+
+	var bestTo *balanceHost
+	bestVM := -1
+	bestPeak := from.load() - balancerMinGain
+	for _, to := range sorted[1:] {
+		if from.load()-to.load() < threshold {
+			continue
+		}
+		for i, vm := range from.vms {
+			fromCPU, fromMem := from.shift(vm, -1)
+			toCPU, toMem := to.shift(vm, 1)
+			if toMem > 100 {
+				continue
+			}
+			peak := math.Max(math.Max(fromCPU, fromMem), math.Max(toCPU, toMem))
+			if peak >= bestPeak {
+				continue
+			}
+			// soft rules are kept too, the balancer only moves VMs where
+			// they comply
+			if hard, soft := rules.check(vm.vm.Name, to.MachineName); len(hard)+len(soft) > 0 {
+				continue
+			}
+			bestTo, bestVM, bestPeak = to, i, peak
+		}
+	}
+	if bestTo == nil {
+		return BalanceMove{}, false
+	}
+
+	vm := from.vms[bestVM]
+	before := fmt.Sprintf("%s at %.0f%% and %s at %.0f%%", from.MachineName, from.load(), bestTo.MachineName, bestTo.load())
+	from.CPUPercent, from.MemoryPercent = from.shift(vm, -1)
+	bestTo.CPUPercent, bestTo.MemoryPercent = bestTo.shift(vm, 1)
+	from.vms = slices.Delete(from.vms, bestVM, bestVM+1)
+	bestTo.vms = append(bestTo.vms, vm)
+	rules.move(vm.vm.Name, bestTo.MachineName)
+
+	return BalanceMove{
+		VmName: vm.vm.Name,
+		From:   from.MachineName,
+		To:     bestTo.MachineName,
+		Reason: fmt.Sprintf("%s, moving %s (%.1f cores, %.0f MB) leaves them at %.0f%% and %.0f%%",
+			before, vm.vm.Name, vm.cores, vm.memoryMB, from.load(), bestTo.load()),
+	}, true
+}
+
+// Apply runs a plan returned by Plan as a job, the moves are the ones that
+// were reviewed and are not planned again. A plan that is too old or whose
+// VMs moved or stopped since is refused, a new one has to be reviewed.
+func (s *BalancerService) Apply(ctx context.Context, plan *BalancePlan) (int, error) {
+	if plan == nil || len(plan.Moves) == 0 {
+		return 0, fmt.Errorf("the plan has no migrations")
+	}
+	if err := s.checkPlan(ctx, plan); err != nil {
+		return 0, fmt.Errorf("the plan is stale, review a new one: %w", err)
+	}
+
+	return StartJob(JobTypeRebalance, "cluster", longTaskTimeout, func(ctx context.Context, job *JobHandle) error {
+		failed := 0
+		for i, move := range plan.Moves {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			job.Log("moving %s from %s to %s: %s", move.VmName, move.From, move.To, move.Reason)
+			if err := s.applyMove(ctx, move); err != nil {
+				failed++
+				job.Log("could not move %s: %v", move.VmName, err)
+			}
+			job.Progress(float64(i+1) * 100 / float64(len(plan.Moves)))
+		}
+		if failed > 0 {
+			err := fmt.Errorf("%d of %d rebalance migrations failed", failed, len(plan.Moves))
+			nots.SendGlobalNotification("Rebalance incomplete", err.Error(), "/", false)
+			return err
+		}
+		return nil
+	})
+}
+
+// checkPlan makes sure a reviewed plan still describes the cluster.
+func (s *BalancerService) checkPlan(ctx context.Context, plan *BalancePlan) error {
+	generated, err := time.Parse(time.RFC3339, plan.GeneratedAt)
+	if err != nil {
+		return fmt.Errorf("invalid generated_at %q", plan.GeneratedAt)
+	}
+	if age := time.Since(generated); age > balancerPlanMaxAge {
+		return fmt.Errorf("it was made %s ago", age.Round(time.Second))
+	}
+	cfg, err := db.GetBalancerConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if len(plan.Moves) > cfg.MaxMigrations {
+		return fmt.Errorf("it has %d migrations, max_migrations is %d", len(plan.Moves), cfg.MaxMigrations)
+	}
+	seen := map[string]bool{}
+	for _, move := range plan.Moves {
+		if seen[move.VmName] {
+			return fmt.Errorf("%s is moved twice", move.VmName)
+		}
+		seen[move.VmName] = true
+		conn := protocol.GetConnectionByMachineName(move.From)
+		if conn == nil || conn.Connection == nil {
+			return fmt.Errorf("machine %s is not connected", move.From)
+		}
+		vm, err := virsh.GetVmByName(conn.Connection, &grpcVirsh.GetVmByNameRequest{Name: move.VmName})
+		if err != nil || vm == nil {
+			return fmt.Errorf("VM %s is no longer on %s", move.VmName, move.From)
+		}
+		if vm.State != grpcVirsh.VmState_RUNNING {
+			return fmt.Errorf("VM %s is not running", move.VmName)
+		}
+		if to := protocol.GetConnectionByMachineName(move.To); to == nil || to.Connection == nil {
+			return fmt.Errorf("machine %s is not connected", move.To)
+		}
+		if err := ensurePlacementAllowed(ctx, move.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BalancerService) applyMove(ctx context.Context, move BalanceMove) error {
+	conn := protocol.GetConnectionByMachineName(move.From)
+	if conn == nil || conn.Connection == nil {
+		return fmt.Errorf("machine %s is not connected", move.From)
+	}
+	vm, err := virsh.GetVmByName(conn.Connection, &grpcVirsh.GetVmByNameRequest{Name: move.VmName})
+	if err != nil || vm == nil {
+		return fmt.Errorf("VM %s is no longer on %s", move.VmName, move.From)
+	}
+	if vm.State != grpcVirsh.VmState_RUNNING {
+		return fmt.Errorf("VM %s is not running", move.VmName)
+	}
+	if err := ensurePlacementAllowed(ctx, move.To); err != nil {
+		return err
+	}
+	if err := ensurePlacementRules(ctx, move.VmName, move.To); err != nil {
+		return err
+	}
+	return moveVM(ctx, move.From, move.To, vm, migrationLive)
+}
+
+// Maintain applies the plan on its own when auto apply is on, at most once
+// every interval and only inside the window.
+func (s *BalancerService) Maintain(ctx context.Context) {
+	ticker := time.NewTicker(balancerTick)
+	defer ticker.Stop()
+
+	var lastRun time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cfg, err := db.GetBalancerConfig(ctx)
+		if err != nil {
+			logger.Errorf("read balancer config: %v", err)
+			continue
+		}
+		if !cfg.AutoApply || time.Since(lastRun) < time.Duration(cfg.IntervalMinutes)*time.Minute {
+			continue
+		}
+		inWindow, err := inBalancerWindow(time.Now(), cfg.WindowStart, cfg.WindowEnd)
+		if err != nil || !inWindow {
+			continue
+		}
+		if busy, err := rebalanceJobActive(ctx); err != nil || busy {
+			continue
+		}
+
+		lastRun = time.Now()
+		plan, err := s.Plan(ctx)
+		if err != nil {
+			logger.Errorf("plan rebalance: %v", err)
+			continue
+		}
+		if len(plan.Moves) == 0 {
+			continue
+		}
+		jobID, err := s.Apply(ctx, plan)
+		if err != nil {
+			logger.Debug("balancer did not run", "reason", err)
+			continue
+		}
+		logger.Info("balancer started a rebalance", "job", jobID)
+	}
+}
+
+func rebalanceJobActive(ctx context.Context) (bool, error) {
+	for _, state := range []string{db.JobStateQueued, db.JobStateRunning} {
+		jobs, err := db.GetJobs(ctx, db.JobFilter{Type: JobTypeRebalance, State: state, Limit: 1})
+		if err != nil {
+			return false, err
+		}
+		if len(jobs) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -255,15 +255,17 @@ func (s *BTRFSService) AddDiskToRaid(machineName string, uuid string, disk strin
 	if conn == nil {
 		return fmt.Errorf("no connection found for machine: %s", machineName)
 	}
-	go func() {
+	_, err := StartJob(JobTypeBtrfs, uuid, 0, func(ctx context.Context, job *JobHandle) error {
+		job.Log("AddDiskToRaid on %s", machineName)
 		err := btrfs.AddDiskToRaid(conn.Connection, &btrfsGrpc.AddDiskToRaidReq{Uuid: uuid, DiskPath: disk})
 		if err != nil {
 			nots.SendGlobalNotification("Add disk Failed", "BTRFS add disk failed for "+uuid+" on "+machineName+" ("+disk+")", err.Error(), true)
 		} else {
 			nots.SendGlobalNotification("Add disk done", "BTRFS add disk done for "+uuid+" on "+machineName+" ("+disk+")", "Add disk operation is done", true)
 		}
-	}()
-	return nil
+		return err
+	})
+	return err
 }
 
 func (s *BTRFSService) RemoveDiskFromRaid(machineName string, uuid string, disk string) error {
@@ -271,15 +273,17 @@ func (s *BTRFSService) RemoveDiskFromRaid(machineName string, uuid string, disk
 	if conn == nil {
 		return fmt.Errorf("no connection found for machine: %s", machineName)
 	}
-	go func() {
+	_, err := StartJob(JobTypeBtrfs, uuid, 0, func(ctx context.Context, job *JobHandle) error {
+		job.Log("RemoveDiskFromRaid on %s", machineName)
 		err := btrfs.RemoveDiskFromRaid(conn.Connection, &btrfsGrpc.RemoveDiskFromRaidReq{Uuid: uuid, DiskPath: disk})
 		if err != nil {
 			nots.SendGlobalNotification("Remove disk Failed", "BTRFS remove disk failed for "+uuid+" on "+machineName+" ("+disk+")", err.Error(), true)
 		} else {
 			nots.SendGlobalNotification("Remove disk done", "BTRFS remove disk done for "+uuid+" on "+machineName+" ("+disk+")", "Remove disk operation is done", true)
 		}
-	}()
-	return nil
+		return err
+	})
+	return err
 }
 
 func (s *BTRFSService) ReplaceDiskInRaid(machineName string, uuid string, oldDisk string, newDisk string) error {
@@ -287,15 +291,17 @@ func (s *BTRFSService) ReplaceDiskInRaid(machineName string, uuid string, oldDis
 	if conn == nil {
 		return fmt.Errorf("no connection found for machine: %s", machineName)
 	}
-	go func() {
+	_, err := StartJob(JobTypeBtrfs, uuid, 0, func(ctx context.Context, job *JobHandle) error {
+		job.Log("ReplaceDiskInRaid on %s", machineName)
 		err := btrfs.ReplaceDiskInRaid(conn.Connection, &btrfsGrpc.ReplaceDiskToRaidReq{Uuid: uuid, OldDiskPath: oldDisk, NewDiskPath: newDisk})
 		if err != nil {
 			nots.SendGlobalNotification("Replace disk Failed", "BTRFS replace disk failed for "+uuid+" on "+machineName+" ("+oldDisk+" -> "+newDisk+")", err.Error(), true)
 		} else {
 			nots.SendGlobalNotification("Replace disk done", "BTRFS replace disk done for "+uuid+" on "+machineName+" ("+oldDisk+" -> "+newDisk+")", "Replace disk operation is done", true)
 		}
-	}()
-	return nil
+		return err
+	})
+	return err
 }
 
 func (s *BTRFSService) ChangeRaidLevel(machineName string, uuid string, newRaidLevel string) error {
@@ -309,15 +315,17 @@ func (s *BTRFSService) ChangeRaidLevel(machineName string, uuid string, newRaidL
 		return fmt.Errorf("raid type is not valid: %s", newRaidLevel)
 	}
 
-	go func() {
+	_, err := StartJob(JobTypeBtrfs, uuid, 0, func(ctx context.Context, job *JobHandle) error {
+		job.Log("ChangeRaidLevel on %s", machineName)
 		err := btrfs.ChangeRaidLevel(conn.Connection, &btrfsGrpc.ChangeRaidLevelReq{Uuid: uuid, RaidType: raidtype.sType})
 		if err != nil {
 			nots.SendGlobalNotification("Change raid level Failed", "BTRFS change raid level failed for "+uuid+" on "+machineName+" to "+raidtype.sType, err.Error(), true)
 		} else {
 			nots.SendGlobalNotification("Change raid level done", "BTRFS change raid level done for "+uuid+" on "+machineName+" to "+raidtype.sType, "Change raid level operation is done", true)
 		}
-	}()
-	return nil
+		return err
+	})
+	return err
 }
 func (s *BTRFSService) BalanceRaid(machineName string, uuid string, dataUsageMax, metadataUsageMax int32, force, convertToCurrentRaid bool) error {
 	conn := protocol.GetConnectionByMachineName(machineName)
@@ -335,15 +343,29 @@ func (s *BTRFSService) BalanceRaid(machineName string, uuid string, dataUsageMax
 		ConvertToCurrentRaid: convertToCurrentRaid,
 	}
 
-	go func() {
+	_, err := StartJob(JobTypeBtrfs, uuid, 0, func(ctx context.Context, job *JobHandle) error {
+		job.Log("BalanceRaid on %s", machineName)
+		// a running balance can be stopped, the other btrfs operations run to completion
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-ctx.Done():
+				if err := btrfs.CancelBalance(conn.Connection, &btrfsGrpc.UUIDReq{Uuid: uuid}); err != nil {
+					job.Log("cancel balance: %v", err)
+				}
+			case <-stop:
+			}
+		}()
 		err := btrfs.BalanceRaid(conn.Connection, req)
 		if err != nil {
 			nots.SendGlobalNotification("Balance Failed", "BTRFS balance failed for "+uuid+" on "+machineName, err.Error(), true)
 		} else {
 			nots.SendGlobalNotification("Balance done", "BTRFS balance done for "+uuid+" on "+machineName, "Balance operation is done", true)
 		}
-	}()
-	return nil
+		return err
+	})
+	return err
 }
 
 func (s *BTRFSService) DefragmentRaid(machineName string, uuid string) error {
@@ -351,15 +373,17 @@ func (s *BTRFSService) DefragmentRaid(machineName string, uuid string) error {
 	if conn == nil {
 		return fmt.Errorf("no connection found for machine: %s", machineName)
 	}
-	go func() {
+	_, err := StartJob(JobTypeBtrfs, uuid, 0, func(ctx context.Context, job *JobHandle) error {
+		job.Log("DefragmentRaid on %s", machineName)
 		err := btrfs.DefragmentRaid(conn.Connection, &btrfsGrpc.UUIDReq{Uuid: uuid})
 		if err != nil {
 			nots.SendGlobalNotification("Defragment Failed", "BTRFS defragment failed for "+uuid, err.Error(), true)
 		} else {
 			nots.SendGlobalNotification("Defragment done", "BTRFS defragment done for "+uuid, "Defragment operation is done", true)
 		}
-	}()
-	return nil
+		return err
+	})
+	return err
 }
 
 func (s *BTRFSService) ScrubRaid(machineName string, uuid string) error {
@@ -367,15 +391,17 @@ func (s *BTRFSService) ScrubRaid(machineName string, uuid string) error {
 	if conn == nil {
 		return fmt.Errorf("no connection found for machine: %s", machineName)
 	}
-	go func() {
+	_, err := StartJob(JobTypeBtrfs, uuid, 0, func(ctx context.Context, job *JobHandle) error {
+		job.Log("ScrubRaid on %s", machineName)
 		err := btrfs.ScrubRaid(conn.Connection, &btrfsGrpc.UUIDReq{Uuid: uuid})
 		if err != nil {
 			nots.SendGlobalNotification("Scrub Failed", "BTRFS scrub failed for "+uuid, err.Error(), true)
 		} else {
 			nots.SendGlobalNotification("Scrub done", "BTRFS scrub done for "+uuid, "Scrub operation in done", false)
 		}
-	}()
-	return nil
+		return err
+	})
+	return err
 }
 
 func (s *BTRFSService) GetRaidStats(machineName string, uuid string) (*btrfsGrpc.RaidStats, error) {
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"512SvMan/db"
+	"512SvMan/protocol"
+	"512SvMan/virsh"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+)
+
+const (
+	FileRestoreMounting = "mounting"
+	FileRestoreReady    = "ready"
+
+	// a session nobody browsed or downloaded from for this long is unmounted
+	fileRestoreIdleTimeout    = 30 * time.Minute
+	fileRestoreUnmountTimeout = 2 * time.Minute
+	fileRestoreMountTimeout   = 2 * time.Minute
+	fileRestoreTick           = time.Minute
+)
+
+// FileRestoreSession is a backup mounted read-only on a slave to pick files
+// from it instead of restoring the whole vm. It is mounting until the job
+// JobId is done, packed backups are decoded into the slave scratch folder
+// first.
+type FileRestoreSession struct {
+	Id          string                            `json:"id"`
+	BackupId    int                               `json:"backup_id"`
+	VmName      string                            `json:"vm_name"`
+	MachineName string                            `json:"machine_name"`
+	Status      string                            `json:"status"`
+	JobId       int                               `json:"job_id"`
+	Partitions  []*grpcVirsh.FileRestorePartition `json:"partitions"`
+	CreatedAt   string                            `json:"created_at"`
+	LastUsed    string                            `json:"last_used"`
+
+	// scratch is the image a packed backup was decoded to on the slave,
+	// removed on unmount
+	scratch  string
+	lastUsed time.Time
+	// streams running, the session doesn't expire under them
+	active int
+}
+
+// Sessions only live in memory. A master that restarts can't know the ones
+// its previous run left on the slaves, so it closes every session on a slave
+// once, before it opens its own there.
+var (
+	fileRestoreMu       sync.Mutex
+	fileRestoreSessions = map[string]*FileRestoreSession{}
+
+	fileRestoreCleanupMu sync.Mutex
+	fileRestoreCleaned   = map[string]bool{}
+)
+
+// leftoverFileRestoreImage matches the decoded images file restore used to
+// write next to the backup.
+var leftoverFileRestoreImage = regexp.MustCompile(`-files-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\.qcow2$`)
+
+func (s *FileRestoreSession) snapshot() FileRestoreSession {
+	out := *s
+	out.LastUsed = s.lastUsed.UTC().Format(time.RFC3339)
+	return out
+}
+
+// useFileRestore marks the session used and returns the connection to its
+// slave, release has to be called once done with it.
+func useFileRestore(id string) (*FileRestoreSession, *grpc.ClientConn, func(), error) {
+	fileRestoreMu.Lock()
+	defer fileRestoreMu.Unlock()
+	s, ok := fileRestoreSessions[id]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("file restore session %s not found", id)
+	}
+	if s.Status != FileRestoreReady {
+		return nil, nil, nil, fmt.Errorf("file restore session %s is still mounting, see job %d", id, s.JobId)
+	}
+	conn := protocol.GetConnectionByMachineName(s.MachineName)
+	if conn == nil || conn.Connection == nil {
+		return nil, nil, nil, fmt.Errorf("slave %s of file restore session %s is not connected", s.MachineName, id)
+	}
+	s.lastUsed = time.Now()
+	s.active++
+	release := func() {
+		fileRestoreMu.Lock()
+		s.active--
+		s.lastUsed = time.Now()
+		fileRestoreMu.Unlock()
+	}
+	return s, conn.Connection, release, nil
+}
+
+// cleanupSlaveFileRestores closes the sessions a previous run of the master
+// left on machineName, only the first time it is called for it.
+func cleanupSlaveFileRestores(ctx context.Context, machineName string, conn *grpc.ClientConn) error {
+	fileRestoreCleanupMu.Lock()
+	defer fileRestoreCleanupMu.Unlock()
+	if fileRestoreCleaned[machineName] {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, fileRestoreUnmountTimeout)
+	defer cancel()
+	if err := virsh.CleanupFileRestores(ctx, conn); err != nil {
+		return fmt.Errorf("close old file restore sessions on %s: %w", machineName, err)
+	}
+	fileRestoreCleaned[machineName] = true
+	return nil
+}
+
+// MountBackupFiles starts a job that mounts a backup read-only on a slave and
+// lists its partitions, a backup already mounted or mounting reuses its
+// session. Incrementals are mounted with their chain, packed backups are
+// decoded into the scratch folder of the slave first.
+func (v *VirshService) MountBackupFiles(ctx context.Context, backupID int) (*FileRestoreSession, error) {
+	backup, err := getBackup(ctx, backupID)
+	if err != nil {
+		return nil, err
+	}
+	if out, ok := fileRestoreOfBackup(backupID); ok {
+		return &out, nil
+	}
+
+	machineName, conn, err := backupSlave(ctx, backup, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := cleanupSlaveFileRestores(ctx, machineName, conn); err != nil {
+		return nil, err
+	}
+	session := &FileRestoreSession{
+		Id:          uuid.New().String(),
+		BackupId:    backup.Id,
+		VmName:      backup.Name,
+		MachineName: machineName,
+		Status:      FileRestoreMounting,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		lastUsed:    time.Now(),
+	}
+
+	fileRestoreMu.Lock()
+	for _, s := range fileRestoreSessions {
+		if s.BackupId == backupID {
+			// another request got here first
+			s.lastUsed = time.Now()
+			out := s.snapshot()
+			fileRestoreMu.Unlock()
+			return &out, nil
+		}
+	}
+	fileRestoreSessions[session.Id] = session
+	fileRestoreMu.Unlock()
+
+	jobID, err := StartJob(JobTypeMountBackup, backup.Name, longTaskTimeout, func(ctx context.Context, job *JobHandle) error {
+		partitions, err := mountFileRestore(ctx, job, session, backup, conn)
+		fileRestoreMu.Lock()
+		defer fileRestoreMu.Unlock()
+		if err != nil {
+			delete(fileRestoreSessions, session.Id)
+			return err
+		}
+		session.Partitions = partitions
+		session.Status = FileRestoreReady
+		session.lastUsed = time.Now()
+		return nil
+	})
+	fileRestoreMu.Lock()
+	defer fileRestoreMu.Unlock()
+	if err != nil {
+		delete(fileRestoreSessions, session.Id)
+		return nil, err
+	}
+	session.JobId = jobID
+	out := session.snapshot()
+	return &out, nil
+}
+
+func fileRestoreOfBackup(backupID int) (FileRestoreSession, bool) {
+	fileRestoreMu.Lock()
+	defer fileRestoreMu.Unlock()
+	for _, s := range fileRestoreSessions {
+		if s.BackupId == backupID {
+			s.lastUsed = time.Now()
+			return s.snapshot(), true
+		}
+	}
+	return FileRestoreSession{}, false
+}
+
+func mountFileRestore(ctx context.Context, job *JobHandle, session *FileRestoreSession, backup *db.VirshBackup, conn *grpc.ClientConn) ([]*grpcVirsh.FileRestorePartition, error) {
+	image := backup.Path
+	if backup.NeedsDecode() {
+		job.Log("decoding %s to the scratch folder of %s", backup.Path, session.MachineName)
+		name := fileRestoreScratchName(session.Id)
+		path, err := decodeBackupToSlave(ctx, conn, backup, name)
+		if err != nil {
+			return nil, err
+		}
+		// only the job writes it, until the session is ready nothing reads it
+		session.scratch = name
+		image = path
+	}
+
+	job.Log("mounting backup %d on %s", backup.Id, session.MachineName)
+	resp, err := virsh.MountBackupImage(ctx, conn, &grpcVirsh.FileRestoreMountRequest{
+		SessionId:      session.Id,
+		ImagePath:      image,
+		TimeoutSeconds: int32(fileRestoreMountTimeout.Seconds()),
+	})
+	if err != nil {
+		session.removeScratch()
+		return nil, fmt.Errorf("mount backup %d on %s: %w", backup.Id, session.MachineName, err)
+	}
+	logger.Infof("file restore %s: backup %d of %s mounted on %s", session.Id, backup.Id, backup.Name, session.MachineName)
+	return resp.Partitions, nil
+}
+
+// fileRestoreScratchName starts with the prefix the slave cleans up on
+// CleanupFileRestores.
+func fileRestoreScratchName(sessionID string) string {
+	return "files-" + sessionID + ".qcow2"
+}
+
+func (s *FileRestoreSession) removeScratch() {
+	if s.scratch == "" {
+		return
+	}
+	conn := protocol.GetConnectionByMachineName(s.MachineName)
+	if conn == nil || conn.Connection == nil {
+		// the slave empties its scratch folder when it comes back
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), fileRestoreUnmountTimeout)
+	defer cancel()
+	if err := virsh.RemoveScratchImage(ctx, conn.Connection, s.scratch); err != nil {
+		logger.Warnf("file restore %s: remove %s on %s: %v", s.Id, s.scratch, s.MachineName, err)
+	}
+}
+
+// FileRestoreSession returns the session sessionID.
+func (v *VirshService) FileRestoreSession(sessionID string) (*FileRestoreSession, error) {
+	fileRestoreMu.Lock()
+	defer fileRestoreMu.Unlock()
+	s, ok := fileRestoreSessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("file restore session %s not found", sessionID)
+	}
+	out := s.snapshot()
+	return &out, nil
+}
+
+func (v *VirshService) FileRestoreSessions() []FileRestoreSession {
+	fileRestoreMu.Lock()
+	defer fileRestoreMu.Unlock()
+	sessions := make([]FileRestoreSession, 0, len(fileRestoreSessions))
+	for _, s := range fileRestoreSessions {
+		sessions = append(sessions, s.snapshot())
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt < sessions[j].CreatedAt })
+	return sessions
+}
+
+// BrowseBackupFiles lists a directory of a partition of the session, symlinks
+// are listed with their target but never followed.
+func (v *VirshService) BrowseBackupFiles(ctx context.Context, sessionID, partition, path string) (*grpcVirsh.FileRestoreBrowseResponse, error) {
+	_, conn, release, err := useFileRestore(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return virsh.BrowseBackupImage(ctx, conn, &grpcVirsh.FileRestoreBrowseRequest{
+		SessionId: sessionID,
+		Partition: partition,
+		Path:      path,
+	})
+}
+
+// ReadBackupFiles streams one file, or a tar of paths, from the session to w.
+func (v *VirshService) ReadBackupFiles(ctx context.Context, sessionID, partition string, paths []string, tar bool, w io.Writer) error {
+	_, conn, release, err := useFileRestore(sessionID)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return virsh.ReadBackupImageFiles(ctx, conn, &grpcVirsh.FileRestoreReadRequest{
+		SessionId: sessionID,
+		Partition: partition,
+		Paths:     paths,
+		Tar:       tar,
+	}, w)
+}
+
+// UnmountBackupFiles unmounts the session on its slave and forgets it.
+func (v *VirshService) UnmountBackupFiles(ctx context.Context, sessionID string) error {
+	fileRestoreMu.Lock()
+	s, ok := fileRestoreSessions[sessionID]
+	if ok && s.Status != FileRestoreReady {
+		fileRestoreMu.Unlock()
+		return fmt.Errorf("file restore session %s is still mounting, see job %d", sessionID, s.JobId)
+	}
+	if ok && s.active > 0 {
+		fileRestoreMu.Unlock()
+		return fmt.Errorf("file restore session %s has %d downloads running", sessionID, s.active)
+	}
+	fileRestoreMu.Unlock()
+	if !ok {
+		return fmt.Errorf("file restore session %s not found", sessionID)
+	}
+	return closeFileRestore(ctx, s)
+}
+
+func closeFileRestore(ctx context.Context, s *FileRestoreSession) error {
+	ctx, cancel := context.WithTimeout(ctx, fileRestoreUnmountTimeout)
+	defer cancel()
+
+	conn := protocol.GetConnectionByMachineName(s.MachineName)
+	if conn == nil || conn.Connection == nil {
+		// the slave unmounts the sessions of its previous run when it comes back
+		logger.Warnf("file restore %s: slave %s is gone, forgetting the session", s.Id, s.MachineName)
+	} else if err := virsh.UnmountBackupImage(ctx, conn.Connection, &grpcVirsh.FileRestoreSession{
+		SessionId:      s.Id,
+		TimeoutSeconds: int32(fileRestoreUnmountTimeout.Seconds()),
+	}); err != nil {
+		return fmt.Errorf("unmount file restore session %s on %s: %w", s.Id, s.MachineName, err)
+	}
+
+	s.removeScratch()
+	fileRestoreMu.Lock()
+	delete(fileRestoreSessions, s.Id)
+	fileRestoreMu.Unlock()
+	logger.Infof("file restore %s: backup %d unmounted", s.Id, s.BackupId)
+	return nil
+}
+
+// LoopFileRestoreSessions closes the sessions a previous run of the master
+// left on each slave as it connects and unmounts the sessions idle for longer
+// than fileRestoreIdleTimeout.
+func (v *VirshService) LoopFileRestoreSessions(ctx context.Context) {
+	sweepBackupLeftovers(ctx, leftoverFileRestoreImage, "file restore")
+	ticker := time.NewTicker(fileRestoreTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, name := range protocol.GetAllMachineNames() {
+				conn := protocol.GetConnectionByMachineName(name)
+				if conn == nil || conn.Connection == nil {
+					continue
+				}
+				if err := cleanupSlaveFileRestores(ctx, name, conn.Connection); err != nil {
+					logger.Warnf("file restore: %v", err)
+				}
+			}
+
+			var idle []*FileRestoreSession
+			fileRestoreMu.Lock()
+			for _, s := range fileRestoreSessions {
+				if s.Status == FileRestoreReady && s.active == 0 && time.Since(s.lastUsed) > fileRestoreIdleTimeout {
+					idle = append(idle, s)
+				}
+			}
+			fileRestoreMu.Unlock()
+			for _, s := range idle {
+				if err := closeFileRestore(ctx, s); err != nil {
+					logger.Warnf("file restore: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// backupHasFileRestore reports whether a backup is mounted for file restore,
+// it can't be deleted meanwhile.
+func backupHasFileRestore(backupID int) bool {
+	fileRestoreMu.Lock()
+	defer fileRestoreMu.Unlock()
+	for _, s := range fileRestoreSessions {
+		if s.BackupId == backupID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFileRestoreSessionWhileMounting(t *testing.T) {
+	session := &FileRestoreSession{Id: "s1", BackupId: 4, VmName: "web", MachineName: "node1", Status: FileRestoreMounting, JobId: 9}
+	fileRestoreMu.Lock()
+	fileRestoreSessions[session.Id] = session
+	fileRestoreMu.Unlock()
+	t.Cleanup(func() {
+		fileRestoreMu.Lock()
+		delete(fileRestoreSessions, session.Id)
+		fileRestoreMu.Unlock()
+	})
+
+	if !backupHasFileRestore(4) {
+		t.Fatalf("a backup being mounted can't be deleted either")
+	}
+	if _, _, _, err := useFileRestore("s1"); err == nil || !strings.Contains(err.Error(), "still mounting") {
+		t.Fatalf("expected browsing a mounting session to be refused, got %v", err)
+	}
+	v := &VirshService{}
+	if err := v.UnmountBackupFiles(context.Background(), "s1"); err == nil || !strings.Contains(err.Error(), "job 9") {
+		t.Fatalf("expected unmounting a mounting session to be refused, got %v", err)
+	}
+	got, err := v.FileRestoreSession("s1")
+	if err != nil || got.VmName != "web" || got.Status != FileRestoreMounting {
+		t.Fatalf("unexpected session %+v (%v)", got, err)
+	}
+	if _, err := v.FileRestoreSession("missing"); err == nil {
+		t.Fatalf("expected an unknown session to be reported")
+	}
+}
+
+func TestLeftoverFileRestoreImage(t *testing.T) {
+	for name, want := range map[string]bool{
+		"web-files-0f8fad5b-d9cb-469f-a165-70867728950e.qcow2":         true,
+		"my-files-vm-files-0f8fad5b-d9cb-469f-a165-70867728950e.qcow2": true,
+		"web-files.qcow2": false,
+		"files-0f8fad5b-d9cb-469f-a165-70867728950e.qcow2":         false,
+		"web-files-0f8fad5b-d9cb-469f-a165-70867728950e.qcow2.enc": false,
+	} {
+		if got := leftoverFileRestoreImage.MatchString(name); got != want {
+			t.Fatalf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
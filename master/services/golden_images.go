@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"512SvMan/db"
+	"512SvMan/nfs"
+	"512SvMan/protocol"
+	"512SvMan/virsh"
+	vmdisk "512SvMan/vm_disk"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	vmDiskGrpc "github.com/Maruqes/512SvMan/api/proto/vm_disk"
+)
+
+type GoldenImageResult struct {
+	db.GoldenImage
+	Clones []db.LinkedClone `json:"clones"`
+}
+
+func (v *VirshService) ListGoldenImages(ctx context.Context) ([]GoldenImageResult, error) {
+	images, err := db.GetAllGoldenImages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list golden images: %w", err)
+	}
+	results := make([]GoldenImageResult, 0, len(images))
+	for _, img := range images {
+		clones, err := db.GetLinkedClonesByGoldenImage(ctx, img.Id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list clones of %s: %w", img.Name, err)
+		}
+		if clones == nil {
+			clones = []db.LinkedClone{}
+		}
+		results = append(results, GoldenImageResult{GoldenImage: img, Clones: clones})
+	}
+	return results, nil
+}
+
+// MarkVMGolden turns a shut off vm into a base for linked clones. From now on its
+// disk is read only, starting it would corrupt every clone.
+func (v *VirshService) MarkVMGolden(ctx context.Context, vmName string) error {
+	vm, err := v.GetVmByName(vmName)
+	if err != nil {
+		return err
+	}
+	if vm == nil {
+		return fmt.Errorf("vm %s does not exist", vmName)
+	}
+	if vm.State != grpcVirsh.VmState_SHUTOFF {
+		return fmt.Errorf("vm %s needs to be shutdown", vmName)
+	}
+	if existing, err := db.GetGoldenImageByName(ctx, db.GoldenImageKindVM, vmName); err != nil {
+		return fmt.Errorf("failed to check golden image: %w", err)
+	} else if existing != nil {
+		return fmt.Errorf("vm %s is already a golden image", vmName)
+	}
+
+	nfsID, err := v.GetNfsByVM(ctx, vm)
+	if err != nil {
+		return err
+	}
+	if _, err := db.AddGoldenImage(ctx, db.GoldenImageKindVM, vmName, nfsID, filepath.Clean(vm.DiskPath)); err != nil {
+		return fmt.Errorf("failed to save golden image: %w", err)
+	}
+	return nil
+}
+
+func (v *VirshService) UnmarkVMGolden(ctx context.Context, vmName string) error {
+	return unmarkGolden(ctx, db.GoldenImageKindVM, vmName)
+}
+
+// FlattenVM copies the golden image data into the clone disk so it no longer
+// depends on its base.
+func (v *VirshService) FlattenVM(ctx context.Context, vmName string) error {
+	clone, err := db.GetLinkedCloneByName(ctx, db.GoldenImageKindVM, vmName)
+	if err != nil {
+		return fmt.Errorf("failed to get linked clone: %w", err)
+	}
+	if clone == nil {
+		return fmt.Errorf("vm %s is not a linked clone", vmName)
+	}
+
+	vm, err := v.GetVmByName(vmName)
+	if err != nil {
+		return err
+	}
+	if vm == nil {
+		return fmt.Errorf("vm %s does not exist", vmName)
+	}
+	if vm.State != grpcVirsh.VmState_SHUTOFF {
+		return fmt.Errorf("vm %s needs to be shutdown", vmName)
+	}
+	slave := protocol.GetConnectionByMachineName(vm.MachineName)
+	if slave == nil || slave.Connection == nil {
+		return fmt.Errorf("slave %s no connected", vm.MachineName)
+	}
+
+	res, err := vmdisk.FlattenDisk(ctx, slave.Connection, &vmDiskGrpc.VMDiskByPathRequest{DiskPath: vm.DiskPath})
+	if err != nil {
+		return fmt.Errorf("failed to flatten %s: %w", vmName, err)
+	}
+	if res == nil || !res.GetOk() {
+		return fmt.Errorf("failed to flatten %s", vmName)
+	}
+	if err := nfs.Sync(slave.Connection); err != nil {
+		return fmt.Errorf("failed to sync NFS after flatten: %w", err)
+	}
+	return db.RemoveLinkedClone(ctx, db.GoldenImageKindVM, vmName)
+}
+
+// createLinkedCloneDisk creates the overlay for a new clone of a golden vm, the
+// clone is recorded by the caller once the vm is defined.
+func createLinkedCloneDisk(ctx context.Context, golden *db.GoldenImage, machineName, diskPath string) error {
+	slave := protocol.GetConnectionByMachineName(machineName)
+	if slave == nil || slave.Connection == nil {
+		return fmt.Errorf("slave %s no connected", machineName)
+	}
+	res, err := vmdisk.CreateOverlayDisk(slave.Connection, &vmDiskGrpc.CreateOverlayDiskRequest{
+		BackingPath: golden.DiskPath,
+		DiskPath:    diskPath,
+	})
+	if err != nil {
+		return fmt.Errorf("create overlay: %w", err)
+	}
+	if res == nil || !res.GetOk() {
+		return fmt.Errorf("create overlay failed")
+	}
+	return nfs.Sync(slave.Connection)
+}
+
+// copyVMDisk makes a standalone copy of a vm disk. Linked clones are merged with
+// their base on the slave, a plain file copy would only take the overlay.
+func (v *VirshService) copyVMDisk(ctx context.Context, vm *grpcVirsh.Vm, dest string) error {
+	clone, err := db.GetLinkedCloneByName(ctx, db.GoldenImageKindVM, vm.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get linked clone: %w", err)
+	}
+	if clone == nil {
+		return copyFile(ctx, vm.DiskPath, dest, vm.Name)
+	}
+
+	slave := protocol.GetConnectionByMachineName(vm.MachineName)
+	if slave == nil || slave.Connection == nil {
+		return fmt.Errorf("slave %s no connected", vm.MachineName)
+	}
+	return virsh.FlattenBackupChain(ctx, slave.Connection, vm.DiskPath, dest)
+}
+
+func isLinkedCloneVM(ctx context.Context, vmName string) (bool, error) {
+	clone, err := db.GetLinkedCloneByName(ctx, db.GoldenImageKindVM, vmName)
+	if err != nil {
+		return false, fmt.Errorf("failed to get linked clone: %w", err)
+	}
+	return clone != nil, nil
+}
+
+// checkGoldenDeletable refuses while clones still read from the image.
+func checkGoldenDeletable(ctx context.Context, kind, name string) (*db.GoldenImage, error) {
+	golden, err := db.GetGoldenImageByName(ctx, kind, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get golden image: %w", err)
+	}
+	if golden == nil {
+		return nil, nil
+	}
+	clones, err := db.GetLinkedClonesByGoldenImage(ctx, golden.Id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get linked clones: %w", err)
+	}
+	if len(clones) > 0 {
+		names := make([]string, 0, len(clones))
+		for _, c := range clones {
+			names = append(names, c.Name)
+		}
+		return nil, fmt.Errorf("%s is the golden image of %s, flatten or delete them first", name, strings.Join(names, ", "))
+	}
+	return golden, nil
+}
+
+func unmarkGolden(ctx context.Context, kind, name string) error {
+	golden, err := checkGoldenDeletable(ctx, kind, name)
+	if err != nil {
+		return err
+	}
+	if golden == nil {
+		return fmt.Errorf("%s is not a golden image", name)
+	}
+	return db.RemoveGoldenImage(ctx, golden.Id)
+}
+
+// forgetGoldenRecords drops the golden/clone rows of a deleted vm or disk.
+func forgetGoldenRecords(ctx context.Context, kind, name string) error {
+	golden, err := db.GetGoldenImageByName(ctx, kind, name)
+	if err != nil {
+		return fmt.Errorf("failed to get golden image: %w", err)
+	}
+	if golden != nil {
+		if err := db.RemoveGoldenImage(ctx, golden.Id); err != nil {
+			return fmt.Errorf("failed to remove golden image: %w", err)
+		}
+	}
+	if err := db.RemoveLinkedClone(ctx, kind, name); err != nil {
+		return fmt.Errorf("failed to remove linked clone: %w", err)
+	}
+	return nil
+}
+
+func (s *VMDiskService) MarkGolden(ctx context.Context, id int) error {
+	disk, err := db.GetVMDiskByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get VM disk by ID: %w", err)
+	}
+	if disk == nil {
+		return fmt.Errorf("VM disk with ID %d not found", id)
+	}
+	if strings.TrimSpace(disk.AttachedVMName) != "" {
+		return fmt.Errorf("VM disk %d is attached to %s, detach it first", id, disk.AttachedVMName)
+	}
+	if existing, err := db.GetGoldenImageByName(ctx, db.GoldenImageKindDisk, disk.Name); err != nil {
+		return fmt.Errorf("failed to check golden image: %w", err)
+	} else if existing != nil {
+		return fmt.Errorf("VM disk %s is already a golden image", disk.Name)
+	}
+	if _, err := db.AddGoldenImage(ctx, db.GoldenImageKindDisk, disk.Name, disk.NFSID, filepath.Clean(disk.DiskPath)); err != nil {
+		return fmt.Errorf("failed to save golden image: %w", err)
+	}
+	return nil
+}
+
+func (s *VMDiskService) UnmarkGolden(ctx context.Context, id int) error {
+	disk, err := db.GetVMDiskByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get VM disk by ID: %w", err)
+	}
+	if disk == nil {
+		return fmt.Errorf("VM disk with ID %d not found", id)
+	}
+	return unmarkGolden(ctx, db.GoldenImageKindDisk, disk.Name)
+}
+
+// LinkedClone creates a new vm disk backed by a golden disk on the same share.
+func (s *VMDiskService) LinkedClone(ctx context.Context, id int, name string) (*VMDiskResult, error) {
+	name = strings.TrimSpace(name)
+	base, err := db.GetVMDiskByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VM disk by ID: %w", err)
+	}
+	if base == nil {
+		return nil, fmt.Errorf("VM disk with ID %d not found", id)
+	}
+	golden, err := db.GetGoldenImageByName(ctx, db.GoldenImageKindDisk, base.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get golden image: %w", err)
+	}
+	if golden == nil {
+		return nil, fmt.Errorf("VM disk %s is not a golden image", base.Name)
+	}
+	if exists, err := db.DoesVMDiskNameExist(ctx, name); err != nil {
+		return nil, fmt.Errorf("failed to check VM disk name: %w", err)
+	} else if exists {
+		return nil, fmt.Errorf("VM disk with name %s already exists", name)
+	}
+
+	conn, target, err := s.readyNFSConnection(ctx, base.NFSID)
+	if err != nil {
+		return nil, err
+	}
+
+	// same layout CreateVMDisk uses so the rest of the vm disk code finds it
+	diskPath := filepath.Join(target, "vm_disk_"+name, name+".qcow2")
+	res, err := vmdisk.CreateOverlayDisk(conn.Connection, &vmDiskGrpc.CreateOverlayDiskRequest{
+		BackingPath: golden.DiskPath,
+		DiskPath:    diskPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res == nil || !res.GetOk() {
+		return nil, fmt.Errorf("failed to create linked clone")
+	}
+	if err := nfs.Sync(conn.Connection); err != nil {
+		return nil, fmt.Errorf("failed to sync NFS after creating linked clone: %w", err)
+	}
+
+	newID, err := db.AddVMDisk(ctx, name, base.NFSID, res.GetDiskPath(), res.GetFolderPath(), res.GetFormat(), res.GetSizeGB())
+	if err != nil {
+		_, _ = vmdisk.DeleteVMDisk(conn.Connection, &vmDiskGrpc.VMDiskByNameRequest{BasePath: target, Name: name})
+		return nil, fmt.Errorf("failed to register VM disk: %w", err)
+	}
+	if _, err := db.AddLinkedClone(ctx, golden.Id, db.GoldenImageKindDisk, name, res.GetDiskPath()); err != nil {
+		return nil, fmt.Errorf("failed to record linked clone: %w", err)
+	}
+
+	result := convertVMDiskResponse(res)
+	result.Id = newID
+	result.Name = name
+	result.NFSID = base.NFSID
+	return result, nil
+}
+
+func (s *VMDiskService) Flatten(ctx context.Context, id int) (*VMDiskResult, error) {
+	disk, err := db.GetVMDiskByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VM disk by ID: %w", err)
+	}
+	if disk == nil {
+		return nil, fmt.Errorf("VM disk with ID %d not found", id)
+	}
+	clone, err := db.GetLinkedCloneByName(ctx, db.GoldenImageKindDisk, disk.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get linked clone: %w", err)
+	}
+	if clone == nil {
+		return nil, fmt.Errorf("VM disk %s is not a linked clone", disk.Name)
+	}
+	if strings.TrimSpace(disk.AttachedVMName) != "" {
+		return nil, fmt.Errorf("VM disk %d is attached to %s, detach it first", id, disk.AttachedVMName)
+	}
+
+	conn, _, err := s.readyNFSConnection(ctx, disk.NFSID)
+	if err != nil {
+		return nil, err
+	}
+	res, err := vmdisk.FlattenDisk(ctx, conn.Connection, &vmDiskGrpc.VMDiskByPathRequest{DiskPath: disk.DiskPath})
+	if err != nil {
+		return nil, err
+	}
+	if res == nil || !res.GetOk() {
+		return nil, fmt.Errorf("failed to flatten VM disk")
+	}
+	if err := nfs.Sync(conn.Connection); err != nil {
+		return nil, fmt.Errorf("failed to sync NFS after flatten: %w", err)
+	}
+	if err := db.RemoveLinkedClone(ctx, db.GoldenImageKindDisk, disk.Name); err != nil {
+		return nil, fmt.Errorf("failed to remove linked clone: %w", err)
+	}
+	return convertDBAndRPCVMDisk(disk, res), nil
+}
@@ -0,0 +1,651 @@
+package services
+
+import (
+	"512SvMan/db"
+	"512SvMan/env512"
+	"512SvMan/nfs"
+	"512SvMan/nots"
+	"512SvMan/protocol"
+	"512SvMan/virsh"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	nfsGrpc "github.com/Maruqes/512SvMan/api/proto/nfs"
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+const (
+	// haHeartbeatDir must match the folder the slaves write on each share
+	haHeartbeatDir   = ".512svman-ha"
+	haCheckInterval  = 10 * time.Second
+	haSyncInterval   = time.Minute
+	haRestartWindow  = 24 * time.Hour
+	haHeartbeatRead  = 3 * time.Second
+	haDefineTimeout  = 2 * time.Minute
+	haHistoryDefault = 100
+)
+
+// haStartupWait is how long the master waits after starting before it treats
+// a slave it never saw as dead, slaves need some time to reconnect.
+const haStartupWait = 5 * time.Minute
+
+type HAService struct{}
+
+// haDownHost is a slave that stopped answering, heartbeats is what its share
+// heartbeat files held when it went down.
+type haDownHost struct {
+	since      time.Time
+	heartbeats map[string]string
+}
+
+var (
+	haMu       sync.Mutex
+	haSeen     = map[string]bool{}
+	haDown     = map[string]*haDownHost{}
+	haReclaims sync.Mutex
+)
+
+func (s *HAService) ListPolicies(ctx context.Context) ([]db.HAPolicy, error) {
+	policies, err := db.GetHAPolicies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ha policies: %w", err)
+	}
+	if policies == nil {
+		policies = []db.HAPolicy{}
+	}
+	return policies, nil
+}
+
+func (s *HAService) GetPolicy(ctx context.Context, vmName string) (*db.HAPolicy, error) {
+	policy, err := db.GetHAPolicy(ctx, vmName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ha policy: %w", err)
+	}
+	if policy == nil {
+		return nil, fmt.Errorf("vm %s has no ha policy", vmName)
+	}
+	return policy, nil
+}
+
+// SetPolicy saves the policy of a VM and stores its domain XML right away so
+// it is protected before the next sync.
+func (s *HAService) SetPolicy(ctx context.Context, policy db.HAPolicy) (*db.HAPolicy, error) {
+	policy.VmName = strings.TrimSpace(policy.VmName)
+	if policy.VmName == "" {
+		return nil, fmt.Errorf("vm name is required")
+	}
+	if policy.MaxRestarts < 0 {
+		return nil, fmt.Errorf("max_restarts must be 0 or more")
+	}
+	hosts := make([]string, 0, len(policy.PreferredHosts))
+	for _, host := range policy.PreferredHosts {
+		host = strings.TrimSpace(host)
+		if host == "" || slices.Contains(hosts, host) {
+			continue
+		}
+		node, err := db.GetNodeByName(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check host %s: %w", host, err)
+		}
+		if node == nil {
+			return nil, fmt.Errorf("unknown host %s", host)
+		}
+		hosts = append(hosts, host)
+	}
+	policy.PreferredHosts = hosts
+
+	machine, err := findVMMachine(policy.VmName, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := db.SetHAPolicy(ctx, policy); err != nil {
+		return nil, fmt.Errorf("failed to save ha policy: %w", err)
+	}
+	if policy.Enabled {
+		if err := s.syncDomain(ctx, machine, policy.VmName); err != nil {
+			logger.Warn("failed to store ha domain", "vm", policy.VmName, "error", err)
+		}
+	}
+	return s.GetPolicy(ctx, policy.VmName)
+}
+
+func (s *HAService) DeletePolicy(ctx context.Context, vmName string) error {
+	if err := db.DeleteHAPolicy(ctx, vmName); err != nil {
+		return fmt.Errorf("failed to delete ha policy: %w", err)
+	}
+	return nil
+}
+
+// Failovers returns the failover history, of every VM when vmName is empty.
+func (s *HAService) Failovers(ctx context.Context, vmName string, limit int) ([]db.HAFailover, error) {
+	if limit <= 0 {
+		limit = haHistoryDefault
+	}
+	failovers, err := db.GetHAFailovers(ctx, vmName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list failovers: %w", err)
+	}
+	if failovers == nil {
+		failovers = []db.HAFailover{}
+	}
+	return failovers, nil
+}
+
+// findVMMachine returns the connected machine that has the VM defined,
+// skipping exclude.
+func findVMMachine(vmName, exclude string) (string, error) {
+	for _, c := range protocol.GetConnectionsSnapshot() {
+		if c.Connection == nil || c.MachineName == exclude {
+			continue
+		}
+		vm, err := virsh.GetVmByName(c.Connection, &grpcVirsh.GetVmByNameRequest{Name: vmName})
+		if err == nil && vm != nil {
+			return c.MachineName, nil
+		}
+	}
+	return "", fmt.Errorf("failed to find VM %s on any connected machine", vmName)
+}
+
+func (s *HAService) syncDomain(ctx context.Context, machine, vmName string) error {
+	virshService := VirshService{}
+	vmXML, err := virshService.GetVmXML(machine, vmName)
+	if err != nil {
+		return err
+	}
+	return db.SetHADomain(ctx, vmName, machine, vmXML)
+}
+
+// SyncDomains stores the current domain XML and host of every enabled HA VM
+// that can be reached, VMs on unreachable hosts keep what was stored before.
+func (s *HAService) SyncDomains(ctx context.Context) {
+	policies, err := db.GetHAPolicies(ctx)
+	if err != nil {
+		logger.Errorf("list ha policies: %v", err)
+		return
+	}
+	enabled := map[string]bool{}
+	for _, policy := range policies {
+		if policy.Enabled {
+			enabled[policy.VmName] = true
+		}
+	}
+	if len(enabled) == 0 {
+		return
+	}
+
+	virshService := VirshService{}
+	vms, _, err := virshService.GetAllVms(ctx)
+	if err != nil {
+		logger.Debug("ha sync could not reach every slave", "error", err)
+	}
+	for _, vm := range vms {
+		if !enabled[vm.Name] {
+			continue
+		}
+		if err := s.syncDomain(ctx, vm.MachineName, vm.Name); err != nil {
+			logger.Warn("failed to store ha domain", "vm", vm.Name, "machine", vm.MachineName, "error", err)
+		}
+	}
+}
+
+// Monitor watches the hosts of the HA VMs, a host that stays unreachable for
+// HA_GRACE_PERIOD and passes the fencing check gets its VMs restarted on the
+// remaining slaves.
+func (s *HAService) Monitor(ctx context.Context) {
+	started := time.Now()
+	var lastSync time.Time
+	ticker := time.NewTicker(haCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(lastSync) >= haSyncInterval {
+				s.SyncDomains(ctx)
+				lastSync = time.Now()
+			}
+			s.checkHosts(ctx, started)
+		}
+	}
+}
+
+func (s *HAService) checkHosts(ctx context.Context, started time.Time) {
+	domains, err := db.GetHADomains(ctx, "")
+	if err != nil {
+		logger.Errorf("list ha domains: %v", err)
+		return
+	}
+	byMachine := map[string][]db.HADomain{}
+	for _, domain := range domains {
+		byMachine[domain.MachineName] = append(byMachine[domain.MachineName], domain)
+	}
+
+	for machine, machineDomains := range byMachine {
+		if protocol.GetConnectionByMachineName(machine) != nil {
+			haMu.Lock()
+			haSeen[machine] = true
+			delete(haDown, machine)
+			haMu.Unlock()
+			continue
+		}
+
+		haMu.Lock()
+		seen := haSeen[machine]
+		down := haDown[machine]
+		haMu.Unlock()
+		if !seen && time.Since(started) < haStartupWait {
+			continue
+		}
+		node, err := db.GetNodeByName(ctx, machine)
+		if err != nil || node == nil || node.State != db.NodeStateApproved {
+			continue
+		}
+		// a host in maintenance is expected to go away, its VMs were moved
+		if err := ensurePlacementAllowed(ctx, machine); err != nil {
+			continue
+		}
+
+		if down == nil {
+			logger.Warnf("slave %s is unreachable, its HA VMs restart in %s unless it comes back", machine, env512.HAGracePeriod)
+			beats, _ := readHAHeartbeats(ctx, machine)
+			haMu.Lock()
+			haDown[machine] = &haDownHost{since: time.Now(), heartbeats: beats}
+			haMu.Unlock()
+			continue
+		}
+		if time.Since(down.since) < env512.HAGracePeriod {
+			continue
+		}
+
+		if reason := fenceCheck(ctx, node, down); reason != "" {
+			logger.Warnf("slave %s is unreachable but %s, not restarting its HA VMs", machine, reason)
+			haMu.Lock()
+			down.since = time.Now()
+			haMu.Unlock()
+			continue
+		}
+
+		s.failoverHost(ctx, machine, machineDomains)
+		haMu.Lock()
+		down.since = time.Now()
+		haMu.Unlock()
+	}
+}
+
+// fenceCheck returns why the host may still be alive, empty when it is safe
+// to take its VMs over.
+func fenceCheck(ctx context.Context, node *db.Node, down *haDownHost) string {
+	current, unreadable := readHAHeartbeats(ctx, node.MachineName)
+	// a share the master can't read says nothing about the host
+	if len(unreadable) > 0 {
+		return "its heartbeat on " + strings.Join(unreadable, ", ") + " can't be read here"
+	}
+	for target, beat := range current {
+		if beat != "" && beat != down.heartbeats[target] {
+			down.heartbeats = current
+			return "it still writes heartbeats on " + target
+		}
+	}
+	if node.Addr != "" {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(node.Addr, "50052"), haHeartbeatRead)
+		if err == nil {
+			conn.Close()
+			return "its slave port still answers"
+		}
+	}
+	return ""
+}
+
+// readHAHeartbeats reads the heartbeat of a machine on every share mounted
+// here. A missing heartbeat file reads as empty, a share that hangs, fails or
+// has no heartbeat folder at all is returned in unreadable.
+func readHAHeartbeats(ctx context.Context, machine string) (map[string]string, []string) {
+	beats := map[string]string{}
+	var unreadable []string
+	shares, err := db.GetAllNFShares(ctx)
+	if err != nil {
+		logger.Errorf("list nfs shares: %v", err)
+		return beats, []string{"the nfs share list"}
+	}
+	for _, share := range shares {
+		target := strings.TrimSpace(share.Target)
+		if target == "" {
+			continue
+		}
+		if _, ok := beats[target]; ok || slices.Contains(unreadable, target) {
+			continue
+		}
+		dir := filepath.Join(target, haHeartbeatDir)
+		type result struct {
+			beat string
+			err  error
+		}
+		done := make(chan result, 1)
+		go func() {
+			// an unmounted share is an empty folder here, it has no heartbeat dir
+			if _, err := os.Stat(dir); err != nil {
+				done <- result{err: err}
+				return
+			}
+			data, err := os.ReadFile(filepath.Join(dir, machine))
+			if os.IsNotExist(err) {
+				done <- result{}
+				return
+			}
+			done <- result{beat: strings.TrimSpace(string(data)), err: err}
+		}()
+		select {
+		case res := <-done:
+			if res.err != nil {
+				logger.Warnf("read ha heartbeat of %s on %s: %v", machine, target, res.err)
+				unreadable = append(unreadable, target)
+				continue
+			}
+			beats[target] = res.beat
+		case <-time.After(haHeartbeatRead):
+			logger.Warnf("read ha heartbeat of %s on %s timed out", machine, target)
+			unreadable = append(unreadable, target)
+		}
+	}
+	return beats, unreadable
+}
+
+type haDomainMemory struct {
+	Memory struct {
+		Value uint64 `xml:",chardata"`
+		Unit  string `xml:"unit,attr"`
+	} `xml:"memory"`
+}
+
+// domainMemoryMB returns the memory of a domain XML in MiB, 0 when unknown.
+func domainMemoryMB(vmXML string) int {
+	var parsed haDomainMemory
+	if err := xml.Unmarshal([]byte(vmXML), &parsed); err != nil {
+		return 0
+	}
+	value := parsed.Memory.Value
+	switch strings.ToLower(parsed.Memory.Unit) {
+	case "b", "bytes":
+		value /= 1024 * 1024
+	case "", "k", "kib":
+		value /= 1024
+	case "kb":
+		value = value * 1000 / (1024 * 1024)
+	case "m", "mib":
+	case "mb":
+		value = value * 1000 * 1000 / (1024 * 1024)
+	case "g", "gib":
+		value *= 1024
+	case "gb":
+		value = value * 1000 * 1000 * 1000 / (1024 * 1024)
+	default:
+		return 0
+	}
+	return int(value)
+}
+
+type haDomainDisks struct {
+	Devices struct {
+		Disks []struct {
+			Source struct {
+				File string `xml:"file,attr"`
+			} `xml:"source"`
+		} `xml:"disk"`
+	} `xml:"devices"`
+}
+
+// domainShares returns the shares holding the disks of a domain XML, the
+// host a VM restarts on must have every one of them mounted.
+func domainShares(vmXML string, shares []db.NFSShare) []db.NFSShare {
+	var parsed haDomainDisks
+	if err := xml.Unmarshal([]byte(vmXML), &parsed); err != nil {
+		return nil
+	}
+	var needed []db.NFSShare
+	for _, disk := range parsed.Devices.Disks {
+		if disk.Source.File == "" {
+			continue
+		}
+		file := filepath.Clean(disk.Source.File)
+		for _, share := range shares {
+			target := filepath.Clean(strings.TrimSpace(share.Target))
+			if target == "." || !strings.HasPrefix(file, target+string(filepath.Separator)) {
+				continue
+			}
+			if !slices.ContainsFunc(needed, func(s db.NFSShare) bool { return s.Id == share.Id }) {
+				needed = append(needed, share)
+			}
+			break
+		}
+	}
+	return needed
+}
+
+// haHostsWithShares keeps the hosts of freeMB that have every share mounted,
+// mounted caches the answers of the slaves by host and share id.
+func haHostsWithShares(freeMB map[string]int, needed []db.NFSShare, mounted map[string]bool) map[string]int {
+	hosts := map[string]int{}
+	for host, free := range freeMB {
+		conn := protocol.GetConnectionByMachineName(host)
+		if conn == nil || conn.Connection == nil {
+			continue
+		}
+		ok := true
+		for _, share := range needed {
+			key := fmt.Sprintf("%s/%d", host, share.Id)
+			working, cached := mounted[key]
+			if !cached {
+				status, err := nfs.GetSharedFolderStatus(conn.Connection, &nfsGrpc.FolderMount{
+					MachineName: share.MachineName,
+					FolderPath:  share.FolderPath,
+					Source:      share.Source,
+					Target:      share.Target,
+				})
+				working = err == nil && status.GetWorking()
+				mounted[key] = working
+			}
+			if !working {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			hosts[host] = free
+		}
+	}
+	return hosts
+}
+
+// pickHAHost chooses where a VM restarts, the first preferred host with room
+// for it, else the host with the most free memory.
+func pickHAHost(preferred []string, freeMB map[string]int, needMB int) string {
+	for _, host := range preferred {
+		if free, ok := freeMB[host]; ok && free >= needMB {
+			return host
+		}
+	}
+	best := ""
+	for host, free := range freeMB {
+		if free < needMB {
+			continue
+		}
+		if best == "" || free > freeMB[best] || (free == freeMB[best] && host < best) {
+			best = host
+		}
+	}
+	return best
+}
+
+func (s *HAService) failoverHost(ctx context.Context, machine string, domains []db.HADomain) {
+	policies, err := db.GetHAPolicies(ctx)
+	if err != nil {
+		logger.Errorf("list ha policies: %v", err)
+		return
+	}
+	byVM := map[string]db.HADomain{}
+	for _, domain := range domains {
+		byVM[domain.VmName] = domain
+	}
+
+	freeMB := freeMemoryByHost(ctx, machine)
+	shares, err := db.GetAllNFShares(ctx)
+	if err != nil {
+		logger.Errorf("list nfs shares: %v", err)
+		return
+	}
+	mounted := map[string]bool{}
+	rules, err := loadPlacementRules(ctx)
+	if err != nil {
+		logger.Errorf("placement rules are ignored for this failover: %v", err)
+		rules = &placementRules{locations: map[string]string{}}
+	}
+
+	// a host that reconnects is reclaimed under the same lock, so it either
+	// sees every failover recorded here or none of them start
+	haReclaims.Lock()
+	defer haReclaims.Unlock()
+
+	logger.Warnf("slave %s is down, restarting its HA VMs", machine)
+	// policies come highest priority first
+	for _, policy := range policies {
+		domain, ok := byVM[policy.VmName]
+		if !ok || !policy.Enabled {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return
+		}
+		if protocol.GetConnectionByMachineName(machine) != nil {
+			logger.Warnf("slave %s came back, stopping HA restarts", machine)
+			return
+		}
+
+		// the VM may already run elsewhere, a migration the sync did not see yet
+		if other, err := findVMMachine(policy.VmName, machine); err == nil {
+			if err := s.syncDomain(ctx, other, policy.VmName); err != nil {
+				logger.Warn("failed to store ha domain", "vm", policy.VmName, "error", err)
+			}
+			continue
+		}
+
+		if policy.MaxRestarts > 0 {
+			restarts, err := db.CountHARestarts(ctx, policy.VmName, time.Now().Add(-haRestartWindow))
+			if err != nil {
+				logger.Errorf("count restarts of %s: %v", policy.VmName, err)
+				continue
+			}
+			if restarts >= policy.MaxRestarts {
+				s.recordFailover(ctx, policy.VmName, machine, "", fmt.Errorf("reached %d restarts in the last day", policy.MaxRestarts))
+				continue
+			}
+		}
+
+		needMB := domainMemoryMB(domain.XML)
+		candidates := haHostsWithShares(freeMB, domainShares(domain.XML, shares), mounted)
+		target := pickHAHost(policy.PreferredHosts, rules.hostsFor(policy.VmName, candidates, needMB), needMB)
+		if target == "" {
+			s.recordFailover(ctx, policy.VmName, machine, "", fmt.Errorf("no slave allowed by the placement rules has the VM disks mounted and %d MB free", needMB))
+			continue
+		}
+		conn := protocol.GetConnectionByMachineName(target)
+		if conn == nil || conn.Connection == nil {
+			s.recordFailover(ctx, policy.VmName, machine, target, fmt.Errorf("slave %s disconnected", target))
+			continue
+		}
+
+		defineCtx, cancel := context.WithTimeout(ctx, haDefineTimeout)
+		err := virsh.DefineVMFromXML(defineCtx, conn.Connection, policy.VmName, domain.XML, true)
+		cancel()
+		if err != nil {
+			s.recordFailover(ctx, policy.VmName, machine, target, err)
+			continue
+		}
+		freeMB[target] -= needMB
+		rules.move(policy.VmName, target)
+		if err := db.SetHADomain(ctx, policy.VmName, target, domain.XML); err != nil {
+			logger.Errorf("store ha domain of %s: %v", policy.VmName, err)
+		}
+		s.recordFailover(ctx, policy.VmName, machine, target, nil)
+	}
+}
+
+func (s *HAService) recordFailover(ctx context.Context, vmName, from, to string, failure error) {
+	failover := db.HAFailover{VmName: vmName, FromMachine: from, ToMachine: to, State: db.HAFailoverRestarted}
+	title := "VM restarted by HA"
+	body := fmt.Sprintf("%s was restarted on %s after %s went down", vmName, to, from)
+	if failure != nil {
+		failover.State = db.HAFailoverFailed
+		failover.Message = failure.Error()
+		title = "HA restart failed"
+		body = fmt.Sprintf("%s could not be restarted after %s went down: %v", vmName, from, failure)
+		logger.Error("ha restart failed", "vm", vmName, "from", from, "to", to, "error", failure)
+	} else {
+		logger.Warn("ha restarted vm", "vm", vmName, "from", from, "to", to)
+	}
+	if err := db.AddHAFailover(ctx, failover); err != nil {
+		logger.Errorf("record failover of %s: %v", vmName, err)
+	}
+	nots.SendGlobalNotification(title, body, "/", true)
+}
+
+// ReclaimHost runs when a slave connects, before its VMs are autostarted. VMs
+// that HA restarted elsewhere while it was down are stopped and undefined on
+// it so they never run twice, their disks are left alone.
+func (s *HAService) ReclaimHost(ctx context.Context, machine string) error {
+	haReclaims.Lock()
+	defer haReclaims.Unlock()
+
+	haMu.Lock()
+	haSeen[machine] = true
+	delete(haDown, machine)
+	haMu.Unlock()
+
+	failovers, err := db.GetUncleanedHAFailovers(ctx, machine)
+	if err != nil {
+		return fmt.Errorf("list failovers of %s: %w", machine, err)
+	}
+	if len(failovers) == 0 {
+		return nil
+	}
+	conn := protocol.GetConnectionByMachineName(machine)
+	if conn == nil || conn.Connection == nil {
+		return fmt.Errorf("slave %s not connected", machine)
+	}
+
+	for _, failover := range failovers {
+		vm, err := virsh.GetVmByName(conn.Connection, &grpcVirsh.GetVmByNameRequest{Name: failover.VmName})
+		if err != nil || vm == nil {
+			if err := db.MarkHAFailoverCleaned(ctx, failover.Id); err != nil {
+				logger.Errorf("mark failover %d cleaned: %v", failover.Id, err)
+			}
+			continue
+		}
+		other, err := findVMMachine(failover.VmName, machine)
+		if err != nil {
+			logger.Warnf("%s is back on %s and was not found on any other slave, leaving it", failover.VmName, machine)
+			continue
+		}
+		if err := virsh.UndefineStaleVM(conn.Connection, vm); err != nil {
+			logger.Errorf("remove stale %s from %s: %v", failover.VmName, machine, err)
+			continue
+		}
+		if err := db.MarkHAFailoverCleaned(ctx, failover.Id); err != nil {
+			logger.Errorf("mark failover %d cleaned: %v", failover.Id, err)
+		}
+		logger.Warnf("removed stale copy of %s from %s, it runs on %s", failover.VmName, machine, other)
+		nots.SendGlobalNotification("HA host came back",
+			fmt.Sprintf("the old copy of %s on %s was stopped and undefined, it keeps running on %s", failover.VmName, machine, other), "/", false)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"512SvMan/db"
 	"512SvMan/info"
 	"512SvMan/protocol"
+	"512SvMan/virsh"
 	"context"
 	"fmt"
 	"time"
@@ -109,6 +110,10 @@ func (s *InfoService) GetNetworkHistory(ctx context.Context, machineName string,
 	return db.GetNetworkSnapshotsSince(ctx, machineName, historySinceDuration(duration), numberOfRows)
 }
 
+func (s *InfoService) GetVMHistory(ctx context.Context, vmName string, duration time.Duration, numberOfRows int) ([]db.VMMetricsSnapshot, error) {
+	return db.GetVMMetricsSince(ctx, vmName, historySinceDuration(duration), numberOfRows)
+}
+
 func (s *InfoService) StressCPU(ctx context.Context, machineName string, params *infoGrpc.StressCPUParams) (*infoGrpc.Empty, error) {
 	conStruct := protocol.GetConnectionByMachineName(machineName)
 	if conStruct == nil || conStruct.Connection == nil {
@@ -159,6 +164,28 @@ func (s *InfoService) collectSlaveSnapshots(ctx context.Context) {
 	machineNames := protocol.GetAllMachineNames()
 	for _, machineName := range machineNames {
 		s.collectMachineSnapshots(ctx, machineName)
+		s.collectVMMetrics(ctx, machineName)
+	}
+	if err := db.DownsampleVMMetrics(ctx, time.Now()); err != nil {
+		logger.Errorf("failed to downsample vm metrics: %v", err)
+	}
+}
+
+func (s *InfoService) collectVMMetrics(ctx context.Context, machineName string) {
+	conStruct := protocol.GetConnectionByMachineName(machineName)
+	if conStruct == nil || conStruct.Connection == nil {
+		return
+	}
+
+	statsCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	resp, err := virsh.GetVmStats(statsCtx, conStruct.Connection)
+	if err != nil {
+		logger.Debugf("vm metrics skipped for %s: %v", machineName, err)
+		return
+	}
+	if err := db.InsertVMMetrics(ctx, machineName, time.Now(), resp.GetVms()); err != nil {
+		logger.Errorf("failed to persist vm metrics for %s: %v", machineName, err)
 	}
 }
 
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"512SvMan/db"
+
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+// Job types, each one gets its own concurrency limit in jobTypeLimits.
+const (
+	JobTypeBackup          = "backup"
+	JobTypeRestoreBackup   = "restore_backup"
+	JobTypeMigrate         = "migrate"
+	JobTypeColdMigrate     = "cold_migrate"
+	JobTypeMoveDisk        = "move_disk"
+	JobTypeCloneVM         = "clone_vm"
+	JobTypeISODownload     = "iso_download"
+	JobTypeVMDiskImport    = "vm_disk_import"
+	JobTypeBtrfs           = "btrfs"
+	JobTypeSmartDisk       = "smartdisk"
+	JobTypeMaintenance     = "maintenance"
+	JobTypeRebalance       = "rebalance"
+	JobTypeOrderedShutdown = "ordered_shutdown"
+	JobTypeVerifyBackup    = "verify_backup"
+	JobTypeTestRestore     = "test_restore"
+	JobTypeReplicateBackup = "replicate_backup"
+	JobTypeFetchReplica    = "fetch_replica"
+	JobTypeMountBackup     = "mount_backup"
+)
+
+var jobTypeLimits = map[string]int{
+	JobTypeBackup:          2,
+	JobTypeRestoreBackup:   2,
+	JobTypeMigrate:         4,
+	JobTypeColdMigrate:     2,
+	JobTypeMoveDisk:        1,
+	JobTypeCloneVM:         2,
+	JobTypeISODownload:     3,
+	JobTypeVMDiskImport:    2,
+	JobTypeBtrfs:           1,
+	JobTypeSmartDisk:       4,
+	JobTypeMaintenance:     2,
+	JobTypeRebalance:       1,
+	JobTypeOrderedShutdown: 1,
+	JobTypeVerifyBackup:    1,
+	JobTypeTestRestore:     1,
+	JobTypeReplicateBackup: 2,
+	JobTypeFetchReplica:    1,
+	JobTypeMountBackup:     2,
+}
+
+const (
+	defaultJobTypeLimit = 2
+	jobRetention        = 30 * 24 * time.Hour
+	jobProgressInterval = 2 * time.Second
+)
+
+// JobFunc is the body of a job, it must give up when ctx is canceled.
+type JobFunc func(ctx context.Context, job *JobHandle) error
+
+// JobHandle is handed to a running job to report progress and log lines.
+type JobHandle struct {
+	Id   int
+	Type string
+
+	mu           sync.Mutex
+	lastProgress time.Time
+}
+
+type runningJob struct {
+	handle *JobHandle
+	cancel context.CancelFunc
+}
+
+var (
+	jobsMu      sync.Mutex
+	runningJobs = map[int]*runningJob{}
+	jobSlots    = map[string]chan struct{}{}
+)
+
+type jobContextKey struct{}
+
+func jobSlot(jobType string) chan struct{} {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	slot, ok := jobSlots[jobType]
+	if !ok {
+		limit := jobTypeLimits[jobType]
+		if limit <= 0 {
+			limit = defaultJobTypeLimit
+		}
+		slot = make(chan struct{}, limit)
+		jobSlots[jobType] = slot
+	}
+	return slot
+}
+
+// RecoverJobs runs at startup, jobs of a previous master process can't be
+// resumed so they are closed as interrupted, and old history is trimmed.
+func RecoverJobs(ctx context.Context) error {
+	n, err := db.MarkInterruptedJobs(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logger.Warnf("%d job(s) were interrupted by the last master shutdown", n)
+	}
+	return db.DeleteJobsFinishedBefore(ctx, time.Now().Add(-jobRetention))
+}
+
+// StartJob records a job and runs fn in the background once a slot for its type
+// is free. timeout <= 0 means no deadline.
+func StartJob(jobType, target string, timeout time.Duration, fn JobFunc) (int, error) {
+	job := &db.Job{Type: jobType, Target: target}
+	if err := db.InsertJob(context.Background(), job); err != nil {
+		return 0, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	handle := &JobHandle{Id: job.Id, Type: jobType}
+	ctx = context.WithValue(ctx, jobContextKey{}, handle)
+
+	jobsMu.Lock()
+	runningJobs[job.Id] = &runningJob{handle: handle, cancel: cancel}
+	jobsMu.Unlock()
+
+	go runJob(ctx, cancel, handle, target, fn)
+	return job.Id, nil
+}
+
+func runJob(ctx context.Context, cancel context.CancelFunc, job *JobHandle, target string, fn JobFunc) {
+	defer func() {
+		jobsMu.Lock()
+		delete(runningJobs, job.Id)
+		jobsMu.Unlock()
+		cancel()
+	}()
+
+	slot := jobSlot(job.Type)
+	select {
+	case slot <- struct{}{}:
+		defer func() { <-slot }()
+	case <-ctx.Done():
+		finishJob(job, ctx.Err())
+		return
+	}
+
+	if err := db.MarkJobRunning(context.Background(), job.Id); err != nil {
+		logger.Errorf("job %d: mark running: %v", job.Id, err)
+	}
+	job.Log("started %s on %s", job.Type, target)
+
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		err = fn(ctx, job)
+	}()
+
+	// a job that returned a wrapped error after being canceled still counts as canceled
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	finishJob(job, err)
+}
+
+func finishJob(job *JobHandle, err error) {
+	state := db.JobStateSucceeded
+	msg := ""
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled):
+		state = db.JobStateCanceled
+		msg = err.Error()
+	default:
+		state = db.JobStateFailed
+		msg = err.Error()
+	}
+	if msg != "" {
+		job.Log("%s: %s", state, msg)
+	}
+	if dbErr := db.FinishJob(context.Background(), job.Id, state, msg); dbErr != nil {
+		logger.Errorf("job %d: finish: %v", job.Id, dbErr)
+	}
+}
+
+// Log appends a line to the job log.
+func (j *JobHandle) Log(format string, args ...any) {
+	if j == nil {
+		return
+	}
+	if err := db.AppendJobLog(context.Background(), j.Id, fmt.Sprintf(format, args...)); err != nil {
+		logger.Errorf("job %d: append log: %v", j.Id, err)
+	}
+}
+
+// Progress stores the completion percentage, throttled so tight copy loops
+// don't hammer sqlite.
+func (j *JobHandle) Progress(pct float64) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	if pct < 100 && time.Since(j.lastProgress) < jobProgressInterval {
+		j.mu.Unlock()
+		return
+	}
+	j.lastProgress = time.Now()
+	j.mu.Unlock()
+
+	if pct < 0 {
+		pct = 0
+	} else if pct > 100 {
+		pct = 100
+	}
+	if err := db.UpdateJobProgress(context.Background(), j.Id, pct); err != nil {
+		logger.Errorf("job %d: update progress: %v", j.Id, err)
+	}
+}
+
+// jobFromContext returns the job running ctx, nil (which is safe to call
+// Log/Progress on) when the code is not running inside a job.
+func jobFromContext(ctx context.Context) *JobHandle {
+	if ctx == nil {
+		return nil
+	}
+	job, _ := ctx.Value(jobContextKey{}).(*JobHandle)
+	return job
+}
+
+type JobService struct{}
+
+type JobDetails struct {
+	db.Job
+	Logs []db.JobLog `json:"logs"`
+}
+
+func (s *JobService) List(ctx context.Context, filter db.JobFilter) ([]db.Job, error) {
+	jobs, err := db.GetJobs(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	if jobs == nil {
+		jobs = []db.Job{}
+	}
+	return jobs, nil
+}
+
+func (s *JobService) Get(ctx context.Context, id int) (*JobDetails, error) {
+	job, err := db.GetJobByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	if job == nil {
+		return nil, nil
+	}
+	logs, err := db.GetJobLogs(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job logs: %w", err)
+	}
+	if logs == nil {
+		logs = []db.JobLog{}
+	}
+	return &JobDetails{Job: *job, Logs: logs}, nil
+}
+
+func (s *JobService) Cancel(ctx context.Context, id int) error {
+	jobsMu.Lock()
+	running, ok := runningJobs[id]
+	jobsMu.Unlock()
+	if ok {
+		running.handle.Log("cancel requested")
+		running.cancel()
+		return nil
+	}
+
+	job, err := db.GetJobByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get job: %w", err)
+	}
+	if job == nil {
+		return fmt.Errorf("job %d not found", id)
+	}
+	return fmt.Errorf("job %d is already %s", id, strings.ToLower(job.State))
+}
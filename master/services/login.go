@@ -1,14 +1,212 @@
 package services
 
-import "512SvMan/npm"
+import (
+	"512SvMan/db"
+	"512SvMan/env512"
+	"512SvMan/npm"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
 
-type LoginService struct {}
+	"github.com/Maruqes/512SvMan/logger"
+	"golang.org/x/crypto/bcrypt"
+)
 
-func (s *LoginService) Login(baseUrl, email, password string) (string, error) {
-	return npm.Login(baseUrl, email, password)
+const SessionTTL = 24 * time.Hour
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+type LoginService struct{}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Login checks the local users first and then, when enabled, nginx proxy
+// manager. An npm account gets a user on its first login, the first user
+// ever becomes admin so a fresh install is not locked out.
+func (s *LoginService) Login(ctx context.Context, baseUrl, login, password string) (string, *db.User, error) {
+	user, err := db.GetUserByLogin(ctx, login)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	npmToken := ""
+	switch {
+	case user != nil && user.Source == db.UserSourceLocal:
+		if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+			return "", nil, ErrInvalidCredentials
+		}
+	case env512.NPMLogin:
+		npmToken, err = npm.Login(baseUrl, login, password)
+		if err != nil {
+			return "", nil, ErrInvalidCredentials
+		}
+		if user == nil {
+			user, err = s.provisionNPMUser(ctx, login)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+	default:
+		return "", nil, ErrInvalidCredentials
+	}
+	if user.Disabled {
+		return "", nil, ErrInvalidCredentials
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+	if err := db.AddUserSession(ctx, hashSessionToken(token), user.Id, npmToken, time.Now().Add(SessionTTL)); err != nil {
+		return "", nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	if err := db.TouchUserLogin(ctx, user.Id); err != nil {
+		logger.Warn("failed to record login", "user", user.Username, "error", err)
+	}
+	return token, user, nil
+}
+
+func (s *LoginService) provisionNPMUser(ctx context.Context, email string) (*db.User, error) {
+	count, err := db.CountUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	role := env512.NPMDefaultRole
+	if !slices.Contains(Roles, role) {
+		logger.Warnf("NPM_DEFAULT_ROLE %q is not a role, using %s", role, db.RoleViewer)
+		role = db.RoleViewer
+	}
+	if count == 0 {
+		role = db.RoleAdmin
+	}
+	user, err := db.AddUser(ctx, db.User{Username: email, Email: email, Role: role, Source: db.UserSourceNPM})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	logger.Infof("npm account %s logged in for the first time, created as %s", email, role)
+	return user, nil
+}
+
+// Authenticate returns the principal of a session token, nil when the token
+// is unknown, expired or its user is disabled.
+func (s *LoginService) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	session, err := db.GetUserSession(ctx, hashSessionToken(token))
+	if err != nil || session == nil {
+		return nil, err
+	}
+	user, err := db.GetUserByID(ctx, session.UserId)
+	if err != nil || user == nil || user.Disabled {
+		return nil, err
+	}
+	grants, err := db.GetUserGrants(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{User: *user, Grants: grants, NPMToken: session.NPMToken}, nil
 }
 
-func (s *LoginService) IsLoginValid(baseUrl, token string) bool {
-	_, err := npm.GetAllUsers(baseUrl, token)
-	return err == nil
+func (s *LoginService) Logout(ctx context.Context, token string) error {
+	return db.DeleteUserSession(ctx, hashSessionToken(token))
+}
+
+var npmService struct {
+	mu       sync.Mutex
+	token    string
+	loggedAt time.Time
+}
+
+// NPMServiceToken logs in with NPM_EMAIL/NPM_PASSWORD, local users reach the
+// proxy manager through this account. The token is reused for a few hours.
+func (s *LoginService) NPMServiceToken(baseUrl string) (string, error) {
+	if env512.NPMServiceEmail == "" {
+		return "", fmt.Errorf("NPM_EMAIL and NPM_PASSWORD are not set, local users cannot use nginx proxy manager")
+	}
+	npmService.mu.Lock()
+	defer npmService.mu.Unlock()
+	if npmService.token != "" && time.Since(npmService.loggedAt) < 6*time.Hour {
+		return npmService.token, nil
+	}
+	token, err := npm.Login(baseUrl, env512.NPMServiceEmail, env512.NPMServicePassword)
+	if err != nil {
+		return "", fmt.Errorf("npm service login failed: %w", err)
+	}
+	npmService.token = token
+	npmService.loggedAt = time.Now()
+	return token, nil
+}
+
+// CleanupSessions drops expired sessions every hour.
+func (s *LoginService) CleanupSessions(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if err := db.DeleteExpiredUserSessions(ctx); err != nil {
+			logger.Error("failed to delete expired sessions", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EnsureAdmin creates an admin with a random password when there are no users
+// and npm login is off, otherwise nobody could ever log in.
+func (s *LoginService) EnsureAdmin(ctx context.Context) error {
+	if env512.NPMLogin {
+		return nil
+	}
+	count, err := db.CountUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count users: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	password := hex.EncodeToString(raw)
+	// the logs end up in the log viewer, the password only goes to a file root can read
+	if err := writeAdminPasswordFile(password); err != nil {
+		return err
+	}
+	if _, err := (&UserService{}).Create(ctx, "admin", "", password, db.RoleAdmin); err != nil {
+		_ = os.Remove(initialAdminPasswordFile)
+		return err
+	}
+	logger.Warn("created the first admin user, its password is in "+initialAdminPasswordFile+", delete the file and change the password after logging in", "username", "admin")
+	return nil
+}
+
+const initialAdminPasswordFile = "initial-admin-password"
+
+func writeAdminPasswordFile(password string) error {
+	f, err := os.OpenFile(initialAdminPasswordFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", initialAdminPasswordFile, err)
+	}
+	// an older file may have been created with wider permissions
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to chmod %s: %w", initialAdminPasswordFile, err)
+	}
+	if _, err := f.WriteString(password + "\n"); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", initialAdminPasswordFile, err)
+	}
+	return f.Close()
 }
@@ -0,0 +1,468 @@
+package services
+
+import (
+	"512SvMan/db"
+	"512SvMan/nots"
+	"512SvMan/protocol"
+	"512SvMan/virsh"
+	"context"
+	"fmt"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+const (
+	migrationLive = "live"
+	migrationCold = "cold"
+
+	maintenanceShutdownWait = 5 * time.Minute
+	maintenancePollInterval = 2 * time.Second
+)
+
+type MaintenanceService struct{}
+
+// MaintenanceRequest puts a host in maintenance, Update runs a system update
+// (of Package, or everything when empty) once the host is empty and Restart
+// reboots it.
+type MaintenanceRequest struct {
+	Reason  string `json:"reason"`
+	Update  bool   `json:"update"`
+	Package string `json:"package"`
+	Restart bool   `json:"restart"`
+}
+
+// MaintenanceStatus is a host with the VMs of its last evacuation,
+// Maintenance is nil once the host left maintenance.
+type MaintenanceStatus struct {
+	MachineName string              `json:"machine_name"`
+	Maintenance *db.HostMaintenance `json:"maintenance"`
+	VMs         []db.MaintenanceVM  `json:"vms"`
+}
+
+// ensurePlacementAllowed refuses new VMs on hosts in maintenance.
+func ensurePlacementAllowed(ctx context.Context, machineName string) error {
+	m, err := db.GetHostMaintenance(ctx, machineName)
+	if err != nil {
+		return fmt.Errorf("failed to check maintenance of %s: %w", machineName, err)
+	}
+	if m != nil {
+		return fmt.Errorf("machine %s is in maintenance, no VM can be placed on it", machineName)
+	}
+	return nil
+}
+
+// freeMemoryByHost returns the free memory of every connected slave that can
+// take VMs, skipping exclude.
+func freeMemoryByHost(ctx context.Context, exclude string) map[string]int {
+	infoService := InfoService{}
+	free := map[string]int{}
+	for _, c := range protocol.GetConnectionsSnapshot() {
+		if c.Connection == nil || c.MachineName == exclude {
+			continue
+		}
+		if err := ensurePlacementAllowed(ctx, c.MachineName); err != nil {
+			continue
+		}
+		mem, err := infoService.GetMemSummary(c.MachineName)
+		if err != nil {
+			logger.Warn("could not read memory of a slave", "machine", c.MachineName, "error", err)
+			continue
+		}
+		free[c.MachineName] = int(mem.FreeMb)
+	}
+	return free
+}
+
+func (s *MaintenanceService) Status(ctx context.Context, machineName string) (*MaintenanceStatus, error) {
+	m, err := db.GetHostMaintenance(ctx, machineName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance: %w", err)
+	}
+	vms, err := db.GetMaintenanceVMs(ctx, machineName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get evacuated vms: %w", err)
+	}
+	if vms == nil {
+		vms = []db.MaintenanceVM{}
+	}
+	return &MaintenanceStatus{MachineName: machineName, Maintenance: m, VMs: vms}, nil
+}
+
+// List returns every host in maintenance.
+func (s *MaintenanceService) List(ctx context.Context) ([]MaintenanceStatus, error) {
+	hosts, err := db.GetHostMaintenances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list maintenance: %w", err)
+	}
+	statuses := make([]MaintenanceStatus, 0, len(hosts))
+	for _, host := range hosts {
+		status, err := s.Status(ctx, host.MachineName)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, *status)
+	}
+	return statuses, nil
+}
+
+// Enter blocks new VMs on the host and starts a job that moves its running
+// VMs to the other slaves, it returns the job id.
+func (s *MaintenanceService) Enter(ctx context.Context, machineName string, req MaintenanceRequest) (int, error) {
+	conn := protocol.GetConnectionByMachineName(machineName)
+	if conn == nil || conn.Connection == nil {
+		return 0, fmt.Errorf("machine %s is not connected", machineName)
+	}
+	m, err := db.GetHostMaintenance(ctx, machineName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get maintenance: %w", err)
+	}
+	if m != nil {
+		return 0, fmt.Errorf("machine %s is already in maintenance", machineName)
+	}
+	if err := db.StartHostMaintenance(ctx, machineName, req.Reason); err != nil {
+		return 0, fmt.Errorf("failed to start maintenance: %w", err)
+	}
+	if err := db.ClearMaintenanceVMs(ctx, machineName); err != nil {
+		return 0, fmt.Errorf("failed to clear last evacuation: %w", err)
+	}
+
+	jobID, err := StartJob(JobTypeMaintenance, machineName, longTaskTimeout, func(jobCtx context.Context, job *JobHandle) error {
+		defer func() {
+			if err := db.SetHostMaintenanceState(context.Background(), machineName, db.MaintenanceActive, job.Id); err != nil {
+				logger.Errorf("set maintenance of %s: %v", machineName, err)
+			}
+		}()
+		return s.evacuate(jobCtx, job, machineName, req)
+	})
+	if err != nil {
+		_ = db.DeleteHostMaintenance(ctx, machineName)
+		return 0, err
+	}
+	if err := db.SetHostMaintenanceJob(ctx, machineName, jobID); err != nil {
+		logger.Errorf("set maintenance of %s: %v", machineName, err)
+	}
+	logger.Infof("machine %s entering maintenance, job %d", machineName, jobID)
+	return jobID, nil
+}
+
+func (s *MaintenanceService) evacuate(ctx context.Context, job *JobHandle, machineName string, req MaintenanceRequest) error {
+	conn := protocol.GetConnectionByMachineName(machineName)
+	if conn == nil || conn.Connection == nil {
+		return fmt.Errorf("machine %s is not connected", machineName)
+	}
+	resp, err := virsh.GetAllVms(conn.Connection, &grpcVirsh.Empty{})
+	if err != nil {
+		return fmt.Errorf("failed to list VMs on %s: %w", machineName, err)
+	}
+
+	var running []*grpcVirsh.Vm
+	var rows []*db.MaintenanceVM
+	for _, vm := range resp.Vms {
+		if vm.State == grpcVirsh.VmState_SHUTOFF {
+			continue
+		}
+		row, err := db.AddMaintenanceVM(ctx, db.MaintenanceVM{MachineName: machineName, VmName: vm.Name, State: db.MaintenanceVMPending})
+		if err != nil {
+			return fmt.Errorf("failed to record %s: %w", vm.Name, err)
+		}
+		running = append(running, vm)
+		rows = append(rows, row)
+	}
+	job.Log("%d running VM(s) to move off %s", len(running), machineName)
+
+	free := freeMemoryByHost(ctx, machineName)
+	rules, err := loadPlacementRules(ctx)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for i, vm := range running {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row := rows[i]
+		target := pickHAHost(nil, rules.hostsFor(vm.Name, free, int(vm.DefinedRam)), int(vm.DefinedRam))
+		if target == "" {
+			failed++
+			s.finishRow(job, row, db.MaintenanceVMFailed, fmt.Errorf("no slave allowed by the placement rules has %d MB free", vm.DefinedRam))
+			continue
+		}
+
+		row.TargetMachine = target
+		row.Method = migrationMethod(ctx, machineName, vm)
+		s.finishRow(job, row, db.MaintenanceVMMigrating, nil)
+		if err := moveVM(ctx, machineName, target, vm, row.Method); err != nil {
+			failed++
+			s.finishRow(job, row, db.MaintenanceVMFailed, err)
+		} else {
+			free[target] -= int(vm.DefinedRam)
+			rules.move(vm.Name, target)
+			s.finishRow(job, row, db.MaintenanceVMMigrated, nil)
+		}
+		job.Progress(float64(i+1) * 100 / float64(len(running)))
+	}
+	job.Progress(100)
+
+	if failed > 0 {
+		err := fmt.Errorf("%d of %d VMs could not be moved off %s", failed, len(running), machineName)
+		if req.Update || req.Restart {
+			job.Log("skipping update and restart, VMs are still running on %s", machineName)
+		}
+		nots.SendGlobalNotification("Maintenance evacuation incomplete", err.Error(), "/", true)
+		return err
+	}
+
+	extraService := ExtraService{}
+	if req.Update {
+		job.Log("updating %s", machineName)
+		if err := extraService.PerformUpdate(machineName, req.Package, req.Restart); err != nil {
+			return fmt.Errorf("update %s: %w", machineName, err)
+		}
+	} else if req.Restart {
+		job.Log("restarting %s", machineName)
+		if err := extraService.Restart(machineName, true); err != nil {
+			return fmt.Errorf("restart %s: %w", machineName, err)
+		}
+	}
+	job.Log("%s is empty and in maintenance", machineName)
+	return nil
+}
+
+func (s *MaintenanceService) finishRow(job *JobHandle, row *db.MaintenanceVM, state string, failure error) {
+	row.State = state
+	row.Message = ""
+	if failure != nil {
+		row.Message = failure.Error()
+		job.Log("%s: %s", row.VmName, failure)
+	} else {
+		job.Log("%s: %s (%s to %s)", row.VmName, state, row.Method, row.TargetMachine)
+	}
+	if err := db.UpdateMaintenanceVM(context.Background(), *row); err != nil {
+		logger.Errorf("update evacuated vm %s: %v", row.VmName, err)
+	}
+}
+
+// migrationMethod is live for running VMs marked live with no passthrough
+// device, everything else is moved cold.
+func migrationMethod(ctx context.Context, machineName string, vm *grpcVirsh.Vm) string {
+	if vm.State != grpcVirsh.VmState_RUNNING {
+		return migrationCold
+	}
+	live, err := db.DoesVmLiveExist(ctx, vm.Name)
+	if err != nil || !live {
+		return migrationCold
+	}
+	pciService := PCIService{}
+	gpus, err := pciService.ListVMGPUs(ctx, machineName, vm.Name)
+	if err != nil || len(gpus.GetGpus()) > 0 {
+		return migrationCold
+	}
+	return migrationLive
+}
+
+// moveVM moves a VM between two slaves. A cold move shuts the VM down and
+// defines it on the target the same way a manual cold migration does, the
+// source definition is only removed once the target has it. Any failure
+// before that starts the VM on the source again.
+func moveVM(ctx context.Context, from, to string, vm *grpcVirsh.Vm, method string) error {
+	fromConn := protocol.GetConnectionByMachineName(from)
+	if fromConn == nil || fromConn.Connection == nil {
+		return fmt.Errorf("machine %s is not connected", from)
+	}
+	toConn := protocol.GetConnectionByMachineName(to)
+	if toConn == nil || toConn.Connection == nil {
+		return fmt.Errorf("machine %s is not connected", to)
+	}
+
+	if method == migrationLive {
+		return runMigration(ctx, fromConn, toConn, vm.Name, true, 0, MigrationOptions{}, nil)
+	}
+
+	virshService := VirshService{}
+	live, err := virshService.isVmLive(ctx, vm.Name)
+	if err != nil {
+		return err
+	}
+	wasRunning := vm.State != grpcVirsh.VmState_SHUTOFF
+	restart := func(failure error) error {
+		if !wasRunning {
+			return failure
+		}
+		current, err := virsh.GetVmByName(fromConn.Connection, &grpcVirsh.GetVmByNameRequest{Name: vm.Name})
+		if err == nil && current != nil && current.State != grpcVirsh.VmState_SHUTOFF {
+			return failure
+		}
+		if err := virsh.StartVm(context.Background(), fromConn.Connection, vm); err != nil {
+			return fmt.Errorf("%w (restart on %s failed: %v)", failure, from, err)
+		}
+		return failure
+	}
+
+	if wasRunning {
+		if err := stopVMAndWait(ctx, fromConn, vm); err != nil {
+			return restart(err)
+		}
+	}
+
+	// the target gets a definition rebuilt from the VM settings, host devices
+	// and anything else tied to this host stay behind
+	coldMigr := &grpcVirsh.ColdMigrationRequest{
+		VmName:      vm.Name,
+		DiskPath:    vm.DiskPath,
+		Memory:      vm.DefinedRam,
+		VCpus:       vm.DefinedCPUS,
+		Network:     vm.Network,
+		VncPassword: vm.VNCPassword,
+		CpuXML:      vm.CPUXML,
+		Live:        live,
+	}
+	if err := virshService.ColdMigrateVm(ctx, to, coldMigr, 0); err != nil {
+		// drop whatever part of the VM the target got before starting the source again
+		if current, lookupErr := virsh.GetVmByName(toConn.Connection, &grpcVirsh.GetVmByNameRequest{Name: vm.Name}); lookupErr == nil && current != nil {
+			if undefineErr := virsh.UndefineVM(toConn.Connection, current); undefineErr != nil {
+				return fmt.Errorf("define on %s: %w (cleanup failed: %v, not restarting on %s)", to, err, undefineErr, from)
+			}
+		}
+		return restart(fmt.Errorf("define on %s: %w", to, err))
+	}
+	// a cold migration boots the VM, keep a stopped VM stopped
+	if !wasRunning {
+		if err := virsh.ForceShutdownVM(toConn.Connection, vm); err != nil {
+			logger.Warnf("moved %s to %s but failed to stop it: %v", vm.Name, to, err)
+		}
+	}
+	if err := virsh.UndefineVM(fromConn.Connection, vm); err != nil {
+		return fmt.Errorf("moved to %s but failed to undefine it on %s: %w", to, from, err)
+	}
+	return nil
+}
+
+// stopVMAndWait shuts a VM down, forcing it off when it ignores the request.
+func stopVMAndWait(ctx context.Context, conn *protocol.ConnectionsStruct, vm *grpcVirsh.Vm) error {
+	if err := virsh.ShutdownVM(conn.Connection, vm); err != nil {
+		return fmt.Errorf("shutdown %s: %w", vm.Name, err)
+	}
+	deadline := time.Now().Add(maintenanceShutdownWait)
+	for time.Now().Before(deadline) {
+		current, err := virsh.GetVmByName(conn.Connection, &grpcVirsh.GetVmByNameRequest{Name: vm.Name})
+		if err == nil && current != nil && current.State == grpcVirsh.VmState_SHUTOFF {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(maintenancePollInterval):
+		}
+	}
+	logger.Warnf("%s did not shut down in %s, forcing it off", vm.Name, maintenanceShutdownWait)
+	if err := virsh.ForceShutdownVM(conn.Connection, vm); err != nil {
+		return fmt.Errorf("force shutdown %s: %w", vm.Name, err)
+	}
+	return nil
+}
+
+// Exit takes the host out of maintenance, its evacuated VMs stay where they
+// are until Return is called.
+func (s *MaintenanceService) Exit(ctx context.Context, machineName string) (*MaintenanceStatus, error) {
+	m, err := db.GetHostMaintenance(ctx, machineName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance: %w", err)
+	}
+	if m == nil {
+		return nil, fmt.Errorf("machine %s is not in maintenance", machineName)
+	}
+	if m.State == db.MaintenanceEvacuating {
+		// a master restart interrupts the job without finishing the evacuation
+		job, err := db.GetJobByID(ctx, m.JobId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get job: %w", err)
+		}
+		if job != nil && (job.State == db.JobStateQueued || job.State == db.JobStateRunning) {
+			return nil, fmt.Errorf("machine %s is still being evacuated, wait for job %d or cancel it", machineName, m.JobId)
+		}
+	}
+	if err := db.DeleteHostMaintenance(ctx, machineName); err != nil {
+		return nil, fmt.Errorf("failed to exit maintenance: %w", err)
+	}
+	logger.Infof("machine %s left maintenance", machineName)
+	return s.Status(ctx, machineName)
+}
+
+// Return moves the VMs evacuated from a host back to it, it returns the job
+// id.
+func (s *MaintenanceService) Return(ctx context.Context, machineName string) (int, error) {
+	if err := ensurePlacementAllowed(ctx, machineName); err != nil {
+		return 0, err
+	}
+	conn := protocol.GetConnectionByMachineName(machineName)
+	if conn == nil || conn.Connection == nil {
+		return 0, fmt.Errorf("machine %s is not connected", machineName)
+	}
+	vms, err := db.GetMaintenanceVMs(ctx, machineName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get evacuated vms: %w", err)
+	}
+	var toReturn []db.MaintenanceVM
+	for _, vm := range vms {
+		if vm.State == db.MaintenanceVMMigrated {
+			toReturn = append(toReturn, vm)
+		}
+	}
+	if len(toReturn) == 0 {
+		return 0, fmt.Errorf("no evacuated VM to return to %s", machineName)
+	}
+
+	return StartJob(JobTypeMaintenance, machineName, longTaskTimeout, func(jobCtx context.Context, job *JobHandle) error {
+		failed := 0
+		for i := range toReturn {
+			if err := jobCtx.Err(); err != nil {
+				return err
+			}
+			row := &toReturn[i]
+			if err := s.returnVM(jobCtx, job, machineName, row); err != nil {
+				failed++
+				row.State = db.MaintenanceVMMigrated
+				row.Message = err.Error()
+				job.Log("%s: %s", row.VmName, err)
+				if err := db.UpdateMaintenanceVM(context.Background(), *row); err != nil {
+					logger.Errorf("update evacuated vm %s: %v", row.VmName, err)
+				}
+			}
+			job.Progress(float64(i+1) * 100 / float64(len(toReturn)))
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d VMs could not be returned to %s", failed, len(toReturn), machineName)
+		}
+		return nil
+	})
+}
+
+func (s *MaintenanceService) returnVM(ctx context.Context, job *JobHandle, machineName string, row *db.MaintenanceVM) error {
+	current, err := findVMMachine(row.VmName, "")
+	if err != nil {
+		return err
+	}
+	if current != machineName {
+		conn := protocol.GetConnectionByMachineName(current)
+		if conn == nil || conn.Connection == nil {
+			return fmt.Errorf("machine %s is not connected", current)
+		}
+		vm, err := virsh.GetVmByName(conn.Connection, &grpcVirsh.GetVmByNameRequest{Name: row.VmName})
+		if err != nil || vm == nil {
+			return fmt.Errorf("VM %s not found on %s", row.VmName, current)
+		}
+		if err := ensurePlacementRules(ctx, row.VmName, machineName); err != nil {
+			return err
+		}
+		row.TargetMachine = current
+		row.Method = migrationMethod(ctx, current, vm)
+		s.finishRow(job, row, db.MaintenanceVMReturning, nil)
+		if err := moveVM(ctx, current, machineName, vm, row.Method); err != nil {
+			return err
+		}
+	}
+	s.finishRow(job, row, db.MaintenanceVMReturned, nil)
+	return nil
+}
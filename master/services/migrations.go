@@ -0,0 +1,150 @@
+package services
+
+import (
+	"512SvMan/extra"
+	"512SvMan/protocol"
+	"512SvMan/virsh"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	protoExtra "github.com/Maruqes/512SvMan/api/proto/extra"
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+// MigrationOptions tune a live migration, AutoConverge defaults to on.
+type MigrationOptions struct {
+	MaxBandwidthMiB uint64 `json:"max_bandwidth_mib"`
+	AutoConverge    *bool  `json:"auto_converge"`
+	PostCopy        bool   `json:"post_copy"`
+}
+
+// MigrationStatus is a migration in progress with the last update the
+// origin slave sent.
+type MigrationStatus struct {
+	VmName    string                       `json:"vm_name"`
+	From      string                       `json:"from"`
+	To        string                       `json:"to"`
+	Live      bool                         `json:"live"`
+	JobId     int                          `json:"job_id"`
+	StartedAt string                       `json:"started_at"`
+	Progress  *grpcVirsh.MigrationProgress `json:"progress"`
+}
+
+var (
+	migrationsMu     sync.Mutex
+	activeMigrations = map[string]*MigrationStatus{}
+)
+
+// runMigration migrates vmName and tracks its progress until it ends, the
+// progress is broadcast on the websocket and reported to job when it is not
+// nil.
+func runMigration(ctx context.Context, from, to *protocol.ConnectionsStruct, vmName string, live bool, timeoutSeconds int, opts MigrationOptions, job *JobHandle) error {
+	status := &MigrationStatus{
+		VmName:    vmName,
+		From:      from.MachineName,
+		To:        to.MachineName,
+		Live:      live,
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+		Progress:  &grpcVirsh.MigrationProgress{Phase: "setup"},
+	}
+	if job != nil {
+		status.JobId = job.Id
+	}
+
+	migrationsMu.Lock()
+	if _, ok := activeMigrations[vmName]; ok {
+		migrationsMu.Unlock()
+		return fmt.Errorf("VM %s is already migrating", vmName)
+	}
+	activeMigrations[vmName] = status
+	migrationsMu.Unlock()
+	defer func() {
+		migrationsMu.Lock()
+		delete(activeMigrations, vmName)
+		migrationsMu.Unlock()
+	}()
+
+	autoConverge := opts.AutoConverge == nil || *opts.AutoConverge
+	req := &grpcVirsh.MigrateVmRequest{
+		Name:            vmName,
+		SlaveIp:         to.Addr,
+		Live:            live,
+		TimeoutSeconds:  int32(timeoutSeconds),
+		MaxBandwidthMib: opts.MaxBandwidthMiB,
+		AutoConverge:    autoConverge,
+		PostCopy:        opts.PostCopy,
+	}
+	phase := ""
+	return virsh.MigrateVm(ctx, from.Connection, req, func(progress *grpcVirsh.MigrationProgress) {
+		migrationsMu.Lock()
+		status.Progress = progress
+		migrationsMu.Unlock()
+
+		if progress.Phase != phase {
+			phase = progress.Phase
+			job.Log("%s migration phase: %s", vmName, phase)
+		}
+		job.Progress(float64(progress.Percent))
+
+		data, err := json.Marshal(status)
+		if err != nil {
+			logger.Error("marshal migration progress", "vm", vmName, "error", err)
+			return
+		}
+		extra.SendWebsocketMessage(protoExtra.WebSocketsMessageType_MigrateVm, string(data), vmName)
+	})
+}
+
+// copyMigrationStatus is safe to share, every update from the slave replaces
+// Progress instead of changing it.
+func copyMigrationStatus(status *MigrationStatus) MigrationStatus {
+	return *status
+}
+
+// Migrations lists the migrations in progress.
+func (v *VirshService) Migrations() []MigrationStatus {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+
+	migrations := make([]MigrationStatus, 0, len(activeMigrations))
+	for _, status := range activeMigrations {
+		migrations = append(migrations, copyMigrationStatus(status))
+	}
+	slices.SortFunc(migrations, func(a, b MigrationStatus) int { return strings.Compare(a.VmName, b.VmName) })
+	return migrations
+}
+
+func (v *VirshService) GetMigration(vmName string) (*MigrationStatus, error) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+
+	status, ok := activeMigrations[vmName]
+	if !ok {
+		return nil, fmt.Errorf("VM %s is not migrating", vmName)
+	}
+	copied := copyMigrationStatus(status)
+	return &copied, nil
+}
+
+// CancelMigration aborts the migration job on the origin slave, the VM keeps
+// running there.
+func (v *VirshService) CancelMigration(ctx context.Context, vmName string) error {
+	status, err := v.GetMigration(vmName)
+	if err != nil {
+		return err
+	}
+	if status.Progress != nil && status.Progress.Phase == "postcopy" {
+		return fmt.Errorf("VM %s already runs on %s in post-copy, it can no longer be cancelled", vmName, status.To)
+	}
+	conn := protocol.GetConnectionByMachineName(status.From)
+	if conn == nil || conn.Connection == nil {
+		return fmt.Errorf("machine %s is not connected", status.From)
+	}
+	return virsh.CancelMigration(ctx, conn.Connection, vmName)
+}
@@ -190,7 +190,9 @@ func forcedelete(ctx context.Context, s *NFSService) error {
 	if err != nil {
 		return fmt.Errorf("failed to query backups using NFS share: %v", err)
 	}
-	for _, bak := range backups {
+	// newest first, incremental backups have to go before the backups they depend on
+	for i := len(backups) - 1; i >= 0; i-- {
+		bak := backups[i]
 		if err := virshService.DeleteBackup(ctx, bak.Id); err != nil {
 			return fmt.Errorf("failed to delete backup %d for VM %s: %v", bak.Id, bak.Name, err)
 		}
@@ -774,24 +776,24 @@ func (s *NFSService) DownloadISOAsync(url, isoName string, nfsShare db.NFSShare)
 		},
 	}
 
-	go func() {
-		taskCtx := context.Background()
+	_, err := StartJob(JobTypeISODownload, isoName, 0, func(taskCtx context.Context, job *JobHandle) error {
+		job.Log("downloading %s to %s on %s", url, isoPath, nfsShare.MachineName)
 		if err := nfs.DownloadISO(conn.Connection, taskCtx, isoRequest); err != nil {
 			nots.SendGlobalNotification("ISO download failed", "ISO download failed for "+isoName+" on "+nfsShare.MachineName, err.Error(), true)
-			return
+			return err
 		}
 		if err := nfs.Sync(conn.Connection); err != nil {
 			nots.SendGlobalNotification("ISO sync failed", "ISO sync failed for "+isoName+" on "+nfsShare.MachineName, err.Error(), true)
-			return
+			return err
 		}
 		if err := db.AddISO(taskCtx, nfsShare.MachineName, isoPath, isoName); err != nil {
 			nots.SendGlobalNotification("ISO register failed", "ISO download finished but failed to save "+isoName+" on "+nfsShare.MachineName, err.Error(), true)
-			return
+			return err
 		}
 		nots.SendGlobalNotification("ISO download done", "ISO "+isoName+" downloaded on "+nfsShare.MachineName, "/", true)
-	}()
-
-	return nil
+		return nil
+	})
+	return err
 }
 
 func (s *NFSService) ListFolderContents(machineName string, path string) (*proto.FolderContents, error) {
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"512SvMan/db"
+	"512SvMan/pki"
+	"512SvMan/protocol"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+type NodeService struct{}
+
+func (s *NodeService) List(ctx context.Context, state string) ([]db.Node, error) {
+	nodes, err := db.GetNodes(ctx, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	if nodes == nil {
+		nodes = []db.Node{}
+	}
+	return nodes, nil
+}
+
+func (s *NodeService) getNode(ctx context.Context, machineName string) (*db.Node, error) {
+	node, err := db.GetNodeByName(ctx, machineName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node: %w", err)
+	}
+	if node == nil {
+		return nil, fmt.Errorf("node %s not found", machineName)
+	}
+	return node, nil
+}
+
+// Approve lets the slave in, it is set up on its next SetConnection retry.
+func (s *NodeService) Approve(ctx context.Context, machineName string) (*db.Node, error) {
+	node, err := s.getNode(ctx, machineName)
+	if err != nil {
+		return nil, err
+	}
+	if node.State == db.NodeStateApproved {
+		return node, nil
+	}
+	if err := db.SetNodeState(ctx, machineName, db.NodeStateApproved); err != nil {
+		return nil, fmt.Errorf("failed to approve node: %w", err)
+	}
+	logger.Infof("slave %s approved", machineName)
+	return s.getNode(ctx, machineName)
+}
+
+func (s *NodeService) Reject(ctx context.Context, machineName string) (*db.Node, error) {
+	node, err := s.getNode(ctx, machineName)
+	if err != nil {
+		return nil, err
+	}
+	if node.State == db.NodeStateApproved {
+		return nil, fmt.Errorf("node %s is already approved, retire it instead", machineName)
+	}
+	if err := db.SetNodeState(ctx, machineName, db.NodeStateRejected); err != nil {
+		return nil, fmt.Errorf("failed to reject node: %w", err)
+	}
+	logger.Warnf("slave %s rejected", machineName)
+	return s.getNode(ctx, machineName)
+}
+
+// Rename only changes the name shown in the UI, the machine name stays the
+// identity of the slave in its certificate and everywhere vms are tracked.
+func (s *NodeService) Rename(ctx context.Context, machineName, displayName string) (*db.Node, error) {
+	if _, err := s.getNode(ctx, machineName); err != nil {
+		return nil, err
+	}
+	if err := db.SetNodeDisplayName(ctx, machineName, strings.TrimSpace(displayName)); err != nil {
+		return nil, fmt.Errorf("failed to rename node: %w", err)
+	}
+	return s.getNode(ctx, machineName)
+}
+
+// Retire takes an approved slave out of the cluster for good, its certificates
+// are revoked so it has to enroll and be approved again to come back. A
+// connected slave is only retired with force, its vms would be left unmanaged.
+func (s *NodeService) Retire(ctx context.Context, machineName string, force bool) (*db.Node, error) {
+	node, err := s.getNode(ctx, machineName)
+	if err != nil {
+		return nil, err
+	}
+	if node.State == db.NodeStateRetired {
+		return node, nil
+	}
+	if !force && protocol.GetConnectionByMachineName(machineName) != nil {
+		return nil, fmt.Errorf("slave %s is connected, shut it down first or retire it with force", machineName)
+	}
+
+	if err := db.SetNodeState(ctx, machineName, db.NodeStateRetired); err != nil {
+		return nil, fmt.Errorf("failed to retire node: %w", err)
+	}
+	serials, err := db.RevokeMachineCertificates(ctx, machineName, "", "node retired")
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke certificates of %s: %w", machineName, err)
+	}
+	for _, serial := range serials {
+		pki.MarkRevoked(serial)
+	}
+	protocol.DropSlave(machineName)
+	logger.Warnf("slave %s retired, %d certificate(s) revoked", machineName, len(serials))
+	return s.getNode(ctx, machineName)
+}
+
+// Delete forgets a node that is not approved, if it connects again it shows
+// up as pending.
+func (s *NodeService) Delete(ctx context.Context, machineName string) error {
+	node, err := s.getNode(ctx, machineName)
+	if err != nil {
+		return err
+	}
+	if node.State == db.NodeStateApproved {
+		return fmt.Errorf("node %s is approved, retire it first", machineName)
+	}
+	if err := db.DeleteNode(ctx, machineName); err != nil {
+		return fmt.Errorf("failed to delete node: %w", err)
+	}
+	return nil
+}
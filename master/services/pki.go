@@ -0,0 +1,156 @@
+package services
+
+import (
+	"512SvMan/db"
+	"512SvMan/pki"
+	"512SvMan/protocol"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/Maruqes/512SvMan/api/proto/protocol"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+const defaultJoinTokenTTL = 24 * time.Hour
+
+type PKIService struct{}
+
+type CAInfo struct {
+	Certificate    string `json:"certificate"`
+	Fingerprint    string `json:"fingerprint"`
+	MasterSerial   string `json:"master_serial"`
+	MasterNotAfter string `json:"master_not_after"`
+}
+
+// CreatedJoinToken carries the secret, it is only returned once.
+type CreatedJoinToken struct {
+	db.JoinToken
+	Token      string `json:"token"`
+	CACertHash string `json:"ca_cert_hash"`
+}
+
+func (s *PKIService) CAInfo() CAInfo {
+	master := pki.MasterCertificate()
+	return CAInfo{
+		Certificate:    string(pki.CAPEM()),
+		Fingerprint:    pki.CAFingerprint(),
+		MasterSerial:   pki.Serial(master),
+		MasterNotAfter: master.NotAfter.UTC().Format(time.RFC3339),
+	}
+}
+
+func (s *PKIService) RotateMasterCertificate() (CAInfo, error) {
+	if _, err := pki.RotateMasterCertificate(); err != nil {
+		return CAInfo{}, fmt.Errorf("failed to rotate master certificate: %w", err)
+	}
+	return s.CAInfo(), nil
+}
+
+// CreateJoinToken makes a one time token a new slave exchanges for its
+// certificate, machineName empty lets any name use it. ttl <= 0 uses the
+// default. An unbound token only re-enrolls a machine that still has active
+// certificates with replaceExisting, a bound one always replaces them.
+func (s *PKIService) CreateJoinToken(ctx context.Context, machineName string, replaceExisting bool, ttl time.Duration) (*CreatedJoinToken, error) {
+	if ttl <= 0 {
+		ttl = defaultJoinTokenTTL
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate join token: %w", err)
+	}
+	token := hex.EncodeToString(secret)
+
+	stored, err := db.AddJoinToken(ctx, protocol.HashJoinToken(token), strings.TrimSpace(machineName), replaceExisting, time.Now().Add(ttl))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store join token: %w", err)
+	}
+	return &CreatedJoinToken{JoinToken: *stored, Token: token, CACertHash: pki.CAFingerprint()}, nil
+}
+
+func (s *PKIService) ListJoinTokens(ctx context.Context) ([]db.JoinToken, error) {
+	tokens, err := db.GetJoinTokens(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list join tokens: %w", err)
+	}
+	if tokens == nil {
+		tokens = []db.JoinToken{}
+	}
+	return tokens, nil
+}
+
+func (s *PKIService) DeleteJoinToken(ctx context.Context, id int) error {
+	if err := db.DeleteJoinToken(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete join token: %w", err)
+	}
+	return nil
+}
+
+func (s *PKIService) ListCertificates(ctx context.Context) ([]db.NodeCertificate, error) {
+	certs, err := db.GetNodeCertificates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificates: %w", err)
+	}
+	if certs == nil {
+		certs = []db.NodeCertificate{}
+	}
+	return certs, nil
+}
+
+// RevokeCertificate blocks the certificate on new handshakes and on every call
+// of open connections, then redials its slave so the master side drops it too.
+func (s *PKIService) RevokeCertificate(ctx context.Context, serial, reason string) error {
+	cert, err := db.GetNodeCertificateBySerial(ctx, serial)
+	if err != nil {
+		return fmt.Errorf("failed to get certificate: %w", err)
+	}
+	if cert == nil {
+		return fmt.Errorf("certificate %s not found", serial)
+	}
+	if cert.RevokedAt != "" {
+		return fmt.Errorf("certificate %s is already revoked", serial)
+	}
+	if strings.TrimSpace(reason) == "" {
+		reason = "revoked by admin"
+	}
+	if err := db.RevokeNodeCertificate(ctx, serial, reason); err != nil {
+		return fmt.Errorf("failed to revoke certificate: %w", err)
+	}
+	pki.MarkRevoked(serial)
+	logger.Warnf("certificate %s of %s revoked: %s", serial, cert.MachineName, reason)
+
+	go func(machineName string) {
+		if err := protocol.ReconnectSlave(machineName); err != nil {
+			logger.Warnf("slave %s stays disconnected after revocation: %v", machineName, err)
+		}
+	}(cert.MachineName)
+	return nil
+}
+
+// RotateNodeCertificate asks the slave for a new key pair and certificate. The
+// old certificate still backs the open slave -> master connection, so it is left
+// valid until it expires or is revoked.
+func (s *PKIService) RotateNodeCertificate(ctx context.Context, machineName string) (*db.NodeCertificate, error) {
+	conn := protocol.GetConnectionByMachineName(machineName)
+	if conn == nil || conn.Connection == nil {
+		return nil, fmt.Errorf("slave %s no connected", machineName)
+	}
+
+	client := pb.NewClientServiceClient(conn.Connection)
+	resp, err := client.RotateCertificate(ctx, &pb.RotateCertificateRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate certificate of %s: %w", machineName, err)
+	}
+
+	cert, err := db.GetNodeCertificateBySerial(ctx, resp.GetSerial())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificate: %w", err)
+	}
+	if cert == nil {
+		return nil, fmt.Errorf("slave %s reported unknown certificate %s", machineName, resp.GetSerial())
+	}
+	return cert, nil
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"512SvMan/db"
+	"512SvMan/protocol"
+	"512SvMan/virsh"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+type PlacementGroupService struct{}
+
+// PlacementViolation is a group whose VMs are not placed as its rule wants,
+// Hosts maps each host involved to the group VMs on it.
+type PlacementViolation struct {
+	Group       string              `json:"group"`
+	Policy      string              `json:"policy"`
+	Enforcement string              `json:"enforcement"`
+	Hosts       map[string][]string `json:"hosts"`
+	Message     string              `json:"message"`
+}
+
+func validatePlacementGroup(group db.PlacementGroup) error {
+	if strings.TrimSpace(group.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if group.Policy != db.PlacementAffinity && group.Policy != db.PlacementAntiAffinity {
+		return fmt.Errorf("policy must be %s or %s", db.PlacementAffinity, db.PlacementAntiAffinity)
+	}
+	if group.Enforcement != db.PlacementHard && group.Enforcement != db.PlacementSoft {
+		return fmt.Errorf("enforcement must be %s or %s", db.PlacementHard, db.PlacementSoft)
+	}
+	return nil
+}
+
+func (s *PlacementGroupService) List(ctx context.Context) ([]db.PlacementGroup, error) {
+	return db.GetPlacementGroups(ctx)
+}
+
+func (s *PlacementGroupService) Get(ctx context.Context, name string) (*db.PlacementGroup, error) {
+	group, err := db.GetPlacementGroup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, fmt.Errorf("placement group %s not found", name)
+	}
+	return group, nil
+}
+
+// Create adds a group with its VMs, the VMs do not need to exist yet so a
+// group can be set up before the VMs are created.
+func (s *PlacementGroupService) Create(ctx context.Context, group db.PlacementGroup) (*db.PlacementGroup, error) {
+	group.Name = strings.TrimSpace(group.Name)
+	if err := validatePlacementGroup(group); err != nil {
+		return nil, err
+	}
+	if err := db.CreatePlacementGroup(ctx, group); err != nil {
+		return nil, fmt.Errorf("failed to create placement group %s: %w", group.Name, err)
+	}
+	for _, vmName := range group.VMs {
+		if err := db.AddPlacementGroupVM(ctx, group.Name, vmName); err != nil {
+			return nil, err
+		}
+	}
+	return s.Get(ctx, group.Name)
+}
+
+// Update changes the rule of a group, its VMs are kept.
+func (s *PlacementGroupService) Update(ctx context.Context, group db.PlacementGroup) (*db.PlacementGroup, error) {
+	if _, err := s.Get(ctx, group.Name); err != nil {
+		return nil, err
+	}
+	if err := validatePlacementGroup(group); err != nil {
+		return nil, err
+	}
+	if err := db.UpdatePlacementGroup(ctx, group); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, group.Name)
+}
+
+func (s *PlacementGroupService) Delete(ctx context.Context, name string) error {
+	return db.DeletePlacementGroup(ctx, name)
+}
+
+func (s *PlacementGroupService) AddVM(ctx context.Context, name, vmName string) (*db.PlacementGroup, error) {
+	if _, err := s.Get(ctx, name); err != nil {
+		return nil, err
+	}
+	if err := db.AddPlacementGroupVM(ctx, name, vmName); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, name)
+}
+
+func (s *PlacementGroupService) RemoveVM(ctx context.Context, name, vmName string) (*db.PlacementGroup, error) {
+	if _, err := s.Get(ctx, name); err != nil {
+		return nil, err
+	}
+	if err := db.RemovePlacementGroupVM(ctx, name, vmName); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, name)
+}
+
+// Compliance lists the groups whose VMs are currently placed against their
+// rule, VMs on disconnected slaves are not known and left out.
+func (s *PlacementGroupService) Compliance(ctx context.Context) ([]PlacementViolation, error) {
+	rules, err := loadPlacementRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	violations := []PlacementViolation{}
+	for _, group := range rules.groups {
+		hosts := map[string][]string{}
+		for _, vmName := range group.VMs {
+			if machine, ok := rules.locations[vmName]; ok {
+				hosts[machine] = append(hosts[machine], vmName)
+			}
+		}
+
+		switch group.Policy {
+		case db.PlacementAntiAffinity:
+			for machine, vms := range hosts {
+				if len(vms) < 2 {
+					delete(hosts, machine)
+				}
+			}
+			if len(hosts) == 0 {
+				continue
+			}
+			var parts []string
+			for _, machine := range slices.Sorted(maps.Keys(hosts)) {
+				parts = append(parts, fmt.Sprintf("%s share %s", strings.Join(hosts[machine], ", "), machine))
+			}
+			violations = append(violations, PlacementViolation{
+				Group: group.Name, Policy: group.Policy, Enforcement: group.Enforcement, Hosts: hosts,
+				Message: strings.Join(parts, "; "),
+			})
+		case db.PlacementAffinity:
+			if len(hosts) < 2 {
+				continue
+			}
+			violations = append(violations, PlacementViolation{
+				Group: group.Name, Policy: group.Policy, Enforcement: group.Enforcement, Hosts: hosts,
+				Message: fmt.Sprintf("VMs are spread over %s", strings.Join(slices.Sorted(maps.Keys(hosts)), ", ")),
+			})
+		}
+	}
+	return violations, nil
+}
+
+// placementRules are the placement groups with where their VMs are defined,
+// placements made while the rules are in use are recorded with move so VMs
+// placed together see each other.
+type placementRules struct {
+	groups    []db.PlacementGroup
+	locations map[string]string
+}
+
+func loadPlacementRules(ctx context.Context) (*placementRules, error) {
+	groups, err := db.GetPlacementGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load placement groups: %w", err)
+	}
+	rules := &placementRules{groups: groups, locations: map[string]string{}}
+	if len(groups) == 0 {
+		return rules, nil
+	}
+
+	for _, conn := range protocol.GetConnectionsSnapshot() {
+		if conn.Connection == nil {
+			continue
+		}
+		resp, err := virsh.GetAllVms(conn.Connection, &grpcVirsh.Empty{})
+		if err != nil {
+			logger.Warn("could not list VMs for placement rules", "machine", conn.MachineName, "error", err)
+			continue
+		}
+		for _, vm := range resp.Vms {
+			rules.locations[vm.Name] = conn.MachineName
+		}
+	}
+	return rules, nil
+}
+
+// check returns the rules placing vmName on machine would break, split by
+// enforcement.
+func (p *placementRules) check(vmName, machine string) (hard, soft []string) {
+	for _, group := range p.groups {
+		if !slices.Contains(group.VMs, vmName) {
+			continue
+		}
+		for _, other := range group.VMs {
+			otherMachine, ok := p.locations[other]
+			if other == vmName || !ok {
+				continue
+			}
+			var violation string
+			switch {
+			case group.Policy == db.PlacementAntiAffinity && otherMachine == machine:
+				violation = fmt.Sprintf("anti-affinity group %s: %s is on %s", group.Name, other, machine)
+			case group.Policy == db.PlacementAffinity && otherMachine != machine:
+				violation = fmt.Sprintf("affinity group %s: %s is on %s", group.Name, other, otherMachine)
+			default:
+				continue
+			}
+			if group.Enforcement == db.PlacementHard {
+				hard = append(hard, violation)
+			} else {
+				soft = append(soft, violation)
+			}
+		}
+	}
+	return hard, soft
+}
+
+func (p *placementRules) move(vmName, machine string) {
+	p.locations[vmName] = machine
+}
+
+// hostsFor narrows the free memory of candidate hosts to the ones vmName may
+// go to, the hosts breaking no rule when one of them has room, else the ones
+// only breaking soft rules.
+func (p *placementRules) hostsFor(vmName string, freeMB map[string]int, needMB int) map[string]int {
+	compliant := map[string]int{}
+	allowed := map[string]int{}
+	fits := false
+	for host, free := range freeMB {
+		hard, soft := p.check(vmName, host)
+		if len(hard) > 0 {
+			continue
+		}
+		allowed[host] = free
+		if len(soft) == 0 {
+			compliant[host] = free
+			fits = fits || free >= needMB
+		}
+	}
+	if fits {
+		return compliant
+	}
+	return allowed
+}
+
+// ensurePlacementRules refuses placing vmName on machine when that breaks a
+// hard rule, broken soft rules are only logged.
+func ensurePlacementRules(ctx context.Context, vmName, machine string) error {
+	rules, err := loadPlacementRules(ctx)
+	if err != nil {
+		return err
+	}
+	hard, soft := rules.check(vmName, machine)
+	if len(hard) > 0 {
+		return fmt.Errorf("%s cannot be placed on %s: %s", vmName, machine, strings.Join(hard, "; "))
+	}
+	for _, violation := range soft {
+		logger.Warn("placement breaks a soft rule", "vm", vmName, "machine", machine, "rule", violation)
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+package services
+
+import (
+	"512SvMan/cron"
+	"512SvMan/db"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+const (
+	powerScheduleTick = 30 * time.Second
+	// a run missed by more than this while the master was down is skipped,
+	// starting lab vms at midnight because the master came back then is worse
+	powerScheduleMissWindow = 10 * time.Minute
+	powerScheduleRetention  = 90 * 24 * time.Hour
+	powerShutdownPoll       = 5 * time.Second
+)
+
+var powerActions = map[string]bool{
+	db.PowerActionStart:    true,
+	db.PowerActionShutdown: true,
+	db.PowerActionRestart:  true,
+	db.PowerActionPause:    true,
+	db.PowerActionResume:   true,
+	db.PowerActionSnapshot: true,
+}
+
+type PowerScheduleService struct{}
+
+// PowerScheduleView is a schedule with the next time it fires.
+type PowerScheduleView struct {
+	db.PowerSchedule
+	NextRun string `json:"next_run"`
+}
+
+func powerScheduleView(s db.PowerSchedule) PowerScheduleView {
+	view := PowerScheduleView{PowerSchedule: s}
+	if sched, err := cron.Parse(s.Cron); err == nil && s.Enabled {
+		if next := sched.Next(time.Now()); !next.IsZero() {
+			view.NextRun = next.UTC().Format(time.RFC3339)
+		}
+	}
+	return view
+}
+
+func (s *PowerScheduleService) List(ctx context.Context) ([]PowerScheduleView, error) {
+	schedules, err := db.GetPowerSchedules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]PowerScheduleView, 0, len(schedules))
+	for _, schedule := range schedules {
+		views = append(views, powerScheduleView(schedule))
+	}
+	return views, nil
+}
+
+func (s *PowerScheduleService) Get(ctx context.Context, id int) (*PowerScheduleView, error) {
+	schedule, err := db.GetPowerSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return nil, fmt.Errorf("power schedule %d not found", id)
+	}
+	view := powerScheduleView(*schedule)
+	return &view, nil
+}
+
+func validatePowerSchedule(schedule *db.PowerSchedule) error {
+	schedule.Name = strings.TrimSpace(schedule.Name)
+	schedule.Cron = strings.TrimSpace(schedule.Cron)
+	schedule.Target = strings.TrimSpace(schedule.Target)
+	if schedule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, err := cron.Parse(schedule.Cron); err != nil {
+		return err
+	}
+	if !powerActions[schedule.Action] {
+		return fmt.Errorf("unknown action %q", schedule.Action)
+	}
+	if schedule.TargetType != db.PowerTargetVM && schedule.TargetType != db.PowerTargetTag {
+		return fmt.Errorf("target_type must be %q or %q", db.PowerTargetVM, db.PowerTargetTag)
+	}
+	if schedule.Target == "" {
+		return fmt.Errorf("target is required")
+	}
+	if schedule.ForceAfterSeconds < 0 {
+		return fmt.Errorf("force_after_seconds can't be negative")
+	}
+	if schedule.KeepSnapshots < 0 {
+		return fmt.Errorf("keep_snapshots can't be negative")
+	}
+	return nil
+}
+
+func (s *PowerScheduleService) Create(ctx context.Context, schedule db.PowerSchedule) (*PowerScheduleView, error) {
+	if err := validatePowerSchedule(&schedule); err != nil {
+		return nil, err
+	}
+	if err := db.AddPowerSchedule(ctx, &schedule); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, schedule.Id)
+}
+
+func (s *PowerScheduleService) Update(ctx context.Context, schedule db.PowerSchedule) (*PowerScheduleView, error) {
+	if _, err := s.Get(ctx, schedule.Id); err != nil {
+		return nil, err
+	}
+	if err := validatePowerSchedule(&schedule); err != nil {
+		return nil, err
+	}
+	if err := db.UpdatePowerSchedule(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, schedule.Id)
+}
+
+func (s *PowerScheduleService) Delete(ctx context.Context, id int) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return db.DeletePowerSchedule(ctx, id)
+}
+
+func (s *PowerScheduleService) Runs(ctx context.Context, id int, limit int) ([]db.PowerScheduleRun, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	return db.GetPowerScheduleRuns(ctx, id, limit)
+}
+
+// RunNow fires a schedule out of its cron, the run is recorded like any other.
+func (s *PowerScheduleService) RunNow(ctx context.Context, id int) error {
+	schedule, err := db.GetPowerSchedule(ctx, id)
+	if err != nil {
+		return err
+	}
+	if schedule == nil {
+		return fmt.Errorf("power schedule %d not found", id)
+	}
+	go s.execute(context.Background(), *schedule)
+	return nil
+}
+
+// Run fires the schedules whose cron matched since their last run until ctx
+// is done.
+func (s *PowerScheduleService) Run(ctx context.Context) {
+	ticker := time.NewTicker(powerScheduleTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runDue(ctx, time.Now())
+		}
+	}
+}
+
+func (s *PowerScheduleService) runDue(ctx context.Context, now time.Time) {
+	schedules, err := db.GetPowerSchedules(ctx)
+	if err != nil {
+		logger.Errorf("power schedules: %v", err)
+		return
+	}
+	if err := db.PrunePowerScheduleRuns(ctx, now.Add(-powerScheduleRetention)); err != nil {
+		logger.Errorf("power schedules: prune history: %v", err)
+	}
+
+	for _, schedule := range schedules {
+		if !schedule.Enabled {
+			continue
+		}
+		sched, err := cron.Parse(schedule.Cron)
+		if err != nil {
+			logger.Errorf("power schedule %d: %v", schedule.Id, err)
+			continue
+		}
+		since := schedule.LastRun
+		if since == "" {
+			since = schedule.CreatedAt
+		}
+		last, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			last = now
+		}
+		next := sched.Next(last.In(now.Location()))
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+
+		// stored first so a slow run is never started twice
+		if err := db.SetPowerScheduleLastRun(ctx, schedule.Id, now); err != nil {
+			logger.Errorf("power schedule %d: %v", schedule.Id, err)
+			continue
+		}
+		if now.Sub(next) > powerScheduleMissWindow {
+			logger.Warnf("power schedule %s: missed the run of %s, skipping it", schedule.Name, next.Format(time.RFC3339))
+			_ = db.AddPowerScheduleRun(ctx, db.PowerScheduleRun{
+				ScheduleId: schedule.Id,
+				VmName:     schedule.Target,
+				Action:     schedule.Action,
+				Status:     db.PowerRunSkipped,
+				Message:    "missed the run of " + next.UTC().Format(time.RFC3339) + " while the master was down",
+				StartedAt:  now.UTC().Format(time.RFC3339),
+			})
+			continue
+		}
+		go s.execute(ctx, schedule)
+	}
+}
+
+func powerScheduleTargets(ctx context.Context, schedule db.PowerSchedule) ([]string, error) {
+	if schedule.TargetType == db.PowerTargetTag {
+		return db.GetVMsByTag(ctx, schedule.Target)
+	}
+	return []string{schedule.Target}, nil
+}
+
+// execute runs the action on every target at once and records one run per vm.
+func (s *PowerScheduleService) execute(ctx context.Context, schedule db.PowerSchedule) {
+	vms, err := powerScheduleTargets(ctx, schedule)
+	if err != nil {
+		sendImportantNotification("Power schedule "+schedule.Name+" failed", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, vmName := range vms {
+		wg.Add(1)
+		go func(vmName string) {
+			defer wg.Done()
+			run := db.PowerScheduleRun{
+				ScheduleId: schedule.Id,
+				VmName:     vmName,
+				Action:     schedule.Action,
+				Status:     db.PowerRunOk,
+				StartedAt:  time.Now().UTC().Format(time.RFC3339),
+			}
+			skipped, err := runPowerAction(ctx, schedule, vmName)
+			switch {
+			case err != nil:
+				run.Status = db.PowerRunFailed
+				run.Message = err.Error()
+				sendImportantNotification("Power schedule "+schedule.Name+" failed", fmt.Errorf("%s %s: %w", schedule.Action, vmName, err))
+			case skipped != "":
+				run.Status = db.PowerRunSkipped
+				run.Message = skipped
+			}
+			if err := db.AddPowerScheduleRun(context.Background(), run); err != nil {
+				logger.Errorf("power schedule %s: record run: %v", schedule.Name, err)
+			}
+		}(vmName)
+	}
+	wg.Wait()
+}
+
+// runPowerAction returns why nothing was done when the vm already is in the
+// state the action leads to.
+func runPowerAction(ctx context.Context, schedule db.PowerSchedule, vmName string) (string, error) {
+	v := VirshService{}
+	vm, err := v.GetVmByName(vmName)
+	if err != nil {
+		return "", err
+	}
+	if vm == nil {
+		return "", fmt.Errorf("vm %s does not exist", vmName)
+	}
+	running := vm.State == grpcVirsh.VmState_RUNNING
+	off := vm.State == grpcVirsh.VmState_SHUTOFF
+
+	switch schedule.Action {
+	case db.PowerActionStart:
+		if !off {
+			return "vm is not shut off", nil
+		}
+		return "", v.StartVM(ctx, vmName)
+	case db.PowerActionShutdown:
+		if off {
+			return "vm is already shut off", nil
+		}
+		return "", shutdownWithForce(ctx, &v, vmName, time.Duration(schedule.ForceAfterSeconds)*time.Second)
+	case db.PowerActionRestart:
+		if !running {
+			return "vm is not running", nil
+		}
+		return "", v.RestartVM(vmName)
+	case db.PowerActionPause:
+		if !running {
+			return "vm is not running", nil
+		}
+		return "", v.PauseVM(vmName)
+	case db.PowerActionResume:
+		if vm.State != grpcVirsh.VmState_PAUSED {
+			return "vm is not paused", nil
+		}
+		return "", v.ResumeVM(vmName)
+	case db.PowerActionSnapshot:
+		_, err := v.CreateSnapshot(vmName, CreateSnapshotParams{
+			Name:        fmt.Sprintf("%s%s", scheduledSnapshotPrefix(schedule.Id), time.Now().UTC().Format("20060102-1504")),
+			Description: "taken by power schedule " + schedule.Name,
+		})
+		if err != nil {
+			return "", err
+		}
+		return "", pruneScheduledSnapshots(&v, schedule, vmName)
+	}
+	return "", fmt.Errorf("unknown action %q", schedule.Action)
+}
+
+func scheduledSnapshotPrefix(scheduleID int) string {
+	return fmt.Sprintf("sched-%d-", scheduleID)
+}
+
+// pruneScheduledSnapshots deletes the oldest snapshots the schedule took of
+// vmName past KeepSnapshots, snapshots taken by hand are never touched.
+func pruneScheduledSnapshots(v *VirshService, schedule db.PowerSchedule, vmName string) error {
+	if schedule.KeepSnapshots <= 0 {
+		return nil
+	}
+	snapshots, err := v.ListSnapshots(vmName)
+	if err != nil {
+		return fmt.Errorf("list snapshots to prune: %w", err)
+	}
+	var names []string
+	for _, snapshot := range snapshots {
+		if strings.HasPrefix(snapshot.Name, scheduledSnapshotPrefix(schedule.Id)) {
+			names = append(names, snapshot.Name)
+		}
+	}
+	// the names end in the time they were taken, so they sort oldest first
+	slices.Sort(names)
+	for _, name := range scheduledSnapshotsToPrune(names, schedule.KeepSnapshots) {
+		if err := v.DeleteSnapshot(vmName, name); err != nil {
+			return fmt.Errorf("prune: %w", err)
+		}
+	}
+	return nil
+}
+
+// scheduledSnapshotsToPrune returns what goes from names sorted oldest first
+// so that keep are left.
+func scheduledSnapshotsToPrune(names []string, keep int) []string {
+	if keep <= 0 || len(names) <= keep {
+		return nil
+	}
+	return names[:len(names)-keep]
+}
+
+// shutdownWithForce asks the guest to shut down and forces it off when it is
+// still up after forceAfter, 0 only asks.
+func shutdownWithForce(ctx context.Context, v *VirshService, vmName string, forceAfter time.Duration) error {
+	if err := v.ShutdownVM(vmName); err != nil {
+		return err
+	}
+	if forceAfter <= 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(forceAfter)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(powerShutdownPoll):
+		}
+		vm, err := v.GetVmByName(vmName)
+		if err == nil && vm != nil && vm.State == grpcVirsh.VmState_SHUTOFF {
+			return nil
+		}
+	}
+	logger.Warnf("%s did not shut down in %s, forcing it off", vmName, forceAfter)
+	return v.ForceShutdownVM(vmName)
+}
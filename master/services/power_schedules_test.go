@@ -0,0 +1,25 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestScheduledSnapshotsToPrune(t *testing.T) {
+	names := []string{"sched-4-20261014-0200", "sched-4-20261015-0200", "sched-4-20261016-0200"}
+	tests := []struct {
+		keep int
+		want []string
+	}{
+		{0, nil},
+		{3, nil},
+		{5, nil},
+		{2, []string{"sched-4-20261014-0200"}},
+		{1, []string{"sched-4-20261014-0200", "sched-4-20261015-0200"}},
+	}
+	for _, tt := range tests {
+		if got := scheduledSnapshotsToPrune(names, tt.keep); !slices.Equal(got, tt.want) {
+			t.Errorf("keep %d: pruned %v, want %v", tt.keep, got, tt.want)
+		}
+	}
+}
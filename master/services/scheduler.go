@@ -0,0 +1,388 @@
+package services
+
+import (
+	"512SvMan/db"
+	"512SvMan/env512"
+	"512SvMan/protocol"
+	"512SvMan/virsh"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+// MachineAuto in place of a machine name lets the scheduler pick the slave.
+const MachineAuto = "auto"
+
+// score weights, they add up to 100
+const (
+	schedMemoryWeight = 50
+	schedCPUWeight    = 30
+	schedVCPUWeight   = 20
+
+	// taken off the score for each soft placement rule a host breaks
+	schedSoftRulePenalty = 25
+)
+
+type SchedulerService struct{}
+
+// PlacementRequest describes the VM to place. Name is the VM being placed and
+// brings in its placement groups, when VmName is set the memory, vCPUs and
+// hugepages of that VM are used, NfsShareId restricts the slaves to the ones
+// that reach the share.
+type PlacementRequest struct {
+	Name       string   `json:"name"`
+	VmName     string   `json:"vm_name"`
+	MemoryMB   int      `json:"memory"`
+	VCPUs      int      `json:"vcpu"`
+	HugePages  bool     `json:"hugepages"`
+	NfsShareId int      `json:"nfs_share_id"`
+	Exclude    []string `json:"exclude"`
+}
+
+// HostScore is how well a slave fits a placement, Reasons explain the score
+// or why the slave cannot take the VM.
+type HostScore struct {
+	MachineName       string   `json:"machine_name"`
+	Eligible          bool     `json:"eligible"`
+	Score             float64  `json:"score"`
+	FreeMemoryMB      int      `json:"free_memory_mb"`
+	TotalMemoryMB     int      `json:"total_memory_mb"`
+	CommittedMemoryMB int      `json:"committed_memory_mb"`
+	CPUUsage          float64  `json:"cpu_usage"`
+	UsableCPUs        int      `json:"usable_cpus"`
+	IsolatedCPUs      int      `json:"isolated_cpus"`
+	CommittedVCPUs    int      `json:"committed_vcpus"`
+	HugePagesMB       int      `json:"hugepages_mb"`
+	Reasons           []string `json:"reasons"`
+}
+
+// Placement is the chosen slave and every slave that was considered, best
+// first.
+type Placement struct {
+	MachineName string      `json:"machine_name"`
+	Reason      string      `json:"reason"`
+	Hosts       []HostScore `json:"hosts"`
+}
+
+// hostLoad is what the scheduler knows about a slave, a share that was not
+// asked for counts as reachable.
+type hostLoad struct {
+	MachineName       string
+	FreeMemoryMB      int
+	TotalMemoryMB     int
+	CommittedMemoryMB int
+	CPUUsage          float64
+	CPUs              int
+	IsolatedCPUs      int
+	CommittedVCPUs    int
+	HugePagesMB       int
+	ShareReachable    bool
+}
+
+// Place scores every connected slave that is not in maintenance and returns
+// the best one, it fails when no slave can take the VM.
+func (s *SchedulerService) Place(ctx context.Context, req PlacementRequest) (*Placement, error) {
+	if err := s.fillFromVM(req.VmName, &req); err != nil {
+		return nil, err
+	}
+	if req.MemoryMB <= 0 || req.VCPUs <= 0 {
+		return nil, fmt.Errorf("memory and vcpu are required to place a VM")
+	}
+
+	reachable := map[string]bool{}
+	if req.NfsShareId > 0 {
+		share, err := db.GetNFSShareByID(ctx, req.NfsShareId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get NFS share by ID: %v", err)
+		}
+		if share == nil {
+			return nil, fmt.Errorf("NFS share with ID %d not found", req.NfsShareId)
+		}
+		nfsService := NFSService{}
+		reachable, err = nfsService.CanFindFileOrDirOnAllSlaves(share.Target)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rules := &placementRules{locations: map[string]string{}}
+	if req.Name != "" {
+		var err error
+		rules, err = loadPlacementRules(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	placement := &Placement{}
+	for _, conn := range protocol.GetConnectionsSnapshot() {
+		if conn.Connection == nil || slices.Contains(req.Exclude, conn.MachineName) {
+			continue
+		}
+		if err := ensurePlacementAllowed(ctx, conn.MachineName); err != nil {
+			continue
+		}
+		load, err := s.hostLoad(conn, req.HugePages)
+		if err != nil {
+			placement.Hosts = append(placement.Hosts, HostScore{MachineName: conn.MachineName, Reasons: []string{err.Error()}})
+			continue
+		}
+		load.ShareReachable = req.NfsShareId <= 0 || reachable[conn.MachineName]
+		score := scoreHost(req, load, env512.CPUOvercommit, env512.MemoryOvercommit)
+		applyPlacementRules(&score, rules, req.Name)
+		placement.Hosts = append(placement.Hosts, score)
+	}
+
+	sortHostScores(placement.Hosts)
+	if len(placement.Hosts) == 0 || !placement.Hosts[0].Eligible {
+		return placement, fmt.Errorf("no slave can take a VM with %d MB and %d vCPUs%s", req.MemoryMB, req.VCPUs, describeRejections(placement.Hosts))
+	}
+	best := placement.Hosts[0]
+	placement.MachineName = best.MachineName
+	placement.Reason = fmt.Sprintf("%s scored %.1f: %s", best.MachineName, best.Score, strings.Join(best.Reasons, ", "))
+	return placement, nil
+}
+
+// Resolve returns machineName unless it is MachineAuto, then the scheduler
+// picks the slave and the placement explains the choice.
+func (s *SchedulerService) Resolve(ctx context.Context, machineName string, req PlacementRequest) (string, *Placement, error) {
+	if machineName != MachineAuto {
+		return machineName, nil, nil
+	}
+	placement, err := s.Place(ctx, req)
+	if err != nil {
+		return "", placement, err
+	}
+	logger.Info("scheduler placed a VM", "machine", placement.MachineName, "reason", placement.Reason)
+	return placement.MachineName, placement, nil
+}
+
+func (s *SchedulerService) fillFromVM(vmName string, req *PlacementRequest) error {
+	if vmName == "" {
+		return nil
+	}
+	virshService := VirshService{}
+	vm, err := virshService.GetVmByName(vmName)
+	if err != nil {
+		return err
+	}
+	if vm == nil {
+		return fmt.Errorf("vm %s does not exist", vmName)
+	}
+	if req.MemoryMB <= 0 {
+		req.MemoryMB = int(vm.DefinedRam)
+	}
+	if req.VCPUs <= 0 {
+		req.VCPUs = int(vm.DefinedCPUS)
+	}
+	if !req.HugePages {
+		hugePages, err := virshService.GetHugePages(vmName)
+		if err != nil {
+			logger.Warn("could not read hugepages of a VM", "vm", vmName, "error", err)
+		} else {
+			req.HugePages = hugePages.Enabled
+		}
+	}
+	return nil
+}
+
+func (s *SchedulerService) hostLoad(conn protocol.ConnectionsStruct, hugePages bool) (hostLoad, error) {
+	load := hostLoad{MachineName: conn.MachineName}
+	infoService := InfoService{}
+	virshService := VirshService{}
+
+	mem, err := infoService.GetMemSummary(conn.MachineName)
+	if err != nil {
+		return load, fmt.Errorf("could not read memory: %v", err)
+	}
+	load.FreeMemoryMB = int(mem.FreeMb)
+	load.TotalMemoryMB = int(mem.TotalMb)
+
+	cpu, err := infoService.GetCPUInfo(conn.MachineName)
+	if err != nil {
+		return load, fmt.Errorf("could not read cpu: %v", err)
+	}
+	load.CPUs = len(cpu.Cores)
+	for _, core := range cpu.Cores {
+		load.CPUUsage += core.Usage
+	}
+	if load.CPUs > 0 {
+		load.CPUUsage /= float64(load.CPUs)
+	}
+
+	// isolated cores are kept for pinned VMs, the rest of the VMs share
+	// whatever is left
+	isolation, err := virshService.GetHostCoreIsolation(conn.MachineName)
+	if err != nil {
+		logger.Warn("could not read core isolation of a slave", "machine", conn.MachineName, "error", err)
+	} else {
+		load.IsolatedCPUs = len(isolation.ActiveCpus)
+	}
+
+	if hugePages {
+		pages, err := virshService.GetHostHugePages(conn.MachineName)
+		if err != nil {
+			return load, fmt.Errorf("could not read hugepages: %v", err)
+		}
+		load.HugePagesMB = hugePageSizeMB(pages.ActivePageSize) * int(pages.ActivePageCount)
+	}
+
+	vms, err := virsh.GetAllVms(conn.Connection, &grpcVirsh.Empty{})
+	if err != nil {
+		return load, fmt.Errorf("could not list VMs: %v", err)
+	}
+	for _, vm := range vms.Vms {
+		if vm.State == grpcVirsh.VmState_SHUTOFF {
+			continue
+		}
+		load.CommittedMemoryMB += int(vm.DefinedRam)
+		load.CommittedVCPUs += int(vm.DefinedCPUS)
+	}
+	return load, nil
+}
+
+// hugePageSizeMB reads the page sizes slaves report, like 2M or 1G.
+func hugePageSizeMB(size string) int {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	if size == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(size[:len(size)-1])
+	if err != nil || n <= 0 {
+		return 0
+	}
+	switch size[len(size)-1] {
+	case 'K':
+		return n / 1024
+	case 'M':
+		return n
+	case 'G':
+		return n * 1024
+	}
+	return 0
+}
+
+// scoreHost checks a slave can take the VM within the overcommit ratios and
+// scores it by the memory left after placing it, how idle its cpu is and how
+// many vCPUs it still has room for.
+func scoreHost(req PlacementRequest, load hostLoad, cpuRatio, memRatio float64) HostScore {
+	score := HostScore{
+		MachineName:       load.MachineName,
+		FreeMemoryMB:      load.FreeMemoryMB,
+		TotalMemoryMB:     load.TotalMemoryMB,
+		CommittedMemoryMB: load.CommittedMemoryMB,
+		CPUUsage:          load.CPUUsage,
+		UsableCPUs:        load.CPUs - load.IsolatedCPUs,
+		IsolatedCPUs:      load.IsolatedCPUs,
+		CommittedVCPUs:    load.CommittedVCPUs,
+		HugePagesMB:       load.HugePagesMB,
+	}
+
+	if score.UsableCPUs < 1 {
+		score.UsableCPUs = 1
+	}
+
+	var rejections []string
+	if !load.ShareReachable {
+		rejections = append(rejections, "cannot reach the NFS share")
+	}
+	memoryLimit := int(float64(load.TotalMemoryMB) * memRatio)
+	if load.CommittedMemoryMB+req.MemoryMB > memoryLimit {
+		rejections = append(rejections, fmt.Sprintf("memory commitment would be %d/%d MB", load.CommittedMemoryMB+req.MemoryMB, memoryLimit))
+	}
+	// without memory overcommit the VM has to fit in what is free right now
+	if memRatio <= 1 && req.MemoryMB > load.FreeMemoryMB {
+		rejections = append(rejections, fmt.Sprintf("only %d MB free", load.FreeMemoryMB))
+	}
+	vcpuLimit := int(float64(score.UsableCPUs) * cpuRatio)
+	if load.CommittedVCPUs+req.VCPUs > vcpuLimit {
+		rejections = append(rejections, fmt.Sprintf("vCPU commitment would be %d/%d", load.CommittedVCPUs+req.VCPUs, vcpuLimit))
+	}
+	if req.HugePages && load.HugePagesMB < req.MemoryMB {
+		rejections = append(rejections, fmt.Sprintf("hugepage pool of %d MB is too small", load.HugePagesMB))
+	}
+	if len(rejections) > 0 {
+		score.Reasons = rejections
+		return score
+	}
+
+	memoryLeft := 0.0
+	if load.TotalMemoryMB > 0 && load.FreeMemoryMB > req.MemoryMB {
+		memoryLeft = float64(load.FreeMemoryMB-req.MemoryMB) / float64(load.TotalMemoryMB)
+	}
+	cpuIdle := math.Min(math.Max(1-load.CPUUsage/100, 0), 1)
+	vcpuLeft := 1 - float64(load.CommittedVCPUs+req.VCPUs)/float64(vcpuLimit)
+
+	score.Eligible = true
+	score.Score = schedMemoryWeight*memoryLeft + schedCPUWeight*cpuIdle + schedVCPUWeight*vcpuLeft
+	score.Reasons = []string{
+		fmt.Sprintf("%d MB free of %d MB", load.FreeMemoryMB, load.TotalMemoryMB),
+		fmt.Sprintf("cpu %.0f%% busy", load.CPUUsage),
+		fmt.Sprintf("%d/%d vCPUs committed", load.CommittedVCPUs+req.VCPUs, vcpuLimit),
+	}
+	if load.IsolatedCPUs > 0 {
+		score.Reasons = append(score.Reasons, fmt.Sprintf("%d isolated cores kept for pinned VMs", load.IsolatedCPUs))
+	}
+	if req.HugePages {
+		score.Reasons = append(score.Reasons, fmt.Sprintf("hugepage pool of %d MB", load.HugePagesMB))
+	}
+	return score
+}
+
+// applyPlacementRules rejects a host breaking a hard rule and lowers the
+// score of one breaking soft rules.
+func applyPlacementRules(score *HostScore, rules *placementRules, vmName string) {
+	hard, soft := rules.check(vmName, score.MachineName)
+	if len(hard) > 0 {
+		if score.Eligible {
+			score.Eligible = false
+			score.Score = 0
+			score.Reasons = nil
+		}
+		score.Reasons = append(score.Reasons, hard...)
+		return
+	}
+	if !score.Eligible {
+		return
+	}
+	for _, violation := range soft {
+		score.Score -= schedSoftRulePenalty
+		score.Reasons = append(score.Reasons, "breaks "+violation)
+	}
+}
+
+// sortHostScores puts eligible slaves first, best score first.
+func sortHostScores(hosts []HostScore) {
+	slices.SortStableFunc(hosts, func(a, b HostScore) int {
+		if a.Eligible != b.Eligible {
+			if a.Eligible {
+				return -1
+			}
+			return 1
+		}
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.MachineName, b.MachineName)
+	})
+}
+
+func describeRejections(hosts []HostScore) string {
+	var parts []string
+	for _, host := range hosts {
+		parts = append(parts, fmt.Sprintf("%s: %s", host.MachineName, strings.Join(host.Reasons, ", ")))
+	}
+	if len(parts) == 0 {
+		return ", no slave is available"
+	}
+	return " (" + strings.Join(parts, "; ") + ")"
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"512SvMan/db"
+	"strings"
+	"testing"
+)
+
+func TestHugePageSizeMB(t *testing.T) {
+	tests := []struct {
+		size string
+		want int
+	}{
+		{"2M", 2},
+		{"2m", 2},
+		{" 1G ", 1024},
+		{"2048K", 2},
+		{"512K", 0},
+		{"", 0},
+		{"M", 0},
+		{"-2M", 0},
+		{"2T", 0},
+		{"abc", 0},
+	}
+	for _, tt := range tests {
+		if got := hugePageSizeMB(tt.size); got != tt.want {
+			t.Errorf("hugePageSizeMB(%q) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestScoreHost(t *testing.T) {
+	idle := hostLoad{
+		MachineName:    "node1",
+		FreeMemoryMB:   16384,
+		TotalMemoryMB:  32768,
+		CPUs:           8,
+		ShareReachable: true,
+	}
+	req := PlacementRequest{MemoryMB: 4096, VCPUs: 2}
+
+	tests := []struct {
+		name     string
+		req      PlacementRequest
+		load     func(hostLoad) hostLoad
+		cpuRatio float64
+		memRatio float64
+		eligible bool
+		reason   string
+	}{
+		{
+			name:     "fits",
+			req:      req,
+			cpuRatio: 4, memRatio: 1,
+			eligible: true,
+			reason:   "2/32 vCPUs committed",
+		},
+		{
+			name: "share not reachable",
+			req:  req,
+			load: func(l hostLoad) hostLoad {
+				l.ShareReachable = false
+				return l
+			},
+			cpuRatio: 4, memRatio: 1,
+			reason: "cannot reach the NFS share",
+		},
+		{
+			name: "memory commitment over the ratio",
+			req:  req,
+			load: func(l hostLoad) hostLoad {
+				l.CommittedMemoryMB = 30000
+				return l
+			},
+			cpuRatio: 4, memRatio: 1,
+			reason: "memory commitment would be 34096/32768 MB",
+		},
+		{
+			name: "overcommit allows more than the free memory",
+			req:  PlacementRequest{MemoryMB: 20000, VCPUs: 2},
+			load: func(l hostLoad) hostLoad {
+				l.CommittedMemoryMB = 30000
+				return l
+			},
+			cpuRatio: 4, memRatio: 2,
+			eligible: true,
+		},
+		{
+			name:     "no overcommit needs free memory",
+			req:      PlacementRequest{MemoryMB: 20000, VCPUs: 2},
+			cpuRatio: 4, memRatio: 1,
+			reason: "only 16384 MB free",
+		},
+		{
+			name: "vcpu commitment over the ratio",
+			req:  req,
+			load: func(l hostLoad) hostLoad {
+				l.CommittedVCPUs = 31
+				return l
+			},
+			cpuRatio: 4, memRatio: 1,
+			reason: "vCPU commitment would be 33/32",
+		},
+		{
+			name: "isolated cores are not usable",
+			req:  req,
+			load: func(l hostLoad) hostLoad {
+				l.IsolatedCPUs = 6
+				l.CommittedVCPUs = 7
+				return l
+			},
+			cpuRatio: 4, memRatio: 1,
+			reason: "vCPU commitment would be 9/8",
+		},
+		{
+			name: "hugepage pool too small",
+			req:  PlacementRequest{MemoryMB: 4096, VCPUs: 2, HugePages: true},
+			load: func(l hostLoad) hostLoad {
+				l.HugePagesMB = 2048
+				return l
+			},
+			cpuRatio: 4, memRatio: 1,
+			reason: "hugepage pool of 2048 MB is too small",
+		},
+		{
+			name: "hugepage pool large enough",
+			req:  PlacementRequest{MemoryMB: 4096, VCPUs: 2, HugePages: true},
+			load: func(l hostLoad) hostLoad {
+				l.HugePagesMB = 8192
+				return l
+			},
+			cpuRatio: 4, memRatio: 1,
+			eligible: true,
+			reason:   "hugepage pool of 8192 MB",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			load := idle
+			if tt.load != nil {
+				load = tt.load(load)
+			}
+			score := scoreHost(tt.req, load, tt.cpuRatio, tt.memRatio)
+			if score.Eligible != tt.eligible {
+				t.Fatalf("eligible = %v, want %v (reasons %v)", score.Eligible, tt.eligible, score.Reasons)
+			}
+			if !score.Eligible && score.Score != 0 {
+				t.Fatalf("a rejected host scored %.1f", score.Score)
+			}
+			if tt.reason != "" && !strings.Contains(strings.Join(score.Reasons, ", "), tt.reason) {
+				t.Fatalf("reasons %v do not mention %q", score.Reasons, tt.reason)
+			}
+		})
+	}
+}
+
+func TestScoreHostPrefersIdleHosts(t *testing.T) {
+	req := PlacementRequest{MemoryMB: 2048, VCPUs: 2}
+	busy := scoreHost(req, hostLoad{MachineName: "busy", FreeMemoryMB: 4096, TotalMemoryMB: 16384, CPUs: 4,
+		CPUUsage: 90, CommittedVCPUs: 10, ShareReachable: true}, 4, 1)
+	idle := scoreHost(req, hostLoad{MachineName: "idle", FreeMemoryMB: 14336, TotalMemoryMB: 16384, CPUs: 4,
+		CPUUsage: 5, ShareReachable: true}, 4, 1)
+	if !busy.Eligible || !idle.Eligible {
+		t.Fatalf("both hosts should fit: %+v %+v", busy, idle)
+	}
+	if idle.Score <= busy.Score {
+		t.Fatalf("idle host scored %.1f, busy host %.1f", idle.Score, busy.Score)
+	}
+}
+
+func TestApplyPlacementRules(t *testing.T) {
+	rules := &placementRules{
+		groups: []db.PlacementGroup{
+			{Name: "db", Policy: db.PlacementAntiAffinity, Enforcement: db.PlacementHard, VMs: []string{"db1", "db2"}},
+			{Name: "web", Policy: db.PlacementAntiAffinity, Enforcement: db.PlacementSoft, VMs: []string{"web1", "web2"}},
+		},
+		locations: map[string]string{"db1": "node1", "web1": "node2"},
+	}
+	req := PlacementRequest{MemoryMB: 1024, VCPUs: 1}
+	load := func(machine string) hostLoad {
+		return hostLoad{MachineName: machine, FreeMemoryMB: 8192, TotalMemoryMB: 8192, CPUs: 4, ShareReachable: true}
+	}
+
+	tests := []struct {
+		name     string
+		vm       string
+		machine  string
+		eligible bool
+		penalty  bool
+	}{
+		{"hard anti-affinity rejects the shared host", "db2", "node1", false, false},
+		{"hard anti-affinity allows another host", "db2", "node2", true, false},
+		{"soft anti-affinity lowers the score", "web2", "node2", true, true},
+		{"soft anti-affinity leaves other hosts alone", "web2", "node1", true, false},
+		{"vm outside any group", "other", "node1", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := scoreHost(req, load(tt.machine), 4, 1)
+			before := score.Score
+			applyPlacementRules(&score, rules, tt.vm)
+			if score.Eligible != tt.eligible {
+				t.Fatalf("eligible = %v, want %v (reasons %v)", score.Eligible, tt.eligible, score.Reasons)
+			}
+			if penalized := score.Eligible && score.Score < before; penalized != tt.penalty {
+				t.Fatalf("score went from %.1f to %.1f, penalty expected %v", before, score.Score, tt.penalty)
+			}
+		})
+	}
+}
+
+func TestSortHostScores(t *testing.T) {
+	hosts := []HostScore{
+		{MachineName: "rejected", Eligible: false, Score: 0},
+		{MachineName: "b", Eligible: true, Score: 50},
+		{MachineName: "best", Eligible: true, Score: 80},
+		{MachineName: "a", Eligible: true, Score: 50},
+		{MachineName: "also-rejected", Eligible: false, Score: 0},
+	}
+	sortHostScores(hosts)
+
+	want := []string{"best", "a", "b", "also-rejected", "rejected"}
+	for i, name := range want {
+		if hosts[i].MachineName != name {
+			var got []string
+			for _, host := range hosts {
+				got = append(got, host.MachineName)
+			}
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+}
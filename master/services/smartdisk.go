@@ -95,6 +95,9 @@ func (s *SmartDiskService) StartFullWipe(ctx context.Context, machineName, devic
 	if err != nil {
 		return "", err
 	}
+	if err := s.trackRealloc(machineName, device, "full wipe"); err != nil {
+		logger.Errorf("smartdisk: cannot track full wipe of %s on %s: %v", device, machineName, err)
+	}
 	return resp.GetMessage(), nil
 }
 
@@ -111,6 +114,9 @@ func (s *SmartDiskService) StartNonDestructiveRealloc(ctx context.Context, machi
 	if err != nil {
 		return "", err
 	}
+	if err := s.trackRealloc(machineName, device, "non destructive realloc"); err != nil {
+		logger.Errorf("smartdisk: cannot track non destructive realloc of %s on %s: %v", device, machineName, err)
+	}
 	return resp.GetMessage(), nil
 }
 
@@ -154,6 +160,54 @@ func (s *SmartDiskService) CancelRealloc(ctx context.Context, machineName, devic
 	return resp.GetMessage(), nil
 }
 
+const reallocPollInterval = 10 * time.Second
+
+// trackRealloc follows a wipe/realloc that runs on the slave as a job, canceling
+// the job cancels the realloc.
+func (s *SmartDiskService) trackRealloc(machineName, device, label string) error {
+	_, err := StartJob(JobTypeSmartDisk, machineName+":"+device, 0, func(ctx context.Context, job *JobHandle) error {
+		job.Log("%s of %s on %s", label, device, machineName)
+		ticker := time.NewTicker(reallocPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				conn := protocol.GetConnectionByMachineName(machineName)
+				if conn != nil && conn.Connection != nil {
+					cancelCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+					_, err := smartdisk.CancelRealloc(cancelCtx, conn.Connection, &smartdiskGrpc.ForceReallocRequest{Device: device})
+					cancel()
+					if err != nil {
+						job.Log("cancel realloc: %v", err)
+					}
+				}
+				return ctx.Err()
+			case <-ticker.C:
+			}
+
+			conn := protocol.GetConnectionByMachineName(machineName)
+			if conn == nil || conn.Connection == nil {
+				return fmt.Errorf("lost connection to %s", machineName)
+			}
+			status, err := smartdisk.GetReallocStatus(conn.Connection, &smartdiskGrpc.ForceReallocStatusRequest{Device: device})
+			if err != nil {
+				return fmt.Errorf("realloc status: %w", err)
+			}
+			job.Progress(status.GetPercent())
+			if !status.GetCompleted() {
+				continue
+			}
+			job.Log("read errors %d, write errors %d, corruption errors %d", status.GetReadErrors(), status.GetWriteErrors(), status.GetCorruptionErrors())
+			if msg := strings.TrimSpace(status.GetError()); msg != "" {
+				return fmt.Errorf("%s", msg)
+			}
+			return nil
+		}
+	})
+	return err
+}
+
 func (s *SmartDiskService) DoAutomaticTest() {
 	go func() {
 
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"512SvMan/db"
+	"512SvMan/nots"
+	"512SvMan/protocol"
+	"512SvMan/virsh"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+// liveDiskMove is one disk of a running vm and where it goes.
+type liveDiskMove struct {
+	vmDisk     *db.VMDisk // nil for the vm's own disk
+	source     string
+	dest       string
+	destFolder string
+	format     string
+}
+
+// MoveDiskLive moves the vm's disk, or the attached VM disk vmDiskID when it is
+// not 0, to the nfs share nfsId while the vm keeps running. The slave mirrors
+// the disk with a libvirt block copy and switches the vm over once the copy is
+// in sync, then the old file is deleted.
+func (v *VirshService) MoveDiskLive(ctx context.Context, vmName string, nfsId int, vmDiskID int, maxBandwidthMiB uint64) (int, error) {
+	vm, err := v.GetVmByName(vmName)
+	if err != nil {
+		return 0, err
+	}
+	if vm == nil {
+		return 0, fmt.Errorf("vm %s does not exist", vmName)
+	}
+	if vm.State != grpcVirsh.VmState_RUNNING && vm.State != grpcVirsh.VmState_PAUSED {
+		return 0, fmt.Errorf("vm %s is not running, use moveDisk instead", vmName)
+	}
+	if _, err := v.GetMigration(vmName); err == nil {
+		return 0, fmt.Errorf("vm %s is migrating", vmName)
+	}
+	conn := protocol.GetConnectionByMachineName(vm.MachineName)
+	if conn == nil || conn.Connection == nil {
+		return 0, fmt.Errorf("machine %s not connected", vm.MachineName)
+	}
+
+	var move *liveDiskMove
+	if vmDiskID == 0 {
+		move, err = v.planVMDiskMove(ctx, vm, nfsId)
+	} else {
+		move, err = planAttachedDiskMove(ctx, vm, vmDiskID, nfsId)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return StartJob(JobTypeMoveDisk, vmName, longTaskTimeout, func(taskCtx context.Context, job *JobHandle) error {
+		job.Log("moving %s of %s to %s while it runs", move.source, vmName, move.dest)
+		phase := ""
+		err := virsh.BlockCopyDisk(taskCtx, conn.Connection, &grpcVirsh.BlockCopyRequest{
+			VmName:          vmName,
+			SourcePath:      move.source,
+			DestPath:        move.dest,
+			Format:          move.format,
+			MaxBandwidthMib: maxBandwidthMiB,
+			RemoveSource:    true,
+		}, func(progress *grpcVirsh.BlockCopyProgress) {
+			if progress.Phase != phase {
+				phase = progress.Phase
+				job.Log("block copy phase: %s", phase)
+			}
+			job.Progress(progress.Percent)
+		})
+		if err != nil {
+			if rmErr := os.Remove(move.destFolder); rmErr != nil && !os.IsNotExist(rmErr) {
+				logger.Warnf("MoveDiskLive: remove %s: %v", move.destFolder, rmErr)
+			}
+			sendImportantNotification("MoveDiskLive failed", fmt.Errorf("%s: %w", vmName, err))
+			return err
+		}
+
+		if err := afterLiveDiskMove(taskCtx, vmName, nfsId, move); err != nil {
+			sendImportantNotification("MoveDiskLive: disk moved but records not updated", err)
+			return err
+		}
+		job.Log("%s now runs from %s", vmName, move.dest)
+		nots.SendGlobalNotification("Disk moved", fmt.Sprintf("%s now runs from %s", vmName, move.dest), "/", false)
+		return nil
+	})
+}
+
+func (v *VirshService) planVMDiskMove(ctx context.Context, vm *grpcVirsh.Vm, nfsId int) (*liveDiskMove, error) {
+	vmNfs, err := v.GetNfsByVM(ctx, vm)
+	if err != nil {
+		return nil, err
+	}
+	if vmNfs == nfsId {
+		return nil, fmt.Errorf("cannot move disk to same nfs")
+	}
+	if golden, err := db.GetGoldenImageByName(ctx, db.GoldenImageKindVM, vm.Name); err != nil {
+		return nil, fmt.Errorf("failed to check golden image: %v", err)
+	} else if golden != nil {
+		return nil, fmt.Errorf("vm %s is a golden image, linked clones depend on its disk", vm.Name)
+	}
+
+	dest, err := v.ImportVmHelper(ctx, nfsId, vm.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &liveDiskMove{
+		source:     vm.DiskPath,
+		dest:       dest,
+		destFolder: filepath.Dir(dest),
+		format:     "qcow2",
+	}, nil
+}
+
+func planAttachedDiskMove(ctx context.Context, vm *grpcVirsh.Vm, vmDiskID int, nfsId int) (*liveDiskMove, error) {
+	disk, err := db.GetVMDiskByID(ctx, vmDiskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VM disk: %w", err)
+	}
+	if disk == nil {
+		return nil, fmt.Errorf("VM disk with ID %d not found", vmDiskID)
+	}
+	if disk.AttachedVMName != vm.Name {
+		return nil, fmt.Errorf("VM disk %d is not attached to VM %s", vmDiskID, vm.Name)
+	}
+	if disk.NFSID == nfsId {
+		return nil, fmt.Errorf("cannot move disk to same nfs")
+	}
+	if golden, err := db.GetGoldenImageByName(ctx, db.GoldenImageKindDisk, disk.Name); err != nil {
+		return nil, fmt.Errorf("failed to check golden image: %w", err)
+	} else if golden != nil {
+		return nil, fmt.Errorf("VM disk %s is a golden image, linked clones depend on it", disk.Name)
+	}
+
+	share, err := db.GetNFSShareByID(ctx, nfsId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NFS share by ID: %v", err)
+	}
+	if share == nil {
+		return nil, fmt.Errorf("NFS share with ID %d not found", nfsId)
+	}
+	folder := filepath.Join(share.Target, "vm_disk_"+disk.Name)
+	if _, err := os.Stat(folder); err == nil {
+		return nil, fmt.Errorf("folder %s already exists", folder)
+	}
+	if err := os.MkdirAll(folder, 0777); err != nil {
+		return nil, fmt.Errorf("failed to create folder %s: %v", folder, err)
+	}
+	return &liveDiskMove{
+		vmDisk:     disk,
+		source:     disk.DiskPath,
+		dest:       filepath.Join(folder, filepath.Base(disk.DiskPath)),
+		destFolder: folder,
+		format:     disk.Format,
+	}, nil
+}
+
+// afterLiveDiskMove points the records at the new file. The copy is
+// standalone, so a linked clone is no longer one, and the slave dropped the
+// checkpoints the backup chain was built on.
+func afterLiveDiskMove(ctx context.Context, vmName string, nfsId int, move *liveDiskMove) error {
+	kind, name := db.GoldenImageKindVM, vmName
+	if move.vmDisk != nil {
+		kind, name = db.GoldenImageKindDisk, move.vmDisk.Name
+		if err := db.UpdateVMDiskLocation(ctx, move.vmDisk.Id, nfsId, move.dest, move.destFolder); err != nil {
+			return fmt.Errorf("update VM disk %d: %w", move.vmDisk.Id, err)
+		}
+	}
+	if err := db.RemoveLinkedClone(ctx, kind, name); err != nil {
+		return fmt.Errorf("forget linked clone %s: %w", name, err)
+	}
+	if err := db.ClearVirshBackupCheckpoints(ctx, vmName); err != nil {
+		return err
+	}
+
+	// the old folder only goes away when nothing else was kept in it
+	if err := os.Remove(filepath.Dir(move.source)); err != nil && !os.IsNotExist(err) {
+		logger.Warnf("MoveDiskLive: old folder %s kept: %v", filepath.Dir(move.source), err)
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"512SvMan/db"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Maruqes/512SvMan/logger"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// resource types used by roles and grants, the last three are only reachable
+// through a role
+const (
+	ResourceVMs       = "vms"
+	ResourceNFS       = "nfs"
+	ResourceBTRFS     = "btrfs"
+	ResourceDocker    = "docker"
+	ResourceNPM       = "npm"
+	ResourceWireguard = "wireguard"
+	ResourceSystem    = "system"
+	ResourceCluster   = "cluster"
+	ResourceUsers     = "users"
+)
+
+// GrantableResources can be given to a single user per resource.
+var GrantableResources = []string{ResourceVMs, ResourceNFS, ResourceBTRFS, ResourceDocker, ResourceNPM, ResourceWireguard}
+
+var Roles = []string{db.RoleAdmin, db.RoleOperator, db.RoleViewer, db.RoleVMOwner}
+
+// rolePermissions is what a role can do without any grant, admin can do
+// everything and vm_owner only what it was granted.
+var rolePermissions = map[string]map[string]string{
+	db.RoleOperator: {
+		ResourceVMs:       db.AccessWrite,
+		ResourceNFS:       db.AccessWrite,
+		ResourceBTRFS:     db.AccessWrite,
+		ResourceDocker:    db.AccessWrite,
+		ResourceNPM:       db.AccessWrite,
+		ResourceWireguard: db.AccessWrite,
+		ResourceSystem:    db.AccessRead,
+		ResourceCluster:   db.AccessRead,
+	},
+	db.RoleViewer: {
+		ResourceVMs:       db.AccessRead,
+		ResourceNFS:       db.AccessRead,
+		ResourceBTRFS:     db.AccessRead,
+		ResourceDocker:    db.AccessRead,
+		ResourceNPM:       db.AccessRead,
+		ResourceWireguard: db.AccessRead,
+		ResourceSystem:    db.AccessRead,
+		ResourceCluster:   db.AccessRead,
+	},
+}
+
+func accessLevel(access string) int {
+	switch access {
+	case db.AccessRead:
+		return 1
+	case db.AccessWrite:
+		return 2
+	}
+	return 0
+}
+
+// Principal is the user behind an authenticated request.
+type Principal struct {
+	User   db.User
+	Grants []db.UserGrant
+	// NPMToken is the nginx proxy manager token of the session, empty for
+	// local users
+	NPMToken string
+	// Token is set when the request came with an api token, its scopes
+	// limit what the user can do
+	Token *db.APIToken
+}
+
+// Can reports if the principal has access to a resource, name is empty for
+// routes that are not about a single resource. Reading such a route is
+// allowed with any grant on the type so lists can be filtered by the handler.
+func (p *Principal) Can(resourceType, name, access string) bool {
+	if p == nil || p.User.Disabled {
+		return false
+	}
+	need := accessLevel(access)
+	if need == 0 {
+		return false
+	}
+	if p.Token != nil && !p.tokenAllows(resourceType, access) {
+		return false
+	}
+	if p.User.Role == db.RoleAdmin || accessLevel(rolePermissions[p.User.Role][resourceType]) >= need {
+		return true
+	}
+	for _, grant := range p.Grants {
+		if grant.ResourceType != resourceType {
+			continue
+		}
+		if grant.ResourceName == "*" || (name != "" && grant.ResourceName == name) {
+			if accessLevel(grant.Access) >= need {
+				return true
+			}
+			continue
+		}
+		if name == "" && need == accessLevel(db.AccessRead) {
+			return true
+		}
+	}
+	return false
+}
+
+type UserService struct{}
+
+// UserInfo is a user as returned by the api, with its grants.
+type UserInfo struct {
+	db.User
+	Grants []db.UserGrant `json:"grants"`
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < 8 {
+		return "", fmt.Errorf("password must have at least 8 characters")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func validRole(role string) error {
+	if !slices.Contains(Roles, role) {
+		return fmt.Errorf("invalid role %q, expected one of %s", role, strings.Join(Roles, ", "))
+	}
+	return nil
+}
+
+func (s *UserService) getUser(ctx context.Context, id int) (*db.User, error) {
+	user, err := db.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %d not found", id)
+	}
+	return user, nil
+}
+
+func (s *UserService) info(ctx context.Context, user *db.User) (*UserInfo, error) {
+	grants, err := db.GetUserGrants(ctx, user.Id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get grants: %w", err)
+	}
+	if grants == nil {
+		grants = []db.UserGrant{}
+	}
+	return &UserInfo{User: *user, Grants: grants}, nil
+}
+
+func (s *UserService) List(ctx context.Context) ([]UserInfo, error) {
+	users, err := db.GetUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	infos := make([]UserInfo, 0, len(users))
+	for i := range users {
+		info, err := s.info(ctx, &users[i])
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, *info)
+	}
+	return infos, nil
+}
+
+func (s *UserService) Get(ctx context.Context, id int) (*UserInfo, error) {
+	user, err := s.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.info(ctx, user)
+}
+
+// Create adds a local user, npm users are only created by logging in.
+func (s *UserService) Create(ctx context.Context, username, email, password, role string) (*UserInfo, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+	if err := validRole(role); err != nil {
+		return nil, err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := db.GetUserByLogin(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check username: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("user %s already exists", username)
+	}
+
+	user, err := db.AddUser(ctx, db.User{
+		Username:     username,
+		Email:        strings.TrimSpace(email),
+		Role:         role,
+		Source:       db.UserSourceLocal,
+		PasswordHash: hash,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	logger.Infof("user %s created with role %s", user.Username, user.Role)
+	return s.info(ctx, user)
+}
+
+type UserUpdate struct {
+	Email    *string `json:"email"`
+	Role     *string `json:"role"`
+	Disabled *bool   `json:"disabled"`
+	Password *string `json:"password"`
+}
+
+// Update changes a user, the sessions of the user are dropped when its role,
+// password or disabled state change so the change applies right away.
+func (s *UserService) Update(ctx context.Context, id int, update UserUpdate) (*UserInfo, error) {
+	user, err := s.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	wasAdmin := user.Role == db.RoleAdmin && !user.Disabled
+	dropSessions := false
+
+	if update.Email != nil {
+		user.Email = strings.TrimSpace(*update.Email)
+	}
+	if update.Role != nil && *update.Role != user.Role {
+		if err := validRole(*update.Role); err != nil {
+			return nil, err
+		}
+		user.Role = *update.Role
+		dropSessions = true
+	}
+	if update.Disabled != nil && *update.Disabled != user.Disabled {
+		user.Disabled = *update.Disabled
+		dropSessions = true
+	}
+	if wasAdmin && (user.Role != db.RoleAdmin || user.Disabled) {
+		if err := s.checkNotLastAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if update.Password != nil {
+		if user.Source != db.UserSourceLocal {
+			return nil, fmt.Errorf("user %s logs in through %s, change the password there", user.Username, user.Source)
+		}
+		hash, err := hashPassword(*update.Password)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.SetUserPassword(ctx, user.Id, hash); err != nil {
+			return nil, fmt.Errorf("failed to set password: %w", err)
+		}
+		dropSessions = true
+	}
+	if err := db.UpdateUser(ctx, *user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	if dropSessions {
+		if err := db.DeleteUserSessions(ctx, user.Id); err != nil {
+			return nil, fmt.Errorf("failed to drop sessions: %w", err)
+		}
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *UserService) checkNotLastAdmin(ctx context.Context) error {
+	admins, err := db.CountActiveAdmins(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if admins <= 1 {
+		return fmt.Errorf("this is the last active admin")
+	}
+	return nil
+}
+
+func (s *UserService) Delete(ctx context.Context, id int) error {
+	user, err := s.getUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.Role == db.RoleAdmin && !user.Disabled {
+		if err := s.checkNotLastAdmin(ctx); err != nil {
+			return err
+		}
+	}
+	if err := db.RevokeUserAPITokens(ctx, id); err != nil {
+		return fmt.Errorf("failed to revoke api tokens: %w", err)
+	}
+	if err := db.DeleteUser(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	logger.Infof("user %s deleted", user.Username)
+	return nil
+}
+
+func (s *UserService) SetGrant(ctx context.Context, userID int, grant db.UserGrant) (*db.UserGrant, error) {
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(GrantableResources, grant.ResourceType) {
+		return nil, fmt.Errorf("invalid resource type %q, expected one of %s", grant.ResourceType, strings.Join(GrantableResources, ", "))
+	}
+	grant.ResourceName = strings.TrimSpace(grant.ResourceName)
+	if grant.ResourceName == "" {
+		return nil, fmt.Errorf("resource_name is required, use * for every resource")
+	}
+	if accessLevel(grant.Access) == 0 {
+		return nil, fmt.Errorf("invalid access %q, expected read or write", grant.Access)
+	}
+	grant.UserId = userID
+	saved, err := db.SetUserGrant(ctx, grant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save grant: %w", err)
+	}
+	return saved, nil
+}
+
+func (s *UserService) DeleteGrant(ctx context.Context, userID, grantID int) error {
+	if err := db.DeleteUserGrant(ctx, userID, grantID); err != nil {
+		return fmt.Errorf("failed to delete grant: %w", err)
+	}
+	return nil
+}
@@ -171,7 +171,8 @@ func (v *VirshService) GetCpuDisableFeatures(conns []string) (string, error) {
 }
 
 // vmReq.MachineName, vmReq.Name, vmReq.Memory, vmReq.Vcpu, vmReq.NfsShareId, vmReq.DiskSizeGB, vmReq.IsoID, vmReq.Network, vmReq.VNCPassword
-func (v *VirshService) CreateVM(ctx context.Context, machine_name string, name string, memory int32, vcpu int32, nfsShareId int, diskSizeGB int32, isoID int, network string, VNCPassword string, cpuXML string, autoStart bool, isWindows bool, templateID int) error {
+// cloudInit is optional, when set the slave attaches a NoCloud seed for the first boot
+func (v *VirshService) CreateVM(ctx context.Context, machine_name string, name string, memory int32, vcpu int32, nfsShareId int, diskSizeGB int32, isoID int, network string, VNCPassword string, cpuXML string, autoStart bool, isWindows bool, templateID int, cloudInit *grpcVirsh.CloudInitConfig) error {
 
 	exists, err := virsh.DoesVMExist(name)
 	if err != nil {
@@ -185,6 +186,12 @@ func (v *VirshService) CreateVM(ctx context.Context, machine_name string, name s
 	if slaveMachine == nil {
 		return fmt.Errorf("machine %s not found", machine_name)
 	}
+	if err := ensurePlacementAllowed(ctx, machine_name); err != nil {
+		return err
+	}
+	if err := ensurePlacementRules(ctx, name, machine_name); err != nil {
+		return err
+	}
 
 	//get disk path from nfsShareId
 	nfsShare, err := db.GetNFSShareByID(ctx, nfsShareId)
@@ -248,7 +255,7 @@ func (v *VirshService) CreateVM(ctx context.Context, machine_name string, name s
 		return err
 	}
 
-	if err := virsh.CreateVM(slaveMachine.Connection, name, bootstrapMemory, bootstrapVCPU, diskFolder, qcowFile, diskSizeGB, isoPath, bootstrapNetwork, VNCPassword, cpuXML, autoStart, isWindows); err != nil {
+	if err := virsh.CreateVM(slaveMachine.Connection, name, bootstrapMemory, bootstrapVCPU, diskFolder, qcowFile, diskSizeGB, isoPath, bootstrapNetwork, VNCPassword, cpuXML, autoStart, isWindows, cloudInit); err != nil {
 		return err
 	}
 
@@ -262,7 +269,7 @@ func (v *VirshService) CreateVM(ctx context.Context, machine_name string, name s
 	return nil
 }
 
-func (v *VirshService) CreateLiveVM(ctx context.Context, machine_name string, name string, memory int32, vcpu int32, nfsShareId int, diskSizeGB int32, isoID int, network string, VNCPassword string, cpuXml string, autoStart bool, isWindows bool, templateID int) error {
+func (v *VirshService) CreateLiveVM(ctx context.Context, machine_name string, name string, memory int32, vcpu int32, nfsShareId int, diskSizeGB int32, isoID int, network string, VNCPassword string, cpuXml string, autoStart bool, isWindows bool, templateID int, cloudInit *grpcVirsh.CloudInitConfig) error {
 	exists, err := db.DoesVmLiveExist(ctx, name)
 	if err != nil {
 		return fmt.Errorf("failed to check if live VM exists in database: %v", err)
@@ -284,7 +291,7 @@ func (v *VirshService) CreateLiveVM(ctx context.Context, machine_name string, na
 		return fmt.Errorf("cant have live VM on a HostNormalMount NFS true, use a nfs where HostNormalMount is false")
 	}
 
-	err = v.CreateVM(ctx, machine_name, name, memory, vcpu, nfsShareId, diskSizeGB, isoID, network, VNCPassword, cpuXml, autoStart, isWindows, templateID)
+	err = v.CreateVM(ctx, machine_name, name, memory, vcpu, nfsShareId, diskSizeGB, isoID, network, VNCPassword, cpuXml, autoStart, isWindows, templateID, cloudInit)
 	if err != nil {
 		return err
 	}
@@ -302,6 +309,9 @@ func (v *VirshService) ColdMigrateVm(ctx context.Context, slaveName string, mach
 	if originConn == nil || originConn.Connection == nil {
 		return fmt.Errorf("machine %s not found or not connected", slaveName)
 	}
+	if err := ensurePlacementRules(ctx, machine.VmName, slaveName); err != nil {
+		return err
+	}
 
 	renderedTemplateXML, err := v.prepareVMXMLTemplateForCreate(ctx, templateID, machine.VmName, machine.DiskPath)
 	if err != nil {
@@ -354,7 +364,7 @@ func (v *VirshService) ColdMigrateVm(ctx context.Context, slaveName string, mach
 	return nil
 }
 
-func (v *VirshService) MigrateVm(ctx context.Context, originMachine string, destMachine string, vmName string, live bool, timeoutSeconds int) error {
+func (v *VirshService) MigrateVm(ctx context.Context, originMachine string, destMachine string, vmName string, live bool, timeoutSeconds int, opts MigrationOptions) error {
 	logErr := func(e error) error {
 		logger.Error(e.Error())
 		return e
@@ -371,6 +381,12 @@ func (v *VirshService) MigrateVm(ctx context.Context, originMachine string, dest
 	if originMachine == destMachine {
 		return logErr(fmt.Errorf("origin and destination machines cannot be the same"))
 	}
+	if err := ensurePlacementAllowed(ctx, destMachine); err != nil {
+		return logErr(err)
+	}
+	if err := ensurePlacementRules(ctx, vmName, destMachine); err != nil {
+		return logErr(err)
+	}
 
 	//Get Connections
 	originConn := protocol.GetConnectionByMachineName(originMachine)
@@ -403,19 +419,18 @@ func (v *VirshService) MigrateVm(ctx context.Context, originMachine string, dest
 		return logErr(fmt.Errorf("VM %s is not running on origin machine %s", vmName, originMachine))
 	}
 
-	go func() {
-		ctxTimeout, cancel := context.WithTimeout(context.Background(), longTaskTimeout)
-		defer cancel()
-		err := virsh.MigrateVm(ctxTimeout, originConn.Connection, vmName, destConn.Addr, live, timeoutSeconds)
+	_, err = StartJob(JobTypeMigrate, vmName, longTaskTimeout, func(ctxTimeout context.Context, job *JobHandle) error {
+		job.Log("migrating %s from %s to %s (live=%t)", vmName, originMachine, destMachine, live)
+		err := runMigration(ctxTimeout, originConn, destConn, vmName, live, timeoutSeconds, opts, job)
 		if err != nil {
 			logger.Errorf("%v", err)
 			logger.Error(err.Error())
 			extra.SendWebsocketMessage(protoExtra.WebSocketsMessageType_Error, fmt.Sprintf("MigrateVm failed for %s: %v", vmName, err), vmName)
 			sendImportantNotification("MigrateVm failed", err)
 		}
-	}()
-
-	return nil
+		return err
+	})
+	return err
 }
 
 func (v *VirshService) UpdateCpuXml(_ context.Context, machine_name string, vmName string, cpuXml string) error {
@@ -517,6 +532,9 @@ func (v *VirshService) DeleteVM(ctx context.Context, name string) error {
 	if !exists {
 		return fmt.Errorf("a VM with the name %s does not exist", name)
 	}
+	if _, err := checkGoldenDeletable(ctx, db.GoldenImageKindVM, name); err != nil {
+		return err
+	}
 
 	con := protocol.GetAllGRPCConnections()
 	for _, conn := range con {
@@ -554,6 +572,26 @@ func (v *VirshService) DeleteVM(ctx context.Context, name string) error {
 				return fmt.Errorf("failed to clear VM disk attachments for VM %s: %v", name, err)
 			}
 
+			if err := forgetGoldenRecords(ctx, db.GoldenImageKindVM, name); err != nil {
+				return fmt.Errorf("failed to remove golden image records for VM %s: %v", name, err)
+			}
+
+			if err := db.DeleteResourceGrants(ctx, ResourceVMs, name); err != nil {
+				return fmt.Errorf("failed to remove user grants for VM %s: %v", name, err)
+			}
+
+			if err := db.DeleteHAPolicy(ctx, name); err != nil {
+				return fmt.Errorf("failed to remove ha policy for VM %s: %v", name, err)
+			}
+
+			if err := db.RemoveVMTags(ctx, name); err != nil {
+				return fmt.Errorf("failed to remove tags of VM %s: %v", name, err)
+			}
+
+			if err := db.DeleteVMMetrics(ctx, name); err != nil {
+				return fmt.Errorf("failed to remove metrics of VM %s: %v", name, err)
+			}
+
 			return nil
 		}
 	}
@@ -572,6 +610,12 @@ func (v *VirshService) StartVM(ctx context.Context, name string) error {
 	if !exists {
 		return fmt.Errorf("a VM with the name %s does not exist", name)
 	}
+	// writes to a golden image would corrupt every linked clone
+	if golden, err := db.GetGoldenImageByName(ctx, db.GoldenImageKindVM, name); err != nil {
+		return fmt.Errorf("failed to check golden image: %v", err)
+	} else if golden != nil {
+		return fmt.Errorf("vm %s is a golden image and cannot be started, clone it instead", name)
+	}
 
 	con := protocol.GetAllGRPCConnections()
 	for _, conn := range con {
@@ -933,6 +977,11 @@ func (v *VirshService) AddVMDisk(ctx context.Context, vmName string, vmDiskID in
 	if strings.TrimSpace(disk.AttachedVMName) != "" {
 		return nil, fmt.Errorf("VM disk %d is already attached to VM %s", vmDiskID, disk.AttachedVMName)
 	}
+	if golden, err := db.GetGoldenImageByName(ctx, db.GoldenImageKindDisk, disk.Name); err != nil {
+		return nil, fmt.Errorf("failed to check golden image: %w", err)
+	} else if golden != nil {
+		return nil, fmt.Errorf("VM disk %s is a golden image, attach a linked clone of it instead", disk.Name)
+	}
 
 	vm, err := v.GetVmByName(vmName)
 	if err != nil {
@@ -1590,6 +1639,7 @@ func (v *VirshService) startAutoStartVms(ctx context.Context, machineFilter stri
 		ctx = context.Background()
 	}
 
+	// already sorted by boot group and order
 	autoStart, err := db.GetAllAutoStart(ctx)
 	if err != nil {
 		return err
@@ -1726,6 +1776,15 @@ func (v *VirshService) startAutoStartVms(ctx context.Context, machineFilter stri
 
 			worked := runVm(vm, conn, auto, tries)
 			if worked {
+				// the next vm in the boot order may depend on this one
+				waitAutoStartReady(ctx, conn, auto)
+				if auto.DelaySeconds > 0 {
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-time.After(time.Duration(auto.DelaySeconds) * time.Second):
+					}
+				}
 				break
 			}
 		}
@@ -1788,6 +1847,11 @@ func (v *VirshService) MoveDisk(ctx context.Context, vmName string, nfsId int, n
 	if vmNfs == nfsId {
 		return logErr(fmt.Errorf("cannot move disk to same nfs"))
 	}
+	if golden, err := db.GetGoldenImageByName(ctx, db.GoldenImageKindVM, vmName); err != nil {
+		return logErr(fmt.Errorf("failed to check golden image: %v", err))
+	} else if golden != nil {
+		return logErr(fmt.Errorf("vm %s is a golden image, linked clones depend on its disk", vmName))
+	}
 
 	// Check if it's a live VM before deleting
 	liveQuestion, err := v.isVmLive(ctx, vmName)
@@ -1830,12 +1894,9 @@ func (v *VirshService) MoveDisk(ctx context.Context, vmName string, nfsId int, n
 		logger.Debug(string(data))
 	}
 
-	go func() {
-		taskCtx, cancel := context.WithTimeout(context.Background(), longTaskTimeout)
-		defer cancel()
-
+	_, err = StartJob(JobTypeMoveDisk, vmName, longTaskTimeout, func(taskCtx context.Context, job *JobHandle) error {
 		err := func() error {
-			if err := copyFile(taskCtx, vm.DiskPath, finalFile, newName); err != nil {
+			if err := v.copyVMDisk(taskCtx, vm, finalFile); err != nil {
 				return fmt.Errorf("copy disk: %w", err)
 			}
 
@@ -1843,6 +1904,11 @@ func (v *VirshService) MoveDisk(ctx context.Context, vmName string, nfsId int, n
 				return fmt.Errorf("ColdMigrateVm: %w", err)
 			}
 
+			// the copy is flattened, the vm no longer holds its golden image
+			if err := db.RemoveLinkedClone(taskCtx, db.GoldenImageKindVM, vmName); err != nil {
+				return fmt.Errorf("forget linked clone %s: %w", vmName, err)
+			}
+
 			if err := v.DeleteVM(taskCtx, vmName); err != nil {
 				return fmt.Errorf("DeleteVM: %w", err)
 			}
@@ -1851,6 +1917,11 @@ func (v *VirshService) MoveDisk(ctx context.Context, vmName string, nfsId int, n
 				if err := db.AddAutoStart(taskCtx, newName); err != nil {
 					return fmt.Errorf("AddAutoStart: %w", err)
 				}
+				boot := *autoStartQuestion
+				boot.VmName = newName
+				if err := db.SetAutoStartBoot(taskCtx, boot); err != nil {
+					return fmt.Errorf("SetAutoStartBoot: %w", err)
+				}
 			}
 
 			return nil
@@ -1861,9 +1932,9 @@ func (v *VirshService) MoveDisk(ctx context.Context, vmName string, nfsId int, n
 			extra.SendWebsocketMessage(protoExtra.WebSocketsMessageType_Error, fmt.Sprintf("MoveDisk failed for %s: %v", vmName, err), vmName)
 			sendImportantNotification("MoveDisk failed", err)
 		}
-	}()
-
-	return nil
+		return err
+	})
+	return err
 }
 
 func (v *VirshService) ColdMigrate(ctx context.Context, vmName string, destinationMachine string, templateID int) error {
@@ -1899,6 +1970,12 @@ func (v *VirshService) ColdMigrate(ctx context.Context, vmName string, destinati
 	if vm.MachineName == destinationMachine {
 		return logErr(fmt.Errorf("destinationMachine can not be the same as origin machine"))
 	}
+	if err := ensurePlacementAllowed(ctx, destinationMachine); err != nil {
+		return logErr(err)
+	}
+	if err := ensurePlacementRules(ctx, vmName, destinationMachine); err != nil {
+		return logErr(err)
+	}
 
 	//check if it exists
 
@@ -1925,10 +2002,7 @@ func (v *VirshService) ColdMigrate(ctx context.Context, vmName string, destinati
 		logger.Debug(string(data))
 	}
 
-	go func() {
-		taskCtx, cancel := context.WithTimeout(context.Background(), longTaskTimeout)
-		defer cancel()
-
+	_, err = StartJob(JobTypeColdMigrate, vmName, longTaskTimeout, func(taskCtx context.Context, job *JobHandle) error {
 		err := func() error {
 			if err := v.ColdMigrateVm(taskCtx, destinationMachine, &coldMigr, templateID); err != nil {
 				return err
@@ -1945,11 +2019,13 @@ func (v *VirshService) ColdMigrate(ctx context.Context, vmName string, destinati
 			extra.SendWebsocketMessage(protoExtra.WebSocketsMessageType_Error, fmt.Sprintf("ColdMigrate failed for %s: %v", vmName, err), vmName)
 			sendImportantNotification("ColdMigrate failed", err)
 		}
-	}()
-
-	return nil
+		return err
+	})
+	return err
 }
-func (v *VirshService) CloneVM(ctx context.Context, vmName string, newName string, destinationMachine string, nfsId int, templateID int) error {
+
+// linked clones get a thin overlay on top of the golden image instead of a full copy
+func (v *VirshService) CloneVM(ctx context.Context, vmName string, newName string, destinationMachine string, nfsId int, templateID int, linked bool) error {
 	logErr := func(e error) error {
 		logger.Error(e.Error())
 		return e
@@ -1981,12 +2057,41 @@ func (v *VirshService) CloneVM(ctx context.Context, vmName string, newName strin
 	if exists {
 		return logErr(fmt.Errorf("a VM with the name %s already exists", newName))
 	}
+	if err := ensurePlacementAllowed(ctx, destinationMachine); err != nil {
+		return logErr(err)
+	}
+	if err := ensurePlacementRules(ctx, newName, destinationMachine); err != nil {
+		return logErr(err)
+	}
 
 	liveQuestion, err := v.isVmLive(ctx, vmName)
 	if err != nil {
 		return logErr(err)
 	}
 
+	var golden *db.GoldenImage
+	if linked {
+		golden, err = db.GetGoldenImageByName(ctx, db.GoldenImageKindVM, vmName)
+		if err != nil {
+			return logErr(fmt.Errorf("failed to check golden image: %v", err))
+		}
+		if golden == nil {
+			return logErr(fmt.Errorf("vm %s is not a golden image", vmName))
+		}
+		// the overlay references its base by a relative path, both have to be on the same share
+		if golden.NFSID != nfsId {
+			return logErr(fmt.Errorf("linked clones of %s must be on nfs share %d", vmName, golden.NFSID))
+		}
+	} else if vm.State != grpcVirsh.VmState_SHUTOFF {
+		isClone, err := isLinkedCloneVM(ctx, vmName)
+		if err != nil {
+			return logErr(err)
+		}
+		if isClone {
+			return logErr(fmt.Errorf("vm %s is a linked clone, shut it down before a full clone", vmName))
+		}
+	}
+
 	//create folder for new vm
 	//checks if nfsShareId exists also and creates finalFile path
 	finalFile, err := v.ImportVmHelper(ctx, nfsId, newName)
@@ -2017,11 +2122,21 @@ func (v *VirshService) CloneVM(ctx context.Context, vmName string, newName strin
 		logger.Debug(string(data))
 	}
 
-	go func() {
-		taskCtx, cancel := context.WithTimeout(context.Background(), longTaskTimeout)
-		defer cancel()
-
+	_, err = StartJob(JobTypeCloneVM, newName, longTaskTimeout, func(taskCtx context.Context, job *JobHandle) error {
 		err := func() error {
+			if golden != nil {
+				if err := createLinkedCloneDisk(taskCtx, golden, vm.MachineName, finalFile); err != nil {
+					return err
+				}
+				if err := v.ColdMigrateVm(taskCtx, destinationMachine, &coldMigr, templateID); err != nil {
+					return err
+				}
+				if _, err := db.AddLinkedClone(taskCtx, golden.Id, db.GoldenImageKindVM, newName, finalFile); err != nil {
+					return fmt.Errorf("failed to record linked clone %s: %w", newName, err)
+				}
+				return nil
+			}
+
 			if vm.State != grpcVirsh.VmState_SHUTOFF {
 				conn := protocol.GetConnectionByMachineName(vm.MachineName)
 				if conn == nil || conn.Connection == nil {
@@ -2045,7 +2160,7 @@ func (v *VirshService) CloneVM(ctx context.Context, vmName string, newName strin
 					return err
 				}
 			} else {
-				if err := copyFile(taskCtx, vm.DiskPath, finalFile, vmName); err != nil {
+				if err := v.copyVMDisk(taskCtx, vm, finalFile); err != nil {
 					return err
 				}
 			}
@@ -2061,9 +2176,9 @@ func (v *VirshService) CloneVM(ctx context.Context, vmName string, newName strin
 			extra.SendWebsocketMessage(protoExtra.WebSocketsMessageType_Error, fmt.Sprintf("CloneVM failed for %s: %v", newName, err), newName)
 			sendImportantNotification("CloneVM failed", err)
 		}
-	}()
-
-	return nil
+		return err
+	})
+	return err
 }
 
 // ─── CPU Pinning ─────────────────────────────────────────────────────────────
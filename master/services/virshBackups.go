@@ -13,7 +13,6 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -24,6 +23,9 @@ import (
 	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
 	"github.com/Maruqes/512SvMan/logger"
 	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var copyFileSlots = make(chan struct{}, 1)
@@ -71,6 +73,8 @@ func copyFile(ctx context.Context, origin, dest, vmName string) (err error) {
 	buf := make([]byte, 32*1024*1024) // 32MB buffer
 
 	identifier := fmt.Sprintf("%s-%d", vmName, time.Now().Unix())
+	job := jobFromContext(ctx)
+	job.Log("copying %s to %s", origin, dest)
 
 	for {
 		if err := ctx.Err(); err != nil {
@@ -88,6 +92,7 @@ func copyFile(ctx context.Context, origin, dest, vmName string) (err error) {
 			// Calculate and log progress
 			progress := float64(copied) / float64(totalSize) * 100
 			extra.SendWebsocketMessage(extraGrpc.WebSocketsMessageType_BackUpVM, fmt.Sprintf("Backup progress for %s: %.2f%%", vmName, progress), identifier)
+			job.Progress(progress)
 		}
 		if err == io.EOF {
 			break
@@ -100,14 +105,9 @@ func copyFile(ctx context.Context, origin, dest, vmName string) (err error) {
 		return fmt.Errorf("failed to flush destination file: %v", err)
 	}
 
-	qemuUID, err := strconv.Atoi(env512.Qemu_UID)
+	qemuUID, qemuGID, err := qemuOwnership()
 	if err != nil {
-		return fmt.Errorf("invalid qemu uid %s: %v", env512.Qemu_UID, err)
-	}
-
-	qemuGID, err := strconv.Atoi(env512.Qemu_GID)
-	if err != nil {
-		return fmt.Errorf("invalid qemu gid %s: %v", env512.Qemu_GID, err)
+		return err
 	}
 
 	if err := output.Chown(qemuUID, qemuGID); err != nil {
@@ -121,6 +121,19 @@ func copyFile(ctx context.Context, origin, dest, vmName string) (err error) {
 	return nil
 }
 
+// qemuOwnership is the uid and gid disk images handed to libvirt must have.
+func qemuOwnership() (int, int, error) {
+	uid, err := strconv.Atoi(env512.Qemu_UID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid qemu uid %s: %v", env512.Qemu_UID, err)
+	}
+	gid, err := strconv.Atoi(env512.Qemu_GID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid qemu gid %s: %v", env512.Qemu_GID, err)
+	}
+	return uid, gid, nil
+}
+
 // helper to send important notifications
 func sendImportantNotification(title string, err error) {
 	if err == nil {
@@ -201,7 +214,18 @@ func (v *VirshService) ImportVmHelper(ctx context.Context, nfsId int, filename s
 // Virtual machine needs to have "qemu-guest-agent" for live
 // Virtual machine needs to have "qemu-guest-agent" for live
 // Virtual machine needs to have "qemu-guest-agent" for live
-func (v *VirshService) BackupVM(ctx context.Context, vmName string, nfsID int, automatic bool) error {
+// When incremental is set and the vm is running, only the blocks changed since the
+// newest checkpointed backup on the same share are copied; it falls back to a full
+// backup when there is no usable parent.
+// storage is how the file is kept on the share, packed backups are always full.
+func (v *VirshService) BackupVM(ctx context.Context, vmName string, nfsID int, automatic bool, incremental bool, storage BackupStorage) error {
+	if err := validateBackupStorage(storage); err != nil {
+		return err
+	}
+	if storage.packed() {
+		incremental = false
+	}
+
 	//check if vmName exists and is turned off, check if nfsID exists
 	vm, err := v.GetVmByName(vmName)
 	if err != nil {
@@ -292,59 +316,110 @@ func (v *VirshService) BackupVM(ctx context.Context, vmName string, nfsID int, a
 				return err
 			}
 
-			logger.Info("Frezzing")
-			err := virsh.FreezeDisk(conn.Connection, vm)
+			logger.Info("Backing up through libvirt")
+			err := v.pushBackup(taskCtx, conn.Connection, backup, incremental)
 			if err != nil {
-				sendImportantNotification("BackupVM: FreezeDisk failed", err)
-				return err
-			}
-
-			defer func() {
-				logger.Info("UnFrezzing")
-				err = virsh.UnFreezeDisk(conn.Connection, vm)
-				if err != nil {
-					logger.Error("Cannot unfreeze machine " + vm.Name)
-				}
-			}()
-
-			logger.Info("Copying")
-			err = copyFile(taskCtx, vm.DiskPath, backup.Path, vmName)
-			if err != nil {
-				sendImportantNotification("BackupVM: copyFile failed", err)
+				sendImportantNotification("BackupVM: BackupDisk failed", err)
 				return err
 			}
 
 		} else {
-			err = copyFile(taskCtx, vm.DiskPath, backup.Path, vmName)
+			err = v.copyVMDisk(taskCtx, vm, backup.Path)
 			if err != nil {
 				sendImportantNotification("BackupVM: copyFile failed", err)
 				return err
 			}
 		}
 
+		if err := storeBackup(taskCtx, backup, vm.MachineName, storage); err != nil {
+			sendImportantNotification("BackupVM: storing backup failed", err)
+			return fmt.Errorf("failed to store backup %s: %v", backup.Path, err)
+		}
+
 		err = db.InsertVirshBackup(taskCtx, backup)
 		if err != nil {
 			sendImportantNotification("BackupVM: InsertVirshBackup failed", err)
 			return fmt.Errorf("problems writing to db backup: %v", err)
 		}
+		recordBackupChecksum(taskCtx, backup)
+		replicateNewBackup(taskCtx, backup.Id)
 
 		nots.SendGlobalNotification("Backup successful", fmt.Sprintf("Backup %s created at %s", vmName, backup.Path), "/", false)
 		return nil
 	}
 
-	go func() {
-		taskCtx, cancel := context.WithTimeout(context.Background(), longTaskTimeout)
-		defer cancel()
-
+	_, err = StartJob(JobTypeBackup, vmName, longTaskTimeout, func(taskCtx context.Context, job *JobHandle) error {
 		err := actuallyDoBakcup(taskCtx)
 		if err != nil {
 			logger.Error(err.Error())
 			extra.SendWebsocketMessage(proto.WebSocketsMessageType_Error, "Could not backups "+err.Error(), vmName)
 			sendImportantNotification("BackupVM: copyFile failed", err)
 		}
-	}()
+		return err
+	})
+	return err
+}
 
-	return nil
+// pushBackup asks the slave to run a libvirt backup job of a running vm. The
+// checkpoint recorded with it is what the next incremental backup builds on.
+func (v *VirshService) pushBackup(ctx context.Context, conn *grpc.ClientConn, backup *db.VirshBackup, incremental bool) error {
+	backup.Checkpoint = "bak-" + uuid.New().String()
+	req := &grpcVirsh.BackupDiskRequest{
+		VmName:     backup.Name,
+		TargetPath: backup.Path,
+		Checkpoint: backup.Checkpoint,
+	}
+
+	if incremental {
+		parent, err := db.GetLatestCheckpointBackup(ctx, backup.Name)
+		if err != nil {
+			return err
+		}
+		// chains never span shares so removing a share can't orphan incrementals,
+		// and a packed backup is no qcow2 to build on
+		if parent != nil && parent.NfsId == backup.NfsId && !parent.NeedsDecode() {
+			req.ParentCheckpoint = parent.Checkpoint
+			req.ParentPath = parent.Path
+
+			err := virsh.BackupDisk(ctx, conn, req)
+			if err == nil {
+				backup.ParentId = &parent.Id
+				backup.Incremental = true
+				return nil
+			}
+			if status.Code(err) != codes.FailedPrecondition {
+				return err
+			}
+			logger.Warnf("incremental backup of %s not possible, doing a full one: %v", backup.Name, err)
+			req.ParentCheckpoint = ""
+			req.ParentPath = ""
+		}
+	}
+
+	return virsh.BackupDisk(ctx, conn, req)
+}
+
+// shouldBackupIncremental follows FullEvery, a new full backup is taken once
+// the current chain already has FullEvery incrementals
+func shouldBackupIncremental(ctx context.Context, bak db.AutomaticBackup) (bool, error) {
+	if bak.FullEvery <= 0 {
+		return false, nil
+	}
+
+	latest, err := db.GetLatestCheckpointBackup(ctx, bak.VmName)
+	if err != nil {
+		return false, err
+	}
+	if latest == nil || latest.NfsId != bak.NfsMountId {
+		return false, nil
+	}
+
+	chain, err := db.GetVirshBackupChain(ctx, latest.Id)
+	if err != nil {
+		logger.Warnf("backup chain of %s is broken, starting a new one: %v", bak.VmName, err)
+		return false, nil
+	}
+	return len(chain)-1 < bak.FullEvery, nil
 }
 
 func (v *VirshService) DeleteBackup(ctx context.Context, bakId int) error {
@@ -360,6 +435,25 @@ func (v *VirshService) DeleteBackup(ctx context.Context, bakId int) error {
 		return err
 	}
 
+	children, err := db.GetVirshBackupChildren(ctx, bakId)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return fmt.Errorf("backup %d has %d incremental backup(s) depending on it, delete them first", bakId, len(children))
+	}
+	if bakup.Pinned {
+		return fmt.Errorf("backup %d is pinned, unpin it first", bakId)
+	}
+	if backupHasFileRestore(bakId) {
+		return fmt.Errorf("backup %d is mounted for file restore, unmount it first", bakId)
+	}
+	// holding the claim also keeps a verification from starting mid delete
+	if !claimBackupVerification(bakId) {
+		return fmt.Errorf("backup %d is being verified, wait for the job to finish", bakId)
+	}
+	defer releaseBackupVerification(bakId)
+
 	dir := filepath.Dir(bakup.Path)
 
 	if err := validateBackupDirectory(dir); err != nil {
@@ -371,6 +465,9 @@ func (v *VirshService) DeleteBackup(ctx context.Context, bakId int) error {
 		sendImportantNotification("DeleteBackup: DeleteVirshBackupById failed", err)
 		return err
 	}
+	if err := db.DeleteBackupVerifications(ctx, bakId); err != nil {
+		logger.Warnf("DeleteBackup: verifications of %d kept: %v", bakId, err)
+	}
 
 	fileErr := os.Remove(bakup.Path)
 	if fileErr != nil && !os.IsNotExist(fileErr) {
@@ -401,7 +498,7 @@ func (v *VirshService) UseBackup(ctx context.Context, bakID int, slaveName strin
 	const longTaskTimeout = 7 * 24 * time.Hour
 
 	originConn := protocol.GetConnectionByMachineName(slaveName)
-	if originConn == nil {
+	if originConn == nil || originConn.Connection == nil {
 		err := fmt.Errorf("origin machine %s not found", slaveName)
 		sendImportantNotification("UseBackup: origin machine not found", err)
 		return logErr(err)
@@ -418,6 +515,22 @@ func (v *VirshService) UseBackup(ctx context.Context, bakID int, slaveName strin
 		return logErr(err)
 	}
 
+	// an incremental backup is only a delta, every file down to the full backup is needed
+	if backup.Incremental {
+		chain, err := db.GetVirshBackupChain(ctx, backup.Id)
+		if err != nil {
+			sendImportantNotification("UseBackup: broken backup chain", err)
+			return logErr(err)
+		}
+		for _, link := range chain {
+			if _, err := os.Stat(link.Path); err != nil {
+				err := fmt.Errorf("backup %d of the chain is not available: %v", link.Id, err)
+				sendImportantNotification("UseBackup: backup chain file missing", err)
+				return logErr(err)
+			}
+		}
+	}
+
 	exists, err := virsh.DoesVMExist(coldReq.VmName)
 	if err != nil {
 		sendImportantNotification("UseBackup: DoesVMExist failed", err)
@@ -472,12 +585,20 @@ func (v *VirshService) UseBackup(ctx context.Context, bakID int, slaveName strin
 		return logErr(err)
 	}
 
-	go func() {
-		taskCtx, cancel := context.WithTimeout(context.Background(), longTaskTimeout)
-		defer cancel()
-
+	_, err = StartJob(JobTypeRestoreBackup, reqCopy.VmName, longTaskTimeout, func(taskCtx context.Context, job *JobHandle) error {
 		err := func() error {
-			if err := copyFile(taskCtx, backup.Path, newDiskPath, reqCopy.VmName); err != nil {
+			if backup.Incremental {
+				if err := virsh.FlattenBackupChain(taskCtx, originConn.Connection, backup.Path, newDiskPath); err != nil {
+					_ = os.RemoveAll(newFolder)
+					return fmt.Errorf("failed to rebuild backup chain: %w", err)
+				}
+			} else if backup.NeedsDecode() {
+				job.Log("decoding %s", backup.Path)
+				if err := decodeBackup(taskCtx, backup, newDiskPath); err != nil {
+					_ = os.RemoveAll(newFolder)
+					return fmt.Errorf("failed to decode backup file: %w", err)
+				}
+			} else if err := copyFile(taskCtx, backup.Path, newDiskPath, reqCopy.VmName); err != nil {
 				_ = os.RemoveAll(newFolder)
 				return fmt.Errorf("failed to copy backup file: %w", err)
 			}
@@ -493,9 +614,9 @@ func (v *VirshService) UseBackup(ctx context.Context, bakID int, slaveName strin
 			extra.SendWebsocketMessage(proto.WebSocketsMessageType_Error, fmt.Sprintf("UseBackup failed for %s: %v", reqCopy.VmName, err), reqCopy.VmName)
 			sendImportantNotification("UseBackup failed", err)
 		}
-	}()
-
-	return nil
+		return err
+	})
+	return err
 }
 
 func (v *VirshService) CreateAutoBak(ctx context.Context, bak db.AutomaticBackup) error {
@@ -538,8 +659,14 @@ func (v *VirshService) CreateAutoBak(ctx context.Context, bak db.AutomaticBackup
 	if bak.FrequencyDays < 1 {
 		return fmt.Errorf("frequency days must be at least 1")
 	}
-	if bak.MaxBackupsRetain < 1 {
-		return fmt.Errorf("max backups retain must be at least 1")
+	if err := validateRetention(bak); err != nil {
+		return err
+	}
+	if _, err := autoBakStorage(bak); err != nil {
+		return err
+	}
+	if bak.FullEvery < 0 {
+		return fmt.Errorf("full every must be 0 or more incrementals")
 	}
 
 	//add to database
@@ -599,8 +726,14 @@ func (v *VirshService) UpdateAutoBak(ctx context.Context, id int, bak db.Automat
 	if bak.FrequencyDays < 1 {
 		return fmt.Errorf("frequency days must be at least 1")
 	}
-	if bak.MaxBackupsRetain < 1 {
-		return fmt.Errorf("max backups retain must be at least 1")
+	if err := validateRetention(bak); err != nil {
+		return err
+	}
+	if _, err := autoBakStorage(bak); err != nil {
+		return err
+	}
+	if bak.FullEvery < 0 {
+		return fmt.Errorf("full every must be 0 or more incrementals")
 	}
 
 	//set the ID to ensure we update the correct record
@@ -694,9 +827,21 @@ func (v *VirshService) DisableAutoBak(ctx context.Context, id int) error {
 
 // fazer backups
 // se sucesso eliminar com GetAutomaticBackups
-// ja elimina backups antigos e mantem MaxBackupsRetain
+// ja elimina backups antigos segundo a retencao (MaxBackupsRetain e GFS)
 func (v *VirshService) createAutoBak(ctx context.Context, bak db.AutomaticBackup) error {
-	if err := v.BackupVM(ctx, bak.VmName, bak.NfsMountId, true); err != nil {
+	incremental, err := shouldBackupIncremental(ctx, bak)
+	if err != nil {
+		sendImportantNotification("createAutoBak: shouldBackupIncremental failed", err)
+		return err
+	}
+
+	storage, err := autoBakStorage(bak)
+	if err != nil {
+		sendImportantNotification("createAutoBak: invalid storage options", err)
+		return err
+	}
+
+	if err := v.BackupVM(ctx, bak.VmName, bak.NfsMountId, true, incremental, storage); err != nil {
 		sendImportantNotification("createAutoBak: BackupVM failed", err)
 		return err
 	}
@@ -708,40 +853,11 @@ func (v *VirshService) createAutoBak(ctx context.Context, bak db.AutomaticBackup
 	}
 
 	//eliminar baks antigos
-	baksVm, err := db.GetAutomaticBackups(ctx, bak.VmName)
-	if err != nil {
-		sendImportantNotification("createAutoBak: GetAutomaticBackups failed", err)
+	if _, err := v.pruneAutoBak(ctx, bak, false); err != nil {
+		sendImportantNotification("createAutoBak: pruning old backups failed", err)
 		return err
 	}
 
-	// Sort backups by CreatedAt (newest first)
-	sort.SliceStable(baksVm, func(i, j int) bool {
-		timeI, okI := parseBackupTimestamp(baksVm[i].CreatedAt)
-		timeJ, okJ := parseBackupTimestamp(baksVm[j].CreatedAt)
-
-		switch {
-		case okI && okJ:
-			return timeI.After(timeJ)
-		case okI:
-			return true
-		case okJ:
-			return false
-		default:
-			return baksVm[i].CreatedAt > baksVm[j].CreatedAt
-		}
-	})
-
-	// Delete oldest backups if we exceed MaxBackupsRetain
-	if len(baksVm) > bak.MaxBackupsRetain {
-		for i := bak.MaxBackupsRetain; i < len(baksVm); i++ {
-			err := v.DeleteBackup(ctx, baksVm[i].Id)
-			if err != nil {
-				logger.Errorf("failed to delete old backup %d: %v", baksVm[i].Id, err)
-				sendImportantNotification(fmt.Sprintf("createAutoBak: failed to delete old backup %d", baksVm[i].Id), err)
-			}
-		}
-	}
-
 	return nil
 }
 func (v *VirshService) LoopAutomaticBaks(ctx context.Context) {
@@ -811,7 +927,7 @@ func (v *VirshService) LoopAutomaticBaks(ctx context.Context) {
 				if bak.LastBackupTime == nil {
 					shouldBackup = true
 				} else {
-					lastBakTime, ok := parseBackupTimestamp(*bak.LastBackupTime)
+					lastBakTime, ok := db.ParseBackupTime(*bak.LastBackupTime)
 					if !ok {
 						logger.Errorf("Error parsing last backup time for VM %s: %s", bak.VmName, *bak.LastBackupTime)
 						continue
@@ -836,22 +952,6 @@ func (v *VirshService) LoopAutomaticBaks(ctx context.Context) {
 	}()
 }
 
-func parseBackupTimestamp(value string) (time.Time, bool) {
-	if value == "" {
-		return time.Time{}, false
-	}
-
-	if ts, err := time.Parse(time.RFC3339, value); err == nil {
-		return ts, true
-	}
-
-	if ts, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.UTC); err == nil {
-		return ts, true
-	}
-
-	return time.Time{}, false
-}
-
 func calculateWindowDuration(minClock, maxClock db.Clock) time.Duration {
 	minTime := minClock.GetTodayTime()
 	maxTime := maxClock.GetTodayTime()
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"512SvMan/protocol"
+	"512SvMan/virsh"
+	"fmt"
+	"strings"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"google.golang.org/grpc"
+)
+
+type CreateSnapshotParams struct {
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	DiskOnly      bool   `json:"disk_only"`
+	IncludeMemory bool   `json:"include_memory"`
+	Quiesce       bool   `json:"quiesce"`
+}
+
+func (v *VirshService) snapshotVmConnection(vmName string) (*grpcVirsh.Vm, *grpc.ClientConn, error) {
+	vm, err := v.GetVmByName(vmName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if vm == nil {
+		return nil, nil, fmt.Errorf("vm %s does not exist", vmName)
+	}
+
+	slave := protocol.GetConnectionByMachineName(vm.MachineName)
+	if slave == nil || slave.Connection == nil {
+		return nil, nil, fmt.Errorf("slave %s no connected", vm.MachineName)
+	}
+	return vm, slave.Connection, nil
+}
+
+func (v *VirshService) CreateSnapshot(vmName string, params CreateSnapshotParams) (*grpcVirsh.SnapshotInfo, error) {
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" {
+		return nil, fmt.Errorf("snapshot name is required")
+	}
+
+	vm, conn, err := v.snapshotVmConnection(vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	running := vm.State == grpcVirsh.VmState_RUNNING || vm.State == grpcVirsh.VmState_PAUSED
+	if params.IncludeMemory && !running {
+		return nil, fmt.Errorf("vm %s needs to be running to save its memory state", vmName)
+	}
+	if params.Quiesce && !running {
+		// nothing to freeze, the disks are already consistent
+		params.Quiesce = false
+	}
+
+	return virsh.CreateSnapshot(conn, &grpcVirsh.CreateSnapshotRequest{
+		VmName:        vmName,
+		Name:          params.Name,
+		Description:   params.Description,
+		DiskOnly:      params.DiskOnly,
+		IncludeMemory: params.IncludeMemory,
+		Quiesce:       params.Quiesce,
+	})
+}
+
+func (v *VirshService) ListSnapshots(vmName string) ([]*grpcVirsh.SnapshotInfo, error) {
+	_, conn, err := v.snapshotVmConnection(vmName)
+	if err != nil {
+		return nil, err
+	}
+	return virsh.ListSnapshots(conn, vmName)
+}
+
+func (v *VirshService) RevertSnapshot(vmName, snapshotName string) error {
+	snapshotName = strings.TrimSpace(snapshotName)
+	if snapshotName == "" {
+		return fmt.Errorf("snapshot name is required")
+	}
+
+	_, conn, err := v.snapshotVmConnection(vmName)
+	if err != nil {
+		return err
+	}
+	if err := virsh.RevertSnapshot(conn, vmName, snapshotName); err != nil {
+		return fmt.Errorf("failed to revert vm %s to snapshot %s: %v", vmName, snapshotName, err)
+	}
+	return nil
+}
+
+func (v *VirshService) DeleteSnapshot(vmName, snapshotName string) error {
+	snapshotName = strings.TrimSpace(snapshotName)
+	if snapshotName == "" {
+		return fmt.Errorf("snapshot name is required")
+	}
+
+	_, conn, err := v.snapshotVmConnection(vmName)
+	if err != nil {
+		return err
+	}
+	if err := virsh.DeleteSnapshot(conn, vmName, snapshotName); err != nil {
+		return fmt.Errorf("failed to delete snapshot %s of vm %s: %v", snapshotName, vmName, err)
+	}
+	return nil
+}
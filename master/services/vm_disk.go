@@ -7,6 +7,7 @@ import (
 
 	"512SvMan/db"
 	"512SvMan/nfs"
+	"512SvMan/nots"
 	"512SvMan/protocol"
 	"512SvMan/virsh"
 	vmdisk "512SvMan/vm_disk"
@@ -98,6 +99,71 @@ func (s *VMDiskService) Create(ctx context.Context, name string, nfsID int, size
 	return result, nil
 }
 
+type ImportVMDiskParams struct {
+	Name         string `json:"name"`
+	NFSID        int    `json:"nfs_id"`
+	URL          string `json:"url"`
+	Format       string `json:"format"`        // qcow2 (default) or raw
+	SourceFormat string `json:"source_format"` // optional, qcow2, raw, vmdk or vhdx
+	Checksum     string `json:"checksum"`      // optional, sha256:<hex> or sha512:<hex>
+}
+
+// ImportAsync downloads a disk image (cloud images, exported disks, ...) into the
+// share and registers it as a vm disk once the slave finished converting it.
+func (s *VMDiskService) ImportAsync(ctx context.Context, params ImportVMDiskParams) error {
+	params.Name = strings.TrimSpace(params.Name)
+	params.URL = strings.TrimSpace(params.URL)
+	if params.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if params.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if exists, err := db.DoesVMDiskNameExist(ctx, params.Name); err != nil {
+		return fmt.Errorf("failed to check VM disk name: %w", err)
+	} else if exists {
+		return fmt.Errorf("VM disk with name %s already exists", params.Name)
+	}
+
+	conn, target, err := s.readyNFSConnection(ctx, params.NFSID)
+	if err != nil {
+		return err
+	}
+
+	req := &vmDiskGrpc.ImportVMDiskRequest{
+		BasePath:     target,
+		Name:         params.Name,
+		Url:          params.URL,
+		Format:       params.Format,
+		SourceFormat: params.SourceFormat,
+		Checksum:     params.Checksum,
+	}
+
+	_, err = StartJob(JobTypeVMDiskImport, params.Name, 0, func(taskCtx context.Context, job *JobHandle) error {
+		job.Log("importing %s from %s", params.Name, params.URL)
+		res, err := vmdisk.ImportVMDisk(taskCtx, conn.Connection, req)
+		if err == nil && (res == nil || !res.GetOk()) {
+			err = fmt.Errorf("failed to import VM disk")
+		}
+		if err != nil {
+			nots.SendGlobalNotification("VM disk import failed", "Import of "+params.Name+" on "+conn.MachineName+" failed", err.Error(), true)
+			return err
+		}
+		if err := nfs.Sync(conn.Connection); err != nil {
+			nots.SendGlobalNotification("VM disk sync failed", "VM disk sync failed for "+params.Name+" on "+conn.MachineName, err.Error(), true)
+			return err
+		}
+		if _, err := db.AddVMDisk(taskCtx, params.Name, params.NFSID, res.GetDiskPath(), res.GetFolderPath(), res.GetFormat(), res.GetSizeGB()); err != nil {
+			_, _ = vmdisk.DeleteVMDisk(conn.Connection, &vmDiskGrpc.VMDiskByNameRequest{BasePath: target, Name: params.Name})
+			nots.SendGlobalNotification("VM disk register failed", "VM disk import finished but failed to save "+params.Name, err.Error(), true)
+			return err
+		}
+		nots.SendGlobalNotification("VM disk import done", "VM disk "+params.Name+" imported on "+conn.MachineName, "/", true)
+		return nil
+	})
+	return err
+}
+
 func (s *VMDiskService) Delete(ctx context.Context, id int) (*VMDiskResult, error) {
 	disk, err := db.GetVMDiskByID(ctx, id)
 	if err != nil {
@@ -107,6 +173,10 @@ func (s *VMDiskService) Delete(ctx context.Context, id int) (*VMDiskResult, erro
 		return nil, fmt.Errorf("VM disk with ID %d not found", id)
 	}
 
+	if _, err := checkGoldenDeletable(ctx, db.GoldenImageKindDisk, disk.Name); err != nil {
+		return nil, err
+	}
+
 	conn, target, err := s.readyNFSConnection(ctx, disk.NFSID)
 	if err != nil {
 		return nil, err
@@ -144,6 +214,9 @@ func (s *VMDiskService) Delete(ctx context.Context, id int) (*VMDiskResult, erro
 	if err := db.RemoveVMDiskByID(ctx, disk.Id); err != nil {
 		return nil, fmt.Errorf("failed to remove VM disk from database: %w", err)
 	}
+	if err := forgetGoldenRecords(ctx, db.GoldenImageKindDisk, disk.Name); err != nil {
+		return nil, err
+	}
 	return convertDBAndRPCVMDisk(disk, res), nil
 }
 
@@ -3,6 +3,8 @@ package virsh
 import (
 	"512SvMan/protocol"
 	"context"
+	"io"
+	"time"
 
 	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
 	"google.golang.org/grpc"
@@ -44,7 +46,7 @@ func GetVMXml(conn *grpc.ClientConn, vmName string) (string, error) {
 	return resp.VmXML, nil
 }
 
-func CreateVM(conn *grpc.ClientConn, name string, memory, vcpu int32, diskFolder, diskPath string, diskSizeGB int32, isoPath, network, VNCPassword string, cpuXML string, autoStart bool, isWindows bool) error {
+func CreateVM(conn *grpc.ClientConn, name string, memory, vcpu int32, diskFolder, diskPath string, diskSizeGB int32, isoPath, network, VNCPassword string, cpuXML string, autoStart bool, isWindows bool, cloudInit *grpcVirsh.CloudInitConfig) error {
 	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
 	_, err := client.CreateVm(context.Background(), &grpcVirsh.CreateVmRequest{
 		Name:        name,
@@ -58,6 +60,7 @@ func CreateVM(conn *grpc.ClientConn, name string, memory, vcpu int32, diskFolder
 		VncPassword: VNCPassword,
 		CpuXml:      cpuXML,
 		IsWindows:   isWindows,
+		CloudInit:   cloudInit,
 	})
 	if err != nil {
 		return err
@@ -65,19 +68,32 @@ func CreateVM(conn *grpc.ClientConn, name string, memory, vcpu int32, diskFolder
 	return nil
 }
 
-// conn machine will migrate do slaveIp machine
-func MigrateVm(ctx context.Context, conn *grpc.ClientConn, name, slaveIp string, live bool, timeoutSeconds int) error {
+// conn machine will migrate to req.SlaveIp, onProgress gets every update the
+// slave streams, the last one carries the final phase
+func MigrateVm(ctx context.Context, conn *grpc.ClientConn, req *grpcVirsh.MigrateVmRequest, onProgress func(*grpcVirsh.MigrationProgress)) error {
 	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
-	_, err := client.MigrateVM(ctx, &grpcVirsh.MigrateVmRequest{
-		Name:           name,
-		SlaveIp:        slaveIp,
-		Live:           live,
-		TimeoutSeconds: int32(timeoutSeconds),
-	})
+	stream, err := client.MigrateVM(ctx, req)
 	if err != nil {
 		return err
 	}
-	return nil
+	for {
+		progress, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+}
+
+func CancelMigration(ctx context.Context, conn *grpc.ClientConn, name string) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.CancelMigration(ctx, &grpcVirsh.CancelMigrationRequest{Name: name})
+	return err
 }
 
 func ColdMigrateVm(ctx context.Context, conn *grpc.ClientConn, machine *grpcVirsh.ColdMigrationRequest) error {
@@ -113,6 +129,19 @@ func UpdateVMXml(conn *grpc.ClientConn, vmName, vmXml string) error {
 	return nil
 }
 
+func DefineVMFromXML(ctx context.Context, conn *grpc.ClientConn, vmName, vmXml string, start bool) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.DefineVMFromXML(ctx, &grpcVirsh.DefineVMFromXMLRequest{
+		Name:  vmName,
+		VmXML: vmXml,
+		Start: start,
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func GetAllVms(conn *grpc.ClientConn, empty *grpcVirsh.Empty) (*grpcVirsh.GetAllVmsResponse, error) {
 	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
 	resp, err := client.GetAllVms(context.Background(), empty)
@@ -187,6 +216,15 @@ func UndefineVM(conn *grpc.ClientConn, req *grpcVirsh.Vm) error {
 	return nil
 }
 
+func UndefineStaleVM(conn *grpc.ClientConn, req *grpcVirsh.Vm) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.UndefineStaleVM(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func RestartVM(conn *grpc.ClientConn, req *grpcVirsh.Vm) error {
 	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
 	_, err := client.RestartVM(context.Background(), req)
@@ -556,3 +594,210 @@ func RemoveHostHugePagesGRPC(conn *grpc.ClientConn) (*grpcVirsh.HostHugePagesSta
 	}
 	return resp, nil
 }
+
+func CreateSnapshot(conn *grpc.ClientConn, req *grpcVirsh.CreateSnapshotRequest) (*grpcVirsh.SnapshotInfo, error) {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	resp, err := client.CreateSnapshot(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func ListSnapshots(conn *grpc.ClientConn, vmName string) ([]*grpcVirsh.SnapshotInfo, error) {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	resp, err := client.ListSnapshots(context.Background(), &grpcVirsh.GetVmByNameRequest{Name: vmName})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Snapshots, nil
+}
+
+func RevertSnapshot(conn *grpc.ClientConn, vmName, snapshotName string) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.RevertSnapshot(context.Background(), &grpcVirsh.SnapshotRequest{VmName: vmName, Name: snapshotName})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func DeleteSnapshot(conn *grpc.ClientConn, vmName, snapshotName string) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.DeleteSnapshot(context.Background(), &grpcVirsh.SnapshotRequest{VmName: vmName, Name: snapshotName})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func BackupDisk(ctx context.Context, conn *grpc.ClientConn, req *grpcVirsh.BackupDiskRequest) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.BackupDisk(ctx, req)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func FlattenBackupChain(ctx context.Context, conn *grpc.ClientConn, sourcePath, destPath string) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.FlattenBackupChain(ctx, &grpcVirsh.FlattenBackupRequest{SourcePath: sourcePath, DestPath: destPath})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CompressDiskImage rewrites a standalone qcow2 image with compressed clusters
+// on the slave behind conn.
+func CompressDiskImage(ctx context.Context, conn *grpc.ClientConn, sourcePath, destPath string) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.FlattenBackupChain(ctx, &grpcVirsh.FlattenBackupRequest{SourcePath: sourcePath, DestPath: destPath, Compress: true})
+	return err
+}
+
+// CheckDiskImage runs qemu-img check on path from the slave behind conn.
+func CheckDiskImage(ctx context.Context, conn *grpc.ClientConn, path string) (*grpcVirsh.CheckDiskImageResponse, error) {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	return client.CheckDiskImage(ctx, &grpcVirsh.CheckDiskImageRequest{Path: path})
+}
+
+func TestBootBackup(ctx context.Context, conn *grpc.ClientConn, req *grpcVirsh.TestBootRequest) (*grpcVirsh.TestBootResponse, error) {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	return client.TestBootBackup(ctx, req)
+}
+
+// scratchChunkSize is how much of an image goes in one WriteScratchImage
+// message, well under the default gRPC message limit.
+const scratchChunkSize = 256 * 1024
+
+// WriteScratchImage copies r into the local scratch folder of the slave behind
+// conn as name, the returned path only exists on that slave.
+func WriteScratchImage(ctx context.Context, conn *grpc.ClientConn, name string, r io.Reader) (*grpcVirsh.ScratchImage, error) {
+	// cancelling rather than closing the stream tells the slave the image is
+	// incomplete, it removes it then
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	stream, err := client.WriteScratchImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, scratchChunkSize)
+	chunk := &grpcVirsh.ScratchChunk{Name: name}
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 || chunk.Name != "" {
+			chunk.Data = buf[:n]
+			if sendErr := stream.Send(chunk); sendErr != nil {
+				if _, recvErr := stream.CloseAndRecv(); recvErr != nil {
+					return nil, recvErr
+				}
+				return nil, sendErr
+			}
+			chunk = &grpcVirsh.ScratchChunk{}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+func RemoveScratchImage(ctx context.Context, conn *grpc.ClientConn, name string) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.RemoveScratchImage(ctx, &grpcVirsh.ScratchImage{Name: name})
+	return err
+}
+
+// MountBackupImage attaches a backup image read-only on the slave behind conn
+// and lists its partitions.
+func MountBackupImage(ctx context.Context, conn *grpc.ClientConn, req *grpcVirsh.FileRestoreMountRequest) (*grpcVirsh.FileRestoreMountResponse, error) {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	return client.MountBackupImage(ctx, req)
+}
+
+func BrowseBackupImage(ctx context.Context, conn *grpc.ClientConn, req *grpcVirsh.FileRestoreBrowseRequest) (*grpcVirsh.FileRestoreBrowseResponse, error) {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	return client.BrowseBackupImage(ctx, req)
+}
+
+// ReadBackupImageFiles copies the file or tar the slave streams to w.
+func ReadBackupImageFiles(ctx context.Context, conn *grpc.ClientConn, req *grpcVirsh.FileRestoreReadRequest, w io.Writer) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	stream, err := client.ReadBackupImageFiles(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk.Data); err != nil {
+			return err
+		}
+	}
+}
+
+func UnmountBackupImage(ctx context.Context, conn *grpc.ClientConn, req *grpcVirsh.FileRestoreSession) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.UnmountBackupImage(ctx, req)
+	return err
+}
+
+// CleanupFileRestores closes every file restore session on the slave behind
+// conn and removes their scratch images.
+func CleanupFileRestores(ctx context.Context, conn *grpc.ClientConn) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.CleanupFileRestores(ctx, &grpcVirsh.Empty{})
+	return err
+}
+
+// BlockCopyDisk moves a disk of a running vm on conn, onProgress gets every
+// update the slave streams until the pivot.
+func BlockCopyDisk(ctx context.Context, conn *grpc.ClientConn, req *grpcVirsh.BlockCopyRequest, onProgress func(*grpcVirsh.BlockCopyProgress)) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	stream, err := client.BlockCopyDisk(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		progress, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+}
+
+func GuestAgentPing(ctx context.Context, conn *grpc.ClientConn, vmName string) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.GuestAgentPing(ctx, &grpcVirsh.GetVmByNameRequest{Name: vmName})
+	return err
+}
+
+// CheckTCPPort dials host:port from the slave behind conn.
+func CheckTCPPort(ctx context.Context, conn *grpc.ClientConn, host string, port int, timeout time.Duration) error {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	_, err := client.CheckTCPPort(ctx, &grpcVirsh.TCPPortRequest{Host: host, Port: int32(port), TimeoutMs: int32(timeout.Milliseconds())})
+	return err
+}
+
+// GetVmStats samples the usage of the running vms of the slave behind conn.
+func GetVmStats(ctx context.Context, conn *grpc.ClientConn) (*grpcVirsh.VmStatsResponse, error) {
+	client := grpcVirsh.NewSlaveVirshServiceClient(conn)
+	return client.GetVmStats(ctx, &grpcVirsh.Empty{})
+}
@@ -37,3 +37,24 @@ func GetVMDiskInfo(conn *grpc.ClientConn, req *pb.VMDiskByNameRequest) (*pb.VMDi
 	defer cancel()
 	return client.GetVMDiskInfo(ctx, req)
 }
+
+// ImportVMDisk downloads and converts an image on the slave, it can take a long
+// time so the caller's context decides when to give up.
+func ImportVMDisk(ctx context.Context, conn *grpc.ClientConn, req *pb.ImportVMDiskRequest) (*pb.VMDiskResponse, error) {
+	client := pb.NewVMDiskServiceClient(conn)
+	return client.ImportVMDisk(ctx, req)
+}
+
+func CreateOverlayDisk(conn *grpc.ClientConn, req *pb.CreateOverlayDiskRequest) (*pb.VMDiskResponse, error) {
+	client := pb.NewVMDiskServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultVMDiskTimeout)
+	defer cancel()
+	return client.CreateOverlayDisk(ctx, req)
+}
+
+// FlattenDisk copies the whole backing chain into the disk, no timeout as it
+// scales with the size of the base image.
+func FlattenDisk(ctx context.Context, conn *grpc.ClientConn, req *pb.VMDiskByPathRequest) (*pb.VMDiskResponse, error) {
+	client := pb.NewVMDiskServiceClient(conn)
+	return client.FlattenDisk(ctx, req)
+}
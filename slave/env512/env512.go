@@ -26,6 +26,8 @@ var (
 	DirtyRatioPercent           int
 	DirtyBackgroundRatioPercent int
 	K3sVersion                  string
+	JoinToken                   string // one time token to get a certificate from the master
+	CACertHash                  string // sha256:<hex> of the cluster CA, required to enroll, pins the master
 )
 
 func SetConn(conn *grpc.ClientConn) {
@@ -41,6 +43,8 @@ func Setup() error {
 	Qemu_UID = os.Getenv("QEMU_UID")
 	Qemu_GID = os.Getenv("QEMU_GID")
 	K3sVersion = os.Getenv("K3S_VERSION")
+	JoinToken = strings.TrimSpace(os.Getenv("JOIN_TOKEN"))
+	CACertHash = strings.TrimSpace(os.Getenv("CA_CERT_HASH"))
 
 	if extra := os.Getenv("EXTRA_K8S_IPS"); extra != "" {
 		for _, ip := range strings.Split(extra, ",") {
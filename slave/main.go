@@ -894,6 +894,17 @@ func main() {
 		return
 	}
 
+	// File restore sessions die with the connection of the master that opened them
+	if err := virsh.CleanupFileRestores(); err != nil {
+		logger.Warnf("Cleanup file restore sessions: %v", err)
+	}
+	if err := virsh.CleanupScratch(); err != nil {
+		logger.Warnf("Cleanup scratch images: %v", err)
+	}
+	if err := virsh.ResumeCloudInitSeedWatches(); err != nil {
+		logger.Warnf("Resume cloud-init seed watches: %v", err)
+	}
+
 	// Connect to gRPC server
 	conn := protocol.ConnectGRPC()
 	env512.SetConn(conn)
@@ -0,0 +1,80 @@
+package nfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slave/env512"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+// HeartbeatDir is the folder written on every mounted share, the master reads
+// it before restarting the VMs of a host it cannot reach. A host that still
+// writes here is alive and still owns its disks.
+const HeartbeatDir = ".512svman-ha"
+
+const (
+	heartbeatInterval = 10 * time.Second
+	heartbeatTimeout  = 5 * time.Second
+)
+
+var heartbeatInFlight sync.Map
+
+// WriteHeartbeats keeps a heartbeat file on each tracked mount, a hung mount
+// only skips its own heartbeat.
+func WriteHeartbeats() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		CurrentMountsLock.RLock()
+		mounts := append([]FolderMount(nil), CurrentMounts...)
+		CurrentMountsLock.RUnlock()
+
+		for _, mount := range mounts {
+			target := normalizeMountTarget(mount.Target)
+			if target == "" {
+				continue
+			}
+			if _, busy := heartbeatInFlight.LoadOrStore(target, struct{}{}); busy {
+				continue
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				defer heartbeatInFlight.Delete(target)
+				done <- writeHeartbeat(target)
+			}()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					logger.Debug("failed to write NFS heartbeat", "target", target, "error", err)
+				}
+			case <-time.After(heartbeatTimeout):
+				logger.Warn("NFS heartbeat write timed out", "target", target)
+			}
+		}
+	}
+}
+
+func writeHeartbeat(target string) error {
+	dir := filepath.Join(target, HeartbeatDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	name := strings.TrimSpace(env512.MachineName)
+	if name == "" {
+		return fmt.Errorf("machine name is empty")
+	}
+	path := filepath.Join(dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(time.Now().UTC().Format(time.RFC3339Nano)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -739,6 +739,7 @@ func InstallNFS() error {
 
 	logger.Info("NFS installed and nfs-server enabled")
 	go MonitorMounts()
+	go WriteHeartbeats()
 	return nil
 }
 
@@ -0,0 +1,356 @@
+package pki
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slave/env512"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/Maruqes/512SvMan/api/proto/protocol"
+	"github.com/Maruqes/512SvMan/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// MasterName is the name on the master certificate, it must match the master.
+const MasterName = "512svman-master"
+
+const (
+	pkiDir       = "pki"
+	nodeCertFile = "node.crt"
+	nodeKeyFile  = "node.key"
+	caCertFile   = "ca.crt"
+	enrollPort   = "50053"
+	renewBefore  = 30 * 24 * time.Hour
+)
+
+var (
+	mu       sync.RWMutex
+	nodeCert *tls.Certificate
+	caPool   *x509.CertPool
+)
+
+// Load reads the node certificate from ./pki, os.ErrNotExist means the slave
+// has not enrolled yet.
+func Load() error {
+	certPEM, err := os.ReadFile(filepath.Join(pkiDir, nodeCertFile))
+	if err != nil {
+		return err
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(pkiDir, nodeKeyFile))
+	if err != nil {
+		return err
+	}
+	caPEM, err := os.ReadFile(filepath.Join(pkiDir, caCertFile))
+	if err != nil {
+		return err
+	}
+	return install(certPEM, keyPEM, caPEM)
+}
+
+func Enrolled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return nodeCert != nil
+}
+
+func install(certPEM, keyPEM, caPEM []byte) error {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("load node certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("parse node certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("cluster CA is not a PEM certificate")
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return fmt.Errorf("node certificate is not signed by the cluster CA: %w", err)
+	}
+	if leaf.Subject.CommonName != env512.MachineName {
+		return fmt.Errorf("node certificate is for %s, this machine is %s", leaf.Subject.CommonName, env512.MachineName)
+	}
+	pair.Leaf = leaf
+
+	mu.Lock()
+	nodeCert = &pair
+	caPool = pool
+	mu.Unlock()
+	return nil
+}
+
+func save(certPEM, keyPEM, caPEM []byte) error {
+	if err := os.MkdirAll(pkiDir, 0700); err != nil {
+		return fmt.Errorf("create pki directory: %w", err)
+	}
+	files := []struct {
+		name string
+		data []byte
+		perm os.FileMode
+	}{
+		{nodeKeyFile, keyPEM, 0600},
+		{nodeCertFile, certPEM, 0644},
+		{caCertFile, caPEM, 0644},
+	}
+	for _, f := range files {
+		path := filepath.Join(pkiDir, f.name)
+		// write next to the old file first so a crash never leaves half a pair
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, f.data, f.perm); err != nil {
+			return fmt.Errorf("write %s: %w", tmp, err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return fmt.Errorf("replace %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func newKeyAndCSR() (keyPEM, csrPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate node key: %w", err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: env512.MachineName},
+		DNSNames: []string{env512.MachineName},
+	}, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create csr: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal node key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}), nil
+}
+
+func certFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func caFingerprint(caPEM []byte) (string, error) {
+	block, _ := pem.Decode(caPEM)
+	if block == nil {
+		return "", fmt.Errorf("cluster CA is not a PEM certificate")
+	}
+	return certFingerprint(block.Bytes), nil
+}
+
+// Enroll trades JOIN_TOKEN for a node certificate. The master is not trusted
+// yet, so the handshake only completes when it presents the CA pinned by
+// CA_CERT_HASH and a certificate signed by it, the token is never sent to
+// anything else.
+func Enroll(ctx context.Context) error {
+	if env512.JoinToken == "" {
+		return fmt.Errorf("no node certificate in %s and JOIN_TOKEN is not set, create a join token on the master", pkiDir)
+	}
+	if env512.CACertHash == "" {
+		return fmt.Errorf("CA_CERT_HASH is not set, copy the ca_cert_hash shown with the join token on the master")
+	}
+	keyPEM, csrPEM, err := newKeyAndCSR()
+	if err != nil {
+		return err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		// the system roots don't know the cluster CA, the chain is checked against the pin instead
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyPinnedMaster(rawCerts, env512.CACertHash)
+		},
+	}
+
+	conn, err := grpc.NewClient(fmt.Sprintf("%s:%s", env512.MasterIP, enrollPort),
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		return fmt.Errorf("dial master enrollment: %w", err)
+	}
+	defer conn.Close()
+
+	resp, err := pb.NewEnrollServiceClient(conn).Enroll(ctx, &pb.EnrollRequest{
+		Token:       env512.JoinToken,
+		MachineName: env512.MachineName,
+		Addr:        env512.SlaveIP,
+		Csr:         csrPEM,
+	})
+	if err != nil {
+		return fmt.Errorf("enroll: %w", err)
+	}
+
+	caPEM := resp.GetCaCertificate()
+	fingerprint, err := caFingerprint(caPEM)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(env512.CACertHash, fingerprint) {
+		return fmt.Errorf("cluster CA %s does not match CA_CERT_HASH %s", fingerprint, env512.CACertHash)
+	}
+
+	if err := install(resp.GetCertificate(), keyPEM, caPEM); err != nil {
+		return err
+	}
+	if err := save(resp.GetCertificate(), keyPEM, caPEM); err != nil {
+		return err
+	}
+	logger.Info("enrolled with master", "machine", env512.MachineName, "ca", fingerprint)
+	return nil
+}
+
+// verifyPinnedMaster looks for the pinned CA in the chain the master sent and
+// checks the master certificate against it.
+func verifyPinnedMaster(rawCerts [][]byte, pin string) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("master sent no certificate")
+	}
+	chain := make([]*x509.Certificate, 0, len(rawCerts))
+	var ca *x509.Certificate
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("parse master certificate: %w", err)
+		}
+		chain = append(chain, cert)
+		if strings.EqualFold(certFingerprint(raw), pin) {
+			ca = cert
+		}
+	}
+	if ca == nil {
+		return fmt.Errorf("master did not present the cluster CA %s", pin)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		DNSName:       MasterName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		return fmt.Errorf("master certificate is not signed by the cluster CA: %w", err)
+	}
+	return nil
+}
+
+// Renew gets a new key pair and certificate through the mTLS connection to the
+// master. New handshakes use it right away, open connections keep the old one.
+func Renew(ctx context.Context, conn *grpc.ClientConn) (*x509.Certificate, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("not connected to master")
+	}
+	keyPEM, csrPEM, err := newKeyAndCSR()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := pb.NewProtocolServiceClient(conn).RenewCertificate(ctx, &pb.RenewCertificateRequest{Csr: csrPEM})
+	if err != nil {
+		return nil, fmt.Errorf("renew certificate: %w", err)
+	}
+	if err := install(resp.GetCertificate(), keyPEM, resp.GetCaCertificate()); err != nil {
+		return nil, err
+	}
+	if err := save(resp.GetCertificate(), keyPEM, resp.GetCaCertificate()); err != nil {
+		return nil, err
+	}
+	leaf := Certificate()
+	logger.Info("node certificate renewed", "serial", hex.EncodeToString(leaf.SerialNumber.Bytes()), "notAfter", leaf.NotAfter.Format(time.RFC3339))
+	return leaf, nil
+}
+
+// MaintainCertificate renews the node certificate before it expires.
+func MaintainCertificate(conn *grpc.ClientConn) {
+	ticker := time.NewTicker(12 * time.Hour)
+	defer ticker.Stop()
+	for {
+		if leaf := Certificate(); leaf != nil && time.Until(leaf.NotAfter) < renewBefore {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			if _, err := Renew(ctx, conn); err != nil {
+				logger.Error("failed to renew node certificate", "error", err)
+			}
+			cancel()
+		}
+		<-ticker.C
+	}
+}
+
+func Certificate() *x509.Certificate {
+	mu.RLock()
+	defer mu.RUnlock()
+	if nodeCert == nil {
+		return nil
+	}
+	return nodeCert.Leaf
+}
+
+func getNodeCertificate() (*tls.Certificate, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if nodeCert == nil {
+		return nil, errors.New("node is not enrolled")
+	}
+	return nodeCert, nil
+}
+
+func pool() *x509.CertPool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return caPool
+}
+
+// ServerTLSConfig is used by the slave gRPC server, only the master may call it.
+func ServerTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool(),
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return getNodeCertificate()
+		},
+		VerifyPeerCertificate: func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+				return fmt.Errorf("no verified certificate chain")
+			}
+			return verifiedChains[0][0].VerifyHostname(MasterName)
+		},
+	}
+}
+
+// ClientTLSConfig is used to dial the master.
+func ClientTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		RootCAs:    pool(),
+		ServerName: MasterName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return getNodeCertificate()
+		},
+	}
+}
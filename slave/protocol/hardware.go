@@ -0,0 +1,40 @@
+package protocol
+
+import (
+	"strings"
+
+	pb "github.com/Maruqes/512SvMan/api/proto/protocol"
+	"github.com/Maruqes/512SvMan/logger"
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/host"
+	"github.com/shirou/gopsutil/v4/mem"
+)
+
+// nodeHardware is what the admin sees before approving this slave, a field that
+// can't be read is left empty.
+func nodeHardware() *pb.NodeHardware {
+	hw := &pb.NodeHardware{}
+
+	if h, err := host.Info(); err != nil {
+		logger.Warn("read host info", "error", err)
+	} else {
+		hw.Hostname = h.Hostname
+		hw.Os = strings.TrimSpace(h.Platform + " " + h.PlatformVersion)
+		hw.Kernel = h.KernelVersion
+	}
+	if infos, err := cpu.Info(); err != nil {
+		logger.Warn("read cpu info", "error", err)
+	} else if len(infos) > 0 {
+		hw.CpuModel = infos[0].ModelName
+	}
+	if n, err := cpu.Counts(false); err == nil {
+		hw.CpuCores = int32(n)
+	}
+	if n, err := cpu.Counts(true); err == nil {
+		hw.CpuThreads = int32(n)
+	}
+	if v, err := mem.VirtualMemory(); err == nil {
+		hw.MemoryMB = int64(v.Total / 1024 / 1024)
+	}
+	return hw
+}
@@ -2,6 +2,8 @@ package protocol
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -14,6 +16,7 @@ import (
 	nfsservice "slave/nfs"
 	ourk8s "slave/our_k8s"
 	pciservice "slave/pci"
+	"slave/pki"
 	smartdisk "slave/smartdisk"
 	"slave/virsh"
 	vmdiskservice "slave/vm_disk"
@@ -35,9 +38,11 @@ import (
 
 	"github.com/Maruqes/512SvMan/logger"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 )
 
 func restartSelf() error {
@@ -87,6 +92,17 @@ func (s *clientServer) Notify(ctx context.Context, req *pb.NotifyRequest) (*pb.N
 	return &pb.NotifyResponse{Ok: "OK do Cliente"}, nil
 }
 
+func (s *clientServer) RotateCertificate(ctx context.Context, req *pb.RotateCertificateRequest) (*pb.RotateCertificateResponse, error) {
+	cert, err := pki.Renew(ctx, env512.Conn)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RotateCertificateResponse{
+		Serial:   hex.EncodeToString(cert.SerialNumber.Bytes()),
+		NotAfter: cert.NotAfter.UTC().Format(time.RFC3339),
+	}, nil
+}
+
 func listenGRPC() {
 	for {
 		lis, err := net.Listen("tcp", ":50052")
@@ -110,6 +126,7 @@ func listenGRPC() {
 		}
 
 		s := grpc.NewServer(
+			grpc.Creds(credentials.NewTLS(pki.ServerTLSConfig())),
 			grpc.KeepaliveEnforcementPolicy(enf),
 			grpc.KeepaliveParams(srvParams),
 		)
@@ -183,15 +200,23 @@ func PingMaster(conn *grpc.ClientConn) {
 	}
 }
 
+// loadOrEnroll loads the node certificate, enrolling with JOIN_TOKEN the first time.
+func loadOrEnroll() error {
+	err := pki.Load()
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	return pki.Enroll(ctx)
+}
+
 var startSlaveServerOnce sync.Once
 var restartSlaveOnce sync.Once
 
 func ConnectGRPC() *grpc.ClientConn {
 
 	target := fmt.Sprintf("%s:50051", env512.MasterIP)
-	startSlaveServerOnce.Do(func() {
-		go listenGRPC()
-	})
 
 	const (
 		minRetryDelay = 5 * time.Second
@@ -200,6 +225,23 @@ func ConnectGRPC() *grpc.ClientConn {
 	retryDelay := minRetryDelay
 
 	for {
+		if !pki.Enrolled() {
+			if err := loadOrEnroll(); err != nil {
+				logger.Error("failed to get a node certificate", "error", err)
+				time.Sleep(retryDelay)
+				if retryDelay < maxRetryDelay {
+					retryDelay *= 2
+					if retryDelay > maxRetryDelay {
+						retryDelay = maxRetryDelay
+					}
+				}
+				continue
+			}
+		}
+		startSlaveServerOnce.Do(func() {
+			go listenGRPC()
+		})
+
 		logger.Info("connecting to master", "target", target)
 		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 
@@ -210,7 +252,7 @@ func ConnectGRPC() *grpc.ClientConn {
 		}
 
 		conn, err := grpc.DialContext(ctx, target,
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithTransportCredentials(credentials.NewTLS(pki.ClientTLSConfig())),
 			grpc.WithBlock(),
 			grpc.WithKeepaliveParams(ka),
 			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(50*1024*1024), grpc.MaxCallSendMsgSize(50*1024*1024)),
@@ -228,13 +270,20 @@ func ConnectGRPC() *grpc.ClientConn {
 			continue
 		}
 
-		logs512.StartLogs(conn)
 		h := pb.NewProtocolServiceClient(conn)
 		reqCtx, reqCancel := context.WithTimeout(context.Background(), 300*time.Second)
-		outR, err := h.SetConnection(reqCtx, &pb.SetConnectionRequest{Addr: env512.SlaveIP, MachineName: env512.MachineName})
+		outR, err := h.SetConnection(reqCtx, &pb.SetConnectionRequest{
+			Addr:        env512.SlaveIP,
+			MachineName: env512.MachineName,
+			Hardware:    nodeHardware(),
+		})
 		reqCancel()
 		if err != nil {
-			logger.Error("SetConnection request failed", "error", err)
+			if status.Code(err) == codes.FailedPrecondition {
+				logger.Info("waiting for an admin to approve this slave on the master", "machine", env512.MachineName)
+			} else {
+				logger.Error("SetConnection request failed", "error", err)
+			}
 			conn.Close()
 			time.Sleep(retryDelay)
 			if retryDelay < maxRetryDelay {
@@ -247,9 +296,12 @@ func ConnectGRPC() *grpc.ClientConn {
 		}
 
 		retryDelay = minRetryDelay
+		// logs are only accepted from approved slaves
+		logs512.StartLogs(conn)
 		logger.Info("master acknowledged slave", "message", outR.GetOk())
 		go monitorConnection(conn)
 		go PingMaster(conn)
+		go pki.MaintainCertificate(conn)
 		return conn
 	}
 }
@@ -0,0 +1,337 @@
+package virsh
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"slave/extra"
+	"strings"
+	"time"
+
+	extraGrpc "github.com/Maruqes/512SvMan/api/proto/extra"
+	"github.com/Maruqes/512SvMan/logger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	libvirt "libvirt.org/go/libvirt"
+)
+
+type DiskBackupOptions struct {
+	VmName           string
+	TargetPath       string
+	Checkpoint       string
+	ParentCheckpoint string // empty means full backup
+	ParentPath       string
+}
+
+type backupDomainXML struct {
+	XMLName     xml.Name          `xml:"domainbackup"`
+	Mode        string            `xml:"mode,attr"`
+	Incremental string            `xml:"incremental,omitempty"`
+	Disks       backupDiskListXML `xml:"disks"`
+}
+
+type backupDiskListXML struct {
+	Disks []backupDiskXML `xml:"disk"`
+}
+
+type backupDiskXML struct {
+	Name   string             `xml:"name,attr"`
+	Backup string             `xml:"backup,attr"`
+	Type   string             `xml:"type,attr,omitempty"`
+	Target *snapshotSourceXML `xml:"target,omitempty"`
+	Driver *snapshotDriverXML `xml:"driver,omitempty"`
+}
+
+type checkpointDomainXML struct {
+	XMLName xml.Name              `xml:"domaincheckpoint"`
+	Name    string                `xml:"name"`
+	Disks   checkpointDiskListXML `xml:"disks"`
+}
+
+type checkpointDiskListXML struct {
+	Disks []checkpointDiskXML `xml:"disk"`
+}
+
+type checkpointDiskXML struct {
+	Name       string `xml:"name,attr"`
+	Checkpoint string `xml:"checkpoint,attr"`
+}
+
+// only the primary disk is backed up (same disk BackupVM always copied), the
+// rest are excluded from both the backup job and the checkpoint bitmap
+func buildBackupXML(opts DiskBackupOptions, disks []snapshotTargetDisk, primary string) (string, string, error) {
+	backup := backupDomainXML{Mode: "push", Incremental: opts.ParentCheckpoint}
+	checkpoint := checkpointDomainXML{Name: opts.Checkpoint}
+
+	found := false
+	for _, disk := range disks {
+		if disk.File != primary {
+			backup.Disks.Disks = append(backup.Disks.Disks, backupDiskXML{Name: disk.Target, Backup: "no"})
+			checkpoint.Disks.Disks = append(checkpoint.Disks.Disks, checkpointDiskXML{Name: disk.Target, Checkpoint: "no"})
+			continue
+		}
+		found = true
+		backup.Disks.Disks = append(backup.Disks.Disks, backupDiskXML{
+			Name:   disk.Target,
+			Backup: "yes",
+			Type:   "file",
+			Target: &snapshotSourceXML{File: opts.TargetPath},
+			Driver: &snapshotDriverXML{Type: "qcow2"},
+		})
+		checkpoint.Disks.Disks = append(checkpoint.Disks.Disks, checkpointDiskXML{Name: disk.Target, Checkpoint: "bitmap"})
+	}
+	if !found {
+		return "", "", fmt.Errorf("primary disk %s not found in domain", primary)
+	}
+
+	backupOut, err := xml.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+	checkpointOut, err := xml.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+	return string(backupOut), string(checkpointOut), nil
+}
+
+// deleteAllCheckpoints drops every checkpoint (and its bitmap) of dom
+func deleteAllCheckpoints(dom *libvirt.Domain) error {
+	checkpoints, err := dom.ListAllCheckpoints(libvirt.DOMAIN_CHECKPOINT_LIST_ROOTS)
+	if err != nil {
+		return fmt.Errorf("list checkpoints: %w", err)
+	}
+	for i := range checkpoints {
+		name, _ := checkpoints[i].GetName()
+		err := checkpoints[i].Delete(libvirt.DOMAIN_CHECKPOINT_DELETE_CHILDREN)
+		checkpoints[i].Free()
+		if err != nil {
+			return fmt.Errorf("delete checkpoint %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// BackupDisk runs a push mode libvirt backup of the primary disk and records a
+// checkpoint so the next backup only has to copy the blocks written since.
+// Incremental targets are created with the previous backup as backing file so
+// the chain can be flattened later with qemu-img.
+func BackupDisk(ctx context.Context, opts DiskBackupOptions) error {
+	opts.VmName = strings.TrimSpace(opts.VmName)
+	opts.TargetPath = strings.TrimSpace(opts.TargetPath)
+	opts.Checkpoint = strings.TrimSpace(opts.Checkpoint)
+	if opts.VmName == "" {
+		return fmt.Errorf("vm name is empty")
+	}
+	if opts.TargetPath == "" {
+		return fmt.Errorf("target path is empty")
+	}
+	if err := validateSnapshotName(opts.Checkpoint); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if opts.ParentCheckpoint != "" && opts.ParentPath == "" {
+		return fmt.Errorf("incremental backup needs the parent backup path")
+	}
+	if err := ensureParentDirExists(opts.TargetPath); err != nil {
+		return err
+	}
+
+	conn, err := libvirt.NewConnect("qemu:///system")
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	dom, err := conn.LookupDomainByName(opts.VmName)
+	if err != nil {
+		return fmt.Errorf("lookup: %w", err)
+	}
+	defer dom.Free()
+
+	state, _, err := dom.GetState()
+	if err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	if state != libvirt.DOMAIN_RUNNING && state != libvirt.DOMAIN_PAUSED && state != libvirt.DOMAIN_BLOCKED {
+		return status.Errorf(codes.FailedPrecondition, "domain %s is not running", opts.VmName)
+	}
+
+	if opts.ParentCheckpoint != "" {
+		parent, err := dom.CheckpointLookupByName(opts.ParentCheckpoint, 0)
+		if err != nil {
+			// checkpoint metadata is per host, it is gone after a migration or undefine
+			return status.Errorf(codes.FailedPrecondition, "parent checkpoint %s not found on %s: %v", opts.ParentCheckpoint, opts.VmName, err)
+		}
+		parent.Free()
+		if _, err := os.Stat(opts.ParentPath); err != nil {
+			return status.Errorf(codes.FailedPrecondition, "parent backup %s: %v", opts.ParentPath, err)
+		}
+	}
+
+	xmlDesc, err := dom.GetXMLDesc(0)
+	if err != nil {
+		return fmt.Errorf("get xml: %w", err)
+	}
+	disks, err := snapshotDisks(xmlDesc)
+	if err != nil {
+		return err
+	}
+	primary, err := GetPrimaryDiskInfo(dom)
+	if err != nil {
+		return fmt.Errorf("primary disk: %w", err)
+	}
+
+	backupXML, checkpointXML, err := buildBackupXML(opts, disks, primary.Path)
+	if err != nil {
+		return err
+	}
+
+	var flags libvirt.DomainBackupBeginFlags
+	if opts.ParentCheckpoint != "" {
+		cmd := exec.Command("qemu-img", "create", "-f", "qcow2", "-b", opts.ParentPath, "-F", "qcow2", opts.TargetPath)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("qemu-img create %s: %s", opts.TargetPath, strings.TrimSpace(string(out)))
+		}
+		if err := ensureDiskPermissions(opts.TargetPath); err != nil {
+			return err
+		}
+		flags |= libvirt.DOMAIN_BACKUP_BEGIN_REUSE_EXTERNAL
+	}
+
+	// the backup is point in time from BackupBegin, so the guest only has to stay frozen while it starts
+	// (a paused guest can't run the agent and isn't writing anyway)
+	freeze := state != libvirt.DOMAIN_PAUSED
+	if freeze {
+		if err := FreezeDisk(opts.VmName); err != nil {
+			_ = os.Remove(opts.TargetPath)
+			return err
+		}
+	}
+	beginErr := dom.BackupBegin(backupXML, checkpointXML, flags)
+	if freeze {
+		if err := UnFreezeDisk(opts.VmName); err != nil {
+			logger.Errorf("backup %s: cannot unfreeze: %v", opts.VmName, err)
+		}
+	}
+	if beginErr != nil {
+		_ = os.Remove(opts.TargetPath)
+		return fmt.Errorf("backup begin: %w", beginErr)
+	}
+
+	if err := waitBackupJob(ctx, dom, opts.VmName); err != nil {
+		_ = os.Remove(opts.TargetPath)
+		if chk, lookupErr := dom.CheckpointLookupByName(opts.Checkpoint, 0); lookupErr == nil {
+			_ = chk.Delete(0)
+			chk.Free()
+		}
+		return err
+	}
+
+	if err := ensureDiskPermissions(opts.TargetPath); err != nil {
+		return err
+	}
+
+	// keep a single checkpoint per vm, the new one already tracks every write after this backup.
+	// Only dropped now so a failed backup leaves the previous chain usable.
+	if err := deleteCheckpointsExcept(dom, opts.Checkpoint); err != nil {
+		logger.Errorf("backup %s: cannot delete old checkpoints: %v", opts.VmName, err)
+	}
+	return nil
+}
+
+// deleteCheckpointsExcept removes every checkpoint of dom but keep.
+func deleteCheckpointsExcept(dom *libvirt.Domain, keep string) error {
+	checkpoints, err := dom.ListAllCheckpoints(0)
+	if err != nil {
+		return fmt.Errorf("list checkpoints: %w", err)
+	}
+	var firstErr error
+	for i := range checkpoints {
+		name, _ := checkpoints[i].GetName()
+		if name != keep {
+			if err := checkpoints[i].Delete(0); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("delete checkpoint %s: %w", name, err)
+			}
+		}
+		checkpoints[i].Free()
+	}
+	return firstErr
+}
+
+func waitBackupJob(ctx context.Context, dom *libvirt.Domain, vmName string) error {
+	identifier := fmt.Sprintf("%s-%d", vmName, time.Now().Unix())
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := dom.AbortJob(); err != nil {
+				logger.Errorf("backup %s: abort job: %v", vmName, err)
+			}
+			return fmt.Errorf("backup canceled: %w", ctx.Err())
+		case <-ticker.C:
+		}
+
+		info, err := dom.GetJobInfo()
+		if err != nil {
+			return fmt.Errorf("backup job info: %w", err)
+		}
+		if info.Type == libvirt.DOMAIN_JOB_NONE {
+			break
+		}
+		if info.DataTotal > 0 {
+			pct := float64(info.DataProcessed) / float64(info.DataTotal) * 100
+			extra.SendWebsocketMessage(fmt.Sprintf("Backup progress for %s: %.2f%%", vmName, pct), identifier, extraGrpc.WebSocketsMessageType_BackUpVM)
+		}
+	}
+
+	stats, err := dom.GetJobStats(libvirt.DOMAIN_JOB_STATS_COMPLETED)
+	if err != nil {
+		return fmt.Errorf("backup job stats: %w", err)
+	}
+	if stats.Type != libvirt.DOMAIN_JOB_COMPLETED {
+		msg := "unknown error"
+		if stats.ErrorMessageSet {
+			msg = stats.ErrorMessage
+		}
+		return fmt.Errorf("backup job for %s did not complete: %s", vmName, msg)
+	}
+	return nil
+}
+
+// FlattenBackupChain merges an incremental backup and all its backing files into
+// a standalone qcow2 image, with compress its clusters are zlib compressed.
+func FlattenBackupChain(source, dest string, compress bool) error {
+	source = strings.TrimSpace(source)
+	dest = strings.TrimSpace(dest)
+	if source == "" || dest == "" {
+		return fmt.Errorf("source and destination are required")
+	}
+	if _, err := os.Stat(source); err != nil {
+		return fmt.Errorf("stat %s: %w", source, err)
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("destination %s already exists", dest)
+	}
+	if err := ensureParentDirExists(dest); err != nil {
+		return err
+	}
+
+	args := []string{"convert", "-O", "qcow2"}
+	if compress {
+		args = append(args, "-c")
+	}
+	cmd := exec.Command("qemu-img", append(args, source, dest)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		_ = os.Remove(dest)
+		msg := strings.TrimSpace(string(out))
+		if msg != "" {
+			return fmt.Errorf("qemu-img convert %s: %s", source, msg)
+		}
+		return fmt.Errorf("qemu-img convert %s: %w", source, err)
+	}
+	return ensureDiskPermissions(dest)
+}
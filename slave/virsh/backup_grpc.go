@@ -0,0 +1,98 @@
+package virsh
+
+import (
+	"context"
+	"errors"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+func (s *SlaveVirshService) BackupDisk(ctx context.Context, req *grpcVirsh.BackupDiskRequest) (*grpcVirsh.OkResponse, error) {
+	err := BackupDisk(ctx, DiskBackupOptions{
+		VmName:           req.VmName,
+		TargetPath:       req.TargetPath,
+		Checkpoint:       req.Checkpoint,
+		ParentCheckpoint: req.ParentCheckpoint,
+		ParentPath:       req.ParentPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.OkResponse{Ok: true}, nil
+}
+
+func (s *SlaveVirshService) FlattenBackupChain(ctx context.Context, req *grpcVirsh.FlattenBackupRequest) (*grpcVirsh.OkResponse, error) {
+	if err := FlattenBackupChain(req.SourcePath, req.DestPath, req.Compress); err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.OkResponse{Ok: true}, nil
+}
+
+func (s *SlaveVirshService) CheckDiskImage(ctx context.Context, req *grpcVirsh.CheckDiskImageRequest) (*grpcVirsh.CheckDiskImageResponse, error) {
+	return CheckDiskImage(req.Path)
+}
+
+func (s *SlaveVirshService) TestBootBackup(ctx context.Context, req *grpcVirsh.TestBootRequest) (*grpcVirsh.TestBootResponse, error) {
+	return TestBootBackup(req)
+}
+
+func (s *SlaveVirshService) WriteScratchImage(stream grpcVirsh.SlaveVirshService_WriteScratchImageServer) error {
+	image, err := WriteScratchImage(stream.Recv)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(image)
+}
+
+func (s *SlaveVirshService) RemoveScratchImage(ctx context.Context, req *grpcVirsh.ScratchImage) (*grpcVirsh.OkResponse, error) {
+	if err := RemoveScratchImage(req.Name); err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.OkResponse{Ok: true}, nil
+}
+
+func (s *SlaveVirshService) BlockCopyDisk(req *grpcVirsh.BlockCopyRequest, stream grpcVirsh.SlaveVirshService_BlockCopyDiskServer) error {
+	opts := BlockCopyOptions{
+		VmName:          req.VmName,
+		SourcePath:      req.SourcePath,
+		DestPath:        req.DestPath,
+		Format:          req.Format,
+		MaxBandwidthMiB: req.MaxBandwidthMib,
+		RemoveSource:    req.RemoveSource,
+	}
+	return BlockCopyDisk(stream.Context(), opts, func(progress *grpcVirsh.BlockCopyProgress) {
+		if err := stream.Send(progress); err != nil {
+			logger.Error("send block copy progress: %v", err)
+		}
+	})
+}
+
+func (s *SlaveVirshService) MountBackupImage(ctx context.Context, req *grpcVirsh.FileRestoreMountRequest) (*grpcVirsh.FileRestoreMountResponse, error) {
+	return MountBackupImage(ctx, req)
+}
+
+func (s *SlaveVirshService) BrowseBackupImage(ctx context.Context, req *grpcVirsh.FileRestoreBrowseRequest) (*grpcVirsh.FileRestoreBrowseResponse, error) {
+	return BrowseBackupImage(ctx, req)
+}
+
+func (s *SlaveVirshService) ReadBackupImageFiles(req *grpcVirsh.FileRestoreReadRequest, stream grpcVirsh.SlaveVirshService_ReadBackupImageFilesServer) error {
+	return ReadBackupImageFiles(stream.Context(), req, stream.Send)
+}
+
+func (s *SlaveVirshService) UnmountBackupImage(ctx context.Context, req *grpcVirsh.FileRestoreSession) (*grpcVirsh.OkResponse, error) {
+	if err := UnmountBackupImage(ctx, req); err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.OkResponse{Ok: true}, nil
+}
+
+// CleanupFileRestores closes every session, a master that just started has
+// none and can't unmount the ones its previous run left.
+func (s *SlaveVirshService) CleanupFileRestores(ctx context.Context, req *grpcVirsh.Empty) (*grpcVirsh.OkResponse, error) {
+	err := errors.Join(CleanupFileRestores(), RemoveScratchImages(fileRestoreScratchPrefix))
+	if err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.OkResponse{Ok: true}, nil
+}
@@ -0,0 +1,59 @@
+package virsh
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildBackupXMLOnlyBacksUpPrimaryDisk(t *testing.T) {
+	disks := []snapshotTargetDisk{
+		{Target: "vda", File: "/mnt/share/web/web.qcow2"},
+		{Target: "vdb", File: "/mnt/share/web/data.qcow2"},
+	}
+	opts := DiskBackupOptions{
+		VmName:           "web",
+		TargetPath:       "/mnt/bak/backup-1/web.qcow2",
+		Checkpoint:       "bak-2",
+		ParentCheckpoint: "bak-1",
+		ParentPath:       "/mnt/bak/backup-0/web.qcow2",
+	}
+
+	backupXML, checkpointXML, err := buildBackupXML(opts, disks, "/mnt/share/web/web.qcow2")
+	if err != nil {
+		t.Fatalf("buildBackupXML returned error: %v", err)
+	}
+
+	if !strings.Contains(backupXML, `<incremental>bak-1</incremental>`) {
+		t.Fatalf("expected incremental parent, got:\n%s", backupXML)
+	}
+	if !strings.Contains(backupXML, `<disk name="vda" backup="yes" type="file">`) {
+		t.Fatalf("expected vda to be backed up, got:\n%s", backupXML)
+	}
+	if !strings.Contains(backupXML, `<disk name="vdb" backup="no">`) {
+		t.Fatalf("expected vdb to be skipped, got:\n%s", backupXML)
+	}
+	if !strings.Contains(checkpointXML, `<disk name="vda" checkpoint="bitmap"></disk>`) {
+		t.Fatalf("expected bitmap checkpoint on vda, got:\n%s", checkpointXML)
+	}
+	if !strings.Contains(checkpointXML, `<disk name="vdb" checkpoint="no"></disk>`) {
+		t.Fatalf("expected no checkpoint on vdb, got:\n%s", checkpointXML)
+	}
+}
+
+func TestBuildBackupXMLFullHasNoIncremental(t *testing.T) {
+	disks := []snapshotTargetDisk{{Target: "vda", File: "/mnt/share/web/web.qcow2"}}
+	backupXML, _, err := buildBackupXML(DiskBackupOptions{TargetPath: "/mnt/bak/web.qcow2", Checkpoint: "bak-1"}, disks, "/mnt/share/web/web.qcow2")
+	if err != nil {
+		t.Fatalf("buildBackupXML returned error: %v", err)
+	}
+	if strings.Contains(backupXML, "incremental") {
+		t.Fatalf("full backup must not reference a parent, got:\n%s", backupXML)
+	}
+}
+
+func TestBuildBackupXMLMissingPrimary(t *testing.T) {
+	disks := []snapshotTargetDisk{{Target: "vda", File: "/mnt/share/web/web.qcow2"}}
+	if _, _, err := buildBackupXML(DiskBackupOptions{Checkpoint: "bak-1"}, disks, "/other.qcow2"); err == nil {
+		t.Fatalf("expected error when the primary disk is not in the domain")
+	}
+}
@@ -0,0 +1,201 @@
+package virsh
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+	libvirt "libvirt.org/go/libvirt"
+)
+
+const (
+	testBootDefaultTimeout = 5 * time.Minute
+	testBootPoll           = 5 * time.Second
+)
+
+type qemuImgCheck struct {
+	CheckErrors int64 `json:"check-errors"`
+	Corruptions int64 `json:"corruptions"`
+	Leaks       int64 `json:"leaks"`
+}
+
+// parseQemuImgCheck reads the json of qemu-img check, leaked clusters only
+// waste space so they don't fail the check.
+func parseQemuImgCheck(out []byte) (*grpcVirsh.CheckDiskImageResponse, error) {
+	var check qemuImgCheck
+	if err := json.Unmarshal(out, &check); err != nil {
+		return nil, fmt.Errorf("parse qemu-img check output: %w", err)
+	}
+	resp := &grpcVirsh.CheckDiskImageResponse{
+		Ok:          check.CheckErrors == 0 && check.Corruptions == 0,
+		Corruptions: check.Corruptions,
+		Leaks:       check.Leaks,
+		Output:      strings.TrimSpace(string(out)),
+	}
+	return resp, nil
+}
+
+// CheckDiskImage runs qemu-img check on path. Exit codes 2 and 3 (corruptions
+// and leaks) still print the report, only the others are errors.
+func CheckDiskImage(path string) (*grpcVirsh.CheckDiskImageResponse, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("stat %s: %w", path, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("qemu-img", "check", "--output=json", path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || (exitErr.ExitCode() != 2 && exitErr.ExitCode() != 3) {
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				msg = err.Error()
+			}
+			return &grpcVirsh.CheckDiskImageResponse{Ok: false, Output: msg}, nil
+		}
+	}
+	return parseQemuImgCheck(stdout.Bytes())
+}
+
+// buildTestBootXML is a transient domain with only the disk, a guest agent
+// channel and no network, so a restored guest can't talk to anything.
+func buildTestBootXML(name, overlayPath string, memoryMB, vcpus int32) (string, error) {
+	var escName, escPath bytes.Buffer
+	if err := xml.EscapeText(&escName, []byte(name)); err != nil {
+		return "", err
+	}
+	if err := xml.EscapeText(&escPath, []byte(overlayPath)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`<domain type='kvm'>
+  <name>%s</name>
+  <memory unit='MiB'>%d</memory>
+  <vcpu placement='static'>%d</vcpu>
+  <os>
+    <type arch='x86_64'>hvm</type>
+    <boot dev='hd'/>
+  </os>
+  <features><acpi/><apic/></features>
+  <cpu mode='host-passthrough'/>
+  <devices>
+    <disk type='file' device='disk'>
+      <driver name='qemu' type='qcow2'/>
+      <source file='%s'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+    <controller type='virtio-serial' index='0'/>
+    <channel type='unix'>
+      <source mode='bind'/>
+      <target type='virtio' name='org.qemu.guest_agent.0'/>
+    </channel>
+    <memballoon model='virtio'/>
+  </devices>
+</domain>`, escName.String(), memoryMB, vcpus, escPath.String()), nil
+}
+
+// TestBootBackup boots the backup as a throwaway domain on a qcow2 overlay and
+// waits for its guest agent. The domain is transient, destroying it is all the
+// cleanup libvirt needs, and the overlay is removed afterwards.
+func TestBootBackup(req *grpcVirsh.TestBootRequest) (*grpcVirsh.TestBootResponse, error) {
+	if strings.TrimSpace(req.Name) == "" || strings.TrimSpace(req.DiskPath) == "" {
+		return nil, fmt.Errorf("name and disk_path are required")
+	}
+	// an overlay on a share is opened up like any VM disk, one in the scratch
+	// folder only needs to belong to qemu
+	permissions := ensureDiskPermissions
+	if req.OverlayPath == "" {
+		if err := os.MkdirAll(scratchRoot, 0711); err != nil {
+			return nil, fmt.Errorf("create scratch folder: %w", err)
+		}
+		path, err := scratchPath(req.Name + "-overlay.qcow2")
+		if err != nil {
+			return nil, err
+		}
+		req.OverlayPath = path
+		permissions = chownScratch
+	}
+	if _, err := os.Stat(req.DiskPath); err != nil {
+		return nil, fmt.Errorf("stat %s: %w", req.DiskPath, err)
+	}
+	if _, err := os.Stat(req.OverlayPath); err == nil {
+		return nil, fmt.Errorf("overlay %s already exists", req.OverlayPath)
+	}
+	memoryMB, vcpus := req.MemoryMb, req.Vcpus
+	if memoryMB <= 0 {
+		memoryMB = 1024
+	}
+	if vcpus <= 0 {
+		vcpus = 1
+	}
+	timeout := time.Duration(req.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = testBootDefaultTimeout
+	}
+
+	cmd := exec.Command("qemu-img", "create", "-f", "qcow2", "-b", req.DiskPath, "-F", "qcow2", req.OverlayPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("qemu-img create %s: %s", req.OverlayPath, strings.TrimSpace(string(out)))
+	}
+	defer func() {
+		if err := os.Remove(req.OverlayPath); err != nil && !os.IsNotExist(err) {
+			logger.Warnf("test boot %s: remove overlay: %v", req.Name, err)
+		}
+	}()
+	if err := permissions(req.OverlayPath); err != nil {
+		return nil, err
+	}
+
+	domXML, err := buildTestBootXML(req.Name, req.OverlayPath, memoryMB, vcpus)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := libvirt.NewConnect("qemu:///system")
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	dom, err := conn.DomainCreateXML(domXML, 0)
+	if err != nil {
+		return nil, fmt.Errorf("start test domain: %w", err)
+	}
+	defer dom.Free()
+	defer func() {
+		if err := dom.Destroy(); err != nil {
+			logger.Warnf("test boot %s: destroy: %v", req.Name, err)
+		}
+	}()
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		if _, lastErr = dom.QemuAgentCommand(`{"execute":"guest-ping"}`, guestAgentPingTimeout, 0); lastErr == nil {
+			return &grpcVirsh.TestBootResponse{
+				AgentOk: true,
+				Seconds: int32(time.Since(start).Seconds()),
+				Message: "guest agent answered",
+			}, nil
+		}
+		time.Sleep(testBootPoll)
+	}
+	return &grpcVirsh.TestBootResponse{
+		AgentOk: false,
+		Seconds: int32(timeout.Seconds()),
+		Message: fmt.Sprintf("guest agent did not answer within %s: %v", timeout, lastErr),
+	}, nil
+}
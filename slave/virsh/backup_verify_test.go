@@ -0,0 +1,58 @@
+package virsh
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestParseQemuImgCheck(t *testing.T) {
+	clean := []byte(`{"image-end-offset": 262144, "total-clusters": 16384, "check-errors": 0, "filename": "/mnt/share1/backup-x/web.qcow2", "format": "qcow2"}`)
+	resp, err := parseQemuImgCheck(clean)
+	if err != nil {
+		t.Fatalf("parseQemuImgCheck returned error: %v", err)
+	}
+	if !resp.Ok || resp.Corruptions != 0 || resp.Leaks != 0 {
+		t.Fatalf("clean image reported as %+v", resp)
+	}
+
+	leaks := []byte(`{"check-errors": 0, "leaks": 12, "filename": "a.qcow2", "format": "qcow2"}`)
+	if resp, _ = parseQemuImgCheck(leaks); !resp.Ok || resp.Leaks != 12 {
+		t.Fatalf("leaked clusters should not fail the check, got %+v", resp)
+	}
+
+	corrupt := []byte(`{"check-errors": 0, "corruptions": 3, "leaks": 1, "filename": "a.qcow2", "format": "qcow2"}`)
+	if resp, _ = parseQemuImgCheck(corrupt); resp.Ok || resp.Corruptions != 3 {
+		t.Fatalf("corrupt image reported as %+v", resp)
+	}
+
+	if _, err := parseQemuImgCheck([]byte("qemu-img: Could not open")); err == nil {
+		t.Fatalf("expected an error for non json output")
+	}
+}
+
+func TestBuildTestBootXML(t *testing.T) {
+	got, err := buildTestBootXML("verify-7", "/mnt/share1/backup-x/verify&.qcow2", 2048, 2)
+	if err != nil {
+		t.Fatalf("buildTestBootXML returned error: %v", err)
+	}
+	if strings.Contains(got, "<interface") {
+		t.Fatalf("test boot domain must not have a network interface:\n%s", got)
+	}
+	var dom struct {
+		Name    string `xml:"name"`
+		Devices struct {
+			Disk struct {
+				Source struct {
+					File string `xml:"file,attr"`
+				} `xml:"source"`
+			} `xml:"disk"`
+		} `xml:"devices"`
+	}
+	if err := xml.Unmarshal([]byte(got), &dom); err != nil {
+		t.Fatalf("generated xml does not parse: %v", err)
+	}
+	if dom.Name != "verify-7" || dom.Devices.Disk.Source.File != "/mnt/share1/backup-x/verify&.qcow2" {
+		t.Fatalf("unexpected domain %+v", dom)
+	}
+}
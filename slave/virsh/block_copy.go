@@ -0,0 +1,239 @@
+package virsh
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	libvirt "libvirt.org/go/libvirt"
+)
+
+// a var so tests don't wait a second per poll
+var blockCopyPollInterval = time.Second
+
+type BlockCopyOptions struct {
+	VmName          string
+	SourcePath      string
+	DestPath        string
+	Format          string
+	MaxBandwidthMiB uint64
+	RemoveSource    bool
+}
+
+type blockCopyDestXML struct {
+	XMLName xml.Name          `xml:"disk"`
+	Type    string            `xml:"type,attr"`
+	Driver  snapshotDriverXML `xml:"driver"`
+	Source  snapshotSourceXML `xml:"source"`
+}
+
+func buildBlockCopyXML(dest, format string) (string, error) {
+	if format == "" {
+		format = "qcow2"
+	}
+	out, err := xml.Marshal(blockCopyDestXML{
+		Type:   "file",
+		Driver: snapshotDriverXML{Type: format},
+		Source: snapshotSourceXML{File: dest},
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// BlockCopyDisk mirrors a disk of a running domain to DestPath and pivots the
+// domain onto the copy once both are in sync, the guest keeps running the
+// whole time. Backing files are merged into the copy so linked clones come out
+// standalone. progress is called every second and once more with the final
+// phase.
+func BlockCopyDisk(ctx context.Context, opts BlockCopyOptions, progress func(*grpcVirsh.BlockCopyProgress)) error {
+	opts.VmName = strings.TrimSpace(opts.VmName)
+	opts.SourcePath = filepath.Clean(strings.TrimSpace(opts.SourcePath))
+	opts.DestPath = filepath.Clean(strings.TrimSpace(opts.DestPath))
+	if opts.VmName == "" {
+		return fmt.Errorf("vm name is empty")
+	}
+	if opts.SourcePath == "." || opts.DestPath == "." {
+		return fmt.Errorf("source and destination paths are required")
+	}
+	if opts.SourcePath == opts.DestPath {
+		return fmt.Errorf("source and destination are the same file")
+	}
+	if _, err := os.Stat(opts.DestPath); err == nil {
+		return fmt.Errorf("destination %s already exists", opts.DestPath)
+	}
+	if err := ensureParentDirExists(opts.DestPath); err != nil {
+		return err
+	}
+
+	conn, err := libvirt.NewConnect("qemu:///system")
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	dom, err := conn.LookupDomainByName(opts.VmName)
+	if err != nil {
+		return fmt.Errorf("lookup: %w", err)
+	}
+	defer dom.Free()
+
+	state, _, err := dom.GetState()
+	if err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	if state != libvirt.DOMAIN_RUNNING && state != libvirt.DOMAIN_PAUSED && state != libvirt.DOMAIN_BLOCKED {
+		return status.Errorf(codes.FailedPrecondition, "domain %s is not running, move the disk offline", opts.VmName)
+	}
+
+	xmlDesc, err := dom.GetXMLDesc(0)
+	if err != nil {
+		return fmt.Errorf("get xml: %w", err)
+	}
+	disks, err := snapshotDisks(xmlDesc)
+	if err != nil {
+		return err
+	}
+	target := ""
+	for _, disk := range disks {
+		if filepath.Clean(disk.File) == opts.SourcePath {
+			target = disk.Target
+			break
+		}
+	}
+	if target == "" {
+		return fmt.Errorf("disk %s is not attached to %s", opts.SourcePath, opts.VmName)
+	}
+
+	destXML, err := buildBlockCopyXML(opts.DestPath, opts.Format)
+	if err != nil {
+		return err
+	}
+	params := &libvirt.DomainBlockCopyParameters{}
+	if opts.MaxBandwidthMiB > 0 {
+		params.BandwidthSet = true
+		params.Bandwidth = opts.MaxBandwidthMiB << 20
+	}
+	if err := dom.BlockCopy(target, destXML, params, 0); err != nil {
+		_ = os.Remove(opts.DestPath)
+		return fmt.Errorf("block copy %s: %w", target, err)
+	}
+
+	report := &grpcVirsh.BlockCopyProgress{Phase: "copying"}
+	if err := waitBlockCopyPivot(ctx, dom, target, report, progress); err != nil {
+		_ = os.Remove(opts.DestPath)
+		return fmt.Errorf("block copy of %s: %w", opts.VmName, err)
+	}
+
+	// checkpoints go only once the domain runs from the copy so a failed copy
+	// leaves the backup chain alone, the master starts a new chain after a move
+	if err := deleteAllCheckpoints(dom); err != nil {
+		logger.Errorf("block copy %s: %v", opts.VmName, err)
+	}
+	if err := ensureDiskPermissions(opts.DestPath); err != nil {
+		logger.Errorf("block copy %s: %v", opts.VmName, err)
+	}
+	if err := syncInactiveDiskSource(conn, dom, opts.SourcePath, opts.DestPath); err != nil {
+		logger.Errorf("block copy %s: %v", opts.VmName, err)
+	}
+	if opts.RemoveSource {
+		if err := os.Remove(opts.SourcePath); err != nil {
+			logger.Errorf("block copy %s: remove old disk %s: %v", opts.VmName, opts.SourcePath, err)
+		}
+	}
+
+	report.Phase = "pivoted"
+	report.Percent = 100
+	progress(report)
+	return nil
+}
+
+// blockCopyJob is the part of a domain that drives a running block copy.
+type blockCopyJob interface {
+	GetBlockJobInfo(disk string, flags libvirt.DomainBlockJobInfoFlags) (*libvirt.DomainBlockJobInfo, error)
+	BlockJobAbort(disk string, flags libvirt.DomainBlockJobAbortFlags) error
+}
+
+// waitBlockCopyPivot reports the copy of target until the mirror is in sync
+// and pivots onto it. On failure or cancel the job is aborted, the domain
+// keeps the old disk and the final phase is reported.
+func waitBlockCopyPivot(ctx context.Context, job blockCopyJob, target string, report *grpcVirsh.BlockCopyProgress, progress func(*grpcVirsh.BlockCopyProgress)) error {
+	abort := func(phase string, cause error) error {
+		if err := job.BlockJobAbort(target, 0); err != nil {
+			logger.Errorf("block copy %s: abort job: %v", target, err)
+		}
+		report.Phase = phase
+		report.Message = cause.Error()
+		progress(report)
+		return cause
+	}
+
+	ticker := time.NewTicker(blockCopyPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return abort("cancelled", fmt.Errorf("cancelled: %w", ctx.Err()))
+		case <-ticker.C:
+		}
+
+		info, err := job.GetBlockJobInfo(target, 0)
+		if err != nil {
+			return abort("failed", fmt.Errorf("block job info: %w", err))
+		}
+		if info.Type == 0 && info.End == 0 {
+			return abort("failed", fmt.Errorf("the job disappeared before the pivot"))
+		}
+		report.Cur = info.Cur
+		report.End = info.End
+		if info.End > 0 {
+			report.Percent = float64(info.Cur) / float64(info.End) * 100
+		}
+		if info.End == 0 || info.Cur != info.End {
+			progress(report)
+			continue
+		}
+
+		// in sync, the pivot fails while the mirror is not ready yet so just retry
+		report.Phase = "ready"
+		progress(report)
+		if err := job.BlockJobAbort(target, libvirt.DOMAIN_BLOCK_JOB_ABORT_PIVOT); err != nil {
+			logger.Warnf("block copy %s: pivot not possible yet: %v", target, err)
+			continue
+		}
+		return nil
+	}
+}
+
+// syncInactiveDiskSource points the persistent definition at the new file in
+// case libvirt only switched the running one.
+func syncInactiveDiskSource(conn *libvirt.Connect, dom *libvirt.Domain, oldPath, newPath string) error {
+	persistent, err := dom.IsPersistent()
+	if err != nil || !persistent {
+		return err
+	}
+	inactive, err := dom.GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE)
+	if err != nil {
+		return fmt.Errorf("get inactive xml: %w", err)
+	}
+	updated := strings.ReplaceAll(inactive, "file='"+oldPath+"'", "file='"+newPath+"'")
+	updated = strings.ReplaceAll(updated, `file="`+oldPath+`"`, `file="`+newPath+`"`)
+	if updated == inactive {
+		return nil
+	}
+	redefined, err := conn.DomainDefineXML(updated)
+	if err != nil {
+		return fmt.Errorf("redefine with %s: %w", newPath, err)
+	}
+	redefined.Free()
+	return nil
+}
@@ -0,0 +1,107 @@
+package virsh
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	libvirt "libvirt.org/go/libvirt"
+)
+
+func TestBuildBlockCopyXML(t *testing.T) {
+	got, err := buildBlockCopyXML("/mnt/share2/web/web.qcow2", "")
+	if err != nil {
+		t.Fatalf("buildBlockCopyXML returned error: %v", err)
+	}
+	want := `<disk type="file"><driver type="qcow2"></driver><source file="/mnt/share2/web/web.qcow2"></source></disk>`
+	if got != want {
+		t.Fatalf("unexpected xml:\n%s\nwant:\n%s", got, want)
+	}
+
+	got, err = buildBlockCopyXML("/mnt/share2/vm_disk_data/data.img", "raw")
+	if err != nil {
+		t.Fatalf("buildBlockCopyXML returned error: %v", err)
+	}
+	want = `<disk type="file"><driver type="raw"></driver><source file="/mnt/share2/vm_disk_data/data.img"></source></disk>`
+	if got != want {
+		t.Fatalf("unexpected raw xml:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+type fakeBlockCopyJob struct {
+	infos         []libvirt.DomainBlockJobInfo
+	pivotFailures int
+	aborts        []libvirt.DomainBlockJobAbortFlags
+}
+
+func (f *fakeBlockCopyJob) GetBlockJobInfo(string, libvirt.DomainBlockJobInfoFlags) (*libvirt.DomainBlockJobInfo, error) {
+	info := f.infos[0]
+	if len(f.infos) > 1 {
+		f.infos = f.infos[1:]
+	}
+	return &info, nil
+}
+
+func (f *fakeBlockCopyJob) BlockJobAbort(_ string, flags libvirt.DomainBlockJobAbortFlags) error {
+	f.aborts = append(f.aborts, flags)
+	if flags == libvirt.DOMAIN_BLOCK_JOB_ABORT_PIVOT && f.pivotFailures > 0 {
+		f.pivotFailures--
+		return errors.New("mirror not ready")
+	}
+	return nil
+}
+
+func TestWaitBlockCopyPivot(t *testing.T) {
+	blockCopyPollInterval = time.Millisecond
+	t.Cleanup(func() { blockCopyPollInterval = time.Second })
+	copying := libvirt.DomainBlockJobInfo{Type: libvirt.DOMAIN_BLOCK_JOB_TYPE_COPY, Cur: 50, End: 100}
+	synced := libvirt.DomainBlockJobInfo{Type: libvirt.DOMAIN_BLOCK_JOB_TYPE_COPY, Cur: 100, End: 100}
+
+	t.Run("pivots once in sync", func(t *testing.T) {
+		job := &fakeBlockCopyJob{infos: []libvirt.DomainBlockJobInfo{copying, synced}, pivotFailures: 1}
+		var phases []string
+		report := &grpcVirsh.BlockCopyProgress{Phase: "copying"}
+		err := waitBlockCopyPivot(context.Background(), job, "vda", report, func(p *grpcVirsh.BlockCopyProgress) {
+			phases = append(phases, p.Phase)
+		})
+		if err != nil {
+			t.Fatalf("waitBlockCopyPivot returned error: %v", err)
+		}
+		want := []libvirt.DomainBlockJobAbortFlags{libvirt.DOMAIN_BLOCK_JOB_ABORT_PIVOT, libvirt.DOMAIN_BLOCK_JOB_ABORT_PIVOT}
+		if !slices.Equal(job.aborts, want) {
+			t.Fatalf("expected a retried pivot and no plain abort, got %v", job.aborts)
+		}
+		if phases[0] != "copying" || phases[len(phases)-1] != "ready" || report.Percent != 100 {
+			t.Fatalf("unexpected progress %v at %.0f%%", phases, report.Percent)
+		}
+	})
+
+	t.Run("aborts when the job disappears", func(t *testing.T) {
+		job := &fakeBlockCopyJob{infos: []libvirt.DomainBlockJobInfo{copying, {}}}
+		report := &grpcVirsh.BlockCopyProgress{Phase: "copying"}
+		err := waitBlockCopyPivot(context.Background(), job, "vda", report, func(*grpcVirsh.BlockCopyProgress) {})
+		if err == nil {
+			t.Fatalf("expected an error when the job disappears")
+		}
+		if len(job.aborts) != 1 || job.aborts[0] != 0 || report.Phase != "failed" {
+			t.Fatalf("expected a plain abort and a failed phase, got %v %q", job.aborts, report.Phase)
+		}
+	})
+
+	t.Run("aborts on cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		job := &fakeBlockCopyJob{infos: []libvirt.DomainBlockJobInfo{copying}}
+		report := &grpcVirsh.BlockCopyProgress{Phase: "copying"}
+		err := waitBlockCopyPivot(ctx, job, "vda", report, func(*grpcVirsh.BlockCopyProgress) {})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected a cancelled error, got %v", err)
+		}
+		if len(job.aborts) != 1 || job.aborts[0] != 0 || report.Phase != "cancelled" {
+			t.Fatalf("expected a plain abort and a cancelled phase, got %v %q", job.aborts, report.Phase)
+		}
+	})
+}
@@ -0,0 +1,524 @@
+package virsh
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+	libvirt "libvirt.org/go/libvirt"
+)
+
+const (
+	xorrisoBinary  = "xorriso"
+	xorrisoPackage = "xorriso"
+
+	// NoCloud only looks at volumes labelled cidata
+	cloudInitVolumeID = "cidata"
+	// sda is the install ISO and sdb the virtio drivers ISO
+	cloudInitTargetDev = "sdc"
+)
+
+type CloudInitUser struct {
+	Name              string
+	Password          string
+	SSHAuthorizedKeys []string
+	Sudo              bool
+	Shell             string
+}
+
+type CloudInitOptions struct {
+	Hostname          string
+	Users             []CloudInitUser
+	SSHAuthorizedKeys []string
+	UserData          string
+	NetworkConfig     string
+	VendorData        string
+}
+
+func EnsureXorrisoInstalled() error {
+	if _, err := exec.LookPath(xorrisoBinary); err == nil {
+		return nil
+	}
+
+	logger.Info("xorriso not found, installing package", "package", xorrisoPackage)
+	if _, err := exec.LookPath("dnf"); err != nil {
+		return fmt.Errorf("dnf is required to install xorriso: %w", err)
+	}
+	if err := runCmdDiscardOutput("dnf", "-y", "install", xorrisoPackage); err != nil {
+		return fmt.Errorf("install xorriso: %w", err)
+	}
+	if _, err := exec.LookPath(xorrisoBinary); err != nil {
+		return fmt.Errorf("xorriso binary still missing after install: %w", err)
+	}
+	return nil
+}
+
+func (o *CloudInitOptions) validate() error {
+	if strings.TrimSpace(o.UserData) != "" && (len(o.Users) > 0 || len(o.SSHAuthorizedKeys) > 0) {
+		return fmt.Errorf("cloud-init user_data cannot be combined with users or ssh keys, put them in user_data")
+	}
+	for _, u := range o.Users {
+		name := strings.TrimSpace(u.Name)
+		if name == "" {
+			return fmt.Errorf("cloud-init user name is required")
+		}
+		if strings.ContainsAny(name, " \t\n:/") {
+			return fmt.Errorf("cloud-init user name %q is invalid", name)
+		}
+	}
+	if h := strings.TrimSpace(o.Hostname); h != "" && strings.ContainsAny(h, " \t\n/_") {
+		return fmt.Errorf("hostname %q is invalid", h)
+	}
+	return nil
+}
+
+// buildCloudInitUserData returns the user-data file. Raw user data is passed
+// through, otherwise a #cloud-config is generated (JSON is valid YAML so no
+// yaml dependency is needed).
+func buildCloudInitUserData(o CloudInitOptions) (string, error) {
+	if raw := strings.TrimSpace(o.UserData); raw != "" {
+		return raw + "\n", nil
+	}
+
+	cfg := map[string]any{}
+	if len(o.SSHAuthorizedKeys) > 0 {
+		cfg["ssh_authorized_keys"] = o.SSHAuthorizedKeys
+	}
+
+	if len(o.Users) > 0 {
+		// keep the image default user (ubuntu, debian, ...) next to ours
+		users := []any{"default"}
+		needsPasswordAuth := false
+		for _, u := range o.Users {
+			entry := map[string]any{
+				"name": strings.TrimSpace(u.Name),
+			}
+			if u.Password != "" {
+				// the seed iso is readable by anyone with the share, only ship the crypt hash
+				hash, err := hashCloudInitPassword(u.Password)
+				if err != nil {
+					return "", err
+				}
+				entry["passwd"] = hash
+				entry["lock_passwd"] = false
+				needsPasswordAuth = true
+			}
+			if len(u.SSHAuthorizedKeys) > 0 {
+				entry["ssh_authorized_keys"] = u.SSHAuthorizedKeys
+			}
+			if u.Sudo {
+				entry["sudo"] = "ALL=(ALL) NOPASSWD:ALL"
+			}
+			shell := strings.TrimSpace(u.Shell)
+			if shell == "" {
+				shell = "/bin/bash"
+			}
+			entry["shell"] = shell
+			users = append(users, entry)
+		}
+		cfg["users"] = users
+		if needsPasswordAuth {
+			cfg["ssh_pwauth"] = true
+		}
+	}
+
+	body, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal cloud-config: %w", err)
+	}
+	return "#cloud-config\n" + string(body) + "\n", nil
+}
+
+func buildCloudInitMetaData(vmName string, o CloudInitOptions) string {
+	hostname := strings.TrimSpace(o.Hostname)
+	if hostname == "" {
+		hostname = vmName
+	}
+	// a new instance-id per seed makes cloud-init run again on a recreated vm
+	return fmt.Sprintf("instance-id: %s-%d\nlocal-hostname: %s\n", vmName, time.Now().Unix(), hostname)
+}
+
+func cloudInitSeedPath(diskPath, vmName string) string {
+	return filepath.Join(filepath.Dir(diskPath), vmName+"-seed.iso")
+}
+
+// CreateCloudInitSeed writes a NoCloud seed ISO next to the vm disk and returns its path.
+func CreateCloudInitSeed(vmName, diskPath string, o CloudInitOptions) (string, error) {
+	if err := o.validate(); err != nil {
+		return "", err
+	}
+	if err := EnsureXorrisoInstalled(); err != nil {
+		return "", err
+	}
+
+	userData, err := buildCloudInitUserData(o)
+	if err != nil {
+		return "", err
+	}
+
+	staging, err := os.MkdirTemp("", "cloud-init-"+vmName+"-")
+	if err != nil {
+		return "", fmt.Errorf("create staging dir: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	files := []struct{ name, content string }{
+		{"user-data", userData},
+		{"meta-data", buildCloudInitMetaData(vmName, o)},
+	}
+	if nc := strings.TrimSpace(o.NetworkConfig); nc != "" {
+		files = append(files, struct{ name, content string }{"network-config", nc + "\n"})
+	}
+	if vd := strings.TrimSpace(o.VendorData); vd != "" {
+		files = append(files, struct{ name, content string }{"vendor-data", vd + "\n"})
+	}
+
+	seedPath := cloudInitSeedPath(diskPath, vmName)
+	_ = os.Remove(seedPath)
+
+	args := []string{"-as", "mkisofs", "-output", seedPath, "-volid", cloudInitVolumeID, "-joliet", "-rock"}
+	for _, f := range files {
+		path := filepath.Join(staging, f.name)
+		if err := os.WriteFile(path, []byte(f.content), 0o644); err != nil {
+			return "", fmt.Errorf("write %s: %w", f.name, err)
+		}
+		args = append(args, path)
+	}
+
+	cmd := exec.Command(xorrisoBinary, args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("xorriso %s: %s", seedPath, strings.TrimSpace(string(out)))
+	}
+	if err := ensureDiskPermissions(seedPath); err != nil {
+		return "", err
+	}
+	return seedPath, nil
+}
+
+// removeCloudInitSeed deletes a seed iso, a running guest still has the file
+// open so cloud-init can finish reading it until the vm powers off.
+func removeCloudInitSeed(seedPath string) {
+	if err := os.Remove(seedPath); err != nil && !os.IsNotExist(err) {
+		logger.Errorf("remove cloud-init seed %s: %v", seedPath, err)
+	}
+}
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// hashCloudInitPassword returns the SHA-512 crypt ($6$) hash of password with
+// a random salt, the format /etc/shadow and cloud-init's passwd key expect.
+func hashCloudInitPassword(password string) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("generate salt: %w", err)
+	}
+	salt := make([]byte, len(raw))
+	for i, b := range raw {
+		salt[i] = cryptAlphabet[int(b)%len(cryptAlphabet)]
+	}
+	return sha512Crypt(password, string(salt)), nil
+}
+
+// sha512Crypt implements SHA-crypt with SHA-512 and the default 5000 rounds
+// (https://www.akkadia.org/drepper/SHA-crypt.txt).
+func sha512Crypt(password, salt string) string {
+	if len(salt) > 16 {
+		salt = salt[:16]
+	}
+	pw, sl := []byte(password), []byte(salt)
+
+	// repeat fills n bytes with copies of sum
+	repeat := func(sum []byte, n int) []byte {
+		out := make([]byte, 0, n)
+		for len(out)+len(sum) <= n {
+			out = append(out, sum...)
+		}
+		return append(out, sum[:n-len(out)]...)
+	}
+
+	h := sha512.New()
+	h.Write(pw)
+	h.Write(sl)
+	h.Write(pw)
+	b := h.Sum(nil)
+
+	h.Reset()
+	h.Write(pw)
+	h.Write(sl)
+	h.Write(repeat(b, len(pw)))
+	for n := len(pw); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(pw)
+		}
+	}
+	a := h.Sum(nil)
+
+	h.Reset()
+	for range pw {
+		h.Write(pw)
+	}
+	p := repeat(h.Sum(nil), len(pw))
+
+	h.Reset()
+	for i := 0; i < 16+int(a[0]); i++ {
+		h.Write(sl)
+	}
+	s := repeat(h.Sum(nil), len(sl))
+
+	c := a
+	for i := 0; i < 5000; i++ {
+		h.Reset()
+		if i%2 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(c)
+		}
+		if i%3 != 0 {
+			h.Write(s)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i%2 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(p)
+		}
+		c = h.Sum(nil)
+	}
+
+	out := []byte("$6$" + salt + "$")
+	encode := func(b2, b1, b0 byte, n int) {
+		w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+		for ; n > 0; n-- {
+			out = append(out, cryptAlphabet[w&0x3f])
+			w >>= 6
+		}
+	}
+	// every group holds bytes i, i+21 and i+42, rotated by i%3
+	for i := 0; i < 21; i++ {
+		g := [3]byte{c[i], c[i+21], c[i+42]}
+		r := i % 3
+		encode(g[r], g[(r+1)%3], g[(r+2)%3], 4)
+	}
+	encode(0, 0, c[63], 2)
+	return string(out)
+}
+
+func cloudInitCDROMXML(seedPath string) string {
+	return fmt.Sprintf(`
+	<disk type='file' device='cdrom'>
+	  <driver name='qemu' type='raw'/>
+	  <source file='%s'/>
+	  <target dev='%s' bus='sata'/>
+	  <readonly/>
+	</disk>`, seedPath, cloudInitTargetDev)
+}
+
+// detachCloudInitSeedFromConfig drops the seed from the persistent definition,
+// a running guest keeps the cdrom until it powers off.
+func detachCloudInitSeedFromConfig(dom *libvirt.Domain, seedPath string) error {
+	if err := dom.DetachDeviceFlags(cloudInitCDROMXML(seedPath), libvirt.DOMAIN_DEVICE_MODIFY_CONFIG); err != nil {
+		return fmt.Errorf("detach cloud-init seed: %w", err)
+	}
+	return nil
+}
+
+// cloud-init writes it at the end of its final stage, /run is empty on every
+// boot so a file left in the image can't be mistaken for this one
+const cloudInitResultFile = "/run/cloud-init/result.json"
+
+var (
+	// a var so tests don't wait half a minute per poll
+	cloudInitSeedPollInterval = 30 * time.Second
+	cloudInitSeedMaxWait      = 24 * time.Hour
+)
+
+// cloudInitGuest is the part of a domain that tells whether the first boot
+// is done.
+type cloudInitGuest interface {
+	GetState() (libvirt.DomainState, int, error)
+	QemuAgentCommand(command string, timeout libvirt.DomainQemuAgentCommandTimeout, flags uint32) (string, error)
+}
+
+// cloudInitFirstBootDone reports whether the guest no longer needs its seed:
+// it was shut off, or the guest agent finds the result cloud-init writes when
+// it is done. A guest without an agent keeps the seed until it powers off.
+func cloudInitFirstBootDone(guest cloudInitGuest) (bool, error) {
+	state, _, err := guest.GetState()
+	if err != nil {
+		return false, fmt.Errorf("state: %w", err)
+	}
+	if state == libvirt.DOMAIN_SHUTOFF {
+		return true, nil
+	}
+	if state != libvirt.DOMAIN_RUNNING {
+		return false, nil
+	}
+	out, err := guest.QemuAgentCommand(`{"execute":"guest-file-open","arguments":{"path":"`+cloudInitResultFile+`","mode":"r"}}`, guestAgentPingTimeout, 0)
+	if err != nil {
+		// no agent yet or cloud-init still running
+		return false, nil
+	}
+	var opened struct {
+		Return int64 `json:"return"`
+	}
+	if json.Unmarshal([]byte(out), &opened) == nil {
+		_, _ = guest.QemuAgentCommand(fmt.Sprintf(`{"execute":"guest-file-close","arguments":{"handle":%d}}`, opened.Return), guestAgentPingTimeout, 0)
+	}
+	return true, nil
+}
+
+// watchCloudInitSeed waits for the first boot of vmName to finish, then takes
+// the seed out of its definition and deletes it. It gives up when the domain
+// goes away, a migrated vm still reads the seed on its new slave.
+func watchCloudInitSeed(connURI, vmName, seedPath string) {
+	deadline := time.Now().Add(cloudInitSeedMaxWait)
+	for time.Now().Before(deadline) {
+		time.Sleep(cloudInitSeedPollInterval)
+		done, err := checkCloudInitSeed(connURI, vmName, seedPath)
+		if err != nil {
+			logger.Warnf("vm %s: stop watching cloud-init seed %s: %v", vmName, seedPath, err)
+			return
+		}
+		if done {
+			return
+		}
+	}
+	logger.Warnf("vm %s: first boot did not finish in %s, cloud-init seed %s stays attached until the next slave start", vmName, cloudInitSeedMaxWait, seedPath)
+}
+
+func checkCloudInitSeed(connURI, vmName, seedPath string) (bool, error) {
+	conn, err := libvirt.NewConnect(connURI)
+	if err != nil {
+		return false, fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	dom, err := conn.LookupDomainByName(vmName)
+	if err != nil {
+		return false, fmt.Errorf("lookup: %w", err)
+	}
+	defer dom.Free()
+
+	done, err := cloudInitFirstBootDone(dom)
+	if err != nil || !done {
+		return false, err
+	}
+	return true, releaseCloudInitSeed(dom, vmName, seedPath)
+}
+
+// releaseCloudInitSeed detaches the seed, deletes it and rewrites the domain
+// xml kept next to the disk so it no longer points at the iso.
+func releaseCloudInitSeed(dom *libvirt.Domain, vmName, seedPath string) error {
+	if err := detachCloudInitSeedFromConfig(dom, seedPath); err != nil {
+		return err
+	}
+	removeCloudInitSeed(seedPath)
+
+	xmlDesc, err := dom.GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE)
+	if err != nil {
+		return fmt.Errorf("get inactive xml: %w", err)
+	}
+	diskPath, err := diskPathFromDomainXML(xmlDesc)
+	if err != nil {
+		return err
+	}
+	if diskPath == "" {
+		return nil
+	}
+	if _, err := WriteDomainXMLToDisk(vmName, xmlDesc, diskPath); err != nil {
+		return err
+	}
+	return nil
+}
+
+// cloudInitSeedFromDomainXML returns the seed iso still attached to a domain,
+// empty when there is none.
+func cloudInitSeedFromDomainXML(xmlData string) (string, error) {
+	var d struct {
+		Devices struct {
+			Disks []struct {
+				Device string `xml:"device,attr"`
+				Source struct {
+					File string `xml:"file,attr"`
+				} `xml:"source"`
+				Target struct {
+					Dev string `xml:"dev,attr"`
+				} `xml:"target"`
+			} `xml:"disk"`
+		} `xml:"devices"`
+	}
+	if err := xml.Unmarshal([]byte(xmlData), &d); err != nil {
+		return "", fmt.Errorf("parse domain xml: %w", err)
+	}
+	for _, disk := range d.Devices.Disks {
+		if disk.Device == "cdrom" && disk.Target.Dev == cloudInitTargetDev && strings.HasSuffix(disk.Source.File, "-seed.iso") {
+			return disk.Source.File, nil
+		}
+	}
+	return "", nil
+}
+
+// ResumeCloudInitSeedWatches watches again the seeds of vms whose first boot
+// had not finished when the slave stopped.
+func ResumeCloudInitSeedWatches() error {
+	conn, err := libvirt.NewConnect("qemu:///system")
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	doms, err := conn.ListAllDomains(libvirt.CONNECT_LIST_DOMAINS_PERSISTENT)
+	if err != nil {
+		return fmt.Errorf("list domains: %w", err)
+	}
+	for i := range doms {
+		name, nameErr := doms[i].GetName()
+		xmlDesc, xmlErr := doms[i].GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE)
+		doms[i].Free()
+		if nameErr != nil || xmlErr != nil {
+			continue
+		}
+		seed, err := cloudInitSeedFromDomainXML(xmlDesc)
+		if err != nil || seed == "" {
+			continue
+		}
+		go watchCloudInitSeed("qemu:///system", name, seed)
+	}
+	return nil
+}
+
+func cloudInitFromProto(cfg *grpcVirsh.CloudInitConfig) *CloudInitOptions {
+	if cfg == nil {
+		return nil
+	}
+	opts := &CloudInitOptions{
+		Hostname:          cfg.Hostname,
+		SSHAuthorizedKeys: cfg.SshAuthorizedKeys,
+		UserData:          cfg.UserData,
+		NetworkConfig:     cfg.NetworkConfig,
+		VendorData:        cfg.VendorData,
+	}
+	for _, u := range cfg.Users {
+		opts.Users = append(opts.Users, CloudInitUser{
+			Name:              u.Name,
+			Password:          u.Password,
+			SSHAuthorizedKeys: u.SshAuthorizedKeys,
+			Sudo:              u.Sudo,
+			Shell:             u.Shell,
+		})
+	}
+	return opts
+}
@@ -0,0 +1,160 @@
+package virsh
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	libvirt "libvirt.org/go/libvirt"
+)
+
+func TestBuildCloudInitUserDataGeneratesCloudConfig(t *testing.T) {
+	out, err := buildCloudInitUserData(CloudInitOptions{
+		SSHAuthorizedKeys: []string{"ssh-ed25519 AAAA root@host"},
+		Users: []CloudInitUser{
+			{Name: "ops", Password: "secret", Sudo: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("buildCloudInitUserData returned error: %v", err)
+	}
+	if !strings.HasPrefix(out, "#cloud-config\n") {
+		t.Fatalf("expected #cloud-config header, got %q", out)
+	}
+
+	var cfg map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(out, "#cloud-config\n")), &cfg); err != nil {
+		t.Fatalf("cloud-config body is not valid json: %v", err)
+	}
+	if cfg["ssh_pwauth"] != true {
+		t.Fatalf("expected ssh_pwauth when a password is set, got %v", cfg["ssh_pwauth"])
+	}
+	users, ok := cfg["users"].([]any)
+	if !ok || len(users) != 2 || users[0] != "default" {
+		t.Fatalf("unexpected users %v", cfg["users"])
+	}
+	ops := users[1].(map[string]any)
+	if ops["name"] != "ops" || ops["sudo"] != "ALL=(ALL) NOPASSWD:ALL" || ops["shell"] != "/bin/bash" || ops["lock_passwd"] != false {
+		t.Fatalf("unexpected user entry %v", ops)
+	}
+	if hash, _ := ops["passwd"].(string); !strings.HasPrefix(hash, "$6$") || strings.Contains(out, "secret") {
+		t.Fatalf("expected only the crypt hash of the password, got %q", out)
+	}
+}
+
+func TestBuildCloudInitUserDataPassesRawThrough(t *testing.T) {
+	raw := "#cloud-config\npackages:\n  - nginx"
+	out, err := buildCloudInitUserData(CloudInitOptions{UserData: raw})
+	if err != nil {
+		t.Fatalf("buildCloudInitUserData returned error: %v", err)
+	}
+	if out != raw+"\n" {
+		t.Fatalf("expected raw user data, got %q", out)
+	}
+}
+
+func TestCloudInitOptionsValidate(t *testing.T) {
+	bad := []CloudInitOptions{
+		{UserData: "#cloud-config", Users: []CloudInitUser{{Name: "ops"}}},
+		{Users: []CloudInitUser{{Name: ""}}},
+		{Users: []CloudInitUser{{Name: "bad name"}}},
+		{Hostname: "web_01"},
+	}
+	for i, o := range bad {
+		if err := o.validate(); err == nil {
+			t.Fatalf("case %d: expected validation error", i)
+		}
+	}
+
+	good := CloudInitOptions{Hostname: "web-01", Users: []CloudInitUser{{Name: "ops"}}}
+	if err := good.validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestBuildCloudInitMetaDataDefaultsHostname(t *testing.T) {
+	out := buildCloudInitMetaData("web", CloudInitOptions{})
+	if !strings.Contains(out, "local-hostname: web\n") || !strings.HasPrefix(out, "instance-id: web-") {
+		t.Fatalf("unexpected meta-data %q", out)
+	}
+}
+
+func TestSha512Crypt(t *testing.T) {
+	// checked against openssl passwd -6
+	if got := sha512Crypt("Hello world!", "saltstring"); got != "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1" {
+		t.Fatalf("unexpected hash %q", got)
+	}
+	if got := sha512Crypt("we have a short salt string but not a short password", "short"); got != "$6$short$qmfj2meTBr5G2EAGIJ4vjX7RpefsD4JzpEyTAeEUJdzdxlBS6pe8gdMHm5zFftaFSj/2p2bjBwyVS9ZhWpLZt." {
+		t.Fatalf("unexpected hash %q", got)
+	}
+
+	hash, err := hashCloudInitPassword("secret")
+	if err != nil {
+		t.Fatalf("hashCloudInitPassword returned error: %v", err)
+	}
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[1] != "6" || len(parts[2]) != 16 || sha512Crypt("secret", parts[2]) != hash {
+		t.Fatalf("unexpected password hash %q", hash)
+	}
+}
+
+type fakeCloudInitGuest struct {
+	state    libvirt.DomainState
+	finished bool
+	commands []string
+}
+
+func (f *fakeCloudInitGuest) GetState() (libvirt.DomainState, int, error) {
+	return f.state, 0, nil
+}
+
+func (f *fakeCloudInitGuest) QemuAgentCommand(command string, _ libvirt.DomainQemuAgentCommandTimeout, _ uint32) (string, error) {
+	f.commands = append(f.commands, command)
+	if strings.Contains(command, "guest-file-open") && !f.finished {
+		return "", errors.New("No such file or directory")
+	}
+	return `{"return":1000}`, nil
+}
+
+func TestCloudInitFirstBootDone(t *testing.T) {
+	cases := []struct {
+		name  string
+		guest *fakeCloudInitGuest
+		want  bool
+	}{
+		{"still booting", &fakeCloudInitGuest{state: libvirt.DOMAIN_RUNNING}, false},
+		{"cloud-init finished", &fakeCloudInitGuest{state: libvirt.DOMAIN_RUNNING, finished: true}, true},
+		{"shut off", &fakeCloudInitGuest{state: libvirt.DOMAIN_SHUTOFF}, true},
+		{"paused", &fakeCloudInitGuest{state: libvirt.DOMAIN_PAUSED, finished: true}, false},
+	}
+	for _, tc := range cases {
+		done, err := cloudInitFirstBootDone(tc.guest)
+		if err != nil || done != tc.want {
+			t.Fatalf("%s: got %v %v, want %v", tc.name, done, err, tc.want)
+		}
+	}
+
+	guest := &fakeCloudInitGuest{state: libvirt.DOMAIN_RUNNING, finished: true}
+	cloudInitFirstBootDone(guest)
+	if len(guest.commands) != 2 || !strings.Contains(guest.commands[1], `"guest-file-close","arguments":{"handle":1000}`) {
+		t.Fatalf("expected the result file to be closed again, got %v", guest.commands)
+	}
+}
+
+func TestCloudInitSeedFromDomainXML(t *testing.T) {
+	withSeed := `<domain><devices>
+	<disk type='file' device='disk'><source file='/mnt/vms/web/web.qcow2'/><target dev='vda' bus='virtio'/></disk>
+	<disk type='file' device='cdrom'><source file='/isos/ubuntu.iso'/><target dev='sda' bus='sata'/></disk>
+	` + cloudInitCDROMXML("/mnt/vms/web/web-seed.iso") + `
+	</devices></domain>`
+	seed, err := cloudInitSeedFromDomainXML(withSeed)
+	if err != nil || seed != "/mnt/vms/web/web-seed.iso" {
+		t.Fatalf("got %q %v", seed, err)
+	}
+
+	without := `<domain><devices><disk type='file' device='cdrom'><source file='/isos/tools.iso'/><target dev='sdc' bus='sata'/></disk></devices></domain>`
+	if seed, err := cloudInitSeedFromDomainXML(without); err != nil || seed != "" {
+		t.Fatalf("expected no seed, got %q %v", seed, err)
+	}
+}
@@ -68,6 +68,8 @@ type qiInfo struct {
 	Format      string `json:"format"`
 	VirtualSize uint64 `json:"virtual-size"`
 	ActualSize  uint64 `json:"actual-size"`
+	// absolute path of the backing file, empty without one
+	FullBackingFilename string `json:"full-backing-filename"`
 }
 
 // DetectDiskFormat returns "qcow2" or "raw" (or other qemu formats if present).
@@ -189,28 +191,9 @@ func ensureDiskPermissions(path string) error {
 		}
 	}
 
-	var uid int
-	var gid int
-
-	// Use configured QEMU UID/GID when provided, otherwise fall back to the current process values.
-	if s := strings.TrimSpace(env512.Qemu_UID); s != "" {
-		u, err := strconv.Atoi(s)
-		if err != nil {
-			return fmt.Errorf("parse qemu uid: %w", err)
-		}
-		uid = u
-	} else {
-		uid = os.Geteuid()
-	}
-
-	if s := strings.TrimSpace(env512.Qemu_GID); s != "" {
-		g, err := strconv.Atoi(s)
-		if err != nil {
-			return fmt.Errorf("parse qemu gid: %w", err)
-		}
-		gid = g
-	} else {
-		gid = os.Getegid()
+	uid, gid, err := qemuOwner()
+	if err != nil {
+		return err
 	}
 
 	if err := os.Chown(path, uid, gid); err != nil {
@@ -223,6 +206,23 @@ func ensureDiskPermissions(path string) error {
 	return nil
 }
 
+// qemuOwner is the configured QEMU UID/GID, the ones of this process when
+// they are not set.
+func qemuOwner() (uid, gid int, err error) {
+	uid, gid = os.Geteuid(), os.Getegid()
+	if s := strings.TrimSpace(env512.Qemu_UID); s != "" {
+		if uid, err = strconv.Atoi(s); err != nil {
+			return 0, 0, fmt.Errorf("parse qemu uid: %w", err)
+		}
+	}
+	if s := strings.TrimSpace(env512.Qemu_GID); s != "" {
+		if gid, err = strconv.Atoi(s); err != nil {
+			return 0, 0, fmt.Errorf("parse qemu gid: %w", err)
+		}
+	}
+	return uid, gid, nil
+}
+
 func ensureDirTreePermissions(path string) error {
 	cleaned := filepath.Clean(path)
 	root := string(filepath.Separator)
@@ -179,7 +179,22 @@ func RemoveVM(name string) error {
 	return nil
 }
 
+// UndefineVm refuses VMs with snapshots or checkpoints, their overlays and
+// memory files would be left without anything pointing at them.
 func UndefineVm(name string) error {
+	// Undefine without flags so storage/disk is not removed
+	return undefineVm(name, 0)
+}
+
+// UndefineStaleVm removes the copy of a VM that HA already started on another
+// slave. Only the metadata goes, the disks, overlays and nvram file belong to
+// the running copy.
+func UndefineStaleVm(name string) error {
+	return undefineVm(name, libvirt.DOMAIN_UNDEFINE_MANAGED_SAVE|libvirt.DOMAIN_UNDEFINE_SNAPSHOTS_METADATA|
+		libvirt.DOMAIN_UNDEFINE_CHECKPOINTS_METADATA|libvirt.DOMAIN_UNDEFINE_KEEP_NVRAM)
+}
+
+func undefineVm(name string, flags libvirt.DomainUndefineFlagsValues) error {
 	conn, err := libvirt.NewConnect("qemu:///system")
 	if err != nil {
 		return fmt.Errorf("connect: %w", err)
@@ -203,8 +218,7 @@ func UndefineVm(name string) error {
 		}
 	}
 
-	// Undefine without flags so storage/disk is not removed
-	if err := dom.UndefineFlags(0); err != nil {
+	if err := dom.UndefineFlags(flags); err != nil {
 		return fmt.Errorf("undefine: %w", err)
 	}
 
@@ -69,6 +69,7 @@ type CreateVMCustomCPUOptions struct {
 	CPUXml            string
 	IsWindows         bool
 	VirtioISOPath     string
+	CloudInit         *CloudInitOptions // optional NoCloud seed, detached once cloud-init is done or the vm first powers off
 }
 
 func isValidVMName(vmName string) error {
@@ -173,6 +174,22 @@ func CreateVMCustomCPU(opts CreateVMCustomCPUOptions) (xmlPath string, err error
 		hasVirtioISO = true
 	}
 
+	cloudInitSeed := ""
+	if opts.CloudInit != nil {
+		seed, e := CreateCloudInitSeed(opts.Name, disk, *opts.CloudInit)
+		if e != nil {
+			err = fmt.Errorf("cloud-init seed: %w", e)
+			return "", err
+		}
+		cloudInitSeed = seed
+		// the seed holds the users' credentials, never leave it behind on the share
+		defer func() {
+			if err != nil {
+				removeCloudInitSeed(cloudInitSeed)
+			}
+		}()
+	}
+
 	connURI := strings.TrimSpace(opts.ConnURI)
 	if connURI == "" {
 		connURI = "qemu:///system"
@@ -231,6 +248,11 @@ func CreateVMCustomCPU(opts CreateVMCustomCPUOptions) (xmlPath string, err error
 	</disk>`, virtioISOTrim)
 	}
 
+	cloudInitCDROM := ""
+	if cloudInitSeed != "" {
+		cloudInitCDROM = cloudInitCDROMXML(cloudInitSeed)
+	}
+
 	listenAddr := strings.TrimSpace(opts.GraphicsListen)
 	if listenAddr == "" {
 		listenAddr = "127.0.0.1"
@@ -325,7 +347,7 @@ func CreateVMCustomCPU(opts CreateVMCustomCPUOptions) (xmlPath string, err error
 	  <driver name='qemu' type='%s' cache='none' io='native'/>
 	  <source file='%s'/>
 	  <target dev='vda' bus='virtio'/>
-	</disk>%s%s%s%s%s%s%s%s%s
+	</disk>%s%s%s%s%s%s%s%s%s%s
 	%s
 	<sound model='ich9'/>
 	%s
@@ -334,7 +356,7 @@ func CreateVMCustomCPU(opts CreateVMCustomCPUOptions) (xmlPath string, err error
 		opts.Name, opts.MemoryMB, opts.VCPUs,
 		machineAttr,
 		bootDev,
-		cpuXML, driverType, disk, cdromXML, virtioCDROMXML, networkXML, virtioSerialControllerXML, guestAgentChannelXML, spiceChannelXML, inputDevicesXML, vncGraphicsXML, spiceGraphicsXML, memballoonXML, videoXML,
+		cpuXML, driverType, disk, cdromXML, virtioCDROMXML, cloudInitCDROM, networkXML, virtioSerialControllerXML, guestAgentChannelXML, spiceChannelXML, inputDevicesXML, vncGraphicsXML, spiceGraphicsXML, memballoonXML, videoXML,
 	)
 
 	xmlPath, err = WriteDomainXMLToDisk(opts.Name, domainXML, disk)
@@ -353,6 +375,10 @@ func CreateVMCustomCPU(opts CreateVMCustomCPUOptions) (xmlPath string, err error
 		err = fmt.Errorf("start: %w", e)
 		return "", err
 	}
+
+	if cloudInitSeed != "" {
+		go watchCloudInitSeed(connURI, opts.Name, cloudInitSeed)
+	}
 	return xmlPath, nil
 }
 
@@ -371,6 +397,10 @@ type MigrateOptions struct {
 	Live    bool
 	Timeout int32
 
+	MaxBandwidthMiB uint64
+	AutoConverge    bool
+	PostCopy        bool
+
 	SSH SSHOptions
 }
 
@@ -0,0 +1,597 @@
+package virsh
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+// A file restore session lives in fileRestoreRoot/<id>: the nbd device the
+// image is attached to is in the device file and every filesystem is mounted
+// read-only under mnt/<partition>. Nothing is kept in memory, so a restarted
+// slave can still clean up.
+const (
+	fileRestoreRoot           = "/var/lib/512svman/file-restore"
+	fileRestoreDefaultTimeout = 2 * time.Minute
+	fileRestoreChunkSize      = 256 * 1024
+	nbdMaxDevices             = 16
+	nbdWaitPartitions         = 10 * time.Second
+)
+
+var (
+	fileRestoreSessionID = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+	fileRestorePartition = regexp.MustCompile(`^(disk|p[0-9]{1,3})$`)
+)
+
+// filesystems mounted for browsing, swap, lvm and luks members are only
+// listed
+var mountableFSTypes = map[string]bool{
+	"ext2": true, "ext3": true, "ext4": true, "xfs": true, "btrfs": true, "vfat": true,
+	"exfat": true, "ntfs": true, "ntfs3": true, "f2fs": true, "iso9660": true,
+}
+
+// fsMountOptions keep the mount from writing to the image, a dirty journal is
+// ignored instead of replayed.
+func fsMountOptions(fstype string) string {
+	opts := "ro,nodev,nosuid,noexec"
+	switch fstype {
+	case "ext3", "ext4":
+		opts += ",noload"
+	case "xfs":
+		opts += ",norecovery,nouuid"
+	case "btrfs":
+		opts += ",nologreplay"
+	}
+	return opts
+}
+
+func fileRestoreDir(sessionID string) (string, error) {
+	if !fileRestoreSessionID.MatchString(sessionID) {
+		return "", fmt.Errorf("invalid session id %q", sessionID)
+	}
+	return filepath.Join(fileRestoreRoot, sessionID), nil
+}
+
+func timeoutOrDefault(seconds int32) time.Duration {
+	if seconds <= 0 {
+		return fileRestoreDefaultTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func runCmdContext(ctx context.Context, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("%s %s: %s", name, strings.Join(args, " "), msg)
+	}
+	return stdout.String(), nil
+}
+
+// freeNBDDevices lists the nbd devices nothing is attached to.
+func freeNBDDevices() []string {
+	var free []string
+	for i := 0; i < nbdMaxDevices; i++ {
+		name := fmt.Sprintf("nbd%d", i)
+		if _, err := os.Stat(filepath.Join("/sys/block", name)); err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join("/sys/block", name, "pid")); err == nil {
+			continue
+		}
+		if size, err := os.ReadFile(filepath.Join("/sys/block", name, "size")); err == nil && strings.TrimSpace(string(size)) != "0" {
+			continue
+		}
+		free = append(free, "/dev/"+name)
+	}
+	return free
+}
+
+// attachNBD connects image read-only to the first free nbd device, another
+// session may take a device between the check and the connect so the next
+// one is tried.
+func attachNBD(ctx context.Context, image string) (string, error) {
+	if _, err := runCmdContext(ctx, "modprobe", "nbd", "max_part=16"); err != nil {
+		logger.Warnf("modprobe nbd: %v", err)
+	}
+	var lastErr error = errors.New("no free nbd device")
+	for _, device := range freeNBDDevices() {
+		_, err := runCmdContext(ctx, "qemu-nbd", "--read-only", "--format=qcow2", "--connect="+device, image)
+		if err == nil {
+			return device, nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+func detachNBD(ctx context.Context, device string) error {
+	_, err := runCmdContext(ctx, "qemu-nbd", "--disconnect", device)
+	return err
+}
+
+// lsblkSize is a number on recent util-linux and a string on older ones.
+type lsblkSize int64
+
+func (s *lsblkSize) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "" || text == "null" {
+		*s = 0
+		return nil
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return err
+	}
+	*s = lsblkSize(n)
+	return nil
+}
+
+type lsblkDevice struct {
+	Name     string        `json:"name"`
+	Path     string        `json:"path"`
+	Size     lsblkSize     `json:"size"`
+	FSType   string        `json:"fstype"`
+	Label    string        `json:"label"`
+	UUID     string        `json:"uuid"`
+	Type     string        `json:"type"`
+	Children []lsblkDevice `json:"children"`
+}
+
+// parseLsblk turns lsblk -J of an nbd device into its partitions, the device
+// itself counts as the "disk" partition when it holds a filesystem directly.
+func parseLsblk(out []byte) ([]*grpcVirsh.FileRestorePartition, error) {
+	var res struct {
+		BlockDevices []lsblkDevice `json:"blockdevices"`
+	}
+	if err := json.Unmarshal(out, &res); err != nil {
+		return nil, fmt.Errorf("parse lsblk output: %w", err)
+	}
+	if len(res.BlockDevices) != 1 {
+		return nil, fmt.Errorf("lsblk returned %d devices", len(res.BlockDevices))
+	}
+	disk := res.BlockDevices[0]
+	toPartition := func(d lsblkDevice, name string) *grpcVirsh.FileRestorePartition {
+		device := d.Path
+		if device == "" {
+			device = "/dev/" + d.Name
+		}
+		return &grpcVirsh.FileRestorePartition{
+			Name:      name,
+			Device:    device,
+			Size:      int64(d.Size),
+			Fstype:    d.FSType,
+			Label:     d.Label,
+			Uuid:      d.UUID,
+			Mountable: mountableFSTypes[d.FSType],
+		}
+	}
+
+	partitions := []*grpcVirsh.FileRestorePartition{}
+	if len(disk.Children) == 0 {
+		if disk.FSType != "" {
+			partitions = append(partitions, toPartition(disk, "disk"))
+		}
+		return partitions, nil
+	}
+	for _, child := range disk.Children {
+		if child.Type != "part" || !strings.HasPrefix(child.Name, disk.Name+"p") {
+			continue
+		}
+		partitions = append(partitions, toPartition(child, strings.TrimPrefix(child.Name, disk.Name)))
+	}
+	return partitions, nil
+}
+
+// listNBDPartitions waits for the kernel to scan the partition table of
+// device, an image without any filesystem shows nothing until the timeout.
+func listNBDPartitions(ctx context.Context, device string) ([]*grpcVirsh.FileRestorePartition, error) {
+	deadline := time.Now().Add(nbdWaitPartitions)
+	for {
+		_, _ = runCmdContext(ctx, "udevadm", "settle", "--timeout=5")
+		out, err := runCmdContext(ctx, "lsblk", "-J", "-b", "-o", "NAME,PATH,SIZE,FSTYPE,LABEL,UUID,TYPE", device)
+		if err != nil {
+			return nil, err
+		}
+		partitions, err := parseLsblk([]byte(out))
+		if err != nil || len(partitions) > 0 || time.Now().After(deadline) {
+			return partitions, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func sessionDevice(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "device"))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("file restore session %s is not mounted", filepath.Base(dir))
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// MountBackupImage attaches the image of a session and lists its partitions,
+// mounting an already mounted session only lists them again.
+func MountBackupImage(ctx context.Context, req *grpcVirsh.FileRestoreMountRequest) (*grpcVirsh.FileRestoreMountResponse, error) {
+	dir, err := fileRestoreDir(req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeoutOrDefault(req.TimeoutSeconds))
+	defer cancel()
+
+	device, err := sessionDevice(dir)
+	if err != nil {
+		if _, err := os.Stat(req.ImagePath); err != nil {
+			return nil, fmt.Errorf("stat %s: %w", req.ImagePath, err)
+		}
+		if err := os.MkdirAll(filepath.Join(dir, "mnt"), 0o700); err != nil {
+			return nil, err
+		}
+		if device, err = attachNBD(ctx, req.ImagePath); err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "device"), []byte(device), 0o600); err != nil {
+			_ = detachNBD(context.Background(), device)
+			_ = os.RemoveAll(dir)
+			return nil, err
+		}
+		logger.Infof("file restore %s: %s attached to %s", req.SessionId, req.ImagePath, device)
+	}
+
+	partitions, err := listNBDPartitions(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.FileRestoreMountResponse{Partitions: partitions}, nil
+}
+
+// isMountPoint compares the device of path with the one of its parent.
+func isMountPoint(path string) bool {
+	var st, parent syscall.Stat_t
+	if syscall.Stat(path, &st) != nil || syscall.Stat(filepath.Dir(path), &parent) != nil {
+		return false
+	}
+	return st.Dev != parent.Dev
+}
+
+// mountPartition mounts a partition of the session read-only the first time it
+// is browsed and returns where.
+func mountPartition(ctx context.Context, sessionID, partition string) (string, error) {
+	dir, err := fileRestoreDir(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if !fileRestorePartition.MatchString(partition) {
+		return "", fmt.Errorf("invalid partition %q", partition)
+	}
+	target := filepath.Join(dir, "mnt", partition)
+	if isMountPoint(target) {
+		return target, nil
+	}
+
+	device, err := sessionDevice(dir)
+	if err != nil {
+		return "", err
+	}
+	partitions, err := listNBDPartitions(ctx, device)
+	if err != nil {
+		return "", err
+	}
+	var part *grpcVirsh.FileRestorePartition
+	for _, p := range partitions {
+		if p.Name == partition {
+			part = p
+		}
+	}
+	if part == nil {
+		return "", fmt.Errorf("partition %s not found", partition)
+	}
+	if !part.Mountable {
+		return "", fmt.Errorf("partition %s holds %q, which can't be browsed", partition, part.Fstype)
+	}
+
+	if err := os.MkdirAll(target, 0o700); err != nil {
+		return "", err
+	}
+	if _, err := runCmdContext(ctx, "mount", "-t", part.Fstype, "-o", fsMountOptions(part.Fstype), part.Device, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// resolveInside maps p to a path under root without following symlinks, the
+// guest ones point into the guest and would land on the host when followed.
+func resolveInside(root, p string) (string, error) {
+	clean := filepath.Clean("/" + p)
+	current := root
+	if clean == "/" {
+		return current, nil
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(clean, "/"), "/") {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", clean, fs.ErrNotExist)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, _ := os.Readlink(current)
+			return "", fmt.Errorf("%s is a symlink to %s, browse the target instead", clean, target)
+		}
+	}
+	return current, nil
+}
+
+func listDirEntries(dir string) ([]*grpcVirsh.FileRestoreEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*grpcVirsh.FileRestoreEntry, 0, len(dirEntries))
+	for _, e := range dirEntries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		entry := &grpcVirsh.FileRestoreEntry{
+			Name:    e.Name(),
+			Dir:     info.IsDir(),
+			Size:    info.Size(),
+			ModTime: info.ModTime().Unix(),
+			Mode:    info.Mode().String(),
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			entry.LinkTarget, _ = os.Readlink(filepath.Join(dir, e.Name()))
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// BrowseBackupImage lists a directory of a partition of the session.
+func BrowseBackupImage(ctx context.Context, req *grpcVirsh.FileRestoreBrowseRequest) (*grpcVirsh.FileRestoreBrowseResponse, error) {
+	root, err := mountPartition(ctx, req.SessionId, req.Partition)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := resolveInside(root, req.Path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := listDirEntries(dir)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", filepath.Clean("/"+req.Path), err)
+	}
+	return &grpcVirsh.FileRestoreBrowseResponse{Path: filepath.Clean("/" + req.Path), Entries: entries}, nil
+}
+
+// writeTar writes paths of root to w, directories recursively. Symlinks are
+// stored as symlinks, sockets and devices are skipped.
+func writeTar(ctx context.Context, w io.Writer, root string, paths []string) error {
+	tw := tar.NewWriter(w)
+	for _, p := range paths {
+		start, err := resolveInside(root, p)
+		if err != nil {
+			return err
+		}
+		err = filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			mode := info.Mode()
+			if !mode.IsRegular() && !mode.IsDir() && mode&os.ModeSymlink == 0 {
+				return nil
+			}
+			link := ""
+			if mode&os.ModeSymlink != 0 {
+				if link, err = os.Readlink(path); err != nil {
+					return err
+				}
+			}
+			hdr, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			hdr.Name = filepath.ToSlash(rel)
+			if mode.IsDir() {
+				hdr.Name += "/"
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if !mode.IsRegular() {
+				return nil
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.CopyN(tw, f, info.Size())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+type chunkSender func([]byte) error
+
+func (s chunkSender) Write(p []byte) (int, error) {
+	if err := s(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ReadBackupImageFiles sends one file, or a tar of paths, in chunks of
+// fileRestoreChunkSize.
+func ReadBackupImageFiles(ctx context.Context, req *grpcVirsh.FileRestoreReadRequest, send func(*grpcVirsh.FileChunk) error) error {
+	if len(req.Paths) == 0 {
+		return fmt.Errorf("paths are required")
+	}
+	root, err := mountPartition(ctx, req.SessionId, req.Partition)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriterSize(chunkSender(func(p []byte) error {
+		return send(&grpcVirsh.FileChunk{Data: p})
+	}), fileRestoreChunkSize)
+
+	if req.Tar {
+		if err := writeTar(ctx, out, root, req.Paths); err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+
+	if len(req.Paths) != 1 {
+		return fmt.Errorf("only one file can be read without tar")
+	}
+	path, err := resolveInside(root, req.Paths[0])
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file, download it as tar", req.Paths[0])
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(out, f); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// unmountAll unmounts the filesystems of a session, lazily when they are
+// still busy after the timeout.
+func unmountAll(ctx context.Context, dir string) error {
+	mounts, err := os.ReadDir(filepath.Join(dir, "mnt"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, m := range mounts {
+		target := filepath.Join(dir, "mnt", m.Name())
+		if isMountPoint(target) {
+			if _, err := runCmdContext(ctx, "umount", target); err != nil {
+				logger.Warnf("file restore: %v, detaching lazily", err)
+				if _, err := runCmdContext(context.Background(), "umount", "-l", target); err != nil {
+					return err
+				}
+			}
+		}
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func closeFileRestore(ctx context.Context, dir string) error {
+	if err := unmountAll(ctx, dir); err != nil {
+		return err
+	}
+	device, err := sessionDevice(dir)
+	if err == nil {
+		if err := detachNBD(ctx, device); err != nil {
+			return err
+		}
+	}
+	_ = os.Remove(filepath.Join(dir, "mnt"))
+	_ = os.Remove(filepath.Join(dir, "device"))
+	return os.Remove(dir)
+}
+
+// UnmountBackupImage unmounts every filesystem of the session and detaches
+// the image.
+func UnmountBackupImage(ctx context.Context, req *grpcVirsh.FileRestoreSession) error {
+	dir, err := fileRestoreDir(req.SessionId)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeoutOrDefault(req.TimeoutSeconds))
+	defer cancel()
+	if err := closeFileRestore(ctx, dir); err != nil {
+		return err
+	}
+	logger.Infof("file restore %s: unmounted", req.SessionId)
+	return nil
+}
+
+// fileRestoreScratchPrefix starts the name of the scratch image a packed backup
+// is decoded to for a session.
+const fileRestoreScratchPrefix = "files-"
+
+// CleanupFileRestores closes the sessions a previous run of the slave left,
+// the master that opened them forgot them when the connection dropped.
+func CleanupFileRestores() error {
+	sessions, err := os.ReadDir(fileRestoreRoot)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range sessions {
+		ctx, cancel := context.WithTimeout(context.Background(), fileRestoreDefaultTimeout)
+		if err := closeFileRestore(ctx, filepath.Join(fileRestoreRoot, s.Name())); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+		cancel()
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,171 @@
+package virsh
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseLsblk(t *testing.T) {
+	partitioned := []byte(`{"blockdevices": [
+		{"name":"nbd0", "path":"/dev/nbd0", "size":21474836480, "fstype":null, "label":null, "uuid":null, "type":"disk",
+		 "children": [
+			{"name":"nbd0p1", "path":"/dev/nbd0p1", "size":1048576, "fstype":null, "label":null, "uuid":null, "type":"part"},
+			{"name":"nbd0p2", "path":"/dev/nbd0p2", "size":1073741824, "fstype":"xfs", "label":"boot", "uuid":"a1", "type":"part"},
+			{"name":"nbd0p3", "path":"/dev/nbd0p3", "size":20400046080, "fstype":"LVM2_member", "label":null, "uuid":"b2", "type":"part"}
+		 ]}
+	]}`)
+	parts, err := parseLsblk(partitioned)
+	if err != nil {
+		t.Fatalf("parseLsblk returned error: %v", err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 partitions, got %+v", parts)
+	}
+	if p := parts[1]; p.Name != "p2" || p.Device != "/dev/nbd0p2" || p.Fstype != "xfs" || !p.Mountable || p.Size != 1073741824 || p.Label != "boot" {
+		t.Fatalf("unexpected xfs partition %+v", p)
+	}
+	if parts[0].Mountable || parts[2].Mountable {
+		t.Fatalf("bios boot and lvm partitions can't be mounted: %+v", parts)
+	}
+
+	// older util-linux prints sizes as strings and has no path column
+	whole := []byte(`{"blockdevices": [{"name":"nbd1", "size":"10737418240", "fstype":"ext4", "type":"disk"}]}`)
+	parts, err = parseLsblk(whole)
+	if err != nil {
+		t.Fatalf("parseLsblk returned error: %v", err)
+	}
+	if len(parts) != 1 || parts[0].Name != "disk" || parts[0].Device != "/dev/nbd1" || parts[0].Size != 10737418240 || !parts[0].Mountable {
+		t.Fatalf("unexpected whole disk filesystem %+v", parts)
+	}
+
+	if parts, err := parseLsblk([]byte(`{"blockdevices": [{"name":"nbd2", "size":0, "type":"disk"}]}`)); err != nil || len(parts) != 0 {
+		t.Fatalf("an empty image has no partitions, got %+v (%v)", parts, err)
+	}
+}
+
+func TestFileRestoreNames(t *testing.T) {
+	for _, id := range []string{"", "../etc", "a/b", strings.Repeat("a", 65)} {
+		if _, err := fileRestoreDir(id); err == nil {
+			t.Fatalf("expected session id %q to be refused", id)
+		}
+	}
+	if dir, err := fileRestoreDir("0b6c-41"); err != nil || dir != filepath.Join(fileRestoreRoot, "0b6c-41") {
+		t.Fatalf("unexpected dir %q (%v)", dir, err)
+	}
+	for _, p := range []string{"disk", "p1", "p12"} {
+		if !fileRestorePartition.MatchString(p) {
+			t.Fatalf("expected partition %q to be accepted", p)
+		}
+	}
+	for _, p := range []string{"", "nbd0p1", "p1/../..", "1"} {
+		if fileRestorePartition.MatchString(p) {
+			t.Fatalf("expected partition %q to be refused", p)
+		}
+	}
+}
+
+// guestTree builds a small guest filesystem with a symlink pointing to an
+// absolute guest path.
+func guestTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"etc/nginx/nginx.conf":          "worker_processes 4;\n",
+		"etc/nginx/conf.d/default.conf": "server {}\n",
+		"etc/hostname":                  "web\n",
+	} {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("/etc", filepath.Join(root, "etc", "nginx", "host-etc")); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestResolveInside(t *testing.T) {
+	root := guestTree(t)
+	if got, err := resolveInside(root, "/etc/../../etc/nginx"); err != nil || got != filepath.Join(root, "etc", "nginx") {
+		t.Fatalf("expected .. to stop at the filesystem root, got %q (%v)", got, err)
+	}
+	if got, err := resolveInside(root, ""); err != nil || got != root {
+		t.Fatalf("empty path is the root, got %q (%v)", got, err)
+	}
+	if _, err := resolveInside(root, "/etc/nginx/host-etc/passwd"); err == nil || !strings.Contains(err.Error(), "symlink") {
+		t.Fatalf("expected a guest symlink not to be followed to the host, got %v", err)
+	}
+	if _, err := resolveInside(root, "/missing"); err == nil {
+		t.Fatalf("expected an error for a missing path")
+	}
+
+	entries, err := listDirEntries(filepath.Join(root, "etc", "nginx"))
+	if err != nil {
+		t.Fatalf("listDirEntries returned error: %v", err)
+	}
+	byName := map[string]bool{}
+	for _, e := range entries {
+		byName[e.Name] = true
+		if e.Name == "host-etc" && (e.Dir || e.LinkTarget != "/etc") {
+			t.Fatalf("symlink listed as %+v", e)
+		}
+		if e.Name == "conf.d" && !e.Dir {
+			t.Fatalf("directory listed as %+v", e)
+		}
+	}
+	if len(entries) != 3 || !byName["nginx.conf"] {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+}
+
+func TestWriteTar(t *testing.T) {
+	root := guestTree(t)
+	var buf bytes.Buffer
+	if err := writeTar(context.Background(), &buf, root, []string{"/etc/nginx", "/etc/hostname"}); err != nil {
+		t.Fatalf("writeTar returned error: %v", err)
+	}
+
+	tr := tar.NewReader(&buf)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read tar: %v", err)
+		}
+		names = append(names, hdr.Name)
+		switch hdr.Name {
+		case "etc/nginx/nginx.conf":
+			data, _ := io.ReadAll(tr)
+			if string(data) != "worker_processes 4;\n" {
+				t.Fatalf("unexpected content %q", data)
+			}
+		case "etc/nginx/host-etc":
+			if hdr.Typeflag != tar.TypeSymlink || hdr.Linkname != "/etc" {
+				t.Fatalf("symlink stored as %+v", hdr)
+			}
+		}
+	}
+	sort.Strings(names)
+	want := []string{"etc/hostname", "etc/nginx/", "etc/nginx/conf.d/", "etc/nginx/conf.d/default.conf", "etc/nginx/host-etc", "etc/nginx/nginx.conf"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected tar entries\n got %v\nwant %v", names, want)
+	}
+
+	if err := writeTar(context.Background(), io.Discard, root, []string{"/etc/nginx/host-etc/shadow"}); err == nil {
+		t.Fatalf("expected paths through a symlink to be refused")
+	}
+}
@@ -8,15 +8,29 @@ import (
 	"regexp"
 	"slave/extra"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	extraGrpc "github.com/Maruqes/512SvMan/api/proto/extra"
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
 	"github.com/Maruqes/512SvMan/logger"
 	libvirt "libvirt.org/go/libvirt"
 )
 
-// MigrateVM shells out to virsh, allowing ssh configuration via sshpass or LIBVIRT_SSH_OPTS.
-func MigrateVM(opts MigrateOptions, ctx context.Context) error {
+// migrations in progress on this slave, by domain name, so CancelMigration
+// can tell an abort it asked for from a failure
+var migrationCancels sync.Map
+
+const (
+	migrationPollInterval = time.Second
+	// pre-copy passes over guest memory before switching to post-copy
+	postCopyAfterIterations = 3
+)
+
+// MigrateVM live migrates a domain with the libvirt API, ssh is configured via
+// LIBVIRT_SSH_OPTS. progress is called every second with the job stats and
+// once more with the final phase.
+func MigrateVM(opts MigrateOptions, ctx context.Context, progress func(*grpcVirsh.MigrationProgress)) error {
 
 	conn, err := libvirt.NewConnect("qemu:///system")
 	if err != nil {
@@ -56,6 +70,15 @@ func MigrateVM(opts MigrateOptions, ctx context.Context) error {
 	if opts.Timeout < 0 {
 		return fmt.Errorf("timeout must be non-negative")
 	}
+	if opts.PostCopy && !opts.Live {
+		return fmt.Errorf("post-copy needs a live migration")
+	}
+
+	cancelled := &atomic.Bool{}
+	if _, loaded := migrationCancels.LoadOrStore(name, cancelled); loaded {
+		return fmt.Errorf("domain %s is already migrating", name)
+	}
+	defer migrationCancels.Delete(name)
 
 	var sshOpts []string
 	if opts.SSH.SkipHostKeyCheck {
@@ -77,90 +100,175 @@ func MigrateVM(opts MigrateOptions, ctx context.Context) error {
 	if len(sshOpts) > 0 {
 		_ = os.Setenv("LIBVIRT_SSH_OPTS", strings.Join(sshOpts, " "))
 	}
-	identifier := fmt.Sprintf("%s-%d", name, time.Now().Unix())
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(opts.Timeout)*time.Second)
+		defer cancel()
+	}
 
 	logger.Info("Starting libvirt migration (API) from %s to %s for %s", connURI, destURI, name)
 
-	// notify migration start (websocket + push)
-	extra.SendWebsocketMessage("migration started", identifier, extraGrpc.WebSocketsMessageType_MigrateVm)
 	// notify migration start
 	extra.SendNotifications("VM migration started", fmt.Sprintf("Starting migration of %s to %s", name, destURI), "/", false)
 
-	// best-effort live progress using libvirt job info while virsh runs
-	progressCtx, cancelProgress := context.WithCancel(ctx)
-	defer cancelProgress()
-	doneCmd := make(chan struct{})
-	go func(ident string) {
-		ticker := time.NewTicker(time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-progressCtx.Done():
-				return
-			case <-doneCmd:
-				return
-			case <-ticker.C:
-				info, infoErr := dom.GetJobInfo()
-				if infoErr != nil {
-					logger.Error("migration progress (GetJobInfo): %v", infoErr)
-					return
-				}
-
-				pct := int64(-1)
-				if info.MemTotal > 0 {
-					pct = int64(info.MemProcessed * 100 / info.MemTotal)
-				} else if info.DataTotal > 0 {
-					pct = int64(info.DataProcessed * 100 / info.DataTotal)
-				}
-
-				if pct >= 0 {
-					if pct > 100 {
-						pct = 100
-					}
-					msg := fmt.Sprintf("migration progress: %d%%", pct)
-					if err := extra.SendWebsocketMessage(msg, ident, extraGrpc.WebSocketsMessageType_MigrateVm); err != nil {
-						logger.Error("SendWebsocketMessage progress: %v", err)
-					}
-				}
-			}
-		}
-	}(identifier)
-
-	flags := libvirt.MIGRATE_PERSIST_DEST | libvirt.MIGRATE_UNDEFINE_SOURCE | libvirt.MIGRATE_PEER2PEER | libvirt.MIGRATE_TUNNELLED | libvirt.MIGRATE_AUTO_CONVERGE | libvirt.MIGRATE_ABORT_ON_ERROR
+	flags := libvirt.MIGRATE_PERSIST_DEST | libvirt.MIGRATE_UNDEFINE_SOURCE | libvirt.MIGRATE_PEER2PEER | libvirt.MIGRATE_TUNNELLED | libvirt.MIGRATE_ABORT_ON_ERROR
 	if opts.Live {
 		flags |= libvirt.MIGRATE_LIVE
 	}
+	if opts.AutoConverge {
+		flags |= libvirt.MIGRATE_AUTO_CONVERGE
+	}
+	if opts.PostCopy {
+		flags |= libvirt.MIGRATE_POSTCOPY
+	}
+	params := &libvirt.DomainMigrateParameters{}
+	if opts.MaxBandwidthMiB > 0 {
+		params.BandwidthSet = true
+		params.Bandwidth = opts.MaxBandwidthMiB
+	}
 
 	// run migrate respecting context; abort job on cancellation
 	migrateErrCh := make(chan error, 1)
 	go func() {
-		migrateErrCh <- dom.MigrateToURI2(destURI, "", "", flags, "", 0)
+		migrateErrCh <- dom.MigrateToURI3(destURI, params, flags)
 	}()
 
+	ticker := time.NewTicker(migrationPollInterval)
+	defer ticker.Stop()
+	phase := "setup"
 	var errRun error
-	select {
-	case <-ctx.Done():
-		logger.Error("migration context canceled, aborting job")
-		if abortErr := dom.AbortJob(); abortErr != nil {
-			logger.Error("AbortJob failed: %v", abortErr)
+	var last *grpcVirsh.MigrationProgress
+wait:
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Error("migration context canceled, aborting job")
+			cancelled.Store(true)
+			if abortErr := dom.AbortJob(); abortErr != nil {
+				logger.Error("AbortJob failed: %v", abortErr)
+			}
+			errRun = <-migrateErrCh
+			if errRun == nil {
+				// the migration finished before the abort reached it
+				break wait
+			}
+			errRun = ctx.Err()
+			break wait
+		case errRun = <-migrateErrCh:
+			break wait
+		case <-ticker.C:
+			stats, err := dom.GetJobStats(0)
+			if err != nil {
+				logger.Error("migration progress (GetJobStats): %v", err)
+				continue
+			}
+			if stats.Type == libvirt.DOMAIN_JOB_NONE {
+				continue
+			}
+			if phase == "setup" && stats.DataTotalSet {
+				phase = "precopy"
+			}
+			if opts.PostCopy && phase == "precopy" && stats.MemIteration >= postCopyAfterIterations {
+				if err := dom.MigrateStartPostCopy(0); err != nil {
+					logger.Error("MigrateStartPostCopy failed: %v", err)
+				} else {
+					phase = "postcopy"
+				}
+			}
+			last = migrationProgressFromStats(phase, stats)
+			if progress != nil {
+				progress(last)
+			}
 		}
-		errRun = ctx.Err()
-	case errRun = <-migrateErrCh:
 	}
-	close(doneCmd)
+
+	final := &grpcVirsh.MigrationProgress{Phase: "completed", Percent: 100}
+	if stats, err := dom.GetJobStats(libvirt.DOMAIN_JOB_STATS_COMPLETED); err == nil && stats.Type != libvirt.DOMAIN_JOB_NONE {
+		final = migrationProgressFromStats("completed", stats)
+		final.Percent = 100
+	} else if last != nil {
+		final.DataTotal = last.DataTotal
+		final.DataProcessed = last.DataTotal
+		final.TimeElapsedMs = last.TimeElapsedMs
+	}
+	if errRun != nil {
+		final = &grpcVirsh.MigrationProgress{Phase: "failed", Message: errRun.Error()}
+		if cancelled.Load() {
+			final.Phase = "cancelled"
+		}
+		if last != nil {
+			final.Percent = last.Percent
+			final.DataTotal = last.DataTotal
+			final.DataProcessed = last.DataProcessed
+			final.DataRemaining = last.DataRemaining
+			final.TimeElapsedMs = last.TimeElapsedMs
+		}
+	}
+	if progress != nil {
+		progress(final)
+	}
+
 	if errRun != nil {
 		errMsg := errRun.Error()
-		extra.SendNotifications("VM migration failed", fmt.Sprintf("Migration of %s to %s failed: %s", name, destURI, errMsg), "/", true)
+		extra.SendNotifications("VM migration failed", fmt.Sprintf("Migration of %s to %s %s: %s", name, destURI, final.Phase, errMsg), "/", true)
 		return fmt.Errorf("virsh migrate: %s", errMsg)
 	}
 
 	// success
-	extra.SendWebsocketMessage("migration finished", identifier, extraGrpc.WebSocketsMessageType_MigrateVm)
 	extra.SendNotifications("VM migration succeeded", fmt.Sprintf("Migration of %s to %s completed successfully", name, destURI), "/", false)
 
 	return nil
 }
 
+func migrationProgressFromStats(phase string, stats *libvirt.DomainJobInfo) *grpcVirsh.MigrationProgress {
+	progress := &grpcVirsh.MigrationProgress{
+		Phase:         phase,
+		DataTotal:     stats.DataTotal,
+		DataProcessed: stats.DataProcessed,
+		DataRemaining: stats.DataRemaining,
+		MemDirtyRate:  stats.MemDirtyRate,
+		MemBps:        stats.MemBps,
+		MemIteration:  stats.MemIteration,
+		DowntimeMs:    stats.Downtime,
+		TimeElapsedMs: stats.TimeElapsed,
+	}
+	if stats.AutoConvergeThrottleSet {
+		progress.AutoConvergeThrottle = int32(stats.AutoConvergeThrottle)
+	}
+	if stats.DataTotal > 0 {
+		progress.Percent = int32(min(stats.DataProcessed*100/stats.DataTotal, 100))
+	}
+	return progress
+}
+
+// CancelMigration aborts the migration job of a domain migrating away from
+// this slave.
+func CancelMigration(name string) error {
+	value, ok := migrationCancels.Load(name)
+	if !ok {
+		return fmt.Errorf("domain %s is not migrating", name)
+	}
+
+	conn, err := libvirt.NewConnect("qemu:///system")
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	dom, err := conn.LookupDomainByName(name)
+	if err != nil {
+		return fmt.Errorf("lookup domain: %w", err)
+	}
+	defer dom.Free()
+
+	value.(*atomic.Bool).Store(true)
+	if err := dom.AbortJob(); err != nil {
+		return fmt.Errorf("abort migration of %s: %w", name, err)
+	}
+	return nil
+}
+
 func extractDiskPath(xmlDesc string) string {
 	startTag := "<source file='"
 	endTag := "'"
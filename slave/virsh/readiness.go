@@ -0,0 +1,59 @@
+package virsh
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	libvirt "libvirt.org/go/libvirt"
+)
+
+const guestAgentPingTimeout = 5 // seconds
+
+// GuestAgentPing succeeds once the qemu guest agent inside the domain answers.
+func GuestAgentPing(vmName string) error {
+	vmName = strings.TrimSpace(vmName)
+	if vmName == "" {
+		return fmt.Errorf("vm name is empty")
+	}
+
+	conn, err := libvirt.NewConnect("qemu:///system")
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	dom, err := conn.LookupDomainByName(vmName)
+	if err != nil {
+		return fmt.Errorf("lookup: %w", err)
+	}
+	defer dom.Free()
+
+	if _, err := dom.QemuAgentCommand(`{"execute":"guest-ping"}`, guestAgentPingTimeout, 0); err != nil {
+		return fmt.Errorf("guest agent of %s: %w", vmName, err)
+	}
+	return nil
+}
+
+// CheckTCPPort dials host:port from this slave, vms on host-only networks are
+// reachable from here but not from the master.
+func CheckTCPPort(host string, port int, timeout time.Duration) error {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return fmt.Errorf("host is empty")
+	}
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	}
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+
+	c, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), timeout)
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
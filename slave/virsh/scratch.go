@@ -0,0 +1,140 @@
+package virsh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+)
+
+// scratchRoot holds images the master decoded for this slave only, backups
+// being verified or browsed. It is on local disk so nothing half written ever
+// shows up on a share, and it is emptied when the slave starts.
+var scratchRoot = "/var/lib/512svman/scratch"
+
+var scratchImageName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+func scratchPath(name string) (string, error) {
+	if !scratchImageName.MatchString(name) {
+		return "", fmt.Errorf("invalid scratch image name %q", name)
+	}
+	return filepath.Join(scratchRoot, name), nil
+}
+
+// WriteScratchImage stores the streamed chunks as a new scratch image owned
+// by qemu, a partial file is removed when the stream fails.
+func WriteScratchImage(recv func() (*grpcVirsh.ScratchChunk, error)) (*grpcVirsh.ScratchImage, error) {
+	first, err := recv()
+	if err != nil {
+		return nil, fmt.Errorf("receive first chunk: %w", err)
+	}
+	path, err := scratchPath(first.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(scratchRoot, 0711); err != nil {
+		return nil, fmt.Errorf("create scratch folder: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", path, err)
+	}
+	if err := writeScratchChunks(f, first, recv); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("close %s: %w", path, err)
+	}
+	if err := chownScratch(path); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return &grpcVirsh.ScratchImage{Name: first.GetName(), Path: path}, nil
+}
+
+// chownScratch hands a scratch file to qemu, unlike ensureDiskPermissions it
+// leaves the mode and the folders above alone.
+func chownScratch(path string) error {
+	uid, gid, err := qemuOwner()
+	if err != nil {
+		return err
+	}
+	if err := os.Chown(path, uid, gid); err != nil {
+		return fmt.Errorf("chown %s: %w", path, err)
+	}
+	return nil
+}
+
+func writeScratchChunks(w io.Writer, chunk *grpcVirsh.ScratchChunk, recv func() (*grpcVirsh.ScratchChunk, error)) error {
+	for {
+		if _, err := w.Write(chunk.GetData()); err != nil {
+			return fmt.Errorf("write scratch image: %w", err)
+		}
+		var err error
+		chunk, err = recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("receive chunk: %w", err)
+		}
+	}
+}
+
+func RemoveScratchImage(name string) error {
+	path, err := scratchPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// RemoveScratchImages removes every image whose name starts with prefix.
+func RemoveScratchImages(prefix string) error {
+	entries, err := os.ReadDir(scratchRoot)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), prefix) {
+			if err := os.Remove(filepath.Join(scratchRoot, e.Name())); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CleanupScratch removes the images a previous run of the slave left, run it
+// after CleanupFileRestores so no session still has one attached.
+func CleanupScratch() error {
+	entries, err := os.ReadDir(scratchRoot)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(scratchRoot, e.Name())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,86 @@
+package virsh
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+)
+
+func chunkStream(chunks []*grpcVirsh.ScratchChunk, end error) func() (*grpcVirsh.ScratchChunk, error) {
+	return func() (*grpcVirsh.ScratchChunk, error) {
+		if len(chunks) == 0 {
+			return nil, end
+		}
+		chunk := chunks[0]
+		chunks = chunks[1:]
+		return chunk, nil
+	}
+}
+
+func TestWriteScratchImage(t *testing.T) {
+	original := scratchRoot
+	scratchRoot = filepath.Join(t.TempDir(), "scratch")
+	t.Cleanup(func() { scratchRoot = original })
+
+	image, err := WriteScratchImage(chunkStream([]*grpcVirsh.ScratchChunk{
+		{Name: "verify-1-2.qcow2", Data: []byte("abc")},
+		{Data: []byte("def")},
+	}, io.EOF))
+	if err != nil {
+		t.Fatalf("WriteScratchImage returned error: %v", err)
+	}
+	data, err := os.ReadFile(image.Path)
+	if err != nil || string(data) != "abcdef" {
+		t.Fatalf("image holds %q, %v", data, err)
+	}
+	if info, _ := os.Stat(image.Path); info.Mode().Perm() != 0600 {
+		t.Fatalf("scratch image mode is %v", info.Mode().Perm())
+	}
+
+	if _, err := WriteScratchImage(chunkStream([]*grpcVirsh.ScratchChunk{{Name: "verify-1-2.qcow2"}}, io.EOF)); err == nil {
+		t.Fatalf("expected an existing image to be refused")
+	}
+
+	broken := errors.New("stream reset")
+	if _, err := WriteScratchImage(chunkStream([]*grpcVirsh.ScratchChunk{{Name: "half.qcow2", Data: []byte("a")}}, broken)); !errors.Is(err, broken) {
+		t.Fatalf("expected the stream error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(scratchRoot, "half.qcow2")); !os.IsNotExist(err) {
+		t.Fatalf("a failed stream must not leave a partial image: %v", err)
+	}
+
+	for _, name := range []string{"", "..", "../etc/passwd", ".hidden", "a/b"} {
+		if _, err := scratchPath(name); err == nil {
+			t.Fatalf("expected scratch name %q to be refused", name)
+		}
+	}
+
+	if err := RemoveScratchImage(image.Name); err != nil {
+		t.Fatalf("RemoveScratchImage returned error: %v", err)
+	}
+	if err := RemoveScratchImage(image.Name); err != nil {
+		t.Fatalf("removing a missing image should not fail: %v", err)
+	}
+
+	for _, name := range []string{"files-a.qcow2", "files-b.qcow2", "left.qcow2"} {
+		if err := os.WriteFile(filepath.Join(scratchRoot, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RemoveScratchImages(fileRestoreScratchPrefix); err != nil {
+		t.Fatalf("RemoveScratchImages returned error: %v", err)
+	}
+	if entries, _ := os.ReadDir(scratchRoot); len(entries) != 1 || entries[0].Name() != "left.qcow2" {
+		t.Fatalf("only the file restore images should be removed: %v", entries)
+	}
+	if err := CleanupScratch(); err != nil {
+		t.Fatalf("CleanupScratch returned error: %v", err)
+	}
+	if entries, _ := os.ReadDir(scratchRoot); len(entries) != 0 {
+		t.Fatalf("scratch folder not emptied: %v", entries)
+	}
+}
@@ -3,10 +3,12 @@ package virsh
 import (
 	"context"
 	"strconv"
+	"time"
 
 	"slave/env512"
 
 	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
 )
 
 type SlaveVirshService struct {
@@ -61,6 +63,7 @@ func (s *SlaveVirshService) CreateVm(ctx context.Context, req *grpcVirsh.CreateV
 		CPUXml:         req.CpuXml,
 		IsWindows:      req.IsWindows,
 		VirtioISOPath:  env512.VirtioISOPath,
+		CloudInit:      cloudInitFromProto(req.CloudInit),
 	}
 	_, err := CreateVMCustomCPU(params)
 	if err != nil {
@@ -70,7 +73,7 @@ func (s *SlaveVirshService) CreateVm(ctx context.Context, req *grpcVirsh.CreateV
 	return &grpcVirsh.OkResponse{Ok: true}, nil
 }
 
-func (s *SlaveVirshService) MigrateVM(ctx context.Context, e *grpcVirsh.MigrateVmRequest) (*grpcVirsh.OkResponse, error) {
+func (s *SlaveVirshService) MigrateVM(e *grpcVirsh.MigrateVmRequest, stream grpcVirsh.SlaveVirshService_MigrateVMServer) error {
 	opts := MigrateOptions{
 		ConnURI: "qemu:///system",
 		Name:    e.Name,
@@ -81,13 +84,23 @@ func (s *SlaveVirshService) MigrateVM(ctx context.Context, e *grpcVirsh.MigrateV
 			SkipHostKeyCheck:   true,
 			UserKnownHostsFile: "/dev/null",
 		},
-		Timeout: e.TimeoutSeconds,
-	}
-	err := MigrateVM(opts, ctx)
-	if err != nil {
+		Timeout:         e.TimeoutSeconds,
+		MaxBandwidthMiB: e.MaxBandwidthMib,
+		AutoConverge:    e.AutoConverge,
+		PostCopy:        e.PostCopy,
+	}
+	return MigrateVM(opts, stream.Context(), func(progress *grpcVirsh.MigrationProgress) {
+		if err := stream.Send(progress); err != nil {
+			logger.Error("send migration progress: %v", err)
+		}
+	})
+}
+
+func (s *SlaveVirshService) CancelMigration(ctx context.Context, e *grpcVirsh.CancelMigrationRequest) (*grpcVirsh.OkResponse, error) {
+	if err := CancelMigration(e.Name); err != nil {
 		return nil, err
 	}
-	return &grpcVirsh.OkResponse{}, nil
+	return &grpcVirsh.OkResponse{Ok: true}, nil
 }
 
 func (s *SlaveVirshService) ColdMigrateVm(ctx context.Context, e *grpcVirsh.ColdMigrationRequest) (*grpcVirsh.OkResponse, error) {
@@ -124,6 +137,14 @@ func (s *SlaveVirshService) UpdateVMXml(ctx context.Context, e *grpcVirsh.Update
 	return &grpcVirsh.OkResponse{}, nil
 }
 
+func (s *SlaveVirshService) DefineVMFromXML(ctx context.Context, e *grpcVirsh.DefineVMFromXMLRequest) (*grpcVirsh.OkResponse, error) {
+	err := DefineVMFromXML(e.Name, e.VmXML, e.Start)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.OkResponse{}, nil
+}
+
 func (s *SlaveVirshService) AttachExternalDisk(ctx context.Context, req *grpcVirsh.ExternalDiskRequest) (*grpcVirsh.ExternalDiskResponse, error) {
 	targetDev, err := AttachExternalDisk(req.VmName, req.DiskPath, req.Format)
 	if err != nil {
@@ -156,6 +177,10 @@ func (s *SlaveVirshService) GetVmByName(ctx context.Context, req *grpcVirsh.GetV
 	return vm, nil
 }
 
+func (s *SlaveVirshService) GetVmStats(ctx context.Context, req *grpcVirsh.Empty) (*grpcVirsh.VmStatsResponse, error) {
+	return GetVmStats()
+}
+
 func (s *SlaveVirshService) ShutdownVM(ctx context.Context, req *grpcVirsh.Vm) (*grpcVirsh.OkResponse, error) {
 	if err := ShutdownVM(req.Name); err != nil {
 		return nil, err
@@ -183,6 +208,13 @@ func (s *SlaveVirshService) UndefineVM(ctx context.Context, req *grpcVirsh.Vm) (
 	return &grpcVirsh.OkResponse{Ok: true}, nil
 }
 
+func (s *SlaveVirshService) UndefineStaleVM(ctx context.Context, req *grpcVirsh.Vm) (*grpcVirsh.OkResponse, error) {
+	if err := UndefineStaleVm(req.Name); err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.OkResponse{Ok: true}, nil
+}
+
 func (s *SlaveVirshService) ForceShutdownVM(ctx context.Context, req *grpcVirsh.Vm) (*grpcVirsh.OkResponse, error) {
 	if err := ForceShutdownVM(req.Name); err != nil {
 		return nil, err
@@ -260,6 +292,20 @@ func (s *SlaveVirshService) UnFreezeDisk(ctx context.Context, req *grpcVirsh.Vm)
 	return &grpcVirsh.OkResponse{Ok: true}, nil
 }
 
+func (s *SlaveVirshService) GuestAgentPing(ctx context.Context, req *grpcVirsh.GetVmByNameRequest) (*grpcVirsh.OkResponse, error) {
+	if err := GuestAgentPing(req.Name); err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.OkResponse{Ok: true}, nil
+}
+
+func (s *SlaveVirshService) CheckTCPPort(ctx context.Context, req *grpcVirsh.TCPPortRequest) (*grpcVirsh.OkResponse, error) {
+	if err := CheckTCPPort(req.Host, int(req.Port), time.Duration(req.TimeoutMs)*time.Millisecond); err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.OkResponse{Ok: true}, nil
+}
+
 func (s *SlaveVirshService) ApplyCPUPinning(ctx context.Context, req *grpcVirsh.CPUPinningRequest) (*grpcVirsh.OkResponse, error) {
 	config := CPUPinningConfig{
 		RangeStart:     int(req.RangeStart),
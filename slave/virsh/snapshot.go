@@ -0,0 +1,570 @@
+package virsh
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+	libvirt "libvirt.org/go/libvirt"
+)
+
+// snapshotCommitTimeout bounds merging the overlay of a running disk, a guest
+// that writes faster than the commit copies would never let it pivot.
+var snapshotCommitTimeout = 30 * time.Minute
+
+type SnapshotOptions struct {
+	VmName        string
+	Name          string
+	Description   string
+	DiskOnly      bool // external overlay per disk instead of an internal qcow2 snapshot
+	IncludeMemory bool // save guest RAM so the VM comes back running on revert
+	Quiesce       bool // fsfreeze through the guest agent while the snapshot is taken
+}
+
+type snapshotDomainXML struct {
+	XMLName      xml.Name             `xml:"domainsnapshot"`
+	Name         string               `xml:"name"`
+	Description  string               `xml:"description,omitempty"`
+	State        string               `xml:"state,omitempty"`
+	CreationTime int64                `xml:"creationTime,omitempty"`
+	Parent       *snapshotParentXML   `xml:"parent,omitempty"`
+	Memory       *snapshotMemoryXML   `xml:"memory,omitempty"`
+	Disks        *snapshotDiskListXML `xml:"disks,omitempty"`
+}
+
+type snapshotParentXML struct {
+	Name string `xml:"name"`
+}
+
+type snapshotMemoryXML struct {
+	Snapshot string `xml:"snapshot,attr"`
+	File     string `xml:"file,attr,omitempty"`
+}
+
+type snapshotDiskListXML struct {
+	Disks []snapshotDiskXML `xml:"disk"`
+}
+
+type snapshotDiskXML struct {
+	Name     string             `xml:"name,attr"`
+	Snapshot string             `xml:"snapshot,attr,omitempty"`
+	Driver   *snapshotDriverXML `xml:"driver,omitempty"`
+	Source   *snapshotSourceXML `xml:"source,omitempty"`
+}
+
+type snapshotDriverXML struct {
+	Type string `xml:"type,attr"`
+}
+
+type snapshotSourceXML struct {
+	File string `xml:"file,attr"`
+}
+
+type snapshotTargetDisk struct {
+	Target string
+	File   string
+}
+
+// snapshotDisks returns every file-backed <disk device='disk'> of the domain,
+// cdroms and block devices are skipped because they can't carry a snapshot.
+func snapshotDisks(xmlDesc string) ([]snapshotTargetDisk, error) {
+	var d struct {
+		Devices struct {
+			Disks []struct {
+				Device string `xml:"device,attr"`
+				Source struct {
+					File string `xml:"file,attr"`
+				} `xml:"source"`
+				Target struct {
+					Dev string `xml:"dev,attr"`
+				} `xml:"target"`
+			} `xml:"disk"`
+		} `xml:"devices"`
+	}
+	if err := xml.Unmarshal([]byte(xmlDesc), &d); err != nil {
+		return nil, fmt.Errorf("unmarshal domain xml: %w", err)
+	}
+
+	var disks []snapshotTargetDisk
+	for _, disk := range d.Devices.Disks {
+		if disk.Device != "disk" {
+			continue
+		}
+		target := strings.TrimSpace(disk.Target.Dev)
+		file := strings.TrimSpace(disk.Source.File)
+		if target == "" || file == "" {
+			continue
+		}
+		disks = append(disks, snapshotTargetDisk{Target: target, File: file})
+	}
+	return disks, nil
+}
+
+// external overlay path: /mnt/share/vm/vm.qcow2 -> /mnt/share/vm/vm.<snapshot>.qcow2
+func externalSnapshotPath(diskPath, snapshotName string) string {
+	dir := filepath.Dir(diskPath)
+	base := strings.TrimSuffix(filepath.Base(diskPath), filepath.Ext(diskPath))
+	return filepath.Join(dir, base+"."+snapshotName+".qcow2")
+}
+
+func validateSnapshotName(name string) error {
+	if name == "" {
+		return fmt.Errorf("snapshot name is required")
+	}
+	if strings.ContainsAny(name, "/\\ \t\n'\"<>&") {
+		return fmt.Errorf("snapshot name %q contains invalid characters", name)
+	}
+	return nil
+}
+
+func buildSnapshotXML(opts SnapshotOptions, disks []snapshotTargetDisk, running bool) (string, libvirt.DomainSnapshotCreateFlags, error) {
+	doc := snapshotDomainXML{
+		Name:        opts.Name,
+		Description: opts.Description,
+	}
+	flags := libvirt.DOMAIN_SNAPSHOT_CREATE_ATOMIC
+
+	if !opts.DiskOnly {
+		// qemu can only take an internal snapshot of a running guest together with its memory
+		if opts.IncludeMemory && !running {
+			return "", 0, fmt.Errorf("memory state can only be saved for a running vm")
+		}
+		if running && !opts.IncludeMemory {
+			return "", 0, fmt.Errorf("internal snapshots of a running vm always include memory, use disk_only or include_memory")
+		}
+		out, err := xml.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return "", 0, err
+		}
+		return string(out), flags, nil
+	}
+
+	if len(disks) == 0 {
+		return "", 0, fmt.Errorf("vm has no file-backed disks to snapshot")
+	}
+
+	doc.Disks = &snapshotDiskListXML{}
+	for _, disk := range disks {
+		doc.Disks.Disks = append(doc.Disks.Disks, snapshotDiskXML{
+			Name:     disk.Target,
+			Snapshot: "external",
+			Driver:   &snapshotDriverXML{Type: "qcow2"},
+			Source:   &snapshotSourceXML{File: externalSnapshotPath(disk.File, opts.Name)},
+		})
+	}
+
+	switch {
+	case opts.IncludeMemory && !running:
+		return "", 0, fmt.Errorf("memory state can only be saved for a running vm")
+	case opts.IncludeMemory:
+		memFile := strings.TrimSuffix(externalSnapshotPath(disks[0].File, opts.Name), ".qcow2") + ".mem"
+		doc.Memory = &snapshotMemoryXML{Snapshot: "external", File: memFile}
+		flags |= libvirt.DOMAIN_SNAPSHOT_CREATE_LIVE
+	default:
+		doc.Memory = &snapshotMemoryXML{Snapshot: "no"}
+		flags |= libvirt.DOMAIN_SNAPSHOT_CREATE_DISK_ONLY
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", 0, err
+	}
+	return string(out), flags, nil
+}
+
+func CreateSnapshot(opts SnapshotOptions) (*grpcVirsh.SnapshotInfo, error) {
+	opts.VmName = strings.TrimSpace(opts.VmName)
+	opts.Name = strings.TrimSpace(opts.Name)
+	opts.Description = strings.TrimSpace(opts.Description)
+	if opts.VmName == "" {
+		return nil, fmt.Errorf("vm name is empty")
+	}
+	if err := validateSnapshotName(opts.Name); err != nil {
+		return nil, err
+	}
+
+	conn, err := libvirt.NewConnect("qemu:///system")
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	dom, err := conn.LookupDomainByName(opts.VmName)
+	if err != nil {
+		return nil, fmt.Errorf("lookup: %w", err)
+	}
+	defer dom.Free()
+
+	if existing, err := dom.SnapshotLookupByName(opts.Name, 0); err == nil {
+		existing.Free()
+		return nil, fmt.Errorf("snapshot %s already exists for vm %s", opts.Name, opts.VmName)
+	}
+
+	state, _, err := dom.GetState()
+	if err != nil {
+		return nil, fmt.Errorf("state: %w", err)
+	}
+	running := state == libvirt.DOMAIN_RUNNING || state == libvirt.DOMAIN_BLOCKED || state == libvirt.DOMAIN_PAUSED
+
+	xmlDesc, err := dom.GetXMLDesc(0)
+	if err != nil {
+		return nil, fmt.Errorf("get xml: %w", err)
+	}
+	disks, err := snapshotDisks(xmlDesc)
+	if err != nil {
+		return nil, err
+	}
+
+	snapXML, flags, err := buildSnapshotXML(opts, disks, running)
+	if err != nil {
+		return nil, err
+	}
+
+	// a memory snapshot already captures a consistent guest, freezing only helps disk state
+	if opts.Quiesce && running && !opts.IncludeMemory {
+		if err := FreezeDisk(opts.VmName); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := UnFreezeDisk(opts.VmName); err != nil {
+				logger.Errorf("snapshot %s: cannot unfreeze vm %s: %v", opts.Name, opts.VmName, err)
+			}
+		}()
+	}
+
+	snap, err := dom.CreateSnapshotXML(snapXML, flags)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot: %w", err)
+	}
+	defer snap.Free()
+
+	return snapshotInfo(snap)
+}
+
+func snapshotInfo(snap *libvirt.DomainSnapshot) (*grpcVirsh.SnapshotInfo, error) {
+	desc, err := snap.GetXMLDesc(0)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot xml: %w", err)
+	}
+
+	var doc snapshotDomainXML
+	if err := xml.Unmarshal([]byte(desc), &doc); err != nil {
+		return nil, fmt.Errorf("unmarshal snapshot xml: %w", err)
+	}
+
+	current, err := snap.IsCurrent(0)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot current: %w", err)
+	}
+
+	info := &grpcVirsh.SnapshotInfo{
+		Name:         doc.Name,
+		Description:  doc.Description,
+		State:        doc.State,
+		CreationTime: doc.CreationTime,
+		Current:      current,
+	}
+	if doc.Parent != nil {
+		info.Parent = doc.Parent.Name
+	}
+	if doc.Memory != nil {
+		info.HasMemory = doc.Memory.Snapshot != "" && doc.Memory.Snapshot != "no"
+		info.External = doc.Memory.Snapshot == "external"
+	}
+	if doc.Disks != nil {
+		for _, disk := range doc.Disks.Disks {
+			if disk.Snapshot == "external" {
+				info.External = true
+				break
+			}
+		}
+	}
+	// internal snapshot of a running vm keeps memory inside the qcow2
+	if doc.Memory != nil && doc.Memory.Snapshot == "internal" {
+		info.HasMemory = true
+	}
+	return info, nil
+}
+
+func ListSnapshots(vmName string) ([]*grpcVirsh.SnapshotInfo, error) {
+	vmName = strings.TrimSpace(vmName)
+	if vmName == "" {
+		return nil, fmt.Errorf("vm name is empty")
+	}
+
+	conn, err := libvirt.NewConnect("qemu:///system")
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	dom, err := conn.LookupDomainByName(vmName)
+	if err != nil {
+		return nil, fmt.Errorf("lookup: %w", err)
+	}
+	defer dom.Free()
+
+	snaps, err := dom.ListAllSnapshots(0)
+	if err != nil {
+		return nil, fmt.Errorf("list snapshots: %w", err)
+	}
+
+	out := make([]*grpcVirsh.SnapshotInfo, 0, len(snaps))
+	for i := range snaps {
+		info, err := snapshotInfo(&snaps[i])
+		snaps[i].Free()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, info)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreationTime < out[j].CreationTime
+	})
+	return out, nil
+}
+
+func RevertSnapshot(vmName, name string) error {
+	vmName = strings.TrimSpace(vmName)
+	name = strings.TrimSpace(name)
+	if vmName == "" {
+		return fmt.Errorf("vm name is empty")
+	}
+	if name == "" {
+		return fmt.Errorf("snapshot name is empty")
+	}
+
+	conn, err := libvirt.NewConnect("qemu:///system")
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	dom, err := conn.LookupDomainByName(vmName)
+	if err != nil {
+		return fmt.Errorf("lookup: %w", err)
+	}
+	defer dom.Free()
+
+	snap, err := dom.SnapshotLookupByName(name, 0)
+	if err != nil {
+		return fmt.Errorf("lookup snapshot %s: %w", name, err)
+	}
+	defer snap.Free()
+
+	// FORCE lets libvirt revert a running guest to a disk-only snapshot (guest is restarted)
+	if err := snap.RevertToSnapshot(libvirt.DOMAIN_SNAPSHOT_REVERT_FORCE); err != nil {
+		return fmt.Errorf("revert snapshot %s: %w", name, err)
+	}
+	return nil
+}
+
+func DeleteSnapshot(ctx context.Context, vmName, name string) error {
+	vmName = strings.TrimSpace(vmName)
+	name = strings.TrimSpace(name)
+	if vmName == "" {
+		return fmt.Errorf("vm name is empty")
+	}
+	if name == "" {
+		return fmt.Errorf("snapshot name is empty")
+	}
+
+	conn, err := libvirt.NewConnect("qemu:///system")
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	dom, err := conn.LookupDomainByName(vmName)
+	if err != nil {
+		return fmt.Errorf("lookup: %w", err)
+	}
+	defer dom.Free()
+
+	snap, err := dom.SnapshotLookupByName(name, 0)
+	if err != nil {
+		return fmt.Errorf("lookup snapshot %s: %w", name, err)
+	}
+	defer snap.Free()
+
+	desc, err := snap.GetXMLDesc(0)
+	if err != nil {
+		return fmt.Errorf("snapshot xml: %w", err)
+	}
+	var doc snapshotDomainXML
+	if err := xml.Unmarshal([]byte(desc), &doc); err != nil {
+		return fmt.Errorf("unmarshal snapshot xml: %w", err)
+	}
+	overlays, memFile := externalSnapshotFiles(doc)
+	if len(overlays) == 0 && memFile == "" {
+		if err := snap.Delete(0); err != nil {
+			return fmt.Errorf("delete snapshot %s: %w", name, err)
+		}
+		return nil
+	}
+	return deleteExternalSnapshot(ctx, conn, dom, snap, name, overlays, memFile)
+}
+
+// externalSnapshotFiles returns the overlays (disk target -> file) and the
+// memory file an external snapshot created.
+func externalSnapshotFiles(doc snapshotDomainXML) ([]snapshotTargetDisk, string) {
+	var overlays []snapshotTargetDisk
+	if doc.Disks != nil {
+		for _, disk := range doc.Disks.Disks {
+			if disk.Snapshot != "external" || disk.Source == nil || disk.Source.File == "" {
+				continue
+			}
+			overlays = append(overlays, snapshotTargetDisk{Target: disk.Name, File: filepath.Clean(disk.Source.File)})
+		}
+	}
+	memFile := ""
+	if doc.Memory != nil && doc.Memory.Snapshot == "external" {
+		memFile = doc.Memory.File
+	}
+	return overlays, memFile
+}
+
+// deleteExternalSnapshot merges the overlays of the newest external snapshot
+// back into their backing files, libvirt before 9.0 can't delete external
+// snapshots and newer versions leave the memory file behind. Only the leaf
+// snapshot the disks still run on can be merged, anything else is refused
+// instead of leaving the chain half committed.
+func deleteExternalSnapshot(ctx context.Context, conn *libvirt.Connect, dom *libvirt.Domain, snap *libvirt.DomainSnapshot, name string, overlays []snapshotTargetDisk, memFile string) error {
+	children, err := snap.NumChildren(0)
+	if err != nil {
+		return fmt.Errorf("snapshot children: %w", err)
+	}
+	if children > 0 {
+		return fmt.Errorf("external snapshot %s has %d newer snapshot(s), delete them first", name, children)
+	}
+
+	state, _, err := dom.GetState()
+	if err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	running := state == libvirt.DOMAIN_RUNNING || state == libvirt.DOMAIN_BLOCKED || state == libvirt.DOMAIN_PAUSED
+
+	xmlDesc, err := dom.GetXMLDesc(0)
+	if err != nil {
+		return fmt.Errorf("get xml: %w", err)
+	}
+	disks, err := snapshotDisks(xmlDesc)
+	if err != nil {
+		return err
+	}
+	active := make(map[string]string, len(disks))
+	for _, disk := range disks {
+		active[disk.Target] = filepath.Clean(disk.File)
+	}
+	bases := make([]string, len(overlays))
+	for i, overlay := range overlays {
+		if active[overlay.Target] != overlay.File {
+			return fmt.Errorf("disk %s no longer runs on the overlay of external snapshot %s, it can't be merged, remove %s by hand", overlay.Target, name, overlay.File)
+		}
+		info, err := readQemuImgInfo(overlay.File)
+		if err != nil {
+			return err
+		}
+		if info.FullBackingFilename == "" {
+			return fmt.Errorf("overlay %s of snapshot %s has no backing file", overlay.File, name)
+		}
+		bases[i] = info.FullBackingFilename
+	}
+
+	for i, overlay := range overlays {
+		if running {
+			err = commitActiveOverlay(ctx, dom, overlay.Target)
+		} else {
+			err = commitOverlay(overlay.File)
+		}
+		if err != nil {
+			return fmt.Errorf("merge snapshot %s of disk %s: %w", name, overlay.Target, err)
+		}
+		if err := syncInactiveDiskSource(conn, dom, overlay.File, bases[i]); err != nil {
+			return fmt.Errorf("merge snapshot %s of disk %s: %w", name, overlay.Target, err)
+		}
+	}
+
+	if err := snap.Delete(libvirt.DOMAIN_SNAPSHOT_DELETE_METADATA_ONLY); err != nil {
+		return fmt.Errorf("delete snapshot %s metadata: %w", name, err)
+	}
+	for _, overlay := range overlays {
+		if err := os.Remove(overlay.File); err != nil && !os.IsNotExist(err) {
+			logger.Warnf("snapshot %s: remove overlay %s: %v", name, overlay.File, err)
+		}
+	}
+	if memFile != "" {
+		if err := os.Remove(memFile); err != nil && !os.IsNotExist(err) {
+			logger.Warnf("snapshot %s: remove memory file %s: %v", name, memFile, err)
+		}
+	}
+	return nil
+}
+
+// commitActiveOverlay block commits the active layer of a running disk into
+// its backing file and pivots the domain onto it.
+func commitActiveOverlay(ctx context.Context, dom *libvirt.Domain, target string) error {
+	if err := dom.BlockCommit(target, "", "", 0, libvirt.DOMAIN_BLOCK_COMMIT_ACTIVE); err != nil {
+		return fmt.Errorf("block commit: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, snapshotCommitTimeout)
+	defer cancel()
+	return waitBlockCommitPivot(ctx, dom, target)
+}
+
+// waitBlockCommitPivot waits for the commit of target to catch up and pivots
+// onto the backing file. When ctx ends first the job is aborted, the disk
+// keeps running on the overlay and nothing is lost.
+func waitBlockCommitPivot(ctx context.Context, job blockCopyJob, target string) error {
+	abort := func(cause error) error {
+		if err := job.BlockJobAbort(target, 0); err != nil {
+			logger.Errorf("block commit %s: abort job: %v", target, err)
+		}
+		return cause
+	}
+
+	ticker := time.NewTicker(blockCopyPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return abort(fmt.Errorf("block commit did not finish: %w", ctx.Err()))
+		case <-ticker.C:
+		}
+
+		info, err := job.GetBlockJobInfo(target, 0)
+		if err != nil {
+			return abort(fmt.Errorf("block job info: %w", err))
+		}
+		if info.Type == 0 && info.End == 0 {
+			return fmt.Errorf("block commit job disappeared before the pivot")
+		}
+		if info.End == 0 || info.Cur != info.End {
+			continue
+		}
+		if err := job.BlockJobAbort(target, libvirt.DOMAIN_BLOCK_JOB_ABORT_PIVOT); err != nil {
+			logger.Warnf("block commit %s: pivot not possible yet: %v", target, err)
+			continue
+		}
+		return nil
+	}
+}
+
+// commitOverlay merges the overlay of a shut off disk into its backing file,
+// the overlay is deleted afterwards so it isn't emptied.
+func commitOverlay(overlay string) error {
+	cmd := exec.Command("qemu-img", "commit", "-d", overlay)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("qemu-img commit %s: %s", overlay, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
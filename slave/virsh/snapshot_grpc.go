@@ -0,0 +1,40 @@
+package virsh
+
+import (
+	"context"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+)
+
+func (s *SlaveVirshService) CreateSnapshot(ctx context.Context, req *grpcVirsh.CreateSnapshotRequest) (*grpcVirsh.SnapshotInfo, error) {
+	return CreateSnapshot(SnapshotOptions{
+		VmName:        req.VmName,
+		Name:          req.Name,
+		Description:   req.Description,
+		DiskOnly:      req.DiskOnly,
+		IncludeMemory: req.IncludeMemory,
+		Quiesce:       req.Quiesce,
+	})
+}
+
+func (s *SlaveVirshService) ListSnapshots(ctx context.Context, req *grpcVirsh.GetVmByNameRequest) (*grpcVirsh.ListSnapshotsResponse, error) {
+	snaps, err := ListSnapshots(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.ListSnapshotsResponse{Snapshots: snaps}, nil
+}
+
+func (s *SlaveVirshService) RevertSnapshot(ctx context.Context, req *grpcVirsh.SnapshotRequest) (*grpcVirsh.OkResponse, error) {
+	if err := RevertSnapshot(req.VmName, req.Name); err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.OkResponse{Ok: true}, nil
+}
+
+func (s *SlaveVirshService) DeleteSnapshot(ctx context.Context, req *grpcVirsh.SnapshotRequest) (*grpcVirsh.OkResponse, error) {
+	if err := DeleteSnapshot(ctx, req.VmName, req.Name); err != nil {
+		return nil, err
+	}
+	return &grpcVirsh.OkResponse{Ok: true}, nil
+}
@@ -0,0 +1,152 @@
+package virsh
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	libvirt "libvirt.org/go/libvirt"
+)
+
+func TestSnapshotDisksSkipsCdromAndBlockDevices(t *testing.T) {
+	input := `<domain type='kvm'>
+  <name>web</name>
+  <devices>
+    <disk type='file' device='disk'>
+      <source file='/mnt/share/web/web.qcow2'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+    <disk type='file' device='cdrom'>
+      <source file='/mnt/isos/debian.iso'/>
+      <target dev='sda' bus='sata'/>
+    </disk>
+    <disk type='block' device='disk'>
+      <source dev='/dev/sdb'/>
+      <target dev='vdb' bus='virtio'/>
+    </disk>
+  </devices>
+</domain>`
+
+	disks, err := snapshotDisks(input)
+	if err != nil {
+		t.Fatalf("snapshotDisks returned error: %v", err)
+	}
+	if len(disks) != 1 {
+		t.Fatalf("expected 1 disk, got %d: %+v", len(disks), disks)
+	}
+	if disks[0].Target != "vda" || disks[0].File != "/mnt/share/web/web.qcow2" {
+		t.Fatalf("unexpected disk %+v", disks[0])
+	}
+}
+
+func TestBuildSnapshotXMLExternalDiskOnly(t *testing.T) {
+	disks := []snapshotTargetDisk{{Target: "vda", File: "/mnt/share/web/web.qcow2"}}
+	out, flags, err := buildSnapshotXML(SnapshotOptions{Name: "pre-upgrade", DiskOnly: true}, disks, true)
+	if err != nil {
+		t.Fatalf("buildSnapshotXML returned error: %v", err)
+	}
+
+	if flags&libvirt.DOMAIN_SNAPSHOT_CREATE_DISK_ONLY == 0 {
+		t.Fatalf("expected disk only flag, got %v", flags)
+	}
+	if !strings.Contains(out, `file="/mnt/share/web/web.pre-upgrade.qcow2"`) {
+		t.Fatalf("expected overlay next to the disk, got:\n%s", out)
+	}
+	if !strings.Contains(out, `<memory snapshot="no"></memory>`) {
+		t.Fatalf("expected memory snapshot disabled, got:\n%s", out)
+	}
+}
+
+func TestBuildSnapshotXMLExternalWithMemory(t *testing.T) {
+	disks := []snapshotTargetDisk{{Target: "vda", File: "/mnt/share/web/web.qcow2"}}
+	out, flags, err := buildSnapshotXML(SnapshotOptions{Name: "s1", DiskOnly: true, IncludeMemory: true}, disks, true)
+	if err != nil {
+		t.Fatalf("buildSnapshotXML returned error: %v", err)
+	}
+
+	if flags&libvirt.DOMAIN_SNAPSHOT_CREATE_DISK_ONLY != 0 {
+		t.Fatalf("disk only flag must not be set with memory, got %v", flags)
+	}
+	if !strings.Contains(out, `file="/mnt/share/web/web.s1.mem"`) {
+		t.Fatalf("expected memory file next to the disk, got:\n%s", out)
+	}
+}
+
+func TestBuildSnapshotXMLRejectsInvalidMemoryCombos(t *testing.T) {
+	disks := []snapshotTargetDisk{{Target: "vda", File: "/mnt/share/web/web.qcow2"}}
+
+	if _, _, err := buildSnapshotXML(SnapshotOptions{Name: "s1", IncludeMemory: true}, disks, false); err == nil {
+		t.Fatalf("expected error saving memory of a stopped vm")
+	}
+	if _, _, err := buildSnapshotXML(SnapshotOptions{Name: "s1"}, disks, true); err == nil {
+		t.Fatalf("expected error for internal snapshot of a running vm without memory")
+	}
+	if _, _, err := buildSnapshotXML(SnapshotOptions{Name: "s1"}, disks, false); err != nil {
+		t.Fatalf("internal snapshot of a stopped vm should be allowed: %v", err)
+	}
+}
+
+func TestExternalSnapshotFiles(t *testing.T) {
+	input := `<domainsnapshot>
+  <name>before-upgrade</name>
+  <memory snapshot='external' file='/mnt/share/web/web.before-upgrade.mem'/>
+  <disks>
+    <disk name='vda' snapshot='external' type='file'>
+      <driver type='qcow2'/>
+      <source file='/mnt/share/web/web.before-upgrade.qcow2'/>
+    </disk>
+    <disk name='sda' snapshot='no'/>
+  </disks>
+</domainsnapshot>`
+
+	var doc snapshotDomainXML
+	if err := xml.Unmarshal([]byte(input), &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	overlays, memFile := externalSnapshotFiles(doc)
+	if len(overlays) != 1 || overlays[0].Target != "vda" || overlays[0].File != "/mnt/share/web/web.before-upgrade.qcow2" {
+		t.Fatalf("unexpected overlays %+v", overlays)
+	}
+	if memFile != "/mnt/share/web/web.before-upgrade.mem" {
+		t.Fatalf("unexpected memory file %q", memFile)
+	}
+
+	internal := snapshotDomainXML{Name: "daily", Memory: &snapshotMemoryXML{Snapshot: "internal"}}
+	if overlays, memFile := externalSnapshotFiles(internal); len(overlays) != 0 || memFile != "" {
+		t.Fatalf("internal snapshot has no files, got %+v %q", overlays, memFile)
+	}
+}
+
+func TestWaitBlockCommitPivot(t *testing.T) {
+	blockCopyPollInterval = time.Millisecond
+	t.Cleanup(func() { blockCopyPollInterval = time.Second })
+	behind := libvirt.DomainBlockJobInfo{Type: libvirt.DOMAIN_BLOCK_JOB_TYPE_ACTIVE_COMMIT, Cur: 50, End: 100}
+	synced := libvirt.DomainBlockJobInfo{Type: libvirt.DOMAIN_BLOCK_JOB_TYPE_ACTIVE_COMMIT, Cur: 100, End: 100}
+
+	t.Run("pivots once in sync", func(t *testing.T) {
+		job := &fakeBlockCopyJob{infos: []libvirt.DomainBlockJobInfo{behind, synced}}
+		if err := waitBlockCommitPivot(context.Background(), job, "vda"); err != nil {
+			t.Fatalf("waitBlockCommitPivot returned error: %v", err)
+		}
+		if !slices.Equal(job.aborts, []libvirt.DomainBlockJobAbortFlags{libvirt.DOMAIN_BLOCK_JOB_ABORT_PIVOT}) {
+			t.Fatalf("expected a single pivot, got %v", job.aborts)
+		}
+	})
+
+	t.Run("aborts when the commit never catches up", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		job := &fakeBlockCopyJob{infos: []libvirt.DomainBlockJobInfo{behind}}
+		err := waitBlockCommitPivot(ctx, job, "vda")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected a deadline error, got %v", err)
+		}
+		if len(job.aborts) != 1 || job.aborts[0] != 0 {
+			t.Fatalf("expected a plain abort, got %v", job.aborts)
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package virsh
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	grpcVirsh "github.com/Maruqes/512SvMan/api/proto/virsh"
+	"github.com/Maruqes/512SvMan/logger"
+	libvirt "libvirt.org/go/libvirt"
+)
+
+// vmStatsInterval is how long the counters of each domain are sampled for,
+// the domains are sampled at the same time.
+const vmStatsInterval = time.Second
+
+type statsDevice struct {
+	dev  string
+	path string // disk source or interface mac
+}
+
+// statsDevicesFromXML returns the disks and the host side interfaces of a
+// domain, the names BlockStats and InterfaceStats take.
+func statsDevicesFromXML(xmlDesc string) (disks, ifaces []statsDevice, err error) {
+	var d struct {
+		Devices struct {
+			Disks []struct {
+				Device string `xml:"device,attr"`
+				Source struct {
+					File string `xml:"file,attr"`
+					Dev  string `xml:"dev,attr"`
+				} `xml:"source"`
+				Target struct {
+					Dev string `xml:"dev,attr"`
+				} `xml:"target"`
+			} `xml:"disk"`
+			Interfaces []struct {
+				Mac struct {
+					Address string `xml:"address,attr"`
+				} `xml:"mac"`
+				Target struct {
+					Dev string `xml:"dev,attr"`
+				} `xml:"target"`
+			} `xml:"interface"`
+		} `xml:"devices"`
+	}
+	if err := xml.Unmarshal([]byte(xmlDesc), &d); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	for _, disk := range d.Devices.Disks {
+		dev := strings.TrimSpace(disk.Target.Dev)
+		if disk.Device != "disk" || dev == "" {
+			continue
+		}
+		source := disk.Source.File
+		if source == "" {
+			source = disk.Source.Dev
+		}
+		disks = append(disks, statsDevice{dev: dev, path: source})
+	}
+	for _, iface := range d.Devices.Interfaces {
+		dev := strings.TrimSpace(iface.Target.Dev)
+		if dev == "" {
+			continue
+		}
+		ifaces = append(ifaces, statsDevice{dev: dev, path: iface.Mac.Address})
+	}
+	return disks, ifaces, nil
+}
+
+// perSecond turns two samples of a counter into a rate, a counter that went
+// back (domain restarted) counts as no traffic.
+func perSecond(before, after int64, elapsed time.Duration) float64 {
+	if after < before || elapsed <= 0 {
+		return 0
+	}
+	return float64(after-before) / elapsed.Seconds()
+}
+
+type statsCounters struct {
+	blocks map[string]*libvirt.DomainBlockStats
+	ifaces map[string]*libvirt.DomainInterfaceStats
+}
+
+func sampleStatsCounters(dom *libvirt.Domain, disks, ifaces []statsDevice) statsCounters {
+	c := statsCounters{
+		blocks: make(map[string]*libvirt.DomainBlockStats, len(disks)),
+		ifaces: make(map[string]*libvirt.DomainInterfaceStats, len(ifaces)),
+	}
+	for _, disk := range disks {
+		if stats, err := dom.BlockStats(disk.dev); err == nil {
+			c.blocks[disk.dev] = stats
+		}
+	}
+	for _, iface := range ifaces {
+		if stats, err := dom.InterfaceStats(iface.dev); err == nil {
+			c.ifaces[iface.dev] = stats
+		}
+	}
+	return c
+}
+
+func domainStats(dom *libvirt.Domain, name string) (*grpcVirsh.VmStats, error) {
+	xmlDesc, err := dom.GetXMLDesc(0)
+	if err != nil {
+		return nil, fmt.Errorf("xml: %w", err)
+	}
+	disks, ifaces, err := statsDevicesFromXML(xmlDesc)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &grpcVirsh.VmStats{Name: name}
+	if totalKiB, usedKiB, rssKiB, err := getMemStats(dom); err == nil {
+		stats.MemTotalKib = totalKiB
+		stats.MemUsedKib = usedKiB
+		stats.MemRssKib = rssKiB
+	}
+
+	start := time.Now()
+	before := sampleStatsCounters(dom, disks, ifaces)
+	pct, vcpus, err := cpuPercentOver(dom, vmStatsInterval)
+	if err != nil {
+		return nil, err
+	}
+	after := sampleStatsCounters(dom, disks, ifaces)
+	elapsed := time.Since(start)
+	stats.CpuPercent = pct
+	stats.Vcpus = int32(vcpus)
+
+	for _, disk := range disks {
+		b, a := before.blocks[disk.dev], after.blocks[disk.dev]
+		if b == nil || a == nil {
+			continue
+		}
+		stats.Disks = append(stats.Disks, &grpcVirsh.VmDiskStats{
+			Device:           disk.dev,
+			Path:             disk.path,
+			ReadIops:         perSecond(b.RdReq, a.RdReq, elapsed),
+			WriteIops:        perSecond(b.WrReq, a.WrReq, elapsed),
+			ReadBytesPerSec:  perSecond(b.RdBytes, a.RdBytes, elapsed),
+			WriteBytesPerSec: perSecond(b.WrBytes, a.WrBytes, elapsed),
+			ReadBytes:        uint64(a.RdBytes),
+			WriteBytes:       uint64(a.WrBytes),
+		})
+	}
+	for _, iface := range ifaces {
+		b, a := before.ifaces[iface.dev], after.ifaces[iface.dev]
+		if b == nil || a == nil {
+			continue
+		}
+		stats.Interfaces = append(stats.Interfaces, &grpcVirsh.VmInterfaceStats{
+			Device:          iface.dev,
+			Mac:             iface.path,
+			RxBytesPerSec:   perSecond(b.RxBytes, a.RxBytes, elapsed),
+			TxBytesPerSec:   perSecond(b.TxBytes, a.TxBytes, elapsed),
+			RxPacketsPerSec: perSecond(b.RxPackets, a.RxPackets, elapsed),
+			TxPacketsPerSec: perSecond(b.TxPackets, a.TxPackets, elapsed),
+			RxBytes:         uint64(a.RxBytes),
+			TxBytes:         uint64(a.TxBytes),
+		})
+	}
+	return stats, nil
+}
+
+// GetVmStats samples cpu, memory, disk and network usage of every running
+// domain on this slave.
+func GetVmStats() (*grpcVirsh.VmStatsResponse, error) {
+	conn, err := libvirt.NewConnect("qemu:///system")
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	doms, err := conn.ListAllDomains(libvirt.CONNECT_LIST_DOMAINS_RUNNING)
+	if err != nil {
+		return nil, fmt.Errorf("list domains: %w", err)
+	}
+
+	resp := &grpcVirsh.VmStatsResponse{}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for i := range doms {
+		wg.Add(1)
+		go func(dom libvirt.Domain) {
+			defer wg.Done()
+			defer dom.Free()
+
+			name, err := dom.GetName()
+			if err != nil {
+				return
+			}
+			stats, err := domainStats(&dom, name)
+			if err != nil {
+				logger.Debugf("vm stats of %s: %v", name, err)
+				return
+			}
+			mu.Lock()
+			resp.Vms = append(resp.Vms, stats)
+			mu.Unlock()
+		}(doms[i])
+	}
+	wg.Wait()
+	return resp, nil
+}
@@ -0,0 +1,40 @@
+package virsh
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsDevicesFromXML(t *testing.T) {
+	const domXML = `<domain type="kvm"><name>web</name><devices>
+		<disk type="file" device="disk"><source file="/mnt/share1/web/web.qcow2"/><target dev="vda" bus="virtio"/></disk>
+		<disk type="block" device="disk"><source dev="/dev/sdb"/><target dev="vdb" bus="virtio"/></disk>
+		<disk type="file" device="cdrom"><source file="/isos/debian.iso"/><target dev="sda" bus="sata"/></disk>
+		<interface type="network"><mac address="52:54:00:aa:bb:cc"/><source network="default"/><target dev="vnet3"/></interface>
+		<interface type="network"><mac address="52:54:00:aa:bb:cd"/><source network="default"/></interface>
+	</devices></domain>`
+
+	disks, ifaces, err := statsDevicesFromXML(domXML)
+	if err != nil {
+		t.Fatalf("statsDevicesFromXML returned error: %v", err)
+	}
+	if len(disks) != 2 || disks[0] != (statsDevice{dev: "vda", path: "/mnt/share1/web/web.qcow2"}) ||
+		disks[1] != (statsDevice{dev: "vdb", path: "/dev/sdb"}) {
+		t.Fatalf("unexpected disks: %+v", disks)
+	}
+	if len(ifaces) != 1 || ifaces[0] != (statsDevice{dev: "vnet3", path: "52:54:00:aa:bb:cc"}) {
+		t.Fatalf("unexpected interfaces: %+v", ifaces)
+	}
+}
+
+func TestPerSecond(t *testing.T) {
+	if got := perSecond(100, 300, 2*time.Second); got != 100 {
+		t.Fatalf("perSecond = %v, want 100", got)
+	}
+	if got := perSecond(300, 100, 2*time.Second); got != 0 {
+		t.Fatalf("counter reset gave %v, want 0", got)
+	}
+	if got := perSecond(100, 300, 0); got != 0 {
+		t.Fatalf("zero interval gave %v, want 0", got)
+	}
+}
@@ -108,6 +108,59 @@ func UpdateVMXml(vmName, vmXml string) error {
 	return nil
 }
 
+// DefineVMFromXML defines a VM from a domain XML kept by the master, used to
+// restart a VM whose host died. A domain that is already running here is left
+// alone so the same VM never runs twice.
+func DefineVMFromXML(vmName, vmXml string, start bool) error {
+	vmName = strings.TrimSpace(vmName)
+	if vmName == "" {
+		return fmt.Errorf("vm name is empty")
+	}
+
+	vmXml = strings.TrimSpace(vmXml)
+	if vmXml == "" {
+		return fmt.Errorf("vm xml is empty")
+	}
+
+	var parsed domainNameOnly
+	if err := xml.Unmarshal([]byte(vmXml), &parsed); err != nil {
+		return fmt.Errorf("parse domain xml: %w", err)
+	}
+	if strings.TrimSpace(parsed.Name) != vmName {
+		return fmt.Errorf("domain xml name %q must match target VM %q", parsed.Name, vmName)
+	}
+
+	conn, err := libvirt.NewConnect("qemu:///system")
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close()
+
+	if existing, err := conn.LookupDomainByName(vmName); err == nil {
+		state, _, err := existing.GetState()
+		existing.Free()
+		if err != nil {
+			return fmt.Errorf("get state: %w", err)
+		}
+		if state != libvirt.DOMAIN_SHUTOFF {
+			return fmt.Errorf("domain %s is already running on this host", vmName)
+		}
+	}
+
+	dom, err := conn.DomainDefineXMLFlags(vmXml, libvirt.DOMAIN_DEFINE_VALIDATE)
+	if err != nil {
+		return fmt.Errorf("define domain: %w", err)
+	}
+	defer dom.Free()
+
+	if start {
+		if err := dom.Create(); err != nil {
+			return fmt.Errorf("start: %w", err)
+		}
+	}
+	return nil
+}
+
 func ensureDomainXMLUUID(vmXML, uuid string) (string, error) {
 	vmXML = strings.TrimSpace(vmXML)
 	uuid = strings.TrimSpace(uuid)
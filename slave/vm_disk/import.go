@@ -0,0 +1,277 @@
+package vmdisk
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slave/extra"
+	"strings"
+	"time"
+
+	extraGrpc "github.com/Maruqes/512SvMan/api/proto/extra"
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+type imageChecksum struct {
+	Algorithm string
+	Sum       string
+}
+
+// parseImageChecksum accepts "sha256:<hex>", "sha512:<hex>" or a bare hex digest
+// (the algorithm is then picked from its length). Empty means no verification.
+func parseImageChecksum(raw string) (*imageChecksum, error) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "" {
+		return nil, nil
+	}
+
+	algorithm, sum, found := strings.Cut(raw, ":")
+	if !found {
+		sum = algorithm
+		switch len(sum) {
+		case sha256.Size * 2:
+			algorithm = "sha256"
+		case sha512.Size * 2:
+			algorithm = "sha512"
+		default:
+			return nil, fmt.Errorf("cannot guess checksum type from a %d character digest, use sha256:<hex> or sha512:<hex>", len(sum))
+		}
+	}
+
+	want := 0
+	switch algorithm {
+	case "sha256":
+		want = sha256.Size * 2
+	case "sha512":
+		want = sha512.Size * 2
+	default:
+		return nil, fmt.Errorf("unsupported checksum type %q, use sha256 or sha512", algorithm)
+	}
+	if len(sum) != want {
+		return nil, fmt.Errorf("%s checksum must have %d hex characters", algorithm, want)
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return nil, fmt.Errorf("checksum is not valid hex: %w", err)
+	}
+	return &imageChecksum{Algorithm: algorithm, Sum: sum}, nil
+}
+
+func (c *imageChecksum) newHash() hash.Hash {
+	if c.Algorithm == "sha512" {
+		return sha512.New()
+	}
+	return sha256.New()
+}
+
+// qemu-img format names of the images we accept as import source
+func normalizeImportSourceFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "":
+		return "", nil
+	case "qcow", "qcow2":
+		return "qcow2", nil
+	case "raw", "img":
+		return "raw", nil
+	case "vmdk":
+		return "vmdk", nil
+	case "vhdx":
+		return "vhdx", nil
+	default:
+		return "", fmt.Errorf("unsupported source format %q, use qcow2, raw, vmdk or vhdx", format)
+	}
+}
+
+// ImportVMDisk downloads a disk image into a new vm_disk_<name> folder, verifies
+// its checksum and converts it with qemu-img to the requested format.
+func ImportVMDisk(ctx context.Context, basePath, name, url, format, sourceFormat, checksum string) (*VMDisk, error) {
+	basePath = strings.TrimSpace(basePath)
+	name = strings.TrimSpace(name)
+	url = strings.TrimSpace(url)
+
+	if err := validateVMDiskName(name); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("url must be http or https")
+	}
+	if strings.TrimSpace(format) == "" {
+		format = "qcow2"
+	}
+	qemuFormat, extension, err := normalizeVMDiskFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	srcFormat, err := normalizeImportSourceFormat(sourceFormat)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := parseImageChecksum(checksum)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireQemuImg(); err != nil {
+		return nil, err
+	}
+
+	cleanBase, err := cleanExistingBasePath(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	folderPath := filepath.Join(cleanBase, "vm_disk_"+name)
+	if err := ensurePathInsideBase(cleanBase, folderPath); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(folderPath); err == nil {
+		return nil, fmt.Errorf("vm disk folder already exists: %s", folderPath)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("stat vm disk folder %s: %w", folderPath, err)
+	}
+	if err := os.Mkdir(folderPath, 0o777); err != nil {
+		return nil, fmt.Errorf("create vm disk folder %s: %w", folderPath, err)
+	}
+
+	diskPath := filepath.Join(folderPath, name+extension)
+	downloadPath := filepath.Join(folderPath, name+".download")
+	cleanup := func() {
+		if err := os.Remove(downloadPath); err != nil && !os.IsNotExist(err) {
+			logger.Warn("failed to cleanup vm disk download", "path", downloadPath, "error", err)
+		}
+		cleanupVMDiskCreate(folderPath, diskPath)
+	}
+
+	if err := downloadDiskImage(ctx, url, downloadPath, name, sum); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	info, err := readQemuImgInfo(downloadPath)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	probed, err := normalizeImportSourceFormat(info.Format)
+	if err != nil {
+		// xz/gz compressed images are probed as raw, anything else is not an image we handle
+		cleanup()
+		return nil, fmt.Errorf("downloaded image: %w", err)
+	}
+	if srcFormat != "" && srcFormat != probed {
+		cleanup()
+		return nil, fmt.Errorf("downloaded image is %s, expected %s", probed, srcFormat)
+	}
+	if info.BackingFilename != "" {
+		// a backing file would point at a path on this host, never trust that from a download
+		cleanup()
+		return nil, fmt.Errorf("downloaded image has a backing file (%s), only standalone images can be imported", info.BackingFilename)
+	}
+
+	cmd := exec.CommandContext(ctx, "qemu-img", "convert", "-f", probed, "-O", qemuFormat, downloadPath, diskPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		cleanup()
+		msg := strings.TrimSpace(string(out))
+		if msg != "" {
+			return nil, fmt.Errorf("qemu-img convert %s: %s", downloadPath, msg)
+		}
+		return nil, fmt.Errorf("qemu-img convert %s: %w", downloadPath, err)
+	}
+	if err := os.Remove(downloadPath); err != nil {
+		logger.Warn("failed to remove vm disk download", "path", downloadPath, "error", err)
+	}
+
+	if err := os.Chmod(diskPath, 0o777); err != nil {
+		cleanupVMDiskCreate(folderPath, diskPath)
+		return nil, fmt.Errorf("chmod vm disk %s: %w", diskPath, err)
+	}
+	if err := os.Chmod(folderPath, 0o777); err != nil {
+		cleanupVMDiskCreate(folderPath, diskPath)
+		return nil, fmt.Errorf("chmod vm disk folder %s: %w", folderPath, err)
+	}
+
+	disk, err := diskInfoFromPath(diskPath, folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("VM disk imported", "name", name, "url", url, "sourceFormat", probed, "format", disk.Format, "sizeGB", disk.SizeGB, "path", diskPath)
+	return disk, nil
+}
+
+// downloadDiskImage streams url into dest hashing on the fly so the checksum
+// doesn't need a second pass over a multi GB file.
+func downloadDiskImage(ctx context.Context, url, dest, name string, sum *imageChecksum) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Accept", "*/*")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s: HTTP %s", url, resp.Status)
+	}
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dest, err)
+	}
+	defer f.Close()
+
+	var writer io.Writer = f
+	var hasher hash.Hash
+	if sum != nil {
+		hasher = sum.newHash()
+		writer = io.MultiWriter(f, hasher)
+	}
+
+	progress := &downloadProgress{name: name, total: resp.ContentLength, identifier: fmt.Sprintf("vm-disk-%s-%d", name, time.Now().Unix())}
+	if _, err := io.Copy(writer, io.TeeReader(resp.Body, progress)); err != nil {
+		return fmt.Errorf("download %s: %w", url, err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %w", dest, err)
+	}
+
+	if sum != nil {
+		got := hex.EncodeToString(hasher.Sum(nil))
+		if got != sum.Sum {
+			return fmt.Errorf("%s checksum mismatch: got %s, want %s", sum.Algorithm, got, sum.Sum)
+		}
+	}
+	return nil
+}
+
+type downloadProgress struct {
+	name       string
+	identifier string
+	total      int64
+	done       int64
+	lastSent   time.Time
+}
+
+func (p *downloadProgress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if time.Since(p.lastSent) < 2*time.Second {
+		return len(b), nil
+	}
+	p.lastSent = time.Now()
+
+	msg := fmt.Sprintf("Disk image %s: %.2f MB", p.name, float64(p.done)/1024/1024)
+	if p.total > 0 {
+		msg = fmt.Sprintf("Disk image %s: %d / %d (%.2f%%)", p.name, p.done, p.total, 100*float64(p.done)/float64(p.total))
+	}
+	extra.SendWebsocketMessage(msg, p.identifier, extraGrpc.WebSocketsMessageType_DownloadIso)
+	return len(b), nil
+}
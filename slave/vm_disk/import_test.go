@@ -0,0 +1,99 @@
+package vmdisk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseImageChecksum(t *testing.T) {
+	sha256Hex := strings.Repeat("ab", 32)
+	sha512Hex := strings.Repeat("cd", 64)
+
+	cases := []struct {
+		in      string
+		algo    string
+		wantErr bool
+	}{
+		{in: "", algo: ""},
+		{in: "sha256:" + sha256Hex, algo: "sha256"},
+		{in: "SHA512:" + strings.ToUpper(sha512Hex), algo: "sha512"},
+		{in: sha256Hex, algo: "sha256"},
+		{in: sha512Hex, algo: "sha512"},
+		{in: "md5:d41d8cd98f00b204e9800998ecf8427e", wantErr: true},
+		{in: "sha256:" + sha512Hex, wantErr: true},
+		{in: "sha256:" + strings.Repeat("zz", 32), wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+	for _, tc := range cases {
+		sum, err := parseImageChecksum(tc.in)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("parseImageChecksum(%q) expected error", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("parseImageChecksum(%q) returned error: %v", tc.in, err)
+		}
+		if tc.algo == "" {
+			if sum != nil {
+				t.Fatalf("parseImageChecksum(%q) = %+v, want nil", tc.in, sum)
+			}
+			continue
+		}
+		if sum == nil || sum.Algorithm != tc.algo {
+			t.Fatalf("parseImageChecksum(%q) = %+v, want %s", tc.in, sum, tc.algo)
+		}
+	}
+}
+
+func TestImportVMDiskConvertsRawToQcow2(t *testing.T) {
+	if _, err := exec.LookPath("qemu-img"); err != nil {
+		t.Skip("qemu-img not available")
+	}
+
+	src := filepath.Join(t.TempDir(), "image.raw")
+	if out, err := exec.Command("qemu-img", "create", "-f", "raw", src, "16M").CombinedOutput(); err != nil {
+		t.Fatalf("qemu-img create: %v: %s", err, out)
+	}
+	body, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(body)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	base := t.TempDir()
+	if _, err := ImportVMDisk(context.Background(), base, "bad", srv.URL, "qcow2", "raw", "sha256:"+strings.Repeat("00", 32)); err == nil {
+		t.Fatalf("ImportVMDisk accepted a wrong checksum")
+	}
+	if _, err := os.Stat(filepath.Join(base, "vm_disk_bad")); !os.IsNotExist(err) {
+		t.Fatalf("failed import left its folder behind: %v", err)
+	}
+
+	disk, err := ImportVMDisk(context.Background(), base, "cloud", srv.URL, "qcow2", "", "sha256:"+hex.EncodeToString(digest[:]))
+	if err != nil {
+		t.Fatalf("ImportVMDisk returned error: %v", err)
+	}
+	if disk.Format != "qcow2" {
+		t.Fatalf("format = %q, want qcow2", disk.Format)
+	}
+	if disk.DiskPath != filepath.Join(base, "vm_disk_cloud", "cloud.qcow2") {
+		t.Fatalf("unexpected disk path %q", disk.DiskPath)
+	}
+	if _, err := os.Stat(filepath.Join(base, "vm_disk_cloud", "cloud.download")); !os.IsNotExist(err) {
+		t.Fatalf("download file was not removed: %v", err)
+	}
+}
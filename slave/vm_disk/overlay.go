@@ -0,0 +1,130 @@
+package vmdisk
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Maruqes/512SvMan/logger"
+)
+
+// overlayBackingRef returns the backing file as seen from the overlay folder.
+// A relative reference keeps the chain valid on every host, whatever path the
+// share is mounted on.
+func overlayBackingRef(backingPath, diskPath string) (string, error) {
+	rel, err := filepath.Rel(filepath.Dir(diskPath), backingPath)
+	if err != nil {
+		return "", fmt.Errorf("resolve backing path: %w", err)
+	}
+	return rel, nil
+}
+
+// CreateOverlayDisk creates a thin qcow2 at diskPath that reads unchanged
+// blocks from backingPath. Both must live on the same share.
+func CreateOverlayDisk(backingPath, diskPath string) (*VMDisk, error) {
+	backingPath = filepath.Clean(strings.TrimSpace(backingPath))
+	diskPath = filepath.Clean(strings.TrimSpace(diskPath))
+	if !filepath.IsAbs(backingPath) || !filepath.IsAbs(diskPath) {
+		return nil, fmt.Errorf("backing and disk paths must be absolute")
+	}
+	if err := requireQemuImg(); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(diskPath); err == nil {
+		return nil, fmt.Errorf("disk %s already exists", diskPath)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("stat disk %s: %w", diskPath, err)
+	}
+	backing, err := readQemuImgInfo(backingPath)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := overlayBackingRef(backingPath, diskPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// vm clones get their folder from the master, vm disk clones need it created here
+	folderPath := filepath.Dir(diskPath)
+	createdFolder := ""
+	if info, err := os.Stat(folderPath); os.IsNotExist(err) {
+		if err := os.Mkdir(folderPath, 0o777); err != nil {
+			return nil, fmt.Errorf("create disk folder %s: %w", folderPath, err)
+		}
+		if err := os.Chmod(folderPath, 0o777); err != nil {
+			cleanupVMDiskCreate(folderPath, "")
+			return nil, fmt.Errorf("chmod disk folder %s: %w", folderPath, err)
+		}
+		createdFolder = folderPath
+	} else if err != nil {
+		return nil, fmt.Errorf("stat disk folder %s: %w", folderPath, err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("disk folder path is not a directory: %s", folderPath)
+	}
+
+	cmd := exec.Command("qemu-img", "create", "-f", "qcow2", "-b", ref, "-F", strings.ToLower(backing.Format), diskPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		cleanupVMDiskCreate(createdFolder, diskPath)
+		msg := strings.TrimSpace(string(out))
+		if msg != "" {
+			return nil, fmt.Errorf("qemu-img create %s: %s", diskPath, msg)
+		}
+		return nil, fmt.Errorf("qemu-img create %s: %w", diskPath, err)
+	}
+	if err := os.Chmod(diskPath, 0o777); err != nil {
+		cleanupVMDiskCreate(createdFolder, diskPath)
+		return nil, fmt.Errorf("chmod overlay %s: %w", diskPath, err)
+	}
+
+	disk, err := diskInfoFromPath(diskPath, folderPath)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Overlay disk created", "path", diskPath, "backing", ref)
+	return disk, nil
+}
+
+// FlattenDisk copies every block the overlay reads from its backing chain into
+// the overlay itself and drops the backing reference. The disk must not be in use.
+func FlattenDisk(diskPath string) (*VMDisk, error) {
+	diskPath = filepath.Clean(strings.TrimSpace(diskPath))
+	if !filepath.IsAbs(diskPath) {
+		return nil, fmt.Errorf("disk path must be absolute")
+	}
+	if err := requireQemuImg(); err != nil {
+		return nil, err
+	}
+
+	info, err := readQemuImgInfo(diskPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.BackingFilename == "" {
+		return diskFromQemuInfo(diskPath, filepath.Dir(diskPath), info), nil
+	}
+	if !strings.EqualFold(info.Format, "qcow2") {
+		return nil, fmt.Errorf("only qcow2 overlays can be flattened, %s is %s", diskPath, info.Format)
+	}
+
+	// safe mode rebase onto nothing copies the data the overlay was reading from the chain
+	cmd := exec.Command("qemu-img", "rebase", "-f", "qcow2", "-b", "", diskPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg != "" {
+			return nil, fmt.Errorf("qemu-img rebase %s: %s", diskPath, msg)
+		}
+		return nil, fmt.Errorf("qemu-img rebase %s: %w", diskPath, err)
+	}
+
+	updated, err := readQemuImgInfo(diskPath)
+	if err != nil {
+		return nil, err
+	}
+	if updated.BackingFilename != "" {
+		return nil, fmt.Errorf("disk %s still has backing file %s after flatten", diskPath, updated.BackingFilename)
+	}
+	logger.Info("Disk flattened", "path", diskPath, "oldBacking", info.BackingFilename)
+	return diskFromQemuInfo(diskPath, filepath.Dir(diskPath), updated), nil
+}
@@ -0,0 +1,55 @@
+package vmdisk
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverlayBackingRefIsRelative(t *testing.T) {
+	ref, err := overlayBackingRef("/mnt/share/golden/golden.qcow2", "/mnt/share/clone1/clone1.qcow2")
+	if err != nil {
+		t.Fatalf("overlayBackingRef returned error: %v", err)
+	}
+	if ref != "../golden/golden.qcow2" {
+		t.Fatalf("ref = %q, want ../golden/golden.qcow2", ref)
+	}
+}
+
+func TestCreateOverlayDiskAndFlatten(t *testing.T) {
+	if _, err := exec.LookPath("qemu-img"); err != nil {
+		t.Skip("qemu-img not available")
+	}
+
+	base := t.TempDir()
+	golden, err := CreateVMDisk(base, "golden", 1, "qcow2")
+	if err != nil {
+		t.Fatalf("CreateVMDisk returned error: %v", err)
+	}
+
+	clonePath := filepath.Join(base, "vm_disk_clone", "clone.qcow2")
+	if _, err := CreateOverlayDisk(golden.DiskPath, clonePath); err != nil {
+		t.Fatalf("CreateOverlayDisk returned error: %v", err)
+	}
+	info, err := readQemuImgInfo(clonePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.BackingFilename != "../vm_disk_golden/golden.qcow2" {
+		t.Fatalf("backing = %q", info.BackingFilename)
+	}
+	if _, err := CreateOverlayDisk(golden.DiskPath, clonePath); err == nil {
+		t.Fatalf("CreateOverlayDisk overwrote an existing disk")
+	}
+
+	if _, err := FlattenDisk(clonePath); err != nil {
+		t.Fatalf("FlattenDisk returned error: %v", err)
+	}
+	info, err = readQemuImgInfo(clonePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.BackingFilename != "" {
+		t.Fatalf("backing still set after flatten: %q", info.BackingFilename)
+	}
+}
@@ -42,6 +42,30 @@ func (s *Service) GetVMDiskInfo(ctx context.Context, req *pb.VMDiskByNameRequest
 	return vmDiskResponse(disk), nil
 }
 
+func (s *Service) ImportVMDisk(ctx context.Context, req *pb.ImportVMDiskRequest) (*pb.VMDiskResponse, error) {
+	disk, err := ImportVMDisk(ctx, req.BasePath, req.Name, req.Url, req.Format, req.SourceFormat, req.Checksum)
+	if err != nil {
+		return &pb.VMDiskResponse{Ok: false}, err
+	}
+	return vmDiskResponse(disk), nil
+}
+
+func (s *Service) CreateOverlayDisk(ctx context.Context, req *pb.CreateOverlayDiskRequest) (*pb.VMDiskResponse, error) {
+	disk, err := CreateOverlayDisk(req.BackingPath, req.DiskPath)
+	if err != nil {
+		return &pb.VMDiskResponse{Ok: false}, err
+	}
+	return vmDiskResponse(disk), nil
+}
+
+func (s *Service) FlattenDisk(ctx context.Context, req *pb.VMDiskByPathRequest) (*pb.VMDiskResponse, error) {
+	disk, err := FlattenDisk(req.DiskPath)
+	if err != nil {
+		return &pb.VMDiskResponse{Ok: false}, err
+	}
+	return vmDiskResponse(disk), nil
+}
+
 func vmDiskResponse(disk *VMDisk) *pb.VMDiskResponse {
 	return &pb.VMDiskResponse{
 		Ok:            true,
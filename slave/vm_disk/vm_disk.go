@@ -26,6 +26,8 @@ type qemuImgInfo struct {
 	Format      string `json:"format"`
 	VirtualSize int64  `json:"virtual-size"`
 	ActualSize  int64  `json:"actual-size"`
+	// set when the image is an overlay on top of another file
+	BackingFilename string `json:"backing-filename"`
 }
 
 const bytesInGB = int64(1024 * 1024 * 1024)